@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/maven"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+	"github.com/xshuden/sbom-scanner/pkg/scanner"
+)
+
+// RunConfigCommand handles "sbom-scanner config init [path]"
+func RunConfigCommand(args []string) error {
+	if len(args) == 0 || args[0] != "init" {
+		return fmt.Errorf("usage: sbom-scanner config init [path]")
+	}
+
+	path := scanner.ConfigFileNames[0]
+	if len(args) > 1 {
+		path = args[1]
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("config file already exists: %s", path)
+	}
+
+	projectFile := "data/pom.xml"
+	if file := sbom.FindProjectFile(filepath.Dir(path)); file != "" {
+		projectFile = filepath.Base(file)
+	}
+
+	if err := os.WriteFile(path, []byte(fmt.Sprintf(scanner.ConfigTemplate, projectFile, maven.DefaultCycloneDXPluginVersion)), 0644); err != nil {
+		return fmt.Errorf("failed to write config: %v", err)
+	}
+
+	runenv.Logger.Infof("Config file written to %s", path)
+	return nil
+}
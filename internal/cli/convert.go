@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+// RunConvertCommand handles "sbom-scanner convert <input> -o <output>", which
+// translates an SBOM between CycloneDX and SPDX
+func RunConvertCommand(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	output := fs.String("o", "-", "Converted SBOM, - for stdout")
+	fs.StringVar(output, "output", "-", "Converted SBOM, - for stdout")
+	to := fs.String("to", "", "Output format: "+strings.Join(sbom.ConvertFormats, ", ")+" (default: from the file extension)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: sbom-scanner convert <sbom> [-o output] [--to cyclonedx-xml|cyclonedx-json|spdx-json|spdx-tag-value]")
+	}
+
+	paths, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(paths) != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one SBOM file")
+	}
+	format := *to
+	if format == "" {
+		if format = sbom.ConvertFormatOf(*output); format == "" {
+			fs.Usage()
+			return fmt.Errorf("unknown output format, set --to (available: %s)", strings.Join(sbom.ConvertFormats, ", "))
+		}
+	}
+
+	doc, err := sbom.ReadBOMDocument(paths[0])
+	if err != nil {
+		return err
+	}
+	data, err := sbom.EncodeBOMDocument(doc, format)
+	if err != nil {
+		return err
+	}
+
+	if *output == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		return fmt.Errorf("failed to write SBOM: %v", err)
+	}
+	runenv.Logger.Infof("Converted %s to %s (%d components)", paths[0], *output, len(doc.Components))
+	return nil
+}
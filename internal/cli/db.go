@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// RunDBCommand implements "sbom-scanner db", which manages the offline OSV database
+func RunDBCommand(args []string) error {
+	fs := flag.NewFlagSet("db", flag.ContinueOnError)
+	dir := fs.String("db-dir", "", "Offline OSV database directory")
+	ecosystemList := fs.String("ecosystems", "", "Comma-separated ecosystems (default: all supported)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: sbom-scanner db [download|status] [--db-dir dir] [--ecosystems Maven,npm,...]")
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	command := "status"
+	if len(positional) > 0 {
+		command = positional[0]
+	}
+	dbDir := *dir
+	if dbDir == "" {
+		dbDir = scan.DefaultOSVDatabaseDir()
+	}
+
+	ecosystems := scan.OSVEcosystems()
+	if *ecosystemList != "" {
+		ecosystems = nil
+		for _, name := range strings.Split(*ecosystemList, ",") {
+			name = strings.TrimSpace(name)
+			index := slices.IndexFunc(scan.OSVEcosystems(), func(e string) bool { return strings.EqualFold(e, name) })
+			if index < 0 {
+				return fmt.Errorf("unknown ecosystem: %s (expected %s)", name, strings.Join(scan.OSVEcosystems(), ", "))
+			}
+			ecosystems = append(ecosystems, scan.OSVEcosystems()[index])
+		}
+	}
+
+	switch command {
+	case "download":
+		for _, ecosystem := range ecosystems {
+			runenv.Logger.Infof("Downloading OSV database for %s", ecosystem)
+			size, err := scan.DownloadOSVEcosystem(dbDir, ecosystem)
+			if err != nil {
+				return err
+			}
+			runenv.Logger.Infof("Stored %s (%.1f MB)", scan.OSVEcosystemPath(dbDir, ecosystem), float64(size)/(1<<20))
+		}
+		return nil
+
+	case "status":
+		fmt.Printf("Offline OSV database: %s\n\n", dbDir)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ECOSYSTEM\tDOWNLOADED\tSIZE")
+		for _, ecosystem := range ecosystems {
+			info, err := os.Stat(scan.OSVEcosystemPath(dbDir, ecosystem))
+			if err != nil {
+				fmt.Fprintf(w, "%s\tmissing\t-\n", ecosystem)
+				continue
+			}
+			downloaded := info.ModTime().Local().Format("2006-01-02 15:04")
+			if time.Since(info.ModTime()) > scan.OSVDatabaseMaxAge {
+				downloaded += " (stale)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%.1f MB\n", ecosystem, downloaded, float64(info.Size())/(1<<20))
+		}
+		return w.Flush()
+	}
+
+	fs.Usage()
+	return fmt.Errorf("unknown db command: %s", command)
+}
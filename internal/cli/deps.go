@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/scanner"
+)
+
+// RunDepsCommand handles "sbom-scanner deps check [--output-format json]",
+// the same as --check, and "sbom-scanner deps install [tool...]"
+func RunDepsCommand(args []string) error {
+	if len(args) > 0 && args[0] == "install" {
+		return runenv.InstallTools(args[1:])
+	}
+	usage := fmt.Errorf("usage: sbom-scanner deps check [--output-format text|json] | sbom-scanner deps install [osv-scanner] [maven]")
+	if len(args) == 0 || args[0] != "check" {
+		return usage
+	}
+	fs := flag.NewFlagSet("deps check", flag.ContinueOnError)
+	format := fs.String("output-format", "text", "Format of the report: text or json")
+	if err := fs.Parse(args[1:]); err != nil || fs.NArg() > 0 {
+		return usage
+	}
+
+	if *format != "text" && *format != "json" {
+		return usage
+	}
+	if *format == "json" {
+		// Only the report goes to stdout
+		runenv.Logger.SetOutput(os.Stderr)
+	}
+
+	report, err := scanner.CheckDependencies()
+	if *format == "json" {
+		if err := scanner.WriteDoctorReport(os.Stdout, report, *format); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("dependency check failed: %v", err)
+	}
+	runenv.Logger.Info("All required dependencies are installed")
+	return nil
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/xshuden/sbom-scanner/pkg/report"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// RunDiffCommand handles "sbom-scanner diff <baseline.json> <current.json>"
+func RunDiffCommand(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "Print JSON")
+	failOn := fs.String("fail-on", "", "Fail when a new vulnerability at or above this severity is found")
+	exitOnNew := fs.Bool("exit-on-new", false, "Fail when any new vulnerability is found")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: sbom-scanner diff <baseline.json> <current.json> [--json] [--exit-on-new] [--fail-on severity]")
+	}
+
+	paths, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(paths) != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected a baseline and a current findings file")
+	}
+
+	threshold, err := scan.ParseSeverityThreshold(*failOn)
+	if err != nil {
+		return err
+	}
+
+	baseline, err := report.LoadFindingsFile(paths[0])
+	if err != nil {
+		return err
+	}
+	diff, err := report.DiffFindings(baseline, scan.FindingsFrom(paths[1]).WithoutSuppressions())
+	if err != nil {
+		return err
+	}
+	diff.Baseline = paths[0]
+
+	if *asJSON {
+		if err := printJSON(diff); err != nil {
+			return err
+		}
+	} else {
+		printFindingsDiff(diff)
+	}
+
+	switch {
+	case threshold != "":
+		if failing := scan.FindingsAtOrAbove(diff.New, threshold); len(failing) > 0 {
+			return fmt.Errorf("%d new vulnerabilities with %s or higher severity", len(failing), threshold)
+		}
+	case *exitOnNew && len(diff.New) > 0:
+		return fmt.Errorf("%d new vulnerabilities", len(diff.New))
+	}
+	return nil
+}
+
+// printFindingsDiff prints the new, fixed and changed findings as a table
+func printFindingsDiff(diff report.FindingsDiff) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATUS\tSEVERITY\tID\tPACKAGE\tVERSION\tDETAILS")
+	for _, f := range diff.New {
+		fmt.Fprintf(w, "new\t%s\t%s\t%s\t%s\t%s\n", f.Severity, f.ID, f.Package, f.Version, f.Summary)
+	}
+	for _, c := range diff.Changed {
+		f := c.Current
+		fmt.Fprintf(w, "changed\t%s\t%s\t%s\t%s\t%s\n", f.Severity, f.ID, f.Package, f.Version, strings.Join(c.Changes, "; "))
+	}
+	for _, f := range diff.Fixed {
+		fmt.Fprintf(w, "fixed\t%s\t%s\t%s\t%s\t%s\n", f.Severity, f.ID, f.Package, f.Version, f.Summary)
+	}
+	w.Flush()
+	fmt.Printf("\n%d new, %d fixed, %d changed, %d unchanged\n", len(diff.New), len(diff.Fixed), len(diff.Changed), diff.Unchanged)
+}
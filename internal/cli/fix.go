@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/report"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+	"github.com/xshuden/sbom-scanner/pkg/scanner"
+)
+
+// RunFixCommand handles "sbom-scanner fix [pom.xml]": the suggested upgrades
+// are applied to the POM and validated with a second scan
+func RunFixCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("fix", flag.ContinueOnError)
+	outputDir := fs.String("o", "fix-results", "Output directory")
+	resolver := fs.String("r", "maven", "Maven dependency resolver (maven, native)")
+	scanners := fs.String("s", "osv", "Vulnerability scanners")
+	dryRun := fs.Bool("dry-run", false, "Print the patch without changing the POM")
+	pr := fs.String("pr", "", "Open a pull request: github or gitlab")
+	fs.StringVar(outputDir, "output", "fix-results", "Output directory")
+	fs.StringVar(resolver, "resolver", "maven", "Maven dependency resolver (maven, native)")
+	fs.StringVar(scanners, "scanner", "osv", "Vulnerability scanners")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: sbom-scanner fix [pom.xml] [-o dir] [-r resolver] [-s scanner] [--dry-run] [--pr github|gitlab]")
+	}
+
+	paths, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	pomPath := "pom.xml"
+	switch len(paths) {
+	case 0:
+	case 1:
+		pomPath = paths[0]
+	default:
+		fs.Usage()
+		return fmt.Errorf("expected a single POM")
+	}
+
+	switch *pr {
+	case "", "github", "gitlab":
+	default:
+		return fmt.Errorf("unknown pull request provider: %s (expected github or gitlab)", *pr)
+	}
+	if *pr != "" && *dryRun {
+		return fmt.Errorf("--pr cannot be combined with --dry-run")
+	}
+
+	if tool, err := sbom.DetectProject(pomPath); err != nil || tool != sbom.BuildToolMaven {
+		return fmt.Errorf("fix supports Maven POMs only: %s", pomPath)
+	}
+	data, err := os.ReadFile(pomPath)
+	if err != nil {
+		return fmt.Errorf("failed to read POM: %v", err)
+	}
+
+	opts := scanner.ScanOptions{Resolver: *resolver}
+	if opts.Scanners, err = scan.ParseScanners(runenv.SettingsOf(ctx), *scanners); err != nil {
+		return err
+	}
+	scanner.ResolveMavenFallback(ctx, &opts, []sbom.Project{{Tool: sbom.BuildToolMaven, File: pomPath}})
+
+	before, err := scanner.ScanPOM(ctx, pomPath, filepath.Join(*outputDir, "before"), opts)
+	if err != nil {
+		return err
+	}
+
+	changes, edits, err := scanner.PlanPOMChanges(data, before)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		runenv.Logger.Infof("No upgrades to apply to %s (%d vulnerabilities)", pomPath, len(before))
+		return nil
+	}
+
+	patched := scanner.ApplyPOMEdits(data, edits)
+	patch := scanner.UnifiedDiff(filepath.Base(pomPath), data, patched)
+	patchPath := filepath.Join(*outputDir, "fix.patch")
+	if err := os.WriteFile(patchPath, []byte(patch), 0644); err != nil {
+		return fmt.Errorf("failed to write patch: %v", err)
+	}
+
+	// A dry run validates a copy next to the POM so that parent POMs still resolve
+	target := pomPath
+	if *dryRun {
+		file, err := os.CreateTemp(filepath.Dir(pomPath), ".sbom-scanner-fix-*.xml")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary POM: %v", err)
+		}
+		target = file.Name()
+		file.Close()
+		defer os.Remove(target)
+	}
+	info, err := os.Stat(pomPath)
+	if err != nil {
+		return fmt.Errorf("failed to read POM: %v", err)
+	}
+	if err := os.WriteFile(target, patched, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write POM: %v", err)
+	}
+
+	after, err := scanner.ScanPOM(ctx, target, filepath.Join(*outputDir, "after"), opts)
+	if err != nil {
+		return fmt.Errorf("validation scan failed: %v", err)
+	}
+	diff, _ := report.DiffFindings(before, scan.SliceFindings(after))
+	validation := fmt.Sprintf("Validated with a new scan: %d of %d vulnerabilities fixed, %d remaining, %d introduced.",
+		len(diff.Fixed), len(before), len(after), len(diff.New))
+	for _, f := range diff.New {
+		runenv.Logger.Warnf("Upgrade introduced %s in %s@%s", f.ID, f.Package, f.Version)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tFROM\tTO\tCHANGE")
+	for _, c := range changes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.Package, c.From, c.To, c.How)
+	}
+	w.Flush()
+	fmt.Printf("\n%s\n", validation)
+
+	if *dryRun {
+		fmt.Printf("\n%s", patch)
+		runenv.Logger.Infof("Dry run, %s is unchanged. Patch written to %s", pomPath, patchPath)
+		return nil
+	}
+	runenv.Logger.Infof("Updated %s, patch written to %s", pomPath, patchPath)
+
+	if *pr != "" {
+		return scanner.OpenFixPullRequest(*pr, pomPath, changes, validation)
+	}
+	return nil
+}
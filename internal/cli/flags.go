@@ -0,0 +1,34 @@
+// Package cli implements the subcommands of the sbom-scanner command.
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+)
+
+// parseInterspersed parses flags that may appear before, between or after
+// the positional arguments of a subcommand and returns the positional ones
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// printJSON writes v as indented JSON to stdout
+func printJSON(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON: %v", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
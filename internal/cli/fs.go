@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// RunFSCommand writes the SBOM of the packages found in a directory, such as
+// an unpacked application server, a vendor drop or a root filesystem
+func RunFSCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("fs", flag.ContinueOnError)
+	output := fs.String("o", "sbom.xml", "SBOM to write, - for stdout")
+	fs.StringVar(output, "output", "sbom.xml", "SBOM to write, - for stdout")
+	format := fs.String("format", "", "Output format: xml or json (default: from the file extension)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: sbom-scanner fs <dir> [-o sbom.xml] [--format xml|json]")
+	}
+
+	dirs, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(dirs) != 1 {
+		fs.Usage()
+		return fmt.Errorf("missing directory")
+	}
+	if *format == "" {
+		*format = "xml"
+		if strings.EqualFold(filepath.Ext(*output), ".json") {
+			*format = "json"
+		}
+	}
+	if *format != "xml" && *format != "json" {
+		return fmt.Errorf("unknown format: %s", *format)
+	}
+	if info, err := os.Stat(dirs[0]); err != nil || !info.IsDir() {
+		return fmt.Errorf("directory not found: %s", dirs[0])
+	}
+
+	components, err := scan.ScanFilesystem(ctx, dirs[0], *output)
+	if err != nil {
+		return err
+	}
+
+	bom := sbom.NewCycloneDXBOM(components, nil)
+	var data []byte
+	if *format == "json" {
+		data, err = json.MarshalIndent(sbom.CycloneDXJSON(bom), "", "  ")
+	} else {
+		data, err = xml.MarshalIndent(bom, "", "  ")
+		data = append([]byte(xml.Header), data...)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode SBOM: %v", err)
+	}
+
+	if *output == "-" {
+		_, err := os.Stdout.Write(append(data, '\n'))
+		return err
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		return fmt.Errorf("failed to write SBOM: %v", err)
+	}
+	runenv.Logger.Infof("SBOM of %s written to %s (%d components)", dirs[0], *output, len(bom.Components))
+	return nil
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/pkg/scanner"
+)
+
+// RunHistoryCommand handles "sbom-scanner history [list|show <id>]"
+func RunHistoryCommand(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	dbPath := fs.String("db", filepath.Join("scan-results", scanner.HistoryFileName), "Path to the history database")
+	target := fs.String("target", "", "Only list scans of this project file or directory")
+	limit := fs.Int("n", 20, "Number of scans to list")
+	asJSON := fs.Bool("json", false, "Print JSON")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: sbom-scanner history [--db path] [list [--target path] [-n count] | show <id>] [--json]")
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+
+	command := "list"
+	if len(positional) > 0 {
+		command, positional = positional[0], positional[1:]
+	}
+
+	if _, err := os.Stat(*dbPath); err != nil {
+		return fmt.Errorf("history database not found: %s", *dbPath)
+	}
+	db, err := scanner.OpenHistory(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch command {
+	case "list":
+		filter := *target
+		if filter != "" {
+			filter = scanner.HistoryTarget(filter)
+		}
+		scans, err := scanner.ListScans(db, filter, *limit)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			return printJSON(scans)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tFINISHED\tSTATUS\tVULNERABILITIES\tSUPPRESSED\tRISK\tTARGET")
+		for _, s := range scans {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%.1f\t%s\n", s.ID, s.FinishedAt.Local().Format("2006-01-02 15:04"),
+				s.Status, s.Vulnerabilities, s.Suppressed, s.RiskScore, s.Target)
+		}
+		return w.Flush()
+
+	case "show":
+		if len(positional) != 1 {
+			fs.Usage()
+			return fmt.Errorf("missing scan id")
+		}
+		id, err := strconv.ParseInt(positional[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid scan id: %s", positional[0])
+		}
+		s, err := scanner.LoadScan(db, id)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			return printJSON(s)
+		}
+		printScan(s)
+		return nil
+	}
+
+	fs.Usage()
+	return fmt.Errorf("unknown history command: %s", command)
+}
+
+// printScan prints a scan with its modules and findings
+func printScan(s scanner.HistoryScan) {
+	fmt.Printf("Scan %d of %s\n", s.ID, s.Target)
+	fmt.Printf("Started:   %s\n", s.StartedAt.Local().Format(time.RFC1123))
+	fmt.Printf("Finished:  %s\n", s.FinishedAt.Local().Format(time.RFC1123))
+	fmt.Printf("Scanners:  %s\n", strings.Join(s.Scanners, ", "))
+	fmt.Printf("Status:    %s\n", s.Status)
+	fmt.Printf("Risk:      %.1f\n", s.RiskScore)
+	if s.Error != "" {
+		fmt.Printf("Error:     %s\n", s.Error)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\nMODULE\tBUILD TOOL\tVULNERABILITIES\tSBOM SHA-256")
+	for _, m := range s.Modules {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", m.Path, m.BuildTool, m.Vulnerabilities, m.SBOMHash)
+	}
+	fmt.Fprintln(w, "\nSEVERITY\tID\tPACKAGE\tVERSION\tSUPPRESSED")
+	for _, f := range s.Findings {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", f.Severity, f.ID, f.Package, f.Version, f.SuppressedBy)
+	}
+	w.Flush()
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+// RunMergeCommand handles "sbom-scanner merge <sbom>...", which combines the
+// SBOMs of several modules or ecosystems into one CycloneDX document
+func RunMergeCommand(args []string) error {
+	fs := flag.NewFlagSet("merge", flag.ContinueOnError)
+	output := fs.String("o", "merged-sbom.xml", "Merged SBOM, - for stdout")
+	fs.StringVar(output, "output", "merged-sbom.xml", "Merged SBOM, - for stdout")
+	format := fs.String("format", "", "Output format: xml or json (default: from the file extension)")
+	name := fs.String("name", "", "Name of the product the merged SBOM describes")
+	version := fs.String("version", "", "Version of the product the merged SBOM describes")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: sbom-scanner merge <sbom|dir>... [-o merged-sbom.xml] [--format xml|json] [--name product] [--version version]")
+	}
+
+	paths, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing SBOM files")
+	}
+	if *format == "" {
+		*format = "xml"
+		if strings.EqualFold(filepath.Ext(*output), ".json") {
+			*format = "json"
+		}
+	}
+	if *format != "xml" && *format != "json" {
+		return fmt.Errorf("unknown format: %s", *format)
+	}
+
+	files, err := sbom.MergeFiles(paths)
+	if err != nil {
+		return err
+	}
+	var inputs []sbom.MergeInput
+	for _, path := range files {
+		input, err := sbom.ReadMergeInput(path)
+		if err != nil {
+			return err
+		}
+		inputs = append(inputs, input)
+	}
+
+	bom := sbom.Merge(inputs, *name, *version)
+	var data []byte
+	if *format == "json" {
+		data, err = json.MarshalIndent(sbom.CycloneDXJSON(bom), "", "  ")
+	} else {
+		data, err = xml.MarshalIndent(bom, "", "  ")
+		data = append([]byte(xml.Header), data...)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode SBOM: %v", err)
+	}
+
+	if *output == "-" {
+		_, err := os.Stdout.Write(append(data, '\n'))
+		return err
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		return fmt.Errorf("failed to write SBOM: %v", err)
+	}
+	runenv.Logger.Infof("Merged %d SBOMs into %s (%d components)", len(inputs), *output, len(bom.Components))
+	return nil
+}
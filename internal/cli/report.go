@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/pkg/maven"
+	"github.com/xshuden/sbom-scanner/pkg/report"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+	"github.com/xshuden/sbom-scanner/pkg/scanner"
+)
+
+// RunReportCommand handles "sbom-scanner report render <findings.json>",
+// which renders reports from the findings of an earlier scan
+func RunReportCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	formats := fs.String("format", "html", "Comma-separated report formats (csv, html, junit, markdown, openvex, pdf)")
+	output := fs.String("o", "", "Report path without extension (default: next to the findings)")
+	title := fs.String("title", "", "Report title")
+	ignore := fs.String("ignore", "", "Comma-separated vulnerability IDs or package@version entries to ignore")
+	ignorePath := fs.String("ignore-file", "", "Path to YAML file with ignore rules")
+	vexPaths := fs.String("vex", "", "Comma-separated OpenVEX or CycloneDX VEX documents")
+	baseline := fs.String("baseline", "", "Findings of a previous scan to compare with (markdown)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: sbom-scanner report render <sbom-findings.json|aggregated-report.json> [--format csv,html,junit,markdown,openvex,pdf] [-o path] [--title title] [--ignore ids] [--ignore-file path] [--vex paths] [--baseline path]")
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 || positional[0] != "render" {
+		fs.Usage()
+		return fmt.Errorf("expected report render and a findings file")
+	}
+	path := positional[1]
+
+	reports, err := report.ParseReportFormats(*formats)
+	if err != nil {
+		return err
+	}
+	rules, err := scanner.Options{
+		Ignore:     scan.ParseIgnoreFlag(*ignore),
+		IgnoreFile: *ignorePath,
+		VEX:        strings.Split(*vexPaths, ","),
+	}.IgnoreRules()
+	if err != nil {
+		return err
+	}
+
+	var known *report.BaselineFindings
+	if *baseline != "" {
+		baseFindings, err := report.LoadFindingsFile(*baseline)
+		if err != nil {
+			return err
+		}
+		known = &report.BaselineFindings{Path: *baseline, Findings: baseFindings}
+	}
+
+	base := *output
+	if base == "" {
+		base = reportBasePath(path)
+	}
+	if *title == "" {
+		*title = "Vulnerability Report: " + filepath.Base(path)
+	}
+	return report.RenderReportFormats(ctx, reports, *title, scan.FindingsFrom(path), rules, known, maven.LicenseReportFor(path), base)
+}
+
+// reportBasePath returns where the reports of a findings file are rendered:
+// sbom-vulnerabilities next to sbom-findings.json, aggregated-report next to
+// aggregated-report.json
+func reportBasePath(findingsPath string) string {
+	if strings.HasSuffix(findingsPath, "-findings.json") {
+		return strings.TrimSuffix(findingsPath, "-findings.json") + "-vulnerabilities"
+	}
+	return strings.TrimSuffix(findingsPath, filepath.Ext(findingsPath))
+}
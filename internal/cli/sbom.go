@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+	"github.com/xshuden/sbom-scanner/pkg/scanner"
+)
+
+// RunSBOMCommand handles "sbom-scanner sbom generate", which only generates
+// the SBOM and the dependency tree of a project
+func RunSBOMCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("sbom", flag.ContinueOnError)
+	file := fs.String("f", "", "Path to project file or directory")
+	fs.StringVar(file, "file", "", "Path to project file or directory")
+	outputDir := fs.String("o", "scan-results", "Output directory")
+	fs.StringVar(outputDir, "output", "scan-results", "Output directory")
+	resolver := fs.String("r", "maven", "Maven dependency resolver (maven, native)")
+	fs.StringVar(resolver, "resolver", "maven", "Maven dependency resolver (maven, native)")
+	scopes := fs.String("scopes", "", "Comma-separated Maven scopes (default: all)")
+	sign := fs.Bool("sign", false, "Sign the SBOMs with cosign")
+	signKey := fs.String("sign-key", "", "cosign private key or KMS URI (default: keyless)")
+	attest := fs.String("attest", "", "Write an in-toto attestation of the SBOMs with a cyclonedx or spdx predicate")
+	subjects := fs.String("attest-subject", "", "Comma-separated artifacts the SBOMs describe, files or name@sha256:digest")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: sbom-scanner sbom generate [project] [-f project] [-o dir] [-r maven|native] [--scopes compile,runtime] [--sign] [--sign-key key] [--attest cyclonedx|spdx] [--attest-subject artifacts]")
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 || positional[0] != "generate" {
+		fs.Usage()
+		return fmt.Errorf("unknown sbom command")
+	}
+	if len(positional) > 1 {
+		*file = positional[1]
+	}
+	if *file == "" {
+		fs.Usage()
+		return fmt.Errorf("missing project file")
+	}
+
+	opts, err := scanner.Options{Resolver: *resolver, Scopes: []string{*scopes}, Sign: *sign, SignKey: *signKey, Attest: *attest, AttestSubjects: strings.Split(*subjects, ",")}.GenerateOptions()
+	if err != nil {
+		return err
+	}
+	if opts.Sign.Enabled {
+		if err := scanner.CosignInstalled(ctx); err != nil {
+			return err
+		}
+	}
+
+	projects, err := sbom.FindProjects(*file, *outputDir)
+	if err != nil {
+		return err
+	}
+	scanner.ResolveMavenFallback(ctx, &opts, projects)
+
+	for _, p := range projects {
+		moduleDir := filepath.Join(*outputDir, p.Output)
+		if err := os.MkdirAll(moduleDir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %v", err)
+		}
+		tasks, err := scanner.GenerateTasks(ctx, p, moduleDir, opts)
+		if err != nil {
+			return err
+		}
+		if err := scanner.RunTasks(ctx, tasks, "Generating SBOM"); err != nil {
+			return err
+		}
+		sbomPath := filepath.Join(moduleDir, "sbom.xml")
+		if opts.Attest.Format != "" {
+			if err := scanner.WriteAttestations(ctx, sbomPath, p, opts); err != nil {
+				return err
+			}
+		}
+		if opts.Sign.Enabled {
+			if err := scanner.SignOutputs(ctx, sbomPath, opts); err != nil {
+				return err
+			}
+		}
+		runenv.Logger.Infof("SBOM of %s written to %s", p.File, sbomPath)
+	}
+	return nil
+}
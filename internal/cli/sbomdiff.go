@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+// RunSBOMDiffCommand handles "sbom-scanner sbom-diff <old> <new>"
+func RunSBOMDiffCommand(args []string) error {
+	fs := flag.NewFlagSet("sbom-diff", flag.ContinueOnError)
+	format := fs.String("format", "text", "Output format: text, json or markdown")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: sbom-scanner sbom-diff <old-sbom> <new-sbom> [--format text|json|markdown]")
+	}
+
+	paths, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(paths) != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected two SBOM files")
+	}
+
+	oldPackages, err := sbom.ReadPackages(paths[0])
+	if err != nil {
+		return err
+	}
+	newPackages, err := sbom.ReadPackages(paths[1])
+	if err != nil {
+		return err
+	}
+
+	diff := sbom.DiffSBOMs(oldPackages, newPackages)
+	diff.Old, diff.New = paths[0], paths[1]
+
+	switch *format {
+	case "json":
+		return printJSON(diff)
+	case "markdown":
+		fmt.Print(sbomDiffMarkdown(diff))
+	case "text":
+		printSBOMDiff(diff)
+	default:
+		return fmt.Errorf("unknown format: %s", *format)
+	}
+	return nil
+}
+
+// printSBOMDiff prints the changes as a table
+func printSBOMDiff(diff sbom.Diff) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHANGE\tCOMPONENT\tOLD\tNEW")
+	for _, p := range diff.Added {
+		fmt.Fprintf(w, "added\t%s\t\t%s\n", p.Name, p.Version)
+	}
+	for _, p := range diff.Removed {
+		fmt.Fprintf(w, "removed\t%s\t%s\t\n", p.Name, p.Version)
+	}
+	for _, c := range diff.Upgraded {
+		fmt.Fprintf(w, "upgraded\t%s\t%s\t%s\n", c.Name, c.From, c.To)
+	}
+	for _, c := range diff.Downgraded {
+		fmt.Fprintf(w, "downgraded\t%s\t%s\t%s\n", c.Name, c.From, c.To)
+	}
+	w.Flush()
+	fmt.Printf("\n%d added, %d removed, %d upgraded, %d downgraded, %d unchanged\n",
+		len(diff.Added), len(diff.Removed), len(diff.Upgraded), len(diff.Downgraded), diff.Unchanged)
+}
+
+// sbomDiffMarkdown renders the changes as a dependency changelog
+func sbomDiffMarkdown(diff sbom.Diff) string {
+	var b strings.Builder
+	b.WriteString("## Dependency changes\n\n")
+	fmt.Fprintf(&b, "%d added, %d removed, %d upgraded, %d downgraded, %d unchanged\n",
+		len(diff.Added), len(diff.Removed), len(diff.Upgraded), len(diff.Downgraded), diff.Unchanged)
+
+	if len(diff.Added) > 0 {
+		b.WriteString("\n### Added\n\n")
+		for _, p := range diff.Added {
+			fmt.Fprintf(&b, "- `%s` %s\n", p.Name, p.Version)
+		}
+	}
+	if len(diff.Removed) > 0 {
+		b.WriteString("\n### Removed\n\n")
+		for _, p := range diff.Removed {
+			fmt.Fprintf(&b, "- `%s` %s\n", p.Name, p.Version)
+		}
+	}
+	if len(diff.Upgraded) > 0 {
+		b.WriteString("\n### Upgraded\n\n")
+		for _, c := range diff.Upgraded {
+			fmt.Fprintf(&b, "- `%s` %s → %s\n", c.Name, c.From, c.To)
+		}
+	}
+	if len(diff.Downgraded) > 0 {
+		b.WriteString("\n### Downgraded\n\n")
+		for _, c := range diff.Downgraded {
+			fmt.Fprintf(&b, "- `%s` %s → %s\n", c.Name, c.From, c.To)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/scanner"
+)
+
+// RunServeCommand starts the HTTP API of the serve subcommand
+func RunServeCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	listen := fs.String("listen", "127.0.0.1:8080", "Address to listen on, other than loopback only with "+scanner.ServerTokenEnv)
+	dataDir := fs.String("data-dir", "sbom-scanner-data", "Directory of the uploaded projects, the results and the history database")
+	configPath := fs.String("config", "", "Config file with the scan settings")
+	workers := fs.Int("workers", 1, "Number of concurrent scans")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: sbom-scanner serve [--listen addr] [--data-dir dir] [--config path] [--workers n]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *workers < 1 {
+		return fmt.Errorf("invalid --workers: %d (expected at least 1)", *workers)
+	}
+
+	base := scanner.Options{}
+	var schedules []scanner.ServerSchedule
+	if *configPath != "" {
+		config, err := scanner.LoadConfig(*configPath)
+		if err != nil {
+			return err
+		}
+		if base, err = config.Options(); err != nil {
+			return err
+		}
+		configCtx, err := base.Context(ctx)
+		if err != nil {
+			return err
+		}
+		if schedules, err = scanner.LoadSchedules(configCtx, config.Schedules); err != nil {
+			return err
+		}
+		runenv.Logger.Infof("Using config file %s", *configPath)
+	}
+	if len(base.Scanners) == 0 {
+		base.Scanners = []string{"osv"}
+	}
+
+	s := &scanner.ScanServer{
+		DataDir: *dataDir,
+		Base:    base,
+		Token:   strings.TrimSpace(os.Getenv(scanner.ServerTokenEnv)),
+		Queue:   make(chan string, scanner.ServerQueueSize),
+		Jobs:    make(map[string]*scanner.ServerJob),
+
+		Schedules: schedules,
+	}
+	// Every scan runs Maven on the submitted POM, whose plugins can run any code
+	if s.Token == "" {
+		if !scanner.LoopbackAddress(*listen) {
+			return fmt.Errorf("%s is not set: refusing to accept unauthenticated scans on %s, set it or listen on a loopback address such as 127.0.0.1:8080", scanner.ServerTokenEnv, *listen)
+		}
+		runenv.Logger.Warnf("%s is not set, the API accepts requests without authentication from this host", scanner.ServerTokenEnv)
+	}
+	if err := os.MkdirAll(*dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %v", err)
+	}
+	if err := s.LoadJobs(); err != nil {
+		return err
+	}
+	if err := s.LoadScheduleStates(); err != nil {
+		return err
+	}
+
+	for i := 0; i < *workers; i++ {
+		go s.Work(ctx)
+	}
+	if len(schedules) > 0 {
+		runenv.Logger.Infof("Running %d scheduled scans", len(schedules))
+		go s.Schedule(ctx)
+	}
+
+	server := &http.Server{Addr: *listen, Handler: s.Routes(), ReadHeaderTimeout: 30 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	runenv.Logger.Infof("Listening on %s, data in %s", *listen, *dataDir)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
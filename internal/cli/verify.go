@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/xshuden/sbom-scanner/pkg/scanner"
+)
+
+// RunVerifyCommand handles "sbom-scanner verify", which checks the cosign
+// signatures written by --sign
+func RunVerifyCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	var opts scanner.VerifyOptions
+	fs.StringVar(&opts.Key, "key", "", "Public key or KMS URI of key-based signatures")
+	fs.StringVar(&opts.Identity, "certificate-identity", "", "Identity of keyless signatures, e.g. the workflow URL or email")
+	fs.StringVar(&opts.IdentityRegexp, "certificate-identity-regexp", "", "Regular expression of the identity of keyless signatures")
+	fs.StringVar(&opts.Issuer, "certificate-oidc-issuer", "", "OIDC issuer of keyless signatures, e.g. https://token.actions.githubusercontent.com")
+	fs.StringVar(&opts.IssuerRegexp, "certificate-oidc-issuer-regexp", "", "Regular expression of the OIDC issuer of keyless signatures")
+	fs.BoolVar(&opts.IgnoreTlog, "insecure-ignore-tlog", false, "Accept signatures without a transparency log entry, e.g. of offline scans")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: sbom-scanner verify [--key cosign.pub | --certificate-identity id --certificate-oidc-issuer url] <sbom.xml|output dir>...")
+	}
+
+	paths, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing SBOM file")
+	}
+	if opts.Key == "" && ((opts.Identity == "" && opts.IdentityRegexp == "") || (opts.Issuer == "" && opts.IssuerRegexp == "")) {
+		fs.Usage()
+		return fmt.Errorf("keyless signatures need --certificate-identity and --certificate-oidc-issuer (or their -regexp variants), or use --key")
+	}
+	if err := scanner.CosignInstalled(ctx); err != nil {
+		return err
+	}
+
+	sboms, err := scanner.SignedSBOMs(paths)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, path := range sboms {
+		if err := scanner.VerifySBOM(ctx, path, opts); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		fmt.Printf("Verified %s\n", path)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+	"github.com/xshuden/sbom-scanner/pkg/scanner"
+)
+
+// RunVulnCommand handles "sbom-scanner vuln scan <sbom.xml>", which scans an
+// existing CycloneDX SBOM and writes the findings next to it
+func RunVulnCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("vuln", flag.ContinueOnError)
+	scanners := fs.String("s", "osv", "Vulnerability scanners (osv, osv-binary, grype, trivy, a comma-separated list or all)")
+	fs.StringVar(scanners, "scanner", "osv", "Vulnerability scanners")
+	exitOnVuln := fs.Bool("e", false, "Exit when vulnerabilities are found")
+	fs.BoolVar(exitOnVuln, "exit-on-vuln", false, "Exit when vulnerabilities are found")
+	failOn := fs.String("fail-on", "", "Fail when a vulnerability at or above this severity is found")
+	failOnUnknown := fs.Bool("fail-on-unknown", false, "With --fail-on, also fail for vulnerabilities of unknown severity")
+	ignore := fs.String("ignore", "", "Comma-separated vulnerability IDs or package@version entries to ignore")
+	ignorePath := fs.String("ignore-file", "", "Path to YAML file with ignore rules")
+	vexPaths := fs.String("vex", "", "Comma-separated OpenVEX or CycloneDX VEX documents")
+	offlineScan := fs.Bool("offline", false, "Scan without internet access using the offline OSV database")
+	dbDir := fs.String("db-dir", "", "Offline OSV database directory")
+	noCache := fs.Bool("no-cache", false, "Always query the vulnerability scanners")
+	ghsa := fs.Bool("ghsa", false, "Enrich the findings from the GitHub Security Advisories (token in GITHUB_TOKEN)")
+	nvd := fs.Bool("nvd", false, "Enrich the findings with the CVSS vectors and CPEs of the NVD (API key in NVD_API_KEY)")
+	exploits := fs.Bool("exploits", false, "Enrich the findings with EPSS scores and the CISA KEV catalog")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: sbom-scanner vuln scan <sbom.xml> [-s scanner] [--fail-on severity] [-e] [--ignore ids] [--ignore-file path] [--vex paths] [--offline] [--db-dir dir] [--no-cache] [--ghsa] [--nvd] [--exploits]")
+	}
+
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 || positional[0] != "scan" {
+		fs.Usage()
+		return fmt.Errorf("expected vuln scan and an SBOM file")
+	}
+	sbomPath := positional[1]
+	if _, err := os.Stat(sbomPath); err != nil {
+		return fmt.Errorf("SBOM file not found: %s", sbomPath)
+	}
+
+	o := scanner.Options{
+		Scanners:      []string{*scanners},
+		ExitOnVuln:    *exitOnVuln,
+		FailOn:        *failOn,
+		FailOnUnknown: *failOnUnknown,
+		Ignore:        scan.ParseIgnoreFlag(*ignore),
+		IgnoreFile:    *ignorePath,
+		VEX:           strings.Split(*vexPaths, ","),
+		Offline:       *offlineScan,
+		DBDir:         *dbDir,
+		GHSA:          *ghsa,
+		NVD:           *nvd,
+		Exploits:      *exploits,
+	}
+	if *noCache {
+		o.CacheTTL = -1
+	}
+	ctx, err = o.Context(ctx)
+	if err != nil {
+		return err
+	}
+	opts, err := o.ScanOptions(ctx)
+	if err != nil {
+		return err
+	}
+	if err := scanner.CheckRequiredTools(ctx, nil, opts); err != nil {
+		return err
+	}
+
+	// The dependency tree is picked up when the SBOM was generated by sbom generate
+	depsPath := filepath.Join(filepath.Dir(sbomPath), "deps-tree.txt")
+	tasks := scanner.VulnerabilityTasks(sbomPath, depsPath, opts)
+	resultsPath := scan.FindingsPath(sbomPath)
+	tasks = append(tasks, scanner.Task{
+		Name: "Checking Results",
+		Action: func(ctx context.Context) error {
+			return scanner.EvaluateResults(resultsPath, opts)
+		},
+		Progress: 5,
+	})
+	return scanner.RunTasks(ctx, tasks, "Scanning "+filepath.Base(sbomPath))
+}
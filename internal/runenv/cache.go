@@ -0,0 +1,39 @@
+package runenv
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultCacheTTL is the default lifetime of cached scan results and Maven resolutions
+const DefaultCacheTTL = 6 * time.Hour
+
+// CacheDir returns a directory of the sbom-scanner cache, with one entry per hash:
+// results for scan results, maven for Maven dependency resolutions. It is below
+// the work directory with --workdir.
+func (s *Settings) CacheDir(kind string) string {
+	if s.WorkDir != "" {
+		return filepath.Join(s.WorkDir, "cache", kind)
+	}
+	return userCacheDir(kind)
+}
+
+// userCacheDir returns a directory of the sbom-scanner cache in the user cache directory
+func userCacheDir(kind string) string {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(".sbom-scanner", kind)
+	}
+	return filepath.Join(cache, "sbom-scanner", kind)
+}
+
+// CacheEntryFresh reports whether the marker file of a cache entry is younger than the TTL
+func (s *Settings) CacheEntryFresh(marker string) (time.Duration, bool) {
+	info, err := os.Stat(marker)
+	if err != nil {
+		return 0, false
+	}
+	age := time.Since(info.ModTime())
+	return age, age <= s.ResultCacheTTL
+}
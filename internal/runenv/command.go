@@ -0,0 +1,22 @@
+package runenv
+
+import (
+	"context"
+	"os/exec"
+	"time"
+)
+
+// Command returns a command that is killed together with the processes it
+// started (e.g. the JVM behind the mvn script) when ctx is canceled. With
+// --workdir its temporary files go to the work directory; callers adding to
+// the environment start from cmd.Environ().
+func Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	killProcessTree(cmd)
+	// Do not wait for pipes held open by orphaned grandchildren
+	cmd.WaitDelay = 5 * time.Second
+	if dir := workTempDir(ctx); dir != "" {
+		cmd.Env = tempEnv(cmd.Environ(), dir)
+	}
+	return cmd
+}
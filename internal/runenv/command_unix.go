@@ -0,0 +1,17 @@
+//go:build !windows
+
+package runenv
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killProcessTree runs the command in its own process group and kills the
+// whole group on cancellation
+func killProcessTree(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package runenv
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// killProcessTree kills the command and its child processes with taskkill on
+// cancellation
+func killProcessTree(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+	}
+}
@@ -0,0 +1,67 @@
+package runenv
+
+import (
+	"fmt"
+)
+
+// Exit codes of the sbom-scanner command, so that CI pipelines can tell a
+// vulnerable project from a scan that broke
+const (
+	ExitOK              = 0 // no findings above the threshold
+	ExitVulnerabilities = 1 // --exit-on-vuln or --fail-on exceeded
+	ExitPolicy          = 2 // policy rule or license denylist violated
+	ExitError           = 3 // invalid configuration, build or tool failure
+	ExitMissingTool     = 4 // a required external tool is not installed
+	ExitMalicious       = 5 // a known malicious package version is present
+)
+
+// codedError carries the exit code of an error without changing its message
+type codedError struct {
+	err  error
+	code int
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+// WithExitCode tags err with the exit code of the command
+func WithExitCode(err error, code int) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{err: err, code: code}
+}
+
+// MissingTool is the error of a required tool that is not installed
+func MissingTool(format string, args ...any) error {
+	return WithExitCode(fmt.Errorf(format, args...), ExitMissingTool)
+}
+
+// ExitCode returns the exit code for an error of Run or a subcommand. Errors
+// that were not tagged are tool or configuration errors. Of several errors
+// joined together, e.g. of failed modules, the highest code wins, so that a
+// broken scan is never reported as merely vulnerable.
+func ExitCode(err error) int {
+	switch e := err.(type) {
+	case nil:
+		return ExitOK
+	case *codedError:
+		return e.code
+	case interface{ Unwrap() []error }:
+		code := ExitOK
+		for _, err := range e.Unwrap() {
+			code = max(code, ExitCode(err))
+		}
+		return code
+	case interface{ Unwrap() error }:
+		if inner := e.Unwrap(); inner != nil {
+			return ExitCode(inner)
+		}
+	}
+	return ExitError
+}
@@ -0,0 +1,10 @@
+package runenv
+
+import (
+	"net/http"
+	"time"
+)
+
+// HTTPClient is shared by all network calls, its default transport uses
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY. Requests are timed with --timings.
+var HTTPClient = &http.Client{Timeout: 60 * time.Second, Transport: timedTransport{base: http.DefaultTransport}}
@@ -0,0 +1,308 @@
+package runenv
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Releases downloaded by "deps install" and "deps check"
+const (
+	osvScannerVersion    = "1.9.2"
+	osvScannerURL        = "https://github.com/google/osv-scanner/releases/download/v%[1]s/"
+	mavenDownloadVersion = "3.9.9"
+	mavenDownloadURL     = "https://archive.apache.org/dist/maven/maven-3/%[1]s/binaries/apache-maven-%[1]s-bin.zip"
+	toolDownloadTimeout  = 10 * time.Minute
+)
+
+// Checksums of the pinned releases by file name: SHA-256 of the osv-scanner
+// binaries per platform, SHA-512 of the Maven archive. They are kept here
+// instead of being downloaded from the same server as the releases, which
+// could serve a matching checksum for a tampered file, and have to be updated
+// together with the versions from the checksums the projects publish.
+// Releases without a checksum here are not installed.
+var toolChecksums = map[string]string{
+	"osv-scanner_1.9.2_darwin_amd64":      "",
+	"osv-scanner_1.9.2_darwin_arm64":      "",
+	"osv-scanner_1.9.2_linux_amd64":       "",
+	"osv-scanner_1.9.2_linux_arm64":       "",
+	"osv-scanner_1.9.2_windows_amd64.exe": "",
+	"osv-scanner_1.9.2_windows_arm64.exe": "",
+	"apache-maven-3.9.9-bin.zip":          "",
+}
+
+// managedTool is a pinned release that sbom-scanner downloads into the tools
+// directory instead of installing it system-wide
+type managedTool struct {
+	name       string // as given to "deps install"
+	tool       string // key in DefaultToolPaths
+	version    string
+	executable string // relative to the installation directory
+	install    func(dir string) error
+}
+
+var managedTools = []managedTool{
+	{
+		name:       "osv-scanner",
+		tool:       "osv-scanner",
+		version:    osvScannerVersion,
+		executable: "osv-scanner" + ExeSuffix(),
+		install:    downloadOSVScanner,
+	},
+	{
+		name:       "maven",
+		tool:       "mvn",
+		version:    mavenDownloadVersion,
+		executable: filepath.Join("bin", mavenScript()),
+		install:    downloadMaven,
+	},
+}
+
+// mavenScript is the name of the Maven launcher in a Maven installation
+func mavenScript() string {
+	if runtime.GOOS == "windows" {
+		return "mvn.cmd"
+	}
+	return "mvn"
+}
+
+// toolsDir holds the downloaded tools, one directory per tool and version.
+// They are installed before any run and stay in the user cache directory
+// with --workdir.
+func toolsDir() string {
+	return userCacheDir("tools")
+}
+
+func (t managedTool) dir() string {
+	return filepath.Join(toolsDir(), t.name+"-"+t.version)
+}
+
+func (t managedTool) path() string {
+	return filepath.Join(t.dir(), t.executable)
+}
+
+// managedToolPath returns the downloaded executable of a tool, if any
+func managedToolPath(tool string) string {
+	for _, t := range managedTools {
+		if t.tool == tool && IsExecutable(t.path()) {
+			return t.path()
+		}
+	}
+	return ""
+}
+
+// InstallTools downloads the pinned releases of the named tools (all when
+// none are given) into the tools directory. Installed versions are kept.
+func InstallTools(names []string) error {
+	if len(names) == 0 {
+		for _, t := range managedTools {
+			names = append(names, t.name)
+		}
+	}
+	for _, name := range names {
+		if err := InstallTool(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InstallTool downloads a managed tool unless its version is already there
+func InstallTool(name string) error {
+	for _, t := range managedTools {
+		if t.name != name && t.tool != name {
+			continue
+		}
+		if IsExecutable(t.path()) {
+			Logger.Infof("%s %s is already installed in %s", t.name, t.version, t.dir())
+			return nil
+		}
+
+		// Unpack next to the final directory so that a failed download leaves nothing behind
+		if err := os.MkdirAll(toolsDir(), 0755); err != nil {
+			return fmt.Errorf("failed to create tools directory: %v", err)
+		}
+		tmp, err := os.MkdirTemp(toolsDir(), ".install-")
+		if err != nil {
+			return fmt.Errorf("failed to create tools directory: %v", err)
+		}
+		defer os.RemoveAll(tmp)
+
+		if err := t.install(tmp); err != nil {
+			return fmt.Errorf("failed to install %s %s: %v", t.name, t.version, err)
+		}
+		if err := os.RemoveAll(t.dir()); err != nil {
+			return fmt.Errorf("failed to install %s %s: %v", t.name, t.version, err)
+		}
+		if err := os.Rename(tmp, t.dir()); err != nil {
+			return fmt.Errorf("failed to install %s %s: %v", t.name, t.version, err)
+		}
+		Logger.Infof("Installed %s %s in %s", t.name, t.version, t.dir())
+		return nil
+	}
+
+	var known []string
+	for _, t := range managedTools {
+		known = append(known, t.name)
+	}
+	return fmt.Errorf("unknown tool: %s (expected %s)", name, strings.Join(known, ", "))
+}
+
+// SystemMaven returns the Maven executable: the configured or downloaded one,
+// mvn on PATH, or the installation in MAVEN_HOME or M2_HOME
+func SystemMaven(s *Settings) string {
+	mvn := s.ToolPath("mvn")
+	if _, err := exec.LookPath(mvn); err == nil || mvn != "mvn" {
+		return mvn
+	}
+	for _, home := range []string{os.Getenv("MAVEN_HOME"), os.Getenv("M2_HOME")} {
+		if home == "" {
+			continue
+		}
+		if path := filepath.Join(home, "bin", mavenScript()); IsExecutable(path) {
+			return path
+		}
+	}
+	return mvn
+}
+
+// downloadOSVScanner downloads the osv-scanner release binary for this
+// platform and checks it against its pinned SHA-256 checksum
+func downloadOSVScanner(dir string) error {
+	base := fmt.Sprintf(osvScannerURL, osvScannerVersion)
+	asset := fmt.Sprintf("osv-scanner_%s_%s_%s%s", osvScannerVersion, runtime.GOOS, runtime.GOARCH, ExeSuffix())
+	checksum, err := pinnedChecksum(asset, "osv-scanner")
+	if err != nil {
+		return err
+	}
+
+	Logger.Infof("Downloading osv-scanner %s from %s...", osvScannerVersion, base+asset)
+	client := &http.Client{Timeout: toolDownloadTimeout}
+	binary, err := download(client, base+asset)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %v", asset, err)
+	}
+	sum := sha256.Sum256(binary)
+	if !strings.EqualFold(checksum, hex.EncodeToString(sum[:])) {
+		return fmt.Errorf("checksum mismatch for %s", asset)
+	}
+
+	return os.WriteFile(filepath.Join(dir, "osv-scanner"+ExeSuffix()), binary, 0755)
+}
+
+// pinnedChecksum returns the checksum of a release file from toolChecksums
+func pinnedChecksum(file, tool string) (string, error) {
+	if checksum := toolChecksums[file]; checksum != "" {
+		return checksum, nil
+	}
+	return "", fmt.Errorf("no pinned checksum for %s on %s/%s, install %s yourself and set tools.%s in the config file",
+		file, runtime.GOOS, runtime.GOARCH, tool, tool)
+}
+
+// downloadMaven downloads the Maven release from the Apache archive, checks
+// it against its pinned SHA-512 checksum and unpacks it into dir
+func downloadMaven(dir string) error {
+	if _, err := exec.LookPath("java"); err != nil && os.Getenv("JAVA_HOME") == "" {
+		Logger.Warn("Java is not installed, Maven needs it to run")
+	}
+
+	url := fmt.Sprintf(mavenDownloadURL, mavenDownloadVersion)
+	checksum, err := pinnedChecksum(fmt.Sprintf("apache-maven-%s-bin.zip", mavenDownloadVersion), "mvn")
+	if err != nil {
+		return err
+	}
+	Logger.Infof("Downloading Maven %s from %s...", mavenDownloadVersion, url)
+
+	client := &http.Client{Timeout: toolDownloadTimeout}
+	archive, err := download(client, url)
+	if err != nil {
+		return fmt.Errorf("failed to download Maven: %v", err)
+	}
+	sum := sha512.Sum512(archive)
+	if !strings.EqualFold(checksum, hex.EncodeToString(sum[:])) {
+		return fmt.Errorf("checksum mismatch for %s", url)
+	}
+	return unzipMaven(archive, dir)
+}
+
+// download fetches a file into memory
+func download(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// unzipMaven unpacks a Maven binary archive into dir, dropping its top-level
+// apache-maven-<version> directory
+func unzipMaven(data []byte, dir string) error {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("downloaded Maven is not a valid archive: %v", err)
+	}
+	for _, file := range archive.File {
+		_, name, ok := strings.Cut(filepath.ToSlash(file.Name), "/")
+		if !ok || name == "" {
+			continue
+		}
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if !strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return fmt.Errorf("invalid path in Maven archive: %s", file.Name)
+		}
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return fmt.Errorf("failed to unpack Maven: %v", err)
+			}
+			continue
+		}
+		if err := unzipFile(file, path); err != nil {
+			return fmt.Errorf("failed to unpack Maven: %v", err)
+		}
+	}
+	// The launcher must be executable even if the archive lost its mode
+	if runtime.GOOS != "windows" {
+		if err := os.Chmod(filepath.Join(dir, "bin", "mvn"), 0755); err != nil {
+			return fmt.Errorf("failed to unpack Maven: %v", err)
+		}
+	}
+	return nil
+}
+
+// unzipFile writes one archive entry to path, keeping its permissions
+func unzipFile(file *zip.File, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	mode := file.Mode().Perm() | 0600
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, reader); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,35 @@
+package runenv
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestToolChecksums(t *testing.T) {
+	files := []string{fmt.Sprintf("apache-maven-%s-bin.zip", mavenDownloadVersion)}
+	for _, platform := range []string{"darwin_amd64", "darwin_arm64", "linux_amd64", "linux_arm64", "windows_amd64.exe", "windows_arm64.exe"} {
+		files = append(files, fmt.Sprintf("osv-scanner_%s_%s", osvScannerVersion, platform))
+	}
+	if len(toolChecksums) != len(files) {
+		t.Errorf("%d pinned checksums, want %d", len(toolChecksums), len(files))
+	}
+
+	for _, file := range files {
+		checksum, ok := toolChecksums[file]
+		if !ok {
+			t.Errorf("no pinned checksum for %s", file)
+			continue
+		}
+		size := sha256.Size
+		if strings.HasPrefix(file, "apache-maven-") {
+			size = sha512.Size
+		}
+		if decoded, err := hex.DecodeString(checksum); err != nil || len(decoded) != size {
+			t.Errorf("checksum of %s = %q, want a hex SHA-%d digest", file, checksum, size*8)
+		}
+	}
+}
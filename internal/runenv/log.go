@@ -0,0 +1,22 @@
+package runenv
+
+import (
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Logger is where the stages write their progress, replaced with
+// scanner.SetLogger
+var Logger = logrus.New()
+
+func init() {
+	Logger.SetFormatter(&logrus.TextFormatter{
+		FullTimestamp:    true,
+		TimestampFormat:  "2006-01-02T15:04:05-07:00",
+		ForceColors:      true,
+		DisableTimestamp: false,
+	})
+	Logger.SetOutput(os.Stdout)
+	Logger.SetLevel(logrus.InfoLevel)
+}
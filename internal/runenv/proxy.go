@@ -0,0 +1,97 @@
+package runenv
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// javaProxy is a proxy of HTTP_PROXY or HTTPS_PROXY for Maven and Gradle
+type javaProxy struct {
+	Scheme   string // of the requests sent through it, http or https
+	Host     string
+	Port     string
+	Username string
+	Password string
+}
+
+// JavaProxies turns HTTP_PROXY, HTTPS_PROXY and NO_PROXY, which Maven and
+// Gradle ignore, into proxies and the hosts they are bypassed for in the
+// nonProxyHosts syntax of Java
+func JavaProxies() ([]javaProxy, string) {
+	var proxies []javaProxy
+	for _, proxy := range []struct{ scheme, env string }{{"http", "HTTP_PROXY"}, {"https", "HTTPS_PROXY"}} {
+		value := os.Getenv(proxy.env)
+		if value == "" {
+			value = os.Getenv(strings.ToLower(proxy.env))
+		}
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "://") {
+			value = "http://" + value
+		}
+		u, err := url.Parse(value)
+		if err != nil || u.Hostname() == "" {
+			Logger.Warnf("Ignoring invalid %s", proxy.env)
+			continue
+		}
+		port := u.Port()
+		if port == "" {
+			port = "80"
+			if u.Scheme == "https" {
+				port = "443"
+			}
+		}
+		p := javaProxy{Scheme: proxy.scheme, Host: u.Hostname(), Port: port}
+		if u.User != nil {
+			p.Username = u.User.Username()
+			p.Password, _ = u.User.Password()
+		}
+		proxies = append(proxies, p)
+	}
+	if len(proxies) == 0 {
+		return nil, ""
+	}
+
+	noProxy := os.Getenv("NO_PROXY")
+	if noProxy == "" {
+		noProxy = os.Getenv("no_proxy")
+	}
+	var hosts []string
+	for _, host := range strings.Split(noProxy, ",") {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		// Java expects *.example.com where NO_PROXY has .example.com
+		if strings.HasPrefix(host, ".") {
+			host = "*" + host
+		} else if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		hosts = append(hosts, host)
+	}
+	return proxies, strings.Join(hosts, "|")
+}
+
+// ProxyPasswordVariable is the environment variable the password of the
+// proxy for scheme is passed to Maven and Gradle in
+func ProxyPasswordVariable(scheme string) string {
+	return "SBOM_SCANNER_" + strings.ToUpper(scheme) + "_PROXY_PASSWORD"
+}
+
+// JavaProxyEnv returns the passwords of the proxies for the environment of
+// Maven and Gradle. They are kept off the command line, where every user of
+// the machine could read them.
+func JavaProxyEnv() []string {
+	proxies, _ := JavaProxies()
+	var env []string
+	for _, proxy := range proxies {
+		if proxy.Password != "" {
+			env = append(env, ProxyPasswordVariable(proxy.Scheme)+"="+proxy.Password)
+		}
+	}
+	return env
+}
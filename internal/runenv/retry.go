@@ -0,0 +1,120 @@
+package runenv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Defaults of --retries and --retry-backoff
+const (
+	DefaultRetries      = 2
+	DefaultRetryBackoff = 2 * time.Second
+)
+
+// Retry records a failed attempt of a network operation that was retried
+type Retry struct {
+	Operation string    `json:"operation"`
+	Attempt   int       `json:"attempt"`
+	Error     string    `json:"error"`
+	Time      time.Time `json:"time"`
+}
+
+// TakeRetries returns the retries of the run of ctx recorded since the last call
+func TakeRetries(ctx context.Context) []Retry {
+	s := SettingsOf(ctx)
+	s.retriesMu.Lock()
+	defer s.retriesMu.Unlock()
+	recorded := s.retries
+	s.retries = nil
+	return recorded
+}
+
+// transientError marks an error that may go away when the operation is retried
+type transientError struct {
+	err error
+}
+
+func (e transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e transientError) Unwrap() error {
+	return e.err
+}
+
+// Transient marks err as worth retrying
+func Transient(err error) error {
+	return transientError{err: err}
+}
+
+// RetryTransient runs fn until it succeeds, fails with an error not marked transient,
+// or the --retries of the run are used up. The waits between the attempts
+// grow exponentially from --retry-backoff.
+func RetryTransient(ctx context.Context, operation string, fn func() error) error {
+	s := SettingsOf(ctx)
+	wait := s.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		var t transientError
+		if err == nil || !errors.As(err, &t) || attempt > s.RetryCount || ctx.Err() != nil {
+			if errors.As(err, &t) {
+				return t.err
+			}
+			return err
+		}
+
+		Logger.Warnf("%s failed (attempt %d of %d): %v, retrying in %s", operation, attempt, s.RetryCount+1, err, wait)
+		s.retriesMu.Lock()
+		s.retries = append(s.retries, Retry{Operation: operation, Attempt: attempt, Error: err.Error(), Time: time.Now().UTC()})
+		s.retriesMu.Unlock()
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		wait *= 2
+	}
+}
+
+// Fetch sends the request built by newRequest and returns the body of a 200
+// response. Network errors and 429 or 5xx responses are retried.
+func Fetch(ctx context.Context, operation string, newRequest func() (*http.Request, error)) ([]byte, error) {
+	var body []byte
+	err := RetryTransient(ctx, operation, func() error {
+		var err error
+		body, err = FetchOnce(newRequest)
+		return err
+	})
+	return body, err
+}
+
+// FetchOnce is fetch without retries, errors worth retrying are marked transient
+func FetchOnce(newRequest func() (*http.Request, error)) ([]byte, error) {
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return nil, Transient(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("%s", resp.Status)
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			return nil, Transient(err)
+		}
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, Transient(err)
+	}
+	return body, nil
+}
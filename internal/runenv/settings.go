@@ -0,0 +1,123 @@
+// Package runenv holds what the stages of a run share: the settings, the
+// logger, the HTTP client, the caches and the external tools.
+package runenv
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Settings are the settings of a run that its stages read: the tools,
+// Maven, the caches and the limits. They are carried in the context of the
+// run, so that runs in the same process, e.g. the scans of the server, do not
+// interfere with each other.
+type Settings struct {
+	// Executables of the tools set in the config file, by tool name
+	Tools map[string]string
+
+	// Offline mode, set from --offline or the config file. The osv scanner then
+	// matches against the local database and nothing is fetched from the internet.
+	Offline bool
+	// Directory of the offline OSV database, set from --db-dir or the config
+	// file. DefaultOSVDatabaseDir is used when empty.
+	OSVDatabaseDir string
+	// Trivy cache directory holding the vulnerability DB, set from
+	// --trivy-cache-dir or the config file. Trivy's own default is used when empty.
+	TrivyCacheDir string
+	// Lifetime of cached scan results, Maven resolutions and NVD responses, set
+	// from --cache-ttl or the config file. Zero (or --no-cache) disables the cache.
+	ResultCacheTTL time.Duration
+
+	// Maven local repository and offline mode, set from --maven-repo-local and
+	// --maven-offline or the config file
+	MavenRepoLocal string
+	MavenOffline   bool
+	// Maven settings.xml, set from --maven-settings or the config file. It is
+	// passed to every Maven run with -s; Maven's default is used when empty.
+	MavenSettings string
+	// Maven profiles activated with -P, set from --maven-profiles or the config file
+	MavenProfiles []string
+	// Options added to every Maven run, set from --mvn-args or the config file
+	MavenExtraArgs []string
+	// False when the Maven executable is configured with --mvn-path or
+	// tools.maven, the Maven wrapper of a project is not used then
+	MavenWrapper bool
+	// Directory holding the Maven workspaces, set from --workspace or the config
+	// file; the system temp directory when empty
+	MavenWorkspaceDir string
+	// Version of the CycloneDX Maven plugin, set from --cyclonedx-plugin-version
+	// or the config file. "latest" lets Maven pick the newest release, the
+	// default version is used when empty.
+	CycloneDXPluginVersion string
+
+	// Work directory set from --workdir or the config file: the temporary files
+	// of the runs live below tmp/, the caches below cache/. The system temp
+	// directory and the user cache directory are used when empty.
+	WorkDir string
+	// Size limit of the work directory in bytes, set from --workdir-max-size.
+	// Zero is unlimited.
+	WorkDirMaxSize int64
+	// Leaves the Maven workspaces and Gradle build directories behind for
+	// debugging, set from --keep-temp
+	KeepTemp bool
+
+	// Hides the progress bars and the module table
+	Quiet bool
+	// Maximum duration of a single pipeline step, unlimited when zero
+	StageTimeout time.Duration
+	// Retries of network operations and the wait before the first one, doubled
+	// for every further retry. Set from --retries and --retry-backoff.
+	RetryCount   int
+	RetryBackoff time.Duration
+
+	// Semaphore of --parallelism, the maximum number of concurrent Maven
+	// processes and concurrently scanned modules. It is shared by the subtasks
+	// of all parallel tasks, so that it bounds them across the modules.
+	Slots chan struct{}
+
+	// Failed attempts of network operations that were retried
+	retriesMu sync.Mutex
+	retries   []Retry
+
+	// Release cycles of endoflife.date by product, shared by the modules
+	EndOfLifeCycles sync.Map
+}
+
+type settingsKey struct{}
+
+// DefaultSettings are used outside of a run, e.g. by the subcommands that do
+// not read the scan options
+var DefaultSettings = NewSettings()
+
+// NewSettings returns the defaults of the settings
+func NewSettings() *Settings {
+	return &Settings{
+		Tools:          DefaultToolPaths,
+		ResultCacheTTL: DefaultCacheTTL,
+		MavenWrapper:   true,
+		RetryCount:     DefaultRetries,
+		RetryBackoff:   DefaultRetryBackoff,
+		Slots:          make(chan struct{}, runtime.NumCPU()),
+	}
+}
+
+// WithSettings returns ctx carrying the settings of a run
+func WithSettings(ctx context.Context, s *Settings) context.Context {
+	return context.WithValue(ctx, settingsKey{}, s)
+}
+
+// SettingsOf returns the settings of the run ctx belongs to, the defaults
+// outside of a run
+func SettingsOf(ctx context.Context) *Settings {
+	if s, ok := ctx.Value(settingsKey{}).(*Settings); ok {
+		return s
+	}
+	return DefaultSettings
+}
+
+// Parallelism returns the --parallelism of the run
+func (s *Settings) Parallelism() int {
+	return cap(s.Slots)
+}
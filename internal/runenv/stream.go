@@ -0,0 +1,167 @@
+package runenv
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// DecodeJSONArray decodes the elements of the JSON array the decoder is at
+// one at a time, so that large reports are never held in memory as a whole.
+// A null value is an empty array.
+func DecodeJSONArray[T any](decoder *json.Decoder, fn func(T) error) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("expected an array, got %v", token)
+	}
+	for decoder.More() {
+		var item T
+		if err := decoder.Decode(&item); err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	// The closing bracket
+	_, err = decoder.Token()
+	return err
+}
+
+// DecodeJSONObject decodes the JSON object the decoder is at, calling the
+// function of a key with the decoder at its value. The values of the other
+// keys are skipped without being held in memory.
+func DecodeJSONObject(decoder *json.Decoder, fields map[string]func(*json.Decoder) error) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("expected an object, got %v", token)
+	}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if field, ok := fields[key.(string)]; ok {
+			err = field(decoder)
+		} else {
+			err = skipJSONValue(decoder)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// The closing brace
+	_, err = decoder.Token()
+	return err
+}
+
+// skipJSONValue reads past the JSON value the decoder is at one token at a
+// time
+func skipJSONValue(decoder *json.Decoder) error {
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('['), json.Delim('{'):
+			depth++
+		case json.Delim(']'), json.Delim('}'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// JSONObjectAhead reports whether the JSON value read next starts an object
+func JSONObjectAhead(r *bufio.Reader) bool {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return false
+		}
+		if !strings.ContainsRune(" \t\r\n", rune(b)) {
+			r.UnreadByte()
+			return b == '{'
+		}
+	}
+}
+
+// EncodeJSONArray writes the items as a JSON array one element at a time,
+// formatted like json.MarshalIndent with the prefix and two spaces
+func EncodeJSONArray[T any](w io.Writer, prefix string, items []T) error {
+	return EncodeJSONItems(w, prefix, func(fn func(T) error) error {
+		for _, item := range items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// EncodeJSONItems is EncodeJSONArray for the items each passes to its
+// function, so that they are written as they are produced
+func EncodeJSONItems[T any](w io.Writer, prefix string, each func(fn func(T) error) error) error {
+	indent := prefix + "  "
+	n := 0
+	err := each(func(item T) error {
+		data, err := json.MarshalIndent(item, indent, "  ")
+		if err != nil {
+			return err
+		}
+		separator := ",\n" + indent
+		if n == 0 {
+			separator = "[\n" + indent
+		}
+		n++
+		if _, err := io.WriteString(w, separator); err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		_, err = io.WriteString(w, "[]")
+		return err
+	}
+	_, err = io.WriteString(w, "\n"+prefix+"]")
+	return err
+}
+
+// WriteBuffered creates the file at path and calls write with a buffered
+// writer on it, flushed and closed before returning
+func WriteBuffered(path string, write func(w *bufio.Writer) error) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	if err := write(w); err != nil {
+		file.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
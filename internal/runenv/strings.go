@@ -0,0 +1,12 @@
+package runenv
+
+import (
+	"strings"
+)
+
+func CutLast(s, sep string) (string, string, bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
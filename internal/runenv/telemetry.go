@@ -0,0 +1,267 @@
+package runenv
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Maximum duration of the export of the spans of a run
+const otlpExportTimeout = 10 * time.Second
+
+// span is a timed operation of a traced run, e.g. a pipeline stage. The
+// methods do nothing on a nil span, which is what StartSpan returns when
+// tracing is off.
+type span struct {
+	trace    *trace
+	id       [8]byte
+	parentID [8]byte
+	name     string
+	start    time.Time
+	end      time.Time
+	attrs    map[string]any
+	err      string
+}
+
+// trace collects the spans of a run until they are exported
+type trace struct {
+	endpoint string
+	headers  map[string]string
+	id       [16]byte
+
+	mu    sync.Mutex
+	spans []*span
+}
+
+type spanKey struct{}
+
+// tracesEndpoint returns the OTLP/HTTP traces URL: the standard environment
+// variables take precedence over the endpoint of the options
+func tracesEndpoint(endpoint string) string {
+	if traces := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); traces != "" {
+		return traces
+	}
+	if env := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); env != "" {
+		endpoint = env
+	}
+	if endpoint == "" {
+		return ""
+	}
+	return strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+}
+
+// StartTrace starts the root span of a run when an OTLP endpoint is set. A
+// W3C TRACEPARENT in the environment, e.g. of the CI job, becomes its parent.
+func StartTrace(ctx context.Context, endpoint, name string) (context.Context, *span) {
+	endpoint = tracesEndpoint(endpoint)
+	if endpoint == "" {
+		return ctx, nil
+	}
+	if protocol := os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); protocol != "" && protocol != "http/json" {
+		Logger.Warnf("OTLP protocol %s is not supported, exporting with http/json", protocol)
+	}
+
+	t := &trace{endpoint: endpoint, headers: parseOTelList(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))}
+	root := &span{trace: t, name: name, start: time.Now(), attrs: make(map[string]any)}
+	if traceID, parentID, ok := parseTraceparent(os.Getenv("TRACEPARENT")); ok {
+		t.id, root.parentID = traceID, parentID
+	} else {
+		rand.Read(t.id[:])
+	}
+	rand.Read(root.id[:])
+	t.spans = append(t.spans, root)
+	return context.WithValue(ctx, spanKey{}, root), root
+}
+
+// StartSpan starts a child of the span in ctx, if the run is traced
+func StartSpan(ctx context.Context, name string) (context.Context, *span) {
+	parent, _ := ctx.Value(spanKey{}).(*span)
+	if parent == nil {
+		return ctx, nil
+	}
+	s := &span{trace: parent.trace, parentID: parent.id, name: name, start: time.Now(), attrs: make(map[string]any)}
+	rand.Read(s.id[:])
+
+	parent.trace.mu.Lock()
+	parent.trace.spans = append(parent.trace.spans, s)
+	parent.trace.mu.Unlock()
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Set records an attribute of the span: a string, bool, int or float64
+func (s *span) Set(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.trace.mu.Lock()
+	defer s.trace.mu.Unlock()
+	s.attrs[key] = value
+}
+
+// Finish ends the span, marking it as failed when err is not nil
+func (s *span) Finish(err error) {
+	if s == nil {
+		return
+	}
+	s.trace.mu.Lock()
+	defer s.trace.mu.Unlock()
+	s.end = time.Now()
+	if err != nil {
+		s.err = err.Error()
+	}
+}
+
+// Export sends all spans of the trace of the root span to the collector
+func (s *span) Export() {
+	if s == nil {
+		return
+	}
+	t := s.trace
+	t.mu.Lock()
+	payload := otlpPayload(t)
+	t.mu.Unlock()
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		Logger.Warnf("Failed to encode spans: %v", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), otlpExportTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		Logger.Warnf("Failed to export spans: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		Logger.Warnf("Failed to export spans to %s: %v", t.endpoint, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		Logger.Warnf("Failed to export spans to %s: %s", t.endpoint, resp.Status)
+		return
+	}
+	Logger.Infof("Exported %d spans of trace %s", len(t.spans), hex.EncodeToString(t.id[:]))
+}
+
+// otlpPayload builds an OTLP/HTTP JSON export request. IDs are hex encoded
+// and times are nanoseconds as strings, as the JSON mapping of OTLP expects.
+func otlpPayload(t *trace) map[string]any {
+	service := os.Getenv("OTEL_SERVICE_NAME")
+	if service == "" {
+		service = "sbom-scanner"
+	}
+	resource := map[string]any{"service.name": service}
+	for key, value := range parseOTelList(os.Getenv("OTEL_RESOURCE_ATTRIBUTES")) {
+		if key != "service.name" {
+			resource[key] = value
+		}
+	}
+
+	spans := make([]map[string]any, 0, len(t.spans))
+	for _, s := range t.spans {
+		end := s.end
+		if end.IsZero() {
+			end = time.Now()
+		}
+		status := map[string]any{"code": 1}
+		if s.err != "" {
+			status = map[string]any{"code": 2, "message": s.err}
+		}
+		item := map[string]any{
+			"traceId":           hex.EncodeToString(t.id[:]),
+			"spanId":            hex.EncodeToString(s.id[:]),
+			"name":              s.name,
+			"kind":              1,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(end.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.attrs),
+			"status":            status,
+		}
+		if s.parentID != [8]byte{} {
+			item["parentSpanId"] = hex.EncodeToString(s.parentID[:])
+		}
+		spans = append(spans, item)
+	}
+
+	return map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": otlpAttributes(resource)},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "github.com/xshuden/sbom-scanner"},
+				"spans": spans,
+			}},
+		}},
+	}
+}
+
+// otlpAttributes converts attributes to OTLP key-value pairs
+func otlpAttributes(attrs map[string]any) []map[string]any {
+	result := make([]map[string]any, 0, len(attrs))
+	for key, value := range attrs {
+		var v map[string]any
+		switch value := value.(type) {
+		case bool:
+			v = map[string]any{"boolValue": value}
+		case int:
+			v = map[string]any{"intValue": strconv.Itoa(value)}
+		case float64:
+			v = map[string]any{"doubleValue": value}
+		default:
+			v = map[string]any{"stringValue": fmt.Sprint(value)}
+		}
+		result = append(result, map[string]any{"key": key, "value": v})
+	}
+	return result
+}
+
+// parseOTelList parses the key=value,key=value lists of the OTEL_*
+// variables, with URL-encoded values
+func parseOTelList(value string) map[string]string {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			continue
+		}
+		if decoded, err := url.QueryUnescape(strings.TrimSpace(val)); err == nil {
+			val = decoded
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	return headers
+}
+
+// parseTraceparent reads a W3C traceparent such as
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func parseTraceparent(value string) ([16]byte, [8]byte, bool) {
+	var traceID [16]byte
+	var spanID [8]byte
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return traceID, spanID, false
+	}
+	if _, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil || traceID == [16]byte{} {
+		return traceID, spanID, false
+	}
+	if _, err := hex.Decode(spanID[:], []byte(parts[2])); err != nil || spanID == [8]byte{} {
+		return traceID, spanID, false
+	}
+	return traceID, spanID, true
+}
@@ -0,0 +1,221 @@
+package runenv
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Kinds of operations timed with --timings
+const (
+	TimingStage   = "stage"
+	TimingModule  = "module"
+	TimingMaven   = "maven"
+	timingNetwork = "network"
+)
+
+// Number of modules listed as the slowest in the timings of the summary
+const slowModuleCount = 10
+
+// Timings tell where the time of a run went, recorded with --timings. The
+// stages of concurrent modules and concurrent requests overlap, so their
+// totals can exceed the wall time of the run.
+type Timings struct {
+	Seconds float64  `json:"seconds"` // wall time of the run
+	Stages  []Timing `json:"stages"`  // pipeline stages, summed over the modules
+	Modules []Timing `json:"modules,omitempty"`
+	Maven   []Timing `json:"maven,omitempty"`   // Maven runs by goal, every attempt
+	Network []Timing `json:"network,omitempty"` // HTTP requests by host, until the body is read
+}
+
+// Timing sums the durations of the operations of the same name, slowest
+// names first
+type Timing struct {
+	Name       string  `json:"name"`
+	Count      int     `json:"count"`
+	Seconds    float64 `json:"seconds"`
+	MaxSeconds float64 `json:"max_seconds"` // longest single operation
+}
+
+// timingRecorder collects the timings of a run. It is carried in the context
+// of the run, so concurrent runs of the server record their own.
+type timingRecorder struct {
+	mu      sync.Mutex
+	start   time.Time
+	timings map[string]map[string]*Timing
+}
+
+type timingsKey struct{}
+
+// WithTimings returns ctx with a recorder for the timings of a run when they
+// are enabled
+func WithTimings(ctx context.Context, enabled bool) context.Context {
+	if !enabled {
+		return ctx
+	}
+	return context.WithValue(ctx, timingsKey{}, &timingRecorder{start: time.Now(), timings: make(map[string]map[string]*Timing)})
+}
+
+// timingsOf returns the recorder of the run ctx belongs to, nil without
+// --timings
+func timingsOf(ctx context.Context) *timingRecorder {
+	recorder, _ := ctx.Value(timingsKey{}).(*timingRecorder)
+	return recorder
+}
+
+// TimingsEnabled reports whether --timings is set for the run of ctx
+func TimingsEnabled(ctx context.Context) bool {
+	return timingsOf(ctx) != nil
+}
+
+// RecordTiming adds an operation to the timings of the run of ctx, if they
+// are recorded
+func RecordTiming(ctx context.Context, kind, name string, d time.Duration) {
+	timingsOf(ctx).record(kind, name, d)
+}
+
+func (r *timingRecorder) record(kind, name string, d time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	byName := r.timings[kind]
+	if byName == nil {
+		byName = make(map[string]*Timing)
+		r.timings[kind] = byName
+	}
+	t := byName[name]
+	if t == nil {
+		t = &Timing{Name: name}
+		byName[name] = t
+	}
+	t.Count++
+	t.Seconds += d.Seconds()
+	t.MaxSeconds = max(t.MaxSeconds, d.Seconds())
+}
+
+// TakeTimings returns the timings recorded for the run of ctx so far. It is
+// nil without --timings.
+func TakeTimings(ctx context.Context) *Timings {
+	r := timingsOf(ctx)
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sorted := func(kind string) []Timing {
+		var result []Timing
+		for _, t := range r.timings[kind] {
+			result = append(result, Timing{Name: t.Name, Count: t.Count, Seconds: roundSeconds(t.Seconds), MaxSeconds: roundSeconds(t.MaxSeconds)})
+		}
+		sort.Slice(result, func(i, j int) bool {
+			if result[i].Seconds != result[j].Seconds {
+				return result[i].Seconds > result[j].Seconds
+			}
+			return result[i].Name < result[j].Name
+		})
+		return result
+	}
+	recorded := &Timings{
+		Seconds: roundSeconds(time.Since(r.start).Seconds()),
+		Stages:  sorted(TimingStage),
+		Modules: sorted(TimingModule),
+		Maven:   sorted(TimingMaven),
+		Network: sorted(timingNetwork),
+	}
+	if recorded.Stages == nil {
+		recorded.Stages = []Timing{}
+	}
+	return recorded
+}
+
+// roundSeconds rounds to milliseconds
+func roundSeconds(seconds float64) float64 {
+	return math.Round(seconds*1000) / 1000
+}
+
+// FormatSeconds formats a duration for the timings table, with less
+// precision the longer it is
+func FormatSeconds(seconds float64) string {
+	d := time.Duration(seconds * float64(time.Second))
+	switch {
+	case d >= time.Minute:
+		return d.Round(time.Second).String()
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// WriteTimingsTable writes the timings as tables, the slowest first
+func WriteTimingsTable(w io.Writer, t *Timings) {
+	fmt.Fprintf(w, "Timings (wall time %s):\n", FormatSeconds(t.Seconds))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	sections := []struct {
+		title   string
+		timings []Timing
+	}{
+		{"STAGE", t.Stages},
+		{"MODULE", t.Modules},
+		{"MAVEN", t.Maven},
+		{"HOST", t.Network},
+	}
+	for _, section := range sections {
+		if len(section.timings) == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "  %s\tCOUNT\tTOTAL\tMAX\n", section.title)
+		for i, timing := range section.timings {
+			if section.title == "MODULE" && i == slowModuleCount {
+				fmt.Fprintf(tw, "  ... %d more\t\t\t\n", len(section.timings)-i)
+				break
+			}
+			fmt.Fprintf(tw, "  %s\t%d\t%s\t%s\n", timing.Name, timing.Count, FormatSeconds(timing.Seconds), FormatSeconds(timing.MaxSeconds))
+		}
+	}
+	tw.Flush()
+}
+
+// timedTransport records the time of every HTTP request, from sending it
+// until its body is closed, by host, in the timings of the run its context
+// belongs to
+type timedTransport struct {
+	base http.RoundTripper
+}
+
+func (t timedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorder := timingsOf(req.Context())
+	if recorder == nil {
+		return t.base.RoundTrip(req)
+	}
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		recorder.record(timingNetwork, req.URL.Host, time.Since(start))
+		return resp, err
+	}
+	resp.Body = &timedBody{ReadCloser: resp.Body, recorder: recorder, host: req.URL.Host, start: start}
+	return resp, nil
+}
+
+// timedBody records the request it belongs to when it is closed
+type timedBody struct {
+	io.ReadCloser
+	recorder *timingRecorder
+	host     string
+	start    time.Time
+	once     sync.Once
+}
+
+func (b *timedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.recorder.record(timingNetwork, b.host, time.Since(b.start)) })
+	return err
+}
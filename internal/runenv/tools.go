@@ -0,0 +1,28 @@
+package runenv
+
+// Executables used by the pipeline, overridable from the config file
+var DefaultToolPaths = map[string]string{
+	"mvn":         "mvn",
+	"gradle":      "gradle",
+	"npm":         "npm",
+	"go":          "go",
+	"osv-scanner": "osv-scanner",
+	"grype":       "grype",
+	"trivy":       "trivy",
+	"chrome":      "",
+	"cosign":      "cosign",
+	"syft":        "syft",
+	"helm":        "helm",
+}
+
+// ToolPath returns the configured executable for a tool, else the release
+// downloaded by "deps install", else the name looked up on PATH
+func (s *Settings) ToolPath(name string) string {
+	if path, ok := s.Tools[name]; ok && path != "" && path != name {
+		return path
+	}
+	if path := managedToolPath(name); path != "" {
+		return path
+	}
+	return name
+}
@@ -0,0 +1,264 @@
+package runenv
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Temporary directories older than this were left behind by killed runs
+const staleTempAge = 24 * time.Hour
+
+// Caches whose entries are removed, oldest first, when the work directory is
+// over its size limit. They are filled again on demand.
+var evictableCaches = []string{"results", "maven", "nvd", "kev", "trivy"}
+
+type runTempKey struct{}
+
+// runTempDir returns the temporary directory of the run ctx belongs to, empty
+// without --workdir
+func runTempDir(ctx context.Context) string {
+	dir, _ := ctx.Value(runTempKey{}).(string)
+	return dir
+}
+
+// workTempDir returns where temporary files go: the temporary directory of
+// the run, the tmp/ directory of the work directory outside of a run, or
+// empty for the system temp directory
+func workTempDir(ctx context.Context) string {
+	if dir := runTempDir(ctx); dir != "" {
+		return dir
+	}
+	if s := SettingsOf(ctx); s.WorkDir != "" {
+		return filepath.Join(s.WorkDir, "tmp")
+	}
+	return ""
+}
+
+// StartWorkDir prepares the work directory for a run: it removes what killed
+// runs left behind, evicts cache entries to get below --workdir-max-size and
+// creates the temporary directory of the run, returned with ctx. finish
+// removes it unless --keep-temp is set. Without --workdir nothing is done.
+func StartWorkDir(ctx context.Context) (context.Context, func(), error) {
+	s := SettingsOf(ctx)
+	if s.WorkDir == "" {
+		return ctx, func() {}, nil
+	}
+	tmp := filepath.Join(s.WorkDir, "tmp")
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		return ctx, nil, fmt.Errorf("failed to create work directory: %v", err)
+	}
+	removeStaleTemp(tmp)
+	if err := s.trimWorkDir(); err != nil {
+		return ctx, nil, err
+	}
+
+	dir, err := os.MkdirTemp(tmp, "run-")
+	if err != nil {
+		return ctx, nil, fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	finish := func() {
+		if s.KeepTemp {
+			Logger.Infof("Keeping the temporary files of the run in %s", dir)
+			return
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			Logger.Warnf("Failed to clean up temporary directory %s: %v", dir, err)
+		}
+	}
+	return context.WithValue(ctx, runTempKey{}, dir), finish, nil
+}
+
+// removeStaleTemp removes the entries of the tmp/ directory that were not
+// modified for staleTempAge, left behind by runs that were killed
+func removeStaleTemp(tmp string) {
+	entries, err := os.ReadDir(tmp)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < staleTempAge {
+			continue
+		}
+		path := filepath.Join(tmp, entry.Name())
+		if err := os.RemoveAll(path); err != nil {
+			Logger.Warnf("Failed to remove stale temporary directory %s: %v", path, err)
+			continue
+		}
+		Logger.Infof("Removed stale temporary directory %s", path)
+	}
+}
+
+// NewTempDir creates a temporary directory for the run of ctx: below
+// --workspace when set, else below workTempDir. It fails when the work
+// directory is over --workdir-max-size.
+func NewTempDir(ctx context.Context, pattern string) (string, error) {
+	s := SettingsOf(ctx)
+	parent := s.MavenWorkspaceDir
+	if parent == "" {
+		parent = workTempDir(ctx)
+	}
+	if parent != "" {
+		if err := os.MkdirAll(parent, 0755); err != nil {
+			return "", fmt.Errorf("failed to create workspace directory: %v", err)
+		}
+	}
+	if err := s.checkWorkDirSize(); err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp(parent, pattern)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(dir)
+}
+
+// checkWorkDirSize fails when the work directory is over --workdir-max-size
+func (s *Settings) checkWorkDirSize() error {
+	if s.WorkDir == "" || s.WorkDirMaxSize == 0 {
+		return nil
+	}
+	if size := dirSize(s.WorkDir); size > s.WorkDirMaxSize {
+		return fmt.Errorf("work directory %s holds %s, more than --workdir-max-size %s", s.WorkDir, formatByteSize(size), formatByteSize(s.WorkDirMaxSize))
+	}
+	return nil
+}
+
+// trimWorkDir removes the oldest cache entries until the work directory is
+// below --workdir-max-size, and fails when that is not enough
+func (s *Settings) trimWorkDir() error {
+	if s.WorkDirMaxSize == 0 {
+		return nil
+	}
+	size := dirSize(s.WorkDir)
+	if size <= s.WorkDirMaxSize {
+		return nil
+	}
+
+	type cacheEntry struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var entries []cacheEntry
+	for _, kind := range evictableCaches {
+		dir := s.CacheDir(kind)
+		if kind == "trivy" {
+			dir = s.TrivyCacheDir
+		}
+		// Caches configured outside of the work directory are not counted
+		if rel, err := filepath.Rel(s.WorkDir, dir); err != nil || !filepath.IsLocal(rel) {
+			continue
+		}
+		children, _ := os.ReadDir(dir)
+		for _, child := range children {
+			info, err := child.Info()
+			if err != nil {
+				continue
+			}
+			path := filepath.Join(dir, child.Name())
+			entries = append(entries, cacheEntry{path: path, size: dirSize(path), modTime: info.ModTime()})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].modTime.Before(entries[j].modTime) })
+
+	removed, freed := 0, int64(0)
+	for _, entry := range entries {
+		if size <= s.WorkDirMaxSize {
+			break
+		}
+		if err := os.RemoveAll(entry.path); err != nil {
+			Logger.Warnf("Failed to remove cache entry %s: %v", entry.path, err)
+			continue
+		}
+		size -= entry.size
+		freed += entry.size
+		removed++
+	}
+	if removed > 0 {
+		Logger.Infof("Removed %d cache entries (%s) to keep the work directory %s below %s",
+			removed, formatByteSize(freed), s.WorkDir, formatByteSize(s.WorkDirMaxSize))
+	}
+	if size > s.WorkDirMaxSize {
+		return fmt.Errorf("work directory %s holds %s, more than --workdir-max-size %s without the caches", s.WorkDir, formatByteSize(size), formatByteSize(s.WorkDirMaxSize))
+	}
+	return nil
+}
+
+// dirSize returns the size of the files below path. Files removed while it
+// is counted, e.g. by concurrent modules, are skipped.
+func dirSize(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+var byteSizePattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([KMGT]?)(?:I?B)?$`)
+
+// ParseByteSize parses a --workdir-max-size value such as 20GB or 512M, in
+// binary units
+func ParseByteSize(value string) (int64, error) {
+	m := byteSizePattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(value)))
+	if m == nil {
+		return 0, fmt.Errorf("invalid --workdir-max-size: %s (expected e.g. 20GB or 512MB)", value)
+	}
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid --workdir-max-size: %s (expected e.g. 20GB or 512MB)", value)
+	}
+	shift := strings.Index("KMGT", m[2])*10 + 10
+	if m[2] == "" {
+		shift = 0
+	}
+	return int64(n * float64(int64(1)<<shift)), nil
+}
+
+// formatByteSize formats a size for the messages of the size limit
+func formatByteSize(size int64) string {
+	switch {
+	case size >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(size)/(1<<30))
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	}
+	return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+}
+
+// tempEnv points the temporary files of a subprocess to dir: TMPDIR, TMP and
+// TEMP for most tools and java.io.tmpdir for the JVMs of Maven and Gradle,
+// added to the options already set
+func tempEnv(env []string, dir string) []string {
+	javaOpt := "-Djava.io.tmpdir=" + dir
+	values := map[string]string{"TMPDIR": dir, "TMP": dir, "TEMP": dir, "MAVEN_OPTS": javaOpt, "GRADLE_OPTS": javaOpt}
+	result := make([]string, 0, len(env)+len(values))
+	for _, kv := range env {
+		name, value, _ := strings.Cut(kv, "=")
+		if _, ok := values[name]; !ok {
+			result = append(result, kv)
+			continue
+		}
+		if strings.HasSuffix(name, "_OPTS") && value != "" {
+			values[name] = value + " " + javaOpt
+		}
+	}
+	for _, name := range []string{"TMPDIR", "TMP", "TEMP", "MAVEN_OPTS", "GRADLE_OPTS"} {
+		result = append(result, name+"="+values[name])
+	}
+	return result
+}
@@ -1,325 +1,757 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
 	"os"
-	"os/exec"
-	"path/filepath"
+	"os/signal"
 	"runtime"
+	"runtime/pprof"
 	"strings"
+	"syscall"
 	"time"
 
-	"github.com/schollz/progressbar/v3"
 	"github.com/sirupsen/logrus"
+	"github.com/xshuden/sbom-scanner/internal/cli"
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/maven"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+	"github.com/xshuden/sbom-scanner/pkg/scanner"
 )
 
-var logger = logrus.New()
+var logger = scanner.Logger()
 
 const helpText = `SBOM Scanner - Software Bill of Materials Scanner
 
 Usage:
-  sbom-scanner [flags]
+  sbom-scanner [flags]               Run the whole pipeline
+  sbom-scanner scan --git <url> [--ref ref] [flags]
+                                    Clone a remote Git repository and scan it
+  sbom-scanner sbom generate [project] [-f project] [-o dir] [-r resolver] [--scopes list] [--sign] [--attest format]
+                                    Only generate the SBOM and dependency tree
+  sbom-scanner vuln scan <sbom.xml> [-s scanner] [--fail-on severity] [--fail-on-unknown] [-e] [--ignore ids] [--offline] [--ghsa] [--nvd] [--exploits]
+                                    Scan an existing CycloneDX SBOM
+  sbom-scanner report render <findings.json> [--format csv,html,junit,markdown,openvex,pdf] [-o path]
+                                    Render reports from the findings of a scan
+  sbom-scanner deps check [--output-format json]
+                                    Check required dependencies and their versions
+  sbom-scanner deps install [osv-scanner] [maven]
+                                    Download pinned releases into the tools directory
+  sbom-scanner config init [path]   Create a .sbom-scanner.yaml config file
+  sbom-scanner diff <baseline.json> <current.json> [--json] [--exit-on-new] [--fail-on severity]
+                                    Compare the findings of two scans
+  sbom-scanner sbom-diff <old-sbom> <new-sbom> [--format text|json|markdown]
+                                    List added, removed and upgraded components
+                                    between two CycloneDX or SPDX documents
+  sbom-scanner merge <sbom|dir>... [-o merged-sbom.xml] [--format xml|json] [--name product]
+                                    Combine several SBOMs into one CycloneDX document
+  sbom-scanner fs <dir> [-o sbom.xml] [--format xml|json]
+                                    Generate the SBOM of the JARs, wheels, node_modules
+                                    and OS packages found in a directory
+  sbom-scanner convert <sbom> [-o output] [--to cyclonedx-xml|cyclonedx-json|spdx-json|spdx-tag-value]
+                                    Translate an SBOM between CycloneDX and SPDX
+  sbom-scanner history [list|show <id>] [--db path] [--target path] [-n count] [--json]
+                                    List and inspect past scans
+  sbom-scanner db [download|status] [--db-dir dir] [--ecosystems Maven,npm,...]
+                                    Download or inspect the offline OSV database
+  sbom-scanner fix [pom.xml] [-o dir] [-r resolver] [-s scanner] [--dry-run] [--pr github|gitlab]
+                                    Upgrade vulnerable Maven dependencies to fixed
+                                    versions and validate with a new scan
+  sbom-scanner serve [--listen 127.0.0.1:8080] [--data-dir dir] [--config path] [--workers n]
+                                    Run scans submitted to a REST API
+  sbom-scanner verify [--key cosign.pub | --certificate-identity id --certificate-oidc-issuer url] <sbom.xml|dir>...
+                                    Verify the cosign signatures of SBOMs signed with --sign
 
 Flags:
-  -f, --file string     Path to POM file (default: "data/pom.xml")
-  -o, --output string   Output directory (default: "scan-results")
+  -f, --file string     Path to project file or directory: pom.xml,
+                       build.gradle(.kts), package.json, package-lock.json,
+                       yarn.lock, pnpm-lock.yaml, go.mod, requirements.txt,
+                       poetry.lock, Pipfile.lock, Cargo.lock,
+                       packages.lock.json, *.csproj, composer.lock,
+                       Gemfile.lock, a built .jar/.war/.ear, an
+                       existing CycloneDX/SPDX SBOM or a Docker Compose file
+                       (default: "data/pom.xml")
+                       [directories are searched recursively for modules]
+      --k8s             -f is a Kubernetes manifest, a directory of manifests
+                       or a Helm chart; the container images of its workloads
+                       are cataloged with syft and scanned
+      --helm-values string
+                       Comma-separated values files to render the Helm chart with
+      --registry string Container registry repository, e.g. ghcr.io/org/app,
+                       whose tags are scanned instead of -f; credentials from
+                       REGISTRY_USERNAME/REGISTRY_PASSWORD or docker login
+      --tags string     Comma-separated globs of the tags to scan, e.g. "v1.*"
+                       (default: all tags)
+      --exclude-tags string
+                       Comma-separated globs of the tags to skip, e.g. "*-rc*"
+      --git string      Remote Git repository (https://, ssh:// or git@host:path)
+                       to shallow-clone into a temporary directory and scan;
+                       -f is then a path inside the repository (default: the
+                       whole repository). The clone is removed after the scan
+      --ref string      Branch, tag or commit of the --git repository
+                       (default: the default branch)
+      --since string    Git ref, e.g. origin/main: only the modules whose manifests
+                       (project files, lockfiles, local parent POMs, Gradle
+                       settings) changed since then are scanned, the others
+                       reuse their results from the previous run in the
+                       output directory
+      --watch           Scan again whenever a project file (or its lockfile)
+                       changes and print only the changes: added, removed and
+                       upgraded components, new and fixed vulnerabilities.
+                       Only the latest run directory is kept, scans are not
+                       recorded in the history and send no notifications
+  -o, --output string   Output directory (default: "scan-results"); every run
+                       writes to a new timestamped subdirectory
+      --clean           Write directly into the output directory after emptying
+                       it (only directories created by sbom-scanner)
+      --keep-last int   Number of run directories kept in the output directory,
+                       older ones are removed (default: 0, keep all)
   -e, --exit-on-vuln    Exit when vulnerabilities are found (for CI/CD)
                        [true: exits with error if vulnerabilities found]
                        [false: continues even if vulnerabilities found (default)]
+  -r, --resolver string Maven dependency resolver: maven or native
+                       [maven: runs mvn, falls back to native if mvn is missing (default)]
+                       [native: resolves the POM in Go using Maven Central]
+      --scopes string   Comma-separated Maven scopes to scan, e.g. compile,runtime
+                       (compile, runtime, provided, system, test; default: all)
+                       [applied to the dependency tree and the SBOM; Gradle
+                        configurations are mapped onto these scopes]
+  -s, --scanner string  Vulnerability scanner: osv, osv-binary, grype or trivy
+                       [osv: queries the OSV.dev API directly (default)]
+                       [osv-binary: runs the osv-scanner executable]
+                       [grype: runs the grype executable]
+                       [trivy: runs the trivy executable]
+                       [a comma-separated list (e.g. osv,grype) or all runs
+                        several scanners concurrently and merges the findings]
+      --trivy-cache-dir string
+                       Trivy cache directory holding the vulnerability DB
+      --offline         Scan without internet access: the osv scanner matches
+                       against the database fetched with "sbom-scanner db download",
+                       grype and trivy use their installed DBs, and Maven Central
+                       and deps.dev lookups are skipped
+      --db-dir string   Offline OSV database directory
+                       (default: sbom-scanner/osv in the user cache directory)
+      --cache-ttl duration
+                       Reuse the scan results of an SBOM with the same components
+                       and the Maven resolution of an unchanged POM for this long,
+                       0 disables the cache (default: 6h)
+      --no-cache        Always query the vulnerability scanners and run Maven
+      --maven-repo-local string
+                       Maven local repository (-Dmaven.repo.local)
+      --maven-offline   Run Maven with --offline, using only the local repository
+      --maven-settings string
+                       Maven settings.xml passed to every Maven run (-s), e.g.
+                       with a Nexus or Artifactory mirror
+      --maven-profiles string
+                       Comma-separated Maven profiles activated with -P, e.g.
+                       prod,!dev
+      --maven-repository string
+                       Comma-separated URLs of private Maven repositories, added
+                       to a generated settings.xml with the credentials of
+                       MAVEN_REPO_USERNAME/MAVEN_REPO_PASSWORD or MAVEN_REPO_TOKEN
+      --maven-mirror string
+                       URL of a private Maven repository that mirrors all
+                       others, e.g. an Artifactory or Nexus virtual repository
+      --cyclonedx-plugin-version string
+                       CycloneDX Maven plugin version, latest for the newest
+                       release (default: 2.7.9)
+      --workspace string
+                       Directory for the temporary copies of the POM that Maven
+                       runs on (default: the system temp directory)
+      --no-deps-tree    Skip the dependency tree (deps-tree.txt); findings get no
+                       dependency paths and --graph is unavailable
+      --no-effective-pom
+                       Skip the Maven effective POM (effective-pom.xml)
+      --include-plugins Add the Maven build plugins and their dependencies to
+                       the SBOM as components with scope excluded
+      --keep-temp       Keep the Maven workspaces and Gradle build directories
+                       for debugging
+      --workdir string  Directory for the temporary files, Maven workspaces,
+                       clones and caches of the scan, e.g. a scratch volume
+                       (default: the system temp and user cache directories)
+      --workdir-max-size string
+                       Size limit of --workdir, e.g. 20GB; the oldest cache
+                       entries are removed to stay below it
+      --mvn-path string Maven executable (default: the project's Maven wrapper
+                       mvnw if it has one, else mvn)
+      --mvn-args string Options added to every Maven run, separated by spaces,
+                       e.g. "-DskipTests -T 1C"
+      --parallelism int Maximum number of concurrently scanned modules and Maven
+                       processes; the dependency tree, effective POM and SBOM
+                       are generated in parallel
+                       (default: number of CPUs, 1 runs everything in order)
+      --timeout duration
+                       Fail the scan when it takes longer, e.g. 30m
+                       (default: no timeout)
+      --stage-timeout duration
+                       Fail the scan when a single step, e.g. the Maven
+                       resolution, takes longer (default: no timeout)
+      --retries int     Retries of OSV queries and Maven downloads that failed
+                       with a network error (default: 2, 0 disables)
+      --retry-backoff duration
+                       Wait before the first retry, doubled for every further
+                       one (default: 2s)
+      --baseline string Findings of a previous scan (sbom-findings.json or
+                       aggregated-report.json); --exit-on-vuln and --fail-on
+                       only consider vulnerabilities that are not in it
+      --ghsa            Enrich the findings with summaries, CWE IDs and the
+                       withdrawn state of the GitHub Security Advisories;
+                       withdrawn advisories are suppressed [token in GITHUB_TOKEN]
+      --nvd             Enrich the findings with the CVSS v3 vectors, CWE IDs and
+                       vulnerable CPEs of the NVD [API key in NVD_API_KEY]
+      --exploits        Enrich the findings with the EPSS probabilities of FIRST
+                       and the CISA Known Exploited Vulnerabilities catalog,
+                       which weight the risk score of the summary
+      --reachability    Tag the findings of Java projects as reachable or
+                       unreachable from the compiled classes in target/classes
+                       or build/classes (build the project first)
+      --maintenance     List end of life, deprecated and unmaintained components
+                       in a separate section of the reports
+      --stale-after string
+                       No release for this long makes a package unmaintained,
+                       e.g. 18m or 3y (default: 2y)
+      --supply-chain    Flag internal packages published in public registries
+                       (dependency confusion) and typosquatting names
+      --internal-namespaces string
+                       Comma-separated Maven groupIds, npm scopes or package
+                       patterns of internal packages, e.g. com.example,@example
+      --secrets         Scan the project files for API keys, tokens and private
+                       keys and list them in a separate section of the reports
+      --fail-on-secret string
+                       Exit with an error when a secret at or above this
+                       severity is found: critical, high, medium or low
+      --history-db string
+                       Scan history database (default: scan-history.db in the
+                       output directory); list past scans with "sbom-scanner history"
+      --no-history      Do not record the scan in the history database
+      --webhook-url string
+                       POST the normalized results as JSON to this URL after the scan
+                       [signed with HMAC-SHA256 when SBOM_SCANNER_WEBHOOK_SECRET is set]
+      --sign            Sign every SBOM with cosign and write sbom.xml.sig and
+                       sbom.xml.bundle next to it; keyless through Fulcio and
+                       Rekor with the OIDC identity of the CI job or the browser
+      --sign-key string cosign private key or KMS URI to sign with instead of
+                       keyless signing [password in COSIGN_PASSWORD]
+      --attest string   Write an in-toto attestation of every SBOM with a
+                       cyclonedx or spdx predicate (sbom.xml.intoto.json) and
+                       its SLSA provenance with the input digests, tool
+                       versions and CI environment (sbom.provenance.json)
+      --attest-subject string
+                       Comma-separated artifacts the SBOM describes, files or
+                       name@sha256:digest (default: the project files)
+      --otel-endpoint string
+                       Export a trace of the run with a span per module and
+                       pipeline stage to this OTLP/HTTP collector, e.g.
+                       http://localhost:4318 [OTEL_EXPORTER_OTLP_ENDPOINT and
+                       the other OTEL_* variables are honored]
+      --timings         Log the duration of every pipeline stage and add the time
+                       spent per stage, module, Maven goal and HTTP host to the
+                       summary and summary.json
+      --profile string  Write a CPU profile of the run in pprof format to this
+                       file, for go tool pprof
+      --comment-pr string
+                       Post the markdown report as a single comment to this GitHub,
+                       GitLab or Bitbucket pull request URL, updated by later scans;
+                       auto detects the pull request in CI [token in GITHUB_TOKEN,
+                       GITLAB_TOKEN or BITBUCKET_TOKEN]
+      --defectdojo-url string
+                       Import the findings into DefectDojo (API key in DEFECTDOJO_TOKEN)
+      --defectdojo-product string
+                       DefectDojo product, needed when the engagement is a name
+      --defectdojo-engagement string
+                       DefectDojo engagement ID, or a name created when missing
+      --defectdojo-dedup
+                       Deduplicate against the whole engagement
+      --defectdojo-close-old
+                       Close findings of the same module missing from the import
+      --report string   Comma-separated report formats to render next to
+                       the JSON results: csv, html, junit, markdown, openvex, pdf
+      --graph string    Comma-separated dependency graph formats to export:
+                       dot, mermaid, graphml [vulnerable packages in red]
+      --fail-on string  Exit with an error only when a vulnerability at or above
+                       this severity is found: critical, high, medium or low
+                       [severities are taken from CVSS scores in the OSV results]
+      --fail-on-unknown With --fail-on, also fail for vulnerabilities without
+                       any severity information
+      --fail-on-license string
+                       Comma-separated licenses that fail the scan, e.g. GPL-3.0,AGPL-3.0
+                       [GPL-3.0 also matches GPL-3.0-only and GPL-3.0-or-later]
+      --config string   Path to config file (default: .sbom-scanner.yaml in the
+                       project directory or the working directory)
+                       [command line flags override config values]
+      --ignore string   Comma-separated vulnerability IDs (CVE/GHSA) or
+                       package@version entries to suppress
+      --ignore-file string
+                       YAML file with ignore rules (id, package, expires, reason)
+                       [suppressed findings do not fail the scan]
+      --policy-file string
+                       YAML file with policy rules over components and findings
+                       (package, license, max-age, unpinned, severity,
+                       fix-available)
+                       [rules with action fail fail the scan]
+      --vex string      Comma-separated OpenVEX or CycloneDX VEX (JSON) documents;
+                       not_affected and fixed statements suppress findings
+  -q, --quiet          Hide the progress bar and info logs and only print the
+                       final summary on stdout (warnings and errors go to stderr)
+      --output-format string
+                       Format of the final summary: text or json
+                       [json prints only the summary on stdout, logs go to stderr]
   -h, --help           Show help message
-  -c, --check          Check and install required dependencies
+  -c, --check          Check and install required dependencies and report their
+                       versions; fails when Maven, Java or osv-scanner is missing
+                       or too old [--output-format=json prints the report]
+
+Exit codes:
+  0    No vulnerabilities above the threshold
+  1    Vulnerabilities found (--exit-on-vuln, or --fail-on exceeded)
+  2    Policy violation (--policy-file rule with action fail, --fail-on-license,
+       --fail-on-secret)
+  3    Tool or configuration error, e.g. invalid flags or a failed build
+  4    Missing dependency: a selected scanner, Gradle or the browser for PDF
+       reports is not installed, or --check failed
+  5    Known malicious package version (OSV MAL- advisory), regardless of
+       --fail-on and the baseline
+  130  Interrupted
+  With several modules, the highest code of the failed modules is used.
 `
 
-func init() {
-	logger.SetFormatter(&logrus.TextFormatter{
-		FullTimestamp:    true,
-		TimestampFormat:  "2006-01-02T15:04:05-07:00",
-		ForceColors:      true,
-		DisableTimestamp: false,
-	})
-	logger.SetOutput(os.Stdout)
-	logger.SetLevel(logrus.InfoLevel)
-}
-
-func copyFile(src, dst string) error {
-	// Create destination directory if it doesn't exist
-	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
-		return fmt.Errorf("failed to create destination directory: %v", err)
-	}
-
-	sourceFile, err := os.Open(src)
-	if err != nil {
-		return fmt.Errorf("failed to open source file: %v", err)
-	}
-	defer sourceFile.Close()
-
-	destFile, err := os.Create(dst)
-	if err != nil {
-		return fmt.Errorf("failed to create destination file: %v", err)
-	}
-	defer destFile.Close()
-
-	if _, err := io.Copy(destFile, sourceFile); err != nil {
-		return fmt.Errorf("failed to copy file: %v", err)
-	}
+func main() {
+	var (
+		pomFile    string
+		outputDir  string
+		clean      bool
+		keepLast   int
+		exitOnVuln bool
+		showHelp   bool
+		check      bool
+		resolver   string
+		scanners   string
+		reports    string
+		graph      string
+		scopes     string
+		dbDir      string
+		cacheTTL   string
+		noCache    bool
+		repoLocal  string
+		mvnOffline bool
+		settings   string
+		mvnRepos   string
+		mvnMirror  string
+		mvnPath    string
+		profiles   string
+		cdxVersion string
+		workspace  string
+		noDepsTree bool
+		noEffPom   bool
+		withPlugin bool
+		keepTemp   bool
+		workdir    string
+		workdirMax string
+		mvnArgs    string
+		mvnArgList []string
+		failOn     string
+		failOnUnk  bool
+		denylist   string
+		configPath string
+		ignore     string
+		ignorePath string
+		policyPath string
+		vexPaths   string
+		trivyCache string
+		dojo       scanner.DefectDojo
+		webhookURL string
+		commentPR  string
+		otelURL    string
+		timings    bool
+		profile    string
+		sign       bool
+		signKey    string
+		attest     string
+		subjects   string
+		email      scanner.EmailConfig
+		offline    bool
+		parallel   int
+		tools      scanner.ToolsConfig
+		historyDB  string
+		noHistory  bool
+		baseline   string
+		ghsa       bool
+		nvd        bool
+		exploits   bool
+		reachable  bool
+		maintain   bool
+		staleAfter string
+		supply     bool
+		internal   string
+		secrets    bool
+		failSecret string
+		timeout    string
+		stageLimit string
+		retries    int
+		backoff    string
+		quiet      bool
+		outFormat  string
+		gitURL     string
+		k8s        bool
+		helmValues string
+		registry   string
+		tags       string
+		exclTags   string
+		gitRef     string
+		since      string
+		watch      bool
+	)
 
-	return nil
-}
+	flag.StringVar(&pomFile, "f", "data/pom.xml", "Path to project file")
+	flag.StringVar(&outputDir, "o", "scan-results", "Output directory")
+	flag.BoolVar(&clean, "clean", false, "Empty the output directory and write into it instead of a timestamped subdirectory")
+	flag.IntVar(&keepLast, "keep-last", 0, "Number of run directories kept in the output directory (0: all)")
+	flag.BoolVar(&exitOnVuln, "e", false, "Exit when vulnerabilities are found")
+	flag.BoolVar(&showHelp, "h", false, "Show help message")
+	flag.BoolVar(&quiet, "q", false, "Only print the final summary")
+	flag.BoolVar(&check, "c", false, "Check and install required dependencies")
+	flag.StringVar(&resolver, "r", "maven", "Maven dependency resolver (maven, native)")
+	flag.StringVar(&scanners, "s", "osv", "Vulnerability scanners (osv, osv-binary, grype, trivy, a comma-separated list or all)")
+	flag.StringVar(&reports, "report", "", "Report formats to render (csv, html, junit, markdown, openvex, pdf)")
+	flag.StringVar(&graph, "graph", "", "Dependency graph formats to export (dot, mermaid, graphml)")
+	flag.StringVar(&scopes, "scopes", "", "Comma-separated Maven scopes to scan (default: all)")
+	flag.StringVar(&failOn, "fail-on", "", "Fail when a vulnerability at or above this severity is found")
+	flag.BoolVar(&failOnUnk, "fail-on-unknown", false, "With --fail-on, also fail for vulnerabilities of unknown severity")
+	flag.StringVar(&denylist, "fail-on-license", "", "Comma-separated licenses (SPDX ids) that fail the scan")
+	flag.StringVar(&configPath, "config", "", "Path to config file")
+	flag.StringVar(&ignore, "ignore", "", "Comma-separated vulnerability IDs or package@version entries to ignore")
+	flag.StringVar(&ignorePath, "ignore-file", "", "Path to YAML file with ignore rules")
+	flag.StringVar(&policyPath, "policy-file", "", "Path to YAML file with policy rules")
+	flag.StringVar(&vexPaths, "vex", "", "Comma-separated OpenVEX or CycloneDX VEX documents")
+	flag.StringVar(&trivyCache, "trivy-cache-dir", "", "Trivy cache directory with the vulnerability DB")
+	flag.BoolVar(&offline, "offline", false, "Scan without internet access using the offline OSV database")
+	flag.StringVar(&dbDir, "db-dir", "", "Offline OSV database directory")
+	flag.StringVar(&cacheTTL, "cache-ttl", runenv.DefaultCacheTTL.String(), "Reuse scan results of the same SBOM components for this long")
+	flag.BoolVar(&noCache, "no-cache", false, "Always query the vulnerability scanners")
+	flag.StringVar(&repoLocal, "maven-repo-local", "", "Maven local repository (-Dmaven.repo.local)")
+	flag.BoolVar(&mvnOffline, "maven-offline", false, "Run Maven with --offline")
+	flag.StringVar(&settings, "maven-settings", "", "Maven settings.xml passed to every Maven run (-s)")
+	flag.StringVar(&profiles, "maven-profiles", "", "Comma-separated Maven profiles activated with -P")
+	flag.StringVar(&mvnRepos, "maven-repository", "", "Comma-separated URLs of private Maven repositories")
+	flag.StringVar(&mvnMirror, "maven-mirror", "", "URL of a private Maven repository mirroring all others")
+	flag.StringVar(&cdxVersion, "cyclonedx-plugin-version", maven.DefaultCycloneDXPluginVersion, "CycloneDX Maven plugin version, or latest")
+	flag.StringVar(&workspace, "workspace", "", "Directory for the temporary Maven workspaces (default: system temp directory)")
+	flag.BoolVar(&noDepsTree, "no-deps-tree", false, "Skip the dependency tree")
+	flag.BoolVar(&noEffPom, "no-effective-pom", false, "Skip the Maven effective POM")
+	flag.BoolVar(&withPlugin, "include-plugins", false, "Add the Maven build plugins and their dependencies to the SBOM")
+	flag.BoolVar(&keepTemp, "keep-temp", false, "Keep the Maven workspaces and Gradle build directories")
+	flag.StringVar(&workdir, "workdir", "", "Directory for the temporary files and caches (default: system temp and user cache directories)")
+	flag.StringVar(&workdirMax, "workdir-max-size", "", "Size limit of --workdir, e.g. 20GB")
+	flag.StringVar(&mvnPath, "mvn-path", "", "Maven executable, disables the Maven wrapper (mvnw) detection")
+	flag.StringVar(&mvnArgs, "mvn-args", "", "Options added to every Maven run, separated by spaces")
+	flag.IntVar(&parallel, "parallelism", runtime.NumCPU(), "Maximum number of concurrently scanned modules and Maven processes")
+	flag.StringVar(&timeout, "timeout", "0", "Maximum duration of the whole scan (0: no timeout)")
+	flag.StringVar(&stageLimit, "stage-timeout", "0", "Maximum duration of a single pipeline step (0: no timeout)")
+	flag.IntVar(&retries, "retries", runenv.DefaultRetries, "Retries of OSV queries and Maven downloads")
+	flag.StringVar(&backoff, "retry-backoff", runenv.DefaultRetryBackoff.String(), "Wait before the first retry, doubled for every further one")
+	flag.StringVar(&baseline, "baseline", "", "Findings of a previous scan, only new vulnerabilities fail the scan")
+	flag.BoolVar(&ghsa, "ghsa", false, "Enrich the findings from the GitHub Security Advisories")
+	flag.BoolVar(&nvd, "nvd", false, "Enrich the findings with the CVSS vectors and CPEs of the NVD")
+	flag.BoolVar(&exploits, "exploits", false, "Enrich the findings with EPSS scores and the CISA KEV catalog")
+	flag.BoolVar(&reachable, "reachability", false, "Tag Java findings as reachable or unreachable from the compiled classes")
+	flag.BoolVar(&maintain, "maintenance", false, "List end of life, deprecated and unmaintained components")
+	flag.StringVar(&staleAfter, "stale-after", scan.DefaultStaleAfter, "No release for this long makes a package unmaintained")
+	flag.BoolVar(&supply, "supply-chain", false, "Flag dependency confusion and typosquatting")
+	flag.StringVar(&internal, "internal-namespaces", "", "Comma-separated namespaces of internal packages")
+	flag.BoolVar(&secrets, "secrets", false, "Scan the project files for secrets")
+	flag.StringVar(&failSecret, "fail-on-secret", "", "Fail when a secret at or above this severity is found")
+	flag.StringVar(&historyDB, "history-db", "", "Scan history database (default: scan-history.db in the output directory)")
+	flag.BoolVar(&noHistory, "no-history", false, "Do not record the scan in the history database")
+	flag.StringVar(&webhookURL, "webhook-url", "", "Post the scan results as JSON to this URL")
+	flag.BoolVar(&sign, "sign", false, "Sign the SBOMs with cosign")
+	flag.StringVar(&signKey, "sign-key", "", "cosign private key or KMS URI (default: keyless)")
+	flag.StringVar(&attest, "attest", "", "Write an in-toto attestation of every SBOM (cyclonedx, spdx)")
+	flag.StringVar(&subjects, "attest-subject", "", "Comma-separated artifacts the SBOM describes, files or name@sha256:digest")
+	flag.StringVar(&otelURL, "otel-endpoint", "", "OTLP/HTTP collector to export the spans of the pipeline stages to")
+	flag.BoolVar(&timings, "timings", false, "Log and summarize the time of the stages, Maven runs and HTTP requests")
+	flag.StringVar(&profile, "profile", "", "Write a pprof CPU profile of the run to this file")
+	flag.StringVar(&commentPR, "comment-pr", "", "Post the report as a comment to this pull request URL, or auto")
+	flag.StringVar(&dojo.URL, "defectdojo-url", "", "DefectDojo URL to import the findings into")
+	flag.StringVar(&dojo.Product, "defectdojo-product", "", "DefectDojo product name")
+	flag.StringVar(&dojo.Engagement, "defectdojo-engagement", "", "DefectDojo engagement ID or name")
+	flag.BoolVar(&dojo.Dedup, "defectdojo-dedup", false, "Deduplicate findings across the DefectDojo engagement")
+	flag.BoolVar(&dojo.CloseOld, "defectdojo-close-old", false, "Close DefectDojo findings missing from the import")
+
+	flag.StringVar(&pomFile, "file", "data/pom.xml", "Path to project file")
+	flag.StringVar(&gitURL, "git", "", "Remote Git repository to clone and scan")
+	flag.BoolVar(&k8s, "k8s", false, "Scan the container images of Kubernetes manifests or a Helm chart")
+	flag.StringVar(&helmValues, "helm-values", "", "Comma-separated values files of the Helm chart")
+	flag.StringVar(&registry, "registry", "", "Container registry repository whose tags are scanned")
+	flag.StringVar(&tags, "tags", "", "Comma-separated globs of the tags to scan")
+	flag.StringVar(&exclTags, "exclude-tags", "", "Comma-separated globs of the tags to skip")
+	flag.StringVar(&gitRef, "ref", "", "Branch, tag or commit of the --git repository")
+	flag.StringVar(&since, "since", "", "Only scan the modules whose manifests changed since this Git ref")
+	flag.BoolVar(&watch, "watch", false, "Scan again whenever a project file changes and print the changes")
+	flag.StringVar(&outputDir, "output", "scan-results", "Output directory")
+	flag.BoolVar(&exitOnVuln, "exit-on-vuln", false, "Exit when vulnerabilities are found")
+	flag.BoolVar(&showHelp, "help", false, "Show help message")
+	flag.BoolVar(&quiet, "quiet", false, "Only print the final summary")
+	flag.StringVar(&outFormat, "output-format", "text", "Format of the final summary (text, json)")
+	flag.BoolVar(&check, "check", false, "Check and install required dependencies")
+	flag.StringVar(&resolver, "resolver", "maven", "Maven dependency resolver (maven, native)")
+	flag.StringVar(&scanners, "scanner", "osv", "Vulnerability scanners (osv, osv-binary, grype, trivy, a comma-separated list or all)")
 
-func runMavenCommand(pomPath, outputPath string) error {
-	// Mutlak yolları al
-	absPomPath, err := filepath.Abs(pomPath)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %v", err)
-	}
+	// Invalid flags and config files are configuration errors
+	logger.ExitFunc = func(int) { os.Exit(scanner.ExitError) }
 
-	absOutputPath, err := filepath.Abs(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %v", err)
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, helpText)
 	}
 
-	cmd := exec.Command("mvn",
-		"dependency:tree",
-		"-f", absPomPath,
-		"-DoutputFile="+absOutputPath,
-		"-DoutputType=text")
-	
-	// Çalışma dizinini ayarla
-	cmd.Dir = filepath.Dir(absOutputPath)
+	// Ctrl-C and SIGTERM cancel the scan: child processes are killed and
+	// partial results removed. A second signal exits immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
 
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("maven command failed: %v\n%s", err, string(output))
+	if len(os.Args) > 1 && os.Args[1] == "sbom" {
+		if err := cli.RunSBOMCommand(ctx, os.Args[2:]); err != nil {
+			exit(err)
+		}
+		os.Exit(0)
 	}
 
-	logger.Infof("Dependency tree written to %s", outputPath)
-	return nil
-}
-
-func getEffectivePom(pomPath, outputPath string) error {
-	// Mutlak yolları al
-	absPomPath, err := filepath.Abs(pomPath)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %v", err)
+	if len(os.Args) > 1 && os.Args[1] == "vuln" {
+		if err := cli.RunVulnCommand(ctx, os.Args[2:]); err != nil {
+			exit(err)
+		}
+		os.Exit(0)
 	}
 
-	absOutputPath, err := filepath.Abs(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %v", err)
+	if len(os.Args) > 1 && os.Args[1] == "report" {
+		if err := cli.RunReportCommand(ctx, os.Args[2:]); err != nil {
+			exit(err)
+		}
+		os.Exit(0)
 	}
 
-	cmd := exec.Command("mvn",
-		"help:effective-pom",
-		"-f", absPomPath,
-		"-Doutput="+absOutputPath)
-	
-	// Çalışma dizinini ayarla
-	cmd.Dir = filepath.Dir(absOutputPath)
-
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("effective-pom generation failed: %v\n%s", err, string(output))
+	if len(os.Args) > 1 && os.Args[1] == "deps" {
+		if err := cli.RunDepsCommand(os.Args[2:]); err != nil {
+			exit(err)
+		}
+		os.Exit(0)
 	}
 
-	logger.Infof("Effective POM written to %s", outputPath)
-	return nil
-}
-
-func generateCycloneDX(pomPath, outputPath string) error {
-	// Mutlak yolları al
-	absPomPath, err := filepath.Abs(pomPath)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %v", err)
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		if err := cli.RunConfigCommand(os.Args[2:]); err != nil {
+			exit(err)
+		}
+		os.Exit(0)
 	}
 
-	absOutputPath, err := filepath.Abs(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %v", err)
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		if err := cli.RunDiffCommand(os.Args[2:]); err != nil {
+			exit(err)
+		}
+		os.Exit(0)
 	}
 
-	outputDir := filepath.Dir(absOutputPath)
-	targetDir := filepath.Join(outputDir, "target")
-
-	// Target dizinini oluştur
-	if err := os.MkdirAll(targetDir, 0755); err != nil {
-		return fmt.Errorf("failed to create target directory: %v", err)
+	if len(os.Args) > 1 && os.Args[1] == "sbom-diff" {
+		if err := cli.RunSBOMDiffCommand(os.Args[2:]); err != nil {
+			exit(err)
+		}
+		os.Exit(0)
 	}
 
-	cmd := exec.Command("mvn",
-		"org.cyclonedx:cyclonedx-maven-plugin:2.7.9:makeAggregateBom",
-		"-f", absPomPath,
-		"-DoutputFormat=xml",
-		"-DoutputFile=bom.xml")
-
-	cmd.Dir = outputDir
-
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("cyclonedx generation failed: %v\n%s", err, string(output))
+	if len(os.Args) > 1 && os.Args[1] == "merge" {
+		if err := cli.RunMergeCommand(os.Args[2:]); err != nil {
+			exit(err)
+		}
+		os.Exit(0)
 	}
 
-	// target/bom.xml'i sbom.xml olarak taşı
-	srcPath := filepath.Join(targetDir, "bom.xml")
-	if err := os.Rename(srcPath, absOutputPath); err != nil {
-		return fmt.Errorf("failed to move SBOM to output dir: %v", err)
+	if len(os.Args) > 1 && os.Args[1] == "fs" {
+		if err := cli.RunFSCommand(ctx, os.Args[2:]); err != nil {
+			exit(err)
+		}
+		os.Exit(0)
 	}
 
-	// target dizinini temizle
-	if err := os.RemoveAll(targetDir); err != nil {
-		logger.Warnf("Failed to clean up target directory: %v", err)
+	if len(os.Args) > 1 && os.Args[1] == "convert" {
+		if err := cli.RunConvertCommand(os.Args[2:]); err != nil {
+			exit(err)
+		}
+		os.Exit(0)
 	}
 
-	logger.Infof("CycloneDX BOM written to %s", outputPath)
-	return nil
-}
-
-func runOSVScanner(sbomPath string, exitOnVuln bool) error {
-	// Mutlak yolu al
-	absSbomPath, err := filepath.Abs(sbomPath)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %v", err)
+	if len(os.Args) > 1 && os.Args[1] == "fix" {
+		if err := cli.RunFixCommand(ctx, os.Args[2:]); err != nil {
+			exit(err)
+		}
+		os.Exit(0)
 	}
 
-	// Dosyanın varlığını kontrol et
-	if _, err := os.Stat(absSbomPath); os.IsNotExist(err) {
-		return fmt.Errorf("SBOM file not found: %s", absSbomPath)
+	if len(os.Args) > 1 && os.Args[1] == "db" {
+		if err := cli.RunDBCommand(os.Args[2:]); err != nil {
+			exit(err)
+		}
+		os.Exit(0)
 	}
 
-	outputPath := strings.TrimSuffix(sbomPath, filepath.Ext(sbomPath)) + "-vulnerabilities.json"
-	absOutputPath, err := filepath.Abs(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %v", err)
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		if err := cli.RunServeCommand(ctx, os.Args[2:]); err != nil {
+			exit(err)
+		}
+		os.Exit(0)
 	}
 
-	outputFile, err := os.Create(absOutputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %v", err)
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		if err := cli.RunVerifyCommand(ctx, os.Args[2:]); err != nil {
+			exit(err)
+		}
+		os.Exit(0)
 	}
-	defer outputFile.Close()
-
-	cmd := exec.Command("osv-scanner",
-		"--sbom", absSbomPath,
-		"--format", "json")
-
-	cmd.Stdout = outputFile
-	cmd.Stderr = os.Stderr
-
-	err = cmd.Run()
 
-	// Vulnerability found (exit status 1)
-	if isExitStatus1(err) {
-		if exitOnVuln {
-			return fmt.Errorf("vulnerabilities found, see details in: %s", outputPath)
+	if len(os.Args) > 1 && os.Args[1] == "history" {
+		if err := cli.RunHistoryCommand(os.Args[2:]); err != nil {
+			exit(err)
 		}
-		logger.Warnf("Vulnerabilities found! Details: %s", outputPath)
-		return nil
+		os.Exit(0)
 	}
 
-	// Other errors
-	if err != nil {
-		return fmt.Errorf("osv-scanner error: %v", err)
+	// "sbom-scanner scan" is the same as running without a subcommand
+	if len(os.Args) > 1 && os.Args[1] == "scan" {
+		os.Args = append(os.Args[:1], os.Args[2:]...)
 	}
+	flag.Parse()
 
-	logger.Infof("Vulnerability report written to %s", outputPath)
-	return nil
-}
-
-// Check for exit status 1
-func isExitStatus1(err error) bool {
-	if exitErr, ok := err.(*exec.ExitError); ok {
-		return exitErr.ExitCode() == 1
+	var ignoreRules []scan.IgnoreRule
+	var policies []scan.PolicyRule
+	var mavenRepos []maven.MavenRepository
+	visited := visitedFlags()
+	if configPath == "" {
+		projectPath := ""
+		if visited["f"] || visited["file"] {
+			projectPath = pomFile
+		}
+		configPath = scanner.FindConfigFile(projectPath)
 	}
-	return false
-}
-
-// checkDependencies checks if required tools are installed
-func checkDependencies() error {
-	// Check Maven
-	if _, err := exec.LookPath("mvn"); err != nil {
-		logger.Warn("Maven is not installed")
-		
-		// Install Maven based on OS
-		var cmd *exec.Cmd
-		switch runtime.GOOS {
-		case "darwin":
-			logger.Info("Installing Maven via Homebrew...")
-			cmd = exec.Command("brew", "install", "maven")
-		case "linux":
-			logger.Info("Installing Maven via package manager...")
-			// Try apt-get first (Debian/Ubuntu)
-			if _, err := exec.LookPath("apt-get"); err == nil {
-				cmd = exec.Command("sudo", "apt-get", "install", "-y", "maven")
-			} else if _, err := exec.LookPath("yum"); err == nil {
-				// Try yum (RHEL/CentOS)
-				cmd = exec.Command("sudo", "yum", "install", "-y", "maven")
-			} else {
-				return fmt.Errorf("no supported package manager found")
-			}
-		default:
-			return fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+	if configPath != "" {
+		config, err := scanner.LoadConfig(configPath)
+		if err != nil {
+			logger.Fatal(err)
 		}
-
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to install Maven: %v", err)
+		overrideString(visited, &pomFile, config.File, "f", "file")
+		overrideBool(visited, &k8s, config.Kubernetes, "k8s")
+		overrideString(visited, &helmValues, strings.Join(config.HelmValues, ","), "helm-values")
+		overrideString(visited, &registry, config.Registry, "registry")
+		overrideString(visited, &tags, strings.Join(config.Tags, ","), "tags")
+		overrideString(visited, &exclTags, strings.Join(config.ExcludeTags, ","), "exclude-tags")
+		overrideString(visited, &outputDir, config.Output, "o", "output")
+		overrideBool(visited, &clean, config.Clean, "clean")
+		overrideInt(visited, &keepLast, config.KeepLast, "keep-last")
+		overrideString(visited, &resolver, config.Resolver, "r", "resolver")
+		overrideString(visited, &scanners, config.Scanner, "s", "scanner")
+		overrideString(visited, &reports, strings.Join(config.Reports, ","), "report")
+		overrideString(visited, &graph, strings.Join(config.Graph, ","), "graph")
+		overrideString(visited, &scopes, strings.Join(config.Scopes, ","), "scopes")
+		overrideString(visited, &failOn, config.FailOn, "fail-on")
+		overrideBool(visited, &failOnUnk, config.FailOnUnknown, "fail-on-unknown")
+		overrideString(visited, &denylist, strings.Join(config.FailOnLicense, ","), "fail-on-license")
+		overrideBool(visited, &exitOnVuln, config.ExitOnVuln, "e", "exit-on-vuln")
+		overrideString(visited, &trivyCache, config.TrivyCache, "trivy-cache-dir")
+		overrideBool(visited, &offline, config.Offline, "offline")
+		overrideString(visited, &dbDir, config.DBDir, "db-dir")
+		overrideString(visited, &cacheTTL, config.CacheTTL, "cache-ttl")
+		overrideBool(visited, &noCache, config.NoCache, "no-cache")
+		overrideString(visited, &repoLocal, config.MavenRepoLocal, "maven-repo-local")
+		overrideBool(visited, &mvnOffline, config.MavenOffline, "maven-offline")
+		overrideString(visited, &settings, config.MavenSettings, "maven-settings")
+		overrideString(visited, &profiles, strings.Join(config.MavenProfiles, ","), "maven-profiles")
+		overrideString(visited, &cdxVersion, config.CycloneDX, "cyclonedx-plugin-version")
+		overrideString(visited, &workspace, config.Workspace, "workspace")
+		overrideBool(visited, &noDepsTree, config.NoDepsTree, "no-deps-tree")
+		overrideBool(visited, &noEffPom, config.NoEffectivePOM, "no-effective-pom")
+		overrideBool(visited, &withPlugin, config.IncludePlugins, "include-plugins")
+		overrideBool(visited, &keepTemp, config.KeepTemp, "keep-temp")
+		overrideString(visited, &workdir, config.Workdir, "workdir")
+		overrideString(visited, &workdirMax, config.WorkdirMaxSize, "workdir-max-size")
+		overrideInt(visited, &parallel, config.Parallelism, "parallelism")
+		overrideString(visited, &timeout, config.Timeout, "timeout")
+		if config.Retries != nil && !visited["retries"] {
+			retries = *config.Retries
 		}
-		logger.Info("Maven installed successfully")
-	} else {
-		logger.Info("Maven is already installed")
-	}
-
-	// Check OSV Scanner
-	if _, err := exec.LookPath("osv-scanner"); err != nil {
-		logger.Warn("OSV Scanner is not installed")
-		
-		// Install OSV Scanner using go install
-		logger.Info("Installing OSV Scanner...")
-		cmd := exec.Command("go", "install", "github.com/google/osv-scanner/cmd/osv-scanner@latest")
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to install OSV Scanner: %v", err)
+		overrideString(visited, &backoff, config.RetryBackoff, "retry-backoff")
+		overrideString(visited, &stageLimit, config.StageTimeout, "stage-timeout")
+		overrideString(visited, &webhookURL, config.WebhookURL, "webhook-url")
+		overrideBool(visited, &sign, config.Sign, "sign")
+		overrideString(visited, &signKey, config.SignKey, "sign-key")
+		overrideString(visited, &attest, config.Attest, "attest")
+		overrideString(visited, &subjects, strings.Join(config.AttestSubjects, ","), "attest-subject")
+		overrideString(visited, &otelURL, config.OTelEndpoint, "otel-endpoint")
+		overrideBool(visited, &timings, config.Timings, "timings")
+		overrideString(visited, &commentPR, config.CommentPR, "comment-pr")
+		overrideString(visited, &historyDB, config.HistoryDB, "history-db")
+		overrideBool(visited, &noHistory, config.NoHistory, "no-history")
+		overrideString(visited, &baseline, config.Baseline, "baseline")
+		overrideBool(visited, &ghsa, config.GHSA, "ghsa")
+		overrideBool(visited, &nvd, config.NVD, "nvd")
+		overrideBool(visited, &exploits, config.Exploits, "exploits")
+		overrideBool(visited, &reachable, config.Reachability, "reachability")
+		overrideBool(visited, &maintain, config.Maintenance, "maintenance")
+		overrideString(visited, &staleAfter, config.StaleAfter, "stale-after")
+		overrideBool(visited, &supply, config.SupplyChain, "supply-chain")
+		overrideString(visited, &internal, strings.Join(config.InternalNamespaces, ","), "internal-namespaces")
+		overrideBool(visited, &secrets, config.Secrets, "secrets")
+		overrideString(visited, &failSecret, config.FailOnSecret, "fail-on-secret")
+		overrideBool(visited, &quiet, config.Quiet, "q", "quiet")
+		overrideString(visited, &outFormat, config.OutputFormat, "output-format")
+		overrideString(visited, &dojo.URL, config.DefectDojo.URL, "defectdojo-url")
+		overrideString(visited, &dojo.Product, config.DefectDojo.Product, "defectdojo-product")
+		overrideString(visited, &dojo.Engagement, config.DefectDojo.Engagement, "defectdojo-engagement")
+		overrideBool(visited, &dojo.Dedup, config.DefectDojo.Dedup, "defectdojo-dedup")
+		overrideBool(visited, &dojo.CloseOld, config.DefectDojo.CloseOld, "defectdojo-close-old")
+		email = config.Email
+		tools = config.Tools
+		if !visited["mvn-args"] {
+			mvnArgList = config.MavenArgs
+		}
+		ignoreRules = append(ignoreRules, config.Ignore...)
+		policies = append(policies, config.Policies...)
+		if !visited["maven-repository"] && !visited["maven-mirror"] {
+			mavenRepos = config.MavenRepositories
+		}
+		if !visited["vex"] {
+			vexPaths = strings.Join(config.VEX, ",")
 		}
-		logger.Info("OSV Scanner installed successfully")
-	} else {
-		logger.Info("OSV Scanner is already installed")
 	}
-
-	return nil
-}
-
-type Task struct {
-	name     string
-	action   func() error
-	progress int
-}
-
-func main() {
-	var (
-		pomFile    string
-		outputDir  string
-		exitOnVuln bool
-		showHelp   bool
-		check      bool
-	)
-
-	flag.StringVar(&pomFile, "f", "data/pom.xml", "Path to POM file")
-	flag.StringVar(&outputDir, "o", "scan-results", "Output directory")
-	flag.BoolVar(&exitOnVuln, "e", false, "Exit when vulnerabilities are found")
-	flag.BoolVar(&showHelp, "h", false, "Show help message")
-	flag.BoolVar(&check, "c", false, "Check and install required dependencies")
-
-	flag.StringVar(&pomFile, "file", "data/pom.xml", "Path to POM file")
-	flag.StringVar(&outputDir, "output", "scan-results", "Output directory")
-	flag.BoolVar(&exitOnVuln, "exit-on-vuln", false, "Exit when vulnerabilities are found")
-	flag.BoolVar(&showHelp, "help", false, "Show help message")
-	flag.BoolVar(&check, "check", false, "Check and install required dependencies")
-
-	flag.Usage = func() {
-		fmt.Fprint(os.Stderr, helpText)
+	if mvnPath != "" {
+		tools.Maven = mvnPath
+	}
+	if mvnArgList == nil {
+		mvnArgList = strings.Fields(mvnArgs)
+	}
+	if mvnMirror != "" {
+		mavenRepos = append(mavenRepos, maven.MavenRepository{URL: mvnMirror, Mirror: true})
+	}
+	for _, url := range splitList(mvnRepos) {
+		mavenRepos = append(mavenRepos, maven.MavenRepository{URL: url})
 	}
 
-	flag.Parse()
+	// Only the summary goes to stdout in quiet and json mode
+	if outFormat != "text" && outFormat != "json" {
+		logger.Fatalf("Invalid --output-format: %s (expected text or json)", outFormat)
+	}
+	summaryOnly := quiet || outFormat == "json"
+	if summaryOnly {
+		logger.SetOutput(os.Stderr)
+	}
+	if quiet {
+		logger.SetLevel(logrus.WarnLevel)
+	}
+	if configPath != "" {
+		logger.Infof("Using config file %s", configPath)
+	}
 
 	if showHelp {
 		flag.Usage()
@@ -328,126 +760,255 @@ func main() {
 
 	// Run dependency check if requested
 	if check {
-		if err := checkDependencies(); err != nil {
-			logger.Fatalf("Dependency check failed: %v", err)
+		report, err := scanner.CheckDependencies()
+		if summaryOnly {
+			if err := scanner.WriteDoctorReport(os.Stdout, report, outFormat); err != nil {
+				logger.Error(err)
+			}
+		}
+		if err != nil {
+			logger.Errorf("Dependency check failed: %v", err)
+			os.Exit(scanner.ExitMissingTool)
 		}
 		logger.Info("All required dependencies are installed")
 		os.Exit(0)
 	}
 
-	if _, err := os.Stat(pomFile); os.IsNotExist(err) {
-		logger.Fatalf("POM file not found: %s", pomFile)
-	}
-
-	// Önce çıktı dizinini oluştur
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		logger.Fatalf("Failed to create directory: %v", err)
-	}
-
-	// Temizlik: Eğer klasör varsa içeriğini temizle
-	if err := cleanDirectory(outputDir); err != nil {
-		logger.Fatalf("Failed to clean directory: %v", err)
-	}
-
-	dstPomPath := filepath.Join(outputDir, "pom.xml")
-	depsPath := filepath.Join(outputDir, "deps-tree.txt")
-	effectivePomPath := filepath.Join(outputDir, "effective-pom.xml")
-	sbomPath := filepath.Join(outputDir, "sbom.xml")
-
-	// Önce POM dosyasını kopyala
-	if err := copyFile(pomFile, dstPomPath); err != nil {
-		logger.Fatalf("Failed to copy POM file: %v", err)
-	}
-	logger.Info("Copying POM File")
-
-	tasks := []Task{
-		{
-			name: "Analyzing Dependencies",
-			action: func() error {
-				return runMavenCommand(dstPomPath, depsPath)
-			},
-			progress: 20,
-		},
-		{
-			name: "Generating Effective POM",
-			action: func() error {
-				return getEffectivePom(dstPomPath, effectivePomPath)
-			},
-			progress: 20,
-		},
-		{
-			name: "Generating CycloneDX SBOM",
-			action: func() error {
-				return generateCycloneDX(dstPomPath, sbomPath)
-			},
-			progress: 30,
-		},
-		{
-			name: "Scanning for Vulnerabilities",
-			action: func() error {
-				return runOSVScanner(sbomPath, exitOnVuln)
-			},
-			progress: 30,
-		},
-	}
-
-	// Create progress bar with clear line option
-	bar := progressbar.NewOptions(100,
-		progressbar.OptionEnableColorCodes(true),
-		progressbar.OptionShowBytes(false),
-		progressbar.OptionSetWidth(30),
-		progressbar.OptionSetDescription("[cyan]Running SBOM Scan[reset]"),
-		progressbar.OptionSetTheme(progressbar.Theme{
-			Saucer:        "[green]=[reset]",
-			SaucerHead:    "[green]>[reset]",
-			SaucerPadding: " ",
-			BarStart:      "[",
-			BarEnd:        "]",
-		}),
-		progressbar.OptionClearOnFinish(),
-		progressbar.OptionSetPredictTime(false),
-		progressbar.OptionShowCount(),
-		progressbar.OptionFullWidth(),
-		progressbar.OptionSpinnerType(14))
+	if parallel < 1 {
+		logger.Fatalf("Invalid --parallelism: %d (expected at least 1)", parallel)
+	}
+	ttl, err := time.ParseDuration(cacheTTL)
+	if err != nil || ttl < 0 {
+		logger.Fatalf("Invalid --cache-ttl: %s (expected a duration such as 6h or 30m)", cacheTTL)
+	}
+	if ttl == 0 || noCache {
+		ttl = -1
+	}
+	if retries < 0 {
+		logger.Fatalf("Invalid --retries: %d (expected 0 or more)", retries)
+	}
+	retryBackoff, err := time.ParseDuration(backoff)
+	if err != nil || retryBackoff <= 0 {
+		logger.Fatalf("Invalid --retry-backoff: %s (expected a duration such as 2s)", backoff)
+	}
+	scanTimeout, err := time.ParseDuration(timeout)
+	if err != nil || scanTimeout < 0 {
+		logger.Fatalf("Invalid --timeout: %s (expected a duration such as 30m)", timeout)
+	}
+	stageTimeout, err := time.ParseDuration(stageLimit)
+	if err != nil || stageTimeout < 0 {
+		logger.Fatalf("Invalid --stage-timeout: %s (expected a duration such as 10m)", stageLimit)
+	}
+
+	// With --git, -f is a path inside the repository; --registry needs no -f
+	if gitURL != "" {
+		if !visited["f"] && !visited["file"] {
+			pomFile = ""
+		}
+	} else if gitRef != "" {
+		logger.Fatal("--ref needs --git")
+	} else if registry != "" {
+		pomFile = ""
+	} else if _, err := os.Stat(pomFile); os.IsNotExist(err) {
+		logger.Fatalf("Project file not found: %s", pomFile)
+	}
 
 	startTime := time.Now()
-	completedProgress := 0
-	
-	// İlk görev için progress bar'ı güncelle
-	bar.Set(10)
-
-	for _, task := range tasks {
-		logger.Info(task.name)
-		if err := task.action(); err != nil {
-			fmt.Println() // Add newline before error
-			logger.Fatalf("%s error: %v", task.name, err)
+
+	options := scanner.Options{
+		Target:             pomFile,
+		GitURL:             gitURL,
+		Kubernetes:         k8s,
+		HelmValues:         splitList(helmValues),
+		Registry:           registry,
+		Tags:               splitList(tags),
+		ExcludeTags:        splitList(exclTags),
+		GitRef:             gitRef,
+		Since:              since,
+		OutputDir:          outputDir,
+		Clean:              clean,
+		KeepLast:           keepLast,
+		Resolver:           resolver,
+		Scanners:           splitList(scanners),
+		Scopes:             splitList(scopes),
+		Reports:            splitList(reports),
+		Graphs:             splitList(graph),
+		ExitOnVuln:         exitOnVuln,
+		FailOn:             failOn,
+		FailOnUnknown:      failOnUnk,
+		FailOnLicense:      splitList(denylist),
+		Ignore:             append(ignoreRules, scan.ParseIgnoreFlag(ignore)...),
+		IgnoreFile:         ignorePath,
+		VEX:                splitList(vexPaths),
+		Policies:           policies,
+		PolicyFile:         policyPath,
+		Baseline:           baseline,
+		GHSA:               ghsa,
+		NVD:                nvd,
+		Exploits:           exploits,
+		Reachability:       reachable,
+		Maintenance:        maintain,
+		StaleAfter:         staleAfter,
+		SupplyChain:        supply,
+		InternalNamespaces: splitList(internal),
+		Secrets:            secrets,
+		FailOnSecret:       failSecret,
+		HistoryDB:          historyDB,
+		NoHistory:          noHistory,
+		WebhookURL:         webhookURL,
+		Sign:               sign,
+		SignKey:            signKey,
+		Attest:             attest,
+		AttestSubjects:     splitList(subjects),
+		OTLPEndpoint:       otelURL,
+		Timings:            timings,
+		CommentPR:          commentPR,
+		DefectDojo:         dojo,
+		Email:              email,
+		Tools:              tools,
+		Offline:            offline,
+		DBDir:              dbDir,
+		TrivyCacheDir:      trivyCache,
+		CacheTTL:           ttl,
+		MavenRepoLocal:     repoLocal,
+		MavenOffline:       mvnOffline,
+		MavenSettings:      settings,
+		MavenProfiles:      splitList(profiles),
+		MavenRepositories:  mavenRepos,
+		MavenArgs:          mvnArgList,
+		Workspace:          workspace,
+		NoDepsTree:         noDepsTree,
+		NoEffectivePOM:     noEffPom,
+		IncludePlugins:     withPlugin,
+		KeepTemp:           keepTemp,
+		Workdir:            workdir,
+		WorkdirMaxSize:     workdirMax,
+		Parallelism:        parallel,
+		Timeout:            scanTimeout,
+		StageTimeout:       stageTimeout,
+		Retries:            &retries,
+		RetryBackoff:       retryBackoff,
+		Quiet:              summaryOnly,
+
+		CycloneDXPluginVersion: cdxVersion,
+	}
+
+	// The profile is written before any exit
+	stopProfile := startProfile(profile)
+	if watch {
+		err := scanner.Watch(ctx, options, os.Stdout, outFormat)
+		stopProfile()
+		if err != nil {
+			exit(err)
 		}
-		completedProgress += task.progress
-		bar.Set(completedProgress)
-		time.Sleep(100 * time.Millisecond)
+		return
 	}
 
-	// Clear the progress bar and show completion time
-	bar.Clear()
+	result, err := scanner.Run(ctx, options)
+	stopProfile()
+	if errors.Is(err, context.Canceled) {
+		logger.Error("Scan interrupted")
+		os.Exit(130)
+	}
+	if summaryOnly {
+		if result != nil {
+			if err := scanner.WriteSummary(os.Stdout, result, outFormat); err != nil {
+				logger.Error(err)
+			}
+		}
+		if err != nil {
+			exit(err)
+		}
+		return
+	}
+	if err != nil {
+		exit(err)
+	}
+
+	// Show completion time
 	fmt.Printf("\nCompleted in %s\n", time.Since(startTime).Round(time.Second))
 	logger.Info("Process completed successfully!")
 }
 
-// Klasörü temizleyen yardımcı fonksiyon
-func cleanDirectory(dir string) error {
-	// Klasör içeriğini oku
-	entries, err := os.ReadDir(dir)
+// startProfile starts writing a CPU profile to path and returns the function
+// that stops it. Without a path nothing is profiled.
+func startProfile(path string) func() {
+	if path == "" {
+		return func() {}
+	}
+	file, err := os.Create(path)
 	if err != nil {
-		return err
+		logger.Fatalf("Failed to create profile: %v", err)
+	}
+	if err := pprof.StartCPUProfile(file); err != nil {
+		logger.Fatalf("Failed to start profile: %v", err)
 	}
+	return func() {
+		pprof.StopCPUProfile()
+		if err := file.Close(); err != nil {
+			logger.Errorf("Failed to write profile: %v", err)
+			return
+		}
+		logger.Infof("CPU profile written to %s", path)
+	}
+}
 
-	// Her bir öğeyi sil
-	for _, entry := range entries {
-		path := filepath.Join(dir, entry.Name())
-		if err := os.RemoveAll(path); err != nil {
-			return err
+// exit logs the error of a scan or subcommand and exits with its exit code
+func exit(err error) {
+	logger.Error(err)
+	os.Exit(scanner.ExitCode(err))
+}
+
+// splitList splits a comma-separated flag value
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
 	}
+	return items
+}
 
-	return nil
+// visitedFlags returns the names of the flags set on the command line
+func visitedFlags() map[string]bool {
+	visited := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		visited[f.Name] = true
+	})
+	return visited
+}
+
+// overrideString sets target to the config value unless one of the flag names
+// was given on the command line
+func overrideString(visited map[string]bool, target *string, value string, names ...string) {
+	if value == "" || anyVisited(visited, names) {
+		return
+	}
+	*target = value
+}
+
+// overrideBool is overrideString for boolean flags
+func overrideBool(visited map[string]bool, target *bool, value bool, names ...string) {
+	if !value || anyVisited(visited, names) {
+		return
+	}
+	*target = value
+}
+
+// overrideInt is overrideString for integer flags
+func overrideInt(visited map[string]bool, target *int, value int, names ...string) {
+	if value == 0 || anyVisited(visited, names) {
+		return
+	}
+	*target = value
+}
+
+func anyVisited(visited map[string]bool, names []string) bool {
+	for _, name := range names {
+		if visited[name] {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,225 @@
+package maven
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+// Nested archives larger than this are skipped instead of being read into memory
+const maxNestedArchiveSize = 256 << 20
+
+// Matches file names like commons-lang3-3.12.0.jar
+var archiveNamePattern = regexp.MustCompile(`^(.+?)-(\d[\w.\-]*)\.[jwe]ar$`)
+
+// ScanArchive collects the components of a JAR/WAR/EAR, including shaded
+// dependencies (pom.properties) and nested archives such as WEB-INF/lib/*.jar
+func ScanArchive(archivePath string) ([]sbom.Component, error) {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %v", err)
+	}
+	defer reader.Close()
+
+	return scanZip(&reader.Reader, path.Base(archivePath), 0)
+}
+
+func scanZip(reader *zip.Reader, name string, depth int) ([]sbom.Component, error) {
+	var components []sbom.Component
+	var manifest map[string]string
+
+	for _, file := range reader.File {
+		switch {
+		case strings.HasPrefix(file.Name, "META-INF/maven/") && strings.HasSuffix(file.Name, "/pom.properties"):
+			props, err := readZipProperties(file)
+			if err != nil {
+				runenv.Logger.Warnf("Failed to read %s in %s: %v", file.Name, name, err)
+				continue
+			}
+			if props["artifactId"] != "" && props["version"] != "" {
+				components = append(components, sbom.Component{
+					Type:      "maven",
+					Namespace: props["groupId"],
+					Name:      props["artifactId"],
+					Version:   props["version"],
+					Licenses:  readZipPOMLicenses(reader, strings.TrimSuffix(file.Name, "pom.properties")+"pom.xml"),
+				})
+			}
+
+		case file.Name == "META-INF/MANIFEST.MF":
+			props, err := readZipManifest(file)
+			if err != nil {
+				runenv.Logger.Warnf("Failed to read manifest in %s: %v", name, err)
+				continue
+			}
+			manifest = props
+
+		case sbom.IsArchive(file.Name) && depth < 4:
+			if file.UncompressedSize64 > maxNestedArchiveSize {
+				runenv.Logger.Warnf("Skipping nested archive %s in %s: too large", file.Name, name)
+				continue
+			}
+			nested, err := openNestedZip(file)
+			if err != nil {
+				runenv.Logger.Warnf("Failed to open nested archive %s in %s: %v", file.Name, name, err)
+				continue
+			}
+			nestedComponents, err := scanZip(nested, path.Base(file.Name), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			components = append(components, nestedComponents...)
+		}
+	}
+
+	// Archives without Maven metadata are identified by their manifest or file name
+	if !hasMavenMetadata(reader) {
+		if c, ok := componentFromManifest(manifest, name); ok {
+			components = append(components, c)
+		}
+	}
+
+	return components, nil
+}
+
+func hasMavenMetadata(reader *zip.Reader) bool {
+	for _, file := range reader.File {
+		if strings.HasPrefix(file.Name, "META-INF/maven/") && strings.HasSuffix(file.Name, "/pom.properties") {
+			return true
+		}
+	}
+	return false
+}
+
+// componentFromManifest falls back to MANIFEST.MF attributes and the file name
+func componentFromManifest(manifest map[string]string, name string) (sbom.Component, bool) {
+	c := sbom.Component{Type: "maven"}
+
+	if m := archiveNamePattern.FindStringSubmatch(name); m != nil {
+		c.Name, c.Version = m[1], m[2]
+	}
+
+	if v := manifest["Implementation-Version"]; v != "" {
+		c.Version = v
+	} else if v := manifest["Bundle-Version"]; v != "" && c.Version == "" {
+		c.Version = v
+	}
+
+	// OSGi bundles usually use groupId.artifactId as symbolic name
+	if symbolic := strings.Split(manifest["Bundle-SymbolicName"], ";")[0]; symbolic != "" && c.Name != "" {
+		if strings.HasSuffix(symbolic, "."+c.Name) {
+			c.Namespace = strings.TrimSuffix(symbolic, "."+c.Name)
+		}
+	}
+	if c.Namespace == "" && manifest["Implementation-Vendor-Id"] != "" {
+		c.Namespace = manifest["Implementation-Vendor-Id"]
+	}
+
+	if c.Name == "" {
+		c.Name = manifest["Implementation-Title"]
+	}
+
+	return c, c.Name != "" && c.Version != ""
+}
+
+func openNestedZip(file *zip.File) (*zip.Reader, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxNestedArchiveSize))
+	if err != nil {
+		return nil, err
+	}
+	return zip.NewReader(bytes.NewReader(data), int64(len(data)))
+}
+
+// readZipProperties parses a Java .properties file
+func readZipProperties(file *zip.File) (map[string]string, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	props := make(map[string]string)
+	scanner := bufio.NewScanner(rc)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			props[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return props, scanner.Err()
+}
+
+// readZipPOMLicenses returns the licenses declared in the POM packaged next to
+// pom.properties, licenses inherited from a parent POM are not resolved
+func readZipPOMLicenses(reader *zip.Reader, name string) []string {
+	rc, err := reader.Open(name)
+	if err != nil {
+		return nil
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil
+	}
+	project, err := ParsePOM(data)
+	if err != nil {
+		return nil
+	}
+	return project.licenseNames()
+}
+
+// readZipManifest parses MANIFEST.MF, joining continuation lines
+func readZipManifest(file *zip.File) (map[string]string, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	props := make(map[string]string)
+	var lastKey string
+
+	scanner := bufio.NewScanner(rc)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(line, " ") && lastKey != "" {
+			props[lastKey] += line[1:]
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			lastKey = strings.TrimSpace(key)
+			props[lastKey] = strings.TrimSpace(value)
+		}
+	}
+	return props, scanner.Err()
+}
+
+// GenerateArtifactSBOM builds the SBOM from the contents of a built artifact
+func GenerateArtifactSBOM(archivePath, outputPath string) error {
+	components, err := ScanArchive(archivePath)
+	if err != nil {
+		return err
+	}
+	runenv.Logger.Infof("Found %d components in %s", len(sbom.UniqueComponents(components)), path.Base(archivePath))
+
+	return sbom.WriteCycloneDX(outputPath, components)
+}
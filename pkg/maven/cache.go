@@ -0,0 +1,67 @@
+package maven
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+// CacheKey hashes the POM together with the settings that change what
+// Maven resolves
+func CacheKey(ctx context.Context, pomPath string, scopes []string) (string, error) {
+	data, err := os.ReadFile(pomPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read POM: %v", err)
+	}
+
+	s := runenv.SettingsOf(ctx)
+	h := sha256.New()
+	fmt.Fprintf(h, "scopes=%s\nrepo=%s\nsettings=%s\nprofiles=%s\nargs=%s\ncyclonedx=%s\n", strings.Join(scopes, ","), s.MavenRepoLocal, s.MavenSettings,
+		strings.Join(s.MavenProfiles, ","), strings.Join(s.MavenExtraArgs, " "), CycloneDXPluginVersion(s))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// RestoreResolution copies the outputs of an earlier run with the same
+// POM into outputDir: the SBOM and, unless skipped, the dependency tree and
+// effective POM
+func RestoreResolution(ctx context.Context, key, outputDir string, files []string) bool {
+	s := runenv.SettingsOf(ctx)
+	entry := filepath.Join(s.CacheDir("maven"), key)
+	age, fresh := s.CacheEntryFresh(filepath.Join(entry, "sbom.xml"))
+	if !fresh {
+		return false
+	}
+	for _, name := range files {
+		if _, err := os.Stat(filepath.Join(entry, name)); os.IsNotExist(err) {
+			// Cached by a run that skipped it
+			return false
+		}
+		if err := runenv.CopyFile(filepath.Join(entry, name), filepath.Join(outputDir, name)); err != nil {
+			runenv.Logger.Warnf("Ignoring cached Maven resolution: %v", err)
+			return false
+		}
+	}
+
+	runenv.Logger.Infof("Reusing the Maven dependency resolution of %s ago, the POM is unchanged", age.Round(time.Second))
+	return true
+}
+
+// StoreResolution caches the outputs of the Maven steps
+func StoreResolution(ctx context.Context, key, outputDir string, files []string) error {
+	entry := filepath.Join(runenv.SettingsOf(ctx).CacheDir("maven"), key)
+	// The SBOM comes last, its modification time marks a complete entry
+	for _, name := range files {
+		if err := runenv.CopyFile(filepath.Join(outputDir, name), filepath.Join(entry, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package maven
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+// ExcludedDependency is a transitive dependency that an <exclusions> entry
+// keeps off the classpath, so it is neither in the SBOM nor scanned
+type ExcludedDependency struct {
+	Package         string   `json:"package"` // group:artifact
+	Version         string   `json:"version,omitempty"`
+	PURL            string   `json:"purl,omitempty"`
+	ExcludedBy      string   `json:"excluded_by"`               // group:artifact:version of the dependency declaring the exclusion
+	Parent          string   `json:"parent"`                    // group:artifact:version of the dependency whose POM declares it
+	Vulnerabilities []string `json:"vulnerabilities,omitempty"` // OSV IDs of the version that would have been resolved
+}
+
+// dependencyExclusion is an exclusion inherited by the subtree of the
+// dependency that declares it, as in Maven
+type dependencyExclusion struct {
+	pomExclusion
+	by string // group:artifact:version of the declaring dependency
+}
+
+// matches reports whether the exclusion applies to a dependency, * matches
+// any groupId or artifactId
+func (e pomExclusion) matches(d POMDependency) bool {
+	return (e.GroupID == "*" || e.GroupID == d.GroupID) && (e.ArtifactID == "*" || e.ArtifactID == d.ArtifactID)
+}
+
+// Coordinates returns group:artifact:version of a dependency
+func (d POMDependency) Coordinates() string {
+	return d.GroupID + ":" + d.ArtifactID + ":" + d.Version
+}
+
+// InheritExclusions returns the exclusions of a dependency followed by the
+// ones of its ancestors
+func InheritExclusions(d POMDependency, inherited []dependencyExclusion) []dependencyExclusion {
+	if len(d.Exclusions) == 0 {
+		return inherited
+	}
+	exclusions := make([]dependencyExclusion, 0, len(d.Exclusions)+len(inherited))
+	for _, e := range d.Exclusions {
+		exclusions = append(exclusions, dependencyExclusion{pomExclusion: e, by: d.Coordinates()})
+	}
+	return append(exclusions, inherited...)
+}
+
+// ExcludedBy returns the exclusion that keeps a dependency off the classpath
+func ExcludedBy(exclusions []dependencyExclusion, d POMDependency) (dependencyExclusion, bool) {
+	for _, e := range exclusions {
+		if e.matches(d) {
+			return e, true
+		}
+	}
+	return dependencyExclusion{}, false
+}
+
+// NewExcludedDependency returns the record of an excluded dependency
+func NewExcludedDependency(d POMDependency, e dependencyExclusion, parent string) ExcludedDependency {
+	excluded := ExcludedDependency{Package: d.Key(), Version: d.Version, ExcludedBy: e.by, Parent: parent}
+	if d.Version != "" {
+		excluded.PURL = sbom.Component{Type: "maven", Namespace: d.GroupID, Name: d.ArtifactID, Version: d.Version}.PURL()
+	}
+	return excluded
+}
+
+// ExcludedPath returns where the excluded dependencies of an SBOM are written
+func ExcludedPath(sbomPath string) string {
+	return filepath.Join(filepath.Dir(sbomPath), "sbom-excluded.json")
+}
+
+// ExcludedReportFor returns the excluded dependencies that belong to a
+// license report: aggregated-excluded.json for aggregated-licenses.json, else
+// sbom-excluded.json
+func ExcludedReportFor(licensesPath string) string {
+	if filepath.Base(licensesPath) == "aggregated-licenses.json" {
+		return filepath.Join(filepath.Dir(licensesPath), "aggregated-excluded.json")
+	}
+	return filepath.Join(filepath.Dir(licensesPath), "sbom-excluded.json")
+}
+
+func WriteExcludedReport(path string, excluded []ExcludedDependency) error {
+	if excluded == nil {
+		excluded = []ExcludedDependency{}
+	}
+	sort.SliceStable(excluded, func(i, j int) bool {
+		if excluded[i].Package != excluded[j].Package {
+			return excluded[i].Package < excluded[j].Package
+		}
+		return excluded[i].Version < excluded[j].Version
+	})
+	data, err := json.MarshalIndent(excluded, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode excluded dependencies: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write excluded dependencies: %v", err)
+	}
+	return nil
+}
+
+func ReadExcludedReport(path string) ([]ExcludedDependency, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read excluded dependencies: %v", err)
+	}
+	var excluded []ExcludedDependency
+	if err := json.Unmarshal(data, &excluded); err != nil {
+		return nil, fmt.Errorf("failed to parse excluded dependencies %s: %v", path, err)
+	}
+	return excluded, nil
+}
@@ -0,0 +1,292 @@
+package maven
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+// SBOM metadata properties of the Java version the module is compiled for
+// and the frameworks it is built on
+const (
+	propertyJavaVersion = "sbom-scanner:java:version"
+	propertyFramework   = "sbom-scanner:framework"
+)
+
+// javaFrameworks are the frameworks detected in the effective POM. A
+// framework is used when the module depends on an artifact of its group or
+// inherits from one of its parents; its version is the one of the parent,
+// else the managed version of the core artifact, which is added to the SBOM.
+var javaFrameworks = []struct {
+	name    string
+	groupID string
+	core    string // group:artifact of the component standing for the framework
+	parents []string
+}{
+	{"Spring Boot", "org.springframework.boot", "org.springframework.boot:spring-boot",
+		[]string{"org.springframework.boot:spring-boot-starter-parent", "org.springframework.boot:spring-boot-dependencies"}},
+	{"Quarkus", "io.quarkus", "io.quarkus:quarkus-core", nil},
+	{"Jakarta EE", "jakarta.platform", "jakarta.platform:jakarta.jakartaee-api", nil},
+}
+
+// javaFramework is a framework a module is built on
+type javaFramework struct {
+	name       string
+	groupID    string
+	artifactID string
+	version    string
+}
+
+// javaVersion returns the Java version a module is compiled for, from the
+// configuration of maven-compiler-plugin or the properties it defaults to,
+// e.g. 17 or 8 for 1.8. It is empty when the POM does not set one.
+func (p *POMProject) javaVersion() string {
+	var configuration pomPluginConfiguration
+	for _, plugins := range [][]pomPlugin{p.Build.Plugins, p.Build.PluginManagement.Plugins} {
+		for _, plugin := range plugins {
+			if plugin.key() == "org.apache.maven.plugins:maven-compiler-plugin" && configuration == (pomPluginConfiguration{}) {
+				configuration = plugin.Configuration
+			}
+		}
+	}
+
+	// release takes precedence over target in maven-compiler-plugin,
+	// java.version is the property of Spring Boot's parent
+	candidates := []string{
+		configuration.Release, p.Properties["maven.compiler.release"],
+		configuration.Target, p.Properties["maven.compiler.target"],
+		configuration.Source, p.Properties["maven.compiler.source"],
+		p.Properties["java.version"],
+	}
+	for _, version := range candidates {
+		version = strings.TrimSpace(version)
+		if version == "" || strings.Contains(version, "${") {
+			continue
+		}
+		return strings.TrimPrefix(version, "1.")
+	}
+	return ""
+}
+
+// frameworks returns the frameworks a module is built on
+func (p *POMProject) frameworks() []javaFramework {
+	managed := make(map[string]string)
+	for _, d := range p.DependencyManagement.Dependencies {
+		if _, ok := managed[d.Key()]; !ok && d.Version != "" {
+			managed[d.Key()] = d.Version
+		}
+	}
+	deps := ApplyManagement(p.Dependencies, p.Managed())
+
+	var found []javaFramework
+	for _, f := range javaFrameworks {
+		groupID, artifactID, _ := strings.Cut(f.core, ":")
+		framework := javaFramework{name: f.name, groupID: groupID, artifactID: artifactID}
+		used := false
+		if p.Parent != nil {
+			for _, parent := range f.parents {
+				if p.Parent.GroupID+":"+p.Parent.ArtifactID == parent {
+					used = true
+					framework.version = p.Parent.Version
+				}
+			}
+		}
+		var declared string
+		for _, d := range deps {
+			if d.GroupID == f.groupID {
+				used = true
+				if declared == "" {
+					declared = d.Version
+				}
+			}
+		}
+		if !used {
+			continue
+		}
+		if framework.version == "" {
+			framework.version = managed[f.core]
+		}
+		if framework.version == "" {
+			framework.version = declared
+		}
+		if framework.version == "" || strings.Contains(framework.version, "${") {
+			runenv.Logger.Warnf("%s is used but its version is unknown", f.name)
+			continue
+		}
+		found = append(found, framework)
+	}
+	return found
+}
+
+// AddRuntimeMetadata records the Java version and the frameworks of a Maven
+// module, detected in its effective POM, as properties of the SBOM metadata.
+// When the parents cannot be resolved, the POM of the module is read alone.
+// The core artifacts of the frameworks are added as components of type
+// framework unless the SBOM has them already, so advisories for the framework
+// match even when only some of its artifacts are dependencies.
+func AddRuntimeMetadata(ctx context.Context, pomPath, effectivePomPath, sbomPath string) error {
+	s := runenv.SettingsOf(ctx)
+	resolver := NewPOMResolver(ctx)
+	resolver.retry = true
+	resolver.profiles = s.MavenProfiles
+	project, err := resolver.loadEffectivePOM(pomPath, effectivePomPath)
+	if err != nil {
+		// The parent and the properties of the module usually tell enough
+		data, readErr := os.ReadFile(pomPath)
+		if readErr != nil {
+			return fmt.Errorf("failed to read POM: %v", readErr)
+		}
+		if project, readErr = ParsePOM(data); readErr != nil {
+			return fmt.Errorf("failed to parse %s: %v", pomPath, readErr)
+		}
+		runenv.Logger.Warnf("Detecting the Java version and frameworks from %s only: %v", pomPath, err)
+		project = interpolatePOM(project.withProfiles(s.MavenProfiles))
+	}
+
+	var properties []sbom.CDXProperty
+	var names []string
+	if version := project.javaVersion(); version != "" {
+		properties = append(properties, sbom.CDXProperty{Name: propertyJavaVersion, Value: version})
+		names = append(names, "Java "+version)
+	}
+	frameworks := project.frameworks()
+	for _, f := range frameworks {
+		properties = append(properties, sbom.CDXProperty{Name: propertyFramework, Value: f.name + " " + f.version})
+		names = append(names, f.name+" "+f.version)
+	}
+	if len(properties) == 0 {
+		return nil
+	}
+
+	existing, err := sbom.ReadCycloneDX(sbomPath)
+	if err != nil {
+		return err
+	}
+	present := make(map[string]bool)
+	for _, c := range existing {
+		purl, _, _ := strings.Cut(c.PURL, "?")
+		present[purl] = true
+	}
+	var components []sbom.CDXComponent
+	for _, f := range frameworks {
+		purl := sbom.Component{Type: "maven", Namespace: f.groupID, Name: f.artifactID, Version: f.version}.PURL()
+		if present[purl] {
+			continue
+		}
+		components = append(components, sbom.CDXComponent{
+			Type:       "framework",
+			BOMRef:     purl,
+			Group:      f.groupID,
+			Name:       f.artifactID,
+			Version:    f.version,
+			PURL:       purl,
+			Properties: []sbom.CDXProperty{{Name: propertyFramework, Value: f.name}},
+		})
+	}
+	if len(components) > 0 {
+		if err := insertComponents(sbomPath, components); err != nil {
+			return err
+		}
+	}
+	if err := insertMetadataProperties(sbomPath, properties); err != nil {
+		return err
+	}
+	runenv.Logger.Infof("Detected %s", strings.Join(names, ", "))
+	return nil
+}
+
+// insertMetadataProperties appends properties to the metadata of a CycloneDX
+// XML document, into its properties element or a new one at the end of the
+// metadata. Documents without metadata are left as they are.
+func insertMetadataProperties(sbomPath string, properties []sbom.CDXProperty) error {
+	data, err := os.ReadFile(sbomPath)
+	if err != nil {
+		return fmt.Errorf("failed to read SBOM: %v", err)
+	}
+
+	// Offsets of the end tags of the metadata and of its properties
+	var metadataEnd, propertiesEnd int64 = -1, -1
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var parents []string
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse SBOM %s: %v", sbomPath, err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			parents = append(parents, t.Name.Local)
+		case xml.EndElement:
+			switch strings.Join(parents, "/") {
+			case "bom/metadata":
+				metadataEnd = offset
+			case "bom/metadata/properties":
+				propertiesEnd = offset
+			}
+			parents = parents[:len(parents)-1]
+		}
+	}
+	if metadataEnd < 0 || bytes.HasSuffix(data[:metadataEnd], []byte("/>")) ||
+		(propertiesEnd >= 0 && bytes.HasSuffix(data[:propertiesEnd], []byte("/>"))) {
+		runenv.Logger.Warn("The SBOM metadata cannot hold the Java version and frameworks")
+		return nil
+	}
+
+	// The properties get the indentation of the end tag they precede, one
+	// level deeper; documents on a single line stay on it
+	lineIndent := func(offset int64) (string, string) {
+		lineStart := bytes.LastIndexByte(data[:offset], '\n') + 1
+		line := data[lineStart:offset]
+		if lineStart == 0 || len(bytes.TrimSpace(line)) > 0 {
+			return "", ""
+		}
+		return string(line), "\n"
+	}
+	element := func(p sbom.CDXProperty) string {
+		var name, value bytes.Buffer
+		xml.EscapeText(&name, []byte(p.Name))
+		xml.EscapeText(&value, []byte(p.Value))
+		return `<property name="` + name.String() + `">` + value.String() + "</property>"
+	}
+
+	var b bytes.Buffer
+	if propertiesEnd >= 0 {
+		indent, newline := lineIndent(propertiesEnd)
+		b.Write(data[:propertiesEnd])
+		for _, p := range properties {
+			if newline != "" {
+				b.WriteString("  ")
+			}
+			b.WriteString(element(p) + newline + indent)
+		}
+		b.Write(data[propertiesEnd:])
+	} else {
+		indent, newline := lineIndent(metadataEnd)
+		step := ""
+		if newline != "" {
+			step = "  "
+		}
+		b.Write(data[:metadataEnd])
+		b.WriteString(step + "<properties>" + newline)
+		for _, p := range properties {
+			b.WriteString(indent + step + step + element(p) + newline)
+		}
+		b.WriteString(indent + step + "</properties>" + newline + indent)
+		b.Write(data[metadataEnd:])
+	}
+	if err := os.WriteFile(sbomPath, b.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write SBOM: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package maven
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+// artifactHashes are the digests of a resolved artifact
+type artifactHashes struct {
+	sha1   string
+	sha256 string
+}
+
+// hashInsertion is a hashes element added to a component of the SBOM
+type hashInsertion struct {
+	offset int64
+	text   string
+}
+
+// AddArtifactHashes adds the SHA-1 and SHA-256 hashes of the resolved JARs to
+// the Maven components of the SBOM that have none. The artifacts are looked up
+// in the Maven local repository and the Gradle cache, nothing is downloaded.
+// The hashes elements are inserted into the document as it is, so the other
+// content written by the CycloneDX plugins is kept.
+func AddArtifactHashes(ctx context.Context, sbomPath string) error {
+	data, err := os.ReadFile(sbomPath)
+	if err != nil {
+		return fmt.Errorf("failed to read SBOM: %v", err)
+	}
+
+	// A component element with the children needed to place its hashes
+	type frame struct {
+		depth  int
+		purl   strings.Builder
+		inPURL bool
+		hashes bool
+		after  int64 // end of the last element hashes follow: name, version, description or scope
+	}
+	var stack []*frame
+	var insertions []hashInsertion
+	hashed, missing := 0, 0
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	depth := 0
+	var parents []string
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse SBOM %s: %v", sbomPath, err)
+		}
+
+		var top *frame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			parent := ""
+			if len(parents) > 0 {
+				parent = parents[len(parents)-1]
+			}
+			parents = append(parents, t.Name.Local)
+			switch {
+			// The metadata component is the project itself, not a resolved artifact
+			case t.Name.Local == "component" && parent != "metadata":
+				stack = append(stack, &frame{depth: depth})
+			case top != nil && depth == top.depth+1:
+				top.hashes = top.hashes || t.Name.Local == "hashes"
+				top.inPURL = t.Name.Local == "purl"
+			}
+		case xml.CharData:
+			if top != nil && top.inPURL {
+				top.purl.Write(t)
+			}
+		case xml.EndElement:
+			switch {
+			case top != nil && depth == top.depth:
+				stack = stack[:len(stack)-1]
+				purl := strings.TrimSpace(top.purl.String())
+				if top.hashes || top.after == 0 || !strings.HasPrefix(purl, "pkg:maven/") {
+					break
+				}
+				paths := ArtifactPaths(ctx, purl)
+				if len(paths) == 0 {
+					break
+				}
+				hashes, ok := resolvedArtifactHashes(paths)
+				if !ok {
+					missing++
+					break
+				}
+				insertions = append(insertions, hashInsertion{offset: top.after, text: hashesElement(data, top.after, hashes)})
+				hashed++
+			case top != nil && depth == top.depth+1:
+				top.inPURL = false
+				switch t.Name.Local {
+				case "name", "version", "description", "scope":
+					top.after = decoder.InputOffset()
+				}
+			}
+			depth--
+			parents = parents[:len(parents)-1]
+		}
+	}
+
+	if missing > 0 {
+		runenv.Logger.Infof("%d artifacts were not found in the local repository, their components have no hashes", missing)
+	}
+	if len(insertions) == 0 {
+		return nil
+	}
+
+	sort.Slice(insertions, func(i, j int) bool { return insertions[i].offset < insertions[j].offset })
+	var b bytes.Buffer
+	var last int64
+	for _, insertion := range insertions {
+		b.Write(data[last:insertion.offset])
+		b.WriteString(insertion.text)
+		last = insertion.offset
+	}
+	b.Write(data[last:])
+	if err := os.WriteFile(sbomPath, b.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write SBOM: %v", err)
+	}
+	runenv.Logger.Infof("Added the hashes of %d artifacts to %s", hashed, sbomPath)
+	return nil
+}
+
+// hashesElement formats the hashes with the indentation of the element they
+// follow
+func hashesElement(data []byte, offset int64, hashes artifactHashes) string {
+	lines := []string{
+		"<hashes>",
+		`  <hash alg="SHA-1">` + hashes.sha1 + "</hash>",
+		`  <hash alg="SHA-256">` + hashes.sha256 + "</hash>",
+		"</hashes>",
+	}
+	lineStart := bytes.LastIndexByte(data[:offset], '\n') + 1
+	if lineStart == 0 {
+		return strings.ReplaceAll(strings.Join(lines, ""), "  ", "")
+	}
+	line := data[lineStart:offset]
+	indent := string(line[:len(line)-len(bytes.TrimLeft(line, " \t"))])
+	return "\n" + indent + strings.Join(lines, "\n"+indent)
+}
+
+// resolvedArtifactHashes hashes the first of the artifact paths that exists
+func resolvedArtifactHashes(paths []string) (artifactHashes, bool) {
+	for _, path := range paths {
+		hashes, err := hashArtifact(path)
+		if err == nil {
+			return hashes, true
+		}
+		if !os.IsNotExist(err) {
+			runenv.Logger.Warnf("Failed to hash %s: %v", path, err)
+		}
+	}
+	return artifactHashes{}, false
+}
+
+// ArtifactPaths returns the files a Maven package URL may be resolved to:
+// group/path/name/version/name-version[-classifier].ext in the local
+// repository, and group/name/version/<sha1>/name-version[-classifier].ext in
+// the Gradle cache
+func ArtifactPaths(ctx context.Context, purl string) []string {
+	purl, _, _ = strings.Cut(purl, "#")
+	rest, qualifiers, _ := strings.Cut(strings.TrimPrefix(purl, "pkg:maven/"), "?")
+	coordinates, version, ok := strings.Cut(rest, "@")
+	if !ok {
+		return nil
+	}
+	slash := strings.LastIndex(coordinates, "/")
+	if slash < 0 {
+		return nil
+	}
+	group, name := coordinates[:slash], coordinates[slash+1:]
+	var err error
+	if group, err = url.PathUnescape(group); err != nil {
+		return nil
+	}
+	if name, err = url.PathUnescape(name); err != nil {
+		return nil
+	}
+	if version, err = url.PathUnescape(version); err != nil {
+		return nil
+	}
+	values, _ := url.ParseQuery(qualifiers)
+
+	extension, classifier := values.Get("type"), values.Get("classifier")
+	switch extension {
+	case "pom":
+		// Parent POMs and BOMs have no artifact to verify
+		return nil
+	case "", "bundle", "maven-plugin", "ejb":
+		extension = "jar"
+	case "test-jar":
+		extension = "jar"
+		if classifier == "" {
+			classifier = "tests"
+		}
+	}
+	file := name + "-" + version
+	if classifier != "" {
+		file += "-" + classifier
+	}
+	file += "." + extension
+
+	var paths []string
+	if repository := localRepository(runenv.SettingsOf(ctx)); repository != "" {
+		paths = append(paths, filepath.Join(repository, filepath.FromSlash(strings.ReplaceAll(group, ".", "/")), name, version, file))
+	}
+	if cache := gradleUserHome(); cache != "" {
+		matches, _ := filepath.Glob(filepath.Join(cache, "caches", "modules-2", "files-2.1", group, name, version, "*", file))
+		paths = append(paths, matches...)
+	}
+	return paths
+}
+
+// gradleUserHome returns GRADLE_USER_HOME or ~/.gradle
+func gradleUserHome() string {
+	if home := os.Getenv("GRADLE_USER_HOME"); home != "" {
+		return home
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".gradle")
+}
+
+// hashArtifact computes the SHA-1 and SHA-256 of a file in one pass
+func hashArtifact(path string) (artifactHashes, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return artifactHashes{}, err
+	}
+	defer file.Close()
+
+	sha1Hash, sha256Hash := sha1.New(), sha256.New()
+	if _, err := io.Copy(io.MultiWriter(sha1Hash, sha256Hash), file); err != nil {
+		return artifactHashes{}, err
+	}
+	return artifactHashes{
+		sha1:   hex.EncodeToString(sha1Hash.Sum(nil)),
+		sha256: hex.EncodeToString(sha256Hash.Sum(nil)),
+	}, nil
+}
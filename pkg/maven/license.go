@@ -0,0 +1,202 @@
+package maven
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+// ComponentLicense lists the licenses of one SBOM component
+type ComponentLicense struct {
+	Package  string   `json:"package"`
+	Version  string   `json:"version"`
+	PURL     string   `json:"purl,omitempty"`
+	Licenses []string `json:"licenses"`
+}
+
+// licenseCount is the number of components under a license
+type licenseCount struct {
+	License    string `json:"license"`
+	Components int    `json:"components"`
+}
+
+// LicenseReport lists the licenses of all components of an SBOM
+type LicenseReport struct {
+	Components []ComponentLicense `json:"components"`
+	Licenses   []licenseCount     `json:"licenses"`
+	Unknown    int                `json:"unknown"` // components without license information
+}
+
+// LicensesPath returns where the license report of an SBOM is written
+func LicensesPath(sbomPath string) string {
+	return filepath.Join(filepath.Dir(sbomPath), "sbom-licenses.json")
+}
+
+// LicenseReportFor returns the license report next to a findings file:
+// aggregated-licenses.json for aggregated-report.json, else sbom-licenses.json
+func LicenseReportFor(findingsPath string) string {
+	if filepath.Base(findingsPath) == "aggregated-report.json" {
+		return filepath.Join(filepath.Dir(findingsPath), "aggregated-licenses.json")
+	}
+	return filepath.Join(filepath.Dir(findingsPath), "sbom-licenses.json")
+}
+
+// readComponentLicenses reads the licenses declared in the SBOM. Maven
+// components without licenses are looked up on Maven Central.
+func readComponentLicenses(ctx context.Context, sbomPath string) ([]ComponentLicense, error) {
+	components, err := sbom.ReadCycloneDX(sbomPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var resolver *POMResolver
+	failed := 0
+
+	var result []ComponentLicense
+	for _, c := range components {
+		if c.Name == "" || c.Type == "application" {
+			continue
+		}
+		pkg := sbom.NewPackage(c.Group, c.Name, c.Version, c.PURL)
+		entry := ComponentLicense{Package: pkg.Name, Version: pkg.Version, PURL: c.PURL, Licenses: c.Licenses.Names()}
+
+		if len(entry.Licenses) == 0 && !runenv.SettingsOf(ctx).Offline && strings.HasPrefix(c.PURL, "pkg:maven/") && c.Group != "" && c.Version != "" {
+			if resolver == nil {
+				resolver = NewPOMResolver(ctx)
+			}
+			if project, err := resolver.Fetch(c.Group, c.Name, c.Version); err == nil {
+				entry.Licenses = project.licenseNames()
+			} else {
+				failed++
+			}
+		}
+
+		if entry.Licenses == nil {
+			entry.Licenses = []string{}
+		}
+		result = append(result, entry)
+	}
+
+	if failed > 0 {
+		runenv.Logger.Warnf("Could not look up the licenses of %d Maven components", failed)
+	}
+	return result, nil
+}
+
+// NewLicenseReport counts the components of every license
+func NewLicenseReport(components []ComponentLicense) LicenseReport {
+	report := LicenseReport{Components: []ComponentLicense{}, Licenses: []licenseCount{}}
+
+	seen := make(map[string]bool)
+	counts := make(map[string]int)
+	for _, c := range components {
+		key := c.PURL
+		if key == "" {
+			key = c.Package + "@" + c.Version
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		report.Components = append(report.Components, c)
+		if len(c.Licenses) == 0 {
+			report.Unknown++
+		}
+		for _, license := range c.Licenses {
+			counts[license]++
+		}
+	}
+
+	for license, count := range counts {
+		report.Licenses = append(report.Licenses, licenseCount{License: license, Components: count})
+	}
+	sort.Slice(report.Licenses, func(i, j int) bool {
+		if report.Licenses[i].Components != report.Licenses[j].Components {
+			return report.Licenses[i].Components > report.Licenses[j].Components
+		}
+		return report.Licenses[i].License < report.Licenses[j].License
+	})
+	sort.Slice(report.Components, func(i, j int) bool {
+		if report.Components[i].Package != report.Components[j].Package {
+			return report.Components[i].Package < report.Components[j].Package
+		}
+		return report.Components[i].Version < report.Components[j].Version
+	})
+	return report
+}
+
+// WriteLicenseReport writes the license report of the SBOM next to it
+func WriteLicenseReport(ctx context.Context, sbomPath string) error {
+	components, err := readComponentLicenses(ctx, sbomPath)
+	if err != nil {
+		return err
+	}
+
+	report := NewLicenseReport(components)
+	if err := WriteLicenseFile(LicensesPath(sbomPath), report); err != nil {
+		return err
+	}
+
+	runenv.Logger.Infof("Found %d licenses, %d of %d components without license information (%s)",
+		len(report.Licenses), report.Unknown, len(report.Components), LicensesPath(sbomPath))
+	return nil
+}
+
+func WriteLicenseFile(path string, report LicenseReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode license report: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write license report: %v", err)
+	}
+	return nil
+}
+
+func ReadLicenseReport(path string) (LicenseReport, error) {
+	var report LicenseReport
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return report, fmt.Errorf("failed to read license report: %v", err)
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return report, fmt.Errorf("failed to parse license report %s: %v", path, err)
+	}
+	return report, nil
+}
+
+// EachComponentLicense calls fn for the components of the license report at
+// path as they are decoded, for the reports listing every component
+func EachComponentLicense(path string, fn func(ComponentLicense) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read license report: %v", err)
+	}
+	defer file.Close()
+
+	// Errors of fn are returned as they are
+	var fnErr error
+	err = runenv.DecodeJSONObject(json.NewDecoder(file), map[string]func(*json.Decoder) error{
+		"components": func(d *json.Decoder) error {
+			return runenv.DecodeJSONArray(d, func(c ComponentLicense) error {
+				fnErr = fn(c)
+				return fnErr
+			})
+		},
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse license report %s: %v", path, err)
+	}
+	return nil
+}
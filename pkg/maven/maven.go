@@ -0,0 +1,371 @@
+// Package maven generates the SBOMs of Maven projects with Maven and the
+// CycloneDX plugin or with the native POM resolver.
+package maven
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+// DefaultCycloneDXPluginVersion is the default of --cyclonedx-plugin-version
+const DefaultCycloneDXPluginVersion = "2.7.9"
+
+var pluginVersionPattern = regexp.MustCompile(`^[0-9][0-9A-Za-z.\-]*$`)
+
+// ValidCycloneDXPluginVersion reports whether version can be passed to Maven
+func ValidCycloneDXPluginVersion(version string) bool {
+	return version == "latest" || pluginVersionPattern.MatchString(version)
+}
+
+// CycloneDXPluginVersion returns the configured version of the CycloneDX
+// Maven plugin, DefaultCycloneDXPluginVersion when none is set
+func CycloneDXPluginVersion(s *runenv.Settings) string {
+	return cmp.Or(s.CycloneDXPluginVersion, DefaultCycloneDXPluginVersion)
+}
+
+// cyclonedxPluginGoal returns the makeAggregateBom goal of the configured version
+func cyclonedxPluginGoal(s *runenv.Settings) string {
+	version := CycloneDXPluginVersion(s)
+	if version == "latest" {
+		return "org.cyclonedx:cyclonedx-maven-plugin:makeAggregateBom"
+	}
+	return "org.cyclonedx:cyclonedx-maven-plugin:" + version + ":makeAggregateBom"
+}
+
+var (
+	pluginMavenPattern = regexp.MustCompile(`requires Maven version (\S+)`)
+	pluginJavaPattern  = regexp.MustCompile(`compiled by a more recent version of the Java Runtime|Unsupported class file major version`)
+)
+
+// cyclonedxPluginError explains a failed plugin run caused by the chosen
+// plugin version, nil when the output shows another problem
+func cyclonedxPluginError(s *runenv.Settings, output []byte) error {
+	version := CycloneDXPluginVersion(s)
+	if match := pluginMavenPattern.FindSubmatch(output); match != nil {
+		return fmt.Errorf("CycloneDX Maven plugin %s requires Maven %s or newer; upgrade Maven or choose an older plugin with --cyclonedx-plugin-version (default: %s)",
+			version, match[1], DefaultCycloneDXPluginVersion)
+	}
+	if pluginJavaPattern.Match(output) {
+		return fmt.Errorf("CycloneDX Maven plugin %s requires a newer Java than Maven runs with; upgrade Java or choose an older plugin with --cyclonedx-plugin-version (default: %s)",
+			version, DefaultCycloneDXPluginVersion)
+	}
+	if bytes.Contains(output, []byte("org.cyclonedx:cyclonedx-maven-plugin")) && bytes.Contains(output, []byte("could not be resolved")) {
+		return fmt.Errorf("CycloneDX Maven plugin %s could not be resolved; check --cyclonedx-plugin-version and the Maven repositories", version)
+	}
+	return nil
+}
+
+// Executable returns the Maven to run for a POM: the Maven wrapper
+// (mvnw) of its directory or a parent directory up to the repository root,
+// else the configured or installed executable
+func Executable(s *runenv.Settings, pomPath string) string {
+	if s.MavenWrapper {
+		if wrapper := findMavenWrapper(pomPath); wrapper != "" {
+			return wrapper
+		}
+	}
+	return runenv.SystemMaven(s)
+}
+
+// findMavenWrapper looks for mvnw next to the POM and in its parent
+// directories, stopping at the root of the git repository
+func findMavenWrapper(pomPath string) string {
+	name := "mvnw"
+	if runtime.GOOS == "windows" {
+		name = "mvnw.cmd"
+	}
+	dir, err := filepath.Abs(filepath.Dir(pomPath))
+	if err != nil {
+		return ""
+	}
+	for {
+		if wrapper := filepath.Join(dir, name); runenv.IsExecutable(wrapper) {
+			return wrapper
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// IsWrapper reports whether mvn is a project's mvnw
+func IsWrapper(mvn string) bool {
+	name := filepath.Base(mvn)
+	return filepath.IsAbs(mvn) && (name == "mvnw" || name == "mvnw.cmd")
+}
+
+// mavenArgs adds the settings file, proxy, local repository, offline
+// settings, profiles and --mvn-args to a Maven command line
+func mavenArgs(s *runenv.Settings, args ...string) []string {
+	if len(s.MavenProfiles) > 0 {
+		args = append(args, "-P"+strings.Join(s.MavenProfiles, ","))
+	}
+	args = append(args, mavenSettingsArgs(s)...)
+	if s.MavenRepoLocal != "" {
+		if abs, err := filepath.Abs(s.MavenRepoLocal); err == nil {
+			args = append(args, "-Dmaven.repo.local="+abs)
+		}
+	}
+	if s.MavenOffline || s.Offline {
+		args = append(args, "--offline")
+	}
+	return append(args, s.MavenExtraArgs...)
+}
+
+// newMavenWorkspace creates a temporary directory outside the project and the
+// output directory with a copy of the POM, its local parent POMs and the POMs
+// of its modules, in the same layout. Maven runs there, so its target/
+// directories never touch a checkout; remove it with removeMavenWorkspace.
+func newMavenWorkspace(ctx context.Context, pomPath string) (string, string, error) {
+	absPomPath, err := filepath.Abs(pomPath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get absolute path: %v", err)
+	}
+	// The workspace mirrors the directory that holds all of them
+	poms := workspacePOMs(absPomPath)
+	root := filepath.Dir(absPomPath)
+	for _, pom := range poms {
+		for !strings.HasPrefix(pom, root+string(filepath.Separator)) && filepath.Dir(root) != root {
+			root = filepath.Dir(root)
+		}
+	}
+
+	dir, err := runenv.NewTempDir(ctx, "sbom-scanner-maven-")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create Maven workspace: %v", err)
+	}
+	for _, pom := range poms {
+		rel, err := filepath.Rel(root, pom)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue // on another volume
+		}
+		if err := runenv.CopyFile(pom, filepath.Join(dir, rel)); err != nil {
+			os.RemoveAll(dir)
+			return "", "", fmt.Errorf("failed to copy POM to the Maven workspace: %v", err)
+		}
+	}
+	rel, err := filepath.Rel(root, absPomPath)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", "", fmt.Errorf("failed to create Maven workspace: %v", err)
+	}
+	return dir, filepath.Join(dir, rel), nil
+}
+
+// workspacePOMs returns the POM with the POMs Maven reads along with it: the
+// parents at their relativePath and, recursively, the modules of the reactor,
+// those of profiles included. The modules of parents are not built and left
+// out, as are POMs that are not on disk; Maven downloads missing parents.
+func workspacePOMs(pomPath string) []string {
+	poms := []string{pomPath}
+	reactor := map[string]bool{pomPath: true}
+	seen := map[string]bool{pomPath: true}
+	for i := 0; i < len(poms); i++ {
+		data, err := os.ReadFile(poms[i])
+		if err != nil {
+			continue
+		}
+		project, err := ParsePOM(data)
+		if err != nil {
+			continue
+		}
+		dir := filepath.Dir(poms[i])
+
+		add := func(path string, inReactor bool) {
+			if seen[path] {
+				return
+			}
+			seen[path] = true
+			if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+				poms = append(poms, path)
+				reactor[path] = inReactor
+			}
+		}
+		if project.Parent != nil {
+			if _, path, ok := LocalParent(project.Parent, dir); ok {
+				add(path, false)
+			}
+		}
+		if !reactor[poms[i]] {
+			continue
+		}
+		modules := project.Modules
+		for _, profile := range project.Profiles {
+			modules = append(modules, profile.Modules...)
+		}
+		for _, module := range modules {
+			path := filepath.Join(dir, strings.TrimSpace(module))
+			if info, err := os.Stat(path); err == nil && info.IsDir() {
+				path = filepath.Join(path, "pom.xml")
+			}
+			add(path, true)
+		}
+	}
+	return poms
+}
+
+// removeMavenWorkspace deletes a workspace of newMavenWorkspace, unless
+// --keep-temp is set
+func removeMavenWorkspace(ctx context.Context, dir string) {
+	if runenv.SettingsOf(ctx).KeepTemp {
+		runenv.Logger.Infof("Keeping Maven workspace %s", dir)
+		return
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		runenv.Logger.Warnf("Failed to clean up Maven workspace %s: %v", dir, err)
+	}
+}
+
+// Maven output of failed downloads, retried as they are often transient
+var mavenDownloadErrors = []string{
+	"Could not transfer",
+	"Connection reset",
+	"Connection refused",
+	"Connect timed out",
+	"Read timed out",
+	"Unknown host",
+	"Temporary failure in name resolution",
+	"status code: 5",
+}
+
+// runMaven runs mvn in dir and returns its output. Runs that failed to
+// download from a repository are retried.
+func runMaven(ctx context.Context, mvn, operation, dir string, args ...string) ([]byte, error) {
+	var output []byte
+	err := runenv.RetryTransient(ctx, operation, func() error {
+		start := time.Now()
+		defer func() {
+			runenv.RecordTiming(ctx, runenv.TimingMaven, strings.TrimPrefix(operation, "Maven "), time.Since(start))
+		}()
+		cmd := runenv.Command(ctx, mvn, mavenArgs(runenv.SettingsOf(ctx), args...)...)
+		cmd.Dir = dir
+		cmd.Env = append(cmd.Environ(), runenv.JavaProxyEnv()...)
+		if IsWrapper(mvn) {
+			// The wrapper reads .mvn/wrapper from its project, not from dir
+			cmd.Env = append(cmd.Environ(), "MAVEN_BASEDIR="+filepath.Dir(mvn))
+		}
+
+		var err error
+		if output, err = cmd.CombinedOutput(); err != nil {
+			for _, message := range mavenDownloadErrors {
+				if strings.Contains(string(output), message) {
+					return runenv.Transient(err)
+				}
+			}
+			return err
+		}
+		return nil
+	})
+	return output, err
+}
+
+func WriteDependencyTree(ctx context.Context, mvn, pomPath, outputPath string) error {
+	absOutputPath, err := filepath.Abs(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %v", err)
+	}
+
+	workspace, workspacePom, err := newMavenWorkspace(ctx, pomPath)
+	if err != nil {
+		return err
+	}
+	defer removeMavenWorkspace(ctx, workspace)
+
+	output, err := runMaven(ctx, mvn, "Maven dependency:tree", workspace,
+		"dependency:tree",
+		"-f", workspacePom,
+		"-DoutputFile="+absOutputPath,
+		"-DoutputType=text")
+	if err != nil {
+		return fmt.Errorf("maven command failed: %v\n%s", err, string(output))
+	}
+
+	runenv.Logger.Infof("Dependency tree written to %s", outputPath)
+	return nil
+}
+
+func WriteEffectivePOM(ctx context.Context, mvn, pomPath, outputPath string) error {
+	absOutputPath, err := filepath.Abs(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %v", err)
+	}
+
+	workspace, workspacePom, err := newMavenWorkspace(ctx, pomPath)
+	if err != nil {
+		return err
+	}
+	defer removeMavenWorkspace(ctx, workspace)
+
+	output, err := runMaven(ctx, mvn, "Maven help:effective-pom", workspace,
+		"help:effective-pom",
+		"-f", workspacePom,
+		"-Doutput="+absOutputPath)
+	if err != nil {
+		return fmt.Errorf("effective-pom generation failed: %v\n%s", err, string(output))
+	}
+
+	runenv.Logger.Infof("Effective POM written to %s", outputPath)
+	return nil
+}
+
+// GenerateCycloneDX runs the CycloneDX Maven plugin, leaving out the
+// dependencies outside the scopes (all are included when empty)
+func GenerateCycloneDX(ctx context.Context, mvn, pomPath, outputPath string, scopes []string) error {
+	s := runenv.SettingsOf(ctx)
+	absOutputPath, err := filepath.Abs(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %v", err)
+	}
+
+	// The plugin writes to target/ of the workspace, removed with it also
+	// when Maven fails or is killed
+	workspace, workspacePom, err := newMavenWorkspace(ctx, pomPath)
+	if err != nil {
+		return err
+	}
+	defer removeMavenWorkspace(ctx, workspace)
+
+	args := []string{
+		cyclonedxPluginGoal(s),
+		"-f", workspacePom,
+		"-DoutputFormat=xml",
+		"-DoutputFile=bom.xml",
+	}
+	for _, scope := range sbom.DependencyScopes {
+		if !sbom.ScopeIncluded(scopes, scope) {
+			args = append(args, "-Dinclude"+strings.ToUpper(scope[:1])+scope[1:]+"Scope=false")
+		}
+	}
+
+	if output, err := runMaven(ctx, mvn, "Maven CycloneDX plugin", workspace, args...); err != nil {
+		if pluginErr := cyclonedxPluginError(s, output); pluginErr != nil {
+			return pluginErr
+		}
+		return fmt.Errorf("cyclonedx generation failed: %v\n%s", err, string(output))
+	}
+
+	// target/bom.xml'i sbom.xml olarak kopyala, the workspace may be on another file system
+	srcPath := filepath.Join(filepath.Dir(workspacePom), "target", "bom.xml")
+	if err := runenv.CopyFile(srcPath, absOutputPath); err != nil {
+		return fmt.Errorf("failed to copy SBOM to output dir: %v", err)
+	}
+
+	runenv.Logger.Infof("CycloneDX BOM written to %s", outputPath)
+	return nil
+}
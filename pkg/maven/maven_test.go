@@ -0,0 +1,80 @@
+package maven
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+func TestNewMavenWorkspace(t *testing.T) {
+	project := t.TempDir()
+	poms := map[string]string{
+		"build-parent/pom.xml": `<project><artifactId>build-parent</artifactId>
+  <modules><module>../other</module></modules>
+</project>`,
+		"app/pom.xml": `<project>
+  <parent><artifactId>build-parent</artifactId><relativePath>../build-parent/pom.xml</relativePath></parent>
+  <artifactId>app</artifactId>
+  <modules><module>core</module><module>web/pom.xml</module><module>missing</module></modules>
+  <profiles><profile><id>extra</id><modules><module>extra</module></modules></profile></profiles>
+</project>`,
+		"app/core/pom.xml":  `<project><parent><artifactId>app</artifactId></parent><artifactId>core</artifactId></project>`,
+		"app/web/pom.xml":   `<project><parent><artifactId>app</artifactId></parent><artifactId>web</artifactId></project>`,
+		"app/extra/pom.xml": `<project><parent><artifactId>app</artifactId></parent><artifactId>extra</artifactId></project>`,
+		"other/pom.xml":     `<project><artifactId>other</artifactId></project>`,
+	}
+	for name, content := range poms {
+		path := filepath.Join(project, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := runenv.NewSettings()
+	s.MavenWorkspaceDir = t.TempDir()
+	ctx := runenv.WithSettings(context.Background(), s)
+	workspace, workspacePom, err := newMavenWorkspace(ctx, filepath.Join(project, "app", "pom.xml"))
+	if err != nil {
+		t.Fatalf("newMavenWorkspace() error = %v", err)
+	}
+	if want := filepath.Join(workspace, "app", "pom.xml"); workspacePom != want {
+		t.Errorf("workspace POM = %s, want %s", workspacePom, want)
+	}
+
+	var got []string
+	filepath.WalkDir(workspace, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(workspace, path)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	sort.Strings(got)
+	want := []string{"app/core/pom.xml", "app/extra/pom.xml", "app/pom.xml", "app/web/pom.xml", "build-parent/pom.xml"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("workspace files = %v, want %v", got, want)
+	}
+
+	// A module is copied with its parent, but not with its siblings
+	workspace, workspacePom, err = newMavenWorkspace(ctx, filepath.Join(project, "app", "core", "pom.xml"))
+	if err != nil {
+		t.Fatalf("newMavenWorkspace() error = %v", err)
+	}
+	if want := filepath.Join(workspace, "app", "core", "pom.xml"); workspacePom != want {
+		t.Errorf("workspace POM = %s, want %s", workspacePom, want)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "app", "web", "pom.xml")); err == nil {
+		t.Errorf("sibling module copied to the workspace of a module")
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "build-parent", "pom.xml")); err != nil {
+		t.Errorf("grandparent POM not copied: %v", err)
+	}
+}
@@ -0,0 +1,400 @@
+package maven
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+// mavenSettingsFile is the parts of settings.xml the native resolver and the
+// artifact hashes need
+type mavenSettingsFile struct {
+	LocalRepository string `xml:"localRepository"`
+	Mirrors         []struct {
+		ID       string `xml:"id"`
+		URL      string `xml:"url"`
+		MirrorOf string `xml:"mirrorOf"`
+	} `xml:"mirrors>mirror"`
+	Servers []struct {
+		ID       string `xml:"id"`
+		Username string `xml:"username"`
+		Password string `xml:"password"`
+		Headers  []struct {
+			Name  string `xml:"name"`
+			Value string `xml:"value"`
+		} `xml:"configuration>httpHeaders>property"`
+	} `xml:"servers>server"`
+	Profiles []struct {
+		ID           string `xml:"id"`
+		Repositories []struct {
+			ID  string `xml:"id"`
+			URL string `xml:"url"`
+		} `xml:"repositories>repository"`
+	} `xml:"profiles>profile"`
+	ActiveProfiles []string `xml:"activeProfiles>activeProfile"`
+}
+
+// mavenRepository is a repository the native resolver downloads POMs from
+type mavenRepository struct {
+	URL           string
+	Username      string
+	Password      string
+	Authorization string // header of the server's httpHeaders, e.g. a Bearer token
+}
+
+// repository returns the repository with the credentials of its server entry
+func (s mavenSettingsFile) repository(id, repositoryURL string) mavenRepository {
+	repository := mavenRepository{URL: strings.TrimSuffix(expandSettings(repositoryURL), "/")}
+	for _, server := range s.Servers {
+		if server.ID != id {
+			continue
+		}
+		repository.Username = expandSettings(server.Username)
+		repository.Password = expandSettings(server.Password)
+		for _, header := range server.Headers {
+			if strings.EqualFold(header.Name, "Authorization") {
+				repository.Authorization = expandSettings(header.Value)
+			}
+		}
+	}
+	return repository
+}
+
+// MavenRepository is a private repository, e.g. on Artifactory, Nexus or
+// GitHub Packages, added to a settings.xml generated for the scan. The
+// credentials are read from environment variables, the file only refers to
+// them.
+type MavenRepository struct {
+	ID          string `yaml:"id,omitempty"` // server id in settings.xml, private-1, private-2, ... when empty
+	URL         string `yaml:"url"`
+	Mirror      bool   `yaml:"mirror,omitempty"`       // mirror of all repositories (mirrorOf *), e.g. a virtual repository
+	UsernameEnv string `yaml:"username-env,omitempty"` // MAVEN_REPO_USERNAME when empty
+	PasswordEnv string `yaml:"password-env,omitempty"` // MAVEN_REPO_PASSWORD when empty
+	TokenEnv    string `yaml:"token-env,omitempty"`    // MAVEN_REPO_TOKEN when empty, sent as a Bearer token
+}
+
+// Default environment variables of the repository credentials
+const (
+	mavenRepoUsernameEnv = "MAVEN_REPO_USERNAME"
+	mavenRepoPasswordEnv = "MAVEN_REPO_PASSWORD"
+	mavenRepoTokenEnv    = "MAVEN_REPO_TOKEN"
+)
+
+// generatedSettings is the settings.xml written for --maven-repository
+type generatedSettings struct {
+	XMLName        xml.Name           `xml:"settings"`
+	Servers        []generatedServer  `xml:"servers>server,omitempty"`
+	Mirrors        []generatedMirror  `xml:"mirrors>mirror,omitempty"`
+	Profiles       []generatedProfile `xml:"profiles>profile,omitempty"`
+	ActiveProfiles []string           `xml:"activeProfiles>activeProfile,omitempty"`
+}
+
+type generatedServer struct {
+	ID       string            `xml:"id"`
+	Username string            `xml:"username,omitempty"`
+	Password string            `xml:"password,omitempty"`
+	Headers  []generatedHeader `xml:"configuration>httpHeaders>property,omitempty"`
+}
+
+type generatedHeader struct {
+	Name  string `xml:"name"`
+	Value string `xml:"value"`
+}
+
+type generatedMirror struct {
+	ID       string `xml:"id"`
+	URL      string `xml:"url"`
+	MirrorOf string `xml:"mirrorOf"`
+}
+
+type generatedProfile struct {
+	ID                 string                `xml:"id"`
+	Repositories       []generatedRepository `xml:"repositories>repository"`
+	PluginRepositories []generatedRepository `xml:"pluginRepositories>pluginRepository"`
+}
+
+type generatedRepository struct {
+	ID        string `xml:"id"`
+	URL       string `xml:"url"`
+	Releases  bool   `xml:"releases>enabled"`
+	Snapshots bool   `xml:"snapshots>enabled"`
+}
+
+// Profile of the generated settings.xml holding the private repositories
+const privateRepositoriesProfile = "sbom-scanner-repositories"
+
+// WriteSettings generates the settings.xml of private repositories and
+// returns its path. A repository gets a Bearer token when its token variable
+// is set, else basic authentication when its username variable is set. The
+// file is written to the cache directory and named after its content, so that
+// it can be shared by concurrent scans.
+func WriteSettings(s *runenv.Settings, repositories []MavenRepository) (string, error) {
+	var settings generatedSettings
+	var profileRepositories []generatedRepository
+	for i, r := range repositories {
+		u, err := url.Parse(r.URL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return "", fmt.Errorf("invalid Maven repository URL: %s", r.URL)
+		}
+		if r.ID == "" {
+			r.ID = fmt.Sprintf("private-%d", i+1)
+		}
+		server := generatedServer{ID: r.ID}
+		tokenEnv := cmp.Or(r.TokenEnv, mavenRepoTokenEnv)
+		usernameEnv := cmp.Or(r.UsernameEnv, mavenRepoUsernameEnv)
+		switch {
+		case os.Getenv(tokenEnv) != "":
+			server.Headers = []generatedHeader{{Name: "Authorization", Value: "Bearer ${env." + tokenEnv + "}"}}
+		case os.Getenv(usernameEnv) != "":
+			server.Username = "${env." + usernameEnv + "}"
+			server.Password = "${env." + cmp.Or(r.PasswordEnv, mavenRepoPasswordEnv) + "}"
+		}
+		if server.Username != "" || len(server.Headers) > 0 {
+			settings.Servers = append(settings.Servers, server)
+		}
+
+		if r.Mirror {
+			if len(settings.Mirrors) > 0 {
+				return "", fmt.Errorf("only one Maven repository can be the mirror")
+			}
+			settings.Mirrors = append(settings.Mirrors, generatedMirror{ID: r.ID, URL: r.URL, MirrorOf: "*"})
+			continue
+		}
+		profileRepositories = append(profileRepositories, generatedRepository{ID: r.ID, URL: r.URL, Releases: true, Snapshots: true})
+	}
+	if len(profileRepositories) > 0 {
+		settings.Profiles = []generatedProfile{{ID: privateRepositoriesProfile, Repositories: profileRepositories, PluginRepositories: profileRepositories}}
+		settings.ActiveProfiles = []string{privateRepositoriesProfile}
+	}
+
+	data, err := xml.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode Maven settings: %v", err)
+	}
+	data = append([]byte(xml.Header), data...)
+	sum := sha256.Sum256(data)
+	path := filepath.Join(s.CacheDir("maven-settings"), "settings-"+hex.EncodeToString(sum[:8])+".xml")
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("failed to write Maven settings: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to write Maven settings: %v", err)
+	}
+	// Maven runs in a temporary workspace
+	return filepath.Abs(path)
+}
+
+// settingsPath returns the settings.xml Maven reads: --maven-settings or
+// ~/.m2/settings.xml
+func settingsPath(s *runenv.Settings) string {
+	if s.MavenSettings != "" {
+		return s.MavenSettings
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".m2", "settings.xml")
+}
+
+// CentralRepository returns the mirror of Maven Central configured in
+// settings.xml with the credentials of its server entry, or Maven Central
+// itself
+func CentralRepository(s *runenv.Settings) (mavenRepository, error) {
+	central := mavenRepository{URL: mavenCentralURL}
+	path := settingsPath(s)
+	if path == "" {
+		return central, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) && s.MavenSettings == "" {
+		return central, nil
+	}
+	if err != nil {
+		return central, fmt.Errorf("failed to read Maven settings: %v", err)
+	}
+
+	var settings mavenSettingsFile
+	if err := xml.Unmarshal(data, &settings); err != nil {
+		return central, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	for _, mirror := range settings.Mirrors {
+		if mirror.URL == "" || !mirrorsCentral(mirror.MirrorOf) {
+			continue
+		}
+		return settings.repository(mirror.ID, mirror.URL), nil
+	}
+	return central, nil
+}
+
+// profileRepositories returns the repositories of the active profiles of
+// settings.xml with their credentials, which the native resolver tries when
+// a POM is not on Maven Central or its mirror
+func profileRepositories(s *runenv.Settings) []mavenRepository {
+	data, err := os.ReadFile(settingsPath(s))
+	if err != nil {
+		return nil
+	}
+	var settings mavenSettingsFile
+	if xml.Unmarshal(data, &settings) != nil {
+		return nil
+	}
+	var repositories []mavenRepository
+	for _, profile := range settings.Profiles {
+		if !slices.Contains(settings.ActiveProfiles, profile.ID) && !slices.Contains(s.MavenProfiles, profile.ID) {
+			continue
+		}
+		for _, r := range profile.Repositories {
+			if r.URL != "" {
+				repositories = append(repositories, settings.repository(r.ID, r.URL))
+			}
+		}
+	}
+	return repositories
+}
+
+// mirrorsCentral reports whether a mirrorOf pattern such as "*,!internal"
+// includes the central repository
+func mirrorsCentral(mirrorOf string) bool {
+	matched := false
+	for _, pattern := range strings.Split(mirrorOf, ",") {
+		switch strings.TrimSpace(pattern) {
+		case "!central":
+			return false
+		case "*", "central", "external:*":
+			matched = true
+		}
+	}
+	return matched
+}
+
+// localRepository returns the Maven local repository: --maven-repo-local, the
+// localRepository of settings.xml or ~/.m2/repository
+func localRepository(s *runenv.Settings) string {
+	if s.MavenRepoLocal != "" {
+		return s.MavenRepoLocal
+	}
+	if data, err := os.ReadFile(settingsPath(s)); err == nil {
+		var settings mavenSettingsFile
+		if xml.Unmarshal(data, &settings) == nil && strings.TrimSpace(settings.LocalRepository) != "" {
+			return expandSettings(settings.LocalRepository)
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".m2", "repository")
+}
+
+var settingsEnvPattern = regexp.MustCompile(`\$\{env\.([^}]+)\}`)
+
+// expandSettings replaces ${env.NAME} as Maven does, e.g. in server passwords
+func expandSettings(value string) string {
+	return settingsEnvPattern.ReplaceAllStringFunc(strings.TrimSpace(value), func(match string) string {
+		return os.Getenv(settingsEnvPattern.FindStringSubmatch(match)[1])
+	})
+}
+
+// generatedProxy is a proxy added to the settings.xml of mavenSettingsArgs
+type generatedProxy struct {
+	XMLName       xml.Name `xml:"proxy"`
+	ID            string   `xml:"id"`
+	Active        bool     `xml:"active"`
+	Protocol      string   `xml:"protocol"`
+	Host          string   `xml:"host"`
+	Port          string   `xml:"port"`
+	Username      string   `xml:"username,omitempty"`
+	Password      string   `xml:"password,omitempty"`
+	NonProxyHosts string   `xml:"nonProxyHosts,omitempty"`
+}
+
+var emptyProxiesPattern = regexp.MustCompile(`<proxies\s*/>`)
+
+// mavenSettingsArgs returns the user settings Maven runs with: --maven-settings
+// or ~/.m2/settings.xml as they are, or with a proxy from the environment a
+// copy of them with the proxies added. The proxies go after the configured
+// ones, which Maven prefers, and the global settings of the installation are
+// left alone. The passwords are referred to as ${env.NAME} of JavaProxyEnv.
+func mavenSettingsArgs(s *runenv.Settings) []string {
+	var args []string
+	if s.MavenSettings != "" {
+		if abs, err := filepath.Abs(s.MavenSettings); err == nil {
+			args = []string{"-s", abs}
+		}
+	}
+	proxies, nonProxyHosts := runenv.JavaProxies()
+	if len(proxies) == 0 {
+		return args
+	}
+
+	var generated []generatedProxy
+	for _, proxy := range proxies {
+		p := generatedProxy{ID: "sbom-scanner-" + proxy.Scheme, Active: true, Protocol: proxy.Scheme,
+			Host: proxy.Host, Port: proxy.Port, Username: proxy.Username, NonProxyHosts: nonProxyHosts}
+		if proxy.Password != "" {
+			p.Password = "${env." + runenv.ProxyPasswordVariable(proxy.Scheme) + "}"
+		}
+		generated = append(generated, p)
+	}
+	entries, err := xml.MarshalIndent(generated, "    ", "  ")
+	if err != nil {
+		runenv.Logger.Warnf("Failed to encode the Maven proxy settings: %v", err)
+		return args
+	}
+
+	settings, err := os.ReadFile(settingsPath(s))
+	if err != nil && (s.MavenSettings != "" || !os.IsNotExist(err)) {
+		runenv.Logger.Warnf("Failed to read the Maven settings, Maven runs without the proxy: %v", err)
+		return args
+	}
+	data, err := addMavenProxies(settings, entries)
+	if err != nil {
+		runenv.Logger.Warnf("Failed to add the proxies to %s, Maven runs without the proxy: %v", settingsPath(s), err)
+		return args
+	}
+
+	sum := sha256.Sum256(data)
+	path := filepath.Join(s.CacheDir("maven-settings"), "proxies-"+hex.EncodeToString(sum[:8])+".xml")
+	if err := runenv.WriteGeneratedFile(path, data); err != nil {
+		runenv.Logger.Warnf("Failed to write the Maven proxy settings, Maven runs without the proxy: %v", err)
+		return args
+	}
+	// Maven runs in a temporary workspace
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return []string{"-s", path}
+}
+
+// addMavenProxies adds proxy elements to the proxies of a settings.xml, or
+// returns a settings.xml with only them when it is empty. The rest of the
+// document is kept as it is.
+func addMavenProxies(settings, entries []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(settings)) == 0 {
+		return []byte(xml.Header + "<settings>\n  <proxies>\n" + string(entries) + "\n  </proxies>\n</settings>\n"), nil
+	}
+	if i := bytes.LastIndex(settings, []byte("</proxies>")); i >= 0 {
+		return slices.Concat(settings[:i], []byte("  "), entries, []byte("\n  "), settings[i:]), nil
+	}
+	proxies := []byte("<proxies>\n" + string(entries) + "\n  </proxies>")
+	if loc := emptyProxiesPattern.FindIndex(settings); loc != nil {
+		return slices.Concat(settings[:loc[0]], proxies, settings[loc[1]:]), nil
+	}
+	i := bytes.LastIndex(settings, []byte("</settings>"))
+	if i < 0 {
+		return nil, fmt.Errorf("no settings element")
+	}
+	return slices.Concat(settings[:i], []byte("  "), proxies, []byte("\n"), settings[i:]), nil
+}
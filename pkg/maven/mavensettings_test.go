@@ -0,0 +1,85 @@
+package maven
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+)
+
+func TestAddMavenProxies(t *testing.T) {
+	entries, err := xml.MarshalIndent([]generatedProxy{
+		{ID: "sbom-scanner-https", Active: true, Protocol: "https", Host: "proxy.example.com", Port: "3128"},
+	}, "    ", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		settings string
+		mirrors  []string
+		proxies  []string
+	}{
+		{
+			name:    "no settings",
+			proxies: []string{"sbom-scanner-https"},
+		},
+		{
+			name: "without proxies",
+			settings: `<?xml version="1.0"?>
+<settings>
+  <mirrors>
+    <mirror><id>nexus</id><url>https://nexus.example.com/maven</url><mirrorOf>*</mirrorOf></mirror>
+  </mirrors>
+</settings>`,
+			mirrors: []string{"nexus"},
+			proxies: []string{"sbom-scanner-https"},
+		},
+		{
+			name: "empty proxies",
+			settings: `<settings>
+  <proxies/>
+  <mirrors><mirror><id>nexus</id><url>https://nexus.example.com/maven</url><mirrorOf>*</mirrorOf></mirror></mirrors>
+</settings>`,
+			mirrors: []string{"nexus"},
+			proxies: []string{"sbom-scanner-https"},
+		},
+		{
+			name: "configured proxy first",
+			settings: `<settings xmlns="http://maven.apache.org/SETTINGS/1.0.0">
+  <proxies>
+    <proxy><id>corporate</id><active>true</active><protocol>https</protocol><host>corp</host><port>8080</port></proxy>
+  </proxies>
+  <mirrors><mirror><id>nexus</id><url>https://nexus.example.com/maven</url><mirrorOf>*</mirrorOf></mirror></mirrors>
+</settings>`,
+			mirrors: []string{"nexus"},
+			proxies: []string{"corporate", "sbom-scanner-https"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := addMavenProxies([]byte(tt.settings), entries)
+			if err != nil {
+				t.Fatalf("addMavenProxies() error = %v", err)
+			}
+			var settings struct {
+				Mirrors []string `xml:"mirrors>mirror>id"`
+				Proxies []string `xml:"proxies>proxy>id"`
+			}
+			if err := xml.Unmarshal(data, &settings); err != nil {
+				t.Fatalf("settings do not parse: %v\n%s", err, data)
+			}
+			if !reflect.DeepEqual(settings.Mirrors, tt.mirrors) {
+				t.Errorf("mirrors = %v, want %v", settings.Mirrors, tt.mirrors)
+			}
+			if !reflect.DeepEqual(settings.Proxies, tt.proxies) {
+				t.Errorf("proxies = %v, want %v", settings.Proxies, tt.proxies)
+			}
+		})
+	}
+
+	if _, err := addMavenProxies([]byte("<project/>"), entries); err == nil {
+		t.Errorf("addMavenProxies() of a file without settings did not fail")
+	}
+}
@@ -0,0 +1,302 @@
+package maven
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+// propertyBuildPlugin names the plugin a build tooling component is on the
+// classpath of
+const propertyBuildPlugin = "sbom-scanner:build-plugin"
+
+// Maven's default groupId of plugins declared without one
+const defaultPluginGroupID = "org.apache.maven.plugins"
+
+// key identifies a plugin regardless of its version
+func (p pomPlugin) key() string {
+	return p.groupID() + ":" + p.ArtifactID
+}
+
+func (p pomPlugin) groupID() string {
+	if p.GroupID == "" {
+		return defaultPluginGroupID
+	}
+	return p.GroupID
+}
+
+// coordinates returns group:artifact:version of a plugin
+func (p pomPlugin) coordinates() string {
+	return p.key() + ":" + p.Version
+}
+
+// classpath returns the plugin and the dependencies declared for it, the
+// roots of the artifacts Maven loads to run it
+func (p pomPlugin) classpath() []POMDependency {
+	deps := []POMDependency{{GroupID: p.groupID(), ArtifactID: p.ArtifactID, Version: p.Version}}
+	for _, d := range p.Dependencies {
+		// Plugin dependencies are all on its classpath, whatever their scope
+		d.Scope = ""
+		deps = append(deps, d)
+	}
+	return deps
+}
+
+// buildPlugins returns the plugins and build extensions of a project, with
+// the versions managed in pluginManagement, and the number of plugins left
+// out because they have no version
+func (p *POMProject) buildPlugins() ([]pomPlugin, int) {
+	managed := make(map[string]pomPlugin)
+	for _, plugin := range p.Build.PluginManagement.Plugins {
+		managed[plugin.key()] = plugin
+	}
+
+	var plugins []pomPlugin
+	unversioned := 0
+	seen := make(map[string]bool)
+	for _, plugin := range append(append([]pomPlugin{}, p.Build.Plugins...), p.Build.Extensions...) {
+		if seen[plugin.key()] {
+			continue
+		}
+		seen[plugin.key()] = true
+		if m, ok := managed[plugin.key()]; ok {
+			if plugin.Version == "" {
+				plugin.Version = m.Version
+			}
+			if len(plugin.Dependencies) == 0 {
+				plugin.Dependencies = m.Dependencies
+			}
+		}
+		if plugin.Version == "" || strings.Contains(plugin.Version, "${") {
+			unversioned++
+			continue
+		}
+		plugins = append(plugins, plugin)
+	}
+	return plugins, unversioned
+}
+
+// AddBuildPlugins adds the Maven build plugins and extensions of a project,
+// with their transitive dependencies, to the SBOM as components with scope
+// excluded, as they run during the build but do not ship with the artifact.
+// The plugins are read from the effective POM written by Maven when there is
+// one, which includes the plugins of the default lifecycle, else from the
+// POM and its parents. The components are inserted into the document as it
+// is, so the content written by the CycloneDX plugin is kept.
+func AddBuildPlugins(ctx context.Context, pomPath, effectivePomPath, sbomPath string) error {
+	s := runenv.SettingsOf(ctx)
+	resolver := NewPOMResolver(ctx)
+	resolver.retry = true
+	resolver.profiles = s.MavenProfiles
+
+	project, err := resolver.loadEffectivePOM(pomPath, effectivePomPath)
+	if err != nil {
+		return fmt.Errorf("failed to read the build plugins: %v", err)
+	}
+
+	plugins, unversioned := project.buildPlugins()
+	if unversioned > 0 {
+		runenv.Logger.Warnf("%d build plugins have no version in %s and are not listed in the SBOM", unversioned, pomPath)
+	}
+	if len(plugins) == 0 {
+		return nil
+	}
+	if s.Offline {
+		runenv.Logger.Info("Transitive dependencies of build plugins are not resolved with --offline, only the plugins are listed")
+	}
+
+	existing, err := sbom.ReadCycloneDX(sbomPath)
+	if err != nil {
+		return err
+	}
+	// Components of the application keep their scope
+	seen := make(map[string]bool)
+	for _, c := range existing {
+		purl, _, _ := strings.Cut(c.PURL, "?")
+		seen[purl] = true
+	}
+
+	var components []sbom.CDXComponent
+	for _, plugin := range plugins {
+		var resolved []sbom.Component
+		if s.Offline {
+			resolved = []sbom.Component{{Type: "maven", Namespace: plugin.groupID(), Name: plugin.ArtifactID, Version: plugin.Version}}
+		} else {
+			resolved = treeComponents(resolver.ResolveTree(&POMProject{Dependencies: plugin.classpath()}))
+		}
+		for _, c := range resolved {
+			purl := c.PURL()
+			if c.Version == "" || seen[purl] {
+				continue
+			}
+			seen[purl] = true
+			components = append(components, sbom.CDXComponent{
+				Type:       "library",
+				BOMRef:     purl,
+				Group:      c.Namespace,
+				Name:       c.Name,
+				Version:    c.Version,
+				Scope:      "excluded",
+				Licenses:   sbom.NewCDXLicenses(c.Licenses),
+				PURL:       purl,
+				Properties: []sbom.CDXProperty{{Name: propertyBuildPlugin, Value: plugin.coordinates()}},
+			})
+		}
+	}
+	if len(components) == 0 {
+		return nil
+	}
+
+	if err := insertComponents(sbomPath, components); err != nil {
+		return err
+	}
+	runenv.Logger.Infof("Added %d build plugins with %d components to %s", len(plugins), len(components), sbomPath)
+	return nil
+}
+
+// insertComponents appends components to the top-level components of a
+// CycloneDX XML document, with the indentation of the existing ones
+func insertComponents(sbomPath string, components []sbom.CDXComponent) error {
+	data, err := os.ReadFile(sbomPath)
+	if err != nil {
+		return fmt.Errorf("failed to read SBOM: %v", err)
+	}
+
+	// Offsets in the document: after the <components> start tag, after its
+	// last component and before the first top-level element following it
+	var open, last, next int64 = -1, -1, -1
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	depth := 0
+	inComponents := false
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse SBOM %s: %v", sbomPath, err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			switch {
+			case depth == 2 && t.Name.Local == "components":
+				open = decoder.InputOffset()
+				inComponents = true
+			case depth == 2 && t.Name.Local != "metadata" && next < 0:
+				next = offset
+			}
+		case xml.EndElement:
+			switch {
+			case depth == 3 && inComponents && t.Name.Local == "component":
+				last = decoder.InputOffset()
+			case depth == 2 && inComponents:
+				inComponents = false
+			case depth == 1 && next < 0:
+				next = offset
+			}
+			depth--
+		}
+	}
+
+	// The components are formatted with the indentation of the line they
+	// follow, or of the line of the element they are placed before
+	encode := func(indent string, leading bool) (string, error) {
+		var b strings.Builder
+		for _, c := range components {
+			if leading {
+				b.WriteString("\n")
+			}
+			leading = true
+			var element bytes.Buffer
+			encoder := xml.NewEncoder(&element)
+			if indent != "" {
+				encoder.Indent(indent, "  ")
+			}
+			if err := encoder.EncodeElement(c, xml.StartElement{Name: xml.Name{Local: "component"}}); err != nil {
+				return "", fmt.Errorf("failed to encode component: %v", err)
+			}
+			b.Write(element.Bytes())
+		}
+		return b.String(), nil
+	}
+	lineIndent := func(offset int64) (string, bool) {
+		lineStart := bytes.LastIndexByte(data[:offset], '\n') + 1
+		if lineStart == 0 {
+			return "", false
+		}
+		line := data[lineStart:offset]
+		return string(line[:len(line)-len(bytes.TrimLeft(line, " \t"))]), true
+	}
+
+	var b bytes.Buffer
+	switch {
+	case last >= 0:
+		indent, _ := lineIndent(last)
+		text, err := encode(indent, true)
+		if err != nil {
+			return err
+		}
+		b.Write(data[:last])
+		b.WriteString(text)
+		b.Write(data[last:])
+	case open >= 0 && bytes.HasSuffix(data[:open], []byte("/>")):
+		// <components/> is replaced by the element with the components
+		start := bytes.LastIndexByte(data[:open], '<')
+		indent, multiline := lineIndent(int64(start))
+		inner, closing := indent+"  ", "\n"+indent
+		if !multiline {
+			inner, closing = "", ""
+		}
+		text, err := encode(inner, multiline)
+		if err != nil {
+			return err
+		}
+		b.Write(data[:open-2])
+		b.WriteString(">" + text + closing + "</components>")
+		b.Write(data[open:])
+	case open >= 0:
+		start := bytes.LastIndexByte(data[:open], '<')
+		indent, multiline := lineIndent(int64(start))
+		inner := indent + "  "
+		if !multiline {
+			inner = ""
+		}
+		text, err := encode(inner, multiline)
+		if err != nil {
+			return err
+		}
+		b.Write(data[:open])
+		b.WriteString(text)
+		b.Write(data[open:])
+	case next >= 0:
+		// Documents without dependencies may have no components element
+		indent, multiline := lineIndent(next)
+		inner, closing := indent+"  ", "\n"+indent
+		if !multiline {
+			inner, closing = "", ""
+		}
+		text, err := encode(inner, multiline)
+		if err != nil {
+			return err
+		}
+		b.Write(data[:next])
+		b.WriteString("<components>" + text + closing + "</components>" + closing)
+		b.Write(data[next:])
+	default:
+		return fmt.Errorf("failed to parse SBOM %s: no bom element", sbomPath)
+	}
+
+	if err := os.WriteFile(sbomPath, b.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write SBOM: %v", err)
+	}
+	return nil
+}
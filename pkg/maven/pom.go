@@ -0,0 +1,881 @@
+package maven
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+const mavenCentralURL = "https://repo.maven.apache.org/maven2"
+
+type POMProject struct {
+	Parent               *pomParent           `xml:"parent"`
+	GroupID              string               `xml:"groupId"`
+	ArtifactID           string               `xml:"artifactId"`
+	Version              string               `xml:"version"`
+	Packaging            string               `xml:"packaging"`
+	Modules              []string             `xml:"modules>module"`
+	Properties           pomProperties        `xml:"properties"`
+	DependencyManagement pomDependencyManager `xml:"dependencyManagement"`
+	Dependencies         []POMDependency      `xml:"dependencies>dependency"`
+	Licenses             []pomLicense         `xml:"licenses>license"`
+	Profiles             []pomProfile         `xml:"profiles>profile"`
+	Build                pomBuild             `xml:"build"`
+}
+
+// pomBuild is the part of <build> that declares the build tooling
+type pomBuild struct {
+	Plugins          []pomPlugin `xml:"plugins>plugin"`
+	PluginManagement struct {
+		Plugins []pomPlugin `xml:"plugins>plugin"`
+	} `xml:"pluginManagement"`
+	Extensions []pomPlugin `xml:"extensions>extension"`
+}
+
+// pomPlugin is a build plugin or extension with the dependencies added to
+// its classpath
+type pomPlugin struct {
+	GroupID       string                 `xml:"groupId"`
+	ArtifactID    string                 `xml:"artifactId"`
+	Version       string                 `xml:"version"`
+	Configuration pomPluginConfiguration `xml:"configuration"`
+	Dependencies  []POMDependency        `xml:"dependencies>dependency"`
+}
+
+// pomPluginConfiguration is the part of a plugin configuration that sets the
+// Java version of maven-compiler-plugin
+type pomPluginConfiguration struct {
+	Release string `xml:"release"`
+	Source  string `xml:"source"`
+	Target  string `xml:"target"`
+}
+
+// pomProfile is the part of a <profile> that changes the dependencies
+type pomProfile struct {
+	ID         string `xml:"id"`
+	Activation struct {
+		ActiveByDefault bool `xml:"activeByDefault"`
+	} `xml:"activation"`
+	Modules              []string             `xml:"modules>module"`
+	Properties           pomProperties        `xml:"properties"`
+	DependencyManagement pomDependencyManager `xml:"dependencyManagement"`
+	Dependencies         []POMDependency      `xml:"dependencies>dependency"`
+}
+
+// withProfiles returns the project with the given profiles and, when none of
+// them is declared in it, its activeByDefault profiles merged in. Profiles
+// prefixed with ! or - are deactivated, as with mvn -P.
+func (p *POMProject) withProfiles(ids []string) *POMProject {
+	if len(p.Profiles) == 0 {
+		return p
+	}
+	active := make(map[string]bool)
+	for _, id := range ids {
+		if strings.HasPrefix(id, "!") || strings.HasPrefix(id, "-") {
+			active[id[1:]] = false
+		} else {
+			active[id] = true
+		}
+	}
+
+	explicit := false
+	for _, profile := range p.Profiles {
+		if active[profile.ID] {
+			explicit = true
+		}
+	}
+
+	result := *p
+	result.Properties = make(pomProperties)
+	for k, v := range p.Properties {
+		result.Properties[k] = v
+	}
+	for _, profile := range p.Profiles {
+		on, listed := active[profile.ID]
+		if !on && (listed || explicit || !profile.Activation.ActiveByDefault) {
+			continue
+		}
+		for k, v := range profile.Properties {
+			result.Properties[k] = v
+		}
+		result.DependencyManagement.Dependencies = mergeManagedDependencies(
+			result.DependencyManagement.Dependencies, profile.DependencyManagement.Dependencies)
+		result.Dependencies = append(append([]POMDependency{}, result.Dependencies...), profile.Dependencies...)
+	}
+	return &result
+}
+
+type pomLicense struct {
+	Name string `xml:"name"`
+	URL  string `xml:"url"`
+}
+
+// licenseNames returns the declared licenses as SPDX ids where possible
+func (p *POMProject) licenseNames() []string {
+	var names []string
+	for _, l := range p.Licenses {
+		name := l.Name
+		if name == "" {
+			name = sbom.LicenseFromURL(l.URL)
+		}
+		if name = sbom.NormalizeLicense(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+type pomParent struct {
+	GroupID      string `xml:"groupId"`
+	ArtifactID   string `xml:"artifactId"`
+	Version      string `xml:"version"`
+	RelativePath string `xml:"relativePath"`
+}
+
+type pomDependencyManager struct {
+	Dependencies []POMDependency `xml:"dependencies>dependency"`
+}
+
+type POMDependency struct {
+	GroupID    string         `xml:"groupId"`
+	ArtifactID string         `xml:"artifactId"`
+	Version    string         `xml:"version"`
+	Type       string         `xml:"type"`
+	Classifier string         `xml:"classifier"`
+	Scope      string         `xml:"scope"`
+	Optional   string         `xml:"optional"`
+	Exclusions []pomExclusion `xml:"exclusions>exclusion"`
+}
+
+type pomExclusion struct {
+	GroupID    string `xml:"groupId"`
+	ArtifactID string `xml:"artifactId"`
+}
+
+// Key identifies a dependency regardless of its version
+func (d POMDependency) Key() string {
+	return d.GroupID + ":" + d.ArtifactID
+}
+
+// ManagementKey identifies a dependencyManagement entry
+func (d POMDependency) ManagementKey() string {
+	return d.GroupID + ":" + d.ArtifactID + ":" + d.typeOrJar() + ":" + d.Classifier
+}
+
+func (d POMDependency) typeOrJar() string {
+	if d.Type == "" {
+		return "jar"
+	}
+	return d.Type
+}
+
+func (d POMDependency) ScopeOrCompile() string {
+	if d.Scope == "" {
+		return "compile"
+	}
+	return d.Scope
+}
+
+// pomProperties holds the free-form <properties> section
+type pomProperties map[string]string
+
+func (p *pomProperties) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*p = make(pomProperties)
+	for {
+		token, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			var value string
+			if err := d.DecodeElement(&value, &t); err != nil {
+				return err
+			}
+			(*p)[t.Name.Local] = strings.TrimSpace(value)
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// POMResolver builds effective models and dependency trees without Maven
+type POMResolver struct {
+	ctx        context.Context // of the run, for the timings of the downloads
+	repo       mavenRepository
+	extra      []mavenRepository // repositories of the active settings.xml profiles, tried after repo
+	downloaded map[string]*POMProject
+	cache      map[string]*POMProject
+	retry      bool                 // retry failed downloads, license and remediation lookups are best effort
+	profiles   []string             // activated in the local POMs, as with mvn -P
+	excluded   []ExcludedDependency // kept off the tree by exclusions in the last ResolveTree
+	unpinned   []UnpinnedDependency // version ranges resolved in the last ResolveTree
+	importing  map[string]bool      // BOMs being imported, to stop import cycles
+}
+
+// NewPOMResolver returns a resolver downloading from Maven Central or its
+// mirror in settings.xml for the run of ctx
+func NewPOMResolver(ctx context.Context) *POMResolver {
+	// Invalid settings are reported by Options.settings, Maven Central is used then
+	s := runenv.SettingsOf(ctx)
+	repo, _ := CentralRepository(s)
+	return &POMResolver{
+		ctx:        ctx,
+		repo:       repo,
+		extra:      profileRepositories(s),
+		downloaded: make(map[string]*POMProject),
+		cache:      make(map[string]*POMProject),
+		importing:  make(map[string]bool),
+	}
+}
+
+// DepNode is a dependency in the resolved tree
+type DepNode struct {
+	POMDependency
+	Licenses   []string
+	Children   []*DepNode
+	exclusions []dependencyExclusion // of the node and its ancestors
+}
+
+func ParsePOM(data []byte) (*POMProject, error) {
+	var project POMProject
+	if err := xml.Unmarshal(data, &project); err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// loadFile reads a local POM and builds its effective model
+func (r *POMResolver) loadFile(path string) (*POMProject, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read POM: %v", err)
+	}
+
+	project, err := ParsePOM(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	return r.effective(project, filepath.Dir(path))
+}
+
+// Fetch downloads a POM from the remote repository and builds its effective model
+func (r *POMResolver) Fetch(groupID, artifactID, version string) (*POMProject, error) {
+	coordinates := groupID + ":" + artifactID + ":" + version
+	if project, ok := r.cache[coordinates]; ok {
+		return project, nil
+	}
+
+	project, err := r.download(groupID, artifactID, version)
+	if err != nil {
+		return nil, err
+	}
+
+	effective, err := r.effective(project, "")
+	if err != nil {
+		return nil, err
+	}
+
+	r.cache[coordinates] = effective
+	return effective, nil
+}
+
+func (r *POMResolver) download(groupID, artifactID, version string) (*POMProject, error) {
+	coordinates := groupID + ":" + artifactID + ":" + version
+	if project, ok := r.downloaded[coordinates]; ok {
+		return project, nil
+	}
+
+	file := fmt.Sprintf("%s/%s/%s/%s-%s.pom", strings.ReplaceAll(groupID, ".", "/"), artifactID, version, artifactID, version)
+	url := r.repo.URL + "/" + file
+	data, err := r.get(r.repo, url)
+	// Internal artifacts are only published in the private repositories
+	for _, repo := range r.extra {
+		if err == nil {
+			break
+		}
+		if data, err = r.get(repo, repo.URL+"/"+file); err == nil {
+			url = repo.URL + "/" + file
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %v", url, err)
+	}
+
+	project, err := ParsePOM(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", url, err)
+	}
+
+	r.downloaded[coordinates] = project
+	return project, nil
+}
+
+// get downloads a file from a Maven repository
+func (r *POMResolver) get(repo mavenRepository, url string) ([]byte, error) {
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(r.ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		if repo.Authorization != "" {
+			req.Header.Set("Authorization", repo.Authorization)
+		} else if repo.Username != "" {
+			req.SetBasicAuth(repo.Username, repo.Password)
+		}
+		return req, nil
+	}
+	if !r.retry {
+		return runenv.FetchOnce(newRequest)
+	}
+	return runenv.Fetch(r.ctx, "Download of "+url, newRequest)
+}
+
+// Versions lists the published versions of an artifact from maven-metadata.xml
+func (r *POMResolver) Versions(groupID, artifactID string) ([]string, error) {
+	url := fmt.Sprintf("%s/%s/%s/maven-metadata.xml", r.repo.URL, strings.ReplaceAll(groupID, ".", "/"), artifactID)
+
+	data, err := r.get(r.repo, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %v", url, err)
+	}
+
+	var metadata struct {
+		Versions []string `xml:"versioning>versions>version"`
+	}
+	if err := xml.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", url, err)
+	}
+	return metadata.Versions, nil
+}
+
+// effective merges the parent chain into the project, interpolates properties
+// and imports the BOMs of dependencyManagement
+func (r *POMResolver) effective(project *POMProject, dir string) (*POMProject, error) {
+	merged, err := r.inherit(project, dir)
+	if err != nil {
+		return nil, err
+	}
+	return r.importBOMs(interpolatePOM(merged))
+}
+
+// importBOMs replaces the import entries of dependencyManagement (scope
+// import, type pom), e.g. spring-boot-dependencies, with the managed
+// dependencies of the BOMs, which may import further BOMs. As in Maven,
+// entries declared in the project or its parents win over imported ones, and
+// earlier imports over later ones.
+func (r *POMResolver) importBOMs(project *POMProject) (*POMProject, error) {
+	var managed, imports []POMDependency
+	for _, d := range project.DependencyManagement.Dependencies {
+		if d.Scope == "import" && d.typeOrJar() == "pom" {
+			imports = append(imports, d)
+		} else {
+			managed = append(managed, d)
+		}
+	}
+	if len(imports) == 0 {
+		return project, nil
+	}
+
+	declared := make(map[string]bool)
+	for _, d := range managed {
+		declared[d.ManagementKey()] = true
+	}
+	for _, bom := range imports {
+		coordinates := bom.Coordinates()
+		if r.importing[coordinates] {
+			runenv.Logger.Warnf("Skipping the import cycle of BOM %s", coordinates)
+			continue
+		}
+		r.importing[coordinates] = true
+		imported, err := r.Fetch(bom.GroupID, bom.ArtifactID, bom.Version)
+		delete(r.importing, coordinates)
+		if err != nil {
+			return nil, fmt.Errorf("failed to import BOM %s: %v", coordinates, err)
+		}
+		for _, d := range imported.DependencyManagement.Dependencies {
+			if !declared[d.ManagementKey()] {
+				declared[d.ManagementKey()] = true
+				managed = append(managed, d)
+			}
+		}
+	}
+
+	result := *project
+	result.DependencyManagement.Dependencies = managed
+	return &result, nil
+}
+
+// inherit merges the raw parent chain into the project without interpolation
+func (r *POMResolver) inherit(project *POMProject, dir string) (*POMProject, error) {
+	if dir != "" {
+		project = project.withProfiles(r.profiles)
+	}
+	result := *project
+	result.Properties = make(pomProperties)
+
+	if project.Parent == nil {
+		for k, v := range project.Properties {
+			result.Properties[k] = v
+		}
+		return &result, nil
+	}
+
+	parent, parentDir, err := r.loadParent(project.Parent, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	merged, err := r.inherit(parent, parentDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.GroupID == "" {
+		result.GroupID = merged.GroupID
+	}
+	if result.Version == "" {
+		result.Version = merged.Version
+	}
+	if len(result.Licenses) == 0 {
+		result.Licenses = merged.Licenses
+	}
+
+	for k, v := range merged.Properties {
+		result.Properties[k] = v
+	}
+	for k, v := range project.Properties {
+		result.Properties[k] = v
+	}
+
+	result.DependencyManagement.Dependencies = mergeManagedDependencies(
+		merged.DependencyManagement.Dependencies,
+		project.DependencyManagement.Dependencies)
+
+	result.Dependencies = append(append([]POMDependency{}, merged.Dependencies...), project.Dependencies...)
+
+	result.Build.Plugins = mergePlugins(merged.Build.Plugins, project.Build.Plugins)
+	result.Build.PluginManagement.Plugins = mergePlugins(
+		merged.Build.PluginManagement.Plugins,
+		project.Build.PluginManagement.Plugins)
+	result.Build.Extensions = mergePlugins(merged.Build.Extensions, project.Build.Extensions)
+	return &result, nil
+}
+
+// loadEffectivePOM returns the effective model of a Maven module: the
+// effective POM written by Maven when there is one, else the POM merged with
+// its parents by the resolver
+func (r *POMResolver) loadEffectivePOM(pomPath, effectivePomPath string) (*POMProject, error) {
+	data, err := os.ReadFile(effectivePomPath)
+	if err != nil {
+		return r.loadFile(pomPath)
+	}
+	project, err := ParsePOM(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", effectivePomPath, err)
+	}
+	return interpolatePOM(project), nil
+}
+
+// loadParent reads the parent POM from relativePath or the remote repository
+func (r *POMResolver) loadParent(parent *pomParent, dir string) (*POMProject, string, error) {
+	if project, path, ok := LocalParent(parent, dir); ok {
+		return project, filepath.Dir(path), nil
+	}
+
+	project, err := r.download(parent.GroupID, parent.ArtifactID, parent.Version)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to resolve parent %s:%s:%s: %v",
+			parent.GroupID, parent.ArtifactID, parent.Version, err)
+	}
+	return project, "", nil
+}
+
+// LocalParent reads the parent POM at relativePath of a local POM in dir and
+// returns it with its path, false when it is not there
+func LocalParent(parent *pomParent, dir string) (*POMProject, string, bool) {
+	if dir == "" {
+		return nil, "", false
+	}
+	relativePath := parent.RelativePath
+	if relativePath == "" {
+		relativePath = "../pom.xml"
+	}
+	path := filepath.Join(dir, relativePath)
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, "pom.xml")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", false
+	}
+	project, err := ParsePOM(data)
+	if err != nil || project.ArtifactID != parent.ArtifactID {
+		return nil, "", false
+	}
+	return project, path, true
+}
+
+// mergeManagedDependencies lets child entries override parent entries
+func mergeManagedDependencies(parent, child []POMDependency) []POMDependency {
+	overridden := make(map[string]bool)
+	for _, d := range child {
+		overridden[d.ManagementKey()] = true
+	}
+
+	var result []POMDependency
+	for _, d := range parent {
+		if !overridden[d.ManagementKey()] {
+			result = append(result, d)
+		}
+	}
+	return append(result, child...)
+}
+
+// mergePlugins lets child plugins override parent plugins, a child without a
+// version or configuration keeps the one of the parent
+func mergePlugins(parent, child []pomPlugin) []pomPlugin {
+	inherited := make(map[string]pomPlugin)
+	for _, p := range parent {
+		inherited[p.key()] = p
+	}
+
+	overridden := make(map[string]bool)
+	children := make([]pomPlugin, len(child))
+	for i, p := range child {
+		parent := inherited[p.key()]
+		if p.Version == "" {
+			p.Version = parent.Version
+		}
+		if p.Configuration == (pomPluginConfiguration{}) {
+			p.Configuration = parent.Configuration
+		}
+		overridden[p.key()] = true
+		children[i] = p
+	}
+
+	var result []pomPlugin
+	for _, p := range parent {
+		if !overridden[p.key()] {
+			result = append(result, p)
+		}
+	}
+	return append(result, children...)
+}
+
+var pomPropertyPattern = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// interpolatePOM replaces ${...} references in coordinates and dependencies
+func interpolatePOM(project *POMProject) *POMProject {
+	props := make(map[string]string)
+	for k, v := range project.Properties {
+		props[k] = v
+	}
+	props["project.groupId"] = project.GroupID
+	props["project.artifactId"] = project.ArtifactID
+	props["project.version"] = project.Version
+	props["pom.groupId"] = project.GroupID
+	props["pom.version"] = project.Version
+	if project.Parent != nil {
+		props["project.parent.groupId"] = project.Parent.GroupID
+		props["project.parent.version"] = project.Parent.Version
+	}
+
+	expand := func(s string) string {
+		// Nested properties are expanded a limited number of times to stop cycles
+		for i := 0; i < 10 && strings.Contains(s, "${"); i++ {
+			s = pomPropertyPattern.ReplaceAllStringFunc(s, func(m string) string {
+				if v, ok := props[m[2:len(m)-1]]; ok {
+					return v
+				}
+				return m
+			})
+		}
+		return s
+	}
+
+	expandAll := func(deps []POMDependency) []POMDependency {
+		result := make([]POMDependency, len(deps))
+		for i, d := range deps {
+			d.GroupID = expand(d.GroupID)
+			d.ArtifactID = expand(d.ArtifactID)
+			d.Version = expand(d.Version)
+			d.Type = expand(d.Type)
+			d.Classifier = expand(d.Classifier)
+			d.Scope = expand(d.Scope)
+			d.Optional = expand(d.Optional)
+			result[i] = d
+		}
+		return result
+	}
+
+	expandPlugins := func(plugins []pomPlugin) []pomPlugin {
+		result := make([]pomPlugin, len(plugins))
+		for i, p := range plugins {
+			p.GroupID = expand(p.GroupID)
+			p.ArtifactID = expand(p.ArtifactID)
+			p.Version = expand(p.Version)
+			p.Configuration.Release = expand(p.Configuration.Release)
+			p.Configuration.Source = expand(p.Configuration.Source)
+			p.Configuration.Target = expand(p.Configuration.Target)
+			p.Dependencies = expandAll(p.Dependencies)
+			result[i] = p
+		}
+		return result
+	}
+
+	result := *project
+	result.GroupID = expand(project.GroupID)
+	result.Version = expand(project.Version)
+	result.DependencyManagement.Dependencies = expandAll(project.DependencyManagement.Dependencies)
+	result.Dependencies = expandAll(project.Dependencies)
+	result.Build.Plugins = expandPlugins(project.Build.Plugins)
+	result.Build.PluginManagement.Plugins = expandPlugins(project.Build.PluginManagement.Plugins)
+	result.Build.Extensions = expandPlugins(project.Build.Extensions)
+	return &result
+}
+
+// Managed returns the dependencyManagement entries indexed by management key
+func (p *POMProject) Managed() map[string]POMDependency {
+	managed := make(map[string]POMDependency)
+	for _, d := range p.DependencyManagement.Dependencies {
+		managed[d.ManagementKey()] = d
+	}
+	return managed
+}
+
+// ResolveTree resolves the transitive dependencies of the project breadth-first,
+// the nearest declaration of an artifact wins like in Maven. Optional
+// dependencies of dependencies are left out, and exclusions apply to the
+// whole subtree of the dependency declaring them.
+func (r *POMResolver) ResolveTree(project *POMProject) []*DepNode {
+	managed := project.Managed()
+	seen := make(map[string]bool)
+	excluded := make(map[string]ExcludedDependency)
+
+	var roots []*DepNode
+	var queue []*DepNode
+
+	r.unpinned = nil
+	for _, d := range ApplyManagement(project.Dependencies, managed) {
+		if d.Scope == "test" || seen[d.Key()] {
+			continue
+		}
+		r.pinRange(&d)
+		seen[d.Key()] = true
+		node := &DepNode{POMDependency: d, exclusions: InheritExclusions(d, nil)}
+		roots = append(roots, node)
+		queue = append(queue, node)
+	}
+
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		if node.Version == "" || node.Type == "pom" {
+			continue
+		}
+
+		dependency, err := r.Fetch(node.GroupID, node.ArtifactID, node.Version)
+		if err != nil {
+			runenv.Logger.Warnf("Skipping transitive dependencies of %s:%s:%s: %v",
+				node.GroupID, node.ArtifactID, node.Version, err)
+			continue
+		}
+		node.Licenses = dependency.licenseNames()
+
+		for _, child := range ApplyManagement(dependency.Dependencies, dependency.Managed()) {
+			scope := child.ScopeOrCompile()
+			if scope != "compile" && scope != "runtime" {
+				continue
+			}
+			if child.Optional == "true" || seen[child.Key()] {
+				continue
+			}
+
+			// Managed versions of the root project override transitive versions
+			if m, ok := managed[child.ManagementKey()]; ok && m.Version != "" {
+				child.Version = m.Version
+			}
+			if node.ScopeOrCompile() != "compile" {
+				child.Scope = node.Scope
+			}
+			r.pinRange(&child)
+			if e, ok := ExcludedBy(node.exclusions, child); ok {
+				if _, ok := excluded[child.Key()]; !ok {
+					excluded[child.Key()] = NewExcludedDependency(child, e, node.Coordinates())
+				}
+				continue
+			}
+
+			seen[child.Key()] = true
+			childNode := &DepNode{POMDependency: child, exclusions: InheritExclusions(child, node.exclusions)}
+			node.Children = append(node.Children, childNode)
+			queue = append(queue, childNode)
+		}
+	}
+
+	// Artifacts excluded on one path may still be resolved through another
+	r.excluded = nil
+	for key, e := range excluded {
+		if !seen[key] {
+			r.excluded = append(r.excluded, e)
+		}
+	}
+	return roots
+}
+
+// pinRange replaces a version range with the newest release in it, as Maven
+// does, and records the range
+func (r *POMResolver) pinRange(d *POMDependency) {
+	if !isVersionRange(d.Version) {
+		return
+	}
+	version, open, err := r.resolveRange(d.GroupID, d.ArtifactID, d.Version)
+	if err != nil {
+		runenv.Logger.Warnf("Could not resolve the version range %s of %s: %v", d.Version, d.Key(), err)
+		r.unpinned = append(r.unpinned, newUnpinnedRange(d.Key(), d.Version, "", false))
+		d.Version = ""
+		return
+	}
+	r.unpinned = append(r.unpinned, newUnpinnedRange(d.Key(), d.Version, version, open))
+	d.Version = version
+}
+
+// ApplyManagement fills missing versions and scopes from dependencyManagement
+func ApplyManagement(deps []POMDependency, managed map[string]POMDependency) []POMDependency {
+	result := make([]POMDependency, len(deps))
+	for i, d := range deps {
+		if m, ok := managed[d.ManagementKey()]; ok {
+			if d.Version == "" {
+				d.Version = m.Version
+			}
+			if d.Scope == "" {
+				d.Scope = m.Scope
+			}
+			// Managed exclusions are added to the declared ones
+			if len(m.Exclusions) > 0 {
+				d.Exclusions = append(slices.Clone(d.Exclusions), m.Exclusions...)
+			}
+		}
+		result[i] = d
+	}
+	return result
+}
+
+// writeDependencyTree writes the tree in the text format of mvn dependency:tree
+func writeDependencyTree(outputPath string, project *POMProject, roots []*DepNode) error {
+	var b strings.Builder
+	packaging := project.Packaging
+	if packaging == "" {
+		packaging = "jar"
+	}
+	fmt.Fprintf(&b, "%s:%s:%s:%s\n", project.GroupID, project.ArtifactID, packaging, project.Version)
+
+	var walk func(nodes []*DepNode, prefix string)
+	walk = func(nodes []*DepNode, prefix string) {
+		for i, node := range nodes {
+			branch, indent := "+- ", "|  "
+			if i == len(nodes)-1 {
+				branch, indent = "\\- ", "   "
+			}
+			optional := ""
+			if node.Optional == "true" {
+				optional = " (optional)"
+			}
+			fmt.Fprintf(&b, "%s%s%s:%s:%s:%s:%s%s\n", prefix, branch,
+				node.GroupID, node.ArtifactID, node.typeOrJar(), node.Version, node.ScopeOrCompile(), optional)
+			walk(node.Children, prefix+indent)
+		}
+	}
+	walk(roots, "")
+
+	if err := os.WriteFile(outputPath, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write dependency tree: %v", err)
+	}
+
+	runenv.Logger.Infof("Dependency tree written to %s", outputPath)
+	return nil
+}
+
+// treeComponents flattens the dependency tree into SBOM components
+func treeComponents(roots []*DepNode) []sbom.Component {
+	var components []sbom.Component
+	var walk func(nodes []*DepNode)
+	walk = func(nodes []*DepNode) {
+		for _, node := range nodes {
+			components = append(components, sbom.Component{
+				Type:      "maven",
+				Namespace: node.GroupID,
+				Name:      node.ArtifactID,
+				Version:   node.Version,
+				Licenses:  node.Licenses,
+			})
+			walk(node.Children)
+		}
+	}
+	walk(roots)
+	return components
+}
+
+// filterScopes drops the dependencies outside the scopes, with their subtrees
+func filterScopes(nodes []*DepNode, scopes []string) []*DepNode {
+	var result []*DepNode
+	for _, node := range nodes {
+		if !sbom.ScopeIncluded(scopes, node.Scope) {
+			continue
+		}
+		node.Children = filterScopes(node.Children, scopes)
+		result = append(result, node)
+	}
+	return result
+}
+
+// ResolveNative produces the dependency tree and SBOM of a POM without Maven,
+// limited to the given scopes (all when empty). The tree is not written when
+// depsPath is empty.
+func ResolveNative(ctx context.Context, pomPath, depsPath, sbomPath string, scopes []string) error {
+	s := runenv.SettingsOf(ctx)
+	if s.Offline {
+		return fmt.Errorf("the native resolver downloads POMs from Maven Central and cannot run with --offline, use --resolver=maven")
+	}
+
+	resolver := NewPOMResolver(ctx)
+	resolver.retry = true
+	resolver.profiles = s.MavenProfiles
+	if resolver.repo.URL != mavenCentralURL {
+		runenv.Logger.Infof("Downloading POMs from mirror %s", resolver.repo.URL)
+	}
+
+	project, err := resolver.loadFile(pomPath)
+	if err != nil {
+		return err
+	}
+
+	roots := filterScopes(resolver.ResolveTree(project), scopes)
+
+	if depsPath != "" {
+		if err := writeDependencyTree(depsPath, project, roots); err != nil {
+			return err
+		}
+	}
+	if err := WriteExcludedReport(ExcludedPath(sbomPath), resolver.excluded); err != nil {
+		return err
+	}
+	if err := WriteUnpinnedReport(UnpinnedPath(sbomPath), resolver.unpinned); err != nil {
+		return err
+	}
+
+	return sbom.WriteCycloneDX(sbomPath, treeComponents(roots))
+}
@@ -0,0 +1,354 @@
+package maven
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// dep parses group:artifact:version[:scope]
+func dep(coordinates string) POMDependency {
+	parts := strings.Split(coordinates, ":")
+	d := POMDependency{GroupID: parts[0], ArtifactID: parts[1]}
+	if len(parts) > 2 {
+		d.Version = parts[2]
+	}
+	if len(parts) > 3 {
+		d.Scope = parts[3]
+	}
+	return d
+}
+
+// testPOM returns a project with the given dependencies
+func testPOM(deps ...POMDependency) *POMProject {
+	return &POMProject{GroupID: "com.example", ArtifactID: "app", Version: "1.0", Dependencies: deps}
+}
+
+// testResolver resolves from the given POMs only, keyed by group:artifact:version
+func testResolver(poms map[string]*POMProject) *POMResolver {
+	r := NewPOMResolver(context.Background())
+	for coordinates, p := range poms {
+		r.cache[coordinates] = p
+	}
+	return r
+}
+
+// formatTree prints a node per line, indented by its depth
+func formatTree(nodes []*DepNode, depth int) []string {
+	var lines []string
+	for _, n := range nodes {
+		lines = append(lines, fmt.Sprintf("%s%s %s", strings.Repeat("  ", depth), n.Coordinates(), n.ScopeOrCompile()))
+		lines = append(lines, formatTree(n.Children, depth+1)...)
+	}
+	return lines
+}
+
+func TestResolveTree(t *testing.T) {
+	optional := dep("org.example:opt:1.0")
+	optional.Optional = "true"
+	excluding := dep("org.example:a:1.0")
+	excluding.Exclusions = []pomExclusion{{GroupID: "org.example", ArtifactID: "c"}}
+
+	tests := []struct {
+		name     string
+		project  *POMProject
+		poms     map[string]*POMProject
+		want     []string
+		excluded []string
+	}{
+		{
+			name:    "first declaration wins at the same depth",
+			project: testPOM(dep("org.example:a:1.0"), dep("org.example:b:1.0")),
+			poms: map[string]*POMProject{
+				"org.example:a:1.0": testPOM(dep("org.example:c:1.0")),
+				"org.example:b:1.0": testPOM(dep("org.example:c:2.0")),
+				"org.example:c:1.0": testPOM(),
+			},
+			want: []string{
+				"org.example:a:1.0 compile",
+				"  org.example:c:1.0 compile",
+				"org.example:b:1.0 compile",
+			},
+		},
+		{
+			name:    "nearest wins over declaration order",
+			project: testPOM(dep("org.example:a:1.0"), dep("org.example:d:1.0")),
+			poms: map[string]*POMProject{
+				"org.example:a:1.0": testPOM(dep("org.example:b:1.0")),
+				"org.example:b:1.0": testPOM(dep("org.example:c:1.0")),
+				"org.example:d:1.0": testPOM(dep("org.example:c:2.0")),
+				"org.example:c:2.0": testPOM(),
+			},
+			want: []string{
+				"org.example:a:1.0 compile",
+				"  org.example:b:1.0 compile",
+				"org.example:d:1.0 compile",
+				"  org.example:c:2.0 compile",
+			},
+		},
+		{
+			name:    "direct dependency wins over transitive",
+			project: testPOM(dep("org.example:a:1.0"), dep("org.example:c:3.0")),
+			poms: map[string]*POMProject{
+				"org.example:a:1.0": testPOM(dep("org.example:c:1.0")),
+				"org.example:c:3.0": testPOM(),
+			},
+			want: []string{
+				"org.example:a:1.0 compile",
+				"org.example:c:3.0 compile",
+			},
+		},
+		{
+			name: "test, provided and optional dependencies are left out",
+			project: testPOM(
+				dep("org.example:a:1.0"),
+				dep("org.example:junit:4.13:test"),
+			),
+			poms: map[string]*POMProject{
+				"org.example:a:1.0": testPOM(
+					dep("org.example:servlet:4.0:provided"),
+					dep("org.example:mock:1.0:test"),
+					optional,
+					dep("org.example:rt:1.0:runtime"),
+				),
+				"org.example:rt:1.0": testPOM(),
+			},
+			want: []string{
+				"org.example:a:1.0 compile",
+				"  org.example:rt:1.0 runtime",
+			},
+		},
+		{
+			name: "managed versions of the project override transitive versions",
+			project: &POMProject{
+				Dependencies: []POMDependency{dep("org.example:a:1.0"), dep("org.example:b")},
+				DependencyManagement: pomDependencyManager{Dependencies: []POMDependency{
+					dep("org.example:b:2.0"),
+					dep("org.example:c:1.5"),
+				}},
+			},
+			poms: map[string]*POMProject{
+				"org.example:a:1.0": testPOM(dep("org.example:c:1.0")),
+				"org.example:b:2.0": testPOM(),
+				"org.example:c:1.5": testPOM(),
+			},
+			want: []string{
+				"org.example:a:1.0 compile",
+				"  org.example:c:1.5 compile",
+				"org.example:b:2.0 compile",
+			},
+		},
+		{
+			name:    "runtime scope is inherited",
+			project: testPOM(dep("org.example:a:1.0:runtime")),
+			poms: map[string]*POMProject{
+				"org.example:a:1.0": testPOM(dep("org.example:b:1.0")),
+				"org.example:b:1.0": testPOM(),
+			},
+			want: []string{
+				"org.example:a:1.0 runtime",
+				"  org.example:b:1.0 runtime",
+			},
+		},
+		{
+			name:    "exclusions apply to the whole subtree",
+			project: testPOM(excluding),
+			poms: map[string]*POMProject{
+				"org.example:a:1.0": testPOM(dep("org.example:b:1.0")),
+				"org.example:b:1.0": testPOM(dep("org.example:c:1.0"), dep("org.example:d:1.0")),
+				"org.example:d:1.0": testPOM(),
+			},
+			want: []string{
+				"org.example:a:1.0 compile",
+				"  org.example:b:1.0 compile",
+				"    org.example:d:1.0 compile",
+			},
+			excluded: []string{"org.example:c"},
+		},
+		{
+			name:    "excluded artifacts resolved through another path are not reported",
+			project: testPOM(excluding, dep("org.example:e:1.0")),
+			poms: map[string]*POMProject{
+				"org.example:a:1.0": testPOM(dep("org.example:b:1.0")),
+				"org.example:b:1.0": testPOM(dep("org.example:c:1.0")),
+				"org.example:e:1.0": testPOM(dep("org.example:c:2.0")),
+				"org.example:c:2.0": testPOM(),
+			},
+			want: []string{
+				"org.example:a:1.0 compile",
+				"  org.example:b:1.0 compile",
+				"org.example:e:1.0 compile",
+				"  org.example:c:2.0 compile",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := testResolver(tt.poms)
+			got := formatTree(r.ResolveTree(tt.project), 0)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ResolveTree() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+
+			var excluded []string
+			for _, e := range r.excluded {
+				excluded = append(excluded, e.Package)
+			}
+			sort.Strings(excluded)
+			if !reflect.DeepEqual(excluded, tt.excluded) {
+				t.Errorf("excluded = %v, want %v", excluded, tt.excluded)
+			}
+		})
+	}
+}
+
+func TestInterpolatePOM(t *testing.T) {
+	tests := []struct {
+		name       string
+		properties pomProperties
+		version    string
+		want       string
+	}{
+		{"property", pomProperties{"jackson.version": "2.15.2"}, "${jackson.version}", "2.15.2"},
+		{"nested property", pomProperties{"base": "2.15", "jackson.version": "${base}.2"}, "${jackson.version}", "2.15.2"},
+		{"project version", nil, "${project.version}", "1.0"},
+		{"legacy pom version", nil, "${pom.version}", "1.0"},
+		{"parent version", nil, "${project.parent.version}", "3.1.0"},
+		{"several references", pomProperties{"major": "2", "minor": "15"}, "${major}.${minor}.0", "2.15.0"},
+		{"undefined property is kept", nil, "${missing.version}", "${missing.version}"},
+		{"cycle is left unresolved", pomProperties{"a": "${b}", "b": "${a}"}, "${a}", "${a}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &POMProject{
+				Parent:     &pomParent{GroupID: "com.example", ArtifactID: "parent", Version: "3.1.0"},
+				GroupID:    "com.example",
+				ArtifactID: "app",
+				Version:    "1.0",
+				Properties: tt.properties,
+				Dependencies: []POMDependency{
+					{GroupID: "${project.groupId}", ArtifactID: "lib", Version: tt.version},
+				},
+			}
+			got := interpolatePOM(p).Dependencies[0]
+			if got.Version != tt.want {
+				t.Errorf("version = %q, want %q", got.Version, tt.want)
+			}
+			if got.GroupID != "com.example" {
+				t.Errorf("groupId = %q, want com.example", got.GroupID)
+			}
+			if p.Dependencies[0].Version != tt.version {
+				t.Errorf("interpolatePOM changed its input")
+			}
+		})
+	}
+}
+
+func TestWithProfiles(t *testing.T) {
+	p := &POMProject{
+		Properties:   pomProperties{"db.version": "1.0"},
+		Dependencies: []POMDependency{dep("org.example:core:1.0")},
+		Profiles: []pomProfile{
+			{ID: "default", Properties: pomProperties{"db.version": "2.0"}, Dependencies: []POMDependency{dep("org.example:h2:2.0")}},
+			{ID: "postgres", Dependencies: []POMDependency{dep("org.example:postgresql:42.0")}},
+			{ID: "oracle", Dependencies: []POMDependency{dep("org.example:ojdbc:19.0")}},
+		},
+	}
+	p.Profiles[0].Activation.ActiveByDefault = true
+
+	tests := []struct {
+		name      string
+		ids       []string
+		wantDeps  []string
+		wantProps string
+	}{
+		{"active by default", nil, []string{"core", "h2"}, "2.0"},
+		{"explicit profile turns off the default", []string{"postgres"}, []string{"core", "postgresql"}, "1.0"},
+		{"default listed with another profile", []string{"default", "oracle"}, []string{"core", "h2", "ojdbc"}, "2.0"},
+		{"deactivated with !", []string{"!default"}, []string{"core"}, "1.0"},
+		{"deactivated with -", []string{"-default", "oracle"}, []string{"core", "ojdbc"}, "1.0"},
+		{"unknown profile keeps the default", []string{"missing"}, []string{"core", "h2"}, "2.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := p.withProfiles(tt.ids)
+			var deps []string
+			for _, d := range got.Dependencies {
+				deps = append(deps, d.ArtifactID)
+			}
+			if !reflect.DeepEqual(deps, tt.wantDeps) {
+				t.Errorf("dependencies = %v, want %v", deps, tt.wantDeps)
+			}
+			if got.Properties["db.version"] != tt.wantProps {
+				t.Errorf("db.version = %q, want %q", got.Properties["db.version"], tt.wantProps)
+			}
+			if len(p.Dependencies) != 1 || p.Properties["db.version"] != "1.0" {
+				t.Errorf("withProfiles changed its input")
+			}
+		})
+	}
+}
+
+func TestImportBOMs(t *testing.T) {
+	bom := func(coordinates string) POMDependency {
+		d := dep(coordinates + ":import")
+		d.Type = "pom"
+		return d
+	}
+	managed := func(deps ...POMDependency) *POMProject {
+		return &POMProject{DependencyManagement: pomDependencyManager{Dependencies: deps}}
+	}
+	poms := map[string]*POMProject{
+		"org.example:bom-a:1.0": managed(dep("org.example:x:2.0"), dep("org.example:y:1.0")),
+		"org.example:bom-b:1.0": managed(dep("org.example:y:2.0"), dep("org.example:z:1.0")),
+	}
+
+	tests := []struct {
+		name    string
+		managed []POMDependency
+		want    []string
+	}{
+		{
+			name:    "no imports",
+			managed: []POMDependency{dep("org.example:x:1.0")},
+			want:    []string{"org.example:x:1.0"},
+		},
+		{
+			name:    "declared entries win over imported ones",
+			managed: []POMDependency{dep("org.example:x:1.0"), bom("org.example:bom-a:1.0")},
+			want:    []string{"org.example:x:1.0", "org.example:y:1.0"},
+		},
+		{
+			name:    "earlier imports win over later ones",
+			managed: []POMDependency{bom("org.example:bom-a:1.0"), bom("org.example:bom-b:1.0")},
+			want:    []string{"org.example:x:2.0", "org.example:y:1.0", "org.example:z:1.0"},
+		},
+		{
+			name:    "declared entries after the import still win",
+			managed: []POMDependency{bom("org.example:bom-b:1.0"), dep("org.example:z:3.0")},
+			want:    []string{"org.example:z:3.0", "org.example:y:2.0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := testResolver(poms).importBOMs(managed(tt.managed...))
+			if err != nil {
+				t.Fatalf("importBOMs() error = %v", err)
+			}
+			var coordinates []string
+			for _, d := range got.DependencyManagement.Dependencies {
+				coordinates = append(coordinates, d.Coordinates())
+			}
+			if !reflect.DeepEqual(coordinates, tt.want) {
+				t.Errorf("managed = %v, want %v", coordinates, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package maven
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+// Sources of the version of a Maven dependency
+const (
+	versionDirect     = "direct"               // declared on the dependency in the module's POM
+	versionProperty   = "property"             // a ${property}, defined in Location
+	versionManaged    = "dependencyManagement" // managed in the module's POM or a BOM it imports
+	versionParent     = "parent"               // declared or managed in a parent POM, or a BOM it imports
+	VersionTransitive = "transitive"           // not set by the project, chosen by the POMs of its dependencies
+)
+
+// VersionProvenance is where the POMs of a project set the version of a
+// Maven dependency, i.e. what to change to upgrade it
+type VersionProvenance struct {
+	Source   string `json:"source"`
+	Property string `json:"property,omitempty"` // e.g. jackson.version
+	// POM setting the version: the module's file, a local parent relative to
+	// it, or group:artifact:version of a remote parent
+	Location string `json:"location,omitempty"`
+	BOM      string `json:"bom,omitempty"` // group:artifact:version of the imported BOM managing the version
+	Via      string `json:"via,omitempty"` // direct dependency whose version this is, for transitive packages
+}
+
+func (p VersionProvenance) String() string {
+	var s string
+	switch {
+	case p.Source == versionProperty:
+		s = "property " + p.Property + " in " + p.Location
+	case p.BOM != "":
+		s = "BOM " + p.BOM + " imported in " + p.Location
+	case p.Source == versionManaged:
+		s = "dependencyManagement in " + p.Location
+	case p.Source == versionParent:
+		s = "parent " + p.Location
+	case p.Source == versionDirect:
+		s = "dependency in " + p.Location
+	default:
+		s = "dependency POMs"
+	}
+	if p.Via != "" {
+		s += " (for " + p.Via + ")"
+	}
+	return s
+}
+
+// componentProvenance is the version provenance of a component of the SBOM
+type componentProvenance struct {
+	Package string `json:"package"` // group:artifact
+	Version string `json:"version"`
+	VersionProvenance
+}
+
+// pomSource is a raw POM of the inheritance chain of a module
+type pomSource struct {
+	project  *POMProject
+	location string
+	module   bool // the scanned POM itself
+}
+
+// versionTracer indexes where the POMs of a module set the versions of the
+// dependencies, by group:artifact
+type versionTracer struct {
+	properties map[string]string // property to the nearest POM defining it
+	declared   map[string]VersionProvenance
+	managed    map[string]VersionProvenance
+}
+
+// ProvenancePath returns where the version provenance of an SBOM is written
+func ProvenancePath(sbomPath string) string {
+	return filepath.Join(filepath.Dir(sbomPath), "sbom-provenance.json")
+}
+
+// traceVersions walks the raw POMs of a module up its parents, whose
+// properties, dependencies and dependencyManagement entries are not merged
+// yet, and records which of them sets each version. Declarations of a POM win
+// over those of its parents, and declared or managed entries over the ones
+// of imported BOMs, as in the effective POM. The chain stops at a parent that
+// cannot be downloaded, and at the first remote one with --offline.
+func (r *POMResolver) traceVersions(pomPath string) (*versionTracer, error) {
+	data, err := os.ReadFile(pomPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read POM: %v", err)
+	}
+	project, err := ParsePOM(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", pomPath, err)
+	}
+
+	moduleDir := filepath.Dir(pomPath)
+	chain := []pomSource{{project: project.withProfiles(r.profiles), location: filepath.Base(pomPath), module: true}}
+	for current, dir := project, moduleDir; current.Parent != nil; {
+		ref := current.Parent
+		coordinates := ref.GroupID + ":" + ref.ArtifactID + ":" + ref.Version
+		parent, path, ok := LocalParent(ref, dir)
+		if ok {
+			location, err := filepath.Rel(moduleDir, path)
+			if err != nil {
+				location = path
+			}
+			chain = append(chain, pomSource{project: parent.withProfiles(r.profiles), location: filepath.ToSlash(location)})
+			current, dir = parent, filepath.Dir(path)
+			continue
+		}
+		if runenv.SettingsOf(r.ctx).Offline {
+			runenv.Logger.Infof("Versions set in parent %s are not traced with --offline", coordinates)
+			break
+		}
+		if parent, err = r.download(ref.GroupID, ref.ArtifactID, ref.Version); err != nil {
+			runenv.Logger.Warnf("Versions set in parent %s are not traced: %v", coordinates, err)
+			break
+		}
+		chain = append(chain, pomSource{project: parent, location: coordinates})
+		current, dir = parent, ""
+	}
+
+	t := &versionTracer{
+		properties: make(map[string]string),
+		declared:   make(map[string]VersionProvenance),
+		managed:    make(map[string]VersionProvenance),
+	}
+	for _, s := range chain {
+		for name := range s.project.Properties {
+			if _, ok := t.properties[name]; !ok {
+				t.properties[name] = s.location
+			}
+		}
+	}
+
+	var imports []POMDependency
+	var importedBy []pomSource
+	for _, s := range chain {
+		for _, d := range s.project.Dependencies {
+			if _, ok := t.declared[d.Key()]; !ok {
+				t.declared[d.Key()] = t.classify(d.Version, s, versionDirect)
+			}
+		}
+		for _, d := range s.project.DependencyManagement.Dependencies {
+			if d.Scope == "import" && d.typeOrJar() == "pom" {
+				imports = append(imports, d)
+				importedBy = append(importedBy, s)
+			} else if _, ok := t.managed[d.Key()]; !ok {
+				t.managed[d.Key()] = t.classify(d.Version, s, versionManaged)
+			}
+		}
+	}
+	if len(imports) == 0 || runenv.SettingsOf(r.ctx).Offline {
+		return t, nil
+	}
+
+	// The coordinates of BOMs may use the properties of the whole chain
+	merged := &POMProject{Parent: project.Parent, Properties: make(pomProperties)}
+	for i := len(chain) - 1; i >= 0; i-- {
+		p := chain[i].project
+		for k, v := range p.Properties {
+			merged.Properties[k] = v
+		}
+		if p.GroupID != "" {
+			merged.GroupID = p.GroupID
+		}
+		if p.Version != "" {
+			merged.Version = p.Version
+		}
+	}
+	merged.DependencyManagement.Dependencies = imports
+	imports = interpolatePOM(merged).DependencyManagement.Dependencies
+
+	for i, ref := range imports {
+		bom, err := r.Fetch(ref.GroupID, ref.ArtifactID, ref.Version)
+		if err != nil {
+			runenv.Logger.Warnf("Versions managed in BOM %s are not traced: %v", ref.Coordinates(), err)
+			continue
+		}
+		source := versionManaged
+		if !importedBy[i].module {
+			source = versionParent
+		}
+		for _, d := range bom.DependencyManagement.Dependencies {
+			if _, ok := t.managed[d.Key()]; !ok {
+				t.managed[d.Key()] = VersionProvenance{Source: source, Location: importedBy[i].location, BOM: ref.Coordinates()}
+			}
+		}
+	}
+	return t, nil
+}
+
+// classify returns the provenance of a version declared in a POM of the
+// chain, empty when the declaration has no version
+func (t *versionTracer) classify(version string, s pomSource, source string) VersionProvenance {
+	if version == "" {
+		return VersionProvenance{}
+	}
+	// Built-in properties such as project.version are set by the POM itself
+	if m := pomPropertyPattern.FindStringSubmatch(version); m != nil {
+		if location, ok := t.properties[m[1]]; ok {
+			return VersionProvenance{Source: versionProperty, Property: m[1], Location: location}
+		}
+	}
+	if !s.module {
+		source = versionParent
+	}
+	return VersionProvenance{Source: source, Location: s.location}
+}
+
+// provenance returns where the POMs set the version of a package, the
+// declared version of a direct dependency wins over dependencyManagement
+func (t *versionTracer) provenance(pkg string) VersionProvenance {
+	if p := t.declared[pkg]; p.Source != "" {
+		return p
+	}
+	if p := t.managed[pkg]; p.Source != "" {
+		return p
+	}
+	return VersionProvenance{Source: VersionTransitive}
+}
+
+// WriteVersionProvenance traces where the POMs of a Maven module set the
+// versions of the components in its license report, and writes them next to
+// the SBOM
+func WriteVersionProvenance(ctx context.Context, pomPath, sbomPath string) error {
+	report, err := ReadLicenseReport(LicensesPath(sbomPath))
+	if err != nil {
+		return err
+	}
+
+	resolver := NewPOMResolver(ctx)
+	resolver.retry = true
+	resolver.profiles = runenv.SettingsOf(ctx).MavenProfiles
+	tracer, err := resolver.traceVersions(pomPath)
+	if err != nil {
+		return err
+	}
+
+	var components []componentProvenance
+	counts := make(map[string]int)
+	for _, c := range report.Components {
+		if !strings.HasPrefix(c.PURL, "pkg:maven/") {
+			continue
+		}
+		p := tracer.provenance(c.Package)
+		counts[p.Source]++
+		components = append(components, componentProvenance{Package: c.Package, Version: c.Version, VersionProvenance: p})
+	}
+
+	path := ProvenancePath(sbomPath)
+	if err := writeProvenanceReport(path, components); err != nil {
+		return err
+	}
+	runenv.Logger.Infof("Traced the versions of %d components (%d from properties, %d managed, %d from parents, %d direct) to %s",
+		len(components), counts[versionProperty], counts[versionManaged], counts[versionParent], counts[versionDirect], path)
+	return nil
+}
+
+func writeProvenanceReport(path string, components []componentProvenance) error {
+	if components == nil {
+		components = []componentProvenance{}
+	}
+	sort.SliceStable(components, func(i, j int) bool {
+		if components[i].Package != components[j].Package {
+			return components[i].Package < components[j].Package
+		}
+		return components[i].Version < components[j].Version
+	})
+	data, err := json.MarshalIndent(components, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode version provenance: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write version provenance: %v", err)
+	}
+	return nil
+}
+
+func ReadProvenanceReport(path string) ([]componentProvenance, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read version provenance: %v", err)
+	}
+	var components []componentProvenance
+	if err := json.Unmarshal(data, &components); err != nil {
+		return nil, fmt.Errorf("failed to parse version provenance %s: %v", path, err)
+	}
+	return components, nil
+}
@@ -0,0 +1,340 @@
+package maven
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+// Kinds of versions that do not pin a single artifact
+const (
+	unpinnedSnapshot = "snapshot" // -SNAPSHOT, replaced by every deployment
+	unpinnedRange    = "range"    // Maven version range or Gradle dynamic version
+)
+
+// UnpinnedDependency is a dependency whose declared version may resolve to a
+// different artifact in the next build, a risk for reproducible builds and
+// for the SBOM describing what is deployed
+type UnpinnedDependency struct {
+	Package  string `json:"package"`            // group:artifact
+	Version  string `json:"version"`            // as declared: the SNAPSHOT, the range or the dynamic version
+	Resolved string `json:"resolved,omitempty"` // timestamped snapshot or version the range currently selects
+	Kind     string `json:"kind"`               // snapshot or range
+	Open     bool   `json:"open,omitempty"`     // range without an upper bound, e.g. [1.0,) or 1.+
+	Message  string `json:"message"`
+}
+
+// Timestamped SNAPSHOT versions of a remote repository, e.g. 1.0-20240101.120000-3
+var timestampedSnapshotPattern = regexp.MustCompile(`-\d{8}\.\d{6}-\d+$`)
+
+// isSnapshot reports whether a Maven version is a SNAPSHOT
+func isSnapshot(version string) bool {
+	return strings.HasSuffix(version, "-SNAPSHOT") || timestampedSnapshotPattern.MatchString(version)
+}
+
+// isVersionRange reports whether a Maven version is a range such as [1.0,2.0)
+func isVersionRange(version string) bool {
+	return strings.HasPrefix(version, "[") || strings.HasPrefix(version, "(")
+}
+
+// isDynamicVersion reports whether a Gradle version is resolved at build
+// time: a range, a prefix such as 1.+ or latest.release
+func isDynamicVersion(version string) bool {
+	return isVersionRange(version) || strings.HasSuffix(version, "+") || strings.HasPrefix(version, "latest.")
+}
+
+// versionInterval is one set of a Maven version range, an empty bound is
+// unbounded
+type versionInterval struct {
+	lower, upper                   string
+	lowerInclusive, upperInclusive bool
+}
+
+// parseVersionRange parses a Maven version range such as [1.0,2.0),
+// (,1.0],[1.2,) or [1.5]
+func parseVersionRange(spec string) ([]versionInterval, error) {
+	var intervals []versionInterval
+	rest := strings.TrimSpace(spec)
+	for rest != "" {
+		if rest[0] != '[' && rest[0] != '(' {
+			return nil, fmt.Errorf("invalid version range: %s", spec)
+		}
+		end := strings.IndexAny(rest, "])")
+		if end < 0 {
+			return nil, fmt.Errorf("invalid version range: %s", spec)
+		}
+		interval := versionInterval{lowerInclusive: rest[0] == '[', upperInclusive: rest[end] == ']'}
+		lower, upper, bounded := strings.Cut(rest[1:end], ",")
+		interval.lower, interval.upper = strings.TrimSpace(lower), strings.TrimSpace(upper)
+		if !bounded {
+			// [1.5] is exactly 1.5
+			interval.upper = interval.lower
+		}
+		intervals = append(intervals, interval)
+		rest = strings.TrimPrefix(strings.TrimSpace(rest[end+1:]), ",")
+	}
+	if len(intervals) == 0 {
+		return nil, fmt.Errorf("invalid version range: %s", spec)
+	}
+	return intervals, nil
+}
+
+// contains reports whether a version is in the interval
+func (i versionInterval) contains(version string) bool {
+	if i.lower != "" {
+		c := sbom.CompareVersions(version, i.lower)
+		if c < 0 || (c == 0 && !i.lowerInclusive) {
+			return false
+		}
+	}
+	if i.upper != "" {
+		c := sbom.CompareVersions(version, i.upper)
+		if c > 0 || (c == 0 && !i.upperInclusive) {
+			return false
+		}
+	}
+	return true
+}
+
+// openRange reports whether a range has no upper bound
+func openRange(intervals []versionInterval) bool {
+	for _, i := range intervals {
+		if i.upper == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveRange returns the newest published release in a version range, as
+// Maven selects it
+func (r *POMResolver) resolveRange(groupID, artifactID, spec string) (string, bool, error) {
+	intervals, err := parseVersionRange(spec)
+	if err != nil {
+		return "", false, err
+	}
+	versions, err := r.Versions(groupID, artifactID)
+	if err != nil {
+		return "", false, err
+	}
+	selected := ""
+	for _, v := range versions {
+		if isSnapshot(v) || (selected != "" && sbom.CompareVersions(v, selected) <= 0) {
+			continue
+		}
+		for _, i := range intervals {
+			if i.contains(v) {
+				selected = v
+				break
+			}
+		}
+	}
+	if selected == "" {
+		return "", false, fmt.Errorf("no version of %s:%s in %s", groupID, artifactID, spec)
+	}
+	return selected, openRange(intervals), nil
+}
+
+// snapshotVersion returns the timestamped version the SNAPSHOT currently
+// points to, from the maven-metadata.xml of the version in the repositories
+func (r *POMResolver) snapshotVersion(groupID, artifactID, version string) (string, error) {
+	file := fmt.Sprintf("%s/%s/%s/maven-metadata.xml", strings.ReplaceAll(groupID, ".", "/"), artifactID, version)
+	var err error
+	for _, repo := range append([]mavenRepository{r.repo}, r.extra...) {
+		var data []byte
+		if data, err = r.get(repo, repo.URL+"/"+file); err != nil {
+			continue
+		}
+		var metadata struct {
+			Timestamp   string `xml:"versioning>snapshot>timestamp"`
+			BuildNumber string `xml:"versioning>snapshot>buildNumber"`
+		}
+		if err = xml.Unmarshal(data, &metadata); err != nil {
+			continue
+		}
+		if metadata.Timestamp == "" {
+			return "", fmt.Errorf("no snapshot deployed for %s:%s:%s", groupID, artifactID, version)
+		}
+		return strings.TrimSuffix(version, "-SNAPSHOT") + "-" + metadata.Timestamp + "-" + metadata.BuildNumber, nil
+	}
+	return "", err
+}
+
+// newUnpinnedRange returns the record of a version range or dynamic version
+func newUnpinnedRange(pkg, spec, resolved string, open bool) UnpinnedDependency {
+	u := UnpinnedDependency{Package: pkg, Version: spec, Resolved: resolved, Kind: unpinnedRange, Open: open}
+	u.Message = "version range " + spec
+	if open {
+		u.Message = "open " + u.Message
+	}
+	if resolved != "" {
+		u.Message += " currently resolves to " + resolved
+	}
+	return u
+}
+
+// UnpinnedPath returns where the unpinned dependencies of an SBOM are written
+func UnpinnedPath(sbomPath string) string {
+	return filepath.Join(filepath.Dir(sbomPath), "sbom-unpinned.json")
+}
+
+// UnpinnedReportFor returns the unpinned dependencies that belong to a
+// license report: aggregated-unpinned.json for aggregated-licenses.json, else
+// sbom-unpinned.json
+func UnpinnedReportFor(licensesPath string) string {
+	if filepath.Base(licensesPath) == "aggregated-licenses.json" {
+		return filepath.Join(filepath.Dir(licensesPath), "aggregated-unpinned.json")
+	}
+	return filepath.Join(filepath.Dir(licensesPath), "sbom-unpinned.json")
+}
+
+// CheckUnpinnedVersions flags the SNAPSHOT components of a Maven or Gradle
+// SBOM and the version ranges they were resolved from, with what they
+// currently point to. Ranges are recorded by the native resolver, read from
+// the effective POM after Maven (direct and managed dependencies) and from
+// the requested versions of the Gradle dependency tree. The dependencies are
+// written next to the SBOM and shown in the reports; an unpinned policy rule
+// fails the scan on them.
+func CheckUnpinnedVersions(ctx context.Context, sbomPath string) error {
+	report, err := ReadLicenseReport(LicensesPath(sbomPath))
+	if err != nil {
+		return err
+	}
+	resolved := make(map[string]string)
+	for _, c := range report.Components {
+		resolved[c.Package] = c.Version
+	}
+
+	path := UnpinnedPath(sbomPath)
+	unpinned, _ := ReadUnpinnedReport(path)
+	unpinned = append(unpinned, declaredRanges(filepath.Dir(sbomPath), resolved)...)
+
+	resolver := NewPOMResolver(ctx)
+	failed := 0
+	for _, c := range report.Components {
+		if !strings.HasPrefix(c.PURL, "pkg:maven/") || !isSnapshot(c.Version) {
+			continue
+		}
+		u := UnpinnedDependency{Package: c.Package, Version: c.Version, Kind: unpinnedSnapshot}
+		u.Message = "SNAPSHOT version, the artifact changes with every deployment"
+		groupID, artifactID, _ := strings.Cut(c.Package, ":")
+		switch {
+		case timestampedSnapshotPattern.MatchString(c.Version):
+			u.Resolved = c.Version
+		case !runenv.SettingsOf(ctx).Offline:
+			if version, err := resolver.snapshotVersion(groupID, artifactID, c.Version); err == nil {
+				u.Resolved = version
+				u.Message += ", currently " + version
+			} else {
+				failed++
+			}
+		}
+		unpinned = append(unpinned, u)
+	}
+	if failed > 0 {
+		runenv.Logger.Warnf("Could not look up the deployments of %d SNAPSHOT versions", failed)
+	}
+
+	unpinned = UniqueUnpinned(unpinned)
+	if err := WriteUnpinnedReport(path, unpinned); err != nil {
+		return err
+	}
+	if len(unpinned) > 0 {
+		runenv.Logger.Warnf("%d dependencies have SNAPSHOT versions or version ranges, see %s", len(unpinned), path)
+	}
+	return nil
+}
+
+// declaredRanges returns the version ranges of the effective POM, or the
+// dynamic versions of the Gradle dependency tree, in dir
+func declaredRanges(dir string, resolved map[string]string) []UnpinnedDependency {
+	var ranges []UnpinnedDependency
+	if data, err := os.ReadFile(filepath.Join(dir, "effective-pom.xml")); err == nil {
+		if project, err := ParsePOM(data); err == nil {
+			deps := append(append([]POMDependency{}, project.Dependencies...), project.DependencyManagement.Dependencies...)
+			for _, d := range deps {
+				if !isVersionRange(d.Version) {
+					continue
+				}
+				intervals, err := parseVersionRange(d.Version)
+				ranges = append(ranges, newUnpinnedRange(d.Key(), d.Version, resolved[d.Key()], err == nil && openRange(intervals)))
+			}
+		}
+	}
+
+	graph, err := sbom.ReadDependencyTree(filepath.Join(dir, "deps-tree.txt"))
+	if err != nil || graph.Format != "gradle" {
+		return ranges
+	}
+	var walk func(nodes []*sbom.TreeNode)
+	walk = func(nodes []*sbom.TreeNode) {
+		for _, n := range nodes {
+			if n.Requested != "" && isDynamicVersion(n.Requested) {
+				open := !isVersionRange(n.Requested)
+				if intervals, err := parseVersionRange(n.Requested); err == nil {
+					open = openRange(intervals)
+				}
+				ranges = append(ranges, newUnpinnedRange(n.Package, n.Requested, n.Version, open))
+			}
+			walk(n.Children)
+		}
+	}
+	walk(graph.Dependencies)
+	return ranges
+}
+
+// UniqueUnpinned drops repeated entries, e.g. of a dependency declared in
+// several Gradle configurations
+func UniqueUnpinned(unpinned []UnpinnedDependency) []UnpinnedDependency {
+	seen := make(map[string]bool)
+	var result []UnpinnedDependency
+	for _, u := range unpinned {
+		key := u.Kind + ":" + u.Package + "@" + u.Version
+		if !seen[key] {
+			seen[key] = true
+			result = append(result, u)
+		}
+	}
+	return result
+}
+
+func WriteUnpinnedReport(path string, unpinned []UnpinnedDependency) error {
+	if unpinned == nil {
+		unpinned = []UnpinnedDependency{}
+	}
+	sort.SliceStable(unpinned, func(i, j int) bool {
+		if unpinned[i].Package != unpinned[j].Package {
+			return unpinned[i].Package < unpinned[j].Package
+		}
+		return unpinned[i].Version < unpinned[j].Version
+	})
+	data, err := json.MarshalIndent(unpinned, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode unpinned dependencies: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write unpinned dependencies: %v", err)
+	}
+	return nil
+}
+
+func ReadUnpinnedReport(path string) ([]UnpinnedDependency, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read unpinned dependencies: %v", err)
+	}
+	var unpinned []UnpinnedDependency
+	if err := json.Unmarshal(data, &unpinned); err != nil {
+		return nil, fmt.Errorf("failed to parse unpinned dependencies %s: %v", path, err)
+	}
+	return unpinned, nil
+}
@@ -0,0 +1,154 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// findingChange is a vulnerability present in both scans whose details differ
+type findingChange struct {
+	Baseline scan.Finding `json:"baseline"`
+	Current  scan.Finding `json:"current"`
+	Changes  []string     `json:"changes"`
+}
+
+// FindingsDiff compares the findings of a scan with a baseline
+type FindingsDiff struct {
+	Baseline  string          `json:"baseline"`
+	New       []scan.Finding  `json:"new"`
+	Fixed     []scan.Finding  `json:"fixed"`
+	Changed   []findingChange `json:"changed"`
+	Unchanged int             `json:"unchanged"`
+}
+
+// baseline holds the findings of a previous scan given with --baseline
+type BaselineFindings struct {
+	Path     string
+	Findings []scan.Finding
+}
+
+// LoadFindingsFile reads the findings of a scan, e.g. a baseline: a findings
+// file (sbom-findings.json) or an aggregated report
+func LoadFindingsFile(path string) ([]scan.Finding, error) {
+	return scan.FindingsFrom(path).WithoutSuppressions().Collect()
+}
+
+// sameVulnerability reports whether two findings are the same vulnerability
+// of the same package, regardless of the package version
+func sameVulnerability(a, b scan.Finding) bool {
+	return a.Package == b.Package && scan.SharesID(a, b)
+}
+
+// InBaseline reports whether the finding is in the baseline
+func InBaseline(baseline []scan.Finding, f scan.Finding) bool {
+	for _, b := range baseline {
+		if sameVulnerability(f, b) {
+			return true
+		}
+	}
+	return false
+}
+
+// DiffFindings matches current findings against the baseline. A baseline
+// finding of the same package version is preferred, so an upgraded package
+// that is still affected shows up as changed. The current findings are
+// streamed, only the baseline is held in memory.
+func DiffFindings(baseline []scan.Finding, current scan.FindingSource) (FindingsDiff, error) {
+	diff := FindingsDiff{New: []scan.Finding{}, Fixed: []scan.Finding{}, Changed: []findingChange{}}
+	matched := make([]bool, len(baseline))
+
+	err := current(func(f scan.Finding) error {
+		match := -1
+		for i, b := range baseline {
+			if matched[i] || !sameVulnerability(f, b) {
+				continue
+			}
+			if match == -1 || b.Version == f.Version {
+				match = i
+			}
+		}
+		if match == -1 {
+			diff.New = append(diff.New, f)
+			return nil
+		}
+
+		matched[match] = true
+		if changes := findingChanges(baseline[match], f); len(changes) > 0 {
+			diff.Changed = append(diff.Changed, findingChange{Baseline: baseline[match], Current: f, Changes: changes})
+		} else {
+			diff.Unchanged++
+		}
+		return nil
+	})
+	if err != nil {
+		return diff, err
+	}
+
+	for i, b := range baseline {
+		if !matched[i] {
+			diff.Fixed = append(diff.Fixed, b)
+		}
+	}
+
+	scan.SortFindings(diff.New)
+	scan.SortFindings(diff.Fixed)
+	return diff, nil
+}
+
+// findingChanges describes how a finding differs from its baseline
+func findingChanges(old, cur scan.Finding) []string {
+	var changes []string
+	if old.Version != cur.Version {
+		changes = append(changes, fmt.Sprintf("version %s -> %s", old.Version, cur.Version))
+	}
+	if old.Severity != cur.Severity {
+		changes = append(changes, fmt.Sprintf("severity %s -> %s", old.Severity, cur.Severity))
+	}
+	if old.Score != cur.Score {
+		changes = append(changes, fmt.Sprintf("score %.1f -> %.1f", old.Score, cur.Score))
+	}
+	if strings.Join(old.FixedVersions, ",") != strings.Join(cur.FixedVersions, ",") {
+		changes = append(changes, fmt.Sprintf("fixed in %s -> %s", listOrNone(old.FixedVersions), listOrNone(cur.FixedVersions)))
+	}
+	return changes
+}
+
+func listOrNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ", ")
+}
+
+// WriteFindingsDiff compares the findings at FindingsPath (a findings file or
+// an aggregated report) with the baseline and writes the difference to DiffPath
+func WriteFindingsDiff(findingsPath, diffPath string, base *BaselineFindings, rules []scan.IgnoreRule) error {
+	known, _ := scan.ApplySuppressions(base.Findings, rules)
+	diff, err := DiffFindings(known, scan.FindingsFrom(findingsPath).SuppressedBy(rules).Where(scan.ActiveFinding))
+	if err != nil {
+		return err
+	}
+	diff.Baseline = base.Path
+
+	data, err := json.MarshalIndent(diff, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode diff: %v", err)
+	}
+	if err := os.WriteFile(diffPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write diff: %v", err)
+	}
+
+	runenv.Logger.Infof("Compared with baseline: %d new, %d fixed, %d changed, %d unchanged (%s)",
+		len(diff.New), len(diff.Fixed), len(diff.Changed), diff.Unchanged, diffPath)
+	return nil
+}
+
+// DiffPath returns where the baseline comparison of a findings file is written
+func DiffPath(findingsPath string) string {
+	return strings.TrimSuffix(findingsPath, ".json") + "-diff.json"
+}
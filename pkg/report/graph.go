@@ -0,0 +1,336 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// graphRenderer formats the dependency graph, ext is the file extension
+type graphRenderer struct {
+	ext    string
+	render func(g *exportGraph) (string, error)
+}
+
+// graphRenderers renders the dependency graph for --graph
+var graphRenderers = map[string]graphRenderer{
+	"dot":     {ext: ".dot", render: renderDOTGraph},
+	"mermaid": {ext: ".mmd", render: renderMermaidGraph},
+	"graphml": {ext: ".graphml", render: renderGraphML},
+}
+
+// exportGraph is the dependency tree with every package version as a single node
+type exportGraph struct {
+	nodes []*graphNode
+	edges []graphEdge
+}
+
+type graphNode struct {
+	id       string
+	pkg      string
+	version  string
+	root     bool
+	findings []scan.Finding // active findings of the package version
+}
+
+type graphEdge struct {
+	from, to string
+	scope    string
+	optional bool
+}
+
+// label is the package and version of the node
+func (n *graphNode) label() string {
+	if n.version == "" {
+		return n.pkg
+	}
+	return n.pkg + " " + n.version
+}
+
+// severity is the highest severity of the node's findings
+func (n *graphNode) severity() string {
+	severity := ""
+	for _, f := range n.findings {
+		if severity == "" || scan.SeverityRank(f.Severity) > scan.SeverityRank(severity) {
+			severity = f.Severity
+		}
+	}
+	return severity
+}
+
+// ids lists the vulnerability IDs of the node's findings
+func (n *graphNode) ids() []string {
+	var ids []string
+	for _, f := range n.findings {
+		ids = append(ids, f.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// ParseGraphFormats splits and validates the --graph flag value
+func ParseGraphFormats(value string) ([]string, error) {
+	var formats []string
+	for _, format := range strings.Split(value, ",") {
+		format = strings.ToLower(strings.TrimSpace(format))
+		if format == "" {
+			continue
+		}
+		if _, ok := graphRenderers[format]; !ok {
+			return nil, fmt.Errorf("unknown graph format: %s (expected dot, mermaid or graphml)", format)
+		}
+		formats = append(formats, format)
+	}
+	return formats, nil
+}
+
+// graphBasePath returns where the dependency graph is written, without extension
+func graphBasePath(depsPath string) string {
+	return filepath.Join(filepath.Dir(depsPath), "deps-graph")
+}
+
+// newExportGraph merges repeated package versions of the tree into single
+// nodes and attaches the findings to the vulnerable ones. Entries that are
+// not resolved (conflict losers, constraints) are left out.
+func newExportGraph(tree *sbom.DependencyGraph, findings []scan.Finding) *exportGraph {
+	vulnerable := make(map[string][]scan.Finding)
+	for _, f := range findings {
+		key := f.Package + "@" + f.Version
+		vulnerable[key] = append(vulnerable[key], f)
+	}
+
+	g := &exportGraph{}
+	nodes := make(map[string]*graphNode)
+	edges := make(map[string]bool)
+
+	node := func(pkg, version string) *graphNode {
+		key := pkg + "@" + version
+		if n, ok := nodes[key]; ok {
+			return n
+		}
+		n := &graphNode{id: fmt.Sprintf("n%d", len(g.nodes)), pkg: pkg, version: version, findings: vulnerable[key]}
+		nodes[key] = n
+		g.nodes = append(g.nodes, n)
+		return n
+	}
+
+	root := &graphNode{id: "root", pkg: "project", root: true}
+	if tree.Project != nil {
+		root.pkg, root.version = tree.Project.Package, tree.Project.Version
+	}
+	nodes[root.pkg+"@"+root.version] = root
+	g.nodes = append(g.nodes, root)
+
+	var walk func(parent *graphNode, children []*sbom.TreeNode)
+	walk = func(parent *graphNode, children []*sbom.TreeNode) {
+		for _, child := range children {
+			if !child.Resolved() {
+				continue
+			}
+			n := node(child.Package, child.Version)
+			key := parent.id + " " + n.id
+			if !edges[key] {
+				edges[key] = true
+				g.edges = append(g.edges, graphEdge{from: parent.id, to: n.id, scope: child.Scope, optional: child.Optional})
+			}
+			walk(n, child.Children)
+		}
+	}
+	walk(root, tree.Dependencies)
+	return g
+}
+
+func renderDOTGraph(g *exportGraph) (string, error) {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+	var b strings.Builder
+	b.WriteString("digraph dependencies {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, fontname=\"Helvetica\", fontsize=10];\n")
+	for _, n := range g.nodes {
+		label := escape.Replace(n.pkg)
+		if n.version != "" {
+			label += `\n` + escape.Replace(n.version)
+		}
+		attrs := []string{`label="` + label + `"`}
+		if n.root {
+			attrs = append(attrs, "style=bold")
+		}
+		if len(n.findings) > 0 {
+			attrs = append(attrs, `style=filled`, `color="#c0392b"`, `fillcolor="#f8d7da"`,
+				`tooltip="`+escape.Replace(n.severity()+": "+strings.Join(n.ids(), ", "))+`"`)
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", n.id, strings.Join(attrs, ", "))
+	}
+	for _, e := range g.edges {
+		if e.optional {
+			fmt.Fprintf(&b, "  %s -> %s [style=dashed];\n", e.from, e.to)
+		} else {
+			fmt.Fprintf(&b, "  %s -> %s;\n", e.from, e.to)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String(), nil
+}
+
+func renderMermaidGraph(g *exportGraph) (string, error) {
+	escape := strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;")
+
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	var vulnerable []string
+	for _, n := range g.nodes {
+		label := escape.Replace(n.pkg)
+		if n.version != "" {
+			label += "<br/>" + escape.Replace(n.version)
+		}
+		if len(n.findings) > 0 {
+			label += "<br/>" + escape.Replace(n.severity())
+			vulnerable = append(vulnerable, n.id)
+		}
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", n.id, label)
+	}
+	for _, e := range g.edges {
+		arrow := "-->"
+		if e.optional {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&b, "  %s %s %s\n", e.from, arrow, e.to)
+	}
+	if len(vulnerable) > 0 {
+		b.WriteString("  classDef vulnerable fill:#f8d7da,stroke:#c0392b,color:#721c24\n")
+		fmt.Fprintf(&b, "  class %s vulnerable\n", strings.Join(vulnerable, ","))
+	}
+	return b.String(), nil
+}
+
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+func renderGraphML(g *exportGraph) (string, error) {
+	doc := graphMLDocument{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "label", For: "node", Name: "label", Type: "string"},
+			{ID: "package", For: "node", Name: "package", Type: "string"},
+			{ID: "version", For: "node", Name: "version", Type: "string"},
+			{ID: "vulnerable", For: "node", Name: "vulnerable", Type: "boolean"},
+			{ID: "severity", For: "node", Name: "severity", Type: "string"},
+			{ID: "vulnerabilities", For: "node", Name: "vulnerabilities", Type: "string"},
+			{ID: "color", For: "node", Name: "color", Type: "string"},
+			{ID: "scope", For: "edge", Name: "scope", Type: "string"},
+			{ID: "optional", For: "edge", Name: "optional", Type: "boolean"},
+		},
+		Graph: graphMLGraph{ID: "dependencies", EdgeDefault: "directed"},
+	}
+
+	for _, n := range g.nodes {
+		node := graphMLNode{ID: n.id, Data: []graphMLData{
+			{Key: "label", Value: n.label()},
+			{Key: "package", Value: n.pkg},
+			{Key: "version", Value: n.version},
+			{Key: "vulnerable", Value: fmt.Sprint(len(n.findings) > 0)},
+		}}
+		if len(n.findings) > 0 {
+			node.Data = append(node.Data,
+				graphMLData{Key: "severity", Value: n.severity()},
+				graphMLData{Key: "vulnerabilities", Value: strings.Join(n.ids(), ",")},
+				graphMLData{Key: "color", Value: "#c0392b"})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, node)
+	}
+	for _, e := range g.edges {
+		edge := graphMLEdge{Source: e.from, Target: e.to}
+		if e.scope != "" {
+			edge.Data = append(edge.Data, graphMLData{Key: "scope", Value: e.scope})
+		}
+		if e.optional {
+			edge.Data = append(edge.Data, graphMLData{Key: "optional", Value: "true"})
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, edge)
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode GraphML: %v", err)
+	}
+	return xml.Header + string(data) + "\n", nil
+}
+
+// WriteDependencyGraphs renders the dependency tree in every requested
+// format, highlighting the packages with active findings
+func WriteDependencyGraphs(formats []string, depsPath, findingsPath string, rules []scan.IgnoreRule) error {
+	if _, err := os.Stat(depsPath); err != nil {
+		runenv.Logger.Warnf("No dependency tree to export: %s", depsPath)
+		return nil
+	}
+	tree, err := sbom.ReadDependencyTree(depsPath)
+	if err != nil {
+		return err
+	}
+
+	var active []scan.Finding
+	if _, err := os.Stat(findingsPath); err == nil {
+		findings, err := scan.ReadFindings(findingsPath)
+		if err != nil {
+			return err
+		}
+		active, _ = scan.ApplySuppressions(findings, rules)
+	}
+
+	g := newExportGraph(tree, active)
+	for _, format := range formats {
+		renderer := graphRenderers[format]
+		content, err := renderer.render(g)
+		if err != nil {
+			return err
+		}
+		outputPath := graphBasePath(depsPath) + renderer.ext
+		if err := os.WriteFile(outputPath, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write dependency graph: %v", err)
+		}
+		runenv.Logger.Infof("Dependency graph written to %s", outputPath)
+	}
+	return nil
+}
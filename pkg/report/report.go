@@ -0,0 +1,143 @@
+// Package report renders the findings of a scan as reports and draws the
+// dependency graphs.
+package report
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/maven"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// reportData is the input of every report renderer
+type reportData struct {
+	Title       string
+	GeneratedAt string
+	Findings    scan.FindingList
+	Suppressed  scan.FindingList
+	Counts      map[string]int
+	Diff        *FindingsDiff          // comparison with the baseline, nil without one
+	Maintenance []scan.MaintenanceRisk // from the maintenance report, nil when there is none
+	SupplyChain []scan.SupplyChainRisk // from the supply chain report, nil when there is none
+	Secrets     []scan.SecretFinding   // from the secrets report, nil when there is none
+	// License report the components are streamed from, as it lists every
+	// component of the SBOM; empty when there is none
+	LicenseReport string
+	// Excluded dependencies that would have been vulnerable, from the
+	// excluded dependencies report
+	Excluded []maven.ExcludedDependency
+	Unpinned []maven.UnpinnedDependency // SNAPSHOTs and version ranges, nil when there are none
+}
+
+// NewReportData counts the findings for the report, which streams them again
+// while it is rendered
+func NewReportData(title string, findings, suppressed scan.FindingSource) (reportData, error) {
+	counts := make(map[string]int)
+	for _, s := range scan.SeverityOrder {
+		counts[s] = 0
+	}
+	data := reportData{
+		Title:       title,
+		GeneratedAt: time.Now().Format(time.RFC1123),
+		Findings:    scan.FindingList{Source: findings},
+		Suppressed:  scan.FindingList{Source: suppressed},
+		Counts:      counts,
+	}
+	err := findings(func(f scan.Finding) error {
+		counts[f.Severity]++
+		data.Findings.Count++
+		return nil
+	})
+	if err != nil {
+		return data, err
+	}
+	err = suppressed(func(scan.Finding) error {
+		data.Suppressed.Count++
+		return nil
+	})
+	return data, err
+}
+
+// ParseReportFormats splits and validates the --report flag value
+func ParseReportFormats(value string) ([]string, error) {
+	var formats []string
+	for _, format := range strings.Split(value, ",") {
+		format = strings.ToLower(strings.TrimSpace(format))
+		if format == "" {
+			continue
+		}
+		if _, ok := reportRenderers[format]; !ok {
+			return nil, fmt.Errorf("unknown report format: %s", format)
+		}
+		formats = append(formats, format)
+	}
+	return formats, nil
+}
+
+// reportRenderers writes a report for the data to basePath plus the format's extension
+var reportRenderers = map[string]func(ctx context.Context, data reportData, basePath string) (string, error){
+	"csv":      renderCSVReport,
+	"html":     renderHTMLReport,
+	"junit":    renderJUnitReport,
+	"markdown": renderMarkdownReport,
+	"openvex":  renderOpenVEXReport,
+	"pdf":      renderPDFReport,
+}
+
+// RenderReports renders the findings at FindingsPath in every requested format
+func RenderReports(ctx context.Context, formats []string, title, findingsPath, basePath string, rules []scan.IgnoreRule, base *BaselineFindings) error {
+	return RenderReportFormats(ctx, formats, title, scan.FindingsFrom(findingsPath), rules, base, maven.LicenseReportFor(findingsPath), basePath)
+}
+
+// RenderReportFormats renders the findings in every requested format,
+// compared with the baseline when there is one. The findings and the
+// components, from the license report at LicensesPath, are streamed by the
+// renderers; the maintenance and supply chain risks and the secrets are read
+// from the reports next to it.
+func RenderReportFormats(ctx context.Context, formats []string, title string, all scan.FindingSource, rules []scan.IgnoreRule, base *BaselineFindings, licensesPath, basePath string) error {
+	all = all.SuppressedBy(rules)
+	data, err := NewReportData(title, all.Where(scan.ActiveFinding), all.Where(scan.SuppressedFinding))
+	if err != nil {
+		return err
+	}
+	if base != nil {
+		known, _ := scan.ApplySuppressions(base.Findings, rules)
+		diff, err := DiffFindings(known, data.Findings.Source)
+		if err != nil {
+			return err
+		}
+		diff.Baseline = base.Path
+		data.Diff = &diff
+	}
+	if _, err := os.Stat(licensesPath); err == nil {
+		data.LicenseReport = licensesPath
+	}
+	if risks, err := scan.ReadMaintenanceReport(scan.MaintenanceReportFor(licensesPath)); err == nil {
+		data.Maintenance = risks
+	}
+	if risks, err := scan.ReadSupplyChainReport(scan.SupplyChainReportFor(licensesPath)); err == nil {
+		data.SupplyChain = risks
+	}
+	if secrets, err := scan.ReadSecretsReport(scan.SecretsReportFor(licensesPath)); err == nil {
+		data.Secrets = secrets
+	}
+	if excluded, err := maven.ReadExcludedReport(maven.ExcludedReportFor(licensesPath)); err == nil {
+		data.Excluded = scan.VulnerableExclusions(excluded)
+	}
+	if unpinned, err := maven.ReadUnpinnedReport(maven.UnpinnedReportFor(licensesPath)); err == nil && len(unpinned) > 0 {
+		data.Unpinned = unpinned
+	}
+	for _, format := range formats {
+		path, err := reportRenderers[format](ctx, data, basePath)
+		if err != nil {
+			return fmt.Errorf("failed to render %s report: %w", format, err)
+		}
+		runenv.Logger.Infof("%s report written to %s", strings.ToUpper(format), path)
+	}
+	return nil
+}
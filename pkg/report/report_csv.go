@@ -0,0 +1,140 @@
+package report
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/maven"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// renderCSVReport writes components.csv and findings.csv into the directory
+// of the report, for spreadsheets and GRC tools that only import CSV.
+// Suppressed findings are included with the rule that suppressed them. The
+// rows are written as the findings are read, the components as they are read
+// from the license report.
+func renderCSVReport(_ context.Context, data reportData, basePath string) (string, error) {
+	dir := filepath.Dir(basePath)
+
+	findings, err := createCSV(filepath.Join(dir, "findings.csv"), []string{"id", "aliases", "severity", "score", "package", "version", "ecosystem",
+		"fixed_versions", "dependency_path", "version_source", "summary", "url", "suppressed_by", "suppression_expires"})
+	if err != nil {
+		return "", err
+	}
+
+	// Active findings per component, counted while the findings are written
+	type exposure struct {
+		count    int
+		severity string
+	}
+	vulnerable := make(map[string]*exposure)
+	write := func(f scan.Finding) error {
+		score := ""
+		if f.Score > 0 {
+			score = strconv.FormatFloat(f.Score, 'f', 1, 64)
+		}
+		source := ""
+		if f.Provenance != nil {
+			source = f.Provenance.String()
+		}
+		return findings.write([]string{f.ID, strings.Join(f.Aliases, " "), f.Severity, score, f.Package, f.Version, f.Ecosystem,
+			strings.Join(f.FixedVersions, " "), strings.Join(f.DependencyPath, " > "), source, f.Summary, f.URL, f.SuppressedBy, f.SuppressionExpires})
+	}
+	err = data.Findings.Each(func(f scan.Finding) error {
+		key := f.Package + "@" + f.Version
+		e, ok := vulnerable[key]
+		if !ok {
+			e = &exposure{severity: f.Severity}
+			vulnerable[key] = e
+		}
+		e.count++
+		if scan.SeverityRank(f.Severity) > scan.SeverityRank(e.severity) {
+			e.severity = f.Severity
+		}
+		return write(f)
+	})
+	if err == nil {
+		err = data.Suppressed.Each(write)
+	}
+	if closeErr := findings.close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if data.LicenseReport == "" {
+		runenv.Logger.Warn("No license report next to the findings, components.csv is not written")
+		return filepath.Join(dir, "findings.csv"), nil
+	}
+
+	components, err := createCSV(filepath.Join(dir, "components.csv"), []string{"package", "version", "purl", "licenses", "vulnerabilities", "highest_severity"})
+	if err != nil {
+		return "", err
+	}
+	err = maven.EachComponentLicense(data.LicenseReport, func(c maven.ComponentLicense) error {
+		count, severity := 0, ""
+		if e, ok := vulnerable[c.Package+"@"+c.Version]; ok {
+			count, severity = e.count, e.severity
+		}
+		return components.write([]string{c.Package, c.Version, c.PURL, strings.Join(c.Licenses, " "), strconv.Itoa(count), severity})
+	})
+	if closeErr := components.close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "{components,findings}.csv"), nil
+}
+
+// csvFile writes records to a CSV file one at a time, guarding the cells
+// against formula injection when the file is opened in a spreadsheet
+type csvFile struct {
+	path   string
+	file   *os.File
+	writer *csv.Writer
+}
+
+// createCSV creates the CSV file at path and writes its header
+func createCSV(path string, header []string) (*csvFile, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	c := &csvFile{path: path, file: file, writer: csv.NewWriter(file)}
+	if err := c.write(header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// write buffers a record; write errors are sticky and reported by close
+func (c *csvFile) write(record []string) error {
+	for i, cell := range record {
+		if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			record[i] = "'" + cell
+		}
+	}
+	return c.writer.Write(record)
+}
+
+// close flushes the records and closes the file
+func (c *csvFile) close() error {
+	c.writer.Flush()
+	err := c.writer.Error()
+	if closeErr := c.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", filepath.Base(c.path), err)
+	}
+	return nil
+}
@@ -0,0 +1,366 @@
+package report
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"html/template"
+	"strings"
+	"sync"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+const htmlReportTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+  body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2rem; color: #222; }
+  h1 { margin-bottom: 0.2rem; }
+  .meta { color: #666; margin-bottom: 2rem; }
+  .chart { max-width: 640px; margin-bottom: 2rem; }
+  .row { display: flex; align-items: center; margin: 4px 0; }
+  .label { width: 90px; font-size: 0.85rem; font-weight: 600; }
+  .bar { height: 18px; border-radius: 3px; min-width: 2px; }
+  .count { margin-left: 8px; font-size: 0.85rem; }
+  table { border-collapse: collapse; width: 100%; font-size: 0.9rem; }
+  th, td { border-bottom: 1px solid #ddd; padding: 6px 8px; text-align: left; vertical-align: top; }
+  th { background: #f4f4f4; cursor: pointer; user-select: none; }
+  th:after { content: " \2195"; color: #aaa; }
+  .sev { display: inline-block; padding: 2px 6px; border-radius: 3px; color: #fff; font-size: 0.75rem; font-weight: 600; }
+  .CRITICAL { background: #7b1fa2; }
+  .HIGH { background: #d32f2f; }
+  .MEDIUM { background: #f57c00; }
+  .LOW { background: #fbc02d; }
+  .UNKNOWN { background: #9e9e9e; }
+  .aliases { color: #666; font-size: 0.8rem; }
+  .empty { color: #2e7d32; font-weight: 600; }
+  .note { color: #666; }
+  .signoff { display: none; }
+  @media print {
+    body { margin: 0; }
+    th:after { content: none; }
+    tr { break-inside: avoid; }
+    .signoff { display: block; margin-top: 3rem; break-inside: avoid; }
+    .signoff td { height: 2.5rem; }
+  }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="meta">Generated {{.GeneratedAt}} &middot; {{.Findings.Len}} findings{{if .Suppressed.Len}} &middot; {{.Suppressed.Len}} suppressed{{end}}</div>
+
+<div class="chart">
+{{- range $severity := severities}}
+  <div class="row">
+    <span class="label">{{$severity}}</span>
+    <span class="bar {{$severity}}" style="width: {{barWidth (index $.Counts $severity) $.Findings.Len}}%"></span>
+    <span class="count">{{index $.Counts $severity}}</span>
+  </div>
+{{- end}}
+</div>
+
+{{if .Findings.Len -}}
+<table id="findings">
+<thead>
+<tr>
+  <th data-type="number">Severity</th>
+  <th>ID</th>
+  <th>Package</th>
+  <th>Version</th>
+  <th>Ecosystem</th>
+  <th>Fixed in</th>
+  <th>Summary</th>
+</tr>
+</thead>
+<tbody>
+{{- range stream .Findings}}
+<tr>
+  <td data-sort="{{severityRank .Severity}}"><span class="sev {{.Severity}}">{{.Severity}}</span>{{if .Score}} {{printf "%.1f" .Score}}{{end}}{{if .CVSSVector}}<div class="aliases">{{.CVSSVector}}</div>{{end}}</td>
+  <td><a href="{{.URL}}" target="_blank" rel="noopener">{{.ID}}</a>{{if .Aliases}}<div class="aliases">{{join .Aliases ", "}}</div>{{end}}{{if .Scanners}}<div class="aliases">found by {{join .Scanners ", "}}</div>{{end}}</td>
+  <td>{{.Package}}{{if gt (len .DependencyPath) 1}}<div class="aliases">via {{join .DependencyPath " → "}}</div>{{end}}{{if .Reachability}}<div class="aliases">{{.Reachability}} from the project's code</div>{{end}}{{with .Provenance}}<div class="aliases">version set by {{.}}</div>{{end}}</td>
+  <td>{{.Version}}</td>
+  <td>{{.Ecosystem}}</td>
+  <td>{{join .FixedVersions ", "}}{{if .Remediation}}<div class="aliases">{{.Remediation}}</div>{{end}}</td>
+  <td>{{.Summary}}{{if .CWEs}}<div class="aliases">{{join .CWEs ", "}}</div>{{end}}</td>
+</tr>
+{{- end}}
+</tbody>
+</table>
+{{- else -}}
+<p class="empty">No vulnerabilities found.</p>
+{{- end}}
+
+{{if .Maintenance -}}
+<h2>Maintenance Risk</h2>
+<table id="maintenance">
+<thead>
+<tr>
+  <th>Risk</th>
+  <th>Package</th>
+  <th>Version</th>
+  <th>Latest release</th>
+  <th>Details</th>
+</tr>
+</thead>
+<tbody>
+{{- range .Maintenance}}
+<tr>
+  <td>{{.Risk}}</td>
+  <td>{{.Package}}</td>
+  <td>{{.Version}}</td>
+  <td>{{.LatestRelease}}</td>
+  <td>{{.Message}}</td>
+</tr>
+{{- end}}
+</tbody>
+</table>
+{{- end}}
+
+{{if .SupplyChain -}}
+<h2>Supply Chain Risk</h2>
+<table id="supply-chain">
+<thead>
+<tr>
+  <th>Risk</th>
+  <th>Package</th>
+  <th>Version</th>
+  <th>Details</th>
+</tr>
+</thead>
+<tbody>
+{{- range .SupplyChain}}
+<tr>
+  <td>{{.Risk}}</td>
+  <td>{{.Package}}</td>
+  <td>{{.Version}}</td>
+  <td>{{.Message}}</td>
+</tr>
+{{- end}}
+</tbody>
+</table>
+{{- end}}
+
+{{if .Unpinned -}}
+<h2>Reproducibility Risk</h2>
+<table id="unpinned">
+<thead>
+<tr>
+  <th>Kind</th>
+  <th>Package</th>
+  <th>Version</th>
+  <th>Resolved</th>
+  <th>Details</th>
+</tr>
+</thead>
+<tbody>
+{{- range .Unpinned}}
+<tr>
+  <td>{{.Kind}}</td>
+  <td>{{.Package}}</td>
+  <td>{{.Version}}</td>
+  <td>{{.Resolved}}</td>
+  <td>{{.Message}}</td>
+</tr>
+{{- end}}
+</tbody>
+</table>
+{{- end}}
+
+{{if .Secrets -}}
+<h2>Secrets</h2>
+<table id="secrets">
+<thead>
+<tr>
+  <th>Severity</th>
+  <th>Secret</th>
+  <th>Location</th>
+  <th>Match</th>
+</tr>
+</thead>
+<tbody>
+{{- range .Secrets}}
+<tr>
+  <td><span class="sev {{.Severity}}">{{.Severity}}</span></td>
+  <td>{{.Description}}</td>
+  <td>{{.Path}}:{{.Line}}</td>
+  <td><code>{{.Match}}</code></td>
+</tr>
+{{- end}}
+</tbody>
+</table>
+{{- end}}
+
+{{if .Excluded -}}
+<h2>Excluded Dependencies</h2>
+<p class="note">These dependencies are kept off the classpath by an exclusion and are not scanned, but the excluded versions have known vulnerabilities. Check that the exclusion stays when the dependency is upgraded.</p>
+<table id="excluded">
+<thead>
+<tr>
+  <th>Package</th>
+  <th>Version</th>
+  <th>Excluded by</th>
+  <th>Vulnerabilities</th>
+</tr>
+</thead>
+<tbody>
+{{- range .Excluded}}
+<tr>
+  <td>{{.Package}}</td>
+  <td>{{.Version}}</td>
+  <td>{{.ExcludedBy}}</td>
+  <td>{{join .Vulnerabilities ", "}}</td>
+</tr>
+{{- end}}
+</tbody>
+</table>
+{{- end}}
+
+{{if .Suppressed.Len -}}
+<h2>Suppressed</h2>
+<table id="suppressed">
+<thead>
+<tr>
+  <th data-type="number">Severity</th>
+  <th>ID</th>
+  <th>Package</th>
+  <th>Version</th>
+  <th>Reason</th>
+  <th>Expires</th>
+</tr>
+</thead>
+<tbody>
+{{- range stream .Suppressed}}
+<tr>
+  <td data-sort="{{severityRank .Severity}}"><span class="sev {{.Severity}}">{{.Severity}}</span></td>
+  <td><a href="{{.URL}}" target="_blank" rel="noopener">{{.ID}}</a></td>
+  <td>{{.Package}}</td>
+  <td>{{.Version}}</td>
+  <td>{{.SuppressedBy}}</td>
+  <td>{{.SuppressionExpires}}</td>
+</tr>
+{{- end}}
+</tbody>
+</table>
+{{- end}}
+
+<div class="signoff">
+<h2>Sign-off</h2>
+<table>
+<thead><tr><th>Reviewed by</th><th>Role</th><th>Date</th><th>Signature</th></tr></thead>
+<tbody>
+<tr><td></td><td></td><td></td><td></td></tr>
+<tr><td></td><td></td><td></td><td></td></tr>
+</tbody>
+</table>
+</div>
+
+<script>
+document.querySelectorAll("table th").forEach(function (th) {
+  var column = th.cellIndex;
+  var ascending = false;
+  th.addEventListener("click", function () {
+    var tbody = th.closest("table").querySelector("tbody");
+    var numeric = th.dataset.type === "number";
+    ascending = !ascending;
+    Array.from(tbody.rows).sort(function (a, b) {
+      var x = a.cells[column].dataset.sort || a.cells[column].textContent.trim();
+      var y = b.cells[column].dataset.sort || b.cells[column].textContent.trim();
+      var result = numeric ? Number(x) - Number(y) : x.localeCompare(y);
+      return ascending ? result : -result;
+    }).forEach(function (row) { tbody.appendChild(row); });
+  });
+});
+</script>
+</body>
+</html>
+`
+
+var htmlReport = template.Must(template.New("report").Funcs(template.FuncMap{
+	"severities":   func() []string { return scan.SeverityOrder },
+	"severityRank": scan.SeverityRank,
+	"join":         strings.Join,
+	"barWidth": func(count, total int) int {
+		if total == 0 {
+			return 0
+		}
+		return count * 100 / total
+	},
+	// Replaced by the streams of each report
+	"stream": func(scan.FindingList) <-chan scan.Finding { return nil },
+}).Parse(htmlReportTemplate))
+
+// renderHTMLReport executes a clone of the template with the streams of the
+// report, so that the findings are written as they are read
+func renderHTMLReport(_ context.Context, data reportData, basePath string) (string, error) {
+	path := basePath + ".html"
+
+	streams := &findingStreams{done: make(chan struct{})}
+	report, err := htmlReport.Clone()
+	if err != nil {
+		return "", err
+	}
+	report.Funcs(template.FuncMap{"stream": streams.stream})
+
+	err = runenv.WriteBuffered(path, func(w *bufio.Writer) error {
+		err := report.Execute(w, data)
+		if closeErr := streams.close(); err == nil {
+			err = closeErr
+		}
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// findingStreams passes the findings of lists to a template through channels,
+// which it ranges over like slices
+type findingStreams struct {
+	done chan struct{}
+	wg   sync.WaitGroup
+	mu   sync.Mutex
+	err  error
+}
+
+// errStreamClosed stops the reading of a list the template did not range
+// over to the end
+var errStreamClosed = errors.New("stream closed")
+
+// stream starts reading the list into the returned channel
+func (s *findingStreams) stream(list scan.FindingList) <-chan scan.Finding {
+	findings := make(chan scan.Finding)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer close(findings)
+		err := list.Each(func(f scan.Finding) error {
+			select {
+			case findings <- f:
+				return nil
+			case <-s.done:
+				return errStreamClosed
+			}
+		})
+		if err != nil && err != errStreamClosed {
+			s.mu.Lock()
+			if s.err == nil {
+				s.err = err
+			}
+			s.mu.Unlock()
+		}
+	}()
+	return findings
+}
+
+// close stops the streams and returns the first error reading a list, which
+// cuts its table short
+func (s *findingStreams) close() error {
+	close(s.done)
+	s.wg.Wait()
+	return s.err
+}
@@ -0,0 +1,200 @@
+package report
+
+import (
+	"bufio"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/maven"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// renderJUnitReport writes the findings as JUnit XML for the test views of
+// Jenkins and GitLab: one test case per component, failed when it has active
+// vulnerabilities. Without a license report only the affected packages are
+// listed. The test cases are encoded as the components are read from the
+// license report, which is read twice: first to count them for the
+// attributes of the suites. Only the text of the findings is kept per
+// component as they are read.
+func renderJUnitReport(_ context.Context, data reportData, basePath string) (string, error) {
+	path := basePath + ".junit.xml"
+
+	byComponent := make(map[string]*junitFindings)
+	suppressed := make(map[string]*junitFindings)
+	err := data.Findings.Each(func(f scan.Finding) error {
+		junitAdd(byComponent, f, junitFindingLine(f))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	err = data.Suppressed.Each(func(f scan.Finding) error {
+		junitAdd(suppressed, f, strings.TrimSuffix(junitFindingLine(f), "\n")+" [suppressed by "+f.SuppressedBy+"]\n")
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// Affected packages missing from the license report are added so that no
+	// finding is left out
+	listed := make(map[string]bool)
+	tests, failures := 0, 0
+	if data.LicenseReport != "" {
+		err := maven.EachComponentLicense(data.LicenseReport, func(c maven.ComponentLicense) error {
+			key := c.Package + "@" + c.Version
+			tests++
+			if byComponent[key] != nil {
+				failures++
+			}
+			if byComponent[key] != nil || suppressed[key] != nil {
+				listed[key] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	var extra []string
+	for _, group := range []map[string]*junitFindings{byComponent, suppressed} {
+		for key := range group {
+			if !listed[key] {
+				listed[key] = true
+				extra = append(extra, key)
+				tests++
+				if byComponent[key] != nil {
+					failures++
+				}
+			}
+		}
+	}
+	sort.Strings(extra)
+
+	err = runenv.WriteBuffered(path, func(w *bufio.Writer) error {
+		if _, err := w.WriteString(xml.Header); err != nil {
+			return err
+		}
+		encoder := xml.NewEncoder(w)
+		encoder.Indent("", "  ")
+		attr := func(name, value string) xml.Attr {
+			return xml.Attr{Name: xml.Name{Local: name}, Value: value}
+		}
+		suites := xml.StartElement{Name: xml.Name{Local: "testsuites"}, Attr: []xml.Attr{
+			attr("name", "sbom-scanner"), attr("tests", strconv.Itoa(tests)), attr("failures", strconv.Itoa(failures)),
+		}}
+		suite := xml.StartElement{Name: xml.Name{Local: "testsuite"}, Attr: []xml.Attr{
+			attr("name", data.Title), attr("tests", strconv.Itoa(tests)), attr("failures", strconv.Itoa(failures)),
+			attr("timestamp", time.Now().UTC().Format("2006-01-02T15:04:05")),
+		}}
+		if err := encoder.EncodeToken(suites); err != nil {
+			return err
+		}
+		if err := encoder.EncodeToken(suite); err != nil {
+			return err
+		}
+
+		encodeCase := func(component string) error {
+			testCase := junitTestCase{Name: component, ClassName: data.Title}
+			if findings := byComponent[component]; findings != nil {
+				testCase.Failure = &junitFailure{
+					Message: fmt.Sprintf("%d vulnerabilities, highest severity %s", findings.count, findings.highest),
+					Type:    findings.highest,
+					Text:    findings.text.String(),
+				}
+			}
+			if findings := suppressed[component]; findings != nil {
+				testCase.SystemOut = findings.text.String()
+			}
+			return encoder.EncodeElement(testCase, xml.StartElement{Name: xml.Name{Local: "testcase"}})
+		}
+		if data.LicenseReport != "" {
+			err := maven.EachComponentLicense(data.LicenseReport, func(c maven.ComponentLicense) error {
+				return encodeCase(c.Package + "@" + c.Version)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		for _, component := range extra {
+			if err := encodeCase(component); err != nil {
+				return err
+			}
+		}
+
+		if err := encoder.EncodeToken(suite.End()); err != nil {
+			return err
+		}
+		if err := encoder.EncodeToken(suites.End()); err != nil {
+			return err
+		}
+		if err := encoder.Flush(); err != nil {
+			return err
+		}
+		_, err := w.WriteString("\n")
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// junitFindings are the findings of a component in a test case
+type junitFindings struct {
+	count   int
+	highest string
+	text    strings.Builder
+}
+
+// junitAdd adds a finding, described by line, to its component
+func junitAdd(components map[string]*junitFindings, f scan.Finding, line string) {
+	key := f.Package + "@" + f.Version
+	c, ok := components[key]
+	if !ok {
+		c = &junitFindings{highest: f.Severity}
+		components[key] = c
+	}
+	c.count++
+	if scan.SeverityRank(f.Severity) > scan.SeverityRank(c.highest) {
+		c.highest = f.Severity
+	}
+	c.text.WriteString(line)
+}
+
+// junitFindingLine describes a finding in the failure text of a test case
+func junitFindingLine(f scan.Finding) string {
+	line := f.ID + " " + f.Severity
+	if f.Score > 0 {
+		line += fmt.Sprintf(" %.1f", f.Score)
+	}
+	if f.Summary != "" {
+		line += ": " + f.Summary
+	}
+	if len(f.FixedVersions) > 0 {
+		line += " (fixed in " + strings.Join(f.FixedVersions, ", ") + ")"
+	}
+	if f.URL != "" {
+		line += " " + f.URL
+	}
+	return line + "\n"
+}
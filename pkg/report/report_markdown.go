@@ -0,0 +1,172 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// Rows of a findings table in the markdown report, comments on GitHub and
+// GitLab are limited in size
+const markdownMaxRows = 50
+
+const markdownReportTemplate = `### {{.Title}}
+
+{{if .Findings.Len -}}
+**{{.Findings.Len}} vulnerabilities**{{range $severity := severities}}{{with index $.Counts $severity}} · {{.}} {{lower $severity}}{{end}}{{end}}{{if .Suppressed.Len}} · {{.Suppressed.Len}} suppressed{{end}}
+{{- else -}}
+**No vulnerabilities found**{{if .Suppressed.Len}} · {{.Suppressed.Len}} suppressed{{end}}
+{{- end}}
+{{with .Diff}}
+Compared with the baseline: **{{len .New}} new** · {{len .Fixed}} fixed · {{len .Changed}} changed · {{.Unchanged}} unchanged
+{{if .New}}
+#### New vulnerabilities
+
+{{template "table" rows .New}}
+{{- end}}
+{{- if .Fixed}}
+<details><summary>Fixed vulnerabilities ({{len .Fixed}})</summary>
+
+{{template "table" rows .Fixed}}
+</details>
+{{end}}
+{{- end}}
+{{- if .Findings.Len}}
+{{if .Diff}}<details><summary>All vulnerabilities ({{.Findings.Len}})</summary>
+{{else}}#### Vulnerabilities
+{{end}}
+{{template "table" firstRows .Findings}}
+{{- if .Diff}}
+</details>
+{{end}}
+{{end}}
+{{- if .Maintenance}}
+<details><summary>Maintenance risk ({{len .Maintenance}} components)</summary>
+
+| Risk | Package | Version | Details |
+|---|---|---|---|
+{{range .Maintenance}}| {{.Risk}} | {{cell .Package}} | {{cell .Version}} | {{cell .Message}} |
+{{end}}
+</details>
+{{end}}
+{{- if .SupplyChain}}
+<details><summary>Supply chain risk ({{len .SupplyChain}} components)</summary>
+
+| Risk | Package | Version | Details |
+|---|---|---|---|
+{{range .SupplyChain}}| {{.Risk}} | {{cell .Package}} | {{cell .Version}} | {{cell .Message}} |
+{{end}}
+</details>
+{{end}}
+{{- if .Unpinned}}
+<details><summary>Reproducibility risk ({{len .Unpinned}} unpinned versions)</summary>
+
+| Kind | Package | Version | Resolved | Details |
+|---|---|---|---|---|
+{{range .Unpinned}}| {{.Kind}} | {{cell .Package}} | {{cell .Version}} | {{cell .Resolved}} | {{cell .Message}} |
+{{end}}
+</details>
+{{end}}
+{{- if .Secrets}}
+<details><summary>Secrets ({{len .Secrets}})</summary>
+
+| Severity | Secret | Location | Match |
+|---|---|---|---|
+{{range .Secrets}}| {{.Severity}} | {{.Description}} | {{cell .Path}}:{{.Line}} | {{cell .Match}} |
+{{end}}
+</details>
+{{end}}
+{{- if .Excluded}}
+<details><summary>Excluded dependencies ({{len .Excluded}} would have been vulnerable)</summary>
+
+These dependencies are kept off the classpath by an exclusion and are not scanned, but the excluded versions have known vulnerabilities. Check that the exclusion stays when the dependency is upgraded.
+
+| Package | Version | Excluded by | Vulnerabilities |
+|---|---|---|---|
+{{range .Excluded}}| {{cell .Package}} | {{cell .Version}} | {{cell .ExcludedBy}} | {{cell (join .Vulnerabilities ", ")}} |
+{{end}}
+</details>
+{{end}}
+
+<sub>Generated by sbom-scanner {{.GeneratedAt}}</sub>
+{{define "table"}}| Severity | ID | Package | Version | Fixed in |
+|---|---|---|---|---|
+{{range .Findings}}| {{.Severity}}{{if .Score}} {{printf "%.1f" .Score}}{{end}} | {{if .URL}}[{{cell .ID}}]({{.URL}}){{else}}{{cell .ID}}{{end}} | {{cell .Package}}{{with .Provenance}}<br><sub>version set by {{cell .String}}</sub>{{end}} | {{cell .Version}} | {{cell (join .FixedVersions ", ")}} |
+{{end}}{{if .More}}
+_… and {{.More}} more, see the full report._
+{{end}}{{end}}`
+
+// markdownRows is a findings table cut to markdownMaxRows
+type markdownRows struct {
+	Findings []scan.Finding
+	More     int
+}
+
+// errTableFull stops reading findings once a table has markdownMaxRows
+var errTableFull = errors.New("table full")
+
+var markdownReport = template.Must(template.New("report").Funcs(template.FuncMap{
+	"severities": func() []string { return scan.SeverityOrder },
+	"lower":      strings.ToLower,
+	"join":       strings.Join,
+	"rows": func(findings []scan.Finding) markdownRows {
+		if len(findings) <= markdownMaxRows {
+			return markdownRows{Findings: findings}
+		}
+		return markdownRows{Findings: findings[:markdownMaxRows], More: len(findings) - markdownMaxRows}
+	},
+	// firstRows reads no more findings of the list than the table shows
+	"firstRows": func(list scan.FindingList) (markdownRows, error) {
+		var rows markdownRows
+		err := list.Each(func(f scan.Finding) error {
+			if len(rows.Findings) == markdownMaxRows {
+				return errTableFull
+			}
+			rows.Findings = append(rows.Findings, f)
+			return nil
+		})
+		if err != nil && err != errTableFull {
+			return rows, err
+		}
+		rows.More = list.Len() - len(rows.Findings)
+		return rows, nil
+	},
+	// cell keeps a value from breaking the table
+	"cell": func(value string) string {
+		return strings.NewReplacer("|", `\|`, "\n", " ", "\r", "").Replace(value)
+	},
+}).Parse(markdownReportTemplate))
+
+// renderMarkdownReport writes a compact report to post as a pull request
+// comment, with the new findings first when there is a baseline
+func renderMarkdownReport(_ context.Context, data reportData, basePath string) (string, error) {
+	path := basePath + ".md"
+
+	content, err := RenderMarkdown(data)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// RenderMarkdown executes the markdown report, also used for the GitHub job
+// summary and pull request comments
+func RenderMarkdown(data reportData) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := markdownReport.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	// The template leaves runs of blank lines between the optional sections
+	return blankLines.ReplaceAll(buf.Bytes(), []byte("\n\n")), nil
+}
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
@@ -0,0 +1,106 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+// Maximum duration of the conversion of a report to PDF
+const pdfTimeout = 2 * time.Minute
+
+// Browsers that can print the HTML report to PDF, looked up on PATH unless
+// tools.chrome is set
+var chromeExecutables = []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome", "msedge"}
+
+// ChromePath returns the configured browser or the first one found
+func ChromePath(s *runenv.Settings) string {
+	if path := s.Tools["chrome"]; path != "" {
+		return path
+	}
+	for _, name := range chromeExecutables {
+		if path, err := exec.LookPath(name); err == nil {
+			return path
+		}
+	}
+
+	var candidates []string
+	switch runtime.GOOS {
+	case "darwin":
+		candidates = []string{"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome", "/Applications/Chromium.app/Contents/MacOS/Chromium"}
+	case "windows":
+		for _, dir := range []string{os.Getenv("ProgramFiles"), os.Getenv("ProgramFiles(x86)")} {
+			if dir != "" {
+				candidates = append(candidates,
+					filepath.Join(dir, "Google", "Chrome", "Application", "chrome.exe"),
+					filepath.Join(dir, "Microsoft", "Edge", "Application", "msedge.exe"))
+			}
+		}
+	}
+	for _, path := range candidates {
+		if runenv.IsExecutable(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// renderPDFReport prints the HTML report, including its sign-off section, to
+// PDF with a headless Chrome, Chromium or Edge
+func renderPDFReport(ctx context.Context, data reportData, basePath string) (string, error) {
+	path, err := filepath.Abs(basePath + ".pdf")
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path: %v", err)
+	}
+	browser := ChromePath(runenv.SettingsOf(ctx))
+	if browser == "" {
+		return "", runenv.MissingTool("PDF reports need Chrome, Chromium or Edge, install one or set tools.chrome in the config file")
+	}
+
+	// The browser profile and the HTML page live in a temporary directory
+	tmp, err := runenv.NewTempDir(ctx, "sbom-scanner-pdf-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	page, err := renderHTMLReport(ctx, data, filepath.Join(tmp, "report"))
+	if err != nil {
+		return "", err
+	}
+	pageURL := filepath.ToSlash(page)
+	if !strings.HasPrefix(pageURL, "/") {
+		pageURL = "/" + pageURL
+	}
+
+	args := []string{
+		"--headless",
+		"--disable-gpu",
+		"--no-pdf-header-footer",
+		"--user-data-dir=" + filepath.Join(tmp, "profile"),
+		"--print-to-pdf=" + path,
+	}
+	// Chrome refuses to run as root, e.g. in CI containers, with its sandbox
+	if os.Geteuid() == 0 {
+		args = append(args, "--no-sandbox")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, pdfTimeout)
+	defer cancel()
+	os.Remove(path)
+	output, err := runenv.Command(ctx, browser, append(args, "file://"+pageURL)...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %v\n%s", filepath.Base(browser), err, string(output))
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("%s did not write the PDF:\n%s", filepath.Base(browser), string(output))
+	}
+	return path, nil
+}
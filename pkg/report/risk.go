@@ -0,0 +1,53 @@
+package report
+
+import (
+	"math"
+
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// Weights of the risk score per severity, as in Dependency-Track; findings of
+// unknown severity count like medium ones
+var severityRiskWeights = map[string]float64{
+	scan.SeverityCritical: 10,
+	scan.SeverityHigh:     5,
+	scan.SeverityMedium:   3,
+	scan.SeverityLow:      1,
+	scan.SeverityUnknown:  3,
+}
+
+// Factors of the risk score for exploitability and reachability
+const (
+	riskKEVFactor         = 2.0 // known exploited in the wild (CISA KEV)
+	riskUnreachableFactor = 0.5 // not reachable from the project's bytecode
+)
+
+// findingRisk weights the severity of a finding by its exploitability: twice
+// for known exploited vulnerabilities, else up to twice by the EPSS
+// probability. Unreachable findings count half.
+func findingRisk(f scan.Finding) float64 {
+	risk := severityRiskWeights[f.Severity]
+	if f.KEV != "" {
+		risk *= riskKEVFactor
+	} else {
+		risk *= 1 + f.EPSS
+	}
+	if f.Reachability == scan.Unreachable {
+		risk *= riskUnreachableFactor
+	}
+	return risk
+}
+
+// RiskScore sums the risk of the active findings, rounded to one decimal.
+// It grows with the number of findings, so that its trend over the scans of
+// a project shows whether the risk is going down.
+func RiskScore(findings scan.FindingSource) (float64, error) {
+	score := 0.0
+	err := findings(func(f scan.Finding) error {
+		if f.SuppressedBy == "" {
+			score += findingRisk(f)
+		}
+		return nil
+	})
+	return math.Round(score*10) / 10, err
+}
@@ -0,0 +1,88 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// renderOpenVEXReport writes an OpenVEX skeleton: findings suppressed by an
+// ignore rule are recorded as not_affected, all others as under_investigation.
+// The statements follow the order of the findings and are encoded as the
+// findings are read, twice: first to derive the ID of the document from its
+// content.
+func renderOpenVEXReport(_ context.Context, data reportData, basePath string) (string, error) {
+	path := basePath + ".openvex.json"
+
+	doc := scan.OpenVEXDocument{
+		Context:   scan.OpenVEXContext,
+		Author:    "sbom-scanner",
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Version:   1,
+	}
+
+	statement := func(f scan.Finding, status, impact string) scan.OpenVEXStatement {
+		name, aliases := scan.VEXName(f)
+		statement := scan.OpenVEXStatement{
+			Vulnerability:   scan.OpenVEXVulnerability{Name: name, Aliases: aliases},
+			Status:          status,
+			ImpactStatement: impact,
+		}
+		if f.Remediation != nil && status != "not_affected" {
+			statement.ActionStatement = f.Remediation.String()
+		}
+		if purl := scan.PURLFromPackage(f.Ecosystem, f.Package, f.Version); purl != "" {
+			statement.Products = []scan.OpenVEXProduct{{ID: purl}}
+		}
+		return statement
+	}
+	statements := func(fn func(scan.OpenVEXStatement) error) error {
+		err := data.Findings.Each(func(f scan.Finding) error {
+			return fn(statement(f, "under_investigation", ""))
+		})
+		if err != nil {
+			return err
+		}
+		return data.Suppressed.Each(func(f scan.Finding) error {
+			return fn(statement(f, "not_affected", f.SuppressedBy))
+		})
+	}
+
+	// Formatted like json.MarshalIndent, with the statements written after
+	// the other fields of the document
+	write := func(w io.Writer) error {
+		head, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(bytes.TrimSuffix(head, []byte("null\n}"))); err != nil {
+			return err
+		}
+		if err := runenv.EncodeJSONItems(w, "  ", statements); err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, "\n}")
+		return err
+	}
+
+	hash := sha256.New()
+	if err := write(hash); err != nil {
+		return "", err
+	}
+	doc.ID = fmt.Sprintf("https://openvex.dev/docs/sbom-scanner-%x", hash.Sum(nil))
+	err := runenv.WriteBuffered(path, func(w *bufio.Writer) error {
+		return write(w)
+	})
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,211 @@
+package sbom
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"gopkg.in/yaml.v3"
+)
+
+// File names of Docker Compose files, scanned per service when given with -f
+var composeFileNames = map[string]bool{
+	"compose.yaml":        true,
+	"compose.yml":         true,
+	"docker-compose.yaml": true,
+	"docker-compose.yml":  true,
+}
+
+// Matches $$, ${VAR}, ${VAR:-default}, ${VAR?error} and $VAR
+var composeVariablePattern = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(?:(:?[-?])([^}]*))?\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// ComposeService is a service of a Compose file with the modules scanned for it
+type ComposeService struct {
+	Name    string          `json:"name"`
+	Build   string          `json:"build,omitempty"` // build context, relative to the Compose file
+	Image   string          `json:"image,omitempty"`
+	Modules []ServiceModule `json:"modules"`
+}
+
+// ServiceModule is a project of the build context, or the image, of a service
+type ServiceModule struct {
+	Module          string         `json:"module"` // project file relative to the Compose file, or the image
+	OutputDir       string         `json:"output_dir"`
+	Vulnerabilities int            `json:"vulnerabilities"`
+	Severities      map[string]int `json:"severities,omitempty"`
+	Error           string         `json:"error,omitempty"`
+}
+
+// composeFile is the part of a Compose file that defines the services
+type composeFile struct {
+	Services map[string]struct {
+		Image string    `yaml:"image"`
+		Build yaml.Node `yaml:"build"` // the context, or a mapping with it
+	} `yaml:"services"`
+}
+
+// IsComposeFile reports whether path is a Docker Compose file
+func IsComposeFile(path string) bool {
+	return composeFileNames[filepath.Base(path)]
+}
+
+// FindComposeServices returns the projects of the services of a Compose
+// file: the projects found in the build context of services that are built,
+// else their image. Build contexts and images shared by several services are
+// scanned once.
+func FindComposeServices(composePath, outputDir string) ([]Project, []ComposeService, error) {
+	data, err := os.ReadFile(composePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read Compose file: %v", err)
+	}
+	var compose composeFile
+	if err := yaml.Unmarshal(data, &compose); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %v", composePath, err)
+	}
+	if len(compose.Services) == 0 {
+		return nil, nil, fmt.Errorf("no services found in %s", composePath)
+	}
+
+	dir := filepath.Dir(composePath)
+	env := composeEnvironment(dir)
+	names := make([]string, 0, len(compose.Services))
+	for name := range compose.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var projects []Project
+	var services []ComposeService
+	scanned := make(map[string][]ServiceModule)
+	for _, name := range names {
+		definition := compose.Services[name]
+		service := ComposeService{Name: name, Image: interpolateCompose(definition.Image, env)}
+		service.Build = interpolateCompose(composeBuildContext(definition.Build), env)
+
+		// Remote build contexts are not cloned, the image is scanned instead
+		local := service.Build != ""
+		if strings.Contains(service.Build, "://") || strings.HasPrefix(service.Build, "git@") {
+			runenv.Logger.Warnf("Skipping the remote build context %s of service %s", service.Build, name)
+			local = false
+		}
+
+		switch {
+		case local:
+			contextDir := filepath.Join(dir, service.Build)
+			if modules, ok := scanned[contextDir]; ok {
+				service.Modules = modules
+				break
+			}
+			found, err := FindProjects(contextDir, outputDir)
+			if err != nil {
+				return nil, nil, fmt.Errorf("service %s: %v", name, err)
+			}
+			// Modules keep their place in the source tree, contexts outside
+			// of the Compose file's directory go to services/<name>
+			base, err := filepath.Rel(dir, contextDir)
+			if err != nil || strings.HasPrefix(base, "..") {
+				base = filepath.Join("services", name)
+			}
+			for _, p := range found {
+				p.Rel = filepath.Join(base, p.Rel)
+				p.Output = filepath.Join(base, p.Output)
+				projects = append(projects, p)
+				service.Modules = append(service.Modules, ServiceModule{
+					Module:    filepath.ToSlash(filepath.Join(p.Rel, filepath.Base(p.File))),
+					OutputDir: p.Output,
+				})
+			}
+			scanned[contextDir] = service.Modules
+		case service.Image != "":
+			if unresolvedImagePattern.MatchString(service.Image) {
+				runenv.Logger.Warnf("Skipping unresolved image %s of service %s", service.Image, name)
+				break
+			}
+			if modules, ok := scanned[service.Image]; ok {
+				service.Modules = modules
+				break
+			}
+			projects = append(projects, Project{Tool: BuildToolImage, File: service.Image, Rel: service.Image, Output: ImageOutputDir(service.Image)})
+			service.Modules = []ServiceModule{{Module: service.Image, OutputDir: ImageOutputDir(service.Image)}}
+			scanned[service.Image] = service.Modules
+		default:
+			runenv.Logger.Warnf("Service %s has neither a build context nor an image", name)
+		}
+		services = append(services, service)
+	}
+
+	if len(projects) == 0 {
+		return nil, nil, fmt.Errorf("no build contexts or images found in %s", composePath)
+	}
+	runenv.Logger.Infof("Found %d services with %d modules in %s", len(services), len(projects), composePath)
+	return projects, services, nil
+}
+
+// composeBuildContext returns the context of a build section, which is either
+// the context itself or a mapping with context and dockerfile
+func composeBuildContext(build yaml.Node) string {
+	switch build.Kind {
+	case yaml.ScalarNode:
+		return build.Value
+	case yaml.MappingNode:
+		var b struct {
+			Context string `yaml:"context"`
+		}
+		if build.Decode(&b) != nil || b.Context == "" {
+			return "."
+		}
+		return b.Context
+	}
+	return ""
+}
+
+// composeEnvironment returns the variables for interpolation: the .env file
+// next to the Compose file, overridden by the environment like in Compose
+func composeEnvironment(dir string) map[string]string {
+	env := make(map[string]string)
+	if data, err := os.ReadFile(filepath.Join(dir, ".env")); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "="); ok {
+				env[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+			}
+		}
+	}
+	for _, entry := range os.Environ() {
+		if key, value, ok := strings.Cut(entry, "="); ok {
+			env[key] = value
+		}
+	}
+	return env
+}
+
+// interpolateCompose substitutes the variables of a Compose value. Variables
+// without a value and default are left as ${VAR}, so that the image is
+// reported as unresolved.
+func interpolateCompose(value string, env map[string]string) string {
+	return composeVariablePattern.ReplaceAllStringFunc(value, func(match string) string {
+		if match == "$$" {
+			return "$"
+		}
+		m := composeVariablePattern.FindStringSubmatch(match)
+		name, operator, fallback := m[1], m[2], m[3]
+		if name == "" {
+			name = m[4]
+		}
+		v, set := env[name]
+		switch {
+		case operator == ":-" && v == "", operator == "-" && !set:
+			return fallback
+		case set:
+			return v
+		}
+		return "${" + name + "}"
+	})
+}
@@ -0,0 +1,699 @@
+package sbom
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// SBOM formats of the convert command
+var ConvertFormats = []string{"cyclonedx-xml", "cyclonedx-json", "spdx-json", "spdx-tag-value"}
+
+// bomDocument is an SBOM independent of its format, the common model of the
+// convert command
+type bomDocument struct {
+	Name       string
+	Namespace  string // CycloneDX serial number or SPDX document namespace
+	created    string
+	Tools      []CDXTool
+	root       *bomComponent // the described application, if known
+	Components []bomComponent
+}
+
+// bomComponent is a component with the details kept by the conversion
+type bomComponent struct {
+	typ        string // CycloneDX component type: library, application, ...
+	Group      string
+	Name       string
+	Version    string
+	PURL       string
+	licenses   []string // SPDX ids or license names
+	expression string   // SPDX license expression, instead of licenses
+	hashes     []cdxHash
+}
+
+// Hash algorithms in CycloneDX notation and their SPDX names
+var spdxHashAlgorithms = map[string]string{
+	"MD5":         "MD5",
+	"SHA-1":       "SHA1",
+	"SHA-256":     "SHA256",
+	"SHA-384":     "SHA384",
+	"SHA-512":     "SHA512",
+	"SHA3-256":    "SHA3-256",
+	"SHA3-384":    "SHA3-384",
+	"SHA3-512":    "SHA3-512",
+	"BLAKE2b-256": "BLAKE2b-256",
+	"BLAKE2b-384": "BLAKE2b-384",
+	"BLAKE2b-512": "BLAKE2b-512",
+	"BLAKE3":      "BLAKE3",
+}
+
+// Characters not allowed in SPDX ids and LicenseRef names
+var spdxIDInvalid = regexp.MustCompile(`[^A-Za-z0-9.\-]+`)
+
+// ConvertFormatOf derives the format from the name of the output file, e.g.
+// bom.spdx.json or sbom.cdx.xml
+func ConvertFormatOf(path string) string {
+	name := strings.ToLower(filepath.Base(path))
+	switch {
+	case strings.HasSuffix(name, ".spdx.json"):
+		return "spdx-json"
+	case strings.HasSuffix(name, ".spdx"):
+		return "spdx-tag-value"
+	case strings.HasSuffix(name, ".json"):
+		return "cyclonedx-json"
+	case strings.HasSuffix(name, ".xml"):
+		return "cyclonedx-xml"
+	}
+	return ""
+}
+
+// ReadBOMDocument reads a CycloneDX (XML or JSON) or SPDX (JSON or tag-value)
+// document
+func ReadBOMDocument(path string) (bomDocument, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return bomDocument{}, fmt.Errorf("failed to read SBOM: %v", err)
+	}
+	trimmed := bytes.TrimSpace(data)
+
+	var doc bomDocument
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		var bom cdxBOM
+		if err := xml.Unmarshal(data, &bom); err != nil {
+			return doc, fmt.Errorf("failed to parse SBOM %s: %v", path, err)
+		}
+		doc = BOMFromCycloneDX(bom)
+
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		var probe struct {
+			BOMFormat   string `json:"bomFormat"`
+			SPDXVersion string `json:"spdxVersion"`
+		}
+		if err := json.Unmarshal(data, &probe); err != nil {
+			return doc, fmt.Errorf("failed to parse SBOM %s: %v", path, err)
+		}
+		switch {
+		case probe.BOMFormat == "CycloneDX":
+			var bom CDXJSONDocument
+			if err := json.Unmarshal(data, &bom); err != nil {
+				return doc, fmt.Errorf("failed to parse SBOM %s: %v", path, err)
+			}
+			doc = bomFromCycloneDXJSON(bom)
+		case probe.SPDXVersion != "":
+			var spdx SPDXJSONDocument
+			if err := json.Unmarshal(data, &spdx); err != nil {
+				return doc, fmt.Errorf("failed to parse SBOM %s: %v", path, err)
+			}
+			doc = bomFromSPDX(spdx)
+		default:
+			return doc, fmt.Errorf("unknown SBOM format: %s", path)
+		}
+
+	case bytes.HasPrefix(trimmed, []byte("SPDXVersion:")):
+		doc = bomFromSPDX(readSPDXTagValueDocument(data))
+
+	default:
+		return doc, fmt.Errorf("unknown SBOM format: %s", path)
+	}
+
+	if doc.Name == "" {
+		doc.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return doc, nil
+}
+
+// EncodeBOMDocument writes the document in one of the ConvertFormats
+func EncodeBOMDocument(doc bomDocument, format string) ([]byte, error) {
+	var data []byte
+	var err error
+	switch format {
+	case "cyclonedx-xml":
+		data, err = xml.MarshalIndent(cycloneDXFromBOM(doc), "", "  ")
+		data = append([]byte(xml.Header), data...)
+	case "cyclonedx-json":
+		data, err = json.MarshalIndent(CycloneDXJSON(cycloneDXFromBOM(doc)), "", "  ")
+	case "spdx-json":
+		data, err = json.MarshalIndent(SPDXFromBOM(doc), "", "  ")
+	case "spdx-tag-value":
+		data = spdxTagValue(SPDXFromBOM(doc))
+	default:
+		return nil, fmt.Errorf("unknown output format: %s (available: %s)", format, strings.Join(ConvertFormats, ", "))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode SBOM: %v", err)
+	}
+	return append(bytes.TrimRight(data, "\n"), '\n'), nil
+}
+
+// BOMFromCycloneDX reads a CycloneDX XML document, nested components are
+// flattened
+func BOMFromCycloneDX(bom cdxBOM) bomDocument {
+	doc := bomDocument{Namespace: bom.SerialNumber}
+	convert := func(c CDXComponent) bomComponent {
+		component := bomComponent{typ: c.Type, Group: c.Group, Name: c.Name, Version: c.Version, PURL: c.PURL}
+		if c.Hashes != nil {
+			for _, hash := range c.Hashes.Hashes {
+				component.hashes = append(component.hashes, cdxHash{Alg: hash.Alg, Value: strings.TrimSpace(hash.Value)})
+			}
+		}
+		if c.Licenses != nil {
+			for _, license := range c.Licenses.Licenses {
+				if name := strings.TrimSpace(license.ID + license.Name); name != "" {
+					component.licenses = append(component.licenses, name)
+				}
+			}
+			component.expression = strings.TrimSpace(c.Licenses.Expression)
+		}
+		return component
+	}
+	if bom.Metadata != nil {
+		doc.created = bom.Metadata.Timestamp
+		doc.Tools = bom.Metadata.Tools
+		if bom.Metadata.Component != nil {
+			root := convert(*bom.Metadata.Component)
+			doc.root = &root
+			doc.Name = root.Name
+		}
+	}
+
+	var walk func(list []CDXComponent)
+	walk = func(list []CDXComponent) {
+		for _, c := range list {
+			doc.Components = append(doc.Components, convert(c))
+			if c.Components != nil {
+				walk(c.Components.Components)
+			}
+		}
+	}
+	walk(bom.Components)
+	return doc
+}
+
+// bomFromCycloneDXJSON reads a CycloneDX JSON document
+func bomFromCycloneDXJSON(bom CDXJSONDocument) bomDocument {
+	doc := bomDocument{Namespace: bom.SerialNumber, created: bom.Metadata.Timestamp}
+	for _, tool := range bom.Metadata.Tools {
+		doc.Tools = append(doc.Tools, CDXTool{Name: tool.Name, Version: tool.Version})
+	}
+	convert := func(c cdxJSONLibrary) bomComponent {
+		component := bomComponent{typ: c.Type, Group: c.Group, Name: c.Name, Version: c.Version, PURL: c.PURL}
+		for _, hash := range c.Hashes {
+			component.hashes = append(component.hashes, cdxHash{Alg: hash.Alg, Value: hash.Content})
+		}
+		for _, entry := range c.Licenses {
+			if expression, ok := entry["expression"].(string); ok {
+				component.expression = expression
+				continue
+			}
+			license, _ := entry["license"].(map[string]any)
+			for _, key := range []string{"id", "name"} {
+				if name, ok := license[key].(string); ok && name != "" {
+					component.licenses = append(component.licenses, name)
+					break
+				}
+			}
+		}
+		return component
+	}
+	if bom.Metadata.Component != nil {
+		root := convert(*bom.Metadata.Component)
+		doc.root = &root
+		doc.Name = root.Name
+	}
+
+	var walk func(list []cdxJSONLibrary)
+	walk = func(list []cdxJSONLibrary) {
+		for _, c := range list {
+			doc.Components = append(doc.Components, convert(c))
+			walk(c.Components)
+		}
+	}
+	walk(bom.Components)
+	return doc
+}
+
+// cycloneDXFromBOM builds a CycloneDX XML document
+func cycloneDXFromBOM(doc bomDocument) cdxBOM {
+	bom := cdxBOM{
+		XMLNS:        cyclonedxNamespace,
+		SerialNumber: doc.Namespace,
+		Version:      1,
+		Metadata:     &cdxMetadata{Timestamp: doc.created, Tools: doc.Tools},
+	}
+	// Only a URN is a valid serial number, SPDX namespaces are URLs
+	if !strings.HasPrefix(bom.SerialNumber, "urn:uuid:") {
+		bom.SerialNumber = ""
+	}
+	if bom.Metadata.Timestamp == "" {
+		bom.Metadata.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	}
+	if len(bom.Metadata.Tools) == 0 {
+		bom.Metadata.Tools = []CDXTool{{Name: "sbom-scanner"}}
+	}
+
+	convert := func(c bomComponent) CDXComponent {
+		component := CDXComponent{
+			Type:    c.typ,
+			BOMRef:  c.PURL,
+			Group:   c.Group,
+			Name:    c.Name,
+			Version: c.Version,
+			PURL:    c.PURL,
+		}
+		if component.Type == "" {
+			component.Type = "library"
+		}
+		if len(c.hashes) > 0 {
+			component.Hashes = &cdxHashes{Hashes: c.hashes}
+		}
+		switch {
+		case c.expression != "":
+			component.Licenses = &cdxLicenses{Expression: c.expression}
+		case len(c.licenses) > 0:
+			component.Licenses = NewCDXLicenses(c.licenses)
+		}
+		return component
+	}
+	if doc.root != nil {
+		root := convert(*doc.root)
+		bom.Metadata.Component = &root
+	}
+	seen := make(map[string]bool)
+	for _, c := range doc.Components {
+		component := convert(c)
+		// bom-refs must be unique
+		if component.BOMRef == "" || seen[component.BOMRef] {
+			component.BOMRef = ""
+		} else {
+			seen[component.BOMRef] = true
+		}
+		bom.Components = append(bom.Components, component)
+	}
+	return bom
+}
+
+// SPDXJSONDocument is an SPDX 2.3 JSON document
+type SPDXJSONDocument struct {
+	SPDXVersion       string `json:"spdxVersion"`
+	DataLicense       string `json:"dataLicense"`
+	SPDXID            string `json:"SPDXID"`
+	Name              string `json:"name"`
+	DocumentNamespace string `json:"documentNamespace"`
+	CreationInfo      struct {
+		Created  string   `json:"created"`
+		Creators []string `json:"creators"`
+	} `json:"creationInfo"`
+	Packages          []spdxJSONPackage      `json:"packages"`
+	Relationships     []spdxRelationship     `json:"relationships,omitempty"`
+	ExtractedLicenses []spdxExtractedLicense `json:"hasExtractedLicensingInfos,omitempty"`
+}
+
+type spdxJSONPackage struct {
+	SPDXID           string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	CopyrightText    string            `json:"copyrightText"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+	Purpose          string            `json:"primaryPackagePurpose,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	Category string `json:"referenceCategory"`
+	Type     string `json:"referenceType"`
+	Locator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	Element string `json:"spdxElementId"`
+	Type    string `json:"relationshipType"`
+	Related string `json:"relatedSpdxElement"`
+}
+
+type spdxExtractedLicense struct {
+	ID   string `json:"licenseId"`
+	Text string `json:"extractedText"`
+	Name string `json:"name,omitempty"`
+}
+
+// SPDXFromBOM builds an SPDX 2.3 document. Licenses that are no SPDX ids
+// become LicenseRefs with their name as extracted text. The document
+// describes the root component, which depends on all others, or every
+// component when there is no root.
+func SPDXFromBOM(doc bomDocument) SPDXJSONDocument {
+	spdx := SPDXJSONDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              doc.Name,
+		DocumentNamespace: doc.Namespace,
+		Packages:          []spdxJSONPackage{},
+	}
+	if !strings.HasPrefix(spdx.DocumentNamespace, "http") {
+		spdx.DocumentNamespace = "https://github.com/xshuden/sbom-scanner/spdx/" + strings.TrimPrefix(doc.Namespace, "urn:uuid:")
+		if doc.Namespace == "" {
+			spdx.DocumentNamespace += fmt.Sprintf("%s-%d", spdxIDInvalid.ReplaceAllString(doc.Name, "-"), time.Now().UnixNano())
+		}
+	}
+	spdx.CreationInfo.Created = doc.created
+	if spdx.CreationInfo.Created == "" {
+		spdx.CreationInfo.Created = time.Now().UTC().Format(time.RFC3339)
+	}
+	for _, tool := range doc.Tools {
+		creator := "Tool: " + tool.Name
+		if tool.Version != "" {
+			creator += "-" + tool.Version
+		}
+		spdx.CreationInfo.Creators = append(spdx.CreationInfo.Creators, creator)
+	}
+	if len(spdx.CreationInfo.Creators) == 0 {
+		spdx.CreationInfo.Creators = []string{"Tool: sbom-scanner"}
+	}
+
+	extracted := make(map[string]bool)
+	licenseExpression := func(c bomComponent) string {
+		if c.expression != "" {
+			return c.expression
+		}
+		var ids []string
+		for _, name := range c.licenses {
+			if isSPDXLicense(name) {
+				ids = append(ids, name)
+				continue
+			}
+			if isLicenseExpression(name) {
+				ids = append(ids, "("+name+")")
+				continue
+			}
+			id := "LicenseRef-" + strings.Trim(spdxIDInvalid.ReplaceAllString(name, "-"), "-")
+			if !extracted[id] {
+				extracted[id] = true
+				spdx.ExtractedLicenses = append(spdx.ExtractedLicenses, spdxExtractedLicense{ID: id, Text: name, Name: name})
+			}
+			ids = append(ids, id)
+		}
+		switch len(ids) {
+		case 0:
+			return "NOASSERTION"
+		case 1:
+			return strings.TrimSuffix(strings.TrimPrefix(ids[0], "("), ")")
+		}
+		return strings.Join(ids, " AND ")
+	}
+	convert := func(c bomComponent, id string) spdxJSONPackage {
+		pkg := spdxJSONPackage{
+			SPDXID:           id,
+			Name:             c.Name,
+			VersionInfo:      c.Version,
+			DownloadLocation: "NOASSERTION",
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  licenseExpression(c),
+			CopyrightText:    "NOASSERTION",
+		}
+		if c.Group != "" {
+			pkg.Name = c.Group + ":" + c.Name
+		}
+		if c.typ != "" {
+			pkg.Purpose = strings.ToUpper(strings.ReplaceAll(c.typ, "-", "_"))
+		}
+		for _, hash := range c.hashes {
+			if algorithm, ok := spdxHashAlgorithms[hash.Alg]; ok {
+				pkg.Checksums = append(pkg.Checksums, spdxChecksum{Algorithm: algorithm, Value: hash.Value})
+			}
+		}
+		if c.PURL != "" {
+			pkg.ExternalRefs = []spdxExternalRef{{Category: "PACKAGE-MANAGER", Type: "purl", Locator: c.PURL}}
+		}
+		return pkg
+	}
+
+	rootID := ""
+	if doc.root != nil {
+		rootID = "SPDXRef-Root-" + spdxIDInvalid.ReplaceAllString(doc.root.Name, "-")
+		spdx.Packages = append(spdx.Packages, convert(*doc.root, rootID))
+		spdx.Relationships = append(spdx.Relationships, spdxRelationship{Element: "SPDXRef-DOCUMENT", Type: "DESCRIBES", Related: rootID})
+	}
+	for i, c := range doc.Components {
+		id := fmt.Sprintf("SPDXRef-Package-%d", i+1)
+		spdx.Packages = append(spdx.Packages, convert(c, id))
+		if rootID != "" {
+			spdx.Relationships = append(spdx.Relationships, spdxRelationship{Element: rootID, Type: "DEPENDS_ON", Related: id})
+		} else {
+			spdx.Relationships = append(spdx.Relationships, spdxRelationship{Element: "SPDXRef-DOCUMENT", Type: "DESCRIBES", Related: id})
+		}
+	}
+	return spdx
+}
+
+// bomFromSPDX reads an SPDX document. A single package described by the
+// document that others depend on becomes the root component.
+func bomFromSPDX(spdx SPDXJSONDocument) bomDocument {
+	doc := bomDocument{Name: spdx.Name, Namespace: spdx.DocumentNamespace, created: spdx.CreationInfo.Created}
+	for _, creator := range spdx.CreationInfo.Creators {
+		if tool, ok := strings.CutPrefix(creator, "Tool: "); ok {
+			doc.Tools = append(doc.Tools, CDXTool{Name: strings.TrimSpace(tool)})
+		}
+	}
+
+	extracted := make(map[string]string)
+	for _, license := range spdx.ExtractedLicenses {
+		name := license.Name
+		if name == "" {
+			name = license.Text
+		}
+		extracted[license.ID] = name
+	}
+	algorithms := make(map[string]string)
+	for cdx, name := range spdxHashAlgorithms {
+		algorithms[name] = cdx
+	}
+
+	var described []string
+	dependents := make(map[string]bool)
+	for _, r := range spdx.Relationships {
+		switch r.Type {
+		case "DESCRIBES":
+			if r.Element == spdx.SPDXID {
+				described = append(described, r.Related)
+			}
+		case "DEPENDS_ON", "CONTAINS":
+			dependents[r.Element] = true
+		}
+	}
+	rootID := ""
+	if len(described) == 1 && dependents[described[0]] {
+		rootID = described[0]
+	}
+
+	for _, p := range spdx.Packages {
+		c := bomComponent{Name: p.Name, Version: p.VersionInfo}
+		if p.Purpose != "" {
+			c.typ = strings.ToLower(strings.ReplaceAll(p.Purpose, "_", "-"))
+		}
+		for _, ref := range p.ExternalRefs {
+			if ref.Type == "purl" {
+				c.PURL = ref.Locator
+			}
+		}
+		// Maven packages are named group:artifact
+		if strings.HasPrefix(c.PURL, "pkg:maven/") {
+			if group, name, ok := strings.Cut(c.Name, ":"); ok {
+				c.Group, c.Name = group, name
+			}
+		}
+		for _, checksum := range p.Checksums {
+			if alg, ok := algorithms[checksum.Algorithm]; ok {
+				c.hashes = append(c.hashes, cdxHash{Alg: alg, Value: checksum.Value})
+			}
+		}
+
+		expression := p.LicenseDeclared
+		if expression == "" || expression == "NOASSERTION" || expression == "NONE" {
+			expression = p.LicenseConcluded
+		}
+		if expression != "" && expression != "NOASSERTION" && expression != "NONE" {
+			// A conjunction of licenses is listed license by license, with
+			// the names of LicenseRefs
+			for _, id := range strings.Split(expression, " AND ") {
+				if strings.ContainsAny(id, " ()") {
+					c.licenses = nil
+					c.expression = expression
+					break
+				}
+				if name := extracted[id]; name != "" {
+					id = name
+				}
+				c.licenses = append(c.licenses, id)
+			}
+		}
+
+		if p.SPDXID == rootID {
+			root := c
+			doc.root = &root
+			continue
+		}
+		doc.Components = append(doc.Components, c)
+	}
+	return doc
+}
+
+// readSPDXTagValueDocument reads an SPDX tag-value document into the model of
+// the JSON format
+func readSPDXTagValueDocument(data []byte) SPDXJSONDocument {
+	var doc SPDXJSONDocument
+	var pkg *spdxJSONPackage
+	var license *spdxExtractedLicense
+	flush := func() {
+		if pkg != nil {
+			doc.Packages = append(doc.Packages, *pkg)
+			pkg = nil
+		}
+		if license != nil {
+			doc.ExtractedLicenses = append(doc.ExtractedLicenses, *license)
+			license = nil
+		}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		tag, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch tag {
+		case "SPDXID":
+			if pkg != nil {
+				pkg.SPDXID = value
+			} else if doc.SPDXID == "" {
+				doc.SPDXID = value
+			}
+		case "DocumentName":
+			doc.Name = value
+		case "DocumentNamespace":
+			doc.DocumentNamespace = value
+		case "Creator":
+			doc.CreationInfo.Creators = append(doc.CreationInfo.Creators, value)
+		case "Created":
+			doc.CreationInfo.Created = value
+		case "PackageName":
+			flush()
+			pkg = &spdxJSONPackage{Name: value}
+		case "PackageVersion", "PackageChecksum", "PackageLicenseDeclared", "PackageLicenseConcluded", "PrimaryPackagePurpose", "ExternalRef":
+			if pkg == nil {
+				continue
+			}
+			switch tag {
+			case "PackageVersion":
+				pkg.VersionInfo = value
+			case "PackageChecksum":
+				// PackageChecksum: SHA256: 9f86d08...
+				if algorithm, checksum, ok := strings.Cut(value, ":"); ok {
+					pkg.Checksums = append(pkg.Checksums, spdxChecksum{Algorithm: strings.TrimSpace(algorithm), Value: strings.TrimSpace(checksum)})
+				}
+			case "PackageLicenseDeclared":
+				pkg.LicenseDeclared = value
+			case "PackageLicenseConcluded":
+				pkg.LicenseConcluded = value
+			case "PrimaryPackagePurpose":
+				pkg.Purpose = value
+			case "ExternalRef":
+				// ExternalRef: PACKAGE-MANAGER purl pkg:npm/lodash@4.17.21
+				if fields := strings.Fields(value); len(fields) == 3 {
+					pkg.ExternalRefs = append(pkg.ExternalRefs, spdxExternalRef{Category: fields[0], Type: fields[1], Locator: fields[2]})
+				}
+			}
+		case "Relationship":
+			// Relationship: SPDXRef-DOCUMENT DESCRIBES SPDXRef-Package-1
+			if fields := strings.Fields(value); len(fields) == 3 {
+				doc.Relationships = append(doc.Relationships, spdxRelationship{Element: fields[0], Type: fields[1], Related: fields[2]})
+			}
+		case "LicenseID":
+			flush()
+			license = &spdxExtractedLicense{ID: value}
+		case "LicenseName":
+			if license != nil {
+				license.Name = value
+			}
+		case "ExtractedText":
+			if license != nil {
+				license.Text = strings.TrimSuffix(strings.TrimPrefix(value, "<text>"), "</text>")
+			}
+		case "FileName", "SnippetSPDXID":
+			flush()
+		}
+	}
+	flush()
+	return doc
+}
+
+// spdxTagValue writes the document in the SPDX tag-value format
+func spdxTagValue(doc SPDXJSONDocument) []byte {
+	var b bytes.Buffer
+	line := func(tag, value string) {
+		if value != "" {
+			fmt.Fprintf(&b, "%s: %s\n", tag, value)
+		}
+	}
+	line("SPDXVersion", doc.SPDXVersion)
+	line("DataLicense", doc.DataLicense)
+	line("SPDXID", doc.SPDXID)
+	line("DocumentName", doc.Name)
+	line("DocumentNamespace", doc.DocumentNamespace)
+	for _, creator := range doc.CreationInfo.Creators {
+		line("Creator", creator)
+	}
+	line("Created", doc.CreationInfo.Created)
+
+	for _, p := range doc.Packages {
+		b.WriteString("\n")
+		line("PackageName", p.Name)
+		line("SPDXID", p.SPDXID)
+		line("PackageVersion", p.VersionInfo)
+		line("PackageDownloadLocation", p.DownloadLocation)
+		line("FilesAnalyzed", fmt.Sprint(p.FilesAnalyzed))
+		for _, checksum := range p.Checksums {
+			line("PackageChecksum", checksum.Algorithm+": "+checksum.Value)
+		}
+		line("PackageLicenseConcluded", p.LicenseConcluded)
+		line("PackageLicenseDeclared", p.LicenseDeclared)
+		line("PackageCopyrightText", p.CopyrightText)
+		line("PrimaryPackagePurpose", p.Purpose)
+		for _, ref := range p.ExternalRefs {
+			line("ExternalRef", ref.Category+" "+ref.Type+" "+ref.Locator)
+		}
+	}
+
+	if len(doc.Relationships) > 0 {
+		b.WriteString("\n")
+	}
+	for _, r := range doc.Relationships {
+		line("Relationship", r.Element+" "+r.Type+" "+r.Related)
+	}
+	for _, license := range doc.ExtractedLicenses {
+		b.WriteString("\n")
+		line("LicenseID", license.ID)
+		line("ExtractedText", "<text>"+license.Text+"</text>")
+		line("LicenseName", license.Name)
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,97 @@
+package sbom
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const convertTestBOM = `<?xml version="1.0" encoding="UTF-8"?>
+<bom xmlns="http://cyclonedx.org/schema/bom/1.4" serialNumber="urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79" version="1">
+  <metadata>
+    <timestamp>2024-01-10T10:00:00Z</timestamp>
+    <tools><tool><name>sbom-scanner</name></tool></tools>
+    <component type="application">
+      <name>app</name>
+      <version>1.0</version>
+    </component>
+  </metadata>
+  <components>
+    <component type="library">
+      <name>lodash</name>
+      <version>4.17.21</version>
+      <licenses><license><id>MIT</id></license></licenses>
+      <purl>pkg:npm/lodash@4.17.21</purl>
+    </component>
+    <component type="library">
+      <group>org.example</group>
+      <name>lib</name>
+      <version>2.0</version>
+      <licenses><license><name>Example Commercial License</name></license></licenses>
+      <purl>pkg:maven/org.example/lib@2.0</purl>
+    </component>
+    <component type="library">
+      <name>chalk</name>
+      <version>5.3.0</version>
+      <licenses><expression>MIT OR Apache-2.0</expression></licenses>
+      <purl>pkg:npm/chalk@5.3.0</purl>
+    </component>
+  </components>
+</bom>
+`
+
+// convertSummary lists the name, version, package URL and licenses of the components
+func convertSummary(doc bomDocument) []string {
+	var lines []string
+	for _, c := range doc.Components {
+		licenses := c.expression
+		if licenses == "" {
+			licenses = strings.Join(c.licenses, ",")
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %s %s", c.Name, c.Version, c.PURL, licenses))
+	}
+	return lines
+}
+
+func TestConvertRoundTrip(t *testing.T) {
+	doc, err := ReadBOMDocument(writeLockfile(t, "bom.xml", convertTestBOM))
+	if err != nil {
+		t.Fatalf("ReadBOMDocument() error = %v", err)
+	}
+	want := convertSummary(doc)
+
+	for _, format := range ConvertFormats {
+		t.Run(format, func(t *testing.T) {
+			data, err := EncodeBOMDocument(doc, format)
+			if err != nil {
+				t.Fatalf("EncodeBOMDocument() error = %v", err)
+			}
+			path := filepath.Join(t.TempDir(), map[string]string{
+				"cyclonedx-xml":  "bom.cdx.xml",
+				"cyclonedx-json": "bom.cdx.json",
+				"spdx-json":      "bom.spdx.json",
+				"spdx-tag-value": "bom.spdx",
+			}[format])
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if got := ConvertFormatOf(path); got != format {
+				t.Errorf("ConvertFormatOf(%s) = %s, want %s", filepath.Base(path), got, format)
+			}
+
+			converted, err := ReadBOMDocument(path)
+			if err != nil {
+				t.Fatalf("ReadBOMDocument() error = %v", err)
+			}
+			if got := convertSummary(converted); !reflect.DeepEqual(got, want) {
+				t.Errorf("components =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+			}
+			if converted.root == nil || converted.root.Name != "app" || converted.root.Version != "1.0" {
+				t.Errorf("root = %+v, want app 1.0", converted.root)
+			}
+		})
+	}
+}
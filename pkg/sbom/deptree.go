@@ -0,0 +1,451 @@
+package sbom
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+// Reasons why a tree entry is not part of the resolved dependencies, or is
+// listed without its children
+const (
+	omittedDuplicate  = "duplicate"  // resolved, children are listed elsewhere
+	omittedConflict   = "conflict"   // another version won conflict resolution
+	omittedCycle      = "cycle"      // dependency cycle
+	omittedConstraint = "constraint" // Gradle dependency constraint
+	omittedUnresolved = "unresolved" // Gradle configuration that cannot be resolved
+)
+
+// TreeNode is a dependency read from deps-tree.txt
+type TreeNode struct {
+	Package    string      `json:"package"` // group:artifact, or the module path for Go
+	Version    string      `json:"version"`
+	Type       string      `json:"type,omitempty"`
+	Classifier string      `json:"classifier,omitempty"`
+	Scope      string      `json:"scope,omitempty"` // Maven scope, or the Gradle configuration
+	Optional   bool        `json:"optional,omitempty"`
+	Requested  string      `json:"requested,omitempty"` // declared version when another one was selected
+	Omitted    string      `json:"omitted,omitempty"`
+	Children   []*TreeNode `json:"children,omitempty"`
+}
+
+// Resolved reports whether the dependency is on the classpath
+func (n *TreeNode) Resolved() bool {
+	return n.Omitted == "" || n.Omitted == omittedDuplicate
+}
+
+// DependencyGraph is the parsed dependency tree of a project
+type DependencyGraph struct {
+	Format       string      `json:"format"` // maven, gradle or go
+	Project      *TreeNode   `json:"project,omitempty"`
+	Dependencies []*TreeNode `json:"dependencies"`
+}
+
+var (
+	gradleConfigurationPattern = regexp.MustCompile(`^([A-Za-z][\w-]*)( - .*)?$`)
+	mavenOmittedPattern        = regexp.MustCompile(`(?: - |; )omitted for (duplicate|cycle|conflict with (\S+))$`)
+	mavenManagedPattern        = regexp.MustCompile(`version managed from ([^;)\s]+)`)
+)
+
+// dependencyGraphPath returns where the structured tree of deps-tree.txt is written
+func dependencyGraphPath(depsPath string) string {
+	return filepath.Join(filepath.Dir(depsPath), "deps-tree.json")
+}
+
+// ReadDependencyTree parses the text output of mvn dependency:tree (also
+// written by the native resolver), gradle dependencies or go mod graph.
+// Gradle lists one tree per configuration, their roots are concatenated.
+// Other formats yield an empty graph.
+func ReadDependencyTree(path string) (*DependencyGraph, error) {
+	lines, err := readTreeLines(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if isGoModGraph(lines) {
+		return parseGoModGraph(lines), nil
+	}
+
+	graph := &DependencyGraph{Format: "maven", Dependencies: []*TreeNode{}}
+	var stack []*TreeNode
+	configuration := ""
+
+	for _, line := range lines {
+		depth, content, gradle := treeLineDepth(line)
+		if depth == 0 {
+			// Maven starts with the project, Gradle with a configuration header
+			if m := gradleConfigurationPattern.FindStringSubmatch(line); m != nil && !strings.Contains(line, ":") {
+				configuration = m[1]
+			} else if graph.Project == nil && len(stack) == 0 && strings.Count(line, ":") >= 3 {
+				graph.Project = parseTreeCoordinates(line, false)
+			}
+			continue
+		}
+		if gradle {
+			graph.Format = "gradle"
+		}
+
+		node := parseTreeCoordinates(content, gradle)
+		if node == nil {
+			continue
+		}
+		if gradle {
+			node.Scope = configuration
+		}
+
+		if depth > len(stack)+1 {
+			depth = len(stack) + 1
+		}
+		stack = append(stack[:depth-1], node)
+		if depth == 1 {
+			graph.Dependencies = append(graph.Dependencies, node)
+		} else {
+			parent := stack[depth-2]
+			parent.Children = append(parent.Children, node)
+		}
+	}
+	return graph, nil
+}
+
+// readTreeLines returns the lines of a dependency tree file
+func readTreeLines(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dependency tree: %v", err)
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dependency tree: %v", err)
+	}
+	return lines, nil
+}
+
+// treeLineDepth finds the branch marker of a tree line and returns the depth
+// of the entry (1 for direct dependencies, 0 for other lines) and its text
+func treeLineDepth(line string) (int, string, bool) {
+	for _, marker := range []string{"+--- ", "\\--- "} {
+		if i := strings.Index(line, marker); i >= 0 {
+			return i/5 + 1, line[i+len(marker):], true
+		}
+	}
+	for _, marker := range []string{"+- ", "\\- "} {
+		if i := strings.Index(line, marker); i >= 0 {
+			return i/3 + 1, line[i+len(marker):], false
+		}
+	}
+	return 0, "", false
+}
+
+// parseTreeCoordinates reads group:artifact:type[:classifier]:version:scope
+// (Maven, entries omitted in verbose mode are wrapped in parentheses) or
+// group:artifact:version [-> version] (Gradle)
+func parseTreeCoordinates(content string, gradle bool) *TreeNode {
+	if gradle {
+		node := &TreeNode{}
+		for suffix, omitted := range map[string]string{" (*)": omittedDuplicate, " (c)": omittedConstraint, " (n)": omittedUnresolved} {
+			if strings.HasSuffix(content, suffix) {
+				content = strings.TrimSuffix(content, suffix)
+				node.Omitted = omitted
+			}
+		}
+		coordinates, resolved, _ := strings.Cut(content, " -> ")
+		fields := strings.Split(strings.TrimSpace(coordinates), ":")
+		if len(fields) < 2 {
+			return nil
+		}
+		node.Package = fields[0] + ":" + fields[1]
+		if len(fields) > 2 {
+			node.Version = fields[2]
+		}
+		if resolved != "" {
+			node.Requested = node.Version
+			node.Version = strings.TrimSpace(resolved)
+		}
+		return node
+	}
+
+	node := &TreeNode{}
+	var notes, selected string
+	if strings.HasPrefix(content, "(") {
+		// (group:artifact:jar:1.0:compile - omitted for conflict with 1.1)
+		content = strings.TrimSuffix(strings.TrimPrefix(content, "("), ")")
+		if m := mavenOmittedPattern.FindStringSubmatch(content); m != nil {
+			content = strings.TrimSuffix(content, m[0])
+			switch {
+			case m[2] != "":
+				node.Omitted = omittedConflict
+				selected = m[2]
+			case m[1] == "cycle":
+				node.Omitted = omittedCycle
+			default:
+				node.Omitted = omittedDuplicate
+			}
+		}
+	}
+	content, notes, _ = strings.Cut(content, " ")
+	if strings.Contains(notes, "(optional)") {
+		node.Optional = true
+	}
+	if m := mavenManagedPattern.FindStringSubmatch(notes); m != nil {
+		node.Requested = m[1]
+	}
+
+	fields := strings.Split(content, ":")
+	if len(fields) < 4 || len(fields) > 6 {
+		return nil
+	}
+	node.Package = fields[0] + ":" + fields[1]
+	node.Type = fields[2]
+	switch len(fields) {
+	case 4:
+		node.Version = fields[3]
+	case 5:
+		node.Version, node.Scope = fields[3], fields[4]
+	case 6:
+		node.Classifier, node.Version, node.Scope = fields[3], fields[4], fields[5]
+	}
+
+	// The tree lists the losing version, record the selected one as Version
+	if selected != "" {
+		node.Version, node.Requested = selected, node.Version
+	}
+	return node
+}
+
+// isGoModGraph reports whether the lines are "module@version dependency@version" edges
+func isGoModGraph(lines []string) bool {
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		return len(fields) == 2 && strings.Contains(fields[1], "@")
+	}
+	return false
+}
+
+// parseGoModGraph builds the tree of the main module from go mod graph edges.
+// Modules reached a second time are marked as duplicates without children.
+func parseGoModGraph(lines []string) *DependencyGraph {
+	graph := &DependencyGraph{Format: "go", Dependencies: []*TreeNode{}}
+	edges := make(map[string][]string)
+	var main string
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if main == "" {
+			main = fields[0]
+		}
+		edges[fields[0]] = append(edges[fields[0]], fields[1])
+	}
+	if main == "" {
+		return graph
+	}
+	graph.Project = &TreeNode{Package: main}
+
+	expanded := make(map[string]bool)
+	var build func(module string) *TreeNode
+	build = func(module string) *TreeNode {
+		path, version, _ := runenv.CutLast(module, "@")
+		node := &TreeNode{Package: path, Version: version}
+		if expanded[module] {
+			if len(edges[module]) > 0 {
+				node.Omitted = omittedDuplicate
+			}
+			return node
+		}
+		expanded[module] = true
+		for _, child := range edges[module] {
+			node.Children = append(node.Children, build(child))
+		}
+		return node
+	}
+
+	expanded[main] = true
+	for _, dep := range edges[main] {
+		graph.Dependencies = append(graph.Dependencies, build(dep))
+	}
+	return graph
+}
+
+// WriteDependencyGraph parses deps-tree.txt and writes it as deps-tree.json
+func WriteDependencyGraph(depsPath string) error {
+	if _, err := os.Stat(depsPath); err != nil {
+		runenv.Logger.Warnf("No dependency tree to parse: %s", depsPath)
+		return nil
+	}
+	graph, err := ReadDependencyTree(depsPath)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(graph, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode dependency tree: %v", err)
+	}
+	outputPath := dependencyGraphPath(depsPath)
+	if err := os.WriteFile(outputPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write dependency tree: %v", err)
+	}
+
+	runenv.Logger.Infof("Parsed %d direct dependencies into %s", len(graph.Dependencies), outputPath)
+	return nil
+}
+
+// FindDependency returns the shortest path from a direct dependency to the
+// package version, nil when it is not in the tree. Entries that are not
+// resolved (conflict losers, constraints) are skipped.
+func FindDependency(roots []*TreeNode, pkg, version string) []*TreeNode {
+	queue := make([][]*TreeNode, 0, len(roots))
+	for _, root := range roots {
+		if root.Resolved() {
+			queue = append(queue, []*TreeNode{root})
+		}
+	}
+
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+
+		node := path[len(path)-1]
+		if node.Package == pkg && node.Version == version {
+			return path
+		}
+		for _, child := range node.Children {
+			if child.Resolved() {
+				queue = append(queue, append(path[:len(path):len(path)], child))
+			}
+		}
+	}
+	return nil
+}
+
+// Maven dependency scopes, the values accepted by --scopes
+var DependencyScopes = []string{"compile", "runtime", "provided", "system", "test"}
+
+// Gradle configurations included in the SBOM, by the scope they stand for
+var gradleScopeConfigurations = map[string][]string{
+	"compile":  {"compileClasspath"},
+	"runtime":  {"runtimeClasspath"},
+	"provided": {"annotationProcessor"},
+	"test":     {"testCompileClasspath", "testRuntimeClasspath"},
+}
+
+// ParseScopes splits and validates the --scopes flag value, nil means all scopes
+func ParseScopes(value string) ([]string, error) {
+	var scopes []string
+	for _, scope := range strings.Split(value, ",") {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if scope == "" {
+			continue
+		}
+		if !slices.Contains(DependencyScopes, scope) {
+			return nil, fmt.Errorf("unknown scope: %s (expected %s)", scope, strings.Join(DependencyScopes, ", "))
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+// ScopeIncluded reports whether dependencies of the scope are scanned
+func ScopeIncluded(scopes []string, scope string) bool {
+	if len(scopes) == 0 {
+		return true
+	}
+	if scope == "" {
+		scope = "compile"
+	}
+	return slices.Contains(scopes, scope)
+}
+
+// gradleConfigurationScope maps a Gradle configuration onto the Maven scope it resembles
+func gradleConfigurationScope(configuration string) string {
+	name := strings.ToLower(configuration)
+	switch {
+	case strings.Contains(name, "test"):
+		return "test"
+	case strings.HasPrefix(name, "runtime"):
+		return "runtime"
+	case strings.Contains(name, "compileonly"), strings.Contains(name, "annotationprocessor"), strings.HasPrefix(name, "kapt"):
+		return "provided"
+	}
+	return "compile"
+}
+
+// FilterDependencyTree removes the dependencies outside the scopes from
+// deps-tree.txt: Maven entries together with their subtrees, Gradle
+// configurations as a whole. go mod graph output has no scopes.
+func FilterDependencyTree(depsPath string, scopes []string) error {
+	if len(scopes) == 0 {
+		return nil
+	}
+	if _, err := os.Stat(depsPath); err != nil {
+		return nil
+	}
+	lines, err := readTreeLines(depsPath)
+	if err != nil {
+		return err
+	}
+	if isGoModGraph(lines) {
+		return nil
+	}
+
+	var kept []string
+	removed := 0
+	skipDepth := 0          // depth of the excluded Maven entry whose subtree is skipped
+	skipConfiguration := "" // excluded Gradle configuration
+	for _, line := range lines {
+		depth, content, gradle := treeLineDepth(line)
+		if depth == 0 {
+			if m := gradleConfigurationPattern.FindStringSubmatch(line); m != nil && !strings.Contains(line, ":") {
+				skipConfiguration = ""
+				if !ScopeIncluded(scopes, gradleConfigurationScope(m[1])) {
+					skipConfiguration = m[1]
+				}
+			}
+			if skipConfiguration == "" {
+				kept = append(kept, line)
+			}
+			continue
+		}
+
+		if skipConfiguration != "" || (skipDepth > 0 && depth > skipDepth) {
+			removed++
+			continue
+		}
+		skipDepth = 0
+
+		if !gradle {
+			if node := parseTreeCoordinates(content, false); node != nil && !ScopeIncluded(scopes, node.Scope) {
+				skipDepth = depth
+				removed++
+				continue
+			}
+		}
+		kept = append(kept, line)
+	}
+
+	if err := os.WriteFile(depsPath, []byte(strings.Join(kept, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write dependency tree: %v", err)
+	}
+	runenv.Logger.Infof("Removed %d dependency tree entries outside the %s scopes", removed, strings.Join(scopes, ", "))
+	return nil
+}
@@ -0,0 +1,134 @@
+package sbom
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// formatGraph prints a node per line, indented by its depth
+func formatGraph(nodes []*TreeNode, depth int) []string {
+	var lines []string
+	for _, n := range nodes {
+		line := fmt.Sprintf("%s%s %s", strings.Repeat("  ", depth), n.Package, n.Version)
+		if n.Scope != "" {
+			line += " " + n.Scope
+		}
+		if n.Optional {
+			line += " optional"
+		}
+		if n.Requested != "" {
+			line += " requested=" + n.Requested
+		}
+		if n.Omitted != "" {
+			line += " omitted=" + n.Omitted
+		}
+		lines = append(lines, line)
+		lines = append(lines, formatGraph(n.Children, depth+1)...)
+	}
+	return lines
+}
+
+func TestReadDependencyTree(t *testing.T) {
+	tests := []struct {
+		name    string
+		tree    string
+		format  string
+		project string
+		want    []string
+	}{
+		{
+			name: "maven verbose",
+			tree: `com.example:app:jar:1.0
++- org.springframework:spring-core:jar:6.0.0:compile
+|  \- org.springframework:spring-jcl:jar:6.0.0:compile
++- com.fasterxml.jackson.core:jackson-databind:jar:2.15.2:compile (version managed from 2.14.0)
+|  +- (com.fasterxml.jackson.core:jackson-core:jar:2.15.0:compile - omitted for conflict with 2.15.2)
+|  \- (org.springframework:spring-jcl:jar:6.0.0:compile - omitted for duplicate)
++- io.netty:netty-tcnative:jar:linux-x86_64:2.0.61.Final:runtime (optional)
+\- junit:junit:jar:4.13.2:test
+`,
+			format:  "maven",
+			project: "com.example:app 1.0",
+			want: []string{
+				"org.springframework:spring-core 6.0.0 compile",
+				"  org.springframework:spring-jcl 6.0.0 compile",
+				"com.fasterxml.jackson.core:jackson-databind 2.15.2 compile requested=2.14.0",
+				"  com.fasterxml.jackson.core:jackson-core 2.15.2 compile requested=2.15.0 omitted=conflict",
+				"  org.springframework:spring-jcl 6.0.0 compile omitted=duplicate",
+				"io.netty:netty-tcnative 2.0.61.Final runtime optional",
+				"junit:junit 4.13.2 test",
+			},
+		},
+		{
+			name: "gradle",
+			tree: `
+------------------------------------------------------------
+Root project 'app'
+------------------------------------------------------------
+
+compileClasspath - Compile classpath for source set 'main'.
++--- org.springframework:spring-core:6.0.0
+|    \--- org.springframework:spring-jcl:6.0.0
++--- com.google.guava:guava:31.0-jre -> 32.1.2-jre
+|    \--- org.springframework:spring-jcl:6.0.0 (*)
+\--- org.yaml:snakeyaml:2.0 (c)
+
+testCompileClasspath - Compile classpath for source set 'test'.
+\--- junit:junit:4.13.2
+
+(*) - dependencies omitted (listed previously)
+`,
+			format: "gradle",
+			want: []string{
+				"org.springframework:spring-core 6.0.0 compileClasspath",
+				"  org.springframework:spring-jcl 6.0.0 compileClasspath",
+				"com.google.guava:guava 32.1.2-jre compileClasspath requested=31.0-jre",
+				"  org.springframework:spring-jcl 6.0.0 compileClasspath omitted=duplicate",
+				"org.yaml:snakeyaml 2.0 compileClasspath omitted=constraint",
+				"junit:junit 4.13.2 testCompileClasspath",
+			},
+		},
+		{
+			name: "go mod graph",
+			tree: `example.com/app github.com/google/uuid@v1.3.0
+example.com/app golang.org/x/text@v0.14.0
+example.com/app golang.org/x/net@v0.17.0
+golang.org/x/net@v0.17.0 golang.org/x/text@v0.14.0
+golang.org/x/text@v0.14.0 golang.org/x/tools@v0.6.0
+`,
+			format:  "go",
+			project: "example.com/app ",
+			want: []string{
+				"github.com/google/uuid v1.3.0",
+				"golang.org/x/text v0.14.0",
+				"  golang.org/x/tools v0.6.0",
+				"golang.org/x/net v0.17.0",
+				"  golang.org/x/text v0.14.0 omitted=duplicate",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph, err := ReadDependencyTree(writeLockfile(t, "deps-tree.txt", tt.tree))
+			if err != nil {
+				t.Fatalf("ReadDependencyTree() error = %v", err)
+			}
+			if graph.Format != tt.format {
+				t.Errorf("format = %s, want %s", graph.Format, tt.format)
+			}
+			project := ""
+			if graph.Project != nil {
+				project = graph.Project.Package + " " + graph.Project.Version
+			}
+			if project != tt.project {
+				t.Errorf("project = %q, want %q", project, tt.project)
+			}
+			if got := formatGraph(graph.Dependencies, 0); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dependencies =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package sbom
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+// goModule is a module requirement or a module of the build list
+type goModule struct {
+	Path    string
+	Version string
+}
+
+// goModFile holds the parts of go.mod that matter for the SBOM
+type goModFile struct {
+	Module    string
+	GoVersion string
+	Toolchain string
+	Require   []goModule
+	Replace   map[string]goModule
+}
+
+// CopyGoModule copies go.mod and go.sum into dstDir
+func CopyGoModule(goMod, dstDir string) (string, error) {
+	dstGoMod := filepath.Join(dstDir, "go.mod")
+	if err := runenv.CopyFile(goMod, dstGoMod); err != nil {
+		return "", err
+	}
+
+	goSum := filepath.Join(filepath.Dir(goMod), "go.sum")
+	if _, err := os.Stat(goSum); err == nil {
+		if err := runenv.CopyFile(goSum, filepath.Join(dstDir, "go.sum")); err != nil {
+			return "", err
+		}
+	}
+	return dstGoMod, nil
+}
+
+// GenerateGoSBOM builds the SBOM of a Go module. The build list comes from
+// "go list -m all" when Go is installed, otherwise from the go.mod requirements.
+// The module graph is written to depsPath unless it is empty.
+func GenerateGoSBOM(ctx context.Context, goMod, depsPath, sbomPath string) error {
+	modFile, err := parseGoMod(goMod)
+	if err != nil {
+		return err
+	}
+
+	var modules []goModule
+	if _, err := exec.LookPath(runenv.SettingsOf(ctx).ToolPath("go")); err == nil {
+		if modules, err = goListModules(ctx, goMod); err != nil {
+			return err
+		}
+		if depsPath != "" {
+			if err := writeGoModGraph(ctx, goMod, depsPath); err != nil {
+				runenv.Logger.Warnf("Failed to write module graph: %v", err)
+			}
+		}
+	} else {
+		runenv.Logger.Warn("Go is not installed, reading requirements from go.mod only")
+		modules = modFile.requirements()
+	}
+
+	var components []Component
+	for _, m := range modules {
+		if m.Path == modFile.Module || m.Version == "" {
+			continue
+		}
+		components = append(components, goComponent(m.Path, m.Version))
+	}
+
+	// Vulnerabilities of the standard library are reported for the "stdlib" module
+	if version := modFile.goToolchainVersion(); version != "" {
+		components = append(components, Component{Type: "golang", Name: "stdlib", Version: version})
+	}
+
+	runenv.Logger.Infof("Found %d Go modules in %s", len(components), filepath.Base(goMod))
+	return WriteCycloneDX(sbomPath, components)
+}
+
+// goComponent splits a module path into purl namespace and name
+func goComponent(path, version string) Component {
+	c := Component{Type: "golang", Name: path, Version: version}
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		c.Namespace, c.Name = path[:i], path[i+1:]
+	}
+	return c
+}
+
+func goListModules(ctx context.Context, goMod string) ([]goModule, error) {
+	cmd := runenv.Command(ctx, runenv.SettingsOf(ctx).ToolPath("go"), "list", "-mod=mod", "-m", "all")
+	cmd.Dir = filepath.Dir(goMod)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list failed: %v\n%s", err, stderr.String())
+	}
+
+	var modules []goModule
+	for _, line := range strings.Split(string(output), "\n") {
+		// Replaced modules are printed as "path version => replacement version"
+		if _, replacement, ok := strings.Cut(line, "=>"); ok {
+			fields := strings.Fields(replacement)
+			if len(fields) == 2 {
+				modules = append(modules, goModule{Path: fields[0], Version: fields[1]})
+			}
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			modules = append(modules, goModule{Path: fields[0], Version: fields[1]})
+		}
+	}
+	return modules, nil
+}
+
+func writeGoModGraph(ctx context.Context, goMod, outputPath string) error {
+	cmd := runenv.Command(ctx, runenv.SettingsOf(ctx).ToolPath("go"), "mod", "graph")
+	cmd.Dir = filepath.Dir(goMod)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("go mod graph failed: %v\n%s", err, stderr.String())
+	}
+
+	if err := os.WriteFile(outputPath, output, 0644); err != nil {
+		return fmt.Errorf("failed to write module graph: %v", err)
+	}
+
+	runenv.Logger.Infof("Dependency tree written to %s", outputPath)
+	return nil
+}
+
+// parseGoMod reads the module, go, toolchain, require and replace directives
+func parseGoMod(path string) (*goModFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read go.mod: %v", err)
+	}
+	defer file.Close()
+
+	modFile := &goModFile{Replace: make(map[string]goModule)}
+	var block string
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if block != "" {
+			if fields[0] == ")" {
+				block = ""
+				continue
+			}
+			modFile.directive(block, fields)
+			continue
+		}
+
+		if len(fields) == 2 && fields[1] == "(" {
+			block = fields[0]
+			continue
+		}
+		modFile.directive(fields[0], fields[1:])
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return modFile, nil
+}
+
+func (m *goModFile) directive(verb string, args []string) {
+	for i := range args {
+		args[i] = strings.Trim(args[i], `"`)
+	}
+
+	switch verb {
+	case "module":
+		if len(args) > 0 {
+			m.Module = args[0]
+		}
+	case "go":
+		if len(args) > 0 {
+			m.GoVersion = args[0]
+		}
+	case "toolchain":
+		if len(args) > 0 {
+			m.Toolchain = args[0]
+		}
+	case "require":
+		if len(args) >= 2 {
+			m.Require = append(m.Require, goModule{Path: args[0], Version: args[1]})
+		}
+	case "replace":
+		// old [version] => new [version]
+		for i, arg := range args {
+			if arg != "=>" || i == 0 || i+1 >= len(args) {
+				continue
+			}
+			replacement := goModule{Path: args[i+1]}
+			if i+2 < len(args) {
+				replacement.Version = args[i+2]
+			}
+			m.Replace[args[0]] = replacement
+		}
+	}
+}
+
+// requirements applies replace directives to the required modules. Local
+// replacements (paths without a version) are dropped.
+func (m *goModFile) requirements() []goModule {
+	var modules []goModule
+	for _, r := range m.Require {
+		if replacement, ok := m.Replace[r.Path]; ok {
+			if replacement.Version == "" {
+				continue
+			}
+			r = replacement
+		}
+		modules = append(modules, r)
+	}
+	return modules
+}
+
+// goToolchainVersion returns the Go version used to build the module
+func (m *goModFile) goToolchainVersion() string {
+	if version := strings.TrimPrefix(m.Toolchain, "go"); version != "" && version != "default" {
+		return version
+	}
+	return m.GoVersion
+}
@@ -0,0 +1,114 @@
+package sbom
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGoMod(t *testing.T) {
+	tests := []struct {
+		name      string
+		goMod     string
+		module    string
+		toolchain string
+		want      []goModule
+	}{
+		{
+			name: "require block",
+			goMod: `module example.com/app
+
+go 1.21
+
+require (
+	github.com/google/uuid v1.3.0
+	golang.org/x/text v0.14.0 // indirect
+)
+`,
+			module:    "example.com/app",
+			toolchain: "1.21",
+			want: []goModule{
+				{Path: "github.com/google/uuid", Version: "v1.3.0"},
+				{Path: "golang.org/x/text", Version: "v0.14.0"},
+			},
+		},
+		{
+			name: "single requirements and toolchain",
+			goMod: `module "example.com/app"
+
+go 1.21
+toolchain go1.22.1
+
+require github.com/google/uuid v1.3.0
+require golang.org/x/text v0.14.0
+`,
+			module:    "example.com/app",
+			toolchain: "1.22.1",
+			want: []goModule{
+				{Path: "github.com/google/uuid", Version: "v1.3.0"},
+				{Path: "golang.org/x/text", Version: "v0.14.0"},
+			},
+		},
+		{
+			name: "replace directives",
+			goMod: `module example.com/app
+
+go 1.20
+toolchain default
+
+require (
+	github.com/google/uuid v1.3.0
+	golang.org/x/text v0.14.0
+	example.com/local v0.0.0
+)
+
+replace golang.org/x/text => golang.org/x/text v0.14.1
+
+replace (
+	github.com/google/uuid v1.3.0 => github.com/fork/uuid v1.3.1
+	example.com/local => ../local
+)
+`,
+			module:    "example.com/app",
+			toolchain: "1.20",
+			want: []goModule{
+				{Path: "github.com/fork/uuid", Version: "v1.3.1"},
+				{Path: "golang.org/x/text", Version: "v0.14.1"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modFile, err := parseGoMod(writeLockfile(t, "go.mod", tt.goMod))
+			if err != nil {
+				t.Fatalf("parseGoMod() error = %v", err)
+			}
+			if modFile.Module != tt.module {
+				t.Errorf("module = %q, want %q", modFile.Module, tt.module)
+			}
+			if got := modFile.goToolchainVersion(); got != tt.toolchain {
+				t.Errorf("goToolchainVersion() = %q, want %q", got, tt.toolchain)
+			}
+			if got := modFile.requirements(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("requirements() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGoComponent(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"github.com/google/uuid", "pkg:golang/github.com/google/uuid@v1.3.0"},
+		{"golang.org/x/text", "pkg:golang/golang.org/x/text@v1.3.0"},
+		{"gopkg.in/yaml.v3", "pkg:golang/gopkg.in/yaml.v3@v1.3.0"},
+	}
+
+	for _, tt := range tests {
+		if got := goComponent(tt.path, "v1.3.0").PURL(); got != tt.want {
+			t.Errorf("goComponent(%s) = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package sbom
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+const cyclonedxGradlePluginVersion = "1.8.2"
+
+// Init script that applies the CycloneDX plugin without touching the project's build files
+const cyclonedxGradleInitScript = `initscript {
+    repositories {
+        gradlePluginPortal()
+    }
+    dependencies {
+        classpath "org.cyclonedx:cyclonedx-gradle-plugin:%s"
+    }
+}
+
+rootProject {
+    apply plugin: org.cyclonedx.gradle.CycloneDxPlugin
+%s}
+`
+
+// Files that are copied next to the Gradle build script
+var gradleSupportFiles = []string{
+	"settings.gradle",
+	"settings.gradle.kts",
+	"gradle.properties",
+}
+
+// CopyGradleProject copies the build script and its settings into dstDir
+func CopyGradleProject(buildFile, dstDir string) (string, error) {
+	dstBuildFile := filepath.Join(dstDir, filepath.Base(buildFile))
+	if err := runenv.CopyFile(buildFile, dstBuildFile); err != nil {
+		return "", err
+	}
+
+	srcDir := filepath.Dir(buildFile)
+	for _, name := range gradleSupportFiles {
+		src := filepath.Join(srcDir, name)
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		if err := runenv.CopyFile(src, filepath.Join(dstDir, name)); err != nil {
+			return "", err
+		}
+	}
+
+	return dstBuildFile, nil
+}
+
+func RunGradleDependencies(ctx context.Context, buildFile, outputPath string) error {
+	s := runenv.SettingsOf(ctx)
+	absProjectDir, err := filepath.Abs(filepath.Dir(buildFile))
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %v", err)
+	}
+
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %v", err)
+	}
+	defer outputFile.Close()
+
+	cmd := runenv.Command(ctx, s.ToolPath("gradle"), append([]string{
+		"dependencies",
+		"-p", absProjectDir,
+		"--console=plain",
+		"-q"}, gradleProxyArgs(s)...)...)
+
+	var stderr bytes.Buffer
+	cmd.Dir = absProjectDir
+	cmd.Env = append(cmd.Environ(), runenv.JavaProxyEnv()...)
+	cmd.Stdout = outputFile
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("gradle command failed: %v\n%s", err, stderr.String())
+	}
+
+	runenv.Logger.Infof("Dependency tree written to %s", outputPath)
+	return nil
+}
+
+func GenerateGradleCycloneDX(ctx context.Context, buildFile, outputPath string, scopes []string) error {
+	s := runenv.SettingsOf(ctx)
+	absProjectDir, err := filepath.Abs(filepath.Dir(buildFile))
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %v", err)
+	}
+
+	absOutputPath, err := filepath.Abs(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %v", err)
+	}
+
+	initScript := filepath.Join(absProjectDir, "cyclonedx-init.gradle")
+	// Limit the BOM to the configurations standing for the requested scopes
+	var includeConfigs string
+	if len(scopes) > 0 {
+		var configurations []string
+		for _, scope := range scopes {
+			for _, configuration := range gradleScopeConfigurations[scope] {
+				configurations = append(configurations, `"`+configuration+`"`)
+			}
+		}
+		includeConfigs = fmt.Sprintf("    cyclonedxBom {\n        includeConfigs = [%s]\n    }\n", strings.Join(configurations, ", "))
+	}
+
+	script := fmt.Sprintf(cyclonedxGradleInitScript, cyclonedxGradlePluginVersion, includeConfigs)
+	if err := os.WriteFile(initScript, []byte(script), 0644); err != nil {
+		return fmt.Errorf("failed to write init script: %v", err)
+	}
+	defer os.Remove(initScript)
+
+	cmd := runenv.Command(ctx, s.ToolPath("gradle"), append([]string{
+		"cyclonedxBom",
+		"-p", absProjectDir,
+		"-I", initScript,
+		"--console=plain",
+		"-q"}, gradleProxyArgs(s)...)...)
+
+	cmd.Dir = absProjectDir
+	cmd.Env = append(cmd.Environ(), runenv.JavaProxyEnv()...)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("cyclonedx generation failed: %v\n%s", err, string(output))
+	}
+
+	// build/reports/bom.xml'i sbom.xml olarak taşı
+	srcPath := filepath.Join(absProjectDir, "build", "reports", "bom.xml")
+	if err := os.Rename(srcPath, absOutputPath); err != nil {
+		return fmt.Errorf("failed to move SBOM to output dir: %v", err)
+	}
+
+	// Gradle çıktılarını temizle
+	if s.KeepTemp {
+		runenv.Logger.Infof("Keeping Gradle build directory %s", filepath.Join(absProjectDir, "build"))
+	} else {
+		for _, dir := range []string{"build", ".gradle"} {
+			if err := os.RemoveAll(filepath.Join(absProjectDir, dir)); err != nil {
+				runenv.Logger.Warnf("Failed to clean up %s directory: %v", dir, err)
+			}
+		}
+	}
+
+	runenv.Logger.Infof("CycloneDX BOM written to %s", outputPath)
+	return nil
+}
+
+// Init script that sets the proxy passwords of Gradle from JavaProxyEnv
+const gradleProxyInitScript = `["http", "https"].each { scheme ->
+    def password = System.getenv("SBOM_SCANNER_" + scheme.toUpperCase() + "_PROXY_PASSWORD")
+    if (password != null) {
+        System.setProperty(scheme + ".proxyPassword", password)
+    }
+}
+`
+
+// gradleProxyArgs returns the Java system properties of the proxies for
+// Gradle. The passwords are set from JavaProxyEnv by an init script.
+func gradleProxyArgs(s *runenv.Settings) []string {
+	proxies, nonProxyHosts := runenv.JavaProxies()
+	if len(proxies) == 0 {
+		return nil
+	}
+	var args []string
+	for _, proxy := range proxies {
+		args = append(args, "-D"+proxy.Scheme+".proxyHost="+proxy.Host, "-D"+proxy.Scheme+".proxyPort="+proxy.Port)
+		if proxy.Username != "" {
+			args = append(args, "-D"+proxy.Scheme+".proxyUser="+proxy.Username)
+		}
+	}
+	if nonProxyHosts != "" {
+		args = append(args, "-Dhttp.nonProxyHosts="+nonProxyHosts)
+	}
+	if len(runenv.JavaProxyEnv()) > 0 {
+		path := filepath.Join(s.CacheDir("gradle-init"), "proxy.gradle")
+		if err := runenv.WriteGeneratedFile(path, []byte(gradleProxyInitScript)); err != nil {
+			runenv.Logger.Warnf("Failed to write the Gradle proxy init script, Gradle runs without the proxy password: %v", err)
+		} else {
+			args = append(args, "-I", path)
+		}
+	}
+	return args
+}
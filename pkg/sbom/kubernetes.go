@@ -0,0 +1,263 @@
+package sbom
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Workload is a Kubernetes object that runs containers
+type Workload struct {
+	Kind      string          `json:"kind"`
+	Namespace string          `json:"namespace,omitempty"`
+	Name      string          `json:"name"`
+	Source    string          `json:"source"` // manifest file, or the chart
+	Images    []workloadImage `json:"images"`
+}
+
+// workloadImage is the image of a container with the findings of its scan
+type workloadImage struct {
+	Container       string         `json:"container"`
+	Image           string         `json:"image"`
+	OutputDir       string         `json:"output_dir,omitempty"`
+	Vulnerabilities int            `json:"vulnerabilities"`
+	Severities      map[string]int `json:"severities,omitempty"`
+	Error           string         `json:"error,omitempty"`
+}
+
+// Image references that are filled in at deploy time cannot be scanned
+var unresolvedImagePattern = regexp.MustCompile(`\{\{|\$\(|\$\{`)
+
+// kubernetesManifest is the part of a manifest that holds the pod template
+type kubernetesManifest struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+	Spec  yaml.Node            `yaml:"spec"`
+	Items []kubernetesManifest `yaml:"items"` // of a List
+}
+
+// podSpec lists the containers of a pod
+type podSpec struct {
+	Containers          []kubernetesContainer `yaml:"containers"`
+	InitContainers      []kubernetesContainer `yaml:"initContainers"`
+	EphemeralContainers []kubernetesContainer `yaml:"ephemeralContainers"`
+}
+
+type kubernetesContainer struct {
+	Name  string `yaml:"name"`
+	Image string `yaml:"image"`
+}
+
+// podSpec returns the pod template of a workload, false for other kinds
+func (m kubernetesManifest) podSpec() (podSpec, bool) {
+	var spec podSpec
+	var err error
+	switch m.Kind {
+	case "Pod":
+		err = m.Spec.Decode(&spec)
+	case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "ReplicationController", "Job":
+		var s struct {
+			Template struct {
+				Spec podSpec `yaml:"spec"`
+			} `yaml:"template"`
+		}
+		err = m.Spec.Decode(&s)
+		spec = s.Template.Spec
+	case "CronJob":
+		var s struct {
+			JobTemplate struct {
+				Spec struct {
+					Template struct {
+						Spec podSpec `yaml:"spec"`
+					} `yaml:"template"`
+				} `yaml:"spec"`
+			} `yaml:"jobTemplate"`
+		}
+		err = m.Spec.Decode(&s)
+		spec = s.JobTemplate.Spec.Template.Spec
+	default:
+		return spec, false
+	}
+	return spec, err == nil
+}
+
+// isHelmChart reports whether dir is a Helm chart
+func isHelmChart(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, "Chart.yaml"))
+	return err == nil && !info.IsDir()
+}
+
+// FindKubernetesImages reads the workloads of the manifests at target, a
+// YAML file, a directory of them or a Helm chart, and returns one project
+// per distinct image. The chart is rendered with helm template and the
+// values files.
+func FindKubernetesImages(ctx context.Context, target string, helmValues []string) ([]Project, []Workload, error) {
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil, nil, fmt.Errorf("manifest not found: %s", target)
+	}
+
+	var workloads []Workload
+	switch {
+	case info.IsDir() && isHelmChart(target):
+		manifests, err := renderHelmChart(ctx, target, helmValues)
+		if err != nil {
+			return nil, nil, err
+		}
+		if workloads, err = parseWorkloads(manifests, target); err != nil {
+			return nil, nil, err
+		}
+	case info.IsDir():
+		err := filepath.WalkDir(target, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != target && (strings.HasPrefix(d.Name(), ".") || isHelmChart(path)) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if ext := filepath.Ext(path); ext != ".yaml" && ext != ".yml" {
+				return nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			found, err := parseWorkloads(data, path)
+			if err != nil {
+				// Directories of manifests often hold other YAML files too
+				runenv.Logger.Warnf("Skipping %s: %v", path, err)
+				return nil
+			}
+			workloads = append(workloads, found...)
+			return nil
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to walk %s: %v", target, err)
+		}
+	default:
+		data, err := os.ReadFile(target)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read manifest: %v", err)
+		}
+		if workloads, err = parseWorkloads(data, target); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var projects []Project
+	seen := make(map[string]bool)
+	for _, w := range workloads {
+		for _, image := range w.Images {
+			if seen[image.Image] {
+				continue
+			}
+			seen[image.Image] = true
+			projects = append(projects, Project{Tool: BuildToolImage, File: image.Image, Rel: image.Image, Output: ImageOutputDir(image.Image)})
+		}
+	}
+	if len(projects) == 0 {
+		return nil, nil, fmt.Errorf("no container images found in %s", target)
+	}
+	runenv.Logger.Infof("Found %d workloads with %d images in %s", len(workloads), len(projects), target)
+	return projects, workloads, nil
+}
+
+// parseWorkloads returns the workloads of a multi-document YAML stream
+func parseWorkloads(data []byte, source string) ([]Workload, error) {
+	var workloads []Workload
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var m kubernetesManifest
+		err := decoder.Decode(&m)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", source, err)
+		}
+		manifests := append([]kubernetesManifest{m}, m.Items...)
+		for _, m := range manifests {
+			spec, ok := m.podSpec()
+			if !ok {
+				continue
+			}
+			w := Workload{Kind: m.Kind, Namespace: m.Metadata.Namespace, Name: m.Metadata.Name, Source: source}
+			for _, containers := range [][]kubernetesContainer{spec.InitContainers, spec.Containers, spec.EphemeralContainers} {
+				for _, c := range containers {
+					if c.Image == "" || unresolvedImagePattern.MatchString(c.Image) {
+						if c.Image != "" {
+							runenv.Logger.Warnf("Skipping unresolved image %s of %s/%s", c.Image, m.Kind, m.Metadata.Name)
+						}
+						continue
+					}
+					w.Images = append(w.Images, workloadImage{Container: c.Name, Image: c.Image})
+				}
+			}
+			if len(w.Images) > 0 {
+				workloads = append(workloads, w)
+			}
+		}
+	}
+	return workloads, nil
+}
+
+// renderHelmChart returns the manifests of a chart rendered with its default
+// values and the values files
+func renderHelmChart(ctx context.Context, chart string, values []string) ([]byte, error) {
+	s := runenv.SettingsOf(ctx)
+	if _, err := exec.LookPath(s.ToolPath("helm")); err != nil {
+		return nil, runenv.MissingTool("Helm is not installed, it is needed to render the chart %s", chart)
+	}
+	// The release is named after the chart directory
+	abs, err := filepath.Abs(chart)
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"template", filepath.Base(abs), chart}
+	for _, v := range values {
+		args = append(args, "--values", v)
+	}
+	cmd := runenv.Command(ctx, s.ToolPath("helm"), args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("helm template failed: %v\n%s", err, strings.TrimSpace(stderr.String()))
+	}
+	return output, nil
+}
+
+// ImageOutputDir returns the output subdirectory of an image, e.g.
+// images/ghcr.io_org_app_1.2.0
+func ImageOutputDir(image string) string {
+	name := strings.NewReplacer("/", "_", ":", "_", "@", "_").Replace(image)
+	return filepath.Join("images", name)
+}
+
+// GenerateImageSBOM catalogs the packages of a container image with syft.
+// The image is pulled from its registry unless it is available locally.
+func GenerateImageSBOM(ctx context.Context, image, sbomPath string) error {
+	cmd := runenv.Command(ctx, runenv.SettingsOf(ctx).ToolPath("syft"), image, "-o", "cyclonedx-xml="+sbomPath, "-q")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("syft failed to catalog %s: %v\n%s", image, err, strings.TrimSpace(string(output)))
+	}
+	runenv.Logger.Infof("Generated the SBOM of image %s", image)
+	return nil
+}
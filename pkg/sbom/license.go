@@ -0,0 +1,98 @@
+package sbom
+
+import (
+	"regexp"
+	"strings"
+)
+
+// SPDX license ids written as <id> in generated SBOMs, the lookup key is lower case
+var spdxLicenses = make(map[string]string)
+
+func init() {
+	for _, id := range []string{
+		"0BSD", "AFL-3.0", "AGPL-3.0", "AGPL-3.0-only", "AGPL-3.0-or-later", "Apache-1.1", "Apache-2.0",
+		"Artistic-2.0", "BSD-2-Clause", "BSD-3-Clause", "BSL-1.0", "CC-BY-3.0", "CC-BY-4.0", "CC0-1.0",
+		"CDDL-1.0", "CDDL-1.1", "CPL-1.0", "EPL-1.0", "EPL-2.0", "EUPL-1.1", "EUPL-1.2",
+		"GPL-2.0", "GPL-2.0-only", "GPL-2.0-or-later", "GPL-2.0-with-classpath-exception",
+		"GPL-3.0", "GPL-3.0-only", "GPL-3.0-or-later", "ISC",
+		"LGPL-2.0", "LGPL-2.0-only", "LGPL-2.0-or-later", "LGPL-2.1", "LGPL-2.1-only", "LGPL-2.1-or-later",
+		"LGPL-3.0", "LGPL-3.0-only", "LGPL-3.0-or-later", "MIT", "MIT-0", "MPL-1.1", "MPL-2.0",
+		"MS-PL", "OFL-1.1", "PostgreSQL", "PSF-2.0", "Python-2.0", "Ruby", "Unicode-DFS-2016",
+		"Unlicense", "UPL-1.0", "W3C", "WTFPL", "Zlib",
+	} {
+		spdxLicenses[strings.ToLower(id)] = id
+	}
+}
+
+// Common license names, as found in POMs and package metadata, and their SPDX ids.
+// The first matching pattern wins.
+var licenseAliases = []struct {
+	pattern *regexp.Regexp
+	id      string
+}{
+	{regexp.MustCompile(`apache.*1\.1`), "Apache-1.1"},
+	{regexp.MustCompile(`apache|\basl\b`), "Apache-2.0"},
+	{regexp.MustCompile(`\bmit\b`), "MIT"},
+	{regexp.MustCompile(`affero.*3|agpl.*3`), "AGPL-3.0-only"},
+	{regexp.MustCompile(`(lesser|library).*2\.1|lgpl.*2\.1`), "LGPL-2.1-only"},
+	{regexp.MustCompile(`lesser.*3|lgpl.*3`), "LGPL-3.0-only"},
+	{regexp.MustCompile(`(lesser|library).*2|lgpl.*2`), "LGPL-2.0-only"},
+	{regexp.MustCompile(`(gpl|general public license).*2.*classpath`), "GPL-2.0-with-classpath-exception"},
+	{regexp.MustCompile(`general public license.*(v|version)\s*2|gpl\s*-?v?2`), "GPL-2.0-only"},
+	{regexp.MustCompile(`general public license.*(v|version)\s*3|gpl\s*-?v?3`), "GPL-3.0-only"},
+	{regexp.MustCompile(`eclipse public license.*2|\bepl.*2`), "EPL-2.0"},
+	{regexp.MustCompile(`eclipse public license|\bepl.*1`), "EPL-1.0"},
+	{regexp.MustCompile(`eclipse distribution license|\bedl\b`), "BSD-3-Clause"},
+	{regexp.MustCompile(`mozilla public license.*2|\bmpl.*2`), "MPL-2.0"},
+	{regexp.MustCompile(`mozilla public license.*1\.1|\bmpl.*1\.1`), "MPL-1.1"},
+	{regexp.MustCompile(`(common development and distribution|cddl).*1\.1`), "CDDL-1.1"},
+	{regexp.MustCompile(`common development and distribution|cddl`), "CDDL-1.0"},
+	{regexp.MustCompile(`bsd.*(3|three|new|revised)|(new|revised).*bsd`), "BSD-3-Clause"},
+	{regexp.MustCompile(`bsd.*(2|two|simplified)|simplified.*bsd`), "BSD-2-Clause"},
+	{regexp.MustCompile(`universal permissive`), "UPL-1.0"},
+	{regexp.MustCompile(`boost`), "BSL-1.0"},
+	{regexp.MustCompile(`\bcc0\b`), "CC0-1.0"},
+	{regexp.MustCompile(`unlicense`), "Unlicense"},
+}
+
+// NormalizeLicense maps a license id or name to its SPDX id, unknown names are
+// returned unchanged
+func NormalizeLicense(name string) string {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" || isLicenseExpression(name) {
+		return name
+	}
+	if id, ok := spdxLicenses[strings.ToLower(name)]; ok {
+		return id
+	}
+
+	lower := strings.ToLower(name)
+	for _, alias := range licenseAliases {
+		if alias.pattern.MatchString(lower) {
+			return alias.id
+		}
+	}
+	return name
+}
+
+// LicenseFromURL guesses the license of a POM entry that only has a URL
+func LicenseFromURL(url string) string {
+	lower := strings.ToLower(url)
+	switch {
+	case strings.Contains(lower, "apache.org/licenses/license-2.0"):
+		return "Apache-2.0"
+	case strings.Contains(lower, "opensource.org/licenses/"):
+		return strings.TrimSuffix(strings.TrimSuffix(lower[strings.LastIndex(lower, "/")+1:], ".php"), ".html")
+	}
+	return ""
+}
+
+// isSPDXLicense reports whether name is a known SPDX license id
+func isSPDXLicense(name string) bool {
+	return spdxLicenses[strings.ToLower(name)] == name
+}
+
+// isLicenseExpression reports whether the license is an SPDX expression such as "MIT OR Apache-2.0"
+func isLicenseExpression(license string) bool {
+	return strings.Contains(license, " OR ") || strings.Contains(license, " AND ") || strings.Contains(license, " WITH ")
+}
@@ -0,0 +1,195 @@
+package sbom
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+// SBOM metadata properties naming the inputs of a merged SBOM
+const (
+	propertyMergedFrom   = "sbom-scanner:merged-from"
+	propertyMergedDigest = "sbom-scanner:merged-from:sha256"
+)
+
+// MergeInput is an SBOM combined by merge
+type MergeInput struct {
+	path       string
+	digest     string
+	components []CDXComponent
+	complete   bool // generated without skipped lockfile entries
+}
+
+// MergeFiles returns the SBOMs to merge: the given files, and the module
+// SBOMs of scan output directories
+func MergeFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		found := 0
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && d.Name() == "sbom.xml" {
+				files = append(files, p)
+				found++
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to search %s: %v", path, err)
+		}
+		if found == 0 {
+			return nil, fmt.Errorf("no SBOM found in %s", path)
+		}
+	}
+	return files, nil
+}
+
+// ReadMergeInput reads the components of an SBOM. CycloneDX XML keeps the
+// licenses, the components of other formats are taken from their package
+// URLs, names and versions.
+func ReadMergeInput(path string) (MergeInput, error) {
+	input := MergeInput{path: path, complete: true}
+	digest, err := runenv.FileSHA256(path)
+	if err != nil {
+		return input, fmt.Errorf("failed to read SBOM: %v", err)
+	}
+	input.digest = digest
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return input, fmt.Errorf("failed to read SBOM: %v", err)
+	}
+	if isCycloneDXXML(bytes.TrimSpace(data)) {
+		bom, err := ReadCycloneDXBOM(path)
+		if err != nil {
+			return input, err
+		}
+		var walk func(list []CDXComponent)
+		walk = func(list []CDXComponent) {
+			for _, c := range list {
+				input.components = append(input.components, c)
+				if c.Components != nil {
+					walk(c.Components.Components)
+				}
+			}
+		}
+		walk(bom.Components)
+		if bom.Metadata != nil {
+			for _, property := range bom.Metadata.Properties {
+				if property.Name == propertySkipped {
+					input.complete = false
+				}
+			}
+		}
+		return input, nil
+	}
+
+	packages, err := ReadPackages(path)
+	if err != nil {
+		return input, err
+	}
+	for _, p := range packages {
+		component := CDXComponent{Type: "library", Name: p.Name, Version: p.Version, PURL: p.PURL}
+		// Maven packages are named group:artifact
+		if strings.HasPrefix(p.PURL, "pkg:maven/") {
+			if group, name, ok := strings.Cut(p.Name, ":"); ok {
+				component.Group, component.Name = group, name
+			}
+		}
+		input.components = append(input.components, component)
+	}
+	return input, nil
+}
+
+// mergeKey identifies a component across SBOMs: its package URL without
+// qualifiers, or group, name and version
+func mergeKey(c CDXComponent) string {
+	if c.PURL != "" {
+		return StripPURLQualifiers(c.PURL)
+	}
+	key := c.Name + "@" + c.Version
+	if c.Group != "" {
+		key = c.Group + ":" + key
+	}
+	return key
+}
+
+// Merge combines the components of the inputs, each component once. The
+// metadata names the inputs and a composition per input lists its components,
+// complete as far as the input is known to be.
+func Merge(inputs []MergeInput, name, version string) cdxBOM {
+	bom := cdxBOM{
+		XMLNS:   cyclonedxNamespace,
+		Version: 1,
+		Metadata: &cdxMetadata{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Tools:     []CDXTool{{Name: "sbom-scanner"}},
+		},
+	}
+	if name != "" {
+		bom.Metadata.Component = &CDXComponent{Type: "application", BOMRef: name, Name: name, Version: version}
+	}
+
+	bom.Compositions = &cdxCompositions{}
+	index := make(map[string]int)
+	for _, input := range inputs {
+		bom.Metadata.Properties = append(bom.Metadata.Properties,
+			CDXProperty{Name: propertyMergedFrom, Value: filepath.ToSlash(input.path)},
+			CDXProperty{Name: propertyMergedDigest, Value: input.digest},
+		)
+
+		composition := cdxComposition{Aggregate: "unknown"}
+		if !input.complete {
+			composition.Aggregate = "incomplete"
+		}
+		seen := make(map[string]bool)
+		for _, c := range input.components {
+			if c.Name == "" {
+				continue
+			}
+			key := mergeKey(c)
+			i, ok := index[key]
+			if !ok {
+				c.BOMRef = key
+				c.Components = nil
+				if c.Type == "" {
+					c.Type = "library"
+				}
+				i = len(bom.Components)
+				index[key] = i
+				bom.Components = append(bom.Components, c)
+			} else if bom.Components[i].Licenses == nil {
+				// Formats without licenses may come first
+				bom.Components[i].Licenses = c.Licenses
+			}
+			if !seen[key] {
+				seen[key] = true
+				composition.Assemblies = append(composition.Assemblies, cdxAssembly{Ref: key})
+			}
+		}
+		bom.Compositions.Compositions = append(bom.Compositions.Compositions, composition)
+	}
+
+	// The composition refs stay valid, only the order of the list changes
+	sort.SliceStable(bom.Components, func(i, j int) bool {
+		return bom.Components[i].BOMRef < bom.Components[j].BOMRef
+	})
+	return bom
+}
@@ -0,0 +1,112 @@
+package sbom
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	first := writeLockfile(t, "first.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<bom xmlns="http://cyclonedx.org/schema/bom/1.4" version="1">
+  <components>
+    <component type="library">
+      <name>lodash</name>
+      <version>4.17.21</version>
+      <purl>pkg:npm/lodash@4.17.21</purl>
+    </component>
+    <component type="library">
+      <group>org.example</group>
+      <name>lib</name>
+      <version>1.0</version>
+      <components>
+        <component type="library">
+          <name>chalk</name>
+          <version>5.3.0</version>
+          <purl>pkg:npm/chalk@5.3.0</purl>
+        </component>
+      </components>
+    </component>
+  </components>
+</bom>
+`)
+	second := writeLockfile(t, "second.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<bom xmlns="http://cyclonedx.org/schema/bom/1.4" version="1">
+  <metadata>
+    <properties>
+      <property name="sbom-scanner:skipped">local 0.1.0 (link)</property>
+    </properties>
+  </metadata>
+  <components>
+    <component type="library">
+      <name>lodash</name>
+      <version>4.17.21</version>
+      <licenses><license><id>MIT</id></license></licenses>
+      <purl>pkg:npm/lodash@4.17.21?repository_url=https://registry.example.com</purl>
+    </component>
+    <component type="library">
+      <name>debug</name>
+      <version>4.3.4</version>
+      <purl>pkg:npm/debug@4.3.4</purl>
+    </component>
+  </components>
+</bom>
+`)
+
+	var inputs []MergeInput
+	for _, path := range []string{first, second} {
+		input, err := ReadMergeInput(path)
+		if err != nil {
+			t.Fatalf("ReadMergeInput(%s) error = %v", path, err)
+		}
+		inputs = append(inputs, input)
+	}
+	bom := Merge(inputs, "app", "1.0")
+
+	var refs []string
+	for _, c := range bom.Components {
+		refs = append(refs, c.BOMRef)
+		if c.Components != nil {
+			t.Errorf("component %s kept its nested components", c.BOMRef)
+		}
+		if c.BOMRef == "pkg:npm/lodash@4.17.21" && (c.Licenses == nil || len(c.Licenses.Licenses) != 1) {
+			t.Errorf("license of the second SBOM was not added to lodash")
+		}
+	}
+	wantRefs := []string{"org.example:lib@1.0", "pkg:npm/chalk@5.3.0", "pkg:npm/debug@4.3.4", "pkg:npm/lodash@4.17.21"}
+	if !reflect.DeepEqual(refs, wantRefs) {
+		t.Errorf("components = %v, want %v", refs, wantRefs)
+	}
+
+	if c := bom.Metadata.Component; c == nil || c.Name != "app" || c.Version != "1.0" {
+		t.Errorf("metadata component = %+v, want app 1.0", c)
+	}
+	var from []string
+	for _, p := range bom.Metadata.Properties {
+		if p.Name == propertyMergedFrom {
+			from = append(from, p.Value)
+		}
+	}
+	if len(from) != 2 {
+		t.Errorf("merged-from properties = %v, want both inputs", from)
+	}
+
+	type composition struct {
+		aggregate string
+		refs      []string
+	}
+	var compositions []composition
+	for _, c := range bom.Compositions.Compositions {
+		var refs []string
+		for _, a := range c.Assemblies {
+			refs = append(refs, a.Ref)
+		}
+		compositions = append(compositions, composition{c.Aggregate, refs})
+	}
+	wantCompositions := []composition{
+		{"unknown", []string{"pkg:npm/lodash@4.17.21", "org.example:lib@1.0", "pkg:npm/chalk@5.3.0"}},
+		{"incomplete", []string{"pkg:npm/lodash@4.17.21", "pkg:npm/debug@4.3.4"}},
+	}
+	if !reflect.DeepEqual(compositions, wantCompositions) {
+		t.Errorf("compositions = %+v, want %+v", compositions, wantCompositions)
+	}
+}
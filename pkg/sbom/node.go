@@ -0,0 +1,260 @@
+package sbom
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"gopkg.in/yaml.v3"
+)
+
+// parseNodeLockfile reads the components from a Node lockfile
+func parseNodeLockfile(path string) ([]Component, error) {
+	switch filepath.Base(path) {
+	case "package-lock.json", "npm-shrinkwrap.json":
+		return parsePackageLock(path)
+	case "yarn.lock":
+		return parseYarnLock(path)
+	case "pnpm-lock.yaml":
+		return parsePnpmLock(path)
+	}
+	return nil, fmt.Errorf("unsupported Node lockfile: %s", path)
+}
+
+// GenerateNodeSBOM builds the SBOM from a Node project file.
+// A bare package.json is resolved into a package-lock.json with npm first.
+func GenerateNodeSBOM(ctx context.Context, projectFile, outputPath string) error {
+	lockfile := projectFile
+	if filepath.Base(projectFile) == "package.json" {
+		var err error
+		if lockfile, err = createPackageLock(ctx, projectFile); err != nil {
+			return err
+		}
+	}
+
+	components, err := parseNodeLockfile(lockfile)
+	if err != nil {
+		return err
+	}
+	runenv.Logger.Infof("Found %d Node packages in %s", len(components), filepath.Base(lockfile))
+
+	return WriteCycloneDX(outputPath, components)
+}
+
+func createPackageLock(ctx context.Context, packageJSON string) (string, error) {
+	absProjectDir, err := filepath.Abs(filepath.Dir(packageJSON))
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path: %v", err)
+	}
+
+	cmd := runenv.Command(ctx, runenv.SettingsOf(ctx).ToolPath("npm"),
+		"install",
+		"--package-lock-only",
+		"--ignore-scripts",
+		"--no-audit",
+		"--no-fund")
+
+	cmd.Dir = absProjectDir
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("npm command failed: %v\n%s", err, string(output))
+	}
+
+	return filepath.Join(absProjectDir, "package-lock.json"), nil
+}
+
+// NodeComponent splits a possibly scoped package name into a component
+func NodeComponent(name, version string) Component {
+	c := Component{Type: "npm", Name: name, Version: version}
+	if strings.HasPrefix(name, "@") {
+		if i := strings.Index(name, "/"); i > 0 {
+			c.Namespace = name[:i]
+			c.Name = name[i+1:]
+		}
+	}
+	return c
+}
+
+type packageLock struct {
+	LockfileVersion int                              `json:"lockfileVersion"`
+	Packages        map[string]packageLockPackage    `json:"packages"`
+	Dependencies    map[string]packageLockDependency `json:"dependencies"`
+}
+
+// packageLockPackage is an entry of "packages" (lockfileVersion 2 and 3), its
+// dependencies map names to version ranges and are not needed
+type packageLockPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Link    bool   `json:"link"`
+}
+
+// packageLockDependency is an entry of the nested "dependencies" tree
+// (lockfileVersion 1, kept in version 2 for older npm releases)
+type packageLockDependency struct {
+	Version      string                           `json:"version"`
+	Dependencies map[string]packageLockDependency `json:"dependencies"`
+}
+
+func parsePackageLock(path string) ([]Component, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lockfile: %v", err)
+	}
+
+	var lock packageLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	var components []Component
+
+	// lockfileVersion 2 and 3
+	if len(lock.Packages) > 0 {
+		for key, entry := range lock.Packages {
+			i := strings.LastIndex(key, "node_modules/")
+			if i < 0 || entry.Link || entry.Version == "" {
+				continue
+			}
+			name := entry.Name
+			if name == "" {
+				name = key[i+len("node_modules/"):]
+			}
+			components = append(components, NodeComponent(name, entry.Version))
+		}
+		return components, nil
+	}
+
+	// lockfileVersion 1
+	var walk func(deps map[string]packageLockDependency)
+	walk = func(deps map[string]packageLockDependency) {
+		for name, entry := range deps {
+			if entry.Version != "" && !strings.HasPrefix(entry.Version, "file:") {
+				components = append(components, NodeComponent(name, entry.Version))
+			}
+			walk(entry.Dependencies)
+		}
+	}
+	walk(lock.Dependencies)
+
+	return components, nil
+}
+
+// parseYarnLock handles both the classic (v1) and the berry lockfile format
+func parseYarnLock(path string) ([]Component, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lockfile: %v", err)
+	}
+	defer file.Close()
+
+	var components []Component
+	var name string
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Entry header: "lodash@^4.17.0", lodash@^4.17.21:
+		if !strings.HasPrefix(line, " ") {
+			name = yarnEntryName(strings.TrimSuffix(line, ":"))
+			continue
+		}
+
+		if name == "" {
+			continue
+		}
+
+		field := strings.TrimSpace(line)
+		if !strings.HasPrefix(field, "version") {
+			continue
+		}
+		version := strings.TrimSpace(strings.TrimPrefix(field, "version"))
+		version = strings.Trim(strings.TrimPrefix(version, ":"), ` "`)
+		if version != "" && !strings.HasPrefix(version, "0.0.0-use.local") {
+			components = append(components, NodeComponent(name, version))
+		}
+		name = ""
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	return components, nil
+}
+
+// yarnEntryName extracts the package name from a yarn.lock entry header
+func yarnEntryName(header string) string {
+	spec := strings.TrimSpace(strings.Split(header, ",")[0])
+	spec = strings.Trim(spec, `"`)
+	if spec == "__metadata" {
+		return ""
+	}
+
+	i := strings.LastIndex(spec, "@")
+	if i <= 0 {
+		return ""
+	}
+	if strings.Contains(spec[i:], "workspace:") || strings.Contains(spec[i:], "link:") {
+		return ""
+	}
+	return spec[:i]
+}
+
+type pnpmLock struct {
+	Packages map[string]yaml.Node `yaml:"packages"`
+}
+
+func parsePnpmLock(path string) ([]Component, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lockfile: %v", err)
+	}
+
+	var lock pnpmLock
+	if err := yaml.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	var components []Component
+	for key := range lock.Packages {
+		if name, version := pnpmPackageKey(key); name != "" && version != "" {
+			components = append(components, NodeComponent(name, version))
+		}
+	}
+
+	return components, nil
+}
+
+// pnpmPackageKey splits keys like /lodash/4.17.21 (v5), /lodash@4.17.21 (v6)
+// and lodash@4.17.21(peer@1.0.0) (v9) into name and version
+func pnpmPackageKey(key string) (string, string) {
+	key = strings.TrimPrefix(key, "/")
+	if i := strings.Index(key, "("); i >= 0 {
+		key = key[:i]
+	}
+
+	// v5 keys separate the version with a slash and append peers with an
+	// underscore, e.g. /@babel/core/7.22.0_supports-color@5.5.0
+	if i := strings.LastIndex(key, "/"); i > 0 && i+1 < len(key) && key[i+1] >= '0' && key[i+1] <= '9' {
+		version := key[i+1:]
+		if j := strings.Index(version, "_"); j >= 0 {
+			version = version[:j]
+		}
+		return key[:i], version
+	}
+
+	if i := strings.LastIndex(key, "@"); i > 0 {
+		return key[:i], key[i+1:]
+	}
+	return "", ""
+}
@@ -0,0 +1,314 @@
+package sbom
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// writeLockfile writes a lockfile into a temporary directory
+func writeLockfile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// purls returns the sorted package URLs of the components
+func purls(components []Component) []string {
+	result := make([]string, 0, len(components))
+	for _, c := range components {
+		result = append(result, c.PURL())
+	}
+	sort.Strings(result)
+	return result
+}
+
+func TestParseNodeLockfile(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		lockfile string
+		want     []string
+	}{
+		{
+			name: "package-lock.json v1",
+			file: "package-lock.json",
+			lockfile: `{
+  "name": "app",
+  "version": "1.0.0",
+  "lockfileVersion": 1,
+  "requires": true,
+  "dependencies": {
+    "express": {
+      "version": "4.18.2",
+      "requires": {"debug": "2.6.9"},
+      "dependencies": {
+        "debug": {"version": "2.6.9"}
+      }
+    },
+    "debug": {"version": "4.3.4"},
+    "@babel/core": {"version": "7.22.0"},
+    "local": {"version": "file:../local"}
+  }
+}`,
+			want: []string{
+				"pkg:npm/%40babel/core@7.22.0",
+				"pkg:npm/debug@2.6.9",
+				"pkg:npm/debug@4.3.4",
+				"pkg:npm/express@4.18.2",
+			},
+		},
+		{
+			name: "package-lock.json v2",
+			file: "package-lock.json",
+			lockfile: `{
+  "name": "app",
+  "version": "1.0.0",
+  "lockfileVersion": 2,
+  "requires": true,
+  "packages": {
+    "": {
+      "name": "app",
+      "version": "1.0.0",
+      "dependencies": {"express": "^4.18.0", "local": "file:../local"}
+    },
+    "node_modules/express": {
+      "version": "4.18.2",
+      "dependencies": {"debug": "2.6.9"}
+    },
+    "node_modules/express/node_modules/debug": {"version": "2.6.9"},
+    "node_modules/local": {"resolved": "../local", "link": true},
+    "../local": {"name": "local", "version": "0.1.0"}
+  },
+  "dependencies": {
+    "express": {
+      "version": "4.18.2",
+      "requires": {"debug": "2.6.9"},
+      "dependencies": {
+        "debug": {"version": "2.6.9"}
+      }
+    }
+  }
+}`,
+			want: []string{
+				"pkg:npm/debug@2.6.9",
+				"pkg:npm/express@4.18.2",
+			},
+		},
+		{
+			name: "package-lock.json v3",
+			file: "package-lock.json",
+			lockfile: `{
+  "name": "app",
+  "lockfileVersion": 3,
+  "packages": {
+    "": {"name": "app", "devDependencies": {"@babel/core": "^7.22.0"}},
+    "node_modules/@babel/core": {
+      "version": "7.22.0",
+      "dev": true,
+      "dependencies": {"semver": "^6.3.0"},
+      "peerDependencies": {"@babel/types": "*"}
+    },
+    "node_modules/semver": {"version": "6.3.1"},
+    "node_modules/aliased": {"name": "lodash", "version": "4.17.21"}
+  }
+}`,
+			want: []string{
+				"pkg:npm/%40babel/core@7.22.0",
+				"pkg:npm/lodash@4.17.21",
+				"pkg:npm/semver@6.3.1",
+			},
+		},
+		{
+			name: "yarn.lock v1",
+			file: "yarn.lock",
+			lockfile: `# THIS IS AN AUTOGENERATED FILE. DO NOT EDIT THIS FILE DIRECTLY.
+# yarn lockfile v1
+
+
+"@babel/code-frame@^7.0.0", "@babel/code-frame@^7.22.5":
+  version "7.22.5"
+  resolved "https://registry.yarnpkg.com/@babel/code-frame/-/code-frame-7.22.5.tgz"
+  dependencies:
+    "@babel/highlight" "^7.22.5"
+
+lodash@^4.17.20, lodash@^4.17.21:
+  version "4.17.21"
+  resolved "https://registry.yarnpkg.com/lodash/-/lodash-4.17.21.tgz"
+`,
+			want: []string{
+				"pkg:npm/%40babel/code-frame@7.22.5",
+				"pkg:npm/lodash@4.17.21",
+			},
+		},
+		{
+			name: "yarn.lock berry",
+			file: "yarn.lock",
+			lockfile: `__metadata:
+  version: 6
+  cacheKey: 8
+
+"app@workspace:.":
+  version: 0.0.0-use.local
+  resolution: "app@workspace:."
+  languageName: unknown
+  linkType: soft
+
+"lodash@npm:^4.17.21":
+  version: 4.17.21
+  resolution: "lodash@npm:4.17.21"
+  languageName: node
+  linkType: hard
+
+"@types/node@npm:*, @types/node@npm:^20.0.0":
+  version: 20.4.2
+  resolution: "@types/node@npm:20.4.2"
+  languageName: node
+  linkType: hard
+`,
+			want: []string{
+				"pkg:npm/%40types/node@20.4.2",
+				"pkg:npm/lodash@4.17.21",
+			},
+		},
+		{
+			name: "pnpm-lock.yaml v5",
+			file: "pnpm-lock.yaml",
+			lockfile: `lockfileVersion: 5.4
+
+packages:
+
+  /lodash/4.17.21:
+    resolution: {integrity: sha512-abc}
+    dev: false
+
+  /@babel/core/7.22.0_supports-color@5.5.0:
+    resolution: {integrity: sha512-def}
+    dev: true
+`,
+			want: []string{
+				"pkg:npm/%40babel/core@7.22.0",
+				"pkg:npm/lodash@4.17.21",
+			},
+		},
+		{
+			name: "pnpm-lock.yaml v6",
+			file: "pnpm-lock.yaml",
+			lockfile: `lockfileVersion: '6.0'
+
+packages:
+
+  /lodash@4.17.21:
+    resolution: {integrity: sha512-abc}
+    dev: false
+
+  /@babel/core@7.22.0(supports-color@5.5.0):
+    resolution: {integrity: sha512-def}
+    dev: true
+`,
+			want: []string{
+				"pkg:npm/%40babel/core@7.22.0",
+				"pkg:npm/lodash@4.17.21",
+			},
+		},
+		{
+			name: "pnpm-lock.yaml v9",
+			file: "pnpm-lock.yaml",
+			lockfile: `lockfileVersion: '9.0'
+
+packages:
+
+  lodash@4.17.21:
+    resolution: {integrity: sha512-abc}
+
+  '@babel/core@7.22.0':
+    resolution: {integrity: sha512-def}
+
+snapshots:
+
+  lodash@4.17.21: {}
+`,
+			want: []string{
+				"pkg:npm/%40babel/core@7.22.0",
+				"pkg:npm/lodash@4.17.21",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			components, err := parseNodeLockfile(writeLockfile(t, tt.file, tt.lockfile))
+			if err != nil {
+				t.Fatalf("parseNodeLockfile() error = %v", err)
+			}
+			if got := purls(components); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseNodeLockfile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNodeLockfileInvalid(t *testing.T) {
+	tests := []struct {
+		file     string
+		lockfile string
+	}{
+		{"package-lock.json", `{"lockfileVersion": 2, "packages": [`},
+		{"pnpm-lock.yaml", "packages: [\n"},
+		{"package.json.lock", "{}"},
+	}
+
+	for _, tt := range tests {
+		if _, err := parseNodeLockfile(writeLockfile(t, tt.file, tt.lockfile)); err == nil {
+			t.Errorf("parseNodeLockfile(%s) did not fail", tt.file)
+		}
+	}
+}
+
+func TestYarnEntryName(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{`lodash@^4.17.21`, "lodash"},
+		{`"@babel/core@^7.0.0", "@babel/core@^7.22.0"`, "@babel/core"},
+		{`"lodash@npm:^4.17.21"`, "lodash"},
+		{`"app@workspace:."`, ""},
+		{`"local@link:../local"`, ""},
+		{`__metadata`, ""},
+		{`lodash`, ""},
+	}
+
+	for _, tt := range tests {
+		if got := yarnEntryName(tt.header); got != tt.want {
+			t.Errorf("yarnEntryName(%s) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestPnpmPackageKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		name    string
+		version string
+	}{
+		{"/lodash/4.17.21", "lodash", "4.17.21"},
+		{"/@babel/core/7.22.0_supports-color@5.5.0", "@babel/core", "7.22.0"},
+		{"/lodash@4.17.21", "lodash", "4.17.21"},
+		{"/@babel/core@7.22.0(supports-color@5.5.0)", "@babel/core", "7.22.0"},
+		{"lodash@4.17.21(peer@1.0.0)", "lodash", "4.17.21"},
+		{"lodash", "", ""},
+	}
+
+	for _, tt := range tests {
+		name, version := pnpmPackageKey(tt.key)
+		if name != tt.name || version != tt.version {
+			t.Errorf("pnpmPackageKey(%s) = %q, %q, want %q, %q", tt.key, name, version, tt.name, tt.version)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package sbom
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+// MSBuild project file extensions that can hold PackageReference items
+var nugetProjectExtensions = map[string]bool{
+	".csproj": true,
+	".fsproj": true,
+	".vbproj": true,
+}
+
+// isNuGetProject reports whether the file is an MSBuild project
+func isNuGetProject(path string) bool {
+	return nugetProjectExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+type nugetLockFile struct {
+	Version      int                                    `json:"version"`
+	Dependencies map[string]map[string]nugetLockPackage `json:"dependencies"`
+}
+
+type nugetLockPackage struct {
+	Type     string `json:"type"`
+	Resolved string `json:"resolved"`
+}
+
+// msbuildProject covers PackageReference items in project files as well as
+// PackageVersion items of Directory.Packages.props
+type msbuildProject struct {
+	ItemGroups []struct {
+		PackageReferences []msbuildPackage `xml:"PackageReference"`
+		PackageVersions   []msbuildPackage `xml:"PackageVersion"`
+	} `xml:"ItemGroup"`
+}
+
+type msbuildPackage struct {
+	Include         string `xml:"Include,attr"`
+	Update          string `xml:"Update,attr"`
+	Version         string `xml:"Version,attr"`
+	VersionOverride string `xml:"VersionOverride,attr"`
+	VersionElement  string `xml:"Version"`
+}
+
+func (p msbuildPackage) name() string {
+	if p.Include != "" {
+		return p.Include
+	}
+	return p.Update
+}
+
+func (p msbuildPackage) version() string {
+	switch {
+	case p.VersionOverride != "":
+		return p.VersionOverride
+	case p.Version != "":
+		return p.Version
+	}
+	return strings.TrimSpace(p.VersionElement)
+}
+
+type packagesConfig struct {
+	Packages []struct {
+		ID      string `xml:"id,attr"`
+		Version string `xml:"version,attr"`
+	} `xml:"package"`
+}
+
+// GenerateNuGetSBOM builds the SBOM from packages.lock.json, packages.config or
+// an MSBuild project file. A project with a packages.lock.json next to it is
+// read from the lockfile, which also lists the transitive packages.
+func GenerateNuGetSBOM(projectFile, outputPath string) error {
+	lockFile := projectFile
+	if isNuGetProject(projectFile) {
+		lockFile = filepath.Join(filepath.Dir(projectFile), "packages.lock.json")
+		if _, err := os.Stat(lockFile); err != nil {
+			lockFile = ""
+		}
+	}
+
+	var components []Component
+	var properties []CDXProperty
+	var err error
+	switch {
+	case lockFile != "" && filepath.Base(lockFile) == "packages.lock.json":
+		components, err = parseNuGetLockFile(lockFile)
+	case filepath.Base(projectFile) == "packages.config":
+		components, err = parsePackagesConfig(projectFile)
+	default:
+		runenv.Logger.Warn("No packages.lock.json found, only direct package references are listed")
+		components, properties, err = parseMSBuildProject(projectFile)
+	}
+	if err != nil {
+		return err
+	}
+
+	runenv.Logger.Infof("Found %d NuGet packages in %s", len(UniqueComponents(components)), filepath.Base(projectFile))
+	for _, p := range properties {
+		runenv.Logger.Warnf("Skipped %s", p.Value)
+	}
+
+	return writeCycloneDXWithProperties(outputPath, components, properties)
+}
+
+// parseNuGetLockFile reads the resolved packages of every target framework
+func parseNuGetLockFile(path string) ([]Component, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lockfile: %v", err)
+	}
+
+	var lock nugetLockFile
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	var components []Component
+	for _, packages := range lock.Dependencies {
+		for name, p := range packages {
+			// Project references are part of the solution, not packages
+			if p.Type == "Project" || p.Resolved == "" {
+				continue
+			}
+			components = append(components, Component{Type: "nuget", Name: name, Version: p.Resolved})
+		}
+	}
+	return components, nil
+}
+
+func parsePackagesConfig(path string) ([]Component, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read packages.config: %v", err)
+	}
+
+	var config packagesConfig
+	if err := xml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	var components []Component
+	for _, p := range config.Packages {
+		if p.ID != "" && p.Version != "" {
+			components = append(components, Component{Type: "nuget", Name: p.ID, Version: p.Version})
+		}
+	}
+	return components, nil
+}
+
+// parseMSBuildProject reads the PackageReference items of a project file.
+// Versions managed centrally come from the nearest Directory.Packages.props.
+func parseMSBuildProject(path string) ([]Component, []CDXProperty, error) {
+	project, err := readMSBuildProject(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	central := make(map[string]string)
+	if propsPath := findUpwards(filepath.Dir(path), "Directory.Packages.props"); propsPath != "" {
+		props, err := readMSBuildProject(propsPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, group := range props.ItemGroups {
+			for _, p := range group.PackageVersions {
+				central[strings.ToLower(p.name())] = p.version()
+			}
+		}
+	}
+
+	var components []Component
+	var properties []CDXProperty
+	for _, group := range project.ItemGroups {
+		for _, p := range group.PackageReferences {
+			name, version := p.name(), p.version()
+			if name == "" {
+				continue
+			}
+			if version == "" {
+				version = central[strings.ToLower(name)]
+			}
+
+			resolved, ok := nugetVersion(version)
+			if !ok {
+				properties = append(properties, CDXProperty{Name: propertySkipped, Value: name + " " + version + " (version not resolved)"})
+				continue
+			}
+			components = append(components, Component{Type: "nuget", Name: name, Version: resolved})
+		}
+	}
+	return components, properties, nil
+}
+
+func readMSBuildProject(path string) (*msbuildProject, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project file: %v", err)
+	}
+
+	var project msbuildProject
+	if err := xml.Unmarshal(data, &project); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return &project, nil
+}
+
+// nugetVersion returns the version NuGet restores for a version specifier:
+// "1.2.3" and "[1.2.3, )" both resolve to the lowest matching version 1.2.3.
+// Floating versions, exclusive lower bounds and MSBuild properties cannot be
+// resolved without a restore.
+func nugetVersion(spec string) (string, bool) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || strings.ContainsAny(spec, "*$(") {
+		return "", false
+	}
+	if !strings.HasPrefix(spec, "[") {
+		return spec, !strings.ContainsAny(spec, "(),")
+	}
+
+	lower, _, _ := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(spec, "["), "]"), ",")
+	lower = strings.TrimSpace(lower)
+	return lower, lower != ""
+}
+
+// findUpwards looks for name in dir and its parent directories
+func findUpwards(dir, name string) string {
+	for {
+		candidate := filepath.Join(dir, name)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
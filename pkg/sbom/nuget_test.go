@@ -0,0 +1,136 @@
+package sbom
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGenerateNuGetSBOM(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		project string
+		want    []string
+		skipped []string
+	}{
+		{
+			name: "packages.lock.json next to the project",
+			files: map[string]string{
+				"app/app.csproj": `<Project Sdk="Microsoft.NET.Sdk">
+  <ItemGroup>
+    <PackageReference Include="Newtonsoft.Json" Version="13.0.1" />
+  </ItemGroup>
+</Project>`,
+				"app/packages.lock.json": `{
+  "version": 1,
+  "dependencies": {
+    "net8.0": {
+      "Newtonsoft.Json": {"type": "Direct", "requested": "[13.0.1, )", "resolved": "13.0.1"},
+      "System.Text.Json": {"type": "Transitive", "resolved": "8.0.0"},
+      "lib": {"type": "Project"}
+    },
+    "net6.0": {
+      "Newtonsoft.Json": {"type": "Direct", "requested": "[13.0.1, )", "resolved": "13.0.1"}
+    }
+  }
+}`,
+			},
+			project: "app/app.csproj",
+			want:    []string{"pkg:nuget/Newtonsoft.Json@13.0.1", "pkg:nuget/System.Text.Json@8.0.0"},
+		},
+		{
+			name: "packages.config",
+			files: map[string]string{
+				"app/packages.config": `<?xml version="1.0" encoding="utf-8"?>
+<packages>
+  <package id="log4net" version="2.0.15" targetFramework="net48" />
+  <package id="NUnit" version="3.13.3" targetFramework="net48" />
+</packages>`,
+			},
+			project: "app/packages.config",
+			want:    []string{"pkg:nuget/NUnit@3.13.3", "pkg:nuget/log4net@2.0.15"},
+		},
+		{
+			name: "project with central package management",
+			files: map[string]string{
+				"Directory.Packages.props": `<Project>
+  <ItemGroup>
+    <PackageVersion Include="Serilog" Version="3.0.1" />
+    <PackageVersion Include="Polly" Version="8.0.0" />
+  </ItemGroup>
+</Project>`,
+				"src/app/app.csproj": `<Project Sdk="Microsoft.NET.Sdk">
+  <ItemGroup>
+    <PackageReference Include="serilog" />
+    <PackageReference Include="Polly" VersionOverride="7.2.4" />
+    <PackageReference Include="Dapper">
+      <Version>[2.1.0, )</Version>
+    </PackageReference>
+    <PackageReference Include="Humanizer" Version="2.*" />
+    <PackageReference Include="AutoMapper" Version="$(AutoMapperVersion)" />
+    <PackageReference Include="Unlisted" />
+  </ItemGroup>
+</Project>`,
+			},
+			project: "src/app/app.csproj",
+			want:    []string{"pkg:nuget/Dapper@2.1.0", "pkg:nuget/Polly@7.2.4", "pkg:nuget/serilog@3.0.1"},
+			skipped: []string{
+				"Humanizer 2.* (version not resolved)",
+				"AutoMapper $(AutoMapperVersion) (version not resolved)",
+				"Unlisted  (version not resolved)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			purls, skipped := generateTestSBOM(t, GenerateNuGetSBOM, filepath.Join(dir, tt.project))
+			if !reflect.DeepEqual(purls, tt.want) {
+				t.Errorf("components = %v, want %v", purls, tt.want)
+			}
+			if !reflect.DeepEqual(skipped, tt.skipped) {
+				t.Errorf("skipped = %q, want %q", skipped, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestNuGetVersion(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    string
+		resolve bool
+	}{
+		{"13.0.1", "13.0.1", true},
+		{" 13.0.1 ", "13.0.1", true},
+		{"[13.0.1]", "13.0.1", true},
+		{"[13.0.1, )", "13.0.1", true},
+		{"[1.0,2.0)", "1.0", true},
+		{"(1.0,2.0)", "", false},
+		{"(,2.0]", "", false},
+		{"[,2.0]", "", false},
+		{"2.*", "", false},
+		{"$(Version)", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := nugetVersion(tt.spec)
+		if ok != tt.resolve || (ok && got != tt.want) {
+			t.Errorf("nugetVersion(%q) = %q, %v, want %q, %v", tt.spec, got, ok, tt.want, tt.resolve)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+type composerLock struct {
+	Packages    []composerPackage `json:"packages"`
+	PackagesDev []composerPackage `json:"packages-dev"`
+}
+
+type composerPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// GenerateComposerSBOM builds the SBOM from a composer.lock, including the
+// packages-dev section
+func GenerateComposerSBOM(lockPath, outputPath string) error {
+	data, err := os.ReadFile(lockPath)
+	if err != nil {
+		return fmt.Errorf("failed to read lockfile: %v", err)
+	}
+
+	var lock composerLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", lockPath, err)
+	}
+
+	var components []Component
+	var properties []CDXProperty
+	for _, p := range append(lock.Packages, lock.PackagesDev...) {
+		if p.Name == "" || p.Version == "" {
+			continue
+		}
+		// Branch checkouts such as dev-main have no release to match advisories against
+		if strings.HasPrefix(p.Version, "dev-") || strings.HasSuffix(p.Version, "-dev") {
+			properties = append(properties, CDXProperty{Name: propertySkipped, Value: p.Name + " " + p.Version + " (development branch)"})
+			continue
+		}
+		components = append(components, composerComponent(p.Name, strings.TrimPrefix(p.Version, "v")))
+	}
+
+	runenv.Logger.Infof("Found %d Composer packages in %s", len(UniqueComponents(components)), filepath.Base(lockPath))
+	for _, p := range properties {
+		runenv.Logger.Warnf("Skipped %s", p.Value)
+	}
+
+	return writeCycloneDXWithProperties(outputPath, components, properties)
+}
+
+// composerComponent splits vendor/package into purl namespace and name
+func composerComponent(name, version string) Component {
+	c := Component{Type: "composer", Name: name, Version: version}
+	if vendor, pkg, ok := strings.Cut(name, "/"); ok {
+		c.Namespace, c.Name = vendor, pkg
+	}
+	return c
+}
@@ -0,0 +1,36 @@
+package sbom
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGenerateComposerSBOM(t *testing.T) {
+	lockfile := `{
+    "_readme": ["This file locks the dependencies of your project to a known state"],
+    "content-hash": "abc",
+    "packages": [
+        {"name": "monolog/monolog", "version": "3.4.0", "type": "library"},
+        {"name": "guzzlehttp/guzzle", "version": "v7.8.0"},
+        {"name": "acme/tools", "version": "dev-main"},
+        {"name": "acme/lib", "version": "2.x-dev"}
+    ],
+    "packages-dev": [
+        {"name": "phpunit/phpunit", "version": "10.3.5"}
+    ]
+}`
+
+	purls, skipped := generateTestSBOM(t, GenerateComposerSBOM, writeLockfile(t, "composer.lock", lockfile))
+
+	want := []string{
+		"pkg:composer/guzzlehttp/guzzle@7.8.0",
+		"pkg:composer/monolog/monolog@3.4.0",
+		"pkg:composer/phpunit/phpunit@10.3.5",
+	}
+	if !reflect.DeepEqual(purls, want) {
+		t.Errorf("components = %v, want %v", purls, want)
+	}
+	if want := []string{"acme/tools dev-main (development branch)", "acme/lib 2.x-dev (development branch)"}; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("skipped = %q, want %q", skipped, want)
+	}
+}
@@ -0,0 +1,255 @@
+package sbom
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Archive types handled by the artifact mode
+var archiveExtensions = map[string]bool{
+	".jar": true,
+	".war": true,
+	".ear": true,
+}
+
+// IsArchive reports whether the file name is a JAR, WAR or EAR
+func IsArchive(name string) bool {
+	return archiveExtensions[strings.ToLower(path.Ext(name))]
+}
+
+// OutputMarker marks a directory created by sbom-scanner, the only kind that
+// --clean empties
+const OutputMarker = ".sbom-scanner"
+
+// BuildTool identifies the build system a project file belongs to
+type BuildTool string
+
+const (
+	BuildToolMaven    BuildTool = "maven"
+	BuildToolGradle   BuildTool = "gradle"
+	BuildToolNode     BuildTool = "node"
+	BuildToolGo       BuildTool = "go"
+	BuildToolPython   BuildTool = "python"
+	BuildToolRust     BuildTool = "rust"
+	BuildToolNuGet    BuildTool = "nuget"
+	BuildToolComposer BuildTool = "composer"
+	BuildToolBundler  BuildTool = "bundler"
+
+	// Built JAR/WAR/EAR archives rather than a build system
+	BuildToolArtifact BuildTool = "artifact"
+
+	// An existing CycloneDX or SPDX SBOM, scanned without a build
+	BuildToolSBOM BuildTool = "sbom"
+
+	// A container image of a Kubernetes workload, cataloged with syft
+	BuildToolImage BuildTool = "image"
+)
+
+// Project files looked up in a directory, in order of preference
+var projectFiles = []string{
+	"pom.xml",
+	"build.gradle.kts",
+	"build.gradle",
+	"package-lock.json",
+	"npm-shrinkwrap.json",
+	"yarn.lock",
+	"pnpm-lock.yaml",
+	"package.json",
+	"go.mod",
+	"poetry.lock",
+	"Pipfile.lock",
+	"requirements.txt",
+	"Cargo.lock",
+	"packages.lock.json",
+	"packages.config",
+	"*.csproj",
+	"*.fsproj",
+	"*.vbproj",
+	"composer.lock",
+	"Gemfile.lock",
+}
+
+// Directories that never contain modules of their own
+var SkippedDirs = map[string]bool{
+	"node_modules": true,
+	"target":       true,
+	"build":        true,
+	"vendor":       true,
+}
+
+// Project is a project file found on disk
+type Project struct {
+	Tool   BuildTool
+	File   string
+	Rel    string // directory relative to the scanned root, "." for the root
+	Output string // output subdirectory, rel unless the directory holds several ecosystems
+	Reuse  string // run directory whose results of the module are reused with --since
+}
+
+// DetectProject determines the build system of a project file
+func DetectProject(path string) (BuildTool, error) {
+	switch filepath.Base(path) {
+	case "pom.xml":
+		return BuildToolMaven, nil
+	case "build.gradle", "build.gradle.kts":
+		return BuildToolGradle, nil
+	case "package.json", "package-lock.json", "npm-shrinkwrap.json", "yarn.lock", "pnpm-lock.yaml":
+		return BuildToolNode, nil
+	case "go.mod":
+		return BuildToolGo, nil
+	case "poetry.lock", "Pipfile.lock", "requirements.txt":
+		return BuildToolPython, nil
+	case "Cargo.lock":
+		return BuildToolRust, nil
+	case "packages.lock.json", "packages.config":
+		return BuildToolNuGet, nil
+	case "composer.lock":
+		return BuildToolComposer, nil
+	case "Gemfile.lock":
+		return BuildToolBundler, nil
+	}
+	if isSBOMFile(path) {
+		return BuildToolSBOM, nil
+	}
+	if isNuGetProject(path) {
+		return BuildToolNuGet, nil
+	}
+	if p, _ := detectProvider(path); p != nil {
+		return BuildTool(p.Name()), nil
+	}
+	if filepath.Ext(path) == ".xml" {
+		return BuildToolMaven, nil
+	}
+	if IsArchive(path) {
+		return BuildToolArtifact, nil
+	}
+	return "", fmt.Errorf("unsupported project file: %s", path)
+}
+
+// FindProjectFile returns the preferred project file in dir, if any
+func FindProjectFile(dir string) string {
+	if files := findProjectFiles(dir); len(files) > 0 {
+		return files[0]
+	}
+	return ""
+}
+
+// findProjectFiles returns the preferred project file of every build tool
+// found in dir, in order of preference
+func findProjectFiles(dir string) []string {
+	var files []string
+	seen := make(map[BuildTool]bool)
+
+	for _, name := range projectFiles {
+		candidates := []string{filepath.Join(dir, name)}
+		if strings.Contains(name, "*") {
+			candidates, _ = filepath.Glob(filepath.Join(dir, name))
+		}
+		for _, candidate := range candidates {
+			info, err := os.Stat(candidate)
+			if err != nil || info.IsDir() {
+				continue
+			}
+			tool, err := DetectProject(candidate)
+			if err != nil || seen[tool] {
+				continue
+			}
+			seen[tool] = true
+			files = append(files, candidate)
+		}
+	}
+
+	for _, p := range Providers() {
+		tool := BuildTool(p.Name())
+		if seen[tool] {
+			continue
+		}
+		if file, ok := p.Detect(dir); ok {
+			seen[tool] = true
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// discoverProjects walks root recursively and returns one project per build
+// tool and directory. Polyglot directories yield several projects: the
+// preferred one writes to the mirrored output directory, the others to a
+// subdirectory named after their build tool.
+func discoverProjects(root, outputDir string) ([]Project, error) {
+	absOutputDir, _ := filepath.Abs(outputDir)
+
+	var projects []Project
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		if path != root {
+			name := d.Name()
+			if strings.HasPrefix(name, ".") || SkippedDirs[name] {
+				return filepath.SkipDir
+			}
+			if absPath, _ := filepath.Abs(path); absPath == absOutputDir {
+				return filepath.SkipDir
+			}
+		}
+
+		files := findProjectFiles(path)
+		if len(files) == 0 {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		for i, file := range files {
+			tool, _ := DetectProject(file)
+			output := rel
+			if i > 0 {
+				output = filepath.Join(rel, string(tool))
+			}
+			projects = append(projects, Project{Tool: tool, File: file, Rel: rel, Output: output})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %v", root, err)
+	}
+
+	return projects, nil
+}
+
+// FindProjects returns the project file target, or the modules below it when
+// target is a directory
+func FindProjects(target, outputDir string) ([]Project, error) {
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil, fmt.Errorf("project file not found: %s", target)
+	}
+	if !info.IsDir() {
+		tool, err := DetectProject(target)
+		if err != nil {
+			return nil, err
+		}
+		return []Project{{Tool: tool, File: target, Rel: ".", Output: "."}}, nil
+	}
+
+	projects, err := discoverProjects(target, outputDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(projects) == 0 {
+		return nil, fmt.Errorf("no supported project file found in %s", target)
+	}
+	return projects, nil
+}
@@ -0,0 +1,201 @@
+package sbom
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+// Provider adds support for a build system or package manager that
+// sbom-scanner does not know itself
+type Provider interface {
+	// Name identifies the provider, it is shown as the build tool of its modules
+	Name() string
+	// Detect is called with a file given with -f or with a directory searched
+	// for modules. It returns the project file the provider handles, if any.
+	Detect(path string) (string, bool)
+	// GenerateSBOM writes the SBOM of projectFile to outputPath, as CycloneDX
+	// (XML or JSON) or SPDX (JSON or tag-value)
+	GenerateSBOM(ctx context.Context, projectFile, outputPath string) error
+}
+
+// Executables on PATH with this prefix are loaded as providers
+const providerPluginPrefix = "sbom-scanner-provider-"
+
+var (
+	registeredProviders []Provider
+	providersMu         sync.Mutex
+	pluginsOnce         sync.Once
+	pluginProviders     []Provider
+)
+
+// Build tools handled by sbom-scanner itself, providers cannot replace them
+var builtinTools = map[BuildTool]bool{
+	BuildToolMaven: true, BuildToolGradle: true, BuildToolNode: true, BuildToolGo: true,
+	BuildToolPython: true, BuildToolRust: true, BuildToolNuGet: true, BuildToolComposer: true,
+	BuildToolBundler: true, BuildToolArtifact: true, BuildToolSBOM: true, BuildToolImage: true,
+}
+
+// RegisterProvider adds a provider to every following scan. Registered
+// providers are asked before the plugins found on PATH.
+func RegisterProvider(p Provider) error {
+	if builtinTools[BuildTool(p.Name())] {
+		return fmt.Errorf("provider name %s is reserved for a built-in build tool", p.Name())
+	}
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	registeredProviders = append(registeredProviders, p)
+	return nil
+}
+
+// Providers returns the registered providers followed by the plugins on PATH
+func Providers() []Provider {
+	pluginsOnce.Do(func() {
+		pluginProviders = findPluginProviders()
+	})
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	return append(append([]Provider(nil), registeredProviders...), pluginProviders...)
+}
+
+// ProviderFor returns the provider of a build tool, nil for built-in tools
+func ProviderFor(tool BuildTool) Provider {
+	if builtinTools[tool] {
+		return nil
+	}
+	for _, p := range Providers() {
+		if BuildTool(p.Name()) == tool {
+			return p
+		}
+	}
+	return nil
+}
+
+// detectProvider returns the provider handling path and its project file
+func detectProvider(path string) (Provider, string) {
+	for _, p := range Providers() {
+		if file, ok := p.Detect(path); ok {
+			return p, file
+		}
+	}
+	return nil, ""
+}
+
+// GenerateProviderSBOM runs a provider and converts its SBOM to the CycloneDX
+// XML the rest of the pipeline reads
+func GenerateProviderSBOM(ctx context.Context, p Provider, projectFile, sbomPath string) error {
+	tmpPath := sbomPath + ".provider"
+	defer os.Remove(tmpPath)
+
+	if err := p.GenerateSBOM(ctx, projectFile, tmpPath); err != nil {
+		return fmt.Errorf("provider %s failed: %v", p.Name(), err)
+	}
+	data, err := os.ReadFile(tmpPath)
+	if err != nil {
+		return fmt.Errorf("provider %s did not write an SBOM: %v", p.Name(), err)
+	}
+	if !isSBOMData(data) {
+		return fmt.Errorf("provider %s did not write a CycloneDX or SPDX SBOM", p.Name())
+	}
+	return ImportSBOM(tmpPath, sbomPath)
+}
+
+// findPluginProviders returns an exec provider for every
+// sbom-scanner-provider-* executable on PATH, the first one of a name wins
+func findPluginProviders() []Provider {
+	seen := make(map[string]bool)
+	var plugins []Provider
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutPrefix(entry.Name(), providerPluginPrefix)
+			if !ok || entry.IsDir() {
+				continue
+			}
+			if runtime.GOOS == "windows" {
+				if !strings.EqualFold(filepath.Ext(name), ".exe") {
+					continue
+				}
+				name = strings.TrimSuffix(name, filepath.Ext(name))
+			}
+			path := filepath.Join(dir, entry.Name())
+			if name == "" || seen[name] || !runenv.IsExecutable(path) {
+				continue
+			}
+			seen[name] = true
+			if builtinTools[BuildTool(name)] {
+				runenv.Logger.Warnf("Ignoring provider plugin %s: %s is a built-in build tool", path, name)
+				continue
+			}
+			plugins = append(plugins, execProvider{name: name, path: path})
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool {
+		return plugins[i].Name() < plugins[j].Name()
+	})
+	return plugins
+}
+
+// execProvider runs a provider plugin:
+//
+//	sbom-scanner-provider-<name> detect <path>
+//	    exits 0 when path is handled, for a directory it prints the project
+//	    file, absolute or relative to the directory
+//	sbom-scanner-provider-<name> generate <project-file> <output>
+//	    writes the SBOM to output
+type execProvider struct {
+	name string
+	path string
+}
+
+func (p execProvider) Name() string {
+	return p.name
+}
+
+func (p execProvider) Detect(path string) (string, bool) {
+	output, err := exec.Command(p.path, "detect", path).Output()
+	if err != nil {
+		return "", false
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return path, true
+	}
+	file := strings.TrimSpace(string(output))
+	if file == "" {
+		return "", false
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(path, file)
+	}
+	return file, true
+}
+
+func (p execProvider) GenerateSBOM(ctx context.Context, projectFile, outputPath string) error {
+	absOutputPath, err := filepath.Abs(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %v", err)
+	}
+	absProjectFile, err := filepath.Abs(projectFile)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %v", err)
+	}
+
+	cmd := runenv.Command(ctx, p.path, "generate", absProjectFile, absOutputPath)
+	cmd.Dir = filepath.Dir(absProjectFile)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v\n%s", err, string(output))
+	}
+	return nil
+}
@@ -0,0 +1,369 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+type OSVPackage struct {
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	Ecosystem string `json:"ecosystem"`
+}
+
+// Package URL types mapped to OSV ecosystem names
+var PURLEcosystems = map[string]string{
+	"maven":    "Maven",
+	"npm":      "npm",
+	"pypi":     "PyPI",
+	"golang":   "Go",
+	"cargo":    "crates.io",
+	"nuget":    "NuGet",
+	"composer": "Packagist",
+	"gem":      "RubyGems",
+}
+
+// PackageFromPURL converts a package URL into an OSV package
+func PackageFromPURL(purl string) (OSVPackage, bool) {
+	purl = StripPURLQualifiers(purl)
+	rest, ok := strings.CutPrefix(purl, "pkg:")
+	if !ok {
+		return OSVPackage{}, false
+	}
+
+	purlType, path, ok := strings.Cut(rest, "/")
+	if !ok {
+		return OSVPackage{}, false
+	}
+
+	var version string
+	if i := strings.LastIndex(path, "@"); i >= 0 {
+		path, version = path[:i], path[i+1:]
+	}
+	if unescaped, err := url.PathUnescape(path); err == nil {
+		path = unescaped
+	}
+	if unescaped, err := url.PathUnescape(version); err == nil {
+		version = unescaped
+	}
+
+	ecosystem, ok := PURLEcosystems[purlType]
+	if !ok {
+		return OSVPackage{}, false
+	}
+
+	name := path
+	if purlType == "maven" {
+		name = strings.Replace(path, "/", ":", 1)
+	}
+
+	return OSVPackage{Name: name, Version: version, Ecosystem: ecosystem}, true
+}
+
+// StripPURLQualifiers removes ?qualifiers and #subpath from a package URL
+func StripPURLQualifiers(purl string) string {
+	if i := strings.IndexAny(purl, "?#"); i >= 0 {
+		return purl[:i]
+	}
+	return purl
+}
+
+// packageURL is a parsed package URL, see https://github.com/package-url/purl-spec
+type packageURL struct {
+	typ        string
+	namespace  string // segments joined with /
+	name       string
+	version    string
+	qualifiers map[string]string
+	subpath    string
+}
+
+var (
+	purlTypePattern      = regexp.MustCompile(`^[a-z.+\-][a-z0-9.+\-]*$`)
+	purlQualifierPattern = regexp.MustCompile(`^[a-z.\-_][a-z0-9.\-_]*$`)
+)
+
+// Package URL types written by plugins and tools under another name than the
+// one of the specification, which OSV does not recognize
+var purlTypeAliases = map[string]string{
+	"go":        "golang",
+	"crates.io": "cargo",
+	"rubygems":  "gem",
+	"packagist": "composer",
+}
+
+// Package URL types that need a namespace
+var purlNamespaceRequired = map[string]bool{
+	"maven":     true,
+	"composer":  true,
+	"github":    true,
+	"bitbucket": true,
+}
+
+// parsePURL parses a package URL as the specification describes: the subpath
+// and the qualifiers are split off from the right, the type and the name
+// follow the scheme, the version is after the last @ of the name
+func parsePURL(s string) (packageURL, error) {
+	var p packageURL
+	rest := strings.TrimSpace(s)
+
+	if i := strings.LastIndex(rest, "#"); i >= 0 {
+		var segments []string
+		for _, segment := range strings.Split(rest[i+1:], "/") {
+			if segment == "" || segment == "." || segment == ".." {
+				continue
+			}
+			unescaped, err := url.PathUnescape(segment)
+			if err != nil {
+				return p, fmt.Errorf("invalid subpath: %v", err)
+			}
+			segments = append(segments, unescaped)
+		}
+		p.subpath = strings.Join(segments, "/")
+		rest = rest[:i]
+	}
+
+	if i := strings.LastIndex(rest, "?"); i >= 0 {
+		for _, pair := range strings.Split(rest[i+1:], "&") {
+			key, value, _ := strings.Cut(pair, "=")
+			key = strings.ToLower(key)
+			if value == "" {
+				continue
+			}
+			if !purlQualifierPattern.MatchString(key) {
+				return p, fmt.Errorf("invalid qualifier %q", key)
+			}
+			unescaped, err := url.PathUnescape(value)
+			if err != nil {
+				return p, fmt.Errorf("invalid value of qualifier %s: %v", key, err)
+			}
+			if p.qualifiers == nil {
+				p.qualifiers = make(map[string]string)
+			}
+			p.qualifiers[key] = unescaped
+		}
+		rest = rest[:i]
+	}
+
+	scheme, rest, ok := strings.Cut(rest, ":")
+	if !ok || !strings.EqualFold(scheme, "pkg") {
+		return p, fmt.Errorf("scheme is not pkg:")
+	}
+	rest = strings.TrimLeft(rest, "/")
+
+	typ, rest, ok := strings.Cut(rest, "/")
+	if !ok {
+		return p, fmt.Errorf("missing name")
+	}
+	p.typ = strings.ToLower(typ)
+	if !purlTypePattern.MatchString(p.typ) {
+		return p, fmt.Errorf("invalid type %q", typ)
+	}
+
+	// The version separator is an @ in the last segment, but not its first
+	// character, which is an unescaped npm scope
+	rest = strings.Trim(rest, "/")
+	if i := strings.LastIndex(rest, "@"); i > strings.LastIndex(rest, "/")+1 {
+		version, err := url.PathUnescape(rest[i+1:])
+		if err != nil {
+			return p, fmt.Errorf("invalid version: %v", err)
+		}
+		p.version = version
+		rest = rest[:i]
+	}
+
+	var segments []string
+	for _, segment := range strings.Split(rest, "/") {
+		if segment == "" {
+			continue
+		}
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			return p, fmt.Errorf("invalid name: %v", err)
+		}
+		segments = append(segments, unescaped)
+	}
+	if len(segments) == 0 {
+		return p, fmt.Errorf("missing name")
+	}
+	p.name = segments[len(segments)-1]
+	p.namespace = strings.Join(segments[:len(segments)-1], "/")
+	return p, nil
+}
+
+// normalize applies the rules of the specification for the known types and
+// repairs the mistakes of plugins that make OSV miss the package
+func (p *packageURL) normalize() error {
+	if alias, ok := purlTypeAliases[p.typ]; ok {
+		p.typ = alias
+	}
+	switch p.typ {
+	case "maven":
+		// group:artifact written as the name
+		if p.namespace == "" {
+			if group, name, ok := strings.Cut(p.name, ":"); ok {
+				p.namespace, p.name = group, name
+			}
+		}
+	case "pypi":
+		p.name = strings.ReplaceAll(strings.ToLower(p.name), "_", "-")
+	case "github", "bitbucket", "composer":
+		p.namespace = strings.ToLower(p.namespace)
+		p.name = strings.ToLower(p.name)
+	}
+	if purlNamespaceRequired[p.typ] && p.namespace == "" {
+		return fmt.Errorf("%s package URLs need a namespace", p.typ)
+	}
+	return nil
+}
+
+// String formats the package URL canonically, with sorted qualifiers
+func (p packageURL) String() string {
+	var b strings.Builder
+	b.WriteString("pkg:")
+	b.WriteString(p.typ)
+	b.WriteString("/")
+	if p.namespace != "" {
+		for _, segment := range strings.Split(p.namespace, "/") {
+			b.WriteString(escapePURLSegment(segment))
+			b.WriteString("/")
+		}
+	}
+	b.WriteString(escapePURLSegment(p.name))
+	if p.version != "" {
+		b.WriteString("@")
+		b.WriteString(escapePURLSegment(p.version))
+	}
+	if len(p.qualifiers) > 0 {
+		keys := make([]string, 0, len(p.qualifiers))
+		for key := range p.qualifiers {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for i, key := range keys {
+			if i == 0 {
+				b.WriteString("?")
+			} else {
+				b.WriteString("&")
+			}
+			b.WriteString(key)
+			b.WriteString("=")
+			b.WriteString(strings.ReplaceAll(escapePURLSegment(p.qualifiers[key]), "&", "%26"))
+		}
+	}
+	if p.subpath != "" {
+		b.WriteString("#")
+		for i, segment := range strings.Split(p.subpath, "/") {
+			if i > 0 {
+				b.WriteString("/")
+			}
+			b.WriteString(escapePURLSegment(segment))
+		}
+	}
+	return b.String()
+}
+
+// normalizePURL returns the canonical form of a package URL, or why it cannot
+// be matched
+func normalizePURL(s string) (string, error) {
+	p, err := parsePURL(s)
+	if err != nil {
+		return "", err
+	}
+	if err := p.normalize(); err != nil {
+		return "", err
+	}
+	return p.String(), nil
+}
+
+// purlReplacement is a purl element whose text is replaced
+type purlReplacement struct {
+	start, end int64
+	purl       string
+}
+
+// NormalizePackageURLs validates the package URLs of the SBOM components and
+// rewrites them in canonical form. The scanners match packages by package
+// URL, so a malformed one silently finds nothing; those are reported. Only
+// the text of the purl elements changes, the rest of the document is kept.
+func NormalizePackageURLs(sbomPath string) error {
+	data, err := os.ReadFile(sbomPath)
+	if err != nil {
+		return fmt.Errorf("failed to read SBOM: %v", err)
+	}
+
+	var replacements []purlReplacement
+	var invalid []string
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var start int64 = -1
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse SBOM %s: %v", sbomPath, err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "purl" {
+				start = decoder.InputOffset()
+				text.Reset()
+			}
+		case xml.CharData:
+			if start >= 0 {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			if t.Name.Local != "purl" || start < 0 {
+				continue
+			}
+			end := int64(bytes.LastIndex(data[:decoder.InputOffset()], []byte("</")))
+			original := strings.TrimSpace(text.String())
+			purl, err := normalizePURL(original)
+			switch {
+			case original == "":
+			case err != nil:
+				invalid = append(invalid, fmt.Sprintf("%s (%v)", original, err))
+			case purl != original:
+				replacements = append(replacements, purlReplacement{start: start, end: end, purl: purl})
+			}
+			start = -1
+		}
+	}
+
+	if len(invalid) > 0 {
+		shown := invalid
+		if len(shown) > 5 {
+			shown = shown[:5]
+		}
+		runenv.Logger.Warnf("%d components have invalid package URLs and cannot be matched with vulnerabilities: %s", len(invalid), strings.Join(shown, ", "))
+	}
+	if len(replacements) == 0 {
+		return nil
+	}
+
+	var b bytes.Buffer
+	var last int64
+	for _, r := range replacements {
+		b.Write(data[last:r.start])
+		xml.EscapeText(&b, []byte(r.purl))
+		last = r.end
+	}
+	b.Write(data[last:])
+	if err := os.WriteFile(sbomPath, b.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write SBOM: %v", err)
+	}
+	runenv.Logger.Infof("Normalized %d package URLs in %s", len(replacements), sbomPath)
+	return nil
+}
@@ -0,0 +1,62 @@
+package sbom
+
+import "testing"
+
+func TestNormalizePURL(t *testing.T) {
+	tests := []struct {
+		purl string
+		want string
+	}{
+		{"pkg:npm/lodash@4.17.21", "pkg:npm/lodash@4.17.21"},
+		{"pkg:maven/org.yaml/snakeyaml@2.0", "pkg:maven/org.yaml/snakeyaml@2.0"},
+		// Type and scheme are case insensitive, slashes after the scheme are ignored
+		{"PKG:NPM/lodash@4.17.21", "pkg:npm/lodash@4.17.21"},
+		{"pkg://npm/lodash@4.17.21", "pkg:npm/lodash@4.17.21"},
+		// Unescaped and escaped npm scopes
+		{"pkg:npm/@babel/core@7.22.0", "pkg:npm/%40babel/core@7.22.0"},
+		{"pkg:npm/%40babel/core@7.22.0", "pkg:npm/%40babel/core@7.22.0"},
+		// Qualifiers are sorted, empty ones dropped, the subpath cleaned
+		{"pkg:maven/org.example/lib@1.0?type=jar&classifier=sources&empty=", "pkg:maven/org.example/lib@1.0?classifier=sources&type=jar"},
+		{"pkg:golang/github.com/google/uuid@v1.3.0#./sub/../pkg/", "pkg:golang/github.com/google/uuid@v1.3.0#sub/pkg"},
+		// Mistakes of plugins
+		{"pkg:maven/org.example:lib@1.0", "pkg:maven/org.example/lib@1.0"},
+		{"pkg:go/github.com/google/uuid@v1.3.0", "pkg:golang/github.com/google/uuid@v1.3.0"},
+		{"pkg:crates.io/serde@1.0.188", "pkg:cargo/serde@1.0.188"},
+		{"pkg:rubygems/rails@7.0.8", "pkg:gem/rails@7.0.8"},
+		{"pkg:packagist/Monolog/Monolog@3.4.0", "pkg:composer/monolog/monolog@3.4.0"},
+		{"pkg:pypi/Typing_Extensions@4.8.0", "pkg:pypi/typing-extensions@4.8.0"},
+		{"pkg:github/Package-URL/Purl-Spec@244fd47", "pkg:github/package-url/purl-spec@244fd47"},
+	}
+
+	for _, tt := range tests {
+		got, err := normalizePURL(tt.purl)
+		if err != nil {
+			t.Errorf("normalizePURL(%s) error = %v", tt.purl, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("normalizePURL(%s) = %s, want %s", tt.purl, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizePURLInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"lodash@4.17.21",
+		"npm:lodash@4.17.21",
+		"pkg:npm",
+		"pkg:npm/",
+		"pkg:1npm/lodash@4.17.21",
+		"pkg:npm/lodash@4.17.21?Bad Key=1",
+		"pkg:npm/lodash@%zz",
+		"pkg:maven/lib@1.0",
+		"pkg:composer/monolog@3.4.0",
+	}
+
+	for _, purl := range tests {
+		if got, err := normalizePURL(purl); err == nil {
+			t.Errorf("normalizePURL(%q) = %s, want an error", purl, got)
+		}
+	}
+}
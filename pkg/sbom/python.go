@@ -0,0 +1,268 @@
+package sbom
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+// SBOM metadata property for requirements whose environment markers were not evaluated
+const propertyMarker = "sbom-scanner:python:marker"
+
+// Matches "name[extra1,extra2] <version specifier>"
+var pythonRequirementPattern = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)\s*(?:\[[^\]]*\])?\s*(.*)$`)
+
+// Matches an exact pin such as "==2.31.0" or "===1.0"
+var pythonPinPattern = regexp.MustCompile(`^===?\s*([^\s,*]+)$`)
+
+var pythonNameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// pythonManifest collects the packages of a Python manifest together with the
+// entries that could not be turned into components
+type pythonManifest struct {
+	components []Component
+	properties []CDXProperty
+}
+
+func (m *pythonManifest) add(name, version string) {
+	m.components = append(m.components, Component{Type: "pypi", Name: NormalizePythonName(name), Version: version})
+}
+
+func (m *pythonManifest) skip(entry, reason string) {
+	m.properties = append(m.properties, CDXProperty{Name: propertySkipped, Value: entry + " (" + reason + ")"})
+}
+
+func (m *pythonManifest) marker(entry string) {
+	m.properties = append(m.properties, CDXProperty{Name: propertyMarker, Value: entry})
+}
+
+// NormalizePythonName applies the PEP 503 name normalization used by PyPI and OSV
+func NormalizePythonName(name string) string {
+	return pythonNameSeparators.ReplaceAllString(strings.ToLower(name), "-")
+}
+
+// GeneratePythonSBOM builds the SBOM from requirements.txt, poetry.lock or Pipfile.lock
+func GeneratePythonSBOM(manifestPath, outputPath string) error {
+	manifest := &pythonManifest{}
+
+	var err error
+	switch filepath.Base(manifestPath) {
+	case "poetry.lock":
+		err = manifest.parsePoetryLock(manifestPath)
+	case "Pipfile.lock":
+		err = manifest.parsePipfileLock(manifestPath)
+	default:
+		err = manifest.parseRequirements(manifestPath, make(map[string]bool))
+	}
+	if err != nil {
+		return err
+	}
+
+	runenv.Logger.Infof("Found %d Python packages in %s", len(UniqueComponents(manifest.components)), filepath.Base(manifestPath))
+	for _, p := range manifest.properties {
+		if p.Name == propertySkipped {
+			runenv.Logger.Warnf("Skipped %s", p.Value)
+		}
+	}
+
+	return writeCycloneDXWithProperties(outputPath, manifest.components, manifest.properties)
+}
+
+// parseRequirements reads a pip requirements file. Only exact pins (==, ===)
+// identify a version; everything else is recorded as skipped. Files included
+// with -r are read as well.
+func (m *pythonManifest) parseRequirements(path string, visited map[string]bool) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %v", err)
+	}
+	if visited[absPath] {
+		return nil
+	}
+	visited[absPath] = true
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read requirements file: %v", err)
+	}
+	defer file.Close()
+
+	var lines []string
+	var continued string
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := continued + scanner.Text()
+		if strings.HasSuffix(line, `\`) {
+			continued = strings.TrimSuffix(line, `\`)
+			continue
+		}
+		continued = ""
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	for _, line := range lines {
+		line = stripRequirementComment(line)
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "-") {
+			if err := m.requirementOption(line, path, visited); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Options like --hash may follow the requirement
+		if i := strings.Index(line, " --"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		m.requirement(line)
+	}
+	return nil
+}
+
+func stripRequirementComment(line string) string {
+	if strings.HasPrefix(strings.TrimSpace(line), "#") {
+		return ""
+	}
+	if i := strings.Index(line, " #"); i >= 0 {
+		line = line[:i]
+	}
+	return strings.TrimSpace(line)
+}
+
+func (m *pythonManifest) requirementOption(line, path string, visited map[string]bool) error {
+	// "-r file", "--requirement file" or "--requirement=file"
+	option, value := line, ""
+	if i := strings.IndexAny(line, " ="); i >= 0 {
+		option, value = line[:i], strings.TrimSpace(line[i+1:])
+	}
+
+	switch option {
+	case "-r", "--requirement":
+		return m.parseRequirements(filepath.Join(filepath.Dir(path), value), visited)
+	case "-c", "--constraint":
+		m.skip(line, "constraints files do not add packages")
+	case "-e", "--editable":
+		m.skip(value, "editable install")
+	}
+	return nil
+}
+
+func (m *pythonManifest) requirement(line string) {
+	spec, marker, hasMarker := strings.Cut(line, ";")
+	spec = strings.TrimSpace(spec)
+
+	// Direct references: "name @ https://..." or a plain URL/path
+	if strings.Contains(spec, "@") || strings.Contains(spec, "://") || strings.HasPrefix(spec, ".") || strings.HasPrefix(spec, "/") || filepath.IsAbs(spec) {
+		m.skip(spec, "direct reference")
+		return
+	}
+
+	match := pythonRequirementPattern.FindStringSubmatch(spec)
+	if match == nil {
+		m.skip(spec, "unrecognized requirement")
+		return
+	}
+
+	pin := pythonPinPattern.FindStringSubmatch(strings.TrimSpace(match[2]))
+	if pin == nil {
+		m.skip(spec, "version not pinned")
+		return
+	}
+
+	m.add(match[1], pin[1])
+	if hasMarker {
+		m.marker(spec + "; " + strings.TrimSpace(marker))
+	}
+}
+
+// parsePoetryLock reads the [[package]] tables of poetry.lock. Packages that
+// do not come from a package index (git, path, url sources) are skipped.
+func (m *pythonManifest) parsePoetryLock(path string) error {
+	packages, err := readTOMLArrayTables(path, "package")
+	if err != nil {
+		return err
+	}
+
+	for _, p := range packages {
+		if p["name"] == "" || p["version"] == "" {
+			continue
+		}
+		switch sourceType := p["source.type"]; sourceType {
+		case "", "legacy":
+		default:
+			m.skip(p["name"]+" "+p["version"], sourceType+" source")
+			continue
+		}
+		m.add(p["name"], p["version"])
+	}
+	return nil
+}
+
+type pipfileLock struct {
+	Default map[string]pipfileLockEntry `json:"default"`
+	Develop map[string]pipfileLockEntry `json:"develop"`
+}
+
+type pipfileLockEntry struct {
+	Version string `json:"version"`
+	Markers string `json:"markers"`
+	Git     string `json:"git"`
+	Path    string `json:"path"`
+	File    string `json:"file"`
+}
+
+// parsePipfileLock reads the default and develop sections of Pipfile.lock
+func (m *pythonManifest) parsePipfileLock(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read lockfile: %v", err)
+	}
+
+	var lock pipfileLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	for _, section := range []map[string]pipfileLockEntry{lock.Default, lock.Develop} {
+		names := make([]string, 0, len(section))
+		for name := range section {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			entry := section[name]
+			version := strings.TrimPrefix(strings.TrimPrefix(entry.Version, "=="), "=")
+			if version == "" {
+				switch {
+				case entry.Git != "":
+					m.skip(name, "git source")
+				case entry.Path != "" || entry.File != "":
+					m.skip(name, "local source")
+				default:
+					m.skip(name, "version not pinned")
+				}
+				continue
+			}
+			m.add(name, version)
+			if entry.Markers != "" {
+				m.marker(name + "==" + version + "; " + entry.Markers)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package sbom
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// recorded returns the entries of the manifest that were recorded with the given property
+func (m *pythonManifest) recorded(name string) []string {
+	var values []string
+	for _, p := range m.properties {
+		if p.Name == name {
+			values = append(values, p.Value)
+		}
+	}
+	return values
+}
+
+func TestParseRequirements(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"requirements.txt": `# production
+requests==2.31.0 --hash=sha256:abc
+Flask[async] == 3.0.0
+django>=4.2
+urllib3===2.0.7 ; python_version >= "3.8"
+zope.interface==6.1 \
+    --hash=sha256:def
+-r base.txt
+-c constraints.txt
+-e ./local
+mylib @ https://example.com/mylib-1.0.tar.gz
+`,
+		"base.txt": `Ruamel_YAML==0.18.5  # pinned
+-r requirements.txt
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := &pythonManifest{}
+	if err := m.parseRequirements(filepath.Join(dir, "requirements.txt"), make(map[string]bool)); err != nil {
+		t.Fatalf("parseRequirements() error = %v", err)
+	}
+
+	want := []string{
+		"pkg:pypi/flask@3.0.0",
+		"pkg:pypi/requests@2.31.0",
+		"pkg:pypi/ruamel-yaml@0.18.5",
+		"pkg:pypi/urllib3@2.0.7",
+		"pkg:pypi/zope-interface@6.1",
+	}
+	if got := purls(m.components); !reflect.DeepEqual(got, want) {
+		t.Errorf("components = %v, want %v", got, want)
+	}
+
+	wantSkipped := []string{
+		"django>=4.2 (version not pinned)",
+		"-c constraints.txt (constraints files do not add packages)",
+		"./local (editable install)",
+		"mylib @ https://example.com/mylib-1.0.tar.gz (direct reference)",
+	}
+	if got := m.recorded(propertySkipped); !reflect.DeepEqual(got, wantSkipped) {
+		t.Errorf("skipped = %q, want %q", got, wantSkipped)
+	}
+
+	wantMarkers := []string{`urllib3===2.0.7; python_version >= "3.8"`}
+	if got := m.recorded(propertyMarker); !reflect.DeepEqual(got, wantMarkers) {
+		t.Errorf("markers = %q, want %q", got, wantMarkers)
+	}
+}
+
+func TestParsePoetryLock(t *testing.T) {
+	lockfile := `# This file is automatically @generated by Poetry and should not be changed by hand.
+
+[[package]]
+name = "certifi"
+version = "2023.7.22"
+description = "Python package for providing Mozilla's CA Bundle."
+optional = false
+python-versions = ">=3.6"
+files = [
+    {file = "certifi-2023.7.22-py3-none-any.whl", hash = "sha256:abc"},
+]
+
+[[package]]
+name = "Typing_Extensions"
+version = "4.8.0"
+description = """
+Backported and Experimental Type Hints
+[[package]]
+"""
+
+[[package]]
+name = "mylib"
+version = "0.1.0"
+
+[package.source]
+type = "git"
+url = "https://example.com/mylib.git"
+
+[[package]]
+name = "internal"
+version = "1.2.0"
+
+[package.source]
+type = "legacy"
+url = "https://pypi.example.com/simple"
+
+[metadata]
+lock-version = "2.0"
+`
+
+	m := &pythonManifest{}
+	if err := m.parsePoetryLock(writeLockfile(t, "poetry.lock", lockfile)); err != nil {
+		t.Fatalf("parsePoetryLock() error = %v", err)
+	}
+
+	want := []string{
+		"pkg:pypi/certifi@2023.7.22",
+		"pkg:pypi/internal@1.2.0",
+		"pkg:pypi/typing-extensions@4.8.0",
+	}
+	if got := purls(m.components); !reflect.DeepEqual(got, want) {
+		t.Errorf("components = %v, want %v", got, want)
+	}
+	if got, want := m.recorded(propertySkipped), []string{"mylib 0.1.0 (git source)"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("skipped = %q, want %q", got, want)
+	}
+}
+
+func TestParsePipfileLock(t *testing.T) {
+	lockfile := `{
+    "_meta": {"hash": {"sha256": "abc"}},
+    "default": {
+        "requests": {"version": "==2.31.0", "hashes": ["sha256:abc"]},
+        "pywin32": {"version": "==306", "markers": "sys_platform == 'win32'"},
+        "mylib": {"git": "https://example.com/mylib.git", "ref": "main"},
+        "local": {"path": "./local"}
+    },
+    "develop": {
+        "pytest": {"version": "==7.4.3"},
+        "black": {}
+    }
+}`
+
+	m := &pythonManifest{}
+	if err := m.parsePipfileLock(writeLockfile(t, "Pipfile.lock", lockfile)); err != nil {
+		t.Fatalf("parsePipfileLock() error = %v", err)
+	}
+
+	want := []string{
+		"pkg:pypi/pytest@7.4.3",
+		"pkg:pypi/pywin32@306",
+		"pkg:pypi/requests@2.31.0",
+	}
+	if got := purls(m.components); !reflect.DeepEqual(got, want) {
+		t.Errorf("components = %v, want %v", got, want)
+	}
+
+	wantSkipped := []string{"local (local source)", "mylib (git source)", "black (version not pinned)"}
+	if got := m.recorded(propertySkipped); !reflect.DeepEqual(got, wantSkipped) {
+		t.Errorf("skipped = %q, want %q", got, wantSkipped)
+	}
+	if got, want := m.recorded(propertyMarker), []string{"pywin32==306; sys_platform == 'win32'"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("markers = %q, want %q", got, want)
+	}
+
+	if err := m.parsePipfileLock(writeLockfile(t, "Pipfile.lock", "{")); err == nil {
+		t.Errorf("parsePipfileLock() of invalid JSON did not fail")
+	}
+}
+
+func TestNormalizePythonName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"requests", "requests"},
+		{"Flask", "flask"},
+		{"Typing_Extensions", "typing-extensions"},
+		{"zope.interface", "zope-interface"},
+		{"some-_.Package", "some-package"},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizePythonName(tt.name); got != tt.want {
+			t.Errorf("NormalizePythonName(%s) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
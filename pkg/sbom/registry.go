@@ -0,0 +1,352 @@
+package sbom
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+// Docker Hub serves the registry API on another host than its name
+const dockerHubRegistry = "registry-1.docker.io"
+
+// Number of tags per page of the tags list
+const registryPageSize = 1000
+
+// Matches the next page in the Link header of a tags list response
+var registryNextLinkPattern = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// Matches the parameters of a WWW-Authenticate challenge
+var registryChallengePattern = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// registryRepository is a repository of a container registry, e.g.
+// ghcr.io/org/app
+type registryRepository struct {
+	name string // as given, images are name:tag
+	host string // host of the registry API
+	path string // repository path on the registry
+}
+
+// RegistryTag is a scanned tag with the findings of its image
+type RegistryTag struct {
+	Tag             string         `json:"tag"`
+	Image           string         `json:"image"`
+	OutputDir       string         `json:"output_dir"`
+	Vulnerabilities int            `json:"vulnerabilities"`
+	Severities      map[string]int `json:"severities,omitempty"`
+	Error           string         `json:"error,omitempty"`
+}
+
+// parseRegistryRepository splits a repository name into the registry and the
+// path. Names without a registry host are Docker Hub repositories, with
+// library/ for official images.
+func parseRegistryRepository(name string) (registryRepository, error) {
+	if name == "" || strings.ContainsAny(name, "@ ") || strings.HasSuffix(name, "/") {
+		return registryRepository{}, fmt.Errorf("invalid registry repository: %s", name)
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		return registryRepository{}, fmt.Errorf("invalid registry repository: %s, select tags with --tags", name)
+	}
+	repo := registryRepository{name: name}
+	first, rest, found := strings.Cut(name, "/")
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		repo.host, repo.path = first, rest
+	} else {
+		repo.host, repo.path = dockerHubRegistry, name
+		if !found {
+			repo.path = "library/" + name
+		}
+	}
+	if repo.host == "docker.io" || repo.host == "index.docker.io" {
+		repo.host = dockerHubRegistry
+	}
+	return repo, nil
+}
+
+// baseURL returns the registry API endpoint, plain HTTP for local registries
+func (r registryRepository) baseURL() string {
+	host, _, _ := strings.Cut(r.host, ":")
+	if host == "localhost" || host == "127.0.0.1" {
+		return "http://" + r.host
+	}
+	return "https://" + r.host
+}
+
+// FindRegistryTags lists the tags of a repository that match one of the
+// include globs (all tags when there are none) and none of the exclude globs,
+// and returns one image project per tag
+func FindRegistryTags(ctx context.Context, name string, include, exclude []string) ([]Project, error) {
+	repo, err := parseRegistryRepository(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, glob := range append(append([]string{}, include...), exclude...) {
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid tag pattern: %s", glob)
+		}
+	}
+	tags, err := listRegistryTags(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var projects []Project
+	for _, tag := range tags {
+		if !matchTag(tag, include, true) || matchTag(tag, exclude, false) {
+			continue
+		}
+		image := repo.name + ":" + tag
+		projects = append(projects, Project{Tool: BuildToolImage, File: image, Rel: image, Output: ImageOutputDir(image)})
+	}
+	if len(projects) == 0 {
+		return nil, fmt.Errorf("no tags of %s match the tag patterns (%d tags)", name, len(tags))
+	}
+	runenv.Logger.Infof("Scanning %d of %d tags of %s", len(projects), len(tags), name)
+	return projects, nil
+}
+
+// matchTag reports whether a tag matches one of the globs, or empty when
+// there are none
+func matchTag(tag string, globs []string, empty bool) bool {
+	if len(globs) == 0 {
+		return empty
+	}
+	for _, glob := range globs {
+		if ok, _ := path.Match(glob, tag); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// listRegistryTags pages through the tags list of the registry API
+func listRegistryTags(ctx context.Context, repo registryRepository) ([]string, error) {
+	client := &registryClient{repo: repo}
+	next := fmt.Sprintf("%s/v2/%s/tags/list?n=%d", repo.baseURL(), repo.path, registryPageSize)
+	var tags []string
+	for next != "" {
+		body, header, err := client.get(ctx, next)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the tags of %s: %v", repo.name, err)
+		}
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("failed to decode the tags of %s: %v", repo.name, err)
+		}
+		tags = append(tags, page.Tags...)
+
+		next = ""
+		if m := registryNextLinkPattern.FindStringSubmatch(header.Get("Link")); m != nil {
+			link, err := url.Parse(m[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid next page of the tags of %s: %v", repo.name, err)
+			}
+			base, _ := url.Parse(repo.baseURL())
+			next = base.ResolveReference(link).String()
+		}
+	}
+	return tags, nil
+}
+
+// registryClient sends requests to the registry API, answering the
+// authentication challenge of the registry on the first 401 response
+type registryClient struct {
+	repo          registryRepository
+	authorization string
+}
+
+func (c *registryClient) get(ctx context.Context, target string) ([]byte, http.Header, error) {
+	var body []byte
+	var header http.Header
+	err := runenv.RetryTransient(ctx, "Registry request", func() error {
+		resp, err := c.do(ctx, target)
+		if err != nil {
+			return runenv.Transient(err)
+		}
+		if resp.StatusCode == http.StatusUnauthorized && c.authorization == "" {
+			resp.Body.Close()
+			if c.authorization, err = c.authenticate(ctx, resp.Header.Get("WWW-Authenticate")); err != nil {
+				return err
+			}
+			if resp, err = c.do(ctx, target); err != nil {
+				return runenv.Transient(err)
+			}
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			err := fmt.Errorf("%s", resp.Status)
+			if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+				return runenv.Transient(err)
+			}
+			return err
+		}
+		if body, err = io.ReadAll(resp.Body); err != nil {
+			return runenv.Transient(err)
+		}
+		header = resp.Header
+		return nil
+	})
+	return body, header, err
+}
+
+func (c *registryClient) do(ctx context.Context, target string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.authorization != "" {
+		req.Header.Set("Authorization", c.authorization)
+	}
+	return runenv.HTTPClient.Do(req)
+}
+
+// authenticate returns the Authorization header for a challenge: Basic with
+// the registry credentials, or Bearer with a pull token of the token service,
+// anonymous when there are no credentials
+func (c *registryClient) authenticate(ctx context.Context, challenge string) (string, error) {
+	username, password := registryCredentials(c.repo.host)
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if strings.EqualFold(scheme, "basic") {
+		if username == "" {
+			return "", fmt.Errorf("%s needs credentials, set REGISTRY_USERNAME and REGISTRY_PASSWORD or run docker login", c.repo.host)
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)), nil
+	}
+	if !strings.EqualFold(scheme, "bearer") {
+		return "", fmt.Errorf("unsupported authentication of %s: %q", c.repo.host, challenge)
+	}
+
+	fields := make(map[string]string)
+	for _, m := range registryChallengePattern.FindAllStringSubmatch(params, -1) {
+		fields[m[1]] = m[2]
+	}
+	if fields["realm"] == "" {
+		return "", fmt.Errorf("authentication challenge of %s without realm", c.repo.host)
+	}
+	query := url.Values{}
+	if fields["service"] != "" {
+		query.Set("service", fields["service"])
+	}
+	scope := fields["scope"]
+	if scope == "" {
+		scope = "repository:" + c.repo.path + ":pull"
+	}
+	query.Set("scope", scope)
+
+	body, err := runenv.Fetch(ctx, "Registry token request", func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fields["realm"]+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get a token for %s: %v", c.repo.name, err)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("failed to decode the token of %s: %v", c.repo.host, err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	return "Bearer " + token.Token, nil
+}
+
+// registryCredentials returns the credentials for a registry host from
+// REGISTRY_USERNAME and REGISTRY_PASSWORD, else from the Docker config file:
+// the auths written by docker login, or its credential helper
+func registryCredentials(host string) (string, string) {
+	if username := os.Getenv("REGISTRY_USERNAME"); username != "" {
+		return username, os.Getenv("REGISTRY_PASSWORD")
+	}
+
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", ""
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		return "", ""
+	}
+	var config struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+		CredsStore  string            `json:"credsStore"`
+		CredHelpers map[string]string `json:"credHelpers"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		runenv.Logger.Warnf("Failed to parse the Docker config: %v", err)
+		return "", ""
+	}
+
+	// Docker Hub credentials are stored under its index URL
+	keys := []string{host, "https://" + host}
+	if host == dockerHubRegistry {
+		keys = []string{"https://index.docker.io/v1/", "index.docker.io", "docker.io"}
+	}
+	for _, key := range keys {
+		if helper := config.CredHelpers[key]; helper != "" {
+			return credentialHelper(helper, key)
+		}
+	}
+	for _, key := range keys {
+		if auth, ok := config.Auths[key]; ok && auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				continue
+			}
+			username, password, _ := strings.Cut(string(decoded), ":")
+			return username, password
+		}
+	}
+	if config.CredsStore != "" {
+		return credentialHelper(config.CredsStore, keys[0])
+	}
+	return "", ""
+}
+
+// credentialHelper asks a docker-credential-<helper> program for the
+// credentials of a registry
+func credentialHelper(helper, serverURL string) (string, string) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(serverURL)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", ""
+	}
+	var credentials struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if json.Unmarshal(bytes.TrimSpace(output), &credentials) != nil {
+		return "", ""
+	}
+	return credentials.Username, credentials.Secret
+}
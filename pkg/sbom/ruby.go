@@ -0,0 +1,82 @@
+package sbom
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+// GenerateBundlerSBOM builds the SBOM from a Gemfile.lock. Gems of the GEM
+// sections are added, gems from GIT and PATH sources are listed as skipped.
+func GenerateBundlerSBOM(lockPath, outputPath string) error {
+	file, err := os.Open(lockPath)
+	if err != nil {
+		return fmt.Errorf("failed to read lockfile: %v", err)
+	}
+	defer file.Close()
+
+	var components []Component
+	var properties []CDXProperty
+	var section string
+	var inSpecs bool
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+
+		// Section headers: GEM, GIT, PATH, PLATFORMS, DEPENDENCIES, ...
+		if !strings.HasPrefix(line, " ") {
+			section = line
+			inSpecs = false
+			continue
+		}
+		if line == "  specs:" {
+			inSpecs = true
+			continue
+		}
+
+		// Gems are indented by four spaces, their dependencies by six
+		if !inSpecs || !strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "     ") {
+			continue
+		}
+
+		name, version, ok := gemSpec(strings.TrimSpace(line))
+		if !ok {
+			continue
+		}
+		if section != "GEM" {
+			properties = append(properties, CDXProperty{Name: propertySkipped, Value: name + " " + version + " (" + strings.ToLower(section) + " source)"})
+			continue
+		}
+		components = append(components, Component{Type: "gem", Name: name, Version: version})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", lockPath, err)
+	}
+
+	runenv.Logger.Infof("Found %d gems in %s", len(UniqueComponents(components)), filepath.Base(lockPath))
+	for _, p := range properties {
+		runenv.Logger.Warnf("Skipped %s", p.Value)
+	}
+
+	return writeCycloneDXWithProperties(outputPath, components, properties)
+}
+
+// gemSpec parses "nokogiri (1.15.4-x86_64-linux)" into name and version,
+// dropping the platform suffix
+func gemSpec(spec string) (string, string, bool) {
+	name, rest, ok := strings.Cut(spec, " (")
+	if !ok || !strings.HasSuffix(rest, ")") {
+		return "", "", false
+	}
+	version, _, _ := strings.Cut(strings.TrimSuffix(rest, ")"), "-")
+	return name, version, name != "" && version != ""
+}
@@ -0,0 +1,76 @@
+package sbom
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGenerateBundlerSBOM(t *testing.T) {
+	lockfile := `GIT
+  remote: https://github.com/example/forked.git
+  revision: 0123456
+  specs:
+    forked (0.3.0)
+
+PATH
+  remote: engines/local
+  specs:
+    local (0.1.0)
+      rails (>= 7.0)
+
+GEM
+  remote: https://rubygems.org/
+  specs:
+    nokogiri (1.15.4-x86_64-linux)
+      racc (~> 1.4)
+    racc (1.7.1)
+    rails (7.0.8)
+      actionpack (= 7.0.8)
+
+PLATFORMS
+  x86_64-linux
+
+DEPENDENCIES
+  forked!
+  local!
+  rails (~> 7.0)
+
+BUNDLED WITH
+   2.4.19
+`
+
+	purls, skipped := generateTestSBOM(t, GenerateBundlerSBOM, writeLockfile(t, "Gemfile.lock", lockfile))
+
+	want := []string{
+		"pkg:gem/nokogiri@1.15.4",
+		"pkg:gem/racc@1.7.1",
+		"pkg:gem/rails@7.0.8",
+	}
+	if !reflect.DeepEqual(purls, want) {
+		t.Errorf("components = %v, want %v", purls, want)
+	}
+	if want := []string{"forked 0.3.0 (git source)", "local 0.1.0 (path source)"}; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("skipped = %q, want %q", skipped, want)
+	}
+}
+
+func TestGemSpec(t *testing.T) {
+	tests := []struct {
+		spec    string
+		name    string
+		version string
+		ok      bool
+	}{
+		{"rails (7.0.8)", "rails", "7.0.8", true},
+		{"nokogiri (1.15.4-x86_64-linux)", "nokogiri", "1.15.4", true},
+		{"rails", "", "", false},
+		{"rails (7.0.8", "", "", false},
+	}
+
+	for _, tt := range tests {
+		name, version, ok := gemSpec(tt.spec)
+		if ok != tt.ok || (ok && (name != tt.name || version != tt.version)) {
+			t.Errorf("gemSpec(%s) = %q, %q, %v, want %q, %q, %v", tt.spec, name, version, ok, tt.name, tt.version, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package sbom
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+// crates.io sources in Cargo.lock, the sparse protocol is used since Cargo 1.70
+var cratesIOSources = map[string]bool{
+	"registry+https://github.com/rust-lang/crates.io-index": true,
+	"sparse+https://index.crates.io/":                       true,
+}
+
+// GenerateRustSBOM builds the SBOM from a Cargo.lock. Workspace members have
+// no source and are left out, crates from git or other registries are listed
+// as skipped because OSV only knows crates.io advisories.
+func GenerateRustSBOM(lockPath, outputPath string) error {
+	packages, err := readTOMLArrayTables(lockPath, "package")
+	if err != nil {
+		return err
+	}
+
+	var components []Component
+	var properties []CDXProperty
+	for _, p := range packages {
+		name, version, source := p["name"], p["version"], p["source"]
+		if name == "" || version == "" || source == "" {
+			continue
+		}
+		if !cratesIOSources[source] {
+			kind, _, _ := strings.Cut(source, "+")
+			properties = append(properties, CDXProperty{Name: propertySkipped, Value: name + " " + version + " (" + kind + " source)"})
+			continue
+		}
+		components = append(components, Component{Type: "cargo", Name: name, Version: version})
+	}
+
+	runenv.Logger.Infof("Found %d crates in %s", len(UniqueComponents(components)), filepath.Base(lockPath))
+	for _, p := range properties {
+		runenv.Logger.Warnf("Skipped %s", p.Value)
+	}
+
+	return writeCycloneDXWithProperties(outputPath, components, properties)
+}
@@ -0,0 +1,88 @@
+package sbom
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// generateTestSBOM runs generate on the lockfile and returns the sorted
+// package URLs and the skipped entries of the SBOM
+func generateTestSBOM(t *testing.T, generate func(lockPath, outputPath string) error, lockPath string) ([]string, []string) {
+	t.Helper()
+	sbomPath := filepath.Join(t.TempDir(), "bom.xml")
+	if err := generate(lockPath, sbomPath); err != nil {
+		t.Fatalf("generate(%s) error = %v", filepath.Base(lockPath), err)
+	}
+
+	data, err := os.ReadFile(sbomPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bom cdxBOM
+	if err := xml.Unmarshal(data, &bom); err != nil {
+		t.Fatal(err)
+	}
+
+	purls := []string{}
+	for _, c := range bom.Components {
+		purls = append(purls, c.PURL)
+	}
+	sort.Strings(purls)
+
+	var skipped []string
+	for _, p := range bom.Metadata.Properties {
+		if p.Name == propertySkipped {
+			skipped = append(skipped, p.Value)
+		}
+	}
+	return purls, skipped
+}
+
+func TestGenerateRustSBOM(t *testing.T) {
+	lockfile := `# This file is automatically @generated by Cargo.
+# It is not intended for manual editing.
+version = 3
+
+[[package]]
+name = "app"
+version = "0.1.0"
+dependencies = [
+ "serde",
+ "tokio",
+]
+
+[[package]]
+name = "serde"
+version = "1.0.188"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+checksum = "abc"
+
+[[package]]
+name = "tokio"
+version = "1.32.0"
+source = "sparse+https://index.crates.io/"
+
+[[package]]
+name = "forked"
+version = "0.2.0"
+source = "git+https://example.com/forked.git#0123456"
+
+[[package]]
+name = "internal"
+version = "1.0.0"
+source = "registry+https://crates.example.com/index"
+`
+
+	purls, skipped := generateTestSBOM(t, GenerateRustSBOM, writeLockfile(t, "Cargo.lock", lockfile))
+
+	if want := []string{"pkg:cargo/serde@1.0.188", "pkg:cargo/tokio@1.32.0"}; !reflect.DeepEqual(purls, want) {
+		t.Errorf("components = %v, want %v", purls, want)
+	}
+	if want := []string{"forked 0.2.0 (git source)", "internal 1.0.0 (registry source)"}; !reflect.DeepEqual(skipped, want) {
+		t.Errorf("skipped = %q, want %q", skipped, want)
+	}
+}
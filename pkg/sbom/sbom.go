@@ -0,0 +1,437 @@
+// Package sbom generates, converts, compares and merges SBOMs: the CycloneDX
+// model, the project discovery and the lockfile parsers of the supported
+// build tools.
+package sbom
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+// ReadCycloneDXBOM reads the CycloneDX XML document with its nested components
+func ReadCycloneDXBOM(path string) (cdxBOM, error) {
+	var bom cdxBOM
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return bom, fmt.Errorf("failed to read SBOM: %v", err)
+	}
+	if err := xml.Unmarshal(data, &bom); err != nil {
+		return bom, fmt.Errorf("failed to parse SBOM %s: %v", path, err)
+	}
+	return bom, nil
+}
+
+const cyclonedxNamespace = "http://cyclonedx.org/schema/bom/1.4"
+
+// SBOM metadata property for lockfile entries that were left out
+const propertySkipped = "sbom-scanner:skipped"
+
+// Component is a resolved dependency discovered in a manifest or lockfile
+type Component struct {
+	Type      string // purl type: maven, npm, ...
+	Namespace string
+	Name      string
+	Version   string
+	Licenses  []string // SPDX ids or license names, when known
+	// Encoded purl qualifiers of OS packages, e.g. arch=amd64&distro=debian-12
+	Qualifiers string
+}
+
+// PURL returns the package URL of the component
+func (c Component) PURL() string {
+	var b strings.Builder
+	b.WriteString("pkg:")
+	b.WriteString(c.Type)
+	b.WriteString("/")
+	if c.Namespace != "" {
+		for _, segment := range strings.Split(c.Namespace, "/") {
+			b.WriteString(escapePURLSegment(segment))
+			b.WriteString("/")
+		}
+	}
+	b.WriteString(escapePURLSegment(c.Name))
+	if c.Version != "" {
+		b.WriteString("@")
+		b.WriteString(escapePURLSegment(c.Version))
+	}
+	if c.Qualifiers != "" {
+		b.WriteString("?")
+		b.WriteString(c.Qualifiers)
+	}
+	return b.String()
+}
+
+func escapePURLSegment(s string) string {
+	return strings.ReplaceAll(url.PathEscape(s), "@", "%40")
+}
+
+type cdxBOM struct {
+	XMLName      xml.Name         `xml:"bom"`
+	XMLNS        string           `xml:"xmlns,attr,omitempty"`
+	SerialNumber string           `xml:"serialNumber,attr,omitempty"`
+	Version      int              `xml:"version,attr"`
+	Metadata     *cdxMetadata     `xml:"metadata,omitempty"`
+	Components   []CDXComponent   `xml:"components>component"`
+	Compositions *cdxCompositions `xml:"compositions"`
+}
+
+type cdxMetadata struct {
+	Timestamp  string        `xml:"timestamp,omitempty"`
+	Tools      []CDXTool     `xml:"tools>tool"`
+	Component  *CDXComponent `xml:"component,omitempty"`
+	Properties []CDXProperty `xml:"properties>property,omitempty"`
+}
+
+// CDXProperty is a name/value pair, used to record what the scanner skipped
+type CDXProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+type CDXTool struct {
+	Vendor  string `xml:"vendor,omitempty"`
+	Name    string `xml:"name"`
+	Version string `xml:"version,omitempty"`
+}
+
+type CDXComponent struct {
+	Type       string         `xml:"type,attr"`
+	BOMRef     string         `xml:"bom-ref,attr,omitempty"`
+	Group      string         `xml:"group,omitempty"`
+	Name       string         `xml:"name"`
+	Version    string         `xml:"version,omitempty"`
+	Scope      string         `xml:"scope,omitempty"` // required, optional or excluded
+	Hashes     *cdxHashes     `xml:"hashes"`
+	Licenses   *cdxLicenses   `xml:"licenses"`
+	PURL       string         `xml:"purl,omitempty"`
+	Properties []CDXProperty  `xml:"properties>property,omitempty"`
+	Components *cdxComponents `xml:"components"`
+}
+
+// cdxHashes wraps the hashes so that empty lists are omitted
+type cdxHashes struct {
+	Hashes []cdxHash `xml:"hash"`
+}
+
+// cdxHash is a digest of the artifact of a component, alg is e.g. SHA-256
+type cdxHash struct {
+	Alg   string `xml:"alg,attr"`
+	Value string `xml:",chardata"`
+}
+
+// cdxCompositions wraps the compositions so that empty lists are omitted
+type cdxCompositions struct {
+	Compositions []cdxComposition `xml:"composition"`
+}
+
+// cdxComposition states how complete the listed components are: complete,
+// incomplete or unknown
+type cdxComposition struct {
+	Aggregate  string        `xml:"aggregate"`
+	Assemblies []cdxAssembly `xml:"assemblies>assembly,omitempty"`
+}
+
+type cdxAssembly struct {
+	Ref string `xml:"ref,attr"`
+}
+
+// cdxLicenses holds either license entries or a single SPDX expression
+type cdxLicenses struct {
+	Licenses   []cdxLicense `xml:"license"`
+	Expression string       `xml:"expression,omitempty"`
+}
+
+type cdxLicense struct {
+	ID   string `xml:"id,omitempty"`
+	Name string `xml:"name,omitempty"`
+}
+
+// Names returns the declared licenses, normalized to SPDX ids where possible
+func (l *cdxLicenses) Names() []string {
+	if l == nil {
+		return nil
+	}
+	var names []string
+	for _, license := range l.Licenses {
+		name := license.ID
+		if name == "" {
+			name = license.Name
+		}
+		if name = NormalizeLicense(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if expression := strings.TrimSpace(l.Expression); expression != "" {
+		names = append(names, expression)
+	}
+	return names
+}
+
+// NewCDXLicenses encodes license names, known SPDX ids are written as ids
+func NewCDXLicenses(names []string) *cdxLicenses {
+	if len(names) == 0 {
+		return nil
+	}
+	if len(names) == 1 && isLicenseExpression(names[0]) {
+		return &cdxLicenses{Expression: names[0]}
+	}
+
+	licenses := &cdxLicenses{}
+	for _, name := range names {
+		if isSPDXLicense(name) {
+			licenses.Licenses = append(licenses.Licenses, cdxLicense{ID: name})
+		} else {
+			licenses.Licenses = append(licenses.Licenses, cdxLicense{Name: name})
+		}
+	}
+	return licenses
+}
+
+// cdxComponents wraps nested components so that empty lists are omitted
+type cdxComponents struct {
+	Components []CDXComponent `xml:"component"`
+}
+
+// ReadCycloneDX reads all components, including nested ones, from a CycloneDX XML document
+func ReadCycloneDX(path string) ([]CDXComponent, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SBOM: %v", err)
+	}
+
+	var bom cdxBOM
+	if err := xml.Unmarshal(data, &bom); err != nil {
+		return nil, fmt.Errorf("failed to parse SBOM %s: %v", path, err)
+	}
+
+	var components []CDXComponent
+	var walk func(list []CDXComponent)
+	walk = func(list []CDXComponent) {
+		for _, c := range list {
+			components = append(components, c)
+			if c.Components != nil {
+				walk(c.Components.Components)
+			}
+		}
+	}
+	walk(bom.Components)
+
+	return components, nil
+}
+
+// WriteCycloneDX writes the components as a CycloneDX XML document
+func WriteCycloneDX(outputPath string, components []Component) error {
+	return writeCycloneDXWithProperties(outputPath, components, nil)
+}
+
+// writeCycloneDXWithProperties also records the given properties in the BOM metadata
+func writeCycloneDXWithProperties(outputPath string, components []Component, properties []CDXProperty) error {
+	data, err := xml.MarshalIndent(NewCycloneDXBOM(components, properties), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode SBOM: %v", err)
+	}
+
+	if err := os.WriteFile(outputPath, append([]byte(xml.Header), data...), 0644); err != nil {
+		return fmt.Errorf("failed to write SBOM: %v", err)
+	}
+
+	runenv.Logger.Infof("CycloneDX BOM written to %s", outputPath)
+	return nil
+}
+
+// NewCycloneDXBOM returns the CycloneDX document of the unique components
+func NewCycloneDXBOM(components []Component, properties []CDXProperty) cdxBOM {
+	components = UniqueComponents(components)
+
+	bom := cdxBOM{
+		XMLNS:   cyclonedxNamespace,
+		Version: 1,
+		Metadata: &cdxMetadata{
+			Timestamp:  time.Now().UTC().Format(time.RFC3339),
+			Tools:      []CDXTool{{Name: "sbom-scanner"}},
+			Properties: properties,
+		},
+	}
+
+	for _, c := range components {
+		purl := c.PURL()
+		bom.Components = append(bom.Components, CDXComponent{
+			Type:     "library",
+			BOMRef:   purl,
+			Group:    c.Namespace,
+			Name:     c.Name,
+			Version:  c.Version,
+			Licenses: NewCDXLicenses(c.Licenses),
+			PURL:     purl,
+		})
+	}
+	return bom
+}
+
+// CDXJSONDocument is a CycloneDX JSON document, written by merge and as the
+// predicate of the SBOM attestation
+type CDXJSONDocument struct {
+	BOMFormat    string               `json:"bomFormat"`
+	SpecVersion  string               `json:"specVersion"`
+	SerialNumber string               `json:"serialNumber,omitempty"`
+	Version      int                  `json:"version"`
+	Metadata     cdxJSONMetadata      `json:"metadata"`
+	Components   []cdxJSONLibrary     `json:"components"`
+	Compositions []cdxJSONComposition `json:"compositions,omitempty"`
+}
+
+type cdxJSONMetadata struct {
+	Timestamp  string            `json:"timestamp"`
+	Component  *cdxJSONLibrary   `json:"component,omitempty"`
+	Tools      []CDXJSONTool     `json:"tools"`
+	Properties []cdxJSONProperty `json:"properties,omitempty"`
+}
+
+type CDXJSONTool struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type cdxJSONProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxJSONLibrary struct {
+	Type       string            `json:"type"`
+	BOMRef     string            `json:"bom-ref,omitempty"`
+	Group      string            `json:"group,omitempty"`
+	Name       string            `json:"name"`
+	Version    string            `json:"version,omitempty"`
+	Scope      string            `json:"scope,omitempty"`
+	Hashes     []cdxJSONHash     `json:"hashes,omitempty"`
+	Licenses   []map[string]any  `json:"licenses,omitempty"`
+	PURL       string            `json:"purl,omitempty"`
+	Properties []cdxJSONProperty `json:"properties,omitempty"`
+	Components []cdxJSONLibrary  `json:"components,omitempty"`
+}
+
+type cdxJSONHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxJSONComposition struct {
+	Aggregate  string   `json:"aggregate"`
+	Assemblies []string `json:"assemblies,omitempty"`
+}
+
+// CycloneDXJSON converts a CycloneDX XML document to CycloneDX JSON
+func CycloneDXJSON(bom cdxBOM) CDXJSONDocument {
+	doc := CDXJSONDocument{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.4",
+		SerialNumber: bom.SerialNumber,
+		Version:      max(bom.Version, 1),
+		Metadata:     cdxJSONMetadata{Timestamp: time.Now().UTC().Format(time.RFC3339)},
+		Components:   []cdxJSONLibrary{},
+	}
+	if bom.Metadata != nil {
+		if bom.Metadata.Timestamp != "" {
+			doc.Metadata.Timestamp = bom.Metadata.Timestamp
+		}
+		for _, tool := range bom.Metadata.Tools {
+			doc.Metadata.Tools = append(doc.Metadata.Tools, CDXJSONTool{Name: tool.Name, Version: tool.Version})
+		}
+		if c := bom.Metadata.Component; c != nil {
+			component := cycloneDXJSONComponents([]CDXComponent{*c})[0]
+			doc.Metadata.Component = &component
+		}
+		for _, property := range bom.Metadata.Properties {
+			doc.Metadata.Properties = append(doc.Metadata.Properties, cdxJSONProperty{Name: property.Name, Value: property.Value})
+		}
+	}
+	if components := cycloneDXJSONComponents(bom.Components); components != nil {
+		doc.Components = components
+	}
+	if bom.Compositions == nil {
+		return doc
+	}
+	for _, composition := range bom.Compositions.Compositions {
+		converted := cdxJSONComposition{Aggregate: composition.Aggregate}
+		for _, assembly := range composition.Assemblies {
+			converted.Assemblies = append(converted.Assemblies, assembly.Ref)
+		}
+		doc.Compositions = append(doc.Compositions, converted)
+	}
+	return doc
+}
+
+func cycloneDXJSONComponents(list []CDXComponent) []cdxJSONLibrary {
+	var result []cdxJSONLibrary
+	for _, c := range list {
+		library := cdxJSONLibrary{
+			Type:    c.Type,
+			BOMRef:  c.BOMRef,
+			Group:   c.Group,
+			Name:    c.Name,
+			Version: c.Version,
+			Scope:   c.Scope,
+			PURL:    c.PURL,
+		}
+		if library.Type == "" {
+			library.Type = "library"
+		}
+		if c.Hashes != nil {
+			for _, hash := range c.Hashes.Hashes {
+				library.Hashes = append(library.Hashes, cdxJSONHash{Alg: hash.Alg, Content: strings.TrimSpace(hash.Value)})
+			}
+		}
+		if c.Licenses != nil {
+			for _, license := range c.Licenses.Licenses {
+				entry := map[string]any{}
+				if license.ID != "" {
+					entry["id"] = license.ID
+				} else {
+					entry["name"] = license.Name
+				}
+				library.Licenses = append(library.Licenses, map[string]any{"license": entry})
+			}
+			if expression := strings.TrimSpace(c.Licenses.Expression); expression != "" {
+				library.Licenses = append(library.Licenses, map[string]any{"expression": expression})
+			}
+		}
+		for _, property := range c.Properties {
+			library.Properties = append(library.Properties, cdxJSONProperty{Name: property.Name, Value: property.Value})
+		}
+		if c.Components != nil {
+			library.Components = cycloneDXJSONComponents(c.Components.Components)
+		}
+		result = append(result, library)
+	}
+	return result
+}
+
+// UniqueComponents removes duplicates and sorts the components by purl
+func UniqueComponents(components []Component) []Component {
+	seen := make(map[string]bool)
+	var result []Component
+	for _, c := range components {
+		if c.Name == "" {
+			continue
+		}
+		key := c.PURL()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, c)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].PURL() < result[j].PURL()
+	})
+	return result
+}
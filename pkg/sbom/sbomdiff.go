@@ -0,0 +1,395 @@
+package sbom
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Package is a component read from a CycloneDX or SPDX document
+type Package struct {
+	Key     string `json:"-"` // package URL without version, or group/name
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	PURL    string `json:"purl,omitempty"`
+}
+
+// VersionChange is a component whose version differs between two SBOMs
+type VersionChange struct {
+	Name string `json:"name"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Diff lists the dependency changes between two SBOMs
+type Diff struct {
+	Old        string          `json:"old"`
+	New        string          `json:"new"`
+	Added      []Package       `json:"added"`
+	Removed    []Package       `json:"removed"`
+	Upgraded   []VersionChange `json:"upgraded"`
+	Downgraded []VersionChange `json:"downgraded"`
+	Unchanged  int             `json:"unchanged"`
+}
+
+type cdxJSONBOM struct {
+	BOMFormat  string             `json:"bomFormat"`
+	Components []cdxJSONComponent `json:"components"`
+}
+
+type cdxJSONComponent struct {
+	Group      string             `json:"group"`
+	Name       string             `json:"name"`
+	Version    string             `json:"version"`
+	PURL       string             `json:"purl"`
+	Components []cdxJSONComponent `json:"components"`
+}
+
+type spdxDocument struct {
+	SPDXVersion string `json:"spdxVersion"`
+	Packages    []struct {
+		Name         string `json:"name"`
+		VersionInfo  string `json:"versionInfo"`
+		ExternalRefs []struct {
+			ReferenceType    string `json:"referenceType"`
+			ReferenceLocator string `json:"referenceLocator"`
+		} `json:"externalRefs"`
+	} `json:"packages"`
+}
+
+// ReadPackages reads the components of a CycloneDX (XML or JSON) or SPDX
+// (JSON or tag-value) document
+func ReadPackages(path string) ([]Package, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SBOM: %v", err)
+	}
+	trimmed := bytes.TrimSpace(data)
+
+	var packages []Package
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		var bom cdxBOM
+		if err := xml.Unmarshal(data, &bom); err != nil {
+			return nil, fmt.Errorf("failed to parse SBOM %s: %v", path, err)
+		}
+		var walk func(list []CDXComponent)
+		walk = func(list []CDXComponent) {
+			for _, c := range list {
+				packages = append(packages, NewPackage(c.Group, c.Name, c.Version, c.PURL))
+				if c.Components != nil {
+					walk(c.Components.Components)
+				}
+			}
+		}
+		walk(bom.Components)
+
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		var probe struct {
+			BOMFormat   string `json:"bomFormat"`
+			SPDXVersion string `json:"spdxVersion"`
+		}
+		if err := json.Unmarshal(data, &probe); err != nil {
+			return nil, fmt.Errorf("failed to parse SBOM %s: %v", path, err)
+		}
+		switch {
+		case probe.BOMFormat == "CycloneDX":
+			var bom cdxJSONBOM
+			if err := json.Unmarshal(data, &bom); err != nil {
+				return nil, fmt.Errorf("failed to parse SBOM %s: %v", path, err)
+			}
+			var walk func(list []cdxJSONComponent)
+			walk = func(list []cdxJSONComponent) {
+				for _, c := range list {
+					packages = append(packages, NewPackage(c.Group, c.Name, c.Version, c.PURL))
+					walk(c.Components)
+				}
+			}
+			walk(bom.Components)
+
+		case probe.SPDXVersion != "":
+			var doc spdxDocument
+			if err := json.Unmarshal(data, &doc); err != nil {
+				return nil, fmt.Errorf("failed to parse SBOM %s: %v", path, err)
+			}
+			for _, p := range doc.Packages {
+				var purl string
+				for _, ref := range p.ExternalRefs {
+					if ref.ReferenceType == "purl" {
+						purl = ref.ReferenceLocator
+					}
+				}
+				packages = append(packages, NewPackage("", p.Name, p.VersionInfo, purl))
+			}
+
+		default:
+			return nil, fmt.Errorf("unknown SBOM format: %s", path)
+		}
+
+	case bytes.HasPrefix(trimmed, []byte("SPDXVersion:")):
+		packages = readSPDXTagValue(data)
+
+	default:
+		return nil, fmt.Errorf("unknown SBOM format: %s", path)
+	}
+
+	return packages, nil
+}
+
+// readSPDXTagValue reads the packages of an SPDX tag-value document
+func readSPDXTagValue(data []byte) []Package {
+	var packages []Package
+	var name, version, purl string
+	inPackage := false
+
+	flush := func() {
+		if inPackage && name != "" {
+			packages = append(packages, NewPackage("", name, version, purl))
+		}
+		name, version, purl = "", "", ""
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		tag, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch tag {
+		case "PackageName":
+			flush()
+			inPackage = true
+			name = value
+		case "PackageVersion":
+			version = value
+		case "ExternalRef":
+			// ExternalRef: PACKAGE-MANAGER purl pkg:npm/lodash@4.17.21
+			if fields := strings.Fields(value); len(fields) == 3 && fields[1] == "purl" {
+				purl = fields[2]
+			}
+		case "FileName", "SnippetSPDXID":
+			flush()
+			inPackage = false
+		}
+	}
+	flush()
+	return packages
+}
+
+// NewPackage keys a component by its package URL without version, or by
+// group and name when it has none
+func NewPackage(group, name, version, purl string) Package {
+	p := Package{Name: name, Version: version, PURL: purl}
+	if group != "" {
+		p.Name = group + ":" + name
+	}
+
+	if purl != "" {
+		base := StripPURLQualifiers(purl)
+		if i := strings.LastIndex(base, "@"); i > strings.LastIndex(base, "/") {
+			if p.Version == "" {
+				p.Version = base[i+1:]
+			}
+			base = base[:i]
+		}
+		p.Key = base
+		if pkg, ok := PackageFromPURL(purl); ok {
+			p.Name = pkg.Name
+		}
+		return p
+	}
+
+	p.Key = strings.ToLower(p.Name)
+	return p
+}
+
+// DiffSBOMs compares the components of two SBOMs. A package with a single
+// version on both sides is an upgrade or downgrade; with several versions
+// the versions are listed as added and removed.
+func DiffSBOMs(oldPackages, newPackages []Package) Diff {
+	diff := Diff{Added: []Package{}, Removed: []Package{}, Upgraded: []VersionChange{}, Downgraded: []VersionChange{}}
+
+	group := func(packages []Package) map[string]map[string]Package {
+		result := make(map[string]map[string]Package)
+		for _, p := range packages {
+			if result[p.Key] == nil {
+				result[p.Key] = make(map[string]Package)
+			}
+			result[p.Key][p.Version] = p
+		}
+		return result
+	}
+	before, after := group(oldPackages), group(newPackages)
+
+	for key, versions := range after {
+		old, ok := before[key]
+		if !ok {
+			for _, p := range versions {
+				diff.Added = append(diff.Added, p)
+			}
+			continue
+		}
+
+		if len(old) == 1 && len(versions) == 1 {
+			oldPkg, newPkg := onlyPackage(old), onlyPackage(versions)
+			change := VersionChange{Name: newPkg.Name, From: oldPkg.Version, To: newPkg.Version}
+			switch cmp := CompareVersions(oldPkg.Version, newPkg.Version); {
+			case oldPkg.Version == newPkg.Version:
+				diff.Unchanged++
+			case cmp > 0:
+				diff.Downgraded = append(diff.Downgraded, change)
+			default:
+				diff.Upgraded = append(diff.Upgraded, change)
+			}
+			continue
+		}
+
+		for version, p := range versions {
+			if _, ok := old[version]; ok {
+				diff.Unchanged++
+			} else {
+				diff.Added = append(diff.Added, p)
+			}
+		}
+		for version, p := range old {
+			if _, ok := versions[version]; !ok {
+				diff.Removed = append(diff.Removed, p)
+			}
+		}
+	}
+
+	for key, versions := range before {
+		if _, ok := after[key]; !ok {
+			for _, p := range versions {
+				diff.Removed = append(diff.Removed, p)
+			}
+		}
+	}
+
+	sortPackages := func(list []Package) {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Name != list[j].Name {
+				return list[i].Name < list[j].Name
+			}
+			return list[i].Version < list[j].Version
+		})
+	}
+	sortChanges := func(list []VersionChange) {
+		sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	}
+	sortPackages(diff.Added)
+	sortPackages(diff.Removed)
+	sortChanges(diff.Upgraded)
+	sortChanges(diff.Downgraded)
+	return diff
+}
+
+func onlyPackage(versions map[string]Package) Package {
+	for _, p := range versions {
+		return p
+	}
+	return Package{}
+}
+
+// Version qualifiers that sort before the release they belong to
+var preReleaseQualifiers = []string{"alpha", "beta", "milestone", "rc", "cr", "snapshot", "dev", "pre", "preview", "a", "b", "m"}
+
+// CompareVersions compares two version strings segment by segment: numbers
+// numerically, other segments alphabetically. A release sorts after its
+// pre-releases, so 1.0.0-rc1 < 1.0.0 < 1.0.1.
+func CompareVersions(a, b string) int {
+	as, bs := VersionSegments(a), VersionSegments(b)
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		switch {
+		case i >= len(as):
+			if order := ExtraSegmentOrder(bs[i]); order != 0 {
+				return -order
+			}
+			continue
+		case i >= len(bs):
+			if order := ExtraSegmentOrder(as[i]); order != 0 {
+				return order
+			}
+			continue
+		}
+
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				return compareInts(an, bn)
+			}
+		case aErr == nil:
+			return 1 // 1.0.1 > 1.0.rc1
+		case bErr == nil:
+			return -1
+		default:
+			if c := strings.Compare(strings.ToLower(as[i]), strings.ToLower(bs[i])); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
+
+// ExtraSegmentOrder returns how a version with this additional segment
+// compares to the version without it: 1.0.0 equals 1.0, 1.0.1 is newer and
+// 1.0-rc1 older
+func ExtraSegmentOrder(segment string) int {
+	if n, err := strconv.Atoi(segment); err == nil && n == 0 {
+		return 0
+	}
+	lower := strings.ToLower(segment)
+	for _, q := range preReleaseQualifiers {
+		if strings.HasPrefix(lower, q) {
+			return -1
+		}
+	}
+	return 1
+}
+
+// VersionSegments splits a version at separators and between digits and letters
+func VersionSegments(version string) []string {
+	var segments []string
+	var current strings.Builder
+	lastDigit := false
+	for _, r := range strings.TrimPrefix(version, "v") {
+		if r == '.' || r == '-' || r == '+' || r == '_' || r == '~' {
+			if current.Len() > 0 {
+				segments = append(segments, current.String())
+				current.Reset()
+			}
+			continue
+		}
+		isDigit := unicode.IsDigit(r)
+		if current.Len() > 0 && isDigit != lastDigit {
+			segments = append(segments, current.String())
+			current.Reset()
+		}
+		current.WriteRune(r)
+		lastDigit = isDigit
+	}
+	if current.Len() > 0 {
+		segments = append(segments, current.String())
+	}
+	return segments
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,104 @@
+package sbom
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.0.0", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.0-RC1", "1.0-rc1", 0},
+		{"1.2", "1.10", -1},
+		{"1.0.0", "1.0.1", -1},
+		{"2.13.4", "2.13.4.2", -1},
+		{"4.1.90.Final", "4.1.94.Final", -1},
+		{"1.0.0-rc1", "1.0.0", -1},
+		{"2.0.0-SNAPSHOT", "2.0.0", -1},
+		{"1.0-M1", "1.0", -1},
+		{"1.0-alpha", "1.0-beta", -1},
+		{"1.0-beta2", "1.0-beta10", -1},
+		{"1.0.rc1", "1.0.1", -1},
+		{"1.9.9", "2.0.0-rc1", -1},
+	}
+
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := CompareVersions(tt.b, tt.a); got != -tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestVersionSegments(t *testing.T) {
+	tests := []struct {
+		version string
+		want    []string
+	}{
+		{"", nil},
+		{"1.2.3", []string{"1", "2", "3"}},
+		{"v1.2.3-rc1", []string{"1", "2", "3", "rc", "1"}},
+		{"4.1.94.Final", []string{"4", "1", "94", "Final"}},
+		{"1.0.0+build.5", []string{"1", "0", "0", "build", "5"}},
+		{"2.0_beta~1", []string{"2", "0", "beta", "1"}},
+	}
+
+	for _, tt := range tests {
+		if got := VersionSegments(tt.version); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("VersionSegments(%q) = %q, want %q", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestDiffSBOMs(t *testing.T) {
+	oldPackages := []Package{
+		NewPackage("", "", "", "pkg:maven/org.yaml/snakeyaml@1.33"),
+		NewPackage("", "", "", "pkg:maven/com.google.guava/guava@32.1.2-jre"),
+		NewPackage("", "", "", "pkg:npm/lodash@4.17.21"),
+		NewPackage("", "", "", "pkg:npm/debug@2.6.9"),
+		NewPackage("", "", "", "pkg:npm/debug@4.3.4"),
+		NewPackage("", "", "", "pkg:npm/left-pad@1.3.0"),
+		NewPackage("org.example", "internal", "1.0", ""),
+	}
+	newPackages := []Package{
+		NewPackage("", "", "", "pkg:maven/org.yaml/snakeyaml@2.0"),
+		NewPackage("", "", "", "pkg:maven/com.google.guava/guava@31.1-jre"),
+		NewPackage("", "", "", "pkg:npm/lodash@4.17.21"),
+		NewPackage("", "", "", "pkg:npm/debug@4.3.4"),
+		NewPackage("", "", "", "pkg:npm/debug@4.3.5"),
+		NewPackage("", "", "", "pkg:npm/chalk@5.3.0?arch=any"),
+		NewPackage("org.example", "internal", "1.0", ""),
+	}
+
+	diff := DiffSBOMs(oldPackages, newPackages)
+
+	names := func(packages []Package) []string {
+		var result []string
+		for _, p := range packages {
+			result = append(result, p.Name+"@"+p.Version)
+		}
+		return result
+	}
+	if got, want := names(diff.Added), []string{"chalk@5.3.0", "debug@4.3.5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("added = %v, want %v", got, want)
+	}
+	if got, want := names(diff.Removed), []string{"debug@2.6.9", "left-pad@1.3.0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("removed = %v, want %v", got, want)
+	}
+	if want := []VersionChange{{Name: "org.yaml:snakeyaml", From: "1.33", To: "2.0"}}; !reflect.DeepEqual(diff.Upgraded, want) {
+		t.Errorf("upgraded = %v, want %v", diff.Upgraded, want)
+	}
+	if want := []VersionChange{{Name: "com.google.guava:guava", From: "32.1.2-jre", To: "31.1-jre"}}; !reflect.DeepEqual(diff.Downgraded, want) {
+		t.Errorf("downgraded = %v, want %v", diff.Downgraded, want)
+	}
+	if diff.Unchanged != 3 {
+		t.Errorf("unchanged = %d, want 3", diff.Unchanged)
+	}
+}
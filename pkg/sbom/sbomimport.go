@@ -0,0 +1,131 @@
+package sbom
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+// isSBOMFile reports whether path is a CycloneDX (XML or JSON) or SPDX
+// (JSON or tag-value) document rather than a project file
+func isSBOMFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xml", ".json", ".spdx":
+	default:
+		return false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return isSBOMData(data)
+}
+
+// isSBOMData reports whether data is a CycloneDX or SPDX document
+func isSBOMData(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return isCycloneDXXML(trimmed)
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		var probe struct {
+			BOMFormat   string `json:"bomFormat"`
+			SPDXVersion string `json:"spdxVersion"`
+		}
+		if err := json.Unmarshal(trimmed, &probe); err != nil {
+			return false
+		}
+		return probe.BOMFormat == "CycloneDX" || probe.SPDXVersion != ""
+	default:
+		return bytes.HasPrefix(trimmed, []byte("SPDXVersion:"))
+	}
+}
+
+// isCycloneDXXML reports whether the root element of an XML document is <bom>
+func isCycloneDXXML(data []byte) bool {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return false
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local == "bom"
+		}
+	}
+}
+
+// ImportSBOM makes an existing SBOM the sbom.xml of the scan. CycloneDX XML is
+// copied as is, other formats are converted to CycloneDX XML.
+func ImportSBOM(sourcePath, sbomPath string) error {
+	data, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to read SBOM: %v", err)
+	}
+	if isCycloneDXXML(bytes.TrimSpace(data)) {
+		if err := runenv.CopyFile(sourcePath, sbomPath); err != nil {
+			return fmt.Errorf("failed to copy SBOM: %v", err)
+		}
+		runenv.Logger.Infof("Imported CycloneDX BOM %s", sourcePath)
+		return nil
+	}
+
+	packages, err := ReadPackages(sourcePath)
+	if err != nil {
+		return err
+	}
+
+	bom := cdxBOM{
+		XMLNS:   cyclonedxNamespace,
+		Version: 1,
+		Metadata: &cdxMetadata{
+			Timestamp:  time.Now().UTC().Format(time.RFC3339),
+			Tools:      []CDXTool{{Name: "sbom-scanner"}},
+			Properties: []CDXProperty{{Name: "sbom-scanner:source", Value: filepath.Base(sourcePath)}},
+		},
+	}
+	seen := make(map[string]bool)
+	for _, p := range packages {
+		key := p.PURL
+		if key == "" {
+			key = p.Name + "@" + p.Version
+		}
+		if p.Name == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		component := CDXComponent{
+			Type:    "library",
+			BOMRef:  key,
+			Name:    p.Name,
+			Version: p.Version,
+			PURL:    p.PURL,
+		}
+		// Maven packages are named group:artifact
+		if strings.HasPrefix(p.PURL, "pkg:maven/") {
+			if group, name, ok := strings.Cut(p.Name, ":"); ok {
+				component.Group, component.Name = group, name
+			}
+		}
+		bom.Components = append(bom.Components, component)
+	}
+
+	encoded, err := xml.MarshalIndent(bom, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode SBOM: %v", err)
+	}
+	if err := os.WriteFile(sbomPath, append([]byte(xml.Header), encoded...), 0644); err != nil {
+		return fmt.Errorf("failed to write SBOM: %v", err)
+	}
+
+	runenv.Logger.Infof("Converted %s to CycloneDX (%d components)", sourcePath, len(bom.Components))
+	return nil
+}
@@ -0,0 +1,98 @@
+package sbom
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// readTOMLArrayTables reads the string values of every [[name]] table of a
+// TOML lockfile such as poetry.lock or Cargo.lock. Keys of sub-tables like
+// [name.source] are prefixed with "source.". Only string values are read,
+// arrays and multi-line strings are skipped.
+func readTOMLArrayTables(path, name string) ([]map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lockfile: %v", err)
+	}
+	defer file.Close()
+
+	var tables []map[string]string
+	var current map[string]string
+	var prefix, multiline string
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Skip the body of """ and ''' strings
+		if multiline != "" {
+			if strings.Contains(line, multiline) {
+				multiline = ""
+			}
+			continue
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			header := strings.Trim(line, "[] ")
+			switch {
+			case line == "[["+name+"]]":
+				current = make(map[string]string)
+				tables = append(tables, current)
+				prefix = ""
+			case current != nil && strings.HasPrefix(header, name+".") && !strings.HasPrefix(line, "[["):
+				prefix = strings.TrimPrefix(header, name+".") + "."
+			default:
+				current = nil
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		for _, quote := range []string{`"""`, `'''`} {
+			if strings.HasPrefix(value, quote) && !strings.Contains(value[3:], quote) {
+				multiline = quote
+			}
+		}
+		if current == nil || multiline != "" {
+			continue
+		}
+
+		if s, ok := tomlString(value); ok {
+			current[prefix+strings.Trim(strings.TrimSpace(key), `"`)] = s
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return tables, nil
+}
+
+// tomlString decodes a basic ("...") or literal ('...') TOML string
+func tomlString(value string) (string, bool) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		if end := strings.LastIndex(value, `"`); end > 0 {
+			s, err := strconv.Unquote(value[:end+1])
+			return s, err == nil
+		}
+	case strings.HasPrefix(value, "'"):
+		if end := strings.LastIndex(value, "'"); end > 0 {
+			return value[1:end], true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,91 @@
+package scan
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+// sbomCacheKey hashes the components of the SBOM together with the scanners.
+// The document itself is not hashed because timestamps and serial numbers
+// change on every run.
+func sbomCacheKey(ctx context.Context, sbomPath string, scanners []string) (string, error) {
+	components, err := sbom.ReadCycloneDX(sbomPath)
+	if err != nil {
+		return "", err
+	}
+
+	var purls []string
+	for _, c := range components {
+		if c.PURL != "" {
+			purls = append(purls, sbom.StripPURLQualifiers(c.PURL))
+		}
+	}
+	sort.Strings(purls)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "scanners=%s\noffline=%t\n", strings.Join(scanners, ","), runenv.SettingsOf(ctx).Offline)
+	for _, purl := range purls {
+		fmt.Fprintln(h, purl)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// cachedScan returns the findings of a previous scan of the same components
+// and restores the scanners' raw reports next to the SBOM. It reports false
+// when there is no entry younger than the TTL.
+func cachedScan(ctx context.Context, key string, scanners []string, sbomPath string) ([]Finding, bool) {
+	s := runenv.SettingsOf(ctx)
+	entry := filepath.Join(s.CacheDir("results"), key)
+	age, fresh := s.CacheEntryFresh(filepath.Join(entry, "findings.json"))
+	if !fresh {
+		return nil, false
+	}
+
+	findings, err := ReadFindings(filepath.Join(entry, "findings.json"))
+	if err != nil {
+		runenv.Logger.Warnf("Ignoring cached scan results: %v", err)
+		return nil, false
+	}
+	for _, name := range scanners {
+		suffix := ScannerBackends[name].rawSuffix
+		if err := runenv.CopyFile(filepath.Join(entry, "sbom"+suffix), rawReportPath(sbomPath, suffix)); err != nil {
+			runenv.Logger.Warnf("Ignoring cached scan results: %v", err)
+			return nil, false
+		}
+	}
+
+	runenv.Logger.Infof("Reusing scan results cached %s ago (SBOM hash %s, --no-cache to scan again)",
+		age.Round(time.Second), key[:12])
+	return findings, true
+}
+
+// storeScan caches the findings and raw reports of a scan
+func storeScan(ctx context.Context, key string, scanners []string, sbomPath string, findings []Finding) error {
+	entry := filepath.Join(runenv.SettingsOf(ctx).CacheDir("results"), key)
+	if err := os.MkdirAll(entry, 0755); err != nil {
+		return fmt.Errorf("failed to create cache directory: %v", err)
+	}
+	for _, name := range scanners {
+		suffix := ScannerBackends[name].rawSuffix
+		if err := runenv.CopyFile(rawReportPath(sbomPath, suffix), filepath.Join(entry, "sbom"+suffix)); err != nil {
+			return err
+		}
+	}
+	// Written last, its modification time marks a complete entry
+	return writeFindings(filepath.Join(entry, "findings.json"), findings)
+}
+
+// rawReportPath returns where a scanner's own output is written next to the SBOM
+func rawReportPath(sbomPath, suffix string) string {
+	return strings.TrimSuffix(sbomPath, filepath.Ext(sbomPath)) + suffix
+}
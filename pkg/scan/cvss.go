@@ -0,0 +1,81 @@
+package scan
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pandatix/go-cvss/20"
+	"github.com/pandatix/go-cvss/30"
+	"github.com/pandatix/go-cvss/31"
+	"github.com/pandatix/go-cvss/40"
+)
+
+// cvss3BaseScore computes the base score of a CVSS v3.0 or v3.1 vector, with
+// the rounding of its version
+func cvss3BaseScore(vector string) (float64, error) {
+	switch {
+	case strings.HasPrefix(vector, "CVSS:3.1/"):
+		parsed, err := gocvss31.ParseVector(vector)
+		if err != nil {
+			return 0, fmt.Errorf("invalid CVSS v3 vector: %s", vector)
+		}
+		return parsed.BaseScore(), nil
+	case strings.HasPrefix(vector, "CVSS:3.0/"):
+		parsed, err := gocvss30.ParseVector(vector)
+		if err != nil {
+			return 0, fmt.Errorf("invalid CVSS v3 vector: %s", vector)
+		}
+		return parsed.BaseScore(), nil
+	}
+	return 0, fmt.Errorf("not a CVSS v3 vector: %s", vector)
+}
+
+// cvss4Score computes the score of a CVSS v4.0 vector. Unlike v3, v4 scores
+// come from the lookup table of macro vectors of the specification.
+func cvss4Score(vector string) (float64, error) {
+	if !strings.HasPrefix(vector, "CVSS:4.0/") {
+		return 0, fmt.Errorf("not a CVSS v4 vector: %s", vector)
+	}
+	parsed, err := gocvss40.ParseVector(vector)
+	if err != nil {
+		return 0, fmt.Errorf("invalid CVSS v4 vector: %s", vector)
+	}
+	return parsed.Score(), nil
+}
+
+// cvss2BaseScore computes the base score of a CVSS v2 vector
+func cvss2BaseScore(vector string) (float64, error) {
+	parsed, err := gocvss20.ParseVector(vector)
+	if err != nil {
+		return 0, fmt.Errorf("invalid CVSS v2 vector: %s", vector)
+	}
+	return parsed.BaseScore(), nil
+}
+
+// cvssSeverity maps a CVSS v3 base score onto a severity level
+func cvssSeverity(score float64) string {
+	switch {
+	case score >= 9.0:
+		return SeverityCritical
+	case score >= 7.0:
+		return SeverityHigh
+	case score >= 4.0:
+		return SeverityMedium
+	case score > 0:
+		return SeverityLow
+	}
+	return SeverityUnknown
+}
+
+// cvss2Severity maps a CVSS v2 base score onto a severity level
+func cvss2Severity(score float64) string {
+	switch {
+	case score >= 7.0:
+		return SeverityHigh
+	case score >= 4.0:
+		return SeverityMedium
+	case score > 0:
+		return SeverityLow
+	}
+	return SeverityUnknown
+}
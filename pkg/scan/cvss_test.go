@@ -0,0 +1,142 @@
+package scan
+
+import "testing"
+
+func TestCVSS3BaseScore(t *testing.T) {
+	tests := []struct {
+		vector string
+		want   float64
+	}{
+		// Scores of the NVD calculator
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", 9.8},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H", 10.0},
+		{"CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:C/C:H/I:H/A:H", 9.9},
+		{"CVSS:3.1/AV:N/AC:L/PR:H/UI:N/S:U/C:H/I:H/A:H", 7.2},
+		{"CVSS:3.1/AV:N/AC:L/PR:H/UI:N/S:C/C:H/I:H/A:H", 9.1},
+		{"CVSS:3.1/AV:L/AC:L/PR:L/UI:N/S:U/C:H/I:H/A:H", 7.8},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:H", 7.5},
+		{"CVSS:3.1/AV:N/AC:H/PR:N/UI:N/S:U/C:H/I:N/A:N", 5.9},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N", 6.1},
+		{"CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:U/C:L/I:N/A:N", 4.3},
+		{"CVSS:3.1/AV:P/AC:H/PR:H/UI:R/S:U/C:L/I:N/A:N", 1.6},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:N", 0},
+		{"CVSS:3.0/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", 9.8},
+		{"CVSS:3.0/AV:N/AC:L/PR:L/UI:N/S:C/C:L/I:L/A:N", 6.4},
+		// Metric order does not matter
+		{"CVSS:3.1/C:H/I:H/A:H/AV:N/AC:L/PR:N/UI:N/S:U", 9.8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.vector, func(t *testing.T) {
+			got, err := cvss3BaseScore(tt.vector)
+			if err != nil {
+				t.Fatalf("cvss3BaseScore() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("cvss3BaseScore() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCVSS3BaseScoreInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H",
+		"CVSS:2.0/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H",
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/C:H/I:H/A:H",
+		"CVSS:3.1/AV:N/AC:L/PR:X/UI:N/S:U/C:H/I:H/A:H",
+		"CVSS:3.1/AV:Q/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H",
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H",
+		"CVSS:3.1/AV:N/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H",
+	}
+
+	for _, vector := range tests {
+		if score, err := cvss3BaseScore(vector); err == nil {
+			t.Errorf("cvss3BaseScore(%q) = %v, want an error", vector, score)
+		}
+	}
+}
+
+func TestCVSS2BaseScore(t *testing.T) {
+	tests := []struct {
+		vector string
+		want   float64
+	}{
+		{"AV:N/AC:L/Au:N/C:C/I:C/A:C", 10.0},
+		{"AV:N/AC:L/Au:N/C:P/I:P/A:P", 7.5},
+		{"AV:N/AC:L/Au:N/C:P/I:N/A:N", 5.0},
+		{"AV:N/AC:M/Au:N/C:N/I:P/A:N", 4.3},
+		{"AV:L/AC:L/Au:N/C:C/I:C/A:C", 7.2},
+		{"AV:N/AC:L/Au:N/C:N/I:N/A:N", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.vector, func(t *testing.T) {
+			got, err := cvss2BaseScore(tt.vector)
+			if err != nil {
+				t.Fatalf("cvss2BaseScore() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("cvss2BaseScore() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := cvss2BaseScore("AV:N/AC:L/Au:N/C:P/I:P"); err == nil {
+		t.Errorf("cvss2BaseScore() of an incomplete vector did not fail")
+	}
+}
+
+func TestCVSS4Score(t *testing.T) {
+	tests := []struct {
+		vector string
+		want   float64
+	}{
+		{"CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:H/VA:H/SC:N/SI:N/SA:N", 9.3},
+		{"CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:N/VI:N/VA:N/SC:N/SI:N/SA:N", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.vector, func(t *testing.T) {
+			got, err := cvss4Score(tt.vector)
+			if err != nil {
+				t.Fatalf("cvss4Score() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("cvss4Score() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := cvss4Score("CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"); err == nil {
+		t.Errorf("cvss4Score() of a v3 vector did not fail")
+	}
+}
+
+func TestCVSSSeverity(t *testing.T) {
+	tests := []struct {
+		score float64
+		v3    string
+		v2    string
+	}{
+		{10, SeverityCritical, SeverityHigh},
+		{9.0, SeverityCritical, SeverityHigh},
+		{8.9, SeverityHigh, SeverityHigh},
+		{7.0, SeverityHigh, SeverityHigh},
+		{6.9, SeverityMedium, SeverityMedium},
+		{4.0, SeverityMedium, SeverityMedium},
+		{3.9, SeverityLow, SeverityLow},
+		{0.1, SeverityLow, SeverityLow},
+		{0, SeverityUnknown, SeverityUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := cvssSeverity(tt.score); got != tt.v3 {
+			t.Errorf("cvssSeverity(%v) = %s, want %s", tt.score, got, tt.v3)
+		}
+		if got := cvss2Severity(tt.score); got != tt.v2 {
+			t.Errorf("cvss2Severity(%v) = %s, want %s", tt.score, got, tt.v2)
+		}
+	}
+}
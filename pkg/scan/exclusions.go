@@ -0,0 +1,118 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/maven"
+)
+
+// findExcludedDependencies reads the exclusions of the direct dependencies
+// of an effective POM and returns the dependencies of their POMs they
+// exclude, with the versions managed by the project. Exclusions further down
+// the tree are applied by Maven but not listed.
+func findExcludedDependencies(ctx context.Context, effectivePomPath string) ([]maven.ExcludedDependency, error) {
+	data, err := os.ReadFile(effectivePomPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read effective POM: %v", err)
+	}
+	project, err := maven.ParsePOM(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", effectivePomPath, err)
+	}
+
+	resolver := maven.NewPOMResolver(ctx)
+	managed := project.Managed()
+	var excluded []maven.ExcludedDependency
+	for _, d := range maven.ApplyManagement(project.Dependencies, managed) {
+		if len(d.Exclusions) == 0 || d.Version == "" || d.Scope == "test" {
+			continue
+		}
+		dependency, err := resolver.Fetch(d.GroupID, d.ArtifactID, d.Version)
+		if err != nil {
+			runenv.Logger.Warnf("Skipping the exclusions of %s: %v", d.Coordinates(), err)
+			continue
+		}
+		exclusions := maven.InheritExclusions(d, nil)
+		for _, child := range maven.ApplyManagement(dependency.Dependencies, dependency.Managed()) {
+			scope := child.ScopeOrCompile()
+			if child.Optional == "true" || (scope != "compile" && scope != "runtime") {
+				continue
+			}
+			e, ok := maven.ExcludedBy(exclusions, child)
+			if !ok {
+				continue
+			}
+			if m, ok := managed[child.ManagementKey()]; ok && m.Version != "" {
+				child.Version = m.Version
+			}
+			excluded = append(excluded, maven.NewExcludedDependency(child, e, d.Coordinates()))
+		}
+	}
+	return excluded, nil
+}
+
+// CheckExcludedDependencies looks up the vulnerabilities of the versions the
+// excluded dependencies of a Maven module would have had. The dependencies
+// are written by the native resolver, or read from the effective POM after
+// Maven. Vulnerable ones get a note in the reports; they are not findings, as
+// the artifacts are not on the classpath.
+func CheckExcludedDependencies(ctx context.Context, sbomPath string) error {
+	s := runenv.SettingsOf(ctx)
+	path := maven.ExcludedPath(sbomPath)
+	excluded, err := maven.ReadExcludedReport(path)
+	if err != nil {
+		effectivePomPath := filepath.Join(filepath.Dir(sbomPath), "effective-pom.xml")
+		if _, statErr := os.Stat(effectivePomPath); statErr != nil || s.Offline {
+			return nil
+		}
+		if excluded, err = findExcludedDependencies(ctx, effectivePomPath); err != nil {
+			return err
+		}
+	}
+	if len(excluded) == 0 || s.Offline {
+		return maven.WriteExcludedReport(path, excluded)
+	}
+
+	var purls []string
+	var queried []int
+	for i, e := range excluded {
+		if e.PURL != "" {
+			purls = append(purls, e.PURL)
+			queried = append(queried, i)
+		}
+	}
+	matches, err := queryOSVBatch(ctx, purls)
+	if err != nil {
+		runenv.Logger.Warnf("Could not look up the vulnerabilities of the excluded dependencies: %v", err)
+		return maven.WriteExcludedReport(path, excluded)
+	}
+	vulnerable := 0
+	for j, i := range queried {
+		excluded[i].Vulnerabilities = matches[j]
+		if len(matches[j]) > 0 {
+			vulnerable++
+		}
+	}
+	if err := maven.WriteExcludedReport(path, excluded); err != nil {
+		return err
+	}
+	if vulnerable > 0 {
+		runenv.Logger.Infof("%d excluded dependencies would have been vulnerable, see %s", vulnerable, path)
+	}
+	return nil
+}
+
+// VulnerableExclusions returns the excluded dependencies with vulnerabilities
+func VulnerableExclusions(excluded []maven.ExcludedDependency) []maven.ExcludedDependency {
+	var vulnerable []maven.ExcludedDependency
+	for _, e := range excluded {
+		if len(e.Vulnerabilities) > 0 {
+			vulnerable = append(vulnerable, e)
+		}
+	}
+	return vulnerable
+}
@@ -0,0 +1,161 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+// EPSS API of FIRST, returns the probability of exploitation within the next
+// 30 days per CVE
+const epssAPIURL = "https://api.first.org/data/v1/epss"
+
+// CISA Known Exploited Vulnerabilities catalog
+const kevCatalogURL = "https://www.cisa.gov/sites/default/files/feeds/known_exploited_vulnerabilities.json"
+
+// Number of CVEs per EPSS request, the API returns 100 entries per page
+const epssBatchSize = 100
+
+// EnrichFindingsWithExploits adds the EPSS probability and the CISA KEV date
+// to the findings with a CVE ID. The KEV catalog is cached like scan results;
+// the enrichment is best effort, a failed download only logs a warning.
+func EnrichFindingsWithExploits(ctx context.Context, findingsPath string) error {
+	findings, err := ReadFindings(findingsPath)
+	if err != nil {
+		return err
+	}
+
+	var cves []string
+	seen := make(map[string]bool)
+	for _, f := range findings {
+		for _, id := range f.IDs() {
+			if cveIDPattern.MatchString(id) && !seen[id] {
+				seen[id] = true
+				cves = append(cves, id)
+			}
+		}
+	}
+	if len(cves) == 0 {
+		return nil
+	}
+
+	kev, err := fetchKEVCatalog(ctx)
+	if err != nil {
+		runenv.Logger.Warnf("Could not download the CISA KEV catalog: %v", err)
+	}
+	epss, err := fetchEPSS(ctx, cves)
+	if err != nil {
+		runenv.Logger.Warnf("Could not look up the EPSS scores: %v", err)
+	}
+
+	enriched := 0
+	for i, f := range findings {
+		changed := false
+		for _, id := range f.IDs() {
+			if score, ok := epss[id]; ok && score > f.EPSS {
+				findings[i].EPSS = score
+				changed = true
+			}
+			if added, ok := kev[id]; ok && findings[i].KEV == "" {
+				findings[i].KEV = added
+				changed = true
+			}
+		}
+		if changed {
+			enriched++
+		}
+	}
+	if enriched == 0 {
+		return nil
+	}
+	if err := writeFindings(findingsPath, findings); err != nil {
+		return err
+	}
+	runenv.Logger.Infof("Enriched %d findings with EPSS scores and the CISA KEV catalog", enriched)
+	return nil
+}
+
+// fetchEPSS returns the EPSS probability of the CVEs that have one
+func fetchEPSS(ctx context.Context, cves []string) (map[string]float64, error) {
+	scores := make(map[string]float64)
+	for start := 0; start < len(cves); start += epssBatchSize {
+		batch := cves[start:min(start+epssBatchSize, len(cves))]
+		data, err := runenv.Fetch(ctx, "EPSS request", func() (*http.Request, error) {
+			return http.NewRequestWithContext(ctx, http.MethodGet, epssAPIURL+"?cve="+url.QueryEscape(strings.Join(batch, ",")), nil)
+		})
+		if err != nil {
+			return scores, err
+		}
+		var response struct {
+			Data []struct {
+				CVE  string `json:"cve"`
+				EPSS string `json:"epss"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(data, &response); err != nil {
+			return scores, fmt.Errorf("failed to decode EPSS response: %v", err)
+		}
+		for _, entry := range response.Data {
+			if score, err := strconv.ParseFloat(entry.EPSS, 64); err == nil {
+				scores[entry.CVE] = score
+			}
+		}
+	}
+	return scores, nil
+}
+
+// fetchKEVCatalog returns the date every CVE of the CISA KEV catalog was added
+func fetchKEVCatalog(ctx context.Context) (map[string]string, error) {
+	s := runenv.SettingsOf(ctx)
+	entry := filepath.Join(s.CacheDir("kev"), "known_exploited_vulnerabilities.json")
+	if _, fresh := s.CacheEntryFresh(entry); fresh {
+		if data, err := os.ReadFile(entry); err == nil {
+			return decodeKEVCatalog(data)
+		}
+	}
+
+	data, err := runenv.Fetch(ctx, "CISA KEV catalog download", func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, kevCatalogURL, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	catalog, err := decodeKEVCatalog(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.ResultCacheTTL > 0 {
+		if err := os.MkdirAll(filepath.Dir(entry), 0755); err != nil {
+			runenv.Logger.Warnf("Failed to cache the CISA KEV catalog: %v", err)
+		} else if err := os.WriteFile(entry, data, 0644); err != nil {
+			runenv.Logger.Warnf("Failed to cache the CISA KEV catalog: %v", err)
+		}
+	}
+	return catalog, nil
+}
+
+func decodeKEVCatalog(data []byte) (map[string]string, error) {
+	var catalog struct {
+		Vulnerabilities []struct {
+			CVEID     string `json:"cveID"`
+			DateAdded string `json:"dateAdded"`
+		} `json:"vulnerabilities"`
+	}
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("failed to decode CISA KEV catalog: %v", err)
+	}
+	added := make(map[string]string, len(catalog.Vulnerabilities))
+	for _, v := range catalog.Vulnerabilities {
+		added[v.CVEID] = v.DateAdded
+	}
+	return added, nil
+}
@@ -0,0 +1,424 @@
+package scan
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/maven"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+// Package databases of the operating system, relative to the scanned root
+const (
+	dpkgStatusPath   = "var/lib/dpkg/status"
+	dpkgStatusDir    = "var/lib/dpkg/status.d" // distroless images
+	apkInstalledPath = "lib/apk/db/installed"
+	rpmDatabaseDir   = "var/lib/rpm"
+)
+
+// Directories of a root filesystem that hold no packages
+var skippedRootDirs = map[string]bool{"proc": true, "sys": true, "dev": true}
+
+// ScanFilesystem walks dir for Java archives, Python wheels and installed
+// distributions, node_modules packages and the package databases of the
+// operating system. Symbolic links are not followed; the file at skip, the
+// SBOM being written, is ignored.
+func ScanFilesystem(ctx context.Context, dir, skip string) ([]sbom.Component, error) {
+	distro := readDistro(dir)
+	skipAbs, _ := filepath.Abs(skip)
+
+	var components []sbom.Component
+	counts := make(map[string]int)
+	add := func(kind string, found []sbom.Component) {
+		components = append(components, found...)
+		counts[kind] += len(found)
+	}
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable directories of a root filesystem are common
+			runenv.Logger.Warnf("Skipping %s: %v", p, err)
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if d.Name() == ".git" || skippedRootDirs[rel] {
+				return filepath.SkipDir
+			}
+			if rel == rpmDatabaseDir {
+				found, err := readRPMDatabase(ctx, p, distro)
+				if err != nil {
+					runenv.Logger.Warnf("Skipping the RPM database: %v", err)
+				}
+				add("OS", found)
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if abs, _ := filepath.Abs(p); abs == skipAbs {
+			return nil
+		}
+
+		var found []sbom.Component
+		kind := ""
+		name := d.Name()
+		parent := path.Base(path.Dir(rel))
+		switch {
+		case rel == dpkgStatusPath || (path.Dir(rel) == dpkgStatusDir && !strings.HasSuffix(name, ".md5sums")):
+			kind = "OS"
+			found, err = readDpkgStatus(p, distro)
+		case rel == apkInstalledPath:
+			kind = "OS"
+			found, err = readApkInstalled(p, distro)
+		case sbom.IsArchive(name):
+			kind = "Java"
+			found, err = maven.ScanArchive(p)
+		case strings.HasSuffix(name, ".whl"):
+			kind = "Python"
+			found, err = readWheel(p)
+		case name == "METADATA" && strings.HasSuffix(parent, ".dist-info"), name == "PKG-INFO" && strings.HasSuffix(parent, ".egg-info"):
+			kind = "Python"
+			found, err = readPythonMetadataFile(p)
+		case name == "package.json" && inNodeModules(rel):
+			kind = "npm"
+			found, err = readNodePackage(p)
+		default:
+			return nil
+		}
+		if err != nil {
+			runenv.Logger.Warnf("Skipping %s: %v", p, err)
+			return nil
+		}
+		add(kind, found)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %v", dir, err)
+	}
+
+	for _, kind := range []string{"Java", "Python", "npm", "OS"} {
+		if counts[kind] > 0 {
+			runenv.Logger.Infof("Found %d %s packages", counts[kind], kind)
+		}
+	}
+	if len(components) == 0 {
+		runenv.Logger.Warnf("No packages found in %s", dir)
+	}
+	return components, nil
+}
+
+// readDistro returns the distro qualifier of the OS packages, e.g. debian-12,
+// from the os-release file of the scanned root. It is empty for directories
+// that are not a root filesystem.
+func readDistro(dir string) string {
+	for _, name := range []string{"etc/os-release", "usr/lib/os-release"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		fields := make(map[string]string)
+		for _, line := range strings.Split(string(data), "\n") {
+			if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+				fields[key] = strings.Trim(value, `"'`)
+			}
+		}
+		if fields["ID"] == "" {
+			return ""
+		}
+		if fields["VERSION_ID"] == "" {
+			return fields["ID"]
+		}
+		return fields["ID"] + "-" + fields["VERSION_ID"]
+	}
+	return ""
+}
+
+// osPackageQualifiers encodes the qualifiers of an OS package, empty values
+// are left out
+func osPackageQualifiers(arch, distro, upstream string) string {
+	q := url.Values{}
+	for key, value := range map[string]string{"arch": arch, "distro": distro, "upstream": upstream} {
+		if value != "" {
+			q.Set(key, value)
+		}
+	}
+	return q.Encode()
+}
+
+// osNamespace returns the purl namespace of an OS package: the distro ID, or
+// the default distro of the package format
+func osNamespace(distro, fallback string) string {
+	if id, _, _ := strings.Cut(distro, "-"); id != "" {
+		return id
+	}
+	return fallback
+}
+
+// readControlParagraphs splits a dpkg status or apk installed file into its
+// paragraphs of key-value fields. Continuation lines are dropped.
+func readControlParagraphs(p string) ([]map[string]string, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var paragraphs []map[string]string
+	fields := make(map[string]string)
+	lines := bufio.NewScanner(bytes.NewReader(data))
+	lines.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lines.Scan() {
+		line := strings.TrimRight(lines.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			if len(fields) > 0 {
+				paragraphs = append(paragraphs, fields)
+				fields = make(map[string]string)
+			}
+			continue
+		}
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			fields[key] = strings.TrimSpace(value)
+		}
+	}
+	if len(fields) > 0 {
+		paragraphs = append(paragraphs, fields)
+	}
+	return paragraphs, lines.Err()
+}
+
+// readDpkgStatus returns the installed packages of a dpkg status file
+func readDpkgStatus(p, distro string) ([]sbom.Component, error) {
+	paragraphs, err := readControlParagraphs(p)
+	if err != nil {
+		return nil, err
+	}
+	var components []sbom.Component
+	for _, f := range paragraphs {
+		// Removed packages keep an entry until they are purged
+		if status := f["Status"]; status != "" && !strings.HasSuffix(status, " installed") {
+			continue
+		}
+		if f["Package"] == "" || f["Version"] == "" {
+			continue
+		}
+		// Source: openssl (3.0.11-1~deb12u2)
+		source, _, _ := strings.Cut(f["Source"], " ")
+		components = append(components, sbom.Component{
+			Type:       "deb",
+			Namespace:  osNamespace(distro, "debian"),
+			Name:       f["Package"],
+			Version:    f["Version"],
+			Qualifiers: osPackageQualifiers(f["Architecture"], distro, source),
+		})
+	}
+	return components, nil
+}
+
+// readApkInstalled returns the packages of the Alpine package database
+func readApkInstalled(p, distro string) ([]sbom.Component, error) {
+	paragraphs, err := readControlParagraphs(p)
+	if err != nil {
+		return nil, err
+	}
+	var components []sbom.Component
+	for _, f := range paragraphs {
+		if f["P"] == "" || f["V"] == "" {
+			continue
+		}
+		c := sbom.Component{
+			Type:       "apk",
+			Namespace:  osNamespace(distro, "alpine"),
+			Name:       f["P"],
+			Version:    f["V"],
+			Qualifiers: osPackageQualifiers(f["A"], distro, f["o"]),
+		}
+		if f["L"] != "" {
+			c.Licenses = []string{f["L"]}
+		}
+		components = append(components, c)
+	}
+	return components, nil
+}
+
+// readRPMDatabase lists the packages of an RPM database with the rpm command,
+// whose database formats (Berkeley DB, NDB, SQLite) are not read directly
+func readRPMDatabase(ctx context.Context, dbDir, distro string) ([]sbom.Component, error) {
+	if _, err := exec.LookPath("rpm"); err != nil {
+		return nil, fmt.Errorf("rpm is not installed")
+	}
+	abs, err := filepath.Abs(dbDir)
+	if err != nil {
+		return nil, err
+	}
+	cmd := runenv.Command(ctx, "rpm", "--dbpath", abs, "-qa", "--qf", `%{NAME}\t%{EPOCHNUM}\t%{VERSION}-%{RELEASE}\t%{ARCH}\t%{LICENSE}\n`)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("rpm -qa failed: %v", err)
+	}
+	var components []sbom.Component
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 5 || fields[0] == "gpg-pubkey" {
+			continue
+		}
+		q := url.Values{}
+		if fields[3] != "" && fields[3] != "(none)" {
+			q.Set("arch", fields[3])
+		}
+		if distro != "" {
+			q.Set("distro", distro)
+		}
+		if fields[1] != "0" {
+			q.Set("epoch", fields[1])
+		}
+		c := sbom.Component{Type: "rpm", Namespace: osNamespace(distro, "redhat"), Name: fields[0], Version: fields[2], Qualifiers: q.Encode()}
+		if fields[4] != "" && fields[4] != "(none)" {
+			c.Licenses = []string{fields[4]}
+		}
+		components = append(components, c)
+	}
+	return components, nil
+}
+
+// inNodeModules reports whether a package.json is the manifest of a package
+// installed in node_modules, e.g. node_modules/@scope/name/package.json
+func inNodeModules(rel string) bool {
+	dir := path.Dir(path.Dir(rel))
+	if strings.HasPrefix(path.Base(dir), "@") {
+		dir = path.Dir(dir)
+	}
+	return path.Base(dir) == "node_modules"
+}
+
+// readNodePackage returns the package of an installed package.json
+func readNodePackage(p string) ([]sbom.Component, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	var manifest struct {
+		Name     string          `json:"name"`
+		Version  string          `json:"version"`
+		License  json.RawMessage `json:"license"`
+		Licenses []struct {
+			Type string `json:"type"`
+		} `json:"licenses"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse package.json: %v", err)
+	}
+	if manifest.Name == "" || manifest.Version == "" {
+		return nil, nil
+	}
+	c := sbom.NodeComponent(manifest.Name, manifest.Version)
+	// "license": "MIT", or the deprecated {"type": "MIT"} and "licenses" list
+	var license string
+	var object struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(manifest.License, &license) == nil && license != "" {
+		c.Licenses = []string{license}
+	} else if json.Unmarshal(manifest.License, &object) == nil && object.Type != "" {
+		c.Licenses = []string{object.Type}
+	}
+	for _, l := range manifest.Licenses {
+		if l.Type != "" {
+			c.Licenses = append(c.Licenses, l.Type)
+		}
+	}
+	return []sbom.Component{c}, nil
+}
+
+// readWheel returns the distribution of a wheel from its dist-info METADATA
+func readWheel(p string) ([]sbom.Component, error) {
+	reader, err := zip.OpenReader(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open wheel: %v", err)
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		dir, name := path.Split(file.Name)
+		if name != "METADATA" || strings.Count(dir, "/") != 1 || !strings.HasSuffix(dir, ".dist-info/") {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return readPythonMetadata(rc)
+	}
+	return nil, fmt.Errorf("no dist-info METADATA in wheel")
+}
+
+// readPythonMetadataFile returns the distribution of an installed dist-info or
+// egg-info directory
+func readPythonMetadataFile(p string) ([]sbom.Component, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readPythonMetadata(f)
+}
+
+// readPythonMetadata parses the headers of a METADATA or PKG-INFO file
+func readPythonMetadata(r io.Reader) ([]sbom.Component, error) {
+	fields := make(map[string]string)
+	lines := bufio.NewScanner(r)
+	lines.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lines.Scan() {
+		line := strings.TrimRight(lines.Text(), "\r")
+		// The headers end at the first empty line, the description follows
+		if line == "" {
+			break
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok && !strings.HasPrefix(line, " ") {
+			if _, seen := fields[key]; !seen {
+				fields[key] = strings.TrimSpace(value)
+			}
+		}
+	}
+	if err := lines.Err(); err != nil {
+		return nil, err
+	}
+	if fields["Name"] == "" || fields["Version"] == "" {
+		return nil, nil
+	}
+	c := sbom.Component{Type: "pypi", Name: sbom.NormalizePythonName(fields["Name"]), Version: fields["Version"]}
+	if license := fields["License-Expression"]; license != "" {
+		c.Licenses = []string{license}
+	} else if license := fields["License"]; license != "" && license != "UNKNOWN" && len(license) < 100 {
+		c.Licenses = []string{license}
+	}
+	return []sbom.Component{c}, nil
+}
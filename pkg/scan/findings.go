@@ -0,0 +1,625 @@
+package scan
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/maven"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+// Severity levels ordered from most to least severe
+const (
+	SeverityCritical = "CRITICAL"
+	SeverityHigh     = "HIGH"
+	SeverityMedium   = "MEDIUM"
+	SeverityLow      = "LOW"
+	SeverityUnknown  = "UNKNOWN"
+)
+
+var SeverityOrder = []string{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityUnknown}
+
+// SeverityRank returns a higher number for more severe levels
+func SeverityRank(severity string) int {
+	for i, s := range SeverityOrder {
+		if s == severity {
+			return len(SeverityOrder) - i
+		}
+	}
+	return 0
+}
+
+// normalizeSeverity maps advisory severity labels onto the levels above
+func normalizeSeverity(label string) string {
+	switch strings.ToUpper(strings.TrimSpace(label)) {
+	case "CRITICAL":
+		return SeverityCritical
+	case "HIGH":
+		return SeverityHigh
+	case "MEDIUM", "MODERATE":
+		return SeverityMedium
+	case "LOW":
+		return SeverityLow
+	}
+	return SeverityUnknown
+}
+
+// Finding is a single vulnerability affecting a package, with aliases merged
+type Finding struct {
+	ID            string       `json:"id"`
+	Aliases       []string     `json:"aliases,omitempty"`
+	Summary       string       `json:"summary,omitempty"`
+	Severity      string       `json:"severity"`
+	Score         float64      `json:"score,omitempty"`       // highest CVSS base score, 0 when unknown
+	CVSSVector    string       `json:"cvss_vector,omitempty"` // CVSS v3 vector of the NVD
+	Package       string       `json:"package"`
+	Version       string       `json:"version"`
+	Ecosystem     string       `json:"ecosystem,omitempty"`
+	FixedVersions []string     `json:"fixed_versions,omitempty"`
+	URL           string       `json:"url,omitempty"`
+	References    []string     `json:"references,omitempty"`
+	CWEs          []string     `json:"cwes,omitempty"`
+	Withdrawn     string       `json:"withdrawn,omitempty"` // time the GitHub advisory was withdrawn
+	CPEs          []string     `json:"cpes,omitempty"`      // vulnerable configurations of the NVD
+	EPSS          float64      `json:"epss,omitempty"`      // probability of exploitation in the next 30 days
+	KEV           string       `json:"kev,omitempty"`       // date the CVE was added to the CISA KEV catalog
+	Source        string       `json:"source,omitempty"`
+	Scanners      []string     `json:"scanners,omitempty"` // backends that reported it, when several ran
+	Remediation   *Remediation `json:"remediation,omitempty"`
+	// Dependencies from the direct dependency down to the package, as name@version
+	DependencyPath []string `json:"dependency_path,omitempty"`
+	// reachable or unreachable from the project's bytecode, with --reachability
+	Reachability string `json:"reachability,omitempty"`
+	// Where the POMs set the version of a Maven package
+	Provenance *maven.VersionProvenance `json:"provenance,omitempty"`
+
+	// Set when the finding is suppressed by an ignore rule
+	SuppressedBy       string `json:"suppressed_by,omitempty"`
+	SuppressionExpires string `json:"suppression_expires,omitempty"`
+}
+
+// IDs returns the primary ID followed by the aliases
+func (f Finding) IDs() []string {
+	return append([]string{f.ID}, f.Aliases...)
+}
+
+// Malicious reports whether the package version is known to be malicious,
+// i.e. the finding is an OSV malicious packages advisory (MAL-)
+func (f Finding) Malicious() bool {
+	return slices.ContainsFunc(f.IDs(), func(id string) bool { return strings.HasPrefix(id, "MAL-") })
+}
+
+// FindingList is the active or suppressed findings of a report, streamed
+// again on every iteration and counted up front
+type FindingList struct {
+	Source FindingSource
+	Count  int
+}
+
+// Len returns the number of findings, for the templates
+func (l FindingList) Len() int {
+	return l.Count
+}
+
+// Each calls fn with one finding after the other
+func (l FindingList) Each(fn func(Finding) error) error {
+	if l.Source == nil {
+		return nil
+	}
+	return l.Source(fn)
+}
+
+// vulnerabilitySeverity returns the severity and CVSS base score of a single
+// OSV entry. CVSS v3 vectors take precedence, then CVSS v4 vectors, which use
+// the same severity bands, then the advisory's own label. CVSS v2 is only used
+// when nothing else is available.
+func vulnerabilitySeverity(v osvVulnerability) (string, float64) {
+	for _, s := range v.Severity {
+		if s.Type != "CVSS_V3" {
+			continue
+		}
+		if score, err := cvss3BaseScore(s.Score); err == nil {
+			return cvssSeverity(score), score
+		}
+	}
+	for _, s := range v.Severity {
+		if s.Type != "CVSS_V4" {
+			continue
+		}
+		if score, err := cvss4Score(s.Score); err == nil {
+			return cvssSeverity(score), score
+		}
+	}
+
+	if label, ok := v.DatabaseSpecific["severity"].(string); ok {
+		if severity := normalizeSeverity(label); severity != SeverityUnknown {
+			return severity, 0
+		}
+	}
+
+	for _, s := range v.Severity {
+		if s.Type != "CVSS_V2" {
+			continue
+		}
+		if score, err := cvss2BaseScore(s.Score); err == nil {
+			return cvss2Severity(score), score
+		}
+	}
+
+	return SeverityUnknown, 0
+}
+
+// findingsFromReport turns an OSV report into findings, one per alias group
+func findingsFromReport(report osvReport) []Finding {
+	var findings []Finding
+
+	for _, result := range report.Results {
+		for _, pkg := range result.Packages {
+			byID := make(map[string]osvVulnerability)
+			for _, v := range pkg.Vulnerabilities {
+				byID[v.ID] = v
+			}
+
+			groups := pkg.Groups
+			if len(groups) == 0 {
+				groups = groupVulnerabilities(pkg.Vulnerabilities)
+			}
+
+			for _, group := range groups {
+				findings = append(findings, newFinding(pkg.Package, group, byID, result.Source.Path))
+			}
+		}
+	}
+
+	SortFindings(findings)
+	return findings
+}
+
+func newFinding(pkg sbom.OSVPackage, group osvGroup, byID map[string]osvVulnerability, source string) Finding {
+	f := Finding{
+		ID:        primaryID(group.IDs),
+		Severity:  SeverityUnknown,
+		Package:   pkg.Name,
+		Version:   pkg.Version,
+		Ecosystem: pkg.Ecosystem,
+		Source:    source,
+	}
+
+	aliases := make(map[string]bool)
+	fixed := make(map[string]bool)
+	references := make(map[string]bool)
+
+	for _, id := range append(append([]string{}, group.IDs...), group.Aliases...) {
+		if id != f.ID {
+			aliases[id] = true
+		}
+
+		v, ok := byID[id]
+		if !ok {
+			continue
+		}
+		if f.Summary == "" {
+			f.Summary = v.Summary
+		}
+		severity, score := vulnerabilitySeverity(v)
+		if SeverityRank(severity) > SeverityRank(f.Severity) {
+			f.Severity = severity
+		}
+		if score > f.Score {
+			f.Score = score
+		}
+		for _, affected := range v.Affected {
+			if affected.Package.Name != "" && affected.Package.Name != pkg.Name {
+				continue
+			}
+			for _, r := range affected.Ranges {
+				for _, e := range r.Events {
+					if e.Fixed != "" {
+						fixed[e.Fixed] = true
+					}
+				}
+			}
+		}
+		for _, ref := range v.References {
+			references[ref.URL] = true
+		}
+	}
+
+	f.Aliases = sortedKeys(aliases)
+	f.FixedVersions = sortedKeys(fixed)
+	f.References = sortedKeys(references)
+	f.URL = "https://osv.dev/vulnerability/" + f.ID
+	// Malicious package advisories rarely carry a severity
+	if f.Malicious() {
+		f.Severity = SeverityCritical
+	}
+	return f
+}
+
+// primaryID prefers GHSA and CVE identifiers over ecosystem specific ones
+func primaryID(ids []string) string {
+	if len(ids) == 0 {
+		return ""
+	}
+	for _, prefix := range []string{"GHSA-", "CVE-"} {
+		for _, id := range ids {
+			if strings.HasPrefix(id, prefix) {
+				return id
+			}
+		}
+	}
+	return ids[0]
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// mergeFindings combines findings for the same package version whose IDs
+// overlap, e.g. a GHSA advisory and the CVE it aliases
+func mergeFindings(findings []Finding) []Finding {
+	var merged []Finding
+
+	for _, f := range findings {
+		var rest []Finding
+		for _, m := range merged {
+			if m.Package == f.Package && m.Version == f.Version && SharesID(m, f) {
+				f = combineFindings(m, f)
+			} else {
+				rest = append(rest, m)
+			}
+		}
+		merged = append(rest, f)
+	}
+
+	return merged
+}
+
+func SharesID(a, b Finding) bool {
+	for _, id := range a.IDs() {
+		if findingHasID(b, id) {
+			return true
+		}
+	}
+	return false
+}
+
+func combineFindings(a, b Finding) Finding {
+	ids := make(map[string]bool)
+	fixed := make(map[string]bool)
+	references := make(map[string]bool)
+	scanners := make(map[string]bool)
+	cwes := make(map[string]bool)
+	cpes := make(map[string]bool)
+	for _, f := range []Finding{a, b} {
+		for _, id := range f.IDs() {
+			ids[id] = true
+		}
+		for _, s := range f.Scanners {
+			scanners[s] = true
+		}
+		for _, v := range f.FixedVersions {
+			fixed[v] = true
+		}
+		for _, r := range f.References {
+			references[r] = true
+		}
+		for _, cwe := range f.CWEs {
+			cwes[cwe] = true
+		}
+		for _, cpe := range f.CPEs {
+			cpes[cpe] = true
+		}
+	}
+
+	if SeverityRank(b.Severity) > SeverityRank(a.Severity) {
+		a.Severity = b.Severity
+	}
+	if b.Score > a.Score {
+		a.Score = b.Score
+	}
+	if a.Summary == "" {
+		a.Summary = b.Summary
+	}
+	if a.Ecosystem == "" {
+		a.Ecosystem = b.Ecosystem
+	}
+	if a.Withdrawn == "" {
+		a.Withdrawn = b.Withdrawn
+	}
+	if a.CVSSVector == "" {
+		a.CVSSVector = b.CVSSVector
+	}
+	if b.EPSS > a.EPSS {
+		a.EPSS = b.EPSS
+	}
+	if a.KEV == "" {
+		a.KEV = b.KEV
+	}
+	if a.Reachability != reachable && b.Reachability != "" {
+		a.Reachability = b.Reachability
+	}
+
+	all := sortedKeys(ids)
+	a.ID = primaryID(all)
+	a.Aliases = nil
+	for _, id := range all {
+		if id != a.ID {
+			a.Aliases = append(a.Aliases, id)
+		}
+	}
+	a.FixedVersions = sortedKeys(fixed)
+	a.References = sortedKeys(references)
+	if len(cwes) > 0 {
+		a.CWEs = sortedKeys(cwes)
+	}
+	if len(cpes) > 0 {
+		a.CPEs = sortedKeys(cpes)
+	}
+	if len(scanners) > 0 {
+		a.Scanners = sortedKeys(scanners)
+	}
+	return a
+}
+
+// writeFindings stores the normalized findings of a scan as JSON, most
+// severe first. The aggregated report merges the sorted files of the modules.
+func writeFindings(path string, findings []Finding) error {
+	SortFindings(findings)
+	err := runenv.WriteBuffered(path, func(w *bufio.Writer) error {
+		return runenv.EncodeJSONArray(w, "", findings)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write findings: %v", err)
+	}
+	return nil
+}
+
+// ReadFindings reads the findings stored at path into memory, for the steps
+// that rewrite the findings file of a single module
+func ReadFindings(path string) ([]Finding, error) {
+	return FindingsFrom(path).Collect()
+}
+
+// FindingSource calls fn with one finding after the other until fn fails.
+// The reports and counts of a run iterate over the findings this way, so that
+// they are never held in memory as a whole.
+type FindingSource func(fn func(Finding) error) error
+
+// FindingsFrom streams the findings stored at path, a findings file or an
+// aggregated report, decoding them one at a time on every iteration
+func FindingsFrom(path string) FindingSource {
+	return func(fn func(Finding) error) error {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to read findings: %v", err)
+		}
+		defer file.Close()
+
+		// Errors of fn are returned as they are
+		var fnErr error
+		each := func(f Finding) error {
+			fnErr = fn(f)
+			return fnErr
+		}
+		reader := bufio.NewReader(file)
+		decoder := json.NewDecoder(reader)
+		if runenv.JSONObjectAhead(reader) {
+			err = runenv.DecodeJSONObject(decoder, map[string]func(*json.Decoder) error{
+				"findings": func(d *json.Decoder) error { return runenv.DecodeJSONArray(d, each) },
+			})
+		} else {
+			err = runenv.DecodeJSONArray(decoder, each)
+		}
+		if fnErr != nil {
+			return fnErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse findings %s: %v", path, err)
+		}
+		return nil
+	}
+}
+
+// SliceFindings iterates over findings already in memory
+func SliceFindings(findings []Finding) FindingSource {
+	return func(fn func(Finding) error) error {
+		for _, f := range findings {
+			if err := fn(f); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Where passes on the findings keep returns true for
+func (s FindingSource) Where(keep func(Finding) bool) FindingSource {
+	return func(fn func(Finding) error) error {
+		return s(func(f Finding) error {
+			if !keep(f) {
+				return nil
+			}
+			return fn(f)
+		})
+	}
+}
+
+// WithoutSuppressions clears the suppression state of the findings, which is
+// evaluated again with the current rules
+func (s FindingSource) WithoutSuppressions() FindingSource {
+	return func(fn func(Finding) error) error {
+		return s(func(f Finding) error {
+			f.SuppressedBy, f.SuppressionExpires = "", ""
+			return fn(f)
+		})
+	}
+}
+
+// SuppressedBy evaluates the suppression of every finding again with the
+// rules, as ApplySuppressions does
+func (s FindingSource) SuppressedBy(rules []IgnoreRule) FindingSource {
+	now := time.Now()
+	return func(fn func(Finding) error) error {
+		return s.WithoutSuppressions()(func(f Finding) error {
+			suppressFinding(&f, rules, now)
+			return fn(f)
+		})
+	}
+}
+
+// Collect reads all findings into memory
+func (s FindingSource) Collect() ([]Finding, error) {
+	findings := []Finding{}
+	err := s(func(f Finding) error {
+		findings = append(findings, f)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return findings, nil
+}
+
+// ActiveFinding and SuppressedFinding split findings by their suppression
+func ActiveFinding(f Finding) bool { return f.SuppressedBy == "" }
+
+func SuppressedFinding(f Finding) bool { return f.SuppressedBy != "" }
+
+// MergeFindingsFiles streams the findings of the findings files at paths, each
+// sorted by writeFindings, as one sorted list. Only the next finding of
+// every file is held in memory. Missing and unreadable files are skipped.
+func MergeFindingsFiles(paths []string) FindingSource {
+	return func(fn func(Finding) error) error {
+		var readers []*findingsReader
+		defer func() {
+			for _, r := range readers {
+				r.file.Close()
+			}
+		}()
+		for _, path := range paths {
+			r, err := openFindings(path)
+			if err != nil {
+				continue
+			}
+			readers = append(readers, r)
+		}
+
+		for {
+			// Of equal findings, the one of the earlier module comes first
+			next := -1
+			for i, r := range readers {
+				if r.more && (next == -1 || findingLess(r.next, readers[next].next)) {
+					next = i
+				}
+			}
+			if next == -1 {
+				return nil
+			}
+			if err := fn(readers[next].next); err != nil {
+				return err
+			}
+			if err := readers[next].advance(); err != nil {
+				return fmt.Errorf("failed to parse findings %s: %v", readers[next].file.Name(), err)
+			}
+		}
+	}
+}
+
+// findingsReader decodes the findings of a findings file one at a time
+type findingsReader struct {
+	file    *os.File
+	decoder *json.Decoder
+	next    Finding
+	more    bool
+}
+
+// openFindings opens the findings file at path and decodes its first finding
+func openFindings(path string) (*findingsReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &findingsReader{file: file, decoder: json.NewDecoder(bufio.NewReader(file))}
+	token, err := r.decoder.Token()
+	if err == nil && token != json.Delim('[') {
+		err = fmt.Errorf("expected an array, got %v", token)
+	}
+	if err == nil {
+		err = r.advance()
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// advance decodes the next finding, if there is one
+func (r *findingsReader) advance() error {
+	r.next = Finding{}
+	if r.more = r.decoder.More(); !r.more {
+		return nil
+	}
+	return r.decoder.Decode(&r.next)
+}
+
+// SortFindings orders findings by severity, then package and ID
+func SortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findingLess(findings[i], findings[j])
+	})
+}
+
+// findingLess reports whether a is listed before b: the more severe first,
+// then by package and ID
+func findingLess(a, b Finding) bool {
+	if ra, rb := SeverityRank(a.Severity), SeverityRank(b.Severity); ra != rb {
+		return ra > rb
+	}
+	if a.Package != b.Package {
+		return a.Package < b.Package
+	}
+	return a.ID < b.ID
+}
+
+// ParseSeverityThreshold validates the --fail-on flag value
+func ParseSeverityThreshold(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	switch severity := normalizeSeverity(value); severity {
+	case SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow:
+		return severity, nil
+	}
+	return "", fmt.Errorf("invalid severity threshold: %s (expected critical, high, medium or low)", value)
+}
+
+// FindingsAtOrAbove returns the findings whose severity reaches the threshold
+func FindingsAtOrAbove(findings []Finding, threshold string) []Finding {
+	var result []Finding
+	for _, f := range findings {
+		if ReachesSeverity(f, threshold) {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// ReachesSeverity reports whether the severity of the finding is at or above
+// the threshold. Findings of unknown severity never reach a threshold.
+func ReachesSeverity(f Finding, threshold string) bool {
+	return f.Severity != SeverityUnknown && SeverityRank(f.Severity) >= SeverityRank(threshold)
+}
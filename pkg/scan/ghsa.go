@@ -0,0 +1,305 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+// GitHub GraphQL endpoint of the advisory database, GITHUB_GRAPHQL_URL
+// overrides it for GitHub Enterprise
+const ghsaGraphQLURL = "https://api.github.com/graphql"
+
+// Number of advisories looked up per GraphQL request
+const ghsaBatchSize = 50
+
+var (
+	ghsaIDPattern = regexp.MustCompile(`^GHSA(-[23456789cfghjmpqrvwx]{4}){3}$`)
+	cveIDPattern  = regexp.MustCompile(`^CVE-\d{4}-\d{4,}$`)
+)
+
+// GHSAOptions configures the enrichment of findings from the GitHub Security
+// Advisories, disabled without a token
+type GHSAOptions struct {
+	token    string
+	endpoint string
+}
+
+// Enabled reports whether findings are enriched
+func (o GHSAOptions) Enabled() bool {
+	return o.token != ""
+}
+
+// NewGHSAOptions reads the token from GITHUB_TOKEN
+func NewGHSAOptions(ctx context.Context) (GHSAOptions, error) {
+	opts := GHSAOptions{token: strings.TrimSpace(os.Getenv(GitHubTokenEnv)), endpoint: ghsaGraphQLURL}
+	if opts.token == "" {
+		return opts, fmt.Errorf("GitHub advisory enrichment needs a token in %s", GitHubTokenEnv)
+	}
+	if runenv.SettingsOf(ctx).Offline {
+		return opts, fmt.Errorf("GitHub advisory enrichment needs the GitHub API and cannot be combined with --offline")
+	}
+	if env := os.Getenv("GITHUB_GRAPHQL_URL"); env != "" {
+		opts.endpoint = env
+	}
+	return opts, nil
+}
+
+// ghsaAdvisory is the part of a GitHub security advisory added to findings
+type ghsaAdvisory struct {
+	GHSAID      string `json:"ghsaId"`
+	Summary     string `json:"summary"`
+	Permalink   string `json:"permalink"`
+	WithdrawnAt string `json:"withdrawnAt"`
+	Identifiers []struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	} `json:"identifiers"`
+	CWEs struct {
+		Nodes []struct {
+			CWEID string `json:"cweId"`
+		} `json:"nodes"`
+	} `json:"cwes"`
+}
+
+const ghsaAdvisoryFragment = `fragment advisory on SecurityAdvisory {
+  ghsaId summary permalink withdrawnAt
+  identifiers { type value }
+  cwes(first: 20) { nodes { cweId } }
+}`
+
+// EnrichFindingsFromGHSA adds the summaries, CWE IDs and withdrawn state of
+// the GitHub advisories of the findings. Findings are looked up by their GHSA
+// ID, or by CVE when they have none; the advisory IDs become aliases, so
+// findings of different scanners that turn out to be the same advisory are
+// merged. The enrichment is best effort, a failed lookup only logs a warning.
+func EnrichFindingsFromGHSA(ctx context.Context, findingsPath string, opts GHSAOptions) error {
+	findings, err := ReadFindings(findingsPath)
+	if err != nil {
+		return err
+	}
+
+	var keys []string
+	seen := make(map[string]bool)
+	for _, f := range findings {
+		for _, id := range ghsaLookupIDs(f) {
+			if !seen[id] {
+				seen[id] = true
+				keys = append(keys, id)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	advisories := make(map[string][]ghsaAdvisory)
+	for start := 0; start < len(keys); start += ghsaBatchSize {
+		batch := keys[start:min(start+ghsaBatchSize, len(keys))]
+		found, err := queryGHSA(ctx, opts, batch)
+		if err != nil {
+			runenv.Logger.Warnf("Could not look up GitHub advisories: %v", err)
+			return nil
+		}
+		for id, list := range found {
+			advisories[id] = list
+		}
+	}
+
+	enriched, withdrawn := 0, 0
+	for i := range findings {
+		var matched []ghsaAdvisory
+		for _, id := range ghsaLookupIDs(findings[i]) {
+			matched = append(matched, advisories[id]...)
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		findings[i] = applyGHSAAdvisories(findings[i], matched)
+		enriched++
+		if findings[i].Withdrawn != "" {
+			withdrawn++
+		}
+	}
+
+	findings = mergeFindings(findings)
+	SortFindings(findings)
+	if err := writeFindings(findingsPath, findings); err != nil {
+		return err
+	}
+	runenv.Logger.Infof("Enriched %d findings from GitHub Security Advisories (%d withdrawn)", enriched, withdrawn)
+	return nil
+}
+
+// ghsaLookupIDs returns the GHSA IDs of a finding, or its CVE IDs without one
+func ghsaLookupIDs(f Finding) []string {
+	var ghsa, cve []string
+	for _, id := range f.IDs() {
+		switch {
+		case ghsaIDPattern.MatchString(id):
+			ghsa = append(ghsa, id)
+		case cveIDPattern.MatchString(id):
+			cve = append(cve, id)
+		}
+	}
+	if len(ghsa) > 0 {
+		return ghsa
+	}
+	return cve
+}
+
+// queryGHSA looks up advisories by GHSA or CVE ID in one GraphQL request,
+// with an aliased field per ID
+func queryGHSA(ctx context.Context, opts GHSAOptions, ids []string) (map[string][]ghsaAdvisory, error) {
+	var query strings.Builder
+	query.WriteString("query {\n")
+	for i, id := range ids {
+		// The IDs are checked against ghsaIDPattern and cveIDPattern, so
+		// they can be written into the query
+		if ghsaIDPattern.MatchString(id) {
+			fmt.Fprintf(&query, "  q%d: securityAdvisory(ghsaId: %q) { ...advisory }\n", i, id)
+		} else {
+			fmt.Fprintf(&query, "  q%d: securityAdvisories(identifier: {type: CVE, value: %q}, first: 5) { nodes { ...advisory } }\n", i, id)
+		}
+	}
+	query.WriteString("}\n")
+	query.WriteString(ghsaAdvisoryFragment)
+
+	body, err := json.Marshal(map[string]string{"query": query.String()})
+	if err != nil {
+		return nil, err
+	}
+	data, err := runenv.Fetch(ctx, "GitHub advisory request", func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, opts.endpoint, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "bearer "+opts.token)
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Data   map[string]json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode GitHub response: %v", err)
+	}
+	// Unknown GHSA IDs are reported as errors next to the other results
+	if response.Data == nil && len(response.Errors) > 0 {
+		return nil, fmt.Errorf("%s", response.Errors[0].Message)
+	}
+
+	result := make(map[string][]ghsaAdvisory)
+	for i, id := range ids {
+		raw, ok := response.Data[fmt.Sprintf("q%d", i)]
+		if !ok || string(raw) == "null" {
+			continue
+		}
+		if ghsaIDPattern.MatchString(id) {
+			var advisory ghsaAdvisory
+			if err := json.Unmarshal(raw, &advisory); err != nil {
+				return nil, fmt.Errorf("failed to decode GitHub response: %v", err)
+			}
+			result[id] = []ghsaAdvisory{advisory}
+			continue
+		}
+		var connection struct {
+			Nodes []ghsaAdvisory `json:"nodes"`
+		}
+		if err := json.Unmarshal(raw, &connection); err != nil {
+			return nil, fmt.Errorf("failed to decode GitHub response: %v", err)
+		}
+		result[id] = connection.Nodes
+	}
+	return result, nil
+}
+
+// applyGHSAAdvisories adds the advisory details to a finding
+func applyGHSAAdvisories(f Finding, advisories []ghsaAdvisory) Finding {
+	ids := make(map[string]bool)
+	for _, id := range f.IDs() {
+		ids[id] = true
+	}
+	cwes := make(map[string]bool)
+	for _, cwe := range f.CWEs {
+		cwes[cwe] = true
+	}
+	references := make(map[string]bool)
+	for _, reference := range f.References {
+		references[reference] = true
+	}
+
+	for _, advisory := range advisories {
+		ids[advisory.GHSAID] = true
+		for _, identifier := range advisory.Identifiers {
+			if identifier.Type == "GHSA" || identifier.Type == "CVE" {
+				ids[identifier.Value] = true
+			}
+		}
+		for _, node := range advisory.CWEs.Nodes {
+			cwes[node.CWEID] = true
+		}
+		if advisory.Permalink != "" && advisory.Permalink != f.URL {
+			references[advisory.Permalink] = true
+		}
+		if f.Summary == "" {
+			f.Summary = advisory.Summary
+		}
+		if advisory.WithdrawnAt != "" {
+			f.Withdrawn = advisory.WithdrawnAt
+		}
+	}
+
+	f.Aliases = nil
+	for _, id := range sortedKeys(ids) {
+		if id != f.ID {
+			f.Aliases = append(f.Aliases, id)
+		}
+	}
+	f.CWEs = sortedKeys(cwes)
+	f.References = sortedKeys(references)
+	return f
+}
+
+// withdrawnDate shortens the withdrawal timestamp of an advisory to its date
+func withdrawnDate(timestamp string) string {
+	date, _, _ := strings.Cut(timestamp, "T")
+	return date
+}
+
+// CWENumber returns the lowest CWE number of the IDs, 0 without one
+func CWENumber(cwes []string) int {
+	numbers := make([]int, 0, len(cwes))
+	for _, cwe := range cwes {
+		var n int
+		if _, err := fmt.Sscanf(cwe, "CWE-%d", &n); err == nil {
+			numbers = append(numbers, n)
+		}
+	}
+	if len(numbers) == 0 {
+		return 0
+	}
+	sort.Ints(numbers)
+	return numbers[0]
+}
+
+// Environment variables holding the API tokens used by fix --pr
+const (
+	GitHubTokenEnv = "GITHUB_TOKEN"
+	GitLabTokenEnv = "GITLAB_TOKEN"
+)
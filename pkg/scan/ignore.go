@@ -0,0 +1,181 @@
+package scan
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"gopkg.in/yaml.v3"
+)
+
+const ignoreDateLayout = "2006-01-02"
+
+// IgnoreRule suppresses findings by vulnerability ID, package or both
+type IgnoreRule struct {
+	ID      string `yaml:"id,omitempty"`      // CVE, GHSA or OSV ID, aliases match too
+	Package string `yaml:"package,omitempty"` // name or name@version
+	Expires string `yaml:"expires,omitempty"` // YYYY-MM-DD, the rule stops applying after this day
+	Reason  string `yaml:"reason,omitempty"`
+}
+
+// ignoreFile is the format of --ignore-file, the same as the ignore section of the config
+type ignoreFile struct {
+	Ignore []IgnoreRule `yaml:"ignore"`
+}
+
+func (r IgnoreRule) String() string {
+	switch {
+	case r.ID != "" && r.Package != "":
+		return r.ID + " in " + r.Package
+	case r.ID != "":
+		return r.ID
+	}
+	return r.Package
+}
+
+// expired reports whether the rule's expiry date has passed
+func (r IgnoreRule) expired(now time.Time) bool {
+	if r.Expires == "" {
+		return false
+	}
+	expires, err := time.Parse(ignoreDateLayout, r.Expires)
+	if err != nil {
+		return false
+	}
+	return now.After(expires.AddDate(0, 0, 1))
+}
+
+// matches reports whether the rule applies to the finding
+func (r IgnoreRule) matches(f Finding) bool {
+	if r.ID != "" && !findingHasID(f, r.ID) {
+		return false
+	}
+	if r.Package != "" {
+		name, version, hasVersion := strings.Cut(r.Package, "@")
+		// Scoped npm packages start with an @
+		if strings.HasPrefix(r.Package, "@") {
+			name, version, hasVersion = runenv.CutLast(r.Package[1:], "@")
+			name = "@" + name
+		}
+		if name != f.Package || (hasVersion && version != f.Version) {
+			return false
+		}
+	}
+	return r.ID != "" || r.Package != ""
+}
+
+func findingHasID(f Finding, id string) bool {
+	if strings.EqualFold(f.ID, id) {
+		return true
+	}
+	for _, alias := range f.Aliases {
+		if strings.EqualFold(alias, id) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateIgnoreRules checks expiry dates and warns about expired rules
+func ValidateIgnoreRules(rules []IgnoreRule) error {
+	now := time.Now()
+	for _, r := range rules {
+		if r.ID == "" && r.Package == "" {
+			return fmt.Errorf("ignore rule needs an id or a package")
+		}
+		if r.Expires != "" {
+			if _, err := time.Parse(ignoreDateLayout, r.Expires); err != nil {
+				return fmt.Errorf("invalid expiry date for ignore rule %s: %s (expected YYYY-MM-DD)", r, r.Expires)
+			}
+		}
+		if r.expired(now) {
+			runenv.Logger.Warnf("Ignore rule for %s expired on %s", r, r.Expires)
+		}
+	}
+	return nil
+}
+
+// LoadIgnoreFile reads ignore rules from a YAML file
+func LoadIgnoreFile(path string) ([]IgnoreRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ignore file: %v", err)
+	}
+
+	var file ignoreFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse ignore file %s: %v", path, err)
+	}
+	return file.Ignore, nil
+}
+
+// ParseIgnoreFlag turns --ignore=CVE-2021-44228,lodash@4.17.20 into rules
+func ParseIgnoreFlag(value string) []IgnoreRule {
+	var rules []IgnoreRule
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if isVulnerabilityID(entry) {
+			rules = append(rules, IgnoreRule{ID: entry, Reason: "ignored on the command line"})
+		} else {
+			rules = append(rules, IgnoreRule{Package: entry, Reason: "ignored on the command line"})
+		}
+	}
+	return rules
+}
+
+// isVulnerabilityID recognizes advisory identifiers such as CVE-2021-44228 or GHSA-xxxx-xxxx-xxxx
+func isVulnerabilityID(s string) bool {
+	prefix, rest, ok := strings.Cut(s, "-")
+	if !ok || rest == "" || strings.ContainsAny(s, "@:/") {
+		return false
+	}
+	return prefix == strings.ToUpper(prefix)
+}
+
+// ApplySuppressions splits findings into active and suppressed ones.
+// Findings of withdrawn GitHub advisories are suppressed as well.
+func ApplySuppressions(findings []Finding, rules []IgnoreRule) ([]Finding, []Finding) {
+	if len(rules) == 0 && !slices.ContainsFunc(findings, func(f Finding) bool { return f.Withdrawn != "" }) {
+		return findings, nil
+	}
+
+	now := time.Now()
+	var active, suppressed []Finding
+
+	for _, f := range findings {
+		if suppressFinding(&f, rules, now) {
+			suppressed = append(suppressed, f)
+		} else {
+			active = append(active, f)
+		}
+	}
+
+	return active, suppressed
+}
+
+// suppressFinding sets suppressed_by when the finding is withdrawn or matches
+// one of the rules, and reports whether it did
+func suppressFinding(f *Finding, rules []IgnoreRule, now time.Time) bool {
+	if f.Withdrawn != "" {
+		f.SuppressedBy = "advisory withdrawn on " + withdrawnDate(f.Withdrawn)
+		return true
+	}
+	for _, r := range rules {
+		if r.expired(now) || !r.matches(*f) {
+			continue
+		}
+		f.SuppressedBy = r.Reason
+		if f.SuppressedBy == "" {
+			f.SuppressedBy = "ignored"
+		}
+		f.SuppressionExpires = r.Expires
+		return true
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package scan
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/maven"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+// ParseLicenseDenylist splits the --fail-on-license value
+func ParseLicenseDenylist(value string) []string {
+	var denied []string
+	for _, license := range strings.Split(value, ",") {
+		if license = strings.TrimSpace(license); license != "" {
+			denied = append(denied, license)
+		}
+	}
+	return denied
+}
+
+// licenseMatches reports whether the license is the denied one or one of its
+// variants, e.g. GPL-3.0 matches GPL-3.0-only, GPL-3.0-or-later and GPL-3.0+
+func licenseMatches(license, denied string) bool {
+	license, denied = strings.ToLower(sbom.NormalizeLicense(license)), strings.ToLower(denied)
+	if license == denied {
+		return true
+	}
+	return strings.HasPrefix(license, denied+"-") || strings.HasPrefix(license, denied+"+")
+}
+
+// licenseDenied evaluates a license or SPDX expression against the denylist:
+// every alternative of an OR must be denied, any part of an AND is enough
+func licenseDenied(license string, denylist []string) bool {
+	license = strings.NewReplacer("(", " ", ")", " ").Replace(license)
+	for _, alternative := range strings.Split(license, " OR ") {
+		denied := false
+		for _, part := range strings.Split(alternative, " AND ") {
+			part, _, _ = strings.Cut(strings.TrimSpace(part), " WITH ")
+			for _, d := range denylist {
+				if licenseMatches(part, d) {
+					denied = true
+				}
+			}
+		}
+		if !denied {
+			return false
+		}
+	}
+	return true
+}
+
+// deniedComponents returns the components whose licenses are all denied.
+// Several licenses on a component are treated as a choice.
+func deniedComponents(report maven.LicenseReport, denylist []string) []maven.ComponentLicense {
+	var result []maven.ComponentLicense
+	for _, c := range report.Components {
+		if len(c.Licenses) == 0 {
+			continue
+		}
+		denied := true
+		for _, license := range c.Licenses {
+			if !licenseDenied(license, denylist) {
+				denied = false
+				break
+			}
+		}
+		if denied {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// CheckLicenses fails when a component of the license report has a denied license
+func CheckLicenses(reportPath string, denylist []string) error {
+	report, err := maven.ReadLicenseReport(reportPath)
+	if err != nil {
+		return err
+	}
+
+	denied := deniedComponents(report, denylist)
+	for _, c := range denied {
+		runenv.Logger.Warnf("Denied license %s: %s@%s", strings.Join(c.Licenses, ", "), c.Package, c.Version)
+	}
+	if len(denied) > 0 {
+		return runenv.WithExitCode(fmt.Errorf("%d components with denied licenses (%s)", len(denied), strings.Join(denylist, ", ")), runenv.ExitPolicy)
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/maven"
+)
+
+// endoflife.date API, lists the release cycles of a product with their end of life
+const endOfLifeURL = "https://endoflife.date/api"
+
+// DefaultStaleAfter is how long a package may go without a release before it
+// counts as unmaintained
+const DefaultStaleAfter = "2y"
+
+// Maintenance risks of a component
+const (
+	riskEndOfLife    = "eol"
+	riskUnmaintained = "unmaintained"
+	riskDeprecated   = "deprecated"
+)
+
+// Packages and their products on endoflife.date, by package URL type; the
+// package names may contain * like policy rules
+var endOfLifeProducts = []struct {
+	purlType string
+	pattern  string
+	product  string
+}{
+	{"maven", "org.springframework:spring-*", "spring-framework"},
+	{"maven", "org.springframework.boot:*", "spring-boot"},
+	{"maven", "org.apache.tomcat.embed:tomcat-embed-core", "tomcat"},
+	{"maven", "org.apache.tomcat:tomcat-catalina", "tomcat"},
+	{"maven", "org.eclipse.jetty:jetty-server", "eclipse-jetty"},
+	{"maven", "org.hibernate:hibernate-core", "hibernate"},
+	{"maven", "org.hibernate.orm:hibernate-core", "hibernate"},
+	{"maven", "org.apache.logging.log4j:log4j-core", "log4j"},
+	{"maven", "log4j:log4j", "log4j"},
+	{"maven", "io.quarkus:quarkus-core", "quarkus-framework"},
+	{"npm", "@angular/core", "angular"},
+	{"npm", "react", "react"},
+	{"npm", "vue", "vue"},
+	{"npm", "jquery", "jquery"},
+	{"pypi", "django", "django"},
+	{"composer", "laravel/framework", "laravel"},
+	{"composer", "symfony/*", "symfony"},
+	{"gem", "rails", "rails"},
+}
+
+// MaintenanceRisk is a component whose upstream is end of life or no longer
+// releases
+type MaintenanceRisk struct {
+	Package       string `json:"package"`
+	Version       string `json:"version"`
+	PURL          string `json:"purl,omitempty"`
+	Risk          string `json:"risk"` // eol, deprecated or unmaintained
+	Product       string `json:"product,omitempty"`
+	Cycle         string `json:"cycle,omitempty"`
+	EOL           string `json:"eol,omitempty"`            // end of life of the release cycle
+	LatestRelease string `json:"latest_release,omitempty"` // date of the newest release of the package
+	Message       string `json:"message"`
+}
+
+// endOfLifeCycle is a release cycle of an endoflife.date product
+type endOfLifeCycle struct {
+	Cycle string      `json:"cycle"`
+	EOL   interface{} `json:"eol"` // a date, or true and false when the date is not known
+}
+
+// endOfLife returns the end of life of the cycle and whether it is reached
+func (c endOfLifeCycle) endOfLife(now time.Time) (string, bool) {
+	switch eol := c.EOL.(type) {
+	case bool:
+		return "", eol
+	case string:
+		date, err := time.Parse("2006-01-02", eol)
+		return eol, err == nil && !date.After(now)
+	}
+	return "", false
+}
+
+// fetchEndOfLifeCycles returns the release cycles of a product, fetched once
+// per run
+func fetchEndOfLifeCycles(ctx context.Context, product string) ([]endOfLifeCycle, error) {
+	s := runenv.SettingsOf(ctx)
+	if cycles, ok := s.EndOfLifeCycles.Load(product); ok {
+		return cycles.([]endOfLifeCycle), nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endOfLifeURL+"/"+url.PathEscape(product)+".json", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := runenv.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("endoflife.date returned %s", resp.Status)
+	}
+	var cycles []endOfLifeCycle
+	if err := json.NewDecoder(resp.Body).Decode(&cycles); err != nil {
+		return nil, err
+	}
+	s.EndOfLifeCycles.Store(product, cycles)
+	return cycles, nil
+}
+
+// endOfLifeProduct returns the endoflife.date product of a component
+func endOfLifeProduct(c maven.ComponentLicense) string {
+	purlType, _, _ := strings.Cut(strings.TrimPrefix(c.PURL, "pkg:"), "/")
+	for _, p := range endOfLifeProducts {
+		if p.purlType == purlType && packageMatches(p.pattern, c.Package) {
+			return p.product
+		}
+	}
+	return ""
+}
+
+// matchCycle returns the release cycle of a version: the longest cycle that
+// is the version or a prefix of it, 5.3 for 5.3.20
+func matchCycle(cycles []endOfLifeCycle, version string) (endOfLifeCycle, bool) {
+	var match endOfLifeCycle
+	found := false
+	for _, c := range cycles {
+		if (version == c.Cycle || strings.HasPrefix(version, c.Cycle+".")) && len(c.Cycle) > len(match.Cycle) {
+			match, found = c, true
+		}
+	}
+	return match, found
+}
+
+// packageReleases is the release history of a package on deps.dev
+type packageReleases struct {
+	latest     time.Time // newest release
+	deprecated bool      // the default version is deprecated in the registry
+}
+
+// fetchPackageReleases looks up the versions of a package on deps.dev
+func fetchPackageReleases(ctx context.Context, c maven.ComponentLicense) (packageReleases, bool, error) {
+	var releases packageReleases
+	purlType, _, _ := strings.Cut(strings.TrimPrefix(c.PURL, "pkg:"), "/")
+	system, ok := depsDevSystems[purlType]
+	if !ok {
+		return releases, false, nil
+	}
+
+	endpoint := fmt.Sprintf("%s/systems/%s/packages/%s", depsDevURL, system, url.PathEscape(c.Package))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return releases, false, err
+	}
+	resp, err := runenv.HTTPClient.Do(req)
+	if err != nil {
+		return releases, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return releases, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return releases, false, fmt.Errorf("deps.dev returned %s", resp.Status)
+	}
+
+	var pkg struct {
+		Versions []struct {
+			PublishedAt  time.Time `json:"publishedAt"`
+			IsDefault    bool      `json:"isDefault"`
+			IsDeprecated bool      `json:"isDeprecated"`
+		} `json:"versions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&pkg); err != nil {
+		return releases, false, err
+	}
+	for _, v := range pkg.Versions {
+		if v.PublishedAt.After(releases.latest) {
+			releases.latest = v.PublishedAt
+		}
+		if v.IsDefault && v.IsDeprecated {
+			releases.deprecated = true
+		}
+	}
+	return releases, !releases.latest.IsZero(), nil
+}
+
+// ValidateStaleAfter checks a --stale-after value such as 2y or 18m
+func ValidateStaleAfter(value string) error {
+	if !policyAgePattern.MatchString(value) {
+		return fmt.Errorf("invalid --stale-after: %s (expected e.g. 2y, 18m, 6w or 90d)", value)
+	}
+	return nil
+}
+
+// MaintenancePath returns where the maintenance risks of an SBOM are written
+func MaintenancePath(sbomPath string) string {
+	return filepath.Join(filepath.Dir(sbomPath), "sbom-maintenance.json")
+}
+
+// MaintenanceReportFor returns the maintenance risks that belong to a license
+// report: aggregated-maintenance.json for aggregated-licenses.json, else
+// sbom-maintenance.json
+func MaintenanceReportFor(licensesPath string) string {
+	if filepath.Base(licensesPath) == "aggregated-licenses.json" {
+		return filepath.Join(filepath.Dir(licensesPath), "aggregated-maintenance.json")
+	}
+	return filepath.Join(filepath.Dir(licensesPath), "sbom-maintenance.json")
+}
+
+// CheckMaintenance flags the components of the SBOM whose release cycle is
+// end of life on endoflife.date, whose latest version is deprecated in the
+// registry, or whose package has not been released for longer than
+// staleAfter (e.g. 2y). The risks are written next to the SBOM and shown in a
+// separate section of the reports; they never fail the scan.
+func CheckMaintenance(ctx context.Context, sbomPath, staleAfter string) error {
+	report, err := maven.ReadLicenseReport(maven.LicensesPath(sbomPath))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	cutoff := ageCutoff(staleAfter, now)
+	risks := []MaintenanceRisk{}
+	failed := 0
+	for _, c := range report.Components {
+		risk := MaintenanceRisk{Package: c.Package, Version: c.Version, PURL: c.PURL}
+		releases, known, err := fetchPackageReleases(ctx, c)
+		if err != nil {
+			failed++
+		}
+		if known {
+			risk.LatestRelease = releases.latest.Format("2006-01-02")
+		}
+
+		if product := endOfLifeProduct(c); product != "" {
+			cycles, err := fetchEndOfLifeCycles(ctx, product)
+			if err != nil {
+				failed++
+			} else if cycle, ok := matchCycle(cycles, c.Version); ok {
+				if eol, reached := cycle.endOfLife(now); reached {
+					r := risk
+					r.Risk, r.Product, r.Cycle, r.EOL = riskEndOfLife, product, cycle.Cycle, eol
+					r.Message = fmt.Sprintf("%s %s is end of life", product, cycle.Cycle)
+					if eol != "" {
+						r.Message += " since " + eol
+					}
+					risks = append(risks, r)
+				}
+			}
+		}
+
+		switch {
+		case !known:
+			continue
+		case releases.deprecated:
+			risk.Risk = riskDeprecated
+			risk.Message = "the latest version is deprecated in the registry"
+		case releases.latest.Before(cutoff):
+			risk.Risk = riskUnmaintained
+			risk.Message = fmt.Sprintf("no release since %s, longer than %s", risk.LatestRelease, staleAfter)
+		default:
+			continue
+		}
+		risks = append(risks, risk)
+	}
+
+	if failed > 0 {
+		runenv.Logger.Warnf("Could not look up the maintenance status of %d components", failed)
+	}
+	if err := WriteMaintenanceReport(MaintenancePath(sbomPath), risks); err != nil {
+		return err
+	}
+	if len(risks) > 0 {
+		runenv.Logger.Warnf("%d components are end of life or unmaintained, see %s", len(risks), MaintenancePath(sbomPath))
+	}
+	return nil
+}
+
+func WriteMaintenanceReport(path string, risks []MaintenanceRisk) error {
+	sort.SliceStable(risks, func(i, j int) bool {
+		if risks[i].Package != risks[j].Package {
+			return risks[i].Package < risks[j].Package
+		}
+		return risks[i].Version < risks[j].Version
+	})
+	data, err := json.MarshalIndent(risks, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode maintenance report: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write maintenance report: %v", err)
+	}
+	return nil
+}
+
+func ReadMaintenanceReport(path string) ([]MaintenanceRisk, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read maintenance report: %v", err)
+	}
+	var risks []MaintenanceRisk
+	if err := json.Unmarshal(data, &risks); err != nil {
+		return nil, fmt.Errorf("failed to parse maintenance report %s: %v", path, err)
+	}
+	return risks, nil
+}
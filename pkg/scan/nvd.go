@@ -0,0 +1,289 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+// NVD CVE API 2.0, NVD_API_URL overrides it for mirrors
+const nvdAPIURL = "https://services.nvd.nist.gov/rest/json/cves/2.0"
+
+// NVD_API_KEY raises the rate limit of the NVD API from 5 to 50 requests per
+// 30 seconds
+const nvdAPIKeyEnv = "NVD_API_KEY"
+
+const nvdRateWindow = 30 * time.Second
+
+// NVDOptions configures the enrichment of findings from the NVD
+type NVDOptions struct {
+	Enabled  bool
+	apiKey   string
+	endpoint string
+}
+
+// NewNVDOptions reads the API key from NVD_API_KEY, the enrichment works
+// without one but is rate limited to a request every 6 seconds
+func NewNVDOptions(ctx context.Context) (NVDOptions, error) {
+	opts := NVDOptions{Enabled: true, apiKey: strings.TrimSpace(os.Getenv(nvdAPIKeyEnv)), endpoint: nvdAPIURL}
+	if runenv.SettingsOf(ctx).Offline {
+		return opts, fmt.Errorf("NVD enrichment needs the NVD API and cannot be combined with --offline")
+	}
+	if env := os.Getenv("NVD_API_URL"); env != "" {
+		opts.endpoint = env
+	}
+	return opts, nil
+}
+
+// rateLimit is the number of requests allowed per 30 seconds
+func (o NVDOptions) rateLimit() int {
+	if o.apiKey != "" {
+		return 50
+	}
+	return 5
+}
+
+// nvdCVE is the part of an NVD CVE record added to findings
+type nvdCVE struct {
+	ID      string `json:"id"`
+	Metrics struct {
+		CVSSMetricV31 []nvdCVSSMetric `json:"cvssMetricV31"`
+		CVSSMetricV30 []nvdCVSSMetric `json:"cvssMetricV30"`
+	} `json:"metrics"`
+	Weaknesses []struct {
+		Description []struct {
+			Value string `json:"value"`
+		} `json:"description"`
+	} `json:"weaknesses"`
+	Configurations []struct {
+		Nodes []struct {
+			CPEMatch []struct {
+				Vulnerable bool   `json:"vulnerable"`
+				Criteria   string `json:"criteria"`
+			} `json:"cpeMatch"`
+		} `json:"nodes"`
+	} `json:"configurations"`
+}
+
+type nvdCVSSMetric struct {
+	Source   string `json:"source"`
+	Type     string `json:"type"` // Primary for the scoring of the NVD, Secondary for CNAs
+	CVSSData struct {
+		VectorString string `json:"vectorString"`
+	} `json:"cvssData"`
+}
+
+// cvssVector returns the CVSS v3 vector of the NVD itself, or of the CNA
+// without one; v3.1 takes precedence over v3.0
+func (c nvdCVE) cvssVector() string {
+	for _, metrics := range [][]nvdCVSSMetric{c.Metrics.CVSSMetricV31, c.Metrics.CVSSMetricV30} {
+		for _, m := range metrics {
+			if m.Type == "Primary" {
+				return m.CVSSData.VectorString
+			}
+		}
+		if len(metrics) > 0 {
+			return metrics[0].CVSSData.VectorString
+		}
+	}
+	return ""
+}
+
+// nvdClient fetches CVE records one at a time, from the cache or within the
+// rate limit of the API
+type nvdClient struct {
+	opts     NVDOptions
+	requests []time.Time // start of the requests in the current window
+	waited   bool
+}
+
+// EnrichFindingsFromNVD adds the CVSS v3 vector, the CWE IDs and the
+// vulnerable CPEs of the NVD to the findings with a CVE ID. The score is
+// recomputed from the vector and kept when it is higher than the one of the
+// scanners. Responses are cached like scan results; the enrichment is best
+// effort, a failed lookup only logs a warning.
+func EnrichFindingsFromNVD(ctx context.Context, findingsPath string, opts NVDOptions) error {
+	findings, err := ReadFindings(findingsPath)
+	if err != nil {
+		return err
+	}
+
+	client := &nvdClient{opts: opts}
+	records := make(map[string]*nvdCVE)
+	enriched := 0
+	for i, f := range findings {
+		var matched []nvdCVE
+		for _, id := range f.IDs() {
+			if !cveIDPattern.MatchString(id) {
+				continue
+			}
+			record, ok := records[id]
+			if !ok {
+				if record, err = client.lookup(ctx, id); err != nil {
+					runenv.Logger.Warnf("Could not look up %s in the NVD: %v", id, err)
+					return finishNVDEnrichment(findingsPath, findings, enriched)
+				}
+				records[id] = record
+			}
+			if record != nil {
+				matched = append(matched, *record)
+			}
+		}
+		if len(matched) > 0 {
+			findings[i] = applyNVDRecords(f, matched)
+			enriched++
+		}
+	}
+	return finishNVDEnrichment(findingsPath, findings, enriched)
+}
+
+// finishNVDEnrichment writes the findings enriched so far
+func finishNVDEnrichment(findingsPath string, findings []Finding, enriched int) error {
+	if enriched == 0 {
+		return nil
+	}
+	if err := writeFindings(findingsPath, findings); err != nil {
+		return err
+	}
+	runenv.Logger.Infof("Enriched %d findings from the NVD", enriched)
+	return nil
+}
+
+// lookup returns the NVD record of a CVE, nil when the NVD does not know it
+func (c *nvdClient) lookup(ctx context.Context, id string) (*nvdCVE, error) {
+	s := runenv.SettingsOf(ctx)
+	entry := filepath.Join(s.CacheDir("nvd"), id+".json")
+	if _, fresh := s.CacheEntryFresh(entry); fresh {
+		if data, err := os.ReadFile(entry); err == nil {
+			return decodeNVDResponse(data)
+		}
+	}
+
+	if err := c.wait(ctx); err != nil {
+		return nil, err
+	}
+	data, err := runenv.Fetch(ctx, "NVD request for "+id, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.opts.endpoint+"?cveId="+url.QueryEscape(id), nil)
+		if err != nil {
+			return nil, err
+		}
+		if c.opts.apiKey != "" {
+			req.Header.Set("apiKey", c.opts.apiKey)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	record, err := decodeNVDResponse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.ResultCacheTTL > 0 {
+		if err := os.MkdirAll(filepath.Dir(entry), 0755); err != nil {
+			runenv.Logger.Warnf("Failed to cache NVD response: %v", err)
+		} else if err := os.WriteFile(entry, data, 0644); err != nil {
+			runenv.Logger.Warnf("Failed to cache NVD response: %v", err)
+		}
+	}
+	return record, nil
+}
+
+// wait blocks until another request fits into the rate limit of the last 30
+// seconds
+func (c *nvdClient) wait(ctx context.Context) error {
+	limit := c.opts.rateLimit()
+	if len(c.requests) >= limit {
+		delay := time.Until(c.requests[len(c.requests)-limit].Add(nvdRateWindow))
+		if delay > 0 {
+			if !c.waited {
+				runenv.Logger.Infof("Waiting for the NVD rate limit of %d requests per 30 seconds (%s raises it)", limit, nvdAPIKeyEnv)
+				c.waited = true
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+		c.requests = c.requests[len(c.requests)-limit+1:]
+	}
+	c.requests = append(c.requests, time.Now())
+	return nil
+}
+
+func decodeNVDResponse(data []byte) (*nvdCVE, error) {
+	var response struct {
+		Vulnerabilities []struct {
+			CVE nvdCVE `json:"cve"`
+		} `json:"vulnerabilities"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode NVD response: %v", err)
+	}
+	if len(response.Vulnerabilities) == 0 {
+		return nil, nil
+	}
+	return &response.Vulnerabilities[0].CVE, nil
+}
+
+// applyNVDRecords adds the CVSS vector, CWE IDs and CPEs of the NVD records to
+// a finding
+func applyNVDRecords(f Finding, records []nvdCVE) Finding {
+	cwes := make(map[string]bool)
+	for _, cwe := range f.CWEs {
+		cwes[cwe] = true
+	}
+	cpes := make(map[string]bool)
+	for _, cpe := range f.CPEs {
+		cpes[cpe] = true
+	}
+
+	for _, record := range records {
+		if vector := record.cvssVector(); vector != "" {
+			if score, err := cvss3BaseScore(vector); err == nil && (f.CVSSVector == "" || score >= f.Score) {
+				f.CVSSVector = vector
+				if score > f.Score {
+					f.Score = score
+				}
+				if severity := cvssSeverity(score); SeverityRank(severity) > SeverityRank(f.Severity) {
+					f.Severity = severity
+				}
+			}
+		}
+		for _, weakness := range record.Weaknesses {
+			for _, description := range weakness.Description {
+				// NVD-CWE-Other and NVD-CWE-noinfo are placeholders
+				if strings.HasPrefix(description.Value, "CWE-") {
+					cwes[description.Value] = true
+				}
+			}
+		}
+		for _, configuration := range record.Configurations {
+			for _, node := range configuration.Nodes {
+				for _, match := range node.CPEMatch {
+					if match.Vulnerable {
+						cpes[match.Criteria] = true
+					}
+				}
+			}
+		}
+	}
+
+	if len(cwes) > 0 {
+		f.CWEs = sortedKeys(cwes)
+	}
+	if len(cpes) > 0 {
+		f.CPEs = sortedKeys(cpes)
+	}
+	return f
+}
@@ -0,0 +1,469 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+const (
+	osvAPIURL       = "https://api.osv.dev/v1"
+	osvMaxBatchSize = 1000
+
+	// Requests to the OSV API in flight at the same time and per second,
+	// shared by all modules of a run
+	osvConcurrency       = 16
+	osvRequestsPerSecond = 100
+)
+
+// osvLimiter keeps the requests of concurrently scanned modules within the
+// rate of the OSV API
+var osvLimiter = runenv.NewTokenBucket(osvRequestsPerSecond, osvConcurrency)
+
+// osvVulnerabilities caches the vulnerability records fetched during the
+// run, which modules of a monorepo share
+var osvVulnerabilities sync.Map
+
+// osvReport mirrors the JSON output of osv-scanner so that both scanners
+// produce the same vulnerability report
+type osvReport struct {
+	Results []osvResult `json:"results"`
+}
+
+type osvResult struct {
+	Source   osvSource          `json:"source"`
+	Packages []osvPackageResult `json:"packages"`
+}
+
+type osvSource struct {
+	Path string `json:"path"`
+	Type string `json:"type"`
+}
+
+type osvPackageResult struct {
+	Package         sbom.OSVPackage    `json:"package"`
+	Vulnerabilities []osvVulnerability `json:"vulnerabilities"`
+	Groups          []osvGroup         `json:"groups"`
+}
+
+type osvGroup struct {
+	IDs         []string `json:"ids"`
+	Aliases     []string `json:"aliases,omitempty"`
+	MaxSeverity string   `json:"max_severity,omitempty"`
+}
+
+type osvVulnerability struct {
+	ID               string                 `json:"id"`
+	Summary          string                 `json:"summary,omitempty"`
+	Details          string                 `json:"details,omitempty"`
+	Aliases          []string               `json:"aliases,omitempty"`
+	Modified         string                 `json:"modified,omitempty"`
+	Published        string                 `json:"published,omitempty"`
+	Withdrawn        string                 `json:"withdrawn,omitempty"`
+	Severity         []osvSeverity          `json:"severity,omitempty"`
+	Affected         []osvAffected          `json:"affected,omitempty"`
+	References       []osvReference         `json:"references,omitempty"`
+	DatabaseSpecific map[string]interface{} `json:"database_specific,omitempty"`
+}
+
+type osvSeverity struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+type osvAffected struct {
+	Package           sbom.OSVPackage        `json:"package"`
+	Ranges            []osvRange             `json:"ranges,omitempty"`
+	Versions          []string               `json:"versions,omitempty"`
+	EcosystemSpecific map[string]interface{} `json:"ecosystem_specific,omitempty"`
+}
+
+type osvRange struct {
+	Type   string     `json:"type"`
+	Events []osvEvent `json:"events"`
+}
+
+type osvEvent struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+}
+
+type osvReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type osvBatchQuery struct {
+	Queries []osvQuery `json:"queries"`
+}
+
+type osvQuery struct {
+	Package   osvQueryPackage `json:"package"`
+	PageToken string          `json:"page_token,omitempty"`
+}
+
+type osvQueryPackage struct {
+	PURL string `json:"purl"`
+}
+
+type osvBatchResponse struct {
+	Results []struct {
+		Vulns []struct {
+			ID string `json:"id"`
+		} `json:"vulns"`
+		NextPageToken string `json:"next_page_token,omitempty"`
+	} `json:"results"`
+}
+
+// scanOSVAPI queries the OSV.dev API for every component in the SBOM and writes
+// the report to outputPath. It reports whether vulnerabilities were found.
+// In offline mode the local OSV database is used instead.
+func scanOSVAPI(ctx context.Context, sbomPath, outputPath string) (bool, error) {
+	if runenv.SettingsOf(ctx).Offline {
+		return scanOSVDatabase(ctx, sbomPath, outputPath)
+	}
+
+	components, err := sbom.ReadCycloneDX(sbomPath)
+	if err != nil {
+		return false, err
+	}
+
+	var purls []string
+	seen := make(map[string]bool)
+	for _, c := range components {
+		purl := sbom.StripPURLQualifiers(c.PURL)
+		if purl == "" || seen[purl] || !strings.Contains(purl, "@") {
+			continue
+		}
+		seen[purl] = true
+		purls = append(purls, purl)
+	}
+
+	matches, details, err := queryOSVStream(ctx, purls)
+	if err != nil {
+		return false, err
+	}
+
+	absSbomPath, _ := filepath.Abs(sbomPath)
+	result := osvResult{Source: osvSource{Path: absSbomPath, Type: "sbom"}}
+	for i, purl := range purls {
+		if len(matches[i]) == 0 {
+			continue
+		}
+
+		pkg, ok := sbom.PackageFromPURL(purl)
+		if !ok {
+			continue
+		}
+
+		packageResult := osvPackageResult{Package: pkg}
+		for _, id := range matches[i] {
+			packageResult.Vulnerabilities = append(packageResult.Vulnerabilities, details[id])
+		}
+		packageResult.Groups = groupVulnerabilities(packageResult.Vulnerabilities)
+		setGroupMaxSeverity(packageResult.Groups, details)
+		result.Packages = append(result.Packages, packageResult)
+	}
+
+	report := osvReport{Results: []osvResult{}}
+	if len(result.Packages) > 0 {
+		report.Results = append(report.Results, result)
+	}
+
+	if err := writeOSVReport(outputPath, report); err != nil {
+		return false, err
+	}
+
+	return len(result.Packages) > 0, nil
+}
+
+// queryOSVStream queries the vulnerabilities of the package URLs and fetches
+// their records while the remaining batches are still being queried. It
+// returns the IDs matching each package URL and the records by ID.
+func queryOSVStream(ctx context.Context, purls []string) ([][]string, map[string]osvVulnerability, error) {
+	start := time.Now()
+	matches := make([][]string, len(purls))
+	details := make(map[string]osvVulnerability)
+	var mu sync.Mutex
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ids := make(chan string)
+	errs := make([]error, osvConcurrency)
+	var workers sync.WaitGroup
+	for w := range osvConcurrency {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for id := range ids {
+				vuln, err := fetchOSVVulnerability(ctx, id)
+				if err != nil {
+					errs[w] = err
+					cancel()
+					return
+				}
+				mu.Lock()
+				details[id] = vuln
+				mu.Unlock()
+			}
+		}()
+	}
+
+	requested := make(map[string]bool)
+	err := queryOSVBatches(ctx, purls, func(i int, found []string) error {
+		var fresh []string
+		mu.Lock()
+		matches[i] = append(matches[i], found...)
+		for _, id := range found {
+			if !requested[id] {
+				requested[id] = true
+				fresh = append(fresh, id)
+			}
+		}
+		mu.Unlock()
+		for _, id := range fresh {
+			select {
+			case ids <- id:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+	close(ids)
+	workers.Wait()
+	// A failed record fetch cancels the queries, its error is the cause
+	for _, fetchErr := range errs {
+		if fetchErr != nil {
+			return nil, nil, fetchErr
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	runenv.Logger.Infof("Queried OSV for %d packages and fetched %d vulnerabilities in %s",
+		len(purls), len(details), time.Since(start).Round(time.Millisecond))
+	return matches, details, nil
+}
+
+// queryOSVBatch returns the matching vulnerability IDs for each package URL
+func queryOSVBatch(ctx context.Context, purls []string) ([][]string, error) {
+	matches := make([][]string, len(purls))
+	var mu sync.Mutex
+	err := queryOSVBatches(ctx, purls, func(i int, ids []string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		matches[i] = append(matches[i], ids...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// queryOSVBatches sends the package URLs to the querybatch endpoint in
+// batches of osvMaxBatchSize, osvConcurrency at a time, and calls found with
+// the index of each package URL and the IDs matching it as the responses
+// arrive. Packages with more vulnerabilities than fit into a response are
+// queried again with its page token, so found may be called several times
+// for them, and concurrently for different packages.
+func queryOSVBatches(ctx context.Context, purls []string, found func(i int, ids []string) error) error {
+	type pending struct {
+		index int
+		token string
+	}
+	queue := make([]pending, len(purls))
+	for i := range purls {
+		queue[i] = pending{index: i}
+	}
+
+	for len(queue) > 0 {
+		var batches [][]pending
+		for start := 0; start < len(queue); start += osvMaxBatchSize {
+			batches = append(batches, queue[start:min(start+osvMaxBatchSize, len(queue))])
+		}
+
+		var mu sync.Mutex
+		var next []pending
+		err := runenv.ForEachConcurrently(ctx, len(batches), osvConcurrency, func(ctx context.Context, b int) error {
+			batch := batches[b]
+			query := osvBatchQuery{}
+			for _, p := range batch {
+				query.Queries = append(query.Queries, osvQuery{Package: osvQueryPackage{PURL: purls[p.index]}, PageToken: p.token})
+			}
+
+			var response osvBatchResponse
+			if err := postOSV(ctx, "/querybatch", query, &response); err != nil {
+				return err
+			}
+			if len(response.Results) != len(batch) {
+				return fmt.Errorf("osv query returned %d results for %d packages", len(response.Results), len(batch))
+			}
+
+			for j, r := range response.Results {
+				if r.NextPageToken != "" {
+					mu.Lock()
+					next = append(next, pending{index: batch[j].index, token: r.NextPageToken})
+					mu.Unlock()
+				}
+				if len(r.Vulns) == 0 {
+					continue
+				}
+				ids := make([]string, 0, len(r.Vulns))
+				for _, v := range r.Vulns {
+					ids = append(ids, v.ID)
+				}
+				if err := found(batch[j].index, ids); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		queue = next
+	}
+	return nil
+}
+
+func fetchOSVVulnerability(ctx context.Context, id string) (osvVulnerability, error) {
+	if cached, ok := osvVulnerabilities.Load(id); ok {
+		return cached.(osvVulnerability), nil
+	}
+	var vuln osvVulnerability
+
+	data, err := runenv.Fetch(ctx, "OSV request for "+id, func() (*http.Request, error) {
+		if err := osvLimiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+		return http.NewRequestWithContext(ctx, http.MethodGet, osvAPIURL+"/vulns/"+url.PathEscape(id), nil)
+	})
+	if err != nil {
+		return vuln, fmt.Errorf("osv request for %s failed: %v", id, err)
+	}
+
+	if err := json.Unmarshal(data, &vuln); err != nil {
+		return vuln, fmt.Errorf("failed to decode osv response: %v", err)
+	}
+	osvVulnerabilities.Store(id, vuln)
+	return vuln, nil
+}
+
+func postOSV(ctx context.Context, path string, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	response, err := runenv.Fetch(ctx, "OSV request "+path, func() (*http.Request, error) {
+		if err := osvLimiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, osvAPIURL+path, bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return fmt.Errorf("osv request failed: %v", err)
+	}
+
+	if err := json.Unmarshal(response, out); err != nil {
+		return fmt.Errorf("failed to decode osv response: %v", err)
+	}
+	return nil
+}
+
+// groupVulnerabilities groups vulnerabilities that are aliases of each other
+func groupVulnerabilities(vulns []osvVulnerability) []osvGroup {
+	groupOf := make(map[string]int)
+	var groups []osvGroup
+
+	for _, v := range vulns {
+		index := -1
+		for _, id := range append([]string{v.ID}, v.Aliases...) {
+			if i, ok := groupOf[id]; ok {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			index = len(groups)
+			groups = append(groups, osvGroup{})
+		}
+
+		groups[index].IDs = append(groups[index].IDs, v.ID)
+		groupOf[v.ID] = index
+		for _, alias := range v.Aliases {
+			if _, ok := groupOf[alias]; !ok {
+				groupOf[alias] = index
+				groups[index].Aliases = append(groups[index].Aliases, alias)
+			}
+		}
+	}
+
+	for i := range groups {
+		sort.Strings(groups[i].IDs)
+		sort.Strings(groups[i].Aliases)
+	}
+	return groups
+}
+
+// setGroupMaxSeverity fills in the highest CVSS score of each group like osv-scanner does
+func setGroupMaxSeverity(groups []osvGroup, vulns map[string]osvVulnerability) {
+	for i, group := range groups {
+		var max float64
+		for _, id := range group.IDs {
+			if _, score := vulnerabilitySeverity(vulns[id]); score > max {
+				max = score
+			}
+		}
+		if max > 0 {
+			groups[i].MaxSeverity = strconv.FormatFloat(max, 'f', 1, 64)
+		}
+	}
+}
+
+func readOSVReport(path string) (osvReport, error) {
+	var report osvReport
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return report, fmt.Errorf("failed to read report: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &report); err != nil {
+		return report, fmt.Errorf("failed to parse report %s: %v", path, err)
+	}
+	return report, nil
+}
+
+func writeOSVReport(outputPath string, report osvReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %v", err)
+	}
+
+	if err := os.WriteFile(outputPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write report: %v", err)
+	}
+	return nil
+}
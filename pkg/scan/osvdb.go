@@ -0,0 +1,315 @@
+package scan
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+const (
+	// Bucket with the full OSV database, one all.zip per ecosystem
+	osvDatabaseURL = "https://osv-vulnerabilities.storage.googleapis.com"
+	// Ecosystem archives are large, the download gets more time than API calls
+	osvDownloadTimeout = 30 * time.Minute
+	// Offline databases older than this are reported as stale
+	OSVDatabaseMaxAge = 7 * 24 * time.Hour
+)
+
+// DefaultOSVDatabaseDir returns the user cache directory for the OSV database
+func DefaultOSVDatabaseDir() string {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(".sbom-scanner", "osv")
+	}
+	return filepath.Join(cache, "sbom-scanner", "osv")
+}
+
+// OSVDatabase returns the configured database directory
+func OSVDatabase(s *runenv.Settings) string {
+	if s.OSVDatabaseDir != "" {
+		return s.OSVDatabaseDir
+	}
+	return DefaultOSVDatabaseDir()
+}
+
+// OSVEcosystemPath returns where the archive of an ecosystem is stored
+func OSVEcosystemPath(dir, ecosystem string) string {
+	return filepath.Join(dir, ecosystem, "all.zip")
+}
+
+// OSVEcosystems lists the ecosystems of the supported package URL types
+func OSVEcosystems() []string {
+	var ecosystems []string
+	for _, ecosystem := range sbom.PURLEcosystems {
+		ecosystems = append(ecosystems, ecosystem)
+	}
+	sort.Strings(ecosystems)
+	return ecosystems
+}
+
+// DownloadOSVEcosystem fetches the archive of an ecosystem into dir
+func DownloadOSVEcosystem(dir, ecosystem string) (int64, error) {
+	client := &http.Client{Timeout: osvDownloadTimeout}
+	resp, err := client.Get(osvDatabaseURL + "/" + ecosystem + "/all.zip")
+	if err != nil {
+		return 0, fmt.Errorf("failed to download %s database: %v", ecosystem, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to download %s database: %s", ecosystem, resp.Status)
+	}
+
+	outputPath := OSVEcosystemPath(dir, ecosystem)
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return 0, fmt.Errorf("failed to create database directory: %v", err)
+	}
+
+	// Replace the previous archive only once the new one is complete
+	tmp, err := os.CreateTemp(filepath.Dir(outputPath), "all-*.zip")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create database file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	size, err := io.Copy(tmp, resp.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to download %s database: %v", ecosystem, err)
+	}
+	archive, err := zip.OpenReader(tmp.Name())
+	if err != nil {
+		return 0, fmt.Errorf("downloaded %s database is not a valid archive: %v", ecosystem, err)
+	}
+	archive.Close()
+	if err := os.Rename(tmp.Name(), outputPath); err != nil {
+		return 0, fmt.Errorf("failed to store %s database: %v", ecosystem, err)
+	}
+	return size, nil
+}
+
+// checkOSVDatabase fails when the offline database has no data for the
+// ecosystems and warns about stale ones
+func checkOSVDatabase(dir string, ecosystems []string) error {
+	var missing []string
+	for _, ecosystem := range ecosystems {
+		info, err := os.Stat(OSVEcosystemPath(dir, ecosystem))
+		if err != nil {
+			missing = append(missing, ecosystem)
+			continue
+		}
+		if age := time.Since(info.ModTime()); age > OSVDatabaseMaxAge {
+			runenv.Logger.Warnf("Offline OSV database for %s is %d days old, refresh it with \"sbom-scanner db download\"",
+				ecosystem, int(age.Hours()/24))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("offline OSV database in %s has no data for %s; download it with internet access: sbom-scanner db download --ecosystems %s",
+			dir, strings.Join(missing, ", "), strings.Join(missing, ","))
+	}
+	return nil
+}
+
+// osvPackageKey identifies a package in an ecosystem, with PyPI names normalized
+func osvPackageKey(ecosystem, name string) string {
+	if ecosystem == "PyPI" {
+		name = strings.NewReplacer("_", "-", ".", "-").Replace(strings.ToLower(name))
+	}
+	return ecosystem + "/" + name
+}
+
+// loadOSVEcosystem reads the vulnerabilities of the given packages from the
+// archive of an ecosystem, indexed by package key
+func loadOSVEcosystem(dir, ecosystem string, packages map[string]bool) (map[string][]osvVulnerability, error) {
+	path := OSVEcosystemPath(dir, ecosystem)
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open offline OSV database %s: %v", path, err)
+	}
+	defer archive.Close()
+
+	index := make(map[string][]osvVulnerability)
+	for _, file := range archive.File {
+		if !strings.HasSuffix(file.Name, ".json") {
+			continue
+		}
+		reader, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s from %s: %v", file.Name, path, err)
+		}
+		var vuln osvVulnerability
+		err = json.NewDecoder(reader).Decode(&vuln)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s from %s: %v", file.Name, path, err)
+		}
+		if vuln.Withdrawn != "" {
+			continue
+		}
+
+		seen := make(map[string]bool)
+		for _, affected := range vuln.Affected {
+			key := osvPackageKey(affected.Package.Ecosystem, affected.Package.Name)
+			if packages[key] && !seen[key] {
+				seen[key] = true
+				index[key] = append(index[key], vuln)
+			}
+		}
+	}
+	return index, nil
+}
+
+// osvAffects reports whether an affected entry covers the version. Versions
+// listed explicitly match, and ECOSYSTEM and SEMVER ranges are evaluated
+// event by event. GIT ranges cannot be matched against package versions.
+func osvAffects(affected osvAffected, version string) bool {
+	if slices.Contains(affected.Versions, version) {
+		return true
+	}
+
+	for _, r := range affected.Ranges {
+		if r.Type != "ECOSYSTEM" && r.Type != "SEMVER" {
+			continue
+		}
+		v := version
+		if r.Type == "SEMVER" {
+			v = strings.TrimPrefix(v, "v")
+		}
+
+		// Events apply in version order, introduced "0" comes first
+		events := slices.Clone(r.Events)
+		sort.SliceStable(events, func(i, j int) bool {
+			return sbom.CompareVersions(osvEventVersion(events[i]), osvEventVersion(events[j])) < 0
+		})
+
+		inRange := false
+		for _, e := range events {
+			switch {
+			case e.Introduced != "":
+				if e.Introduced == "0" || sbom.CompareVersions(v, e.Introduced) >= 0 {
+					inRange = true
+				}
+			case e.Fixed != "":
+				if sbom.CompareVersions(v, e.Fixed) >= 0 {
+					inRange = false
+				}
+			case e.LastAffected != "":
+				if sbom.CompareVersions(v, e.LastAffected) > 0 {
+					inRange = false
+				}
+			}
+		}
+		if inRange {
+			return true
+		}
+	}
+	return false
+}
+
+// osvEventVersion returns the version an event refers to
+func osvEventVersion(e osvEvent) string {
+	switch {
+	case e.Introduced != "":
+		return e.Introduced
+	case e.Fixed != "":
+		return e.Fixed
+	}
+	return e.LastAffected
+}
+
+// scanOSVDatabase matches the SBOM components against the offline OSV
+// database and writes the same report as scanOSVAPI
+func scanOSVDatabase(ctx context.Context, sbomPath, outputPath string) (bool, error) {
+	components, err := sbom.ReadCycloneDX(sbomPath)
+	if err != nil {
+		return false, err
+	}
+
+	var purls []string
+	packages := make(map[string]bool)
+	var ecosystems []string
+	seen := make(map[string]bool)
+	for _, c := range components {
+		purl := sbom.StripPURLQualifiers(c.PURL)
+		if purl == "" || seen[purl] || !strings.Contains(purl, "@") {
+			continue
+		}
+		seen[purl] = true
+		pkg, ok := sbom.PackageFromPURL(purl)
+		if !ok {
+			continue
+		}
+		purls = append(purls, purl)
+		packages[osvPackageKey(pkg.Ecosystem, pkg.Name)] = true
+		if !slices.Contains(ecosystems, pkg.Ecosystem) {
+			ecosystems = append(ecosystems, pkg.Ecosystem)
+		}
+	}
+
+	dir := OSVDatabase(runenv.SettingsOf(ctx))
+	if err := checkOSVDatabase(dir, ecosystems); err != nil {
+		return false, err
+	}
+
+	index := make(map[string][]osvVulnerability)
+	for _, ecosystem := range ecosystems {
+		vulns, err := loadOSVEcosystem(dir, ecosystem, packages)
+		if err != nil {
+			return false, err
+		}
+		for key, list := range vulns {
+			index[key] = list
+		}
+	}
+
+	absSbomPath, _ := filepath.Abs(sbomPath)
+	result := osvResult{Source: osvSource{Path: absSbomPath, Type: "sbom"}}
+	details := make(map[string]osvVulnerability)
+
+	for _, purl := range purls {
+		pkg, _ := sbom.PackageFromPURL(purl)
+		key := osvPackageKey(pkg.Ecosystem, pkg.Name)
+
+		packageResult := osvPackageResult{Package: pkg}
+		for _, vuln := range index[key] {
+			for _, affected := range vuln.Affected {
+				if osvPackageKey(affected.Package.Ecosystem, affected.Package.Name) == key && osvAffects(affected, pkg.Version) {
+					packageResult.Vulnerabilities = append(packageResult.Vulnerabilities, vuln)
+					details[vuln.ID] = vuln
+					break
+				}
+			}
+		}
+		if len(packageResult.Vulnerabilities) == 0 {
+			continue
+		}
+		packageResult.Groups = groupVulnerabilities(packageResult.Vulnerabilities)
+		setGroupMaxSeverity(packageResult.Groups, details)
+		result.Packages = append(result.Packages, packageResult)
+	}
+
+	report := osvReport{Results: []osvResult{}}
+	if len(result.Packages) > 0 {
+		report.Results = append(report.Results, result)
+	}
+
+	if err := writeOSVReport(outputPath, report); err != nil {
+		return false, err
+	}
+
+	return len(result.Packages) > 0, nil
+}
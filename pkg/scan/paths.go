@@ -0,0 +1,45 @@
+package scan
+
+import (
+	"os"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+// TraceDependencyPaths records in the findings file which direct dependency,
+// and which chain of dependencies, pulls each vulnerable package in
+func TraceDependencyPaths(findingsPath, depsPath string) error {
+	if _, err := os.Stat(depsPath); err != nil {
+		return nil
+	}
+	graph, err := sbom.ReadDependencyTree(depsPath)
+	if err != nil {
+		return err
+	}
+
+	findings, err := ReadFindings(findingsPath)
+	if err != nil {
+		return err
+	}
+
+	transitive := 0
+	for i, f := range findings {
+		path := sbom.FindDependency(graph.Dependencies, f.Package, f.Version)
+		findings[i].DependencyPath = nil
+		for _, node := range path {
+			findings[i].DependencyPath = append(findings[i].DependencyPath, node.Package+"@"+node.Version)
+		}
+		if len(path) > 1 {
+			transitive++
+		}
+	}
+
+	if err := writeFindings(findingsPath, findings); err != nil {
+		return err
+	}
+	if transitive > 0 {
+		runenv.Logger.Infof("%d of %d findings are in transitive dependencies", transitive, len(findings))
+	}
+	return nil
+}
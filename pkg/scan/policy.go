@@ -0,0 +1,350 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/maven"
+	"gopkg.in/yaml.v3"
+)
+
+// deps.dev API, used to look up release dates for max-age rules
+const depsDevURL = "https://api.deps.dev/v3"
+
+// Package URL types and their deps.dev systems
+var depsDevSystems = map[string]string{
+	"maven":  "maven",
+	"npm":    "npm",
+	"golang": "go",
+	"pypi":   "pypi",
+	"nuget":  "nuget",
+	"cargo":  "cargo",
+}
+
+// PolicyRule fires for every component or finding that meets all of its
+// conditions. Rules with severity or fix-available apply to findings, the
+// others to components.
+type PolicyRule struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+	Action      string `yaml:"action,omitempty"` // fail (default) or warn
+
+	Package      string `yaml:"package,omitempty"`       // package name, * matches any characters
+	License      string `yaml:"license,omitempty"`       // denied license, variants such as -only match too
+	MaxAge       string `yaml:"max-age,omitempty"`       // releases older than this, e.g. 5y, 18m, 90d
+	Unpinned     bool   `yaml:"unpinned,omitempty"`      // SNAPSHOT versions and version ranges, with package only
+	Severity     string `yaml:"severity,omitempty"`      // findings at or above this severity
+	FixAvailable *bool  `yaml:"fix-available,omitempty"` // findings with (or without) a fixed version
+}
+
+// policyFile is the format of --policy-file, the same as the policies section of the config
+type policyFile struct {
+	Policies []PolicyRule `yaml:"policies"`
+}
+
+// policyViolation is a component or finding for which a rule fired
+type policyViolation struct {
+	Rule        string `json:"rule"`
+	Description string `json:"description,omitempty"`
+	Action      string `json:"action"`
+	Package     string `json:"package"`
+	Version     string `json:"version"`
+	ID          string `json:"id,omitempty"` // vulnerability ID for finding rules
+	Message     string `json:"message"`
+}
+
+var policyAgePattern = regexp.MustCompile(`^(\d+)([ymwd])$`)
+
+// appliesToFindings reports whether the rule is evaluated against findings
+func (r PolicyRule) appliesToFindings() bool {
+	return r.Severity != "" || r.FixAvailable != nil
+}
+
+// cutoff returns the release date before which a component is too old
+func (r PolicyRule) cutoff(now time.Time) time.Time {
+	return ageCutoff(r.MaxAge, now)
+}
+
+// ageCutoff returns the date an age such as 5y, 18m, 6w or 90d before now
+func ageCutoff(age string, now time.Time) time.Time {
+	m := policyAgePattern.FindStringSubmatch(age)
+	n, _ := strconv.Atoi(m[1])
+	switch m[2] {
+	case "y":
+		return now.AddDate(-n, 0, 0)
+	case "m":
+		return now.AddDate(0, -n, 0)
+	case "w":
+		return now.AddDate(0, 0, -7*n)
+	}
+	return now.AddDate(0, 0, -n)
+}
+
+func (r PolicyRule) action() string {
+	if r.Action == "" {
+		return "fail"
+	}
+	return r.Action
+}
+
+// ValidatePolicyRules checks the rules and normalizes their severities
+func ValidatePolicyRules(rules []PolicyRule) error {
+	for i := range rules {
+		r := &rules[i]
+		if r.Name == "" {
+			return fmt.Errorf("policy rule needs a name")
+		}
+		switch r.Action {
+		case "", "fail", "warn":
+		default:
+			return fmt.Errorf("invalid action for policy %s: %s (expected fail or warn)", r.Name, r.Action)
+		}
+		if r.Package == "" && r.License == "" && r.MaxAge == "" && !r.Unpinned && !r.appliesToFindings() {
+			return fmt.Errorf("policy %s has no conditions", r.Name)
+		}
+		if r.appliesToFindings() && (r.License != "" || r.MaxAge != "" || r.Unpinned) {
+			return fmt.Errorf("policy %s mixes finding conditions with license, max-age or unpinned", r.Name)
+		}
+		if r.Unpinned && (r.License != "" || r.MaxAge != "") {
+			return fmt.Errorf("policy %s mixes unpinned with license or max-age", r.Name)
+		}
+		if r.MaxAge != "" && !policyAgePattern.MatchString(r.MaxAge) {
+			return fmt.Errorf("invalid max-age for policy %s: %s (expected e.g. 5y, 18m, 6w or 90d)", r.Name, r.MaxAge)
+		}
+		severity, err := ParseSeverityThreshold(r.Severity)
+		if err != nil {
+			return fmt.Errorf("policy %s: %v", r.Name, err)
+		}
+		r.Severity = severity
+	}
+	return nil
+}
+
+// LoadPolicyFile reads policy rules from a YAML file
+func LoadPolicyFile(path string) ([]PolicyRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy file: %v", err)
+	}
+
+	var file policyFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse policy file %s: %v", path, err)
+	}
+	return file.Policies, nil
+}
+
+// packageMatches compares a package name with a pattern where * matches any characters
+func packageMatches(pattern, name string) bool {
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
+	matched, _ := regexp.MatchString("(?i)"+expr, name)
+	return matched
+}
+
+// releaseDates looks up and caches the publication dates of package versions
+type releaseDates struct {
+	ctx    context.Context // of the run, for the timings of the lookups
+	dates  map[string]time.Time
+	failed int
+}
+
+// lookup returns the release date of the component, false when it is unknown
+func (d *releaseDates) lookup(c maven.ComponentLicense) (time.Time, bool) {
+	if date, ok := d.dates[c.PURL]; ok {
+		return date, !date.IsZero()
+	}
+
+	if runenv.SettingsOf(d.ctx).Offline {
+		return time.Time{}, false
+	}
+
+	date, err := fetchReleaseDate(d.ctx, c)
+	if err != nil {
+		d.failed++
+	}
+	d.dates[c.PURL] = date
+	return date, !date.IsZero()
+}
+
+// fetchReleaseDate queries deps.dev for the publication date of a package version
+func fetchReleaseDate(ctx context.Context, c maven.ComponentLicense) (time.Time, error) {
+	purlType, _, _ := strings.Cut(strings.TrimPrefix(c.PURL, "pkg:"), "/")
+	system, ok := depsDevSystems[purlType]
+	if !ok || c.Version == "" {
+		return time.Time{}, nil
+	}
+
+	endpoint := fmt.Sprintf("%s/systems/%s/packages/%s/versions/%s",
+		depsDevURL, system, url.PathEscape(c.Package), url.PathEscape(c.Version))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	resp, err := runenv.HTTPClient.Do(req)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return time.Time{}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("deps.dev returned %s", resp.Status)
+	}
+
+	var version struct {
+		PublishedAt time.Time `json:"publishedAt"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&version); err != nil {
+		return time.Time{}, err
+	}
+	return version.PublishedAt, nil
+}
+
+// evaluatePolicies applies the rules to the components and active findings.
+// Unpinned rules fire for the SNAPSHOTs and version ranges in unpinned.
+func evaluatePolicies(rules []PolicyRule, components []maven.ComponentLicense, findings []Finding, unpinned []maven.UnpinnedDependency, dates *releaseDates) []policyViolation {
+	now := time.Now()
+	var violations []policyViolation
+
+	for _, r := range rules {
+		violation := policyViolation{Rule: r.Name, Description: r.Description, Action: r.action()}
+
+		if r.appliesToFindings() {
+			for _, f := range findings {
+				if r.Package != "" && !packageMatches(r.Package, f.Package) {
+					continue
+				}
+				if r.Severity != "" && len(FindingsAtOrAbove([]Finding{f}, r.Severity)) == 0 {
+					continue
+				}
+				if r.FixAvailable != nil && *r.FixAvailable != (len(f.FixedVersions) > 0) {
+					continue
+				}
+
+				v := violation
+				v.Package, v.Version, v.ID = f.Package, f.Version, f.ID
+				v.Message = fmt.Sprintf("%s %s", f.Severity, f.ID)
+				if len(f.FixedVersions) > 0 {
+					v.Message += ", fixed in " + strings.Join(f.FixedVersions, ", ")
+				}
+				violations = append(violations, v)
+			}
+			continue
+		}
+
+		if r.Unpinned {
+			for _, u := range unpinned {
+				if r.Package != "" && !packageMatches(r.Package, u.Package) {
+					continue
+				}
+				v := violation
+				v.Package, v.Version = u.Package, u.Version
+				v.Message = u.Message
+				violations = append(violations, v)
+			}
+			continue
+		}
+
+		for _, c := range components {
+			if r.Package != "" && !packageMatches(r.Package, c.Package) {
+				continue
+			}
+
+			var reasons []string
+			if r.License != "" {
+				var denied []string
+				for _, license := range c.Licenses {
+					if licenseDenied(license, []string{r.License}) {
+						denied = append(denied, license)
+					}
+				}
+				if len(denied) == 0 {
+					continue
+				}
+				reasons = append(reasons, "license "+strings.Join(denied, ", "))
+			}
+			if r.MaxAge != "" {
+				released, ok := dates.lookup(c)
+				if !ok || !released.Before(r.cutoff(now)) {
+					continue
+				}
+				reasons = append(reasons, fmt.Sprintf("released %s, older than %s", released.Format("2006-01-02"), r.MaxAge))
+			}
+			if len(reasons) == 0 {
+				reasons = append(reasons, "package matches "+r.Package)
+			}
+
+			v := violation
+			v.Package, v.Version = c.Package, c.Version
+			v.Message = strings.Join(reasons, "; ")
+			violations = append(violations, v)
+		}
+	}
+	return violations
+}
+
+// policyPath returns where the policy results of an SBOM are written
+func policyPath(sbomPath string) string {
+	return filepath.Join(filepath.Dir(sbomPath), "sbom-policy.json")
+}
+
+// CheckPolicies evaluates the rules against the scan results next to the
+// SBOM, writes the violations and fails when a rule with the fail action fired
+func CheckPolicies(ctx context.Context, sbomPath string, rules []PolicyRule, ignoreRules []IgnoreRule) error {
+	report, err := maven.ReadLicenseReport(maven.LicensesPath(sbomPath))
+	if err != nil {
+		return err
+	}
+	findings, err := ReadFindings(FindingsPath(sbomPath))
+	if err != nil {
+		return err
+	}
+	active, _ := ApplySuppressions(findings, ignoreRules)
+	// Only Maven and Gradle modules are checked for unpinned versions
+	unpinned, _ := maven.ReadUnpinnedReport(maven.UnpinnedPath(sbomPath))
+
+	dates := &releaseDates{ctx: ctx, dates: make(map[string]time.Time)}
+	violations := evaluatePolicies(rules, report.Components, active, unpinned, dates)
+	if dates.failed > 0 {
+		runenv.Logger.Warnf("Could not look up the release dates of %d components", dates.failed)
+	}
+
+	if violations == nil {
+		violations = []policyViolation{}
+	}
+	data, err := json.MarshalIndent(violations, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode policy results: %v", err)
+	}
+	if err := os.WriteFile(policyPath(sbomPath), data, 0644); err != nil {
+		return fmt.Errorf("failed to write policy results: %v", err)
+	}
+
+	var fired []string
+	seen := make(map[string]bool)
+	for _, v := range violations {
+		runenv.Logger.Warnf("Policy %s violated by %s@%s: %s", v.Rule, v.Package, v.Version, v.Message)
+		if v.Action == "fail" && !seen[v.Rule] {
+			seen[v.Rule] = true
+			fired = append(fired, v.Rule)
+		}
+	}
+
+	if len(fired) > 0 {
+		return runenv.WithExitCode(fmt.Errorf("policy violations: %s (details: %s)", strings.Join(fired, ", "), policyPath(sbomPath)), runenv.ExitPolicy)
+	}
+	runenv.Logger.Infof("%d policy rules evaluated, %d violations", len(rules), len(violations))
+	return nil
+}
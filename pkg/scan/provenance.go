@@ -0,0 +1,46 @@
+package scan
+
+import (
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/maven"
+)
+
+// AddVersionProvenance adds to the Maven findings where the project sets the
+// version of the package or, for transitive packages, of the direct
+// dependency that brings it in. Only modules with a provenance report next
+// to the SBOM are annotated.
+func AddVersionProvenance(findingsPath, provenancePath string) error {
+	components, err := maven.ReadProvenanceReport(provenancePath)
+	if err != nil {
+		return nil
+	}
+	index := make(map[string]maven.VersionProvenance)
+	for _, c := range components {
+		if _, ok := index[c.Package]; !ok {
+			index[c.Package] = c.VersionProvenance
+		}
+	}
+
+	findings, err := ReadFindings(findingsPath)
+	if err != nil {
+		return err
+	}
+	for i, f := range findings {
+		findings[i].Provenance = nil
+		if f.Ecosystem != "Maven" {
+			continue
+		}
+		p, ok := index[f.Package]
+		if (!ok || p.Source == maven.VersionTransitive) && len(f.DependencyPath) > 1 {
+			direct, _, _ := runenv.CutLast(f.DependencyPath[0], "@")
+			if d, found := index[direct]; found && d.Source != maven.VersionTransitive {
+				d.Via = direct
+				p, ok = d, true
+			}
+		}
+		if ok {
+			findings[i].Provenance = &p
+		}
+	}
+	return writeFindings(findingsPath, findings)
+}
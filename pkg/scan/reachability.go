@@ -0,0 +1,533 @@
+package scan
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/maven"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+// Reachability of a finding from the project's bytecode
+const (
+	reachable   = "reachable"
+	Unreachable = "unreachable"
+)
+
+// Directories of the compiled main classes of Maven and Gradle projects
+var classesDirs = []string{
+	"target/classes",
+	"build/classes/java/main",
+	"build/classes/kotlin/main",
+	"build/classes/scala/main",
+	"build/classes/groovy/main",
+}
+
+// javaClass is the part of a class file the reachability analysis needs
+type javaClass struct {
+	name       string   // internal name, e.g. org/example/Foo
+	super      string   // empty for java/lang/Object
+	interfaces []string // implemented interfaces
+	references []string // classes in the constant pool
+	calls      []string // methods in the constant pool, as owner.name
+}
+
+// classFileReader reads the big-endian values of a class file
+type classFileReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *classFileReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.data) {
+		r.err = fmt.Errorf("truncated class file")
+		return nil
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *classFileReader) u1() int {
+	if b := r.bytes(1); b != nil {
+		return int(b[0])
+	}
+	return 0
+}
+
+func (r *classFileReader) u2() int {
+	if b := r.bytes(2); b != nil {
+		return int(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+// constantPoolEntry is a constant of a class file; a and b are the indices
+// of the other constants it refers to
+type constantPoolEntry struct {
+	tag  int
+	utf8 string
+	a, b int
+}
+
+// parseClassFile reads the name, supertypes and the classes and methods
+// referenced in the constant pool of a class file. The method bodies are not
+// decoded: every method and class in the constant pool is used by the class.
+func parseClassFile(data []byte) (javaClass, error) {
+	var c javaClass
+	r := &classFileReader{data: data}
+	if string(r.bytes(4)) != "\xca\xfe\xba\xbe" {
+		return c, fmt.Errorf("not a class file")
+	}
+	r.bytes(4) // minor and major version
+
+	count := r.u2()
+	pool := make([]constantPoolEntry, count)
+	for i := 1; i < count && r.err == nil; i++ {
+		e := constantPoolEntry{tag: r.u1()}
+		switch e.tag {
+		case 1: // Utf8, modified UTF-8 is the same as UTF-8 for class and method names
+			e.utf8 = string(r.bytes(r.u2()))
+		case 3, 4: // Integer, Float
+			r.bytes(4)
+		case 5, 6: // Long and Double take two entries
+			r.bytes(8)
+			i++
+		case 7, 8, 16, 19, 20: // Class, String, MethodType, Module, Package
+			e.a = r.u2()
+		case 9, 10, 11, 12, 17, 18: // Fieldref, Methodref, InterfaceMethodref, NameAndType, Dynamic, InvokeDynamic
+			e.a, e.b = r.u2(), r.u2()
+		case 15: // MethodHandle
+			r.u1()
+			e.a = r.u2()
+		default:
+			return c, fmt.Errorf("invalid constant pool tag %d", e.tag)
+		}
+		pool[i] = e
+	}
+	r.u2() // access flags
+	this, super := r.u2(), r.u2()
+	interfaces := make([]int, r.u2())
+	for i := range interfaces {
+		interfaces[i] = r.u2()
+	}
+	if r.err != nil {
+		return c, r.err
+	}
+
+	entry := func(index, tag int) (constantPoolEntry, bool) {
+		if index <= 0 || index >= len(pool) || pool[index].tag != tag {
+			return constantPoolEntry{}, false
+		}
+		return pool[index], true
+	}
+	utf8 := func(index int) string {
+		e, _ := entry(index, 1)
+		return e.utf8
+	}
+	className := func(index int) string {
+		e, _ := entry(index, 7)
+		return utf8(e.a)
+	}
+
+	c.name = className(this)
+	if c.name == "" {
+		return c, fmt.Errorf("invalid class name")
+	}
+	c.super = className(super)
+	for _, index := range interfaces {
+		if name := className(index); name != "" {
+			c.interfaces = append(c.interfaces, name)
+		}
+	}
+	for _, e := range pool {
+		switch e.tag {
+		case 7:
+			if name := elementClass(utf8(e.a)); name != "" && name != c.name {
+				c.references = append(c.references, name)
+			}
+		case 10, 11:
+			nameAndType, ok := entry(e.b, 12)
+			if owner := elementClass(className(e.a)); ok && owner != "" {
+				c.calls = append(c.calls, owner+"."+utf8(nameAndType.a))
+			}
+		}
+	}
+	return c, nil
+}
+
+// elementClass returns the class of a class constant, the element class of
+// arrays and nothing for arrays of primitives
+func elementClass(name string) string {
+	if !strings.HasPrefix(name, "[") {
+		return name
+	}
+	name = strings.TrimLeft(name, "[")
+	if strings.HasPrefix(name, "L") && strings.HasSuffix(name, ";") {
+		return name[1 : len(name)-1]
+	}
+	return ""
+}
+
+// classpath locates the classes of the project and its dependencies
+type classpath struct {
+	project   map[string]string    // class name to class file
+	jars      map[string]*zip.File // class name to JAR entry
+	artifacts map[string]string    // class name to the package@version of its JAR
+	services  map[string][]string  // service interface to the implementations in META-INF/services
+	readers   []*zip.ReadCloser
+}
+
+func (cp *classpath) Close() {
+	for _, r := range cp.readers {
+		r.Close()
+	}
+}
+
+// addClasses adds the class files below a directory of compiled classes
+func (cp *classpath) addClasses(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".class") {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		cp.project[strings.TrimSuffix(filepath.ToSlash(rel), ".class")] = path
+		return nil
+	})
+}
+
+// addJAR adds the classes and service declarations of a dependency
+func (cp *classpath) addJAR(path, artifact string) error {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	cp.readers = append(cp.readers, reader)
+	for _, f := range reader.File {
+		name := f.Name
+		switch {
+		case strings.HasPrefix(name, "META-INF/services/") && !strings.HasSuffix(name, "/"):
+			implementations, err := readServiceFile(f)
+			if err != nil {
+				return err
+			}
+			service := strings.ReplaceAll(strings.TrimPrefix(name, "META-INF/services/"), ".", "/")
+			cp.services[service] = append(cp.services[service], implementations...)
+		case strings.HasSuffix(name, ".class") && !strings.HasSuffix(name, "module-info.class"):
+			// Multi-release JARs keep the classes for newer Java versions apart
+			if strings.HasPrefix(name, "META-INF/versions/") {
+				parts := strings.SplitN(name, "/", 4)
+				if len(parts) < 4 {
+					continue
+				}
+				name = parts[3]
+			}
+			class := strings.TrimSuffix(name, ".class")
+			if _, ok := cp.jars[class]; !ok {
+				cp.jars[class] = f
+				cp.artifacts[class] = artifact
+			}
+		}
+	}
+	return nil
+}
+
+// readServiceFile returns the implementations listed in a service declaration
+func readServiceFile(f *zip.File) ([]string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var implementations []string
+	scanner := bufio.NewScanner(rc)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			implementations = append(implementations, strings.ReplaceAll(line, ".", "/"))
+		}
+	}
+	return implementations, scanner.Err()
+}
+
+// load parses a class of the project or a dependency
+func (cp *classpath) load(name string) (javaClass, bool) {
+	var data []byte
+	var err error
+	if path, ok := cp.project[name]; ok {
+		data, err = os.ReadFile(path)
+	} else if f, ok := cp.jars[name]; ok {
+		var rc io.ReadCloser
+		if rc, err = f.Open(); err == nil {
+			data, err = io.ReadAll(rc)
+			rc.Close()
+		}
+	} else {
+		return javaClass{}, false
+	}
+	if err != nil {
+		runenv.Logger.Warnf("Failed to read class %s: %v", name, err)
+		return javaClass{}, false
+	}
+	c, err := parseClassFile(data)
+	if err != nil {
+		runenv.Logger.Warnf("Failed to parse class %s: %v", name, err)
+		return javaClass{}, false
+	}
+	return c, true
+}
+
+// reachableCode is the part of the classpath used from the project's classes
+type reachableCode struct {
+	classes   map[string]javaClass
+	calls     map[string]bool // methods called from reachable classes
+	artifacts map[string]bool // dependencies with a reachable class
+}
+
+// walk follows the class references from every class of the project. Classes
+// loaded by reflection are not followed, the implementations of services
+// are once their interface is reachable.
+func (cp *classpath) walk() reachableCode {
+	code := reachableCode{classes: make(map[string]javaClass), calls: make(map[string]bool), artifacts: make(map[string]bool)}
+	seen := make(map[string]bool)
+	var queue []string
+	enqueue := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			queue = append(queue, name)
+		}
+	}
+	for name := range cp.project {
+		enqueue(name)
+	}
+
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		c, ok := cp.load(name)
+		if !ok {
+			continue
+		}
+		code.classes[name] = c
+		if artifact, ok := cp.artifacts[name]; ok {
+			code.artifacts[artifact] = true
+		}
+		for _, call := range c.calls {
+			code.calls[call] = true
+		}
+		if c.super != "" {
+			enqueue(c.super)
+		}
+		for _, names := range [][]string{c.interfaces, c.references, cp.services[name]} {
+			for _, ref := range names {
+				enqueue(ref)
+			}
+		}
+	}
+	return code
+}
+
+// supertypes returns a reachable class and its reachable superclasses and
+// interfaces
+func (code reachableCode) supertypes(name string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	queue := []string{name}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		c, ok := code.classes[name]
+		if !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		result = append(result, name)
+		queue = append(append(queue, c.super), c.interfaces...)
+	}
+	return result
+}
+
+// reaches reports whether a vulnerable symbol is used: a class when it is
+// reachable, a method when it is called on its class or on a supertype of it
+func (code reachableCode) reaches(symbol vulnerableSymbol) bool {
+	if _, ok := code.classes[symbol.class]; !ok {
+		return false
+	}
+	if symbol.method == "" {
+		return true
+	}
+	for _, owner := range code.supertypes(symbol.class) {
+		if code.calls[owner+"."+symbol.method] {
+			return true
+		}
+	}
+	return false
+}
+
+// vulnerableSymbol is a class or method named by an advisory
+type vulnerableSymbol struct {
+	class  string // internal name
+	method string // empty for the whole class
+}
+
+// parseVulnerableSymbol reads org.example.Foo, org.example.Foo.bar,
+// org.example.Foo#bar or org.example.Foo::bar; a last segment starting with
+// a lowercase letter is a method
+func parseVulnerableSymbol(s string) (vulnerableSymbol, bool) {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "(")
+	var class, method string
+	switch {
+	case strings.Contains(s, "#"):
+		class, method, _ = strings.Cut(s, "#")
+	case strings.Contains(s, "::"):
+		class, method, _ = strings.Cut(s, "::")
+	default:
+		class = s
+		if i := strings.LastIndex(s, "."); i >= 0 && s[i+1:] != "" && unicode.IsLower(rune(s[i+1])) {
+			class, method = s[:i], s[i+1:]
+		}
+	}
+	if class == "" {
+		return vulnerableSymbol{}, false
+	}
+	return vulnerableSymbol{class: strings.ReplaceAll(class, ".", "/"), method: method}, true
+}
+
+// advisorySymbols returns the vulnerable classes and methods the OSV entry of
+// a finding lists in affected_functions, nil when it names none
+func advisorySymbols(ctx context.Context, f Finding) []vulnerableSymbol {
+	if runenv.SettingsOf(ctx).Offline {
+		return nil
+	}
+	for _, id := range f.IDs() {
+		vuln, err := fetchOSVVulnerability(ctx, id)
+		if err != nil {
+			continue
+		}
+		var symbols []vulnerableSymbol
+		for _, affected := range vuln.Affected {
+			if affected.Package.Name != "" && affected.Package.Name != f.Package {
+				continue
+			}
+			functions, _ := affected.EcosystemSpecific["affected_functions"].([]interface{})
+			for _, function := range functions {
+				if s, ok := function.(string); ok {
+					if symbol, ok := parseVulnerableSymbol(s); ok {
+						symbols = append(symbols, symbol)
+					}
+				}
+			}
+		}
+		return symbols
+	}
+	return nil
+}
+
+// AnalyzeReachability tags the Maven findings of a Java project as reachable
+// or unreachable from its compiled classes. The classes referenced from the
+// project are followed through the JARs of the dependencies in the local
+// Maven repository or Gradle cache. A finding is reachable when a vulnerable
+// class or method of its advisory is used, or, when the advisory names none,
+// when any class of the vulnerable dependency is. Findings of dependencies
+// whose JAR is not found stay untagged.
+func AnalyzeReachability(ctx context.Context, findingsPath, sbomPath, projectDir string) error {
+	findings, err := ReadFindings(findingsPath)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(findings, func(f Finding) bool { return f.Ecosystem == "Maven" }) {
+		return nil
+	}
+
+	cp := &classpath{
+		project:   make(map[string]string),
+		jars:      make(map[string]*zip.File),
+		artifacts: make(map[string]string),
+		services:  make(map[string][]string),
+	}
+	defer cp.Close()
+
+	var dirs []string
+	for _, dir := range classesDirs {
+		dir = filepath.Join(projectDir, filepath.FromSlash(dir))
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			if err := cp.addClasses(dir); err != nil {
+				return fmt.Errorf("failed to read classes in %s: %v", dir, err)
+			}
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(cp.project) == 0 {
+		runenv.Logger.Warnf("No compiled classes in %s, build the project before the scan to analyze the reachability of findings", projectDir)
+		return nil
+	}
+
+	components, err := sbom.ReadCycloneDX(sbomPath)
+	if err != nil {
+		return err
+	}
+	resolved := make(map[string]bool)
+	for _, c := range components {
+		pkg, ok := sbom.PackageFromPURL(c.PURL)
+		if !ok || pkg.Ecosystem != "Maven" {
+			continue
+		}
+		for _, path := range maven.ArtifactPaths(ctx, c.PURL) {
+			if err := cp.addJAR(path, pkg.Name+"@"+pkg.Version); err == nil {
+				resolved[pkg.Name+"@"+pkg.Version] = true
+				break
+			} else if !os.IsNotExist(err) {
+				runenv.Logger.Warnf("Failed to read %s: %v", path, err)
+			}
+		}
+	}
+
+	code := cp.walk()
+	runenv.Logger.Infof("%d of %d classes are reachable from the %d classes in %s",
+		len(code.classes), len(cp.project)+len(cp.jars), len(cp.project), strings.Join(dirs, ", "))
+
+	counts := make(map[string]int)
+	for i, f := range findings {
+		artifact := f.Package + "@" + f.Version
+		if f.Ecosystem != "Maven" || !resolved[artifact] {
+			continue
+		}
+		findings[i].Reachability = Unreachable
+		if symbols := advisorySymbols(ctx, f); len(symbols) > 0 {
+			for _, symbol := range symbols {
+				if code.reaches(symbol) {
+					findings[i].Reachability = reachable
+					break
+				}
+			}
+		} else if code.artifacts[artifact] {
+			findings[i].Reachability = reachable
+		}
+		counts[findings[i].Reachability]++
+	}
+
+	if err := writeFindings(findingsPath, findings); err != nil {
+		return err
+	}
+	runenv.Logger.Infof("%d findings are reachable, %d unreachable from the project's code", counts[reachable], counts[Unreachable])
+	return nil
+}
@@ -0,0 +1,183 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/maven"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+// Newer releases of a direct dependency resolved when looking for one that
+// brings a fixed transitive version
+const maxUpgradeCandidates = 20
+
+// Remediation is the smallest upgrade that removes a vulnerability
+type Remediation struct {
+	Package string `json:"package"` // dependency to upgrade, the direct dependency when that fixes it
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Note    string `json:"note,omitempty"`
+}
+
+func (r Remediation) String() string {
+	s := fmt.Sprintf("upgrade %s from %s to %s", r.Package, r.From, r.To)
+	if r.Note != "" {
+		s += " (" + r.Note + ")"
+	}
+	return s
+}
+
+// minimalFix returns the lowest fixed version above the affected version
+func minimalFix(f Finding) string {
+	var fix string
+	for _, v := range f.FixedVersions {
+		if sbom.CompareVersions(v, f.Version) <= 0 {
+			continue
+		}
+		if fix == "" || sbom.CompareVersions(v, fix) < 0 {
+			fix = v
+		}
+	}
+	return fix
+}
+
+// isPreRelease reports whether the version has a qualifier such as -rc1 or -SNAPSHOT
+func isPreRelease(version string) bool {
+	for _, segment := range sbom.VersionSegments(version) {
+		if _, err := strconv.Atoi(segment); err != nil && sbom.ExtraSegmentOrder(segment) < 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// remediator suggests upgrades, caching the Maven Central lookups
+type remediator struct {
+	resolver *maven.POMResolver // nil once a Maven Central lookup failed
+	upgrades map[string]string
+}
+
+func newRemediator(ctx context.Context) *remediator {
+	r := &remediator{upgrades: make(map[string]string)}
+	if !runenv.SettingsOf(ctx).Offline {
+		r.resolver = maven.NewPOMResolver(ctx)
+	}
+	return r
+}
+
+// remediate returns the upgrade that removes the finding, nil when there is no fix.
+// Transitive Maven dependencies (see TraceDependencyPaths) are fixed by upgrading the direct dependency
+// when one of its newer releases brings a fixed version.
+func (r *remediator) remediate(f Finding) *Remediation {
+	fix := minimalFix(f)
+	if fix == "" {
+		return nil
+	}
+	remediation := &Remediation{Package: f.Package, From: f.Version, To: fix}
+
+	if len(f.DependencyPath) < 2 {
+		return remediation
+	}
+
+	name, version, _ := runenv.CutLast(f.DependencyPath[0], "@")
+	direct := &sbom.TreeNode{Package: name, Version: version}
+	if f.Ecosystem == "Maven" {
+		if version := r.mavenUpgrade(direct, f.Package, fix); version != "" {
+			return &Remediation{
+				Package: direct.Package,
+				From:    direct.Version,
+				To:      version,
+				Note:    fmt.Sprintf("brings %s %s or later", f.Package, fix),
+			}
+		}
+	}
+
+	remediation.Note = fmt.Sprintf("pulled in by %s %s, override its version", direct.Package, direct.Version)
+	return remediation
+}
+
+// mavenUpgrade finds the oldest release of the direct dependency whose
+// dependencies no longer include an affected version of the package
+func (r *remediator) mavenUpgrade(direct *sbom.TreeNode, pkg, fix string) string {
+	key := direct.Package + "@" + direct.Version + " " + pkg + "@" + fix
+	if version, ok := r.upgrades[key]; ok {
+		return version
+	}
+	if r.resolver == nil {
+		return ""
+	}
+
+	groupID, artifactID, _ := strings.Cut(direct.Package, ":")
+	versions, err := r.resolver.Versions(groupID, artifactID)
+	if err != nil {
+		runenv.Logger.Warnf("Not looking for upgrades of direct dependencies: %v", err)
+		r.resolver = nil
+		return ""
+	}
+
+	var candidates []string
+	for _, v := range versions {
+		if sbom.CompareVersions(v, direct.Version) > 0 && !isPreRelease(v) {
+			candidates = append(candidates, v)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return sbom.CompareVersions(candidates[i], candidates[j]) < 0
+	})
+	if len(candidates) > maxUpgradeCandidates {
+		candidates = candidates[:maxUpgradeCandidates]
+	}
+
+	upgrade := ""
+	for _, v := range candidates {
+		project := &maven.POMProject{Dependencies: []maven.POMDependency{{GroupID: groupID, ArtifactID: artifactID, Version: v}}}
+		if version, found := depVersion(r.resolver.ResolveTree(project), pkg); !found || sbom.CompareVersions(version, fix) >= 0 {
+			upgrade = v
+			break
+		}
+	}
+
+	r.upgrades[key] = upgrade
+	return upgrade
+}
+
+// depVersion returns the version of the package in a resolved tree
+func depVersion(nodes []*maven.DepNode, pkg string) (string, bool) {
+	for _, node := range nodes {
+		if node.Key() == pkg {
+			return node.Version, true
+		}
+		if version, found := depVersion(node.Children, pkg); found {
+			return version, true
+		}
+	}
+	return "", false
+}
+
+// SuggestRemediations adds the remediation of every finding to the findings file
+func SuggestRemediations(ctx context.Context, findingsPath string) error {
+	findings, err := ReadFindings(findingsPath)
+	if err != nil {
+		return err
+	}
+
+	r := newRemediator(ctx)
+	count := 0
+	for i := range findings {
+		findings[i].Remediation = r.remediate(findings[i])
+		if findings[i].Remediation != nil {
+			count++
+		}
+	}
+
+	if err := writeFindings(findingsPath, findings); err != nil {
+		return err
+	}
+	runenv.Logger.Infof("Suggested upgrades for %d of %d findings", count, len(findings))
+	return nil
+}
@@ -0,0 +1,564 @@
+// Package scan matches SBOMs against the vulnerability databases, enriches
+// and suppresses the findings and holds the findings model.
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+// scannerBackend scans a CycloneDX SBOM. It writes the scanner's own output to
+// rawPath and returns the findings normalized into the common model, so that
+// reports and thresholds behave the same for every backend.
+type scannerBackend struct {
+	Tool      string // executable the backend needs, empty for none
+	rawSuffix string // appended to the SBOM base name to form rawPath
+	scan      func(ctx context.Context, sbomPath, rawPath string) ([]Finding, error)
+}
+
+var ScannerBackends = map[string]scannerBackend{
+	"osv":        {rawSuffix: "-vulnerabilities.json", scan: osvBackend(scanOSVAPI)},
+	"osv-binary": {Tool: "osv-scanner", rawSuffix: "-vulnerabilities.json", scan: osvBackend(runOSVScanner)},
+	"grype":      {Tool: "grype", rawSuffix: "-grype.json", scan: scanGrype},
+	"trivy":      {Tool: "trivy", rawSuffix: "-trivy.json", scan: scanTrivy},
+}
+
+// ParseScanners validates the --scanner value: a backend name, a
+// comma-separated list, or "all" for every installed backend. osv-binary is
+// left out of "all" because it queries the same database as osv.
+func ParseScanners(s *runenv.Settings, value string) ([]string, error) {
+	if strings.TrimSpace(value) == "all" {
+		var scanners []string
+		for _, name := range []string{"osv", "grype", "trivy"} {
+			if tool := ScannerBackends[name].Tool; tool != "" {
+				if _, err := exec.LookPath(s.ToolPath(tool)); err != nil {
+					runenv.Logger.Warnf("Skipping %s scanner: %s is not installed", name, tool)
+					continue
+				}
+			}
+			scanners = append(scanners, name)
+		}
+		return scanners, nil
+	}
+
+	var scanners []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		if _, ok := ScannerBackends[name]; !ok {
+			return nil, fmt.Errorf("unknown scanner: %s", name)
+		}
+		seen[name] = true
+		scanners = append(scanners, name)
+	}
+
+	if len(scanners) == 0 {
+		return nil, fmt.Errorf("no scanner selected")
+	}
+	if seen["osv"] && seen["osv-binary"] {
+		return nil, fmt.Errorf("osv and osv-binary cannot be combined, both use the OSV database")
+	}
+	if s.Offline && seen["osv-binary"] {
+		return nil, fmt.Errorf("osv-binary is not supported with --offline, use --scanner=osv with the offline OSV database")
+	}
+	return scanners, nil
+}
+
+// scanWithBackend runs a single backend on the SBOM
+func scanWithBackend(ctx context.Context, name, sbomPath string) ([]Finding, error) {
+	backend := ScannerBackends[name]
+	if backend.Tool != "" {
+		if _, err := exec.LookPath(runenv.SettingsOf(ctx).ToolPath(backend.Tool)); err != nil {
+			return nil, fmt.Errorf("%s is not installed: %v", backend.Tool, err)
+		}
+	}
+
+	rawPath := rawReportPath(sbomPath, backend.rawSuffix)
+	findings, err := backend.scan(ctx, sbomPath, rawPath)
+	if err != nil {
+		return nil, fmt.Errorf("%s scanner: %v", name, err)
+	}
+	return findings, nil
+}
+
+// mergeScannerResults consolidates the findings of several backends. Findings
+// for the same package are deduplicated by ID and alias, and each one records
+// the scanners that reported it.
+func mergeScannerResults(scanners []string, results [][]Finding) []Finding {
+	var all []Finding
+	for i, name := range scanners {
+		runenv.Logger.Infof("%s reported %d vulnerabilities", name, len(results[i]))
+		for _, f := range results[i] {
+			f.Scanners = []string{name}
+			all = append(all, f)
+		}
+	}
+
+	merged := mergeFindings(all)
+	SortFindings(merged)
+	return merged
+}
+
+// osvBackend adapts a scanner that writes an OSV report
+func osvBackend(scan func(ctx context.Context, sbomPath, outputPath string) (bool, error)) func(context.Context, string, string) ([]Finding, error) {
+	return func(ctx context.Context, sbomPath, rawPath string) ([]Finding, error) {
+		if _, err := scan(ctx, sbomPath, rawPath); err != nil {
+			return nil, err
+		}
+		report, err := readOSVReport(rawPath)
+		if err != nil {
+			return nil, err
+		}
+		return findingsFromReport(report), nil
+	}
+}
+
+// FindingsPath returns the path of the normalized findings written next to the SBOM
+func FindingsPath(sbomPath string) string {
+	return strings.TrimSuffix(sbomPath, ".xml") + "-findings.json"
+}
+
+type grypeReport struct {
+	Matches []grypeMatch `json:"matches"`
+}
+
+type grypeMatch struct {
+	Vulnerability          grypeVulnerability   `json:"vulnerability"`
+	RelatedVulnerabilities []grypeVulnerability `json:"relatedVulnerabilities"`
+	Artifact               grypeArtifact        `json:"artifact"`
+}
+
+type grypeVulnerability struct {
+	ID          string      `json:"id"`
+	DataSource  string      `json:"dataSource"`
+	Severity    string      `json:"severity"`
+	Description string      `json:"description"`
+	URLs        []string    `json:"urls"`
+	CVSS        []grypeCVSS `json:"cvss"`
+	Fix         struct {
+		Versions []string `json:"versions"`
+	} `json:"fix"`
+}
+
+type grypeCVSS struct {
+	Version string `json:"version"`
+	Vector  string `json:"vector"`
+	Metrics struct {
+		BaseScore float64 `json:"baseScore"`
+	} `json:"metrics"`
+}
+
+type grypeArtifact struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	PURL    string `json:"purl"`
+}
+
+// scanGrype scans the SBOM with the grype binary
+func scanGrype(ctx context.Context, sbomPath, rawPath string) ([]Finding, error) {
+	s := runenv.SettingsOf(ctx)
+	outputFile, err := os.Create(rawPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %v", err)
+	}
+	defer outputFile.Close()
+
+	cmd := runenv.Command(ctx, s.ToolPath("grype"), "sbom:"+sbomPath, "-o", "json", "-q")
+	if s.Offline {
+		// Use the installed DB as it is instead of checking for updates
+		cmd.Env = append(cmd.Environ(), "GRYPE_DB_AUTO_UPDATE=false", "GRYPE_DB_VALIDATE_AGE=false", "GRYPE_CHECK_FOR_APP_UPDATE=false")
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stdout = outputFile
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("grype error: %v\n%s", err, stderr.String())
+	}
+
+	data, err := os.ReadFile(rawPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read grype report: %v", err)
+	}
+
+	var report grypeReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse grype report: %v", err)
+	}
+	return findingsFromGrype(report, sbomPath), nil
+}
+
+// findingsFromGrype normalizes grype matches. Severities follow the same
+// precedence as for OSV: CVSS v3, then the advisory label, then CVSS v2.
+func findingsFromGrype(report grypeReport, source string) []Finding {
+	var findings []Finding
+
+	for _, m := range report.Matches {
+		f := Finding{
+			Package: m.Artifact.Name,
+			Version: m.Artifact.Version,
+			URL:     m.Vulnerability.DataSource,
+			Summary: m.Vulnerability.Description,
+			Source:  source,
+		}
+		if pkg, ok := sbom.PackageFromPURL(m.Artifact.PURL); ok {
+			f.Package, f.Ecosystem = pkg.Name, pkg.Ecosystem
+		}
+
+		vulns := append([]grypeVulnerability{m.Vulnerability}, m.RelatedVulnerabilities...)
+		ids := make(map[string]bool)
+		references := make(map[string]bool)
+		for _, v := range vulns {
+			ids[v.ID] = true
+			for _, url := range v.URLs {
+				references[url] = true
+			}
+			if f.Summary == "" {
+				f.Summary = v.Description
+			}
+		}
+
+		allIDs := sortedKeys(ids)
+		f.ID = primaryID(allIDs)
+		for _, id := range allIDs {
+			if id != f.ID {
+				f.Aliases = append(f.Aliases, id)
+			}
+		}
+		f.Severity, f.Score = grypeSeverity(vulns)
+		f.FixedVersions = m.Vulnerability.Fix.Versions
+		f.References = sortedKeys(references)
+
+		findings = append(findings, f)
+	}
+
+	findings = mergeFindings(findings)
+	SortFindings(findings)
+	return findings
+}
+
+func grypeSeverity(vulns []grypeVulnerability) (string, float64) {
+	var v4, v3, v2 float64
+	for _, v := range vulns {
+		for _, c := range v.CVSS {
+			score := c.Metrics.BaseScore
+			switch {
+			case strings.HasPrefix(c.Version, "3"):
+				if computed, err := cvss3BaseScore(c.Vector); err == nil {
+					score = computed
+				}
+				if score > v3 {
+					v3 = score
+				}
+			case strings.HasPrefix(c.Version, "4"):
+				if computed, err := cvss4Score(c.Vector); err == nil {
+					score = computed
+				}
+				v4 = max(v4, score)
+			case strings.HasPrefix(c.Version, "2"):
+				if computed, err := cvss2BaseScore(c.Vector); err == nil {
+					score = computed
+				}
+				if score > v2 {
+					v2 = score
+				}
+			}
+		}
+	}
+
+	if v3 > 0 {
+		return cvssSeverity(v3), v3
+	}
+	if v4 > 0 {
+		return cvssSeverity(v4), v4
+	}
+	for _, v := range vulns {
+		label := v.Severity
+		if strings.EqualFold(label, "Negligible") {
+			label = SeverityLow
+		}
+		if severity := normalizeSeverity(label); severity != SeverityUnknown {
+			return severity, 0
+		}
+	}
+	if v2 > 0 {
+		return cvss2Severity(v2), v2
+	}
+	return SeverityUnknown, 0
+}
+
+type trivyReport struct {
+	Results []struct {
+		Target          string               `json:"Target"`
+		Vulnerabilities []trivyVulnerability `json:"Vulnerabilities"`
+	} `json:"Results"`
+}
+
+type trivyVulnerability struct {
+	VulnerabilityID string   `json:"VulnerabilityID"`
+	VendorIDs       []string `json:"VendorIDs"`
+	PkgName         string   `json:"PkgName"`
+	PkgIdentifier   struct {
+		PURL string `json:"PURL"`
+	} `json:"PkgIdentifier"`
+	InstalledVersion string                    `json:"InstalledVersion"`
+	FixedVersion     string                    `json:"FixedVersion"`
+	Title            string                    `json:"Title"`
+	Description      string                    `json:"Description"`
+	Severity         string                    `json:"Severity"`
+	PrimaryURL       string                    `json:"PrimaryURL"`
+	References       []string                  `json:"References"`
+	CVSS             map[string]trivyCVSSScore `json:"CVSS"`
+}
+
+type trivyCVSSScore struct {
+	V2Vector  string  `json:"V2Vector"`
+	V3Vector  string  `json:"V3Vector"`
+	V40Vector string  `json:"V40Vector"`
+	V2Score   float64 `json:"V2Score"`
+	V3Score   float64 `json:"V3Score"`
+	V40Score  float64 `json:"V40Score"`
+}
+
+// scanTrivy scans the SBOM with the trivy binary
+func scanTrivy(ctx context.Context, sbomPath, rawPath string) ([]Finding, error) {
+	s := runenv.SettingsOf(ctx)
+	args := []string{"sbom", "--format", "json", "--quiet", "--output", rawPath}
+	if s.TrivyCacheDir != "" {
+		args = append(args, "--cache-dir", s.TrivyCacheDir)
+	}
+	if s.Offline {
+		args = append(args, "--skip-db-update", "--skip-java-db-update", "--offline-scan")
+	}
+	args = append(args, sbomPath)
+
+	cmd := runenv.Command(ctx, s.ToolPath("trivy"), args...)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("trivy error: %v\n%s", err, stderr.String())
+	}
+
+	data, err := os.ReadFile(rawPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trivy report: %v", err)
+	}
+
+	var report trivyReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse trivy report: %v", err)
+	}
+	return findingsFromTrivy(report, sbomPath), nil
+}
+
+// findingsFromTrivy normalizes trivy results with the same severity precedence
+// as the other backends: CVSS v3, then the advisory label, then CVSS v2
+func findingsFromTrivy(report trivyReport, source string) []Finding {
+	var findings []Finding
+
+	for _, result := range report.Results {
+		for _, v := range result.Vulnerabilities {
+			ids := append([]string{v.VulnerabilityID}, v.VendorIDs...)
+			f := Finding{
+				ID:         primaryID(ids),
+				Summary:    v.Title,
+				Package:    v.PkgName,
+				Version:    v.InstalledVersion,
+				URL:        v.PrimaryURL,
+				References: v.References,
+				Source:     source,
+			}
+			if pkg, ok := sbom.PackageFromPURL(v.PkgIdentifier.PURL); ok {
+				f.Package, f.Ecosystem = pkg.Name, pkg.Ecosystem
+			}
+			for _, id := range ids {
+				if id != f.ID {
+					f.Aliases = append(f.Aliases, id)
+				}
+			}
+			if f.Summary == "" {
+				f.Summary = v.Description
+			}
+			for _, fixed := range strings.Split(v.FixedVersion, ",") {
+				if fixed = strings.TrimSpace(fixed); fixed != "" {
+					f.FixedVersions = append(f.FixedVersions, fixed)
+				}
+			}
+			f.Severity, f.Score = trivySeverity(v)
+
+			findings = append(findings, f)
+		}
+	}
+
+	findings = mergeFindings(findings)
+	SortFindings(findings)
+	return findings
+}
+
+func trivySeverity(v trivyVulnerability) (string, float64) {
+	var v4, v3, v2 float64
+	for _, score := range v.CVSS {
+		s3 := score.V3Score
+		if computed, err := cvss3BaseScore(score.V3Vector); err == nil {
+			s3 = computed
+		}
+		v3 = max(v3, s3)
+
+		s4 := score.V40Score
+		if computed, err := cvss4Score(score.V40Vector); err == nil {
+			s4 = computed
+		}
+		v4 = max(v4, s4)
+
+		s2 := score.V2Score
+		if computed, err := cvss2BaseScore(score.V2Vector); err == nil {
+			s2 = computed
+		}
+		v2 = max(v2, s2)
+	}
+
+	if v3 > 0 {
+		return cvssSeverity(v3), v3
+	}
+	if v4 > 0 {
+		return cvssSeverity(v4), v4
+	}
+	if severity := normalizeSeverity(v.Severity); severity != SeverityUnknown {
+		return severity, 0
+	}
+	if v2 > 0 {
+		return cvss2Severity(v2), v2
+	}
+	return SeverityUnknown, 0
+}
+
+// VulnerabilityReportPath returns the path of the JSON report written next to the SBOM
+func VulnerabilityReportPath(sbomPath string) string {
+	return strings.TrimSuffix(sbomPath, filepath.Ext(sbomPath)) + "-vulnerabilities.json"
+}
+
+// RunVulnerabilityScan scans the SBOM with the selected scanner backends and
+// writes the normalized findings next to it. Several backends run
+// concurrently and their findings are merged. Results of a previous scan of
+// the same components are reused while they are younger than ResultCacheTTL.
+// It reports whether vulnerabilities were found.
+func RunVulnerabilityScan(ctx context.Context, scanners []string, sbomPath string) (bool, error) {
+	// Mutlak yolu al
+	absSbomPath, err := filepath.Abs(sbomPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to get absolute path: %v", err)
+	}
+
+	// Dosyanın varlığını kontrol et
+	if _, err := os.Stat(absSbomPath); os.IsNotExist(err) {
+		return false, fmt.Errorf("SBOM file not found: %s", absSbomPath)
+	}
+
+	var cacheKey string
+	var findings []Finding
+	cached := false
+	if runenv.SettingsOf(ctx).ResultCacheTTL > 0 {
+		if cacheKey, err = sbomCacheKey(ctx, absSbomPath, scanners); err != nil {
+			return false, err
+		}
+		findings, cached = cachedScan(ctx, cacheKey, scanners, absSbomPath)
+	}
+
+	if !cached {
+		results := make([][]Finding, len(scanners))
+		errs := make([]error, len(scanners))
+
+		var wg sync.WaitGroup
+		for i, name := range scanners {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i], errs[i] = scanWithBackend(ctx, name, absSbomPath)
+			}()
+		}
+		wg.Wait()
+
+		if err := errors.Join(errs...); err != nil {
+			return false, err
+		}
+
+		findings = results[0]
+		if len(scanners) > 1 {
+			findings = mergeScannerResults(scanners, results)
+		}
+
+		if cacheKey != "" {
+			if err := storeScan(ctx, cacheKey, scanners, absSbomPath, findings); err != nil {
+				runenv.Logger.Warnf("Failed to cache scan results: %v", err)
+			}
+		}
+	}
+
+	outputPath := FindingsPath(sbomPath)
+	if err := writeFindings(outputPath, findings); err != nil {
+		return false, err
+	}
+
+	if len(findings) > 0 {
+		runenv.Logger.Warnf("Vulnerabilities found! Details: %s", outputPath)
+		return true, nil
+	}
+
+	runenv.Logger.Infof("Vulnerability report written to %s", outputPath)
+	return false, nil
+}
+
+// runOSVScanner scans the SBOM with the osv-scanner binary
+func runOSVScanner(ctx context.Context, sbomPath, outputPath string) (bool, error) {
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to create output file: %v", err)
+	}
+	defer outputFile.Close()
+
+	cmd := runenv.Command(ctx, runenv.SettingsOf(ctx).ToolPath("osv-scanner"),
+		"--sbom", sbomPath,
+		"--format", "json")
+
+	cmd.Stdout = outputFile
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Run()
+
+	// Vulnerability found (exit status 1)
+	if isExitStatus1(err) {
+		return true, nil
+	}
+
+	// Other errors
+	if err != nil {
+		return false, fmt.Errorf("osv-scanner error: %v", err)
+	}
+
+	return false, nil
+}
+
+// Check for exit status 1
+func isExitStatus1(err error) bool {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode() == 1
+	}
+	return false
+}
@@ -0,0 +1,258 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+// Files larger than this are not scanned for secrets, they are rarely
+// written by hand
+const maxSecretFileSize = 1 << 20
+
+// Lines with this marker are not reported, e.g. for test fixtures
+const secretAllowMarker = "sbom-scanner:allow-secret"
+
+// secretRule detects one kind of secret. The secret is the first submatch of
+// pattern and is redacted; patterns without one match a marker such as the
+// header of a private key, which is kept as is.
+type secretRule struct {
+	id          string
+	description string
+	severity    string
+	pattern     *regexp.Regexp
+}
+
+// Secrets looked for in the files of the scanned project
+var secretRules = []secretRule{
+	{"private-key", "Private key", SeverityCritical,
+		regexp.MustCompile(`-----BEGIN (?:RSA |EC |DSA |OPENSSH |PGP |ENCRYPTED )?PRIVATE KEY(?: BLOCK)?-----`)},
+	{"aws-access-key-id", "AWS access key ID", SeverityCritical,
+		regexp.MustCompile(`\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`)},
+	{"stripe-secret-key", "Stripe secret key", SeverityCritical,
+		regexp.MustCompile(`\b([sr]k_live_[0-9A-Za-z]{24,})\b`)},
+	{"github-token", "GitHub token", SeverityHigh,
+		regexp.MustCompile(`\b(gh[pousr]_[0-9A-Za-z]{36,}|github_pat_[0-9A-Za-z_]{82})\b`)},
+	{"gitlab-token", "GitLab personal access token", SeverityHigh,
+		regexp.MustCompile(`\b(glpat-[0-9A-Za-z_-]{20})\b`)},
+	{"slack-token", "Slack token", SeverityHigh,
+		regexp.MustCompile(`\b(xox[abprs]-[0-9A-Za-z-]{10,})`)},
+	{"google-api-key", "Google API key", SeverityHigh,
+		regexp.MustCompile(`\b(AIza[0-9A-Za-z_-]{35})\b`)},
+	{"npm-token", "npm access token", SeverityHigh,
+		regexp.MustCompile(`\b(npm_[0-9A-Za-z]{36})\b`)},
+	{"jwt", "JSON Web Token", SeverityMedium,
+		regexp.MustCompile(`\b(eyJ[0-9A-Za-z_-]{10,}\.eyJ[0-9A-Za-z_-]{10,}\.[0-9A-Za-z_-]{10,})`)},
+	// Placeholders such as ${DB_PASSWORD} or <token> are not secrets
+	{"generic-secret", "Hard-coded password or API key", SeverityMedium,
+		regexp.MustCompile(`(?i)\b(?:api[_-]?key|secret|token|passw(?:or)?d)["']?\s*[:=]\s*["']([^"'\s${}<>]{8,})["']`)},
+}
+
+// SecretFinding is a secret found in a file of the scanned project. Only a
+// redacted form of the secret is kept.
+type SecretFinding struct {
+	Rule        string `json:"rule"`
+	Description string `json:"description"`
+	Severity    string `json:"severity"`
+	Path        string `json:"path"` // relative to the scanned project
+	Line        int    `json:"line"`
+	Match       string `json:"match"` // redacted secret, or the private key header
+}
+
+// redactSecret keeps the first four characters of a secret
+func redactSecret(secret string) string {
+	runes := []rune(secret)
+	if len(runes) <= 4 {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[:4]) + strings.Repeat("*", min(len(runes)-4, 16))
+}
+
+// SecretsPath returns where the secrets found in a project are written
+func SecretsPath(sbomPath string) string {
+	return filepath.Join(filepath.Dir(sbomPath), "sbom-secrets.json")
+}
+
+// SecretsReportFor returns the secrets that belong to a license report:
+// aggregated-secrets.json for aggregated-licenses.json, else sbom-secrets.json
+func SecretsReportFor(licensesPath string) string {
+	if filepath.Base(licensesPath) == "aggregated-licenses.json" {
+		return filepath.Join(filepath.Dir(licensesPath), "aggregated-secrets.json")
+	}
+	return filepath.Join(filepath.Dir(licensesPath), "sbom-secrets.json")
+}
+
+// findSecrets walks the project directory for secrets. Hidden directories,
+// the directories of SkippedDirs and sbom-scanner output directories are
+// skipped, and with skipModules also subdirectories that are scanned as
+// modules of their own.
+func findSecrets(dir string, skipModules bool) ([]SecretFinding, error) {
+	secrets := []SecretFinding{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path == dir {
+				return nil
+			}
+			name := d.Name()
+			if strings.HasPrefix(name, ".") || sbom.SkippedDirs[name] {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, sbom.OutputMarker)); err == nil {
+				return filepath.SkipDir
+			}
+			if skipModules && sbom.FindProjectFile(path) != "" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		found, err := scanFileForSecrets(path, filepath.ToSlash(rel))
+		if err != nil {
+			runenv.Logger.Warnf("Could not scan %s for secrets: %v", path, err)
+			return nil
+		}
+		secrets = append(secrets, found...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk %s: %v", dir, err)
+	}
+	return secrets, nil
+}
+
+// scanFileForSecrets matches every line of a text file against the secret
+// rules. Binary and large files are skipped.
+func scanFileForSecrets(path, rel string) ([]SecretFinding, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxSecretFileSize {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return nil, nil
+	}
+
+	var secrets []SecretFinding
+	lines := bufio.NewScanner(bytes.NewReader(data))
+	lines.Buffer(make([]byte, 64*1024), maxSecretFileSize)
+	for n := 1; lines.Scan(); n++ {
+		line := lines.Text()
+		if strings.Contains(line, secretAllowMarker) {
+			continue
+		}
+		for _, rule := range secretRules {
+			m := rule.pattern.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			match := m[0]
+			if len(m) > 1 {
+				match = redactSecret(m[1])
+			}
+			secrets = append(secrets, SecretFinding{
+				Rule:        rule.id,
+				Description: rule.description,
+				Severity:    rule.severity,
+				Path:        rel,
+				Line:        n,
+				Match:       match,
+			})
+		}
+	}
+	return secrets, lines.Err()
+}
+
+// ScanSecrets looks for API keys, tokens and private keys in the files of the
+// project directory. The secrets are written next to the SBOM and shown in a
+// separate section of the reports; --fail-on-secret decides whether they
+// fail the scan.
+func ScanSecrets(dir, sbomPath string, skipModules bool) error {
+	secrets, err := findSecrets(dir, skipModules)
+	if err != nil {
+		return err
+	}
+	if err := WriteSecretsReport(SecretsPath(sbomPath), secrets); err != nil {
+		return err
+	}
+	for _, s := range secrets {
+		runenv.Logger.Warnf("Secret found (%s, %s): %s in %s:%d", s.Severity, s.Rule, s.Match, s.Path, s.Line)
+	}
+	return nil
+}
+
+// CheckSecrets fails the scan when a secret at or above the severity
+// threshold was found
+func CheckSecrets(reportPath, threshold string) error {
+	secrets, err := ReadSecretsReport(reportPath)
+	if err != nil {
+		return err
+	}
+	failing := 0
+	for _, s := range secrets {
+		if SeverityRank(s.Severity) >= SeverityRank(threshold) {
+			failing++
+		}
+	}
+	if failing > 0 {
+		return runenv.WithExitCode(fmt.Errorf("%d secrets with %s or higher severity found, see details in: %s", failing, threshold, reportPath), runenv.ExitPolicy)
+	}
+	return nil
+}
+
+func WriteSecretsReport(path string, secrets []SecretFinding) error {
+	sort.SliceStable(secrets, func(i, j int) bool {
+		if secrets[i].Severity != secrets[j].Severity {
+			return SeverityRank(secrets[i].Severity) > SeverityRank(secrets[j].Severity)
+		}
+		if secrets[i].Path != secrets[j].Path {
+			return secrets[i].Path < secrets[j].Path
+		}
+		return secrets[i].Line < secrets[j].Line
+	})
+	data, err := json.MarshalIndent(secrets, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode secrets report: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write secrets report: %v", err)
+	}
+	return nil
+}
+
+func ReadSecretsReport(path string) ([]SecretFinding, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets report: %v", err)
+	}
+	var secrets []SecretFinding
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse secrets report %s: %v", path, err)
+	}
+	return secrets, nil
+}
@@ -0,0 +1,258 @@
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/maven"
+)
+
+// Supply chain risks of a component
+const (
+	riskDependencyConfusion = "dependency-confusion"
+	riskTyposquatting       = "typosquatting"
+)
+
+// Popular packages per package URL type that typosquatters imitate. Names of
+// components within one edit of these, or equal apart from separators and
+// case, are flagged.
+var popularPackages = map[string][]string{
+	"maven": {
+		"com.fasterxml.jackson.core:jackson-databind", "com.fasterxml.jackson.core:jackson-core",
+		"com.fasterxml.jackson.core:jackson-annotations", "com.google.guava:guava", "com.google.code.gson:gson",
+		"org.apache.commons:commons-lang3", "org.apache.commons:commons-text", "org.apache.commons:commons-collections4",
+		"commons-io:commons-io", "commons-codec:commons-codec", "commons-collections:commons-collections",
+		"org.apache.logging.log4j:log4j-core", "org.apache.logging.log4j:log4j-api", "org.slf4j:slf4j-api",
+		"ch.qos.logback:logback-classic", "ch.qos.logback:logback-core", "org.springframework:spring-core",
+		"org.springframework:spring-web", "org.springframework:spring-context", "org.springframework:spring-beans",
+		"org.springframework.boot:spring-boot-starter-web", "org.springframework.boot:spring-boot-autoconfigure",
+		"org.apache.httpcomponents:httpclient", "com.squareup.okhttp3:okhttp", "org.yaml:snakeyaml",
+		"org.hibernate:hibernate-core", "org.postgresql:postgresql", "com.mysql:mysql-connector-j",
+		"mysql:mysql-connector-java", "junit:junit", "org.junit.jupiter:junit-jupiter-api", "org.mockito:mockito-core",
+		"org.projectlombok:lombok", "io.netty:netty-all", "org.apache.tomcat.embed:tomcat-embed-core",
+		"org.bouncycastle:bcprov-jdk18on", "org.bouncycastle:bcprov-jdk15on", "io.jsonwebtoken:jjwt", "com.auth0:java-jwt",
+	},
+	"npm": {
+		"react", "react-dom", "lodash", "express", "axios", "moment", "chalk", "commander", "debug", "request",
+		"vue", "jquery", "webpack", "typescript", "eslint", "prettier", "babel-core", "@babel/core", "next",
+		"uuid", "dotenv", "body-parser", "cross-env", "color", "colors", "async", "bluebird", "underscore", "yargs",
+		"mongoose", "socket.io", "jsonwebtoken", "bcrypt", "cors", "node-fetch", "classnames", "rxjs",
+		"electron", "inquirer", "minimist", "semver", "glob", "rimraf", "mkdirp", "ws",
+	},
+	"pypi": {
+		"requests", "numpy", "pandas", "urllib3", "setuptools", "six", "python-dateutil", "boto3", "botocore",
+		"django", "flask", "pyyaml", "cryptography", "pillow", "jinja2", "sqlalchemy", "beautifulsoup4",
+		"matplotlib", "scipy", "scikit-learn", "tensorflow", "torch", "pytest", "certifi", "idna", "colorama",
+		"pydantic", "fastapi", "selenium", "paramiko", "psycopg2", "pymongo", "redis", "celery", "openai",
+	},
+	"gem":   {"rails", "rack", "nokogiri", "devise", "puma", "rspec", "sinatra", "json", "activesupport", "bundler"},
+	"nuget": {"Newtonsoft.Json", "Serilog", "AutoMapper", "Dapper", "Moq", "xunit", "NUnit", "Polly", "FluentValidation"},
+	"cargo": {"serde", "tokio", "rand", "clap", "regex", "serde_json", "reqwest", "log", "anyhow", "hyper"},
+	"golang": {
+		"github.com/gin-gonic/gin", "github.com/sirupsen/logrus", "github.com/spf13/cobra",
+		"github.com/stretchr/testify", "github.com/gorilla/mux", "gopkg.in/yaml.v2", "gopkg.in/yaml.v3", "github.com/google/uuid",
+	},
+}
+
+// SupplyChainRisk is a component that may have been substituted by a
+// malicious package
+type SupplyChainRisk struct {
+	Package   string `json:"package"`
+	Version   string `json:"version"`
+	PURL      string `json:"purl,omitempty"`
+	Risk      string `json:"risk"`                 // dependency-confusion or typosquatting
+	SimilarTo string `json:"similar_to,omitempty"` // the popular package a typosquatting name imitates
+	Message   string `json:"message"`
+}
+
+// internalPackage reports whether a component belongs to one of the internal
+// namespaces: a Maven groupId and its subgroups (com.example), an npm scope
+// (@example), or a package name pattern where * matches any characters
+func internalPackage(namespaces []string, c maven.ComponentLicense) bool {
+	purlType, _, _ := strings.Cut(strings.TrimPrefix(c.PURL, "pkg:"), "/")
+	for _, ns := range namespaces {
+		switch {
+		case strings.Contains(ns, "*"):
+			if packageMatches(ns, c.Package) {
+				return true
+			}
+		case purlType == "maven":
+			group, _, _ := strings.Cut(c.Package, ":")
+			if group == ns || strings.HasPrefix(group, ns+".") {
+				return true
+			}
+		case strings.HasPrefix(ns, "@"):
+			if strings.HasPrefix(c.Package, ns+"/") {
+				return true
+			}
+		case c.Package == ns:
+			return true
+		}
+	}
+	return false
+}
+
+// similarPackage returns the popular package a name imitates: one within a
+// single edit (insertion, deletion, substitution or swap of neighbours) for
+// names of at least 5 characters, or one that only differs in separators and
+// case, such as python_dateutil
+func similarPackage(purlType, name string) (string, bool) {
+	popular := popularPackages[purlType]
+	for _, p := range popular {
+		if p == name {
+			return "", false
+		}
+	}
+	for _, p := range popular {
+		if strings.EqualFold(p, name) {
+			// Case is not significant in these registries
+			continue
+		}
+		if squashSeparators(p) == squashSeparators(name) {
+			return p, true
+		}
+		if len(name) >= 5 && editDistance(p, name) == 1 {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// squashSeparators lowercases a name and drops -, _ and .
+func squashSeparators(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(name))
+}
+
+// editDistance is the optimal string alignment distance of two names: the
+// number of insertions, deletions, substitutions and swaps of neighbours
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+// SupplyChainPath returns where the supply chain risks of an SBOM are written
+func SupplyChainPath(sbomPath string) string {
+	return filepath.Join(filepath.Dir(sbomPath), "sbom-supply-chain.json")
+}
+
+// SupplyChainReportFor returns the supply chain risks that belong to a
+// license report: aggregated-supply-chain.json for aggregated-licenses.json,
+// else sbom-supply-chain.json
+func SupplyChainReportFor(licensesPath string) string {
+	if filepath.Base(licensesPath) == "aggregated-licenses.json" {
+		return filepath.Join(filepath.Dir(licensesPath), "aggregated-supply-chain.json")
+	}
+	return filepath.Join(filepath.Dir(licensesPath), "sbom-supply-chain.json")
+}
+
+// CheckSupplyChain flags the components of the SBOM that may have been
+// substituted: packages of the internal namespaces that are published in a
+// public registry (dependency confusion, looked up on deps.dev), and names
+// that imitate popular packages (typosquatting). The risks are written next
+// to the SBOM and shown in a separate section of the reports.
+func CheckSupplyChain(ctx context.Context, sbomPath string, namespaces []string) error {
+	report, err := maven.ReadLicenseReport(maven.LicensesPath(sbomPath))
+	if err != nil {
+		return err
+	}
+
+	risks := []SupplyChainRisk{}
+	failed := 0
+	for _, c := range report.Components {
+		risk := SupplyChainRisk{Package: c.Package, Version: c.Version, PURL: c.PURL}
+		if internalPackage(namespaces, c) {
+			if runenv.SettingsOf(ctx).Offline {
+				continue
+			}
+			releases, public, err := fetchPackageReleases(ctx, c)
+			if err != nil {
+				failed++
+				continue
+			}
+			if public {
+				risk.Risk = riskDependencyConfusion
+				risk.Message = fmt.Sprintf("internal package is published in a public registry (latest release %s)", releases.latest.Format("2006-01-02"))
+				risks = append(risks, risk)
+			}
+			continue
+		}
+
+		purlType, _, _ := strings.Cut(strings.TrimPrefix(c.PURL, "pkg:"), "/")
+		if similar, ok := similarPackage(purlType, c.Package); ok {
+			risk.Risk, risk.SimilarTo = riskTyposquatting, similar
+			risk.Message = "name is similar to the popular package " + similar
+			risks = append(risks, risk)
+		}
+	}
+
+	if failed > 0 {
+		runenv.Logger.Warnf("Could not look up %d internal packages in the public registries", failed)
+	}
+	if err := WriteSupplyChainReport(SupplyChainPath(sbomPath), risks); err != nil {
+		return err
+	}
+	for _, r := range risks {
+		runenv.Logger.Warnf("Supply chain risk %s: %s@%s, %s", r.Risk, r.Package, r.Version, r.Message)
+	}
+	return nil
+}
+
+func WriteSupplyChainReport(path string, risks []SupplyChainRisk) error {
+	sort.SliceStable(risks, func(i, j int) bool {
+		if risks[i].Package != risks[j].Package {
+			return risks[i].Package < risks[j].Package
+		}
+		return risks[i].Version < risks[j].Version
+	})
+	data, err := json.MarshalIndent(risks, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode supply chain report: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write supply chain report: %v", err)
+	}
+	return nil
+}
+
+func ReadSupplyChainReport(path string) ([]SupplyChainRisk, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read supply chain report: %v", err)
+	}
+	var risks []SupplyChainRisk
+	if err := json.Unmarshal(data, &risks); err != nil {
+		return nil, fmt.Errorf("failed to parse supply chain report %s: %v", path, err)
+	}
+	return risks, nil
+}
@@ -0,0 +1,216 @@
+package scan
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+const OpenVEXContext = "https://openvex.dev/ns/v0.2.0"
+
+// VEX states that mean the product is not affected by the vulnerability
+var vexSuppressingStates = map[string]bool{
+	"not_affected":   true,
+	"fixed":          true,
+	"false_positive": true,
+	"resolved":       true,
+}
+
+type OpenVEXDocument struct {
+	Context    string             `json:"@context"`
+	ID         string             `json:"@id"`
+	Author     string             `json:"author"`
+	Timestamp  string             `json:"timestamp"`
+	Version    int                `json:"version"`
+	Statements []OpenVEXStatement `json:"statements"`
+}
+
+type OpenVEXStatement struct {
+	Vulnerability   OpenVEXVulnerability `json:"vulnerability"`
+	Products        []OpenVEXProduct     `json:"products,omitempty"`
+	Status          string               `json:"status"`
+	Justification   string               `json:"justification,omitempty"`
+	ImpactStatement string               `json:"impact_statement,omitempty"`
+	ActionStatement string               `json:"action_statement,omitempty"`
+}
+
+type OpenVEXVulnerability struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+// UnmarshalJSON accepts the plain string form used by OpenVEX v0.0.1
+func (v *OpenVEXVulnerability) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		v.Name = name
+		return nil
+	}
+	type plain OpenVEXVulnerability
+	return json.Unmarshal(data, (*plain)(v))
+}
+
+type OpenVEXProduct struct {
+	ID string `json:"@id"`
+}
+
+// UnmarshalJSON accepts the plain string form used by OpenVEX v0.0.1
+func (p *OpenVEXProduct) UnmarshalJSON(data []byte) error {
+	var id string
+	if err := json.Unmarshal(data, &id); err == nil {
+		p.ID = id
+		return nil
+	}
+	type plain OpenVEXProduct
+	return json.Unmarshal(data, (*plain)(p))
+}
+
+type cyclonedxVEXDocument struct {
+	BOMFormat       string `json:"bomFormat"`
+	Vulnerabilities []struct {
+		ID       string `json:"id"`
+		Analysis struct {
+			State         string `json:"state"`
+			Justification string `json:"justification"`
+			Detail        string `json:"detail"`
+		} `json:"analysis"`
+		Affects []struct {
+			Ref string `json:"ref"`
+		} `json:"affects"`
+	} `json:"vulnerabilities"`
+}
+
+// LoadVEX reads an OpenVEX or CycloneDX VEX document and turns its
+// not_affected and fixed statements into ignore rules
+func LoadVEX(path string) ([]IgnoreRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read VEX document: %v", err)
+	}
+
+	var probe struct {
+		Context   string `json:"@context"`
+		BOMFormat string `json:"bomFormat"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse VEX document %s: %v", path, err)
+	}
+
+	switch {
+	case strings.Contains(probe.Context, "openvex"):
+		return loadOpenVEX(data, path)
+	case probe.BOMFormat == "CycloneDX":
+		return loadCycloneDXVEX(data, path)
+	}
+	return nil, fmt.Errorf("unsupported VEX document: %s (expected OpenVEX or CycloneDX JSON)", path)
+}
+
+func loadOpenVEX(data []byte, path string) ([]IgnoreRule, error) {
+	var doc OpenVEXDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenVEX document %s: %v", path, err)
+	}
+
+	var rules []IgnoreRule
+	for _, s := range doc.Statements {
+		if !vexSuppressingStates[s.Status] || s.Vulnerability.Name == "" {
+			continue
+		}
+		reason := vexReason(s.Status, s.Justification, s.ImpactStatement)
+		var refs []string
+		for _, p := range s.Products {
+			refs = append(refs, p.ID)
+		}
+		rules = append(rules, vexRules(s.Vulnerability.Name, refs, reason)...)
+	}
+	return rules, nil
+}
+
+func loadCycloneDXVEX(data []byte, path string) ([]IgnoreRule, error) {
+	var doc cyclonedxVEXDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse CycloneDX VEX document %s: %v", path, err)
+	}
+
+	var rules []IgnoreRule
+	for _, v := range doc.Vulnerabilities {
+		if !vexSuppressingStates[v.Analysis.State] || v.ID == "" {
+			continue
+		}
+		reason := vexReason(v.Analysis.State, v.Analysis.Justification, v.Analysis.Detail)
+		var refs []string
+		for _, a := range v.Affects {
+			refs = append(refs, a.Ref)
+		}
+		rules = append(rules, vexRules(v.ID, refs, reason)...)
+	}
+	return rules, nil
+}
+
+func vexReason(status, justification, detail string) string {
+	reason := "VEX: " + status
+	if justification != "" {
+		reason += " (" + justification + ")"
+	}
+	if detail != "" {
+		reason += ": " + detail
+	}
+	return reason
+}
+
+// vexRules creates one rule per product package URL, or a single ID rule when
+// the statement names no package
+func vexRules(id string, refs []string, reason string) []IgnoreRule {
+	var rules []IgnoreRule
+	for _, ref := range refs {
+		pkg, ok := sbom.PackageFromPURL(ref)
+		if !ok {
+			continue
+		}
+		rule := IgnoreRule{ID: id, Package: pkg.Name, Reason: reason}
+		if pkg.Version != "" {
+			rule.Package += "@" + pkg.Version
+		}
+		rules = append(rules, rule)
+	}
+	if len(rules) == 0 {
+		rules = append(rules, IgnoreRule{ID: id, Reason: reason})
+	}
+	return rules
+}
+
+// PURLFromPackage builds the package URL of an OSV package
+func PURLFromPackage(ecosystem, name, version string) string {
+	for purlType, e := range sbom.PURLEcosystems {
+		if e != ecosystem {
+			continue
+		}
+		c := sbom.Component{Type: purlType, Name: name, Version: version}
+		switch purlType {
+		case "maven":
+			c.Namespace, c.Name, _ = strings.Cut(name, ":")
+		case "npm":
+			c = sbom.NodeComponent(name, version)
+		case "golang", "composer":
+			if i := strings.LastIndex(name, "/"); i >= 0 {
+				c.Namespace, c.Name = name[:i], name[i+1:]
+			}
+		}
+		return c.PURL()
+	}
+	return ""
+}
+
+// VEXName prefers the CVE identifier, which most VEX consumers key on
+func VEXName(f Finding) (string, []string) {
+	ids := append([]string{f.ID}, f.Aliases...)
+	for i, id := range ids {
+		if strings.HasPrefix(id, "CVE-") {
+			return id, append(append([]string{}, ids[:i]...), ids[i+1:]...)
+		}
+	}
+	return f.ID, f.Aliases
+}
@@ -0,0 +1,145 @@
+package scanner
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/maven"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// writeAggregatedExclusions combines the excluded dependencies of all modules
+func writeAggregatedExclusions(outputDir string, projects []sbom.Project) error {
+	var excluded []maven.ExcludedDependency
+	seen := make(map[string]bool)
+	found := false
+	for _, p := range projects {
+		module, err := maven.ReadExcludedReport(maven.ExcludedPath(filepath.Join(outputDir, p.Output, "sbom.xml")))
+		if err != nil {
+			continue
+		}
+		found = true
+		for _, e := range module {
+			key := strings.Join([]string{e.Package, e.Version, e.ExcludedBy}, "@")
+			if !seen[key] {
+				seen[key] = true
+				excluded = append(excluded, e)
+			}
+		}
+	}
+	if !found {
+		return nil
+	}
+	return maven.WriteExcludedReport(filepath.Join(outputDir, "aggregated-excluded.json"), excluded)
+}
+
+// writeAggregatedLicenses combines the license reports of all modules
+func writeAggregatedLicenses(outputDir string, projects []sbom.Project) error {
+	var components []maven.ComponentLicense
+	for _, p := range projects {
+		report, err := maven.ReadLicenseReport(maven.LicensesPath(filepath.Join(outputDir, p.Output, "sbom.xml")))
+		if err != nil {
+			continue
+		}
+		components = append(components, report.Components...)
+	}
+
+	reportPath := filepath.Join(outputDir, "aggregated-licenses.json")
+	if err := maven.WriteLicenseFile(reportPath, maven.NewLicenseReport(components)); err != nil {
+		return err
+	}
+	runenv.Logger.Infof("Aggregated license report written to %s", reportPath)
+	return nil
+}
+
+// writeAggregatedMaintenance combines the maintenance risks of all modules
+func writeAggregatedMaintenance(outputDir string, projects []sbom.Project) error {
+	risks := []scan.MaintenanceRisk{}
+	seen := make(map[string]bool)
+	found := false
+	for _, p := range projects {
+		module, err := scan.ReadMaintenanceReport(scan.MaintenancePath(filepath.Join(outputDir, p.Output, "sbom.xml")))
+		if err != nil {
+			continue
+		}
+		found = true
+		for _, r := range module {
+			key := r.Package + "@" + r.Version + ":" + r.Risk
+			if !seen[key] {
+				seen[key] = true
+				risks = append(risks, r)
+			}
+		}
+	}
+	if !found {
+		return nil
+	}
+	return scan.WriteMaintenanceReport(filepath.Join(outputDir, "aggregated-maintenance.json"), risks)
+}
+
+// writeAggregatedSecrets combines the secrets of all modules, with the paths
+// relative to the scanned root. Polyglot directories are scanned once.
+func writeAggregatedSecrets(outputDir string, projects []sbom.Project) error {
+	secrets := []scan.SecretFinding{}
+	found := false
+	for _, p := range projects {
+		module, err := scan.ReadSecretsReport(scan.SecretsPath(filepath.Join(outputDir, p.Output, "sbom.xml")))
+		if err != nil {
+			continue
+		}
+		found = true
+		for _, s := range module {
+			s.Path = filepath.ToSlash(filepath.Join(p.Rel, s.Path))
+			secrets = append(secrets, s)
+		}
+	}
+	if !found {
+		return nil
+	}
+	return scan.WriteSecretsReport(filepath.Join(outputDir, "aggregated-secrets.json"), secrets)
+}
+
+// writeAggregatedSupplyChain combines the supply chain risks of all modules
+func writeAggregatedSupplyChain(outputDir string, projects []sbom.Project) error {
+	risks := []scan.SupplyChainRisk{}
+	seen := make(map[string]bool)
+	found := false
+	for _, p := range projects {
+		module, err := scan.ReadSupplyChainReport(scan.SupplyChainPath(filepath.Join(outputDir, p.Output, "sbom.xml")))
+		if err != nil {
+			continue
+		}
+		found = true
+		for _, r := range module {
+			key := r.Package + "@" + r.Version + ":" + r.Risk
+			if !seen[key] {
+				seen[key] = true
+				risks = append(risks, r)
+			}
+		}
+	}
+	if !found {
+		return nil
+	}
+	return scan.WriteSupplyChainReport(filepath.Join(outputDir, "aggregated-supply-chain.json"), risks)
+}
+
+// writeAggregatedUnpinned combines the unpinned dependencies of all modules
+func writeAggregatedUnpinned(outputDir string, projects []sbom.Project) error {
+	var unpinned []maven.UnpinnedDependency
+	found := false
+	for _, p := range projects {
+		module, err := maven.ReadUnpinnedReport(maven.UnpinnedPath(filepath.Join(outputDir, p.Output, "sbom.xml")))
+		if err != nil {
+			continue
+		}
+		found = true
+		unpinned = append(unpinned, module...)
+	}
+	if !found {
+		return nil
+	}
+	return maven.WriteUnpinnedReport(filepath.Join(outputDir, "aggregated-unpinned.json"), maven.UniqueUnpinned(unpinned))
+}
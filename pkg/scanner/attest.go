@@ -0,0 +1,444 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/maven"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// in-toto statement and predicate types of the attestations
+const (
+	inTotoStatementType    = "https://in-toto.io/Statement/v1"
+	cyclonedxPredicateType = "https://cyclonedx.org/bom"
+	spdxPredicateType      = "https://spdx.dev/Document"
+	slsaProvenanceType     = "https://slsa.dev/provenance/v1"
+	sbomScannerBuildType   = "https://github.com/xshuden/sbom-scanner/sbom@v1"
+	sbomScannerBuilderID   = "https://github.com/xshuden/sbom-scanner"
+	sbomAttestationSuffix  = ".intoto.json"
+	provenanceFileName     = "sbom.provenance.json"
+)
+
+// CI variables recorded in the provenance. The environment is never copied
+// as a whole since it may hold secrets.
+var provenanceEnvironment = []string{
+	"CI", "GITHUB_ACTIONS", "GITHUB_REPOSITORY", "GITHUB_REF", "GITHUB_SHA", "GITHUB_WORKFLOW_REF", "GITHUB_RUN_ID", "GITHUB_RUN_ATTEMPT", "RUNNER_OS", "RUNNER_ARCH",
+	"GITLAB_CI", "CI_PROJECT_PATH", "CI_COMMIT_SHA", "CI_COMMIT_REF_NAME", "CI_PIPELINE_ID", "CI_JOB_ID",
+	"JENKINS_URL", "BUILD_URL", "GIT_COMMIT", "GIT_BRANCH",
+	"BITBUCKET_REPO_FULL_NAME", "BITBUCKET_COMMIT", "BITBUCKET_BUILD_NUMBER",
+}
+
+var sha256Digest = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// attestOptions configures the in-toto attestations of the SBOMs
+type attestOptions struct {
+	Format   string    // predicate of the SBOM attestation: cyclonedx or spdx, none when empty
+	subjects []string  // artifacts described by the SBOM, files or name@sha256:digest
+	started  time.Time // start of the run, recorded in the provenance
+	source   *attestSource
+}
+
+// attestSource is the remote repository a scan was cloned from
+type attestSource struct {
+	uri    string
+	commit string
+}
+
+// inTotoStatement is an in-toto v1 statement
+type inTotoStatement struct {
+	Type          string          `json:"_type"`
+	Subject       []inTotoSubject `json:"subject"`
+	PredicateType string          `json:"predicateType"`
+	Predicate     any             `json:"predicate"`
+}
+
+type inTotoSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// slsaProvenance is the predicate of a SLSA v1 provenance
+type slsaProvenance struct {
+	BuildDefinition struct {
+		BuildType            string             `json:"buildType"`
+		ExternalParameters   map[string]any     `json:"externalParameters"`
+		InternalParameters   map[string]any     `json:"internalParameters,omitempty"`
+		ResolvedDependencies []slsaResourceDesc `json:"resolvedDependencies,omitempty"`
+	} `json:"buildDefinition"`
+	RunDetails struct {
+		Builder struct {
+			ID      string            `json:"id"`
+			Version map[string]string `json:"version,omitempty"`
+		} `json:"builder"`
+		Metadata struct {
+			InvocationID string `json:"invocationId,omitempty"`
+			StartedOn    string `json:"startedOn,omitempty"`
+			FinishedOn   string `json:"finishedOn,omitempty"`
+		} `json:"metadata"`
+	} `json:"runDetails"`
+}
+
+type slsaResourceDesc struct {
+	Name   string            `json:"name,omitempty"`
+	URI    string            `json:"uri,omitempty"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// parseAttestOptions validates the predicate format and the subjects
+func parseAttestOptions(format string, subjects []string) (attestOptions, error) {
+	opts := attestOptions{Format: strings.ToLower(strings.TrimSpace(format)), started: time.Now()}
+	switch opts.Format {
+	case "", "cyclonedx", "spdx":
+	default:
+		return opts, fmt.Errorf("unknown attestation format: %s (available: cyclonedx, spdx)", format)
+	}
+	for _, subject := range subjects {
+		if subject = strings.TrimSpace(subject); subject == "" {
+			continue
+		}
+		if opts.Format == "" {
+			return opts, fmt.Errorf("--attest-subject needs --attest")
+		}
+		if name, digest, ok := strings.Cut(subject, "@sha256:"); ok {
+			if name == "" || !sha256Digest.MatchString(digest) {
+				return opts, fmt.Errorf("invalid attestation subject: %s (expected a file or name@sha256:<digest>)", subject)
+			}
+		} else if _, err := os.Stat(subject); err != nil {
+			return opts, fmt.Errorf("attestation subject not found: %s", subject)
+		}
+		opts.subjects = append(opts.subjects, subject)
+	}
+	return opts, nil
+}
+
+// WriteAttestations writes the SBOM attestation (sbom.xml.intoto.json), whose
+// predicate is the SBOM, and the SLSA provenance of the SBOM
+// (sbom.provenance.json) with the inputs, tools and environment it was
+// generated from.
+func WriteAttestations(ctx context.Context, sbomPath string, p sbom.Project, opts ScanOptions) error {
+	inputs, err := projectInputs(p)
+	if err != nil {
+		return err
+	}
+	tools := attestationTools(ctx, p, opts)
+
+	subjects, err := attestationSubjects(opts.Attest.subjects)
+	if err != nil {
+		return err
+	}
+	// Without an artifact, the SBOM describes the project files it was generated from
+	if len(subjects) == 0 {
+		for _, input := range inputs {
+			subjects = append(subjects, inTotoSubject{Name: input.Name, Digest: input.Digest})
+		}
+	}
+
+	statement := inTotoStatement{Type: inTotoStatementType, Subject: subjects}
+	switch opts.Attest.Format {
+	case "spdx":
+		statement.PredicateType = spdxPredicateType
+		statement.Predicate, err = spdxPredicate(sbomPath, p, tools)
+	default:
+		statement.PredicateType = cyclonedxPredicateType
+		statement.Predicate, err = cyclonedxPredicate(sbomPath, tools)
+	}
+	if err != nil {
+		return err
+	}
+	if err := writeJSONFile(sbomPath+sbomAttestationSuffix, statement); err != nil {
+		return err
+	}
+
+	digest, err := runenv.FileSHA256(sbomPath)
+	if err != nil {
+		return fmt.Errorf("failed to hash SBOM: %v", err)
+	}
+	provenance := inTotoStatement{
+		Type:          inTotoStatementType,
+		Subject:       []inTotoSubject{{Name: filepath.Base(sbomPath), Digest: map[string]string{"sha256": digest}}},
+		PredicateType: slsaProvenanceType,
+		Predicate:     provenancePredicate(p, opts, inputs, tools),
+	}
+	if err := writeJSONFile(filepath.Join(filepath.Dir(sbomPath), provenanceFileName), provenance); err != nil {
+		return err
+	}
+	runenv.Logger.Infof("Attestations of %s written", sbomPath)
+	return nil
+}
+
+// attestationFiles lists the files written by WriteAttestations, signed
+// together with the SBOM
+func attestationFiles(sbomPath string) []string {
+	return []string{sbomPath + sbomAttestationSuffix, filepath.Join(filepath.Dir(sbomPath), provenanceFileName)}
+}
+
+// attestationSubjects hashes the subject files, name@sha256:digest is taken as is
+func attestationSubjects(subjects []string) ([]inTotoSubject, error) {
+	var result []inTotoSubject
+	for _, subject := range subjects {
+		if name, digest, ok := strings.Cut(subject, "@sha256:"); ok {
+			result = append(result, inTotoSubject{Name: name, Digest: map[string]string{"sha256": digest}})
+			continue
+		}
+		digest, err := runenv.FileSHA256(subject)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash attestation subject: %v", err)
+		}
+		result = append(result, inTotoSubject{Name: filepath.Base(subject), Digest: map[string]string{"sha256": digest}})
+	}
+	return result, nil
+}
+
+// projectInputs returns the digests of the project file and of the files read
+// with it, e.g. the lockfile of a package.json
+func projectInputs(p sbom.Project) ([]slsaResourceDesc, error) {
+	paths := []string{p.File}
+	for _, name := range watchCompanions[filepath.Base(p.File)] {
+		paths = append(paths, filepath.Join(filepath.Dir(p.File), name))
+	}
+
+	var inputs []slsaResourceDesc
+	for i, path := range paths {
+		digest, err := runenv.FileSHA256(path)
+		if err != nil {
+			// Companion files are optional
+			if i > 0 && os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to hash %s: %v", path, err)
+		}
+		name := filepath.ToSlash(filepath.Join(p.Rel, filepath.Base(path)))
+		inputs = append(inputs, slsaResourceDesc{
+			Name:   name,
+			URI:    "file:" + name,
+			Digest: map[string]string{"sha256": digest},
+		})
+	}
+	return inputs, nil
+}
+
+// attestationTools returns the versions of sbom-scanner and of the external
+// tools that generated and scanned the SBOM of the project
+func attestationTools(ctx context.Context, p sbom.Project, opts ScanOptions) map[string]string {
+	s := runenv.SettingsOf(ctx)
+	tools := map[string]string{"sbom-scanner": scannerVersion()}
+
+	var names []string
+	switch {
+	case p.Tool == sbom.BuildToolMaven && opts.Resolver != "native":
+		names = append(names, "maven", "java")
+		tools["cyclonedx-maven-plugin"] = maven.CycloneDXPluginVersion(s)
+	case p.Tool == sbom.BuildToolGradle:
+		names = append(names, "gradle", "java")
+	}
+	for _, name := range opts.Scanners {
+		if tool := scan.ScannerBackends[name].Tool; tool != "" {
+			names = append(names, tool)
+		}
+	}
+	for _, name := range names {
+		if version := toolVersion(s, name); version != "" {
+			tools[name] = version
+		}
+	}
+	return tools
+}
+
+var (
+	toolVersionsMu sync.Mutex
+	toolVersions   = make(map[string]string)
+)
+
+// toolVersion returns the version of a tool of the doctor report, looked up
+// once per process and executable
+func toolVersion(s *runenv.Settings, name string) string {
+	toolVersionsMu.Lock()
+	defer toolVersionsMu.Unlock()
+	for _, tool := range doctorTools {
+		if tool.name != name {
+			continue
+		}
+		key := name + "=" + tool.path(s)
+		if version, ok := toolVersions[key]; ok {
+			return version
+		}
+		version := tool.check(s).Version
+		toolVersions[key] = version
+		return version
+	}
+	return ""
+}
+
+// scannerVersion returns the module version sbom-scanner was built from
+func scannerVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// provenancePredicate describes how the SBOM was generated
+func provenancePredicate(p sbom.Project, opts ScanOptions, inputs []slsaResourceDesc, tools map[string]string) slsaProvenance {
+	var prov slsaProvenance
+	prov.BuildDefinition.BuildType = sbomScannerBuildType
+
+	params := map[string]any{
+		"project":   filepath.ToSlash(filepath.Join(p.Rel, filepath.Base(p.File))),
+		"buildTool": string(p.Tool),
+		"resolver":  opts.Resolver,
+	}
+	if len(opts.scopes) > 0 {
+		params["scopes"] = opts.scopes
+	}
+	if len(opts.Scanners) > 0 {
+		params["scanners"] = opts.Scanners
+	}
+	prov.BuildDefinition.ExternalParameters = params
+
+	environment := map[string]string{"os": runtime.GOOS, "arch": runtime.GOARCH}
+	for _, name := range provenanceEnvironment {
+		if value := os.Getenv(name); value != "" {
+			environment[name] = value
+		}
+	}
+	prov.BuildDefinition.InternalParameters = map[string]any{"tools": tools, "environment": environment}
+
+	if source := opts.Attest.source; source != nil {
+		dependency := slsaResourceDesc{URI: "git+" + source.uri}
+		if source.commit != "" {
+			dependency.Digest = map[string]string{"gitCommit": source.commit}
+		}
+		prov.BuildDefinition.ResolvedDependencies = append(prov.BuildDefinition.ResolvedDependencies, dependency)
+	}
+	prov.BuildDefinition.ResolvedDependencies = append(prov.BuildDefinition.ResolvedDependencies, inputs...)
+
+	prov.RunDetails.Builder.ID, prov.RunDetails.Metadata.InvocationID = builderIdentity()
+	prov.RunDetails.Builder.Version = map[string]string{"sbom-scanner": tools["sbom-scanner"]}
+	if !opts.Attest.started.IsZero() {
+		prov.RunDetails.Metadata.StartedOn = opts.Attest.started.UTC().Format(time.RFC3339)
+	}
+	prov.RunDetails.Metadata.FinishedOn = time.Now().UTC().Format(time.RFC3339)
+	return prov
+}
+
+// builderIdentity returns the builder ID and the invocation ID of the CI job,
+// or the sbom-scanner ID outside of CI
+func builderIdentity() (string, string) {
+	switch {
+	case githubActions():
+		server := os.Getenv("GITHUB_SERVER_URL")
+		if server == "" {
+			server = "https://github.com"
+		}
+		builder := server + "/actions/runner"
+		if ref := os.Getenv("GITHUB_WORKFLOW_REF"); ref != "" {
+			builder = server + "/" + ref
+		}
+		invocation := ""
+		if run := os.Getenv("GITHUB_RUN_ID"); run != "" {
+			invocation = fmt.Sprintf("%s/%s/actions/runs/%s/attempts/%s", server, os.Getenv("GITHUB_REPOSITORY"), run, os.Getenv("GITHUB_RUN_ATTEMPT"))
+		}
+		return builder, invocation
+	case os.Getenv("GITLAB_CI") == "true":
+		return os.Getenv("CI_SERVER_URL") + "/" + os.Getenv("CI_PROJECT_PATH"), os.Getenv("CI_JOB_URL")
+	case os.Getenv("JENKINS_URL") != "":
+		return os.Getenv("JENKINS_URL"), os.Getenv("BUILD_URL")
+	}
+	return sbomScannerBuilderID, ""
+}
+
+// cyclonedxPredicate converts the SBOM to CycloneDX JSON, the format policy
+// controllers read, with the tools that generated it in the metadata
+func cyclonedxPredicate(sbomPath string, tools map[string]string) (sbom.CDXJSONDocument, error) {
+	bom, err := sbom.ReadCycloneDXBOM(sbomPath)
+	if err != nil {
+		return sbom.CDXJSONDocument{}, err
+	}
+	doc := sbom.CycloneDXJSON(bom)
+	doc.Metadata.Tools = nil
+	for _, name := range toolNames(tools) {
+		doc.Metadata.Tools = append(doc.Metadata.Tools, sbom.CDXJSONTool{Name: name, Version: tools[name]})
+	}
+	return doc, nil
+}
+
+// spdxPredicate converts the SBOM to an SPDX 2.3 JSON document
+func spdxPredicate(sbomPath string, p sbom.Project, tools map[string]string) (sbom.SPDXJSONDocument, error) {
+	bom, err := sbom.ReadCycloneDXBOM(sbomPath)
+	if err != nil {
+		return sbom.SPDXJSONDocument{}, err
+	}
+	doc := sbom.BOMFromCycloneDX(bom)
+	doc.Name = filepath.ToSlash(filepath.Join(p.Rel, filepath.Base(p.File)))
+	doc.Tools = nil
+	for _, tool := range toolNames(tools) {
+		doc.Tools = append(doc.Tools, sbom.CDXTool{Name: tool, Version: tools[tool]})
+	}
+	if doc.Namespace == "" {
+		digest, err := runenv.FileSHA256(sbomPath)
+		if err != nil {
+			return sbom.SPDXJSONDocument{}, fmt.Errorf("failed to hash SBOM: %v", err)
+		}
+		doc.Namespace = digest
+	}
+
+	// Nested components may repeat a dependency
+	components := doc.Components[:0]
+	seen := make(map[string]bool)
+	for _, c := range doc.Components {
+		key := c.PURL
+		if key == "" {
+			key = c.Group + ":" + c.Name + "@" + c.Version
+		}
+		if c.Name != "" && !seen[key] {
+			seen[key] = true
+			components = append(components, c)
+		}
+	}
+	doc.Components = components
+	return sbom.SPDXFromBOM(doc), nil
+}
+
+// toolNames returns the names of the tools in alphabetical order
+func toolNames(tools map[string]string) []string {
+	names := make([]string, 0, len(tools))
+	for name := range tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// gitCommit returns the commit checked out in dir
+func gitCommit(ctx context.Context, dir string) string {
+	output, err := runenv.Command(ctx, "git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// writeJSONFile writes v as indented JSON
+func writeJSONFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %v", filepath.Base(path), err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", filepath.Base(path), err)
+	}
+	return nil
+}
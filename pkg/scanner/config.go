@@ -0,0 +1,638 @@
+package scanner
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/maven"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+	"gopkg.in/yaml.v3"
+)
+
+// Config file names looked up in the project directory and the working directory
+var ConfigFileNames = []string{".sbom-scanner.yaml", ".sbom-scanner.yml"}
+
+// Config holds the defaults read from .sbom-scanner.yaml
+type Config struct {
+	File           string            `yaml:"file,omitempty"`
+	Output         string            `yaml:"output,omitempty"`
+	Clean          bool              `yaml:"clean,omitempty"`
+	KeepLast       int               `yaml:"keep-last,omitempty"`
+	Kubernetes     bool              `yaml:"kubernetes,omitempty"`
+	HelmValues     []string          `yaml:"helm-values,omitempty"`
+	Registry       string            `yaml:"registry,omitempty"`
+	Tags           []string          `yaml:"tags,omitempty"`
+	ExcludeTags    []string          `yaml:"exclude-tags,omitempty"`
+	Resolver       string            `yaml:"resolver,omitempty"`
+	Scanner        string            `yaml:"scanner,omitempty"`
+	Reports        []string          `yaml:"reports,omitempty"`
+	Graph          []string          `yaml:"graph,omitempty"`
+	Scopes         []string          `yaml:"scopes,omitempty"`
+	ExitOnVuln     bool              `yaml:"exit-on-vuln,omitempty"`
+	FailOn         string            `yaml:"fail-on,omitempty"`
+	FailOnUnknown  bool              `yaml:"fail-on-unknown,omitempty"`
+	FailOnLicense  []string          `yaml:"fail-on-license,omitempty"`
+	Ignore         []scan.IgnoreRule `yaml:"ignore,omitempty"`
+	Policies       []scan.PolicyRule `yaml:"policies,omitempty"`
+	VEX            []string          `yaml:"vex,omitempty"`
+	TrivyCache     string            `yaml:"trivy-cache-dir,omitempty"`
+	Offline        bool              `yaml:"offline,omitempty"`
+	DBDir          string            `yaml:"db-dir,omitempty"`
+	CacheTTL       string            `yaml:"cache-ttl,omitempty"`
+	NoCache        bool              `yaml:"no-cache,omitempty"`
+	MavenRepoLocal string            `yaml:"maven-repo-local,omitempty"`
+	MavenOffline   bool              `yaml:"maven-offline,omitempty"`
+	MavenSettings  string            `yaml:"maven-settings,omitempty"`
+	MavenProfiles  []string          `yaml:"maven-profiles,omitempty"`
+	MavenArgs      []string          `yaml:"mvn-args,omitempty"`
+	CycloneDX      string            `yaml:"cyclonedx-plugin-version,omitempty"`
+	Workspace      string            `yaml:"workspace,omitempty"`
+	NoDepsTree     bool              `yaml:"no-deps-tree,omitempty"`
+	NoEffectivePOM bool              `yaml:"no-effective-pom,omitempty"`
+	IncludePlugins bool              `yaml:"include-plugins,omitempty"`
+	KeepTemp       bool              `yaml:"keep-temp,omitempty"`
+	Workdir        string            `yaml:"workdir,omitempty"`
+	WorkdirMaxSize string            `yaml:"workdir-max-size,omitempty"`
+	Parallelism    int               `yaml:"parallelism,omitempty"`
+	Timeout        string            `yaml:"timeout,omitempty"`
+	StageTimeout   string            `yaml:"stage-timeout,omitempty"`
+	Retries        *int              `yaml:"retries,omitempty"`
+	RetryBackoff   string            `yaml:"retry-backoff,omitempty"`
+	WebhookURL     string            `yaml:"webhook-url,omitempty"`
+	CommentPR      string            `yaml:"comment-pr,omitempty"`
+	OTelEndpoint   string            `yaml:"otel-endpoint,omitempty"`
+	Timings        bool              `yaml:"timings,omitempty"`
+	Sign           bool              `yaml:"sign,omitempty"`
+	SignKey        string            `yaml:"sign-key,omitempty"`
+	Attest         string            `yaml:"attest,omitempty"`
+	AttestSubjects []string          `yaml:"attest-subjects,omitempty"`
+	HistoryDB      string            `yaml:"history-db,omitempty"`
+	NoHistory      bool              `yaml:"no-history,omitempty"`
+	Baseline       string            `yaml:"baseline,omitempty"`
+	GHSA           bool              `yaml:"ghsa,omitempty"`
+	NVD            bool              `yaml:"nvd,omitempty"`
+	Exploits       bool              `yaml:"exploits,omitempty"`
+	Reachability   bool              `yaml:"reachability,omitempty"`
+	Maintenance    bool              `yaml:"maintenance,omitempty"`
+	StaleAfter     string            `yaml:"stale-after,omitempty"`
+	SupplyChain    bool              `yaml:"supply-chain,omitempty"`
+	// Maven groupIds, npm scopes or package patterns of internal packages
+	InternalNamespaces []string    `yaml:"internal-namespaces,omitempty"`
+	Secrets            bool        `yaml:"secrets,omitempty"`
+	FailOnSecret       string      `yaml:"fail-on-secret,omitempty"`
+	Quiet              bool        `yaml:"quiet,omitempty"`
+	OutputFormat       string      `yaml:"output-format,omitempty"`
+	DefectDojo         DefectDojo  `yaml:"defectdojo,omitempty"`
+	Email              EmailConfig `yaml:"email,omitempty"`
+	Tools              ToolsConfig `yaml:"tools,omitempty"`
+	Schedules          []Schedule  `yaml:"schedules,omitempty"`
+
+	// Private Maven repositories, written to a generated settings.xml
+	MavenRepositories []maven.MavenRepository `yaml:"maven-repositories,omitempty"`
+}
+
+// DefectDojo configures the findings import, the API key is read from DEFECTDOJO_TOKEN
+type DefectDojo struct {
+	URL        string `yaml:"url,omitempty"`
+	Product    string `yaml:"product,omitempty"`
+	Engagement string `yaml:"engagement,omitempty"`
+	Dedup      bool   `yaml:"dedup-on-engagement,omitempty"`
+	CloseOld   bool   `yaml:"close-old-findings,omitempty"`
+}
+
+// Schedule is a recurring scan of the serve command
+type Schedule struct {
+	Name    string   `yaml:"name"`
+	Cron    string   `yaml:"cron"`              // minute hour day-of-month month day-of-week, or @daily, @hourly, ...
+	File    string   `yaml:"file,omitempty"`    // project on the server, or the path inside the Git repository
+	Git     string   `yaml:"git,omitempty"`     // remote repository, cloned for every scan
+	Ref     string   `yaml:"ref,omitempty"`     // branch, tag or commit of the repository
+	Scanner string   `yaml:"scanner,omitempty"` // the scanner of the config file when empty
+	Reports []string `yaml:"reports,omitempty"`
+}
+
+// ToolsConfig overrides the executables used by the scanner
+type ToolsConfig struct {
+	Maven      string `yaml:"maven,omitempty"`
+	Gradle     string `yaml:"gradle,omitempty"`
+	Npm        string `yaml:"npm,omitempty"`
+	Go         string `yaml:"go,omitempty"`
+	OSVScanner string `yaml:"osv-scanner,omitempty"`
+	Grype      string `yaml:"grype,omitempty"`
+	Trivy      string `yaml:"trivy,omitempty"`
+	Chrome     string `yaml:"chrome,omitempty"`
+	Cosign     string `yaml:"cosign,omitempty"`
+	Syft       string `yaml:"syft,omitempty"`
+	Helm       string `yaml:"helm,omitempty"`
+}
+
+const ConfigTemplate = `# sbom-scanner configuration
+# Command line flags override the values in this file.
+
+# Project file or directory to scan, relative to this file
+file: %s
+
+# Output directory, relative to this file. Every run writes to a new
+# timestamped subdirectory, e.g. scan-results/20240102-150405
+output: scan-results
+
+# Empty the output directory and write into it directly instead; refused for
+# directories not created by sbom-scanner
+clean: false
+
+# Number of run directories kept in the output directory, older runs are
+# removed; 0 keeps all
+keep-last: 0
+
+# file is a Kubernetes manifest, a directory of manifests or a Helm chart:
+# scan the container images of its workloads with syft. The chart is
+# rendered with helm template and these values files.
+kubernetes: false
+helm-values: []
+
+# Scan the tags of a container registry repository instead of file, e.g.
+# ghcr.io/org/app, selected with globs such as "v1.*"; excluded tags are
+# skipped
+registry: ""
+tags: []
+exclude-tags: []
+
+# Maven dependency resolver: maven or native
+resolver: maven
+
+# Maven scopes to scan (compile, runtime, provided, system, test), empty for all
+scopes: []
+
+# Vulnerability scanner: osv, osv-binary, grype or trivy,
+# a comma-separated list or all to merge the findings of several scanners
+scanner: osv
+
+# Report formats rendered next to the JSON results
+reports: []
+
+# Dependency graph formats (dot, mermaid, graphml), vulnerable packages are highlighted
+graph: []
+
+# Fail when any vulnerability is found
+exit-on-vuln: false
+
+# Fail only for vulnerabilities at or above this severity: critical, high, medium or low
+fail-on: ""
+
+# With fail-on, also fail for vulnerabilities without any severity information
+fail-on-unknown: false
+
+# Licenses (SPDX ids) that fail the scan, e.g. [GPL-3.0, AGPL-3.0]
+fail-on-license: []
+
+# Suppressed vulnerabilities, by id (aliases match too) and/or package[@version]
+ignore: []
+#  - id: CVE-2021-44228
+#    reason: not reachable, JNDI lookups are disabled
+#    expires: 2025-12-31
+#  - package: commons-collections:commons-collections@3.2.1
+#    reason: test fixture only
+
+# Policy rules evaluated after the scan; all conditions of a rule must hold for it
+# to fire, rules with action: warn only report the violation
+policies: []
+#  - name: no-old-dependencies
+#    max-age: 5y
+#  - name: no-fixable-critical
+#    severity: critical
+#    fix-available: true
+#  - name: no-internal-forks
+#    package: com.example.forks:*
+#    action: warn
+#  - name: reproducible-builds
+#    unpinned: true
+
+# OpenVEX or CycloneDX VEX documents, not_affected and fixed statements are suppressed
+vex: []
+
+# Trivy vulnerability DB cache directory (trivy scanner), Trivy's default when empty
+trivy-cache-dir: ""
+
+# Scan without internet access using the offline OSV database ("sbom-scanner db download")
+offline: false
+
+# Offline OSV database directory, sbom-scanner/osv in the user cache directory when empty
+db-dir: ""
+
+# Reuse the scan results of an SBOM with the same components and the Maven
+# resolution of an unchanged POM for this long (0 disables)
+cache-ttl: 6h
+
+# Always query the vulnerability scanners and run Maven
+no-cache: false
+
+# Maven local repository (-Dmaven.repo.local), Maven's default when empty
+maven-repo-local: ""
+
+# Run Maven with --offline, using only the local repository
+maven-offline: false
+
+# Maven settings.xml passed to every Maven run with -s, e.g. with the mirror
+# and credentials of a Nexus or Artifactory; ~/.m2/settings.xml when empty
+maven-settings: ""
+
+# Private Maven repositories (Artifactory, Nexus, GitHub Packages) written to a
+# generated settings.xml instead of maven-settings. The credentials are read
+# from environment variables, MAVEN_REPO_TOKEN (sent as a Bearer token) or
+# MAVEN_REPO_USERNAME and MAVEN_REPO_PASSWORD unless set per repository, and
+# are never written to the file. One repository can mirror all others.
+#   - url: https://artifactory.example.com/artifactory/libs-release
+#     id: artifactory            # server id, private-1, private-2, ... when empty
+#     mirror: false
+#     username-env: ARTIFACTORY_USER
+#     password-env: ARTIFACTORY_PASSWORD
+#     token-env: ARTIFACTORY_TOKEN
+maven-repositories: []
+
+# Maven profiles activated with -P, e.g. [prod, !dev]
+maven-profiles: []
+
+# Options added to every Maven run, e.g. [-DskipTests, -T, 1C]
+mvn-args: []
+
+# Version of the CycloneDX Maven plugin, latest for the newest release
+cyclonedx-plugin-version: %s
+
+# Directory for the temporary copies of the POM that Maven runs on, so that
+# target/ is never created in the project; the system temp directory when empty
+workspace: ""
+
+# Skip the dependency tree (findings get no dependency paths, no graphs) or the
+# Maven effective POM when they are not needed, to save a Maven run per module
+no-deps-tree: false
+no-effective-pom: false
+
+# Add the Maven build plugins and extensions, with their dependencies, to the
+# SBOM as components with scope excluded, so that vulnerable build tooling is found
+include-plugins: false
+
+# Keep the Maven workspaces and Gradle build directories for debugging
+keep-temp: false
+
+# Directory for the temporary files and caches of the runs, e.g. a scratch
+# volume in CI; the system temp and user cache directories when empty. The
+# temporary files of a run are removed when it ends, the oldest cache entries
+# when the directory grows over workdir-max-size (e.g. 20GB, no limit when empty)
+workdir: ""
+workdir-max-size: ""
+
+# Maximum number of concurrently scanned modules and Maven processes, the number of CPUs when 0
+parallelism: 0
+
+# Fail the scan when it, or a single step such as the Maven resolution, takes
+# longer than this (e.g. 30m, 0 for no timeout)
+timeout: 0
+stage-timeout: 0
+
+# Retry OSV queries and Maven downloads that failed with a network error this
+# many times, waiting retry-backoff before the first retry and doubling it
+retries: 2
+retry-backoff: 2s
+
+# Findings of a previous scan, only new vulnerabilities fail the scan
+baseline: ""
+
+# Add summaries, CWE IDs and the withdrawn state from the GitHub Security
+# Advisories to the findings, the token is read from GITHUB_TOKEN
+ghsa: false
+
+# Add the CVSS v3 vectors, CWE IDs and vulnerable CPEs of the NVD to the
+# findings, an API key in NVD_API_KEY raises the rate limit
+nvd: false
+
+# Add the EPSS probabilities of FIRST and the CISA Known Exploited
+# Vulnerabilities catalog to the findings, they weight the risk score
+exploits: false
+
+# Tag the findings of Java projects as reachable or unreachable from their
+# compiled classes (build the project before the scan)
+reachability: false
+
+# Flag components whose release cycle is end of life (endoflife.date), whose
+# latest version is deprecated, or without a release for longer than stale-after
+maintenance: false
+stale-after: 2y
+
+# Flag internal packages published in public registries (dependency
+# confusion) and names similar to popular packages (typosquatting)
+supply-chain: false
+# Internal packages: Maven groupIds (with their subgroups), npm scopes or
+# package names where * matches any characters
+internal-namespaces: []
+
+# Scan the project files for API keys, tokens and private keys; fail the
+# scan on secrets at or above a severity: critical, high, medium or low
+secrets: false
+fail-on-secret: ""
+
+# Hide the progress bar and info logs and only print the final summary
+quiet: false
+
+# Format of the final summary: text or json (logs go to stderr)
+output-format: text
+
+# Scan history database, scan-history.db in the output directory when empty
+history-db: ""
+# Do not record the scans in the history database
+no-history: false
+
+# POST the normalized results as JSON to this URL after each scan,
+# signed with HMAC-SHA256 when SBOM_SCANNER_WEBHOOK_SECRET is set
+webhook-url: ""
+
+# Post the markdown report as a comment to this pull request, updated by later
+# scans; auto detects it in GitHub Actions, GitLab CI and Bitbucket Pipelines.
+# The token is read from GITHUB_TOKEN, GITLAB_TOKEN or BITBUCKET_TOKEN.
+comment-pr: ""
+
+# Export a trace with a span per module and pipeline stage to this OTLP/HTTP
+# collector, e.g. http://localhost:4318 (OTEL_EXPORTER_OTLP_ENDPOINT overrides it)
+otel-endpoint: ""
+
+# Log the duration of every pipeline stage and add the time spent per stage,
+# module, Maven goal and HTTP host to the summary
+timings: false
+
+# Sign every SBOM with cosign, writing sbom.xml.sig and sbom.xml.bundle next to
+# it. Keyless through Fulcio and Rekor unless sign-key, a cosign key file or a
+# KMS URI, is set; the key password is read from COSIGN_PASSWORD.
+sign: false
+sign-key: ""
+
+# Write an in-toto attestation of every SBOM with a cyclonedx or spdx predicate
+# (sbom.xml.intoto.json) and its SLSA provenance (sbom.provenance.json). The
+# subjects are the artifacts the SBOM describes, files or name@sha256:digest,
+# the project files when empty.
+attest: ""
+attest-subjects: []
+
+# DefectDojo import, the API key is read from DEFECTDOJO_TOKEN
+defectdojo:
+  url: ""
+  product: ""
+  # Engagement ID, or an engagement name created in the product when missing
+  engagement: ""
+  dedup-on-engagement: false
+  close-old-findings: false
+
+# Email the HTML or PDF report after each scan, the SMTP password is read from
+# SBOM_SCANNER_SMTP_PASSWORD. Port 465 uses TLS, other ports STARTTLS.
+email:
+  smtp-host: ""
+  smtp-port: 587
+  username: ""
+  from: ""
+  to: []
+  attach: [html]  # html or pdf
+
+# Executables used by the scanner. Without maven, the Maven wrapper (mvnw) of
+# the project is used when it has one, else mvn.
+tools:
+  maven: ""
+  gradle: gradle
+  npm: npm
+  go: go
+  osv-scanner: osv-scanner
+  grype: grype
+  trivy: trivy
+  # Chrome, Chromium or Edge printing the PDF report, looked up when empty
+  chrome: ""
+  cosign: cosign
+  syft: syft
+  helm: helm
+
+# Recurring scans of "sbom-scanner serve", with the notifications above. cron
+# is minute hour day-of-month month day-of-week in local time, or @hourly,
+# @daily, @weekly, @monthly. file is a project on the server, or with git the
+# path inside the repository, which is cloned for every scan.
+schedules: []
+#  - name: shop-nightly
+#    cron: "0 2 * * *"
+#    git: https://github.com/org/shop.git
+#    ref: main
+#    scanner: osv,grype
+#    reports: [html]
+`
+
+// FindConfigFile returns the config file in the project directory or the
+// working directory, if any
+func FindConfigFile(projectPath string) string {
+	var dirs []string
+	if projectPath != "" {
+		if info, err := os.Stat(projectPath); err == nil && info.IsDir() {
+			dirs = append(dirs, projectPath)
+		} else {
+			dirs = append(dirs, filepath.Dir(projectPath))
+		}
+	}
+	dirs = append(dirs, ".")
+
+	for _, dir := range dirs {
+		for _, name := range ConfigFileNames {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// LoadConfig reads a config file, resolving relative paths against its directory
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %v", path, err)
+	}
+
+	// Relative paths are resolved against the directory of the config file
+	dir := filepath.Dir(path)
+	if config.File != "" && !filepath.IsAbs(config.File) {
+		config.File = filepath.Join(dir, config.File)
+	}
+	if config.Output != "" && !filepath.IsAbs(config.Output) {
+		config.Output = filepath.Join(dir, config.Output)
+	}
+	if config.Baseline != "" && !filepath.IsAbs(config.Baseline) {
+		config.Baseline = filepath.Join(dir, config.Baseline)
+	}
+	if config.HistoryDB != "" && !filepath.IsAbs(config.HistoryDB) {
+		config.HistoryDB = filepath.Join(dir, config.HistoryDB)
+	}
+	if config.TrivyCache != "" && !filepath.IsAbs(config.TrivyCache) {
+		config.TrivyCache = filepath.Join(dir, config.TrivyCache)
+	}
+	if config.MavenSettings != "" && !filepath.IsAbs(config.MavenSettings) {
+		config.MavenSettings = filepath.Join(dir, config.MavenSettings)
+	}
+	if config.Workspace != "" && !filepath.IsAbs(config.Workspace) {
+		config.Workspace = filepath.Join(dir, config.Workspace)
+	}
+	if config.Workdir != "" && !filepath.IsAbs(config.Workdir) {
+		config.Workdir = filepath.Join(dir, config.Workdir)
+	}
+	for i, subject := range config.AttestSubjects {
+		if !strings.Contains(subject, "@sha256:") && !filepath.IsAbs(subject) {
+			config.AttestSubjects[i] = filepath.Join(dir, subject)
+		}
+	}
+	// KMS URIs such as awskms:///alias/sbom are kept
+	if config.SignKey != "" && !strings.Contains(config.SignKey, "://") && !filepath.IsAbs(config.SignKey) {
+		config.SignKey = filepath.Join(dir, config.SignKey)
+	}
+	for i, schedule := range config.Schedules {
+		if schedule.Git == "" && schedule.File != "" && !filepath.IsAbs(schedule.File) {
+			config.Schedules[i].File = filepath.Join(dir, schedule.File)
+		}
+	}
+	for i, vex := range config.VEX {
+		if !filepath.IsAbs(vex) {
+			config.VEX[i] = filepath.Join(dir, vex)
+		}
+	}
+	for i, values := range config.HelmValues {
+		if !filepath.IsAbs(values) {
+			config.HelmValues[i] = filepath.Join(dir, values)
+		}
+	}
+
+	return &config, nil
+}
+
+// Options returns the scan options of the config file, e.g. for the scans of
+// the serve command. Durations are parsed like the command line flags.
+func (c *Config) Options() (Options, error) {
+	o := Options{
+		Target:                 c.File,
+		OutputDir:              c.Output,
+		Clean:                  c.Clean,
+		KeepLast:               c.KeepLast,
+		Kubernetes:             c.Kubernetes,
+		HelmValues:             c.HelmValues,
+		Registry:               c.Registry,
+		Tags:                   c.Tags,
+		ExcludeTags:            c.ExcludeTags,
+		Resolver:               c.Resolver,
+		Reports:                c.Reports,
+		Graphs:                 c.Graph,
+		Scopes:                 c.Scopes,
+		ExitOnVuln:             c.ExitOnVuln,
+		FailOn:                 c.FailOn,
+		FailOnUnknown:          c.FailOnUnknown,
+		FailOnLicense:          c.FailOnLicense,
+		Ignore:                 c.Ignore,
+		VEX:                    c.VEX,
+		Policies:               c.Policies,
+		Baseline:               c.Baseline,
+		GHSA:                   c.GHSA,
+		NVD:                    c.NVD,
+		Exploits:               c.Exploits,
+		Reachability:           c.Reachability,
+		Maintenance:            c.Maintenance,
+		StaleAfter:             c.StaleAfter,
+		SupplyChain:            c.SupplyChain,
+		InternalNamespaces:     c.InternalNamespaces,
+		Secrets:                c.Secrets,
+		FailOnSecret:           c.FailOnSecret,
+		HistoryDB:              c.HistoryDB,
+		NoHistory:              c.NoHistory,
+		WebhookURL:             c.WebhookURL,
+		CommentPR:              c.CommentPR,
+		OTLPEndpoint:           c.OTelEndpoint,
+		Timings:                c.Timings,
+		Sign:                   c.Sign,
+		SignKey:                c.SignKey,
+		Attest:                 c.Attest,
+		AttestSubjects:         c.AttestSubjects,
+		DefectDojo:             c.DefectDojo,
+		Email:                  c.Email,
+		Tools:                  c.Tools,
+		Offline:                c.Offline,
+		DBDir:                  c.DBDir,
+		TrivyCacheDir:          c.TrivyCache,
+		MavenRepoLocal:         c.MavenRepoLocal,
+		MavenOffline:           c.MavenOffline,
+		MavenSettings:          c.MavenSettings,
+		MavenRepositories:      c.MavenRepositories,
+		MavenProfiles:          c.MavenProfiles,
+		MavenArgs:              c.MavenArgs,
+		Workspace:              c.Workspace,
+		NoDepsTree:             c.NoDepsTree,
+		NoEffectivePOM:         c.NoEffectivePOM,
+		IncludePlugins:         c.IncludePlugins,
+		KeepTemp:               c.KeepTemp,
+		Workdir:                c.Workdir,
+		WorkdirMaxSize:         c.WorkdirMaxSize,
+		CycloneDXPluginVersion: c.CycloneDX,
+		Parallelism:            c.Parallelism,
+		Retries:                c.Retries,
+		Quiet:                  c.Quiet,
+	}
+	if c.Scanner != "" {
+		o.Scanners = []string{c.Scanner}
+	}
+
+	durations := []struct {
+		key    string
+		value  string
+		target *time.Duration
+	}{
+		{"cache-ttl", c.CacheTTL, &o.CacheTTL},
+		{"timeout", c.Timeout, &o.Timeout},
+		{"stage-timeout", c.StageTimeout, &o.StageTimeout},
+		{"retry-backoff", c.RetryBackoff, &o.RetryBackoff},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		value, err := time.ParseDuration(d.value)
+		if err != nil || value < 0 {
+			return o, fmt.Errorf("invalid %s in config: %s (expected a duration such as 30m)", d.key, d.value)
+		}
+		*d.target = value
+	}
+	// A zero TTL disables the cache like --cache-ttl=0
+	if c.NoCache || (c.CacheTTL != "" && o.CacheTTL == 0) {
+		o.CacheTTL = -1
+	}
+	return o, nil
+}
+
+// configuredTools returns the default executables, replaced by the configured ones
+func configuredTools(tools ToolsConfig) map[string]string {
+	paths := maps.Clone(runenv.DefaultToolPaths)
+	for name, path := range map[string]string{
+		"mvn":         tools.Maven,
+		"gradle":      tools.Gradle,
+		"npm":         tools.Npm,
+		"go":          tools.Go,
+		"osv-scanner": tools.OSVScanner,
+		"grype":       tools.Grype,
+		"trivy":       tools.Trivy,
+		"chrome":      tools.Chrome,
+		"cosign":      tools.Cosign,
+		"syft":        tools.Syft,
+		"helm":        tools.Helm,
+	} {
+		if path != "" {
+			paths[name] = path
+		}
+	}
+	return paths
+}
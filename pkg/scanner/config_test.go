@@ -0,0 +1,49 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+func TestConfigRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		want      int
+		noHistory bool
+	}{
+		{"unset", "output: out\n", runenv.DefaultRetries, false},
+		{"disabled", "retries: 0\n", 0, false},
+		{"set", "retries: 5\nno-history: true\n", 5, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".sbom-scanner.yaml")
+			if err := os.WriteFile(path, []byte(tt.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			config, err := LoadConfig(path)
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			o, err := config.Options()
+			if err != nil {
+				t.Fatalf("Options() error = %v", err)
+			}
+			if o.NoHistory != tt.noHistory {
+				t.Errorf("NoHistory = %v, want %v", o.NoHistory, tt.noHistory)
+			}
+			s, err := o.settings()
+			if err != nil {
+				t.Fatalf("settings() error = %v", err)
+			}
+			if s.RetryCount != tt.want {
+				t.Errorf("RetryCount = %d, want %d", s.RetryCount, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// Environment variable holding the DefectDojo API v2 key
+const defectDojoTokenEnv = "DEFECTDOJO_TOKEN"
+
+// defectDojoOptions configures the import of findings into DefectDojo
+type defectDojoOptions struct {
+	url        string
+	token      string
+	product    string
+	engagement string // engagement ID, or a name looked up in the product
+	dedup      bool   // deduplicate against the whole engagement
+	closeOld   bool   // close findings of the same module missing from the import
+}
+
+// enabled reports whether findings should be exported
+func (o defectDojoOptions) enabled() bool {
+	return o.url != ""
+}
+
+// validate checks that the options identify a DefectDojo engagement
+func (o defectDojoOptions) validate() error {
+	if !o.enabled() {
+		return nil
+	}
+	if o.token == "" {
+		return fmt.Errorf("DefectDojo export needs an API key in %s", defectDojoTokenEnv)
+	}
+	if o.engagement == "" {
+		return fmt.Errorf("DefectDojo export needs --defectdojo-engagement")
+	}
+	if _, err := strconv.Atoi(o.engagement); err != nil && o.product == "" {
+		return fmt.Errorf("DefectDojo engagement %q is not an ID, --defectdojo-product is required", o.engagement)
+	}
+	return nil
+}
+
+// defectDojoReport is DefectDojo's "Generic Findings Import" format
+type defectDojoReport struct {
+	Findings []defectDojoFinding `json:"findings"`
+}
+
+type defectDojoFinding struct {
+	Title            string  `json:"title"`
+	Description      string  `json:"description"`
+	Severity         string  `json:"severity"`
+	Mitigation       string  `json:"mitigation,omitempty"`
+	References       string  `json:"references,omitempty"`
+	CVE              string  `json:"cve,omitempty"`
+	CWE              int     `json:"cwe,omitempty"`
+	CVSSv3           string  `json:"cvssv3,omitempty"`
+	CVSSv3Score      float64 `json:"cvssv3_score,omitempty"`
+	ComponentName    string  `json:"component_name"`
+	ComponentVersion string  `json:"component_version"`
+	UniqueID         string  `json:"unique_id_from_tool"`
+	VulnID           string  `json:"vuln_id_from_tool"`
+	Active           bool    `json:"active"`
+	RiskAccepted     bool    `json:"risk_accepted,omitempty"`
+}
+
+// defectDojoFindings converts normalized findings. Suppressed findings are
+// imported as inactive, risk accepted findings so that triage stays visible.
+func defectDojoFindings(all []scan.Finding, rules []scan.IgnoreRule) []defectDojoFinding {
+	findings, suppressed := scan.ApplySuppressions(all, rules)
+
+	result := make([]defectDojoFinding, 0, len(all))
+	for _, f := range append(findings, suppressed...) {
+		severity := "Info"
+		if f.Severity != scan.SeverityUnknown {
+			severity = strings.ToUpper(f.Severity[:1]) + strings.ToLower(f.Severity[1:])
+		}
+
+		description := f.Summary
+		if len(f.Aliases) > 0 {
+			description += "\n\nAliases: " + strings.Join(f.Aliases, ", ")
+		}
+		if len(f.DependencyPath) > 1 {
+			description += "\n\nDependency path: " + strings.Join(f.DependencyPath, " > ")
+		}
+		if f.Reachability != "" {
+			description += "\n\nReachability: " + f.Reachability + " from the project's code"
+		}
+		if f.Provenance != nil {
+			description += "\n\nVersion set by: " + f.Provenance.String()
+		}
+		if f.SuppressedBy != "" {
+			description += "\n\nSuppressed: " + f.SuppressedBy
+		}
+
+		var mitigation string
+		switch {
+		case f.Remediation != nil:
+			mitigation = f.Remediation.String()
+		case len(f.FixedVersions) > 0:
+			mitigation = "Upgrade " + f.Package + " to " + strings.Join(f.FixedVersions, " or ")
+		}
+
+		references := f.References
+		if f.URL != "" {
+			references = append([]string{f.URL}, references...)
+		}
+
+		var cve string
+		for _, id := range f.IDs() {
+			if strings.HasPrefix(id, "CVE-") {
+				cve = id
+				break
+			}
+		}
+
+		result = append(result, defectDojoFinding{
+			Title:            f.ID + " in " + f.Package + "@" + f.Version,
+			Description:      strings.TrimSpace(description),
+			Severity:         severity,
+			Mitigation:       mitigation,
+			References:       strings.Join(references, "\n"),
+			CVE:              cve,
+			CWE:              scan.CWENumber(f.CWEs),
+			CVSSv3:           f.CVSSVector,
+			CVSSv3Score:      f.Score,
+			ComponentName:    f.Package,
+			ComponentVersion: f.Version,
+			UniqueID:         f.ID + ":" + f.Package + "@" + f.Version,
+			VulnID:           f.ID,
+			Active:           f.SuppressedBy == "",
+			RiskAccepted:     f.SuppressedBy != "",
+		})
+	}
+	return result
+}
+
+// exportToDefectDojo imports the findings of one module through the
+// import-scan API. The module path is sent as the service, which scopes
+// close_old_findings to the module.
+func exportToDefectDojo(findingsPath, module string, rules []scan.IgnoreRule, opts defectDojoOptions) error {
+	all, err := scan.ReadFindings(findingsPath)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(defectDojoReport{Findings: defectDojoFindings(all, rules)}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode DefectDojo findings: %v", err)
+	}
+
+	fields := map[string]string{
+		"scan_type":                   "Generic Findings Import",
+		"test_title":                  "sbom-scanner " + module,
+		"service":                     module,
+		"minimum_severity":            "Info",
+		"active":                      "true",
+		"verified":                    "false",
+		"deduplication_on_engagement": strconv.FormatBool(opts.dedup),
+		"close_old_findings":          strconv.FormatBool(opts.closeOld),
+	}
+	if _, err := strconv.Atoi(opts.engagement); err == nil {
+		fields["engagement"] = opts.engagement
+	} else {
+		fields["product_name"] = opts.product
+		fields["engagement_name"] = opts.engagement
+		fields["auto_create_context"] = "true"
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			return fmt.Errorf("failed to build DefectDojo request: %v", err)
+		}
+	}
+	part, err := writer.CreateFormFile("file", "sbom-findings-defectdojo.json")
+	if err != nil {
+		return fmt.Errorf("failed to build DefectDojo request: %v", err)
+	}
+	if _, err := part.Write(data); err != nil {
+		return fmt.Errorf("failed to build DefectDojo request: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to build DefectDojo request: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(opts.url, "/")+"/api/v2/import-scan/", &body)
+	if err != nil {
+		return fmt.Errorf("failed to build DefectDojo request: %v", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Token "+opts.token)
+
+	resp, err := runenv.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("DefectDojo request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		var detail bytes.Buffer
+		detail.ReadFrom(resp.Body)
+		return fmt.Errorf("DefectDojo import failed: %s %s", resp.Status, strings.TrimSpace(detail.String()))
+	}
+
+	var result struct {
+		Test int `json:"test"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to parse DefectDojo response: %v", err)
+	}
+
+	runenv.Logger.Infof("Imported %d findings into DefectDojo test %d", len(all), result.Test)
+	return nil
+}
+
+// defectDojoToken reads the API key from the environment
+func defectDojoToken() string {
+	return strings.TrimSpace(os.Getenv(defectDojoTokenEnv))
+}
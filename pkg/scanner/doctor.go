@@ -0,0 +1,225 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/report"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+// ToolCheck is the state of an external tool in the doctor report
+type ToolCheck struct {
+	Name     string `json:"name"`
+	Path     string `json:"path,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Minimum  string `json:"minimum,omitempty"`
+	Required bool   `json:"required"`
+	Status   string `json:"status"` // ok, outdated, missing or unknown (version not recognized)
+	Error    string `json:"error,omitempty"`
+}
+
+// DoctorReport lists the external tools, written by --check --output-format=json
+type DoctorReport struct {
+	Status string      `json:"status"` // passed or failed
+	Tools  []ToolCheck `json:"tools"`
+}
+
+// doctorTool describes how to find and version an external tool
+type doctorTool struct {
+	name     string
+	path     func(s *runenv.Settings) string
+	args     []string
+	pattern  *regexp.Regexp // first group is the version
+	minimum  string
+	required bool
+}
+
+// Oldest supported versions: Maven 3.6.3 and Java 8 for the CycloneDX Maven
+// plugin, osv-scanner 1.7.0 for the --sbom and --format json output read by
+// --scanner=osv-binary
+var doctorTools = []doctorTool{
+	{
+		name:     "maven",
+		path:     runenv.SystemMaven,
+		args:     []string{"-v"},
+		pattern:  regexp.MustCompile(`Apache Maven (\d+(?:\.\d+)+)`),
+		minimum:  "3.6.3",
+		required: true,
+	},
+	{
+		name:     "java",
+		path:     func(*runenv.Settings) string { return javaPath() },
+		args:     []string{"-version"},
+		pattern:  regexp.MustCompile(`version "(?:1\.)?(\d+(?:\.\d+)*)`),
+		minimum:  "8",
+		required: true,
+	},
+	{
+		name:     "osv-scanner",
+		path:     func(s *runenv.Settings) string { return s.ToolPath("osv-scanner") },
+		args:     []string{"--version"},
+		pattern:  regexp.MustCompile(`osv-scanner version:? v?(\d+(?:\.\d+)+)`),
+		minimum:  "1.7.0",
+		required: true,
+	},
+	{
+		name:    "gradle",
+		path:    func(s *runenv.Settings) string { return s.ToolPath("gradle") },
+		args:    []string{"--version"},
+		pattern: regexp.MustCompile(`Gradle (\d+(?:\.\d+)+)`),
+		minimum: "7.0",
+	},
+	{
+		name:    "grype",
+		path:    func(s *runenv.Settings) string { return s.ToolPath("grype") },
+		args:    []string{"version"},
+		pattern: regexp.MustCompile(`Version:\s+v?(\d+(?:\.\d+)+)`),
+	},
+	{
+		name:    "trivy",
+		path:    func(s *runenv.Settings) string { return s.ToolPath("trivy") },
+		args:    []string{"--version"},
+		pattern: regexp.MustCompile(`Version: v?(\d+(?:\.\d+)+)`),
+	},
+	{
+		name:    "chrome",
+		path:    report.ChromePath,
+		args:    []string{"--version"},
+		pattern: regexp.MustCompile(`(?:Chromium|Chrome|Edge) (\d+(?:\.\d+)+)`),
+	},
+	{
+		name:    "cosign",
+		path:    func(s *runenv.Settings) string { return s.ToolPath("cosign") },
+		args:    []string{"version"},
+		pattern: regexp.MustCompile(`GitVersion:\s+v?(\d+(?:\.\d+)+)`),
+		minimum: "2.0.0",
+	},
+	{
+		name:    "syft",
+		path:    func(s *runenv.Settings) string { return s.ToolPath("syft") },
+		args:    []string{"version"},
+		pattern: regexp.MustCompile(`Version:\s+v?(\d+(?:\.\d+)+)`),
+	},
+	{
+		name:    "helm",
+		path:    func(s *runenv.Settings) string { return s.ToolPath("helm") },
+		args:    []string{"version", "--short"},
+		pattern: regexp.MustCompile(`v(\d+(?:\.\d+)+)`),
+	},
+}
+
+// javaPath returns the Java that Maven runs with: JAVA_HOME or java on PATH
+func javaPath() string {
+	if home := os.Getenv("JAVA_HOME"); home != "" {
+		if path := filepath.Join(home, "bin", "java"+runenv.ExeSuffix()); runenv.IsExecutable(path) {
+			return path
+		}
+	}
+	return "java"
+}
+
+// Doctor reports the installed versions of the external tools and compares
+// them with the oldest supported ones. The report fails when a required tool
+// is missing or too old.
+func Doctor() DoctorReport {
+	return doctor(runenv.DefaultSettings)
+}
+
+// doctor is Doctor with the tools of the settings
+func doctor(s *runenv.Settings) DoctorReport {
+	report := DoctorReport{Status: "passed"}
+	for _, tool := range doctorTools {
+		check := tool.check(s)
+		if check.Required && check.Status != "ok" && check.Status != "unknown" {
+			report.Status = "failed"
+		}
+		report.Tools = append(report.Tools, check)
+	}
+	return report
+}
+
+func (t doctorTool) check(s *runenv.Settings) ToolCheck {
+	check := ToolCheck{Name: t.name, Minimum: t.minimum, Required: t.required}
+
+	path, err := exec.LookPath(t.path(s))
+	if err != nil {
+		check.Status = "missing"
+		return check
+	}
+	check.Path = path
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	output, err := runenv.Command(ctx, path, t.args...).CombinedOutput()
+	match := t.pattern.FindSubmatch(output)
+	switch {
+	case match != nil:
+		check.Version = string(match[1])
+	case err != nil:
+		check.Status = "unknown"
+		check.Error = fmt.Sprintf("%v: %s", err, strings.TrimSpace(string(output)))
+		return check
+	default:
+		check.Status = "unknown"
+		check.Error = "version not recognized"
+		return check
+	}
+
+	check.Status = "ok"
+	if t.minimum != "" && sbom.CompareVersions(check.Version, t.minimum) < 0 {
+		check.Status = "outdated"
+	}
+	return check
+}
+
+// problems describes the required tools that fail the report
+func (r DoctorReport) problems() []string {
+	var problems []string
+	for _, check := range r.Tools {
+		switch {
+		case !check.Required:
+		case check.Status == "missing":
+			problems = append(problems, check.Name+" is not installed")
+		case check.Status == "outdated":
+			problems = append(problems, fmt.Sprintf("%s %s is older than %s", check.Name, check.Version, check.Minimum))
+		}
+	}
+	return problems
+}
+
+// WriteDoctorReport writes the doctor report as a text table or json
+func WriteDoctorReport(w io.Writer, report DoctorReport, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case "", "text":
+	default:
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOOL\tVERSION\tMINIMUM\tSTATUS\tPATH")
+	for _, check := range report.Tools {
+		status := check.Status
+		if !check.Required && status != "ok" {
+			status += " (optional)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", check.Name, check.Version, check.Minimum, status, check.Path)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "Status: %s\n", report.Status)
+	return nil
+}
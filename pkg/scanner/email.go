@@ -0,0 +1,241 @@
+package scanner
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// Environment variable holding the SMTP password
+const smtpPasswordEnv = "SBOM_SCANNER_SMTP_PASSWORD"
+
+// Report formats that can be attached to the email
+var emailAttachmentFormats = map[string]string{
+	"html": ".html",
+	"pdf":  ".pdf",
+}
+
+// EmailConfig configures the delivery of reports by email, the password is
+// read from SBOM_SCANNER_SMTP_PASSWORD
+type EmailConfig struct {
+	Host     string   `yaml:"smtp-host,omitempty"`
+	Port     int      `yaml:"smtp-port,omitempty"`
+	Username string   `yaml:"username,omitempty"`
+	From     string   `yaml:"from,omitempty"`
+	To       []string `yaml:"to,omitempty"`
+	Subject  string   `yaml:"subject,omitempty"` // fmt template, see emailSubject
+	Attach   []string `yaml:"attach,omitempty"`  // report formats, html by default
+}
+
+// enabled reports whether reports should be emailed
+func (c EmailConfig) enabled() bool {
+	return c.Host != "" && len(c.To) > 0
+}
+
+// validate checks the settings and fills in defaults
+func (c *EmailConfig) validate() error {
+	if !c.enabled() {
+		return nil
+	}
+	if c.From == "" {
+		return fmt.Errorf("email delivery needs a from address")
+	}
+	if c.Port == 0 {
+		c.Port = 587
+	}
+	if len(c.Attach) == 0 {
+		c.Attach = []string{"html"}
+	}
+	for _, format := range c.Attach {
+		if _, ok := emailAttachmentFormats[format]; !ok {
+			return fmt.Errorf("unsupported email attachment format: %s", format)
+		}
+	}
+	return nil
+}
+
+// emailSubject renders the subject; a custom subject may use %[1]s for the
+// target, %[2]s for the status and %[3]d for the number of vulnerabilities
+func emailSubject(template string, results Result, active int) string {
+	if template == "" {
+		template = "SBOM scan of %[1]s %[2]s: %[3]d vulnerabilities"
+	}
+	return fmt.Sprintf(template, results.Target, results.Status, active)
+}
+
+// emailBody summarizes the run as plain text
+func emailBody(results Result) string {
+	summary := results.Summary()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "SBOM scan of %s finished at %s\n", results.Target, results.GeneratedAt.Format(time.RFC1123))
+	fmt.Fprintf(&b, "Status: %s\n", results.Status)
+	if results.Error != "" {
+		fmt.Fprintf(&b, "Error: %s\n", results.Error)
+	}
+	fmt.Fprintf(&b, "Scanners: %s\n\n", strings.Join(results.Scanners, ", "))
+
+	fmt.Fprintf(&b, "Vulnerabilities: %d\n", summary.Vulnerabilities)
+	for _, s := range scan.SeverityOrder {
+		fmt.Fprintf(&b, "  %-8s %d\n", s, summary.Severities[s])
+	}
+	if summary.Suppressed > 0 {
+		fmt.Fprintf(&b, "Suppressed: %d\n", summary.Suppressed)
+	}
+
+	if len(results.Modules) > 1 {
+		b.WriteString("\nModules:\n")
+		for _, m := range results.Modules {
+			line := fmt.Sprintf("  %s (%s): %d vulnerabilities", m.Path, m.BuildTool, m.Vulnerabilities)
+			if m.Error != "" {
+				line += ", failed: " + m.Error
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
+
+// sendReportEmail mails the summary with the rendered reports found at
+// reportBase attached
+func sendReportEmail(config EmailConfig, results Result, reportBase string) error {
+	var attachments []string
+	for _, format := range config.Attach {
+		path := reportBase + emailAttachmentFormats[format]
+		if _, err := os.Stat(path); err != nil {
+			runenv.Logger.Warnf("Report %s not found, not attached", path)
+			continue
+		}
+		attachments = append(attachments, path)
+	}
+
+	message, err := buildEmail(config.From, config.To, emailSubject(config.Subject, results, results.Summary().Vulnerabilities),
+		emailBody(results), attachments)
+	if err != nil {
+		return err
+	}
+
+	if err := deliverEmail(config, os.Getenv(smtpPasswordEnv), message); err != nil {
+		return fmt.Errorf("failed to send email: %v", err)
+	}
+	runenv.Logger.Infof("Report emailed to %s", strings.Join(config.To, ", "))
+	return nil
+}
+
+// buildEmail creates a multipart/mixed message with a text part and the attachments
+func buildEmail(from string, to []string, subject, body string, attachments []string) ([]byte, error) {
+	var msg bytes.Buffer
+	writer := multipart.NewWriter(&msg)
+
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", writer.Boundary())
+
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"8bit"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to build email: %v", err)
+	}
+	part.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n")))
+
+	for _, path := range attachments {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read attachment: %v", err)
+		}
+
+		name := filepath.Base(path)
+		contentType := mime.TypeByExtension(filepath.Ext(name))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {contentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": name})},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to build email: %v", err)
+		}
+
+		encoded := base64.StdEncoding.EncodeToString(data)
+		for len(encoded) > 76 {
+			part.Write([]byte(encoded[:76] + "\r\n"))
+			encoded = encoded[76:]
+		}
+		part.Write([]byte(encoded + "\r\n"))
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to build email: %v", err)
+	}
+	return msg.Bytes(), nil
+}
+
+// deliverEmail sends the message. Port 465 uses implicit TLS, other ports
+// upgrade with STARTTLS when the server offers it.
+func deliverEmail(config EmailConfig, password string, message []byte) error {
+	addr := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+	var auth smtp.Auth
+	if config.Username != "" {
+		auth = smtp.PlainAuth("", config.Username, password, config.Host)
+	}
+
+	if config.Port != 465 {
+		return smtp.SendMail(addr, auth, config.From, config.To, message)
+	}
+
+	conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: config.Host})
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, config.Host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(config.From); err != nil {
+		return err
+	}
+	for _, to := range config.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
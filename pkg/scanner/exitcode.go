@@ -0,0 +1,22 @@
+package scanner
+
+import "github.com/xshuden/sbom-scanner/internal/runenv"
+
+// Exit codes of the sbom-scanner command, so that CI pipelines can tell a
+// vulnerable project from a scan that broke
+const (
+	ExitOK              = runenv.ExitOK              // no findings above the threshold
+	ExitVulnerabilities = runenv.ExitVulnerabilities // --exit-on-vuln or --fail-on exceeded
+	ExitPolicy          = runenv.ExitPolicy          // policy rule or license denylist violated
+	ExitError           = runenv.ExitError           // invalid configuration, build or tool failure
+	ExitMissingTool     = runenv.ExitMissingTool     // a required external tool is not installed
+	ExitMalicious       = runenv.ExitMalicious       // a known malicious package version is present
+)
+
+// ExitCode returns the exit code for an error of Run or a subcommand. Errors
+// that were not tagged are tool or configuration errors. Of several errors
+// joined together, e.g. of failed modules, the highest code wins, so that a
+// broken scan is never reported as merely vulnerable.
+func ExitCode(err error) int {
+	return runenv.ExitCode(err)
+}
@@ -0,0 +1,386 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// pomRange is the byte range of an element's text in a POM
+type pomRange struct {
+	start, end int
+}
+
+// pomDeclaration is a dependency declared in the POM, with the position of its version
+type pomDeclaration struct {
+	key     string // groupId:artifactId
+	version string
+	at      pomRange // empty when the dependency has no version
+	managed bool     // declared in dependencyManagement
+	start   int      // offset of <dependency>
+}
+
+// pomLayout holds the positions needed to edit a POM in place
+type pomLayout struct {
+	declarations []pomDeclaration
+	properties   map[string]pomRange
+	values       map[string]string
+	managedEnd   int    // start of </dependencies> in dependencyManagement, -1 when missing
+	depsStart    int    // start of the project's <dependencies>, -1 when missing
+	projectEnd   int    // start of </project>
+	indent       string // indentation of one level
+}
+
+// pomChange is a version bump applied to the POM
+type pomChange struct {
+	Package string `json:"package"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	How     string `json:"how"` // version, property <name> or dependencyManagement
+}
+
+// pomEdit replaces data[start:end] with text
+type pomEdit struct {
+	start, end int
+	text       string
+}
+
+// scanPOMLayout records where dependencies, their versions and properties are declared
+func scanPOMLayout(data []byte) (*pomLayout, error) {
+	layout := &pomLayout{
+		properties: make(map[string]pomRange),
+		values:     make(map[string]string),
+		managedEnd: -1,
+		depsStart:  -1,
+		projectEnd: len(data),
+		indent:     "    ",
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+
+	var stack []string
+	var current *pomDeclaration
+	var groupID, artifactID string
+	textStart := 0
+
+	for {
+		offset := int(decoder.InputOffset())
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse POM: %v", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Name.Local)
+			path := strings.Join(stack, ">")
+			switch path {
+			case "project>dependencies>dependency", "project>dependencyManagement>dependencies>dependency":
+				current = &pomDeclaration{managed: strings.Contains(path, "dependencyManagement"), start: offset}
+				groupID, artifactID = "", ""
+			case "project>dependencies":
+				layout.depsStart = offset
+				layout.indent = lineIndent(data, offset)
+			}
+			textStart = int(decoder.InputOffset())
+
+		case xml.EndElement:
+			path := strings.Join(stack, ">")
+			text := strings.TrimSpace(string(data[textStart:offset]))
+			switch {
+			case current != nil && strings.HasSuffix(path, "dependency>groupId"):
+				groupID = text
+			case current != nil && strings.HasSuffix(path, "dependency>artifactId"):
+				artifactID = text
+			case current != nil && strings.HasSuffix(path, "dependency>version"):
+				current.version = text
+				current.at = pomRange{textStart, offset}
+			case path == "project>dependencies>dependency" || path == "project>dependencyManagement>dependencies>dependency":
+				current.key = groupID + ":" + artifactID
+				layout.declarations = append(layout.declarations, *current)
+				current = nil
+			case path == "project>dependencyManagement>dependencies":
+				layout.managedEnd = offset
+			case len(stack) == 3 && stack[1] == "properties":
+				layout.properties[stack[2]] = pomRange{textStart, offset}
+				layout.values[stack[2]] = text
+			case path == "project":
+				layout.projectEnd = offset
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+	return layout, nil
+}
+
+// lineIndent returns the whitespace before offset on its line
+func lineIndent(data []byte, offset int) string {
+	start := bytes.LastIndexByte(data[:offset], '\n') + 1
+	indent := data[start:offset]
+	if len(bytes.TrimSpace(indent)) > 0 {
+		return "    "
+	}
+	return string(indent)
+}
+
+// PlanPOMChanges turns the remediations of Maven findings into edits of the
+// POM: declared versions and the properties they use are bumped, transitive
+// dependencies get a dependencyManagement override
+func PlanPOMChanges(data []byte, findings []scan.Finding) ([]pomChange, []pomEdit, error) {
+	layout, err := scanPOMLayout(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Highest suggested version per package
+	targets := make(map[string]scan.Remediation)
+	for _, f := range findings {
+		r := f.Remediation
+		if r == nil || f.Ecosystem != "Maven" {
+			continue
+		}
+		if t, ok := targets[r.Package]; !ok || sbom.CompareVersions(r.To, t.To) > 0 {
+			targets[r.Package] = *r
+		}
+	}
+
+	packages := make([]string, 0, len(targets))
+	for pkg := range targets {
+		packages = append(packages, pkg)
+	}
+	sort.Strings(packages)
+
+	var changes []pomChange
+	edited := make(map[pomRange]string) // version text per edited range
+	var added []string
+
+	for _, pkg := range packages {
+		target := targets[pkg]
+
+		var declaration *pomDeclaration
+		for _, managed := range []bool{false, true} {
+			for i, d := range layout.declarations {
+				if d.key == pkg && d.managed == managed && d.version != "" && declaration == nil {
+					declaration = &layout.declarations[i]
+				}
+			}
+		}
+
+		if declaration == nil {
+			changes = append(changes, pomChange{Package: pkg, From: target.From, To: target.To, How: "dependencyManagement"})
+			added = append(added, pkg+":"+target.To)
+			continue
+		}
+
+		at, current, how := declaration.at, declaration.version, "version"
+		if name, ok := strings.CutPrefix(current, "${"); ok && strings.HasSuffix(name, "}") {
+			name = strings.TrimSuffix(name, "}")
+			r, ok := layout.properties[name]
+			if !ok {
+				runenv.Logger.Warnf("Cannot upgrade %s: property %s is not defined in the POM", pkg, name)
+				continue
+			}
+			at, current, how = r, layout.values[name], "property "+name
+		}
+		if previous, ok := edited[at]; ok {
+			current = previous
+		}
+		if sbom.CompareVersions(current, target.To) >= 0 {
+			continue
+		}
+
+		edited[at] = target.To
+		changes = append(changes, pomChange{Package: pkg, From: current, To: target.To, How: how})
+	}
+
+	var edits []pomEdit
+	for at, version := range edited {
+		edits = append(edits, pomEdit{start: at.start, end: at.end, text: version})
+	}
+	if len(added) > 0 {
+		edits = append(edits, managedDependencyEdit(layout, added))
+	}
+	return changes, edits, nil
+}
+
+// managedDependencyEdit inserts dependencyManagement entries for
+// group:artifact:version coordinates, creating the section when the POM has none
+func managedDependencyEdit(layout *pomLayout, coordinates []string) pomEdit {
+	unit := layout.indent
+	if unit == "" {
+		unit = "    "
+	}
+	level := func(n int) string { return strings.Repeat(unit, n) }
+
+	var entries strings.Builder
+	for _, c := range coordinates {
+		parts := strings.SplitN(c, ":", 3)
+		fmt.Fprintf(&entries, "%s<dependency>\n", level(3))
+		fmt.Fprintf(&entries, "%s<groupId>%s</groupId>\n", level(4), parts[0])
+		fmt.Fprintf(&entries, "%s<artifactId>%s</artifactId>\n", level(4), parts[1])
+		fmt.Fprintf(&entries, "%s<version>%s</version>\n", level(4), parts[2])
+		fmt.Fprintf(&entries, "%s</dependency>\n", level(3))
+	}
+
+	// The insertion points follow the indentation of their line
+	if layout.managedEnd >= 0 {
+		text := strings.TrimPrefix(entries.String(), level(2)) + level(2)
+		return pomEdit{start: layout.managedEnd, end: layout.managedEnd, text: text}
+	}
+
+	section := "<dependencyManagement>\n" + level(2) + "<dependencies>\n" + entries.String() +
+		level(2) + "</dependencies>\n" + level(1) + "</dependencyManagement>\n"
+	if layout.depsStart >= 0 {
+		return pomEdit{start: layout.depsStart, end: layout.depsStart, text: section + "\n" + level(1)}
+	}
+	return pomEdit{start: layout.projectEnd, end: layout.projectEnd, text: level(1) + section}
+}
+
+// ApplyPOMEdits applies the edits back to front so that offsets stay valid
+func ApplyPOMEdits(data []byte, edits []pomEdit) []byte {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	result := append([]byte{}, data...)
+	for _, e := range edits {
+		result = append(result[:e.start], append([]byte(e.text), result[e.end:]...)...)
+	}
+	return result
+}
+
+// UnifiedDiff returns the changes between two texts as a unified diff with
+// three lines of context
+func UnifiedDiff(name string, old, new []byte) string {
+	a, b := splitLines(old), splitLines(new)
+
+	// Longest common subsequence table, POMs are small enough
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type line struct {
+		op   byte // ' ', '-' or '+'
+		text string
+		i, j int // line numbers in a and b before this line
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, line{' ', a[i], i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', a[i], i, j})
+			i++
+		default:
+			lines = append(lines, line{'+', b[j], i, j})
+			j++
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- a/%s\n+++ b/%s\n", name, name)
+
+	const context = 3
+	for k := 0; k < len(lines); {
+		if lines[k].op == ' ' {
+			k++
+			continue
+		}
+
+		// Extend the hunk while changes are within twice the context of each other
+		start := max(k-context, 0)
+		end := k
+		for end < len(lines) {
+			if lines[end].op != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(lines) && lines[next].op == ' ' {
+				next++
+			}
+			if next == len(lines) || next-end > 2*context {
+				break
+			}
+			end = next
+		}
+		end = min(end+context, len(lines))
+
+		oldCount, newCount := 0, 0
+		for _, l := range lines[start:end] {
+			if l.op != '+' {
+				oldCount++
+			}
+			if l.op != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", lines[start].i+1, oldCount, lines[start].j+1, newCount)
+		for _, l := range lines[start:end] {
+			text := l.text
+			if !strings.HasSuffix(text, "\n") {
+				text += "\n\\ No newline at end of file\n"
+			}
+			out.WriteString(string(l.op) + text)
+		}
+		k = end
+	}
+	return out.String()
+}
+
+// splitLines splits text into lines that keep their line break
+func splitLines(data []byte) []string {
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// ScanPOM scans a POM into dir and returns its active findings
+func ScanPOM(ctx context.Context, pomPath, dir string, opts ScanOptions) ([]scan.Finding, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %v", err)
+	}
+
+	p := sbom.Project{Tool: sbom.BuildToolMaven, File: pomPath, Rel: ".", Output: "."}
+	tasks, err := buildTasks(ctx, p, dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := RunTasks(ctx, tasks, "Scanning "+filepath.Base(pomPath)); err != nil {
+		return nil, err
+	}
+
+	findings, err := scan.ReadFindings(scan.FindingsPath(filepath.Join(dir, "sbom.xml")))
+	if err != nil {
+		return nil, err
+	}
+	active, _ := scan.ApplySuppressions(findings, opts.ignoreRules)
+	return active, nil
+}
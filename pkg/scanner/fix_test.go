@@ -0,0 +1,276 @@
+package scanner
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+const fixTestPOM = `<project>
+    <modelVersion>4.0.0</modelVersion>
+    <properties>
+        <jackson.version>2.13.0</jackson.version>
+    </properties>
+    <dependencies>
+        <dependency>
+            <groupId>com.fasterxml.jackson.core</groupId>
+            <artifactId>jackson-databind</artifactId>
+            <version>${jackson.version}</version>
+        </dependency>
+        <dependency>
+            <groupId>com.fasterxml.jackson.core</groupId>
+            <artifactId>jackson-core</artifactId>
+            <version>${jackson.version}</version>
+        </dependency>
+        <dependency>
+            <groupId>org.yaml</groupId>
+            <artifactId>snakeyaml</artifactId>
+            <version>1.33</version>
+        </dependency>
+    </dependencies>
+</project>
+`
+
+const fixTestManagedPOM = `<project>
+    <dependencyManagement>
+        <dependencies>
+            <dependency>
+                <groupId>io.netty</groupId>
+                <artifactId>netty-handler</artifactId>
+                <version>4.1.90.Final</version>
+            </dependency>
+        </dependencies>
+    </dependencyManagement>
+</project>
+`
+
+// remediation returns a Maven finding fixed by upgrading pkg from one version to another
+func remediation(pkg, from, to string) scan.Finding {
+	return scan.Finding{Ecosystem: "Maven", Package: pkg, Version: from, Remediation: &scan.Remediation{Package: pkg, From: from, To: to}}
+}
+
+func TestPlanPOMChanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		pom      string
+		findings []scan.Finding
+		changes  []pomChange
+		// replacements of the original POM text expected in the result
+		replace []string
+	}{
+		{
+			name:     "declared version",
+			pom:      fixTestPOM,
+			findings: []scan.Finding{remediation("org.yaml:snakeyaml", "1.33", "2.0")},
+			changes:  []pomChange{{Package: "org.yaml:snakeyaml", From: "1.33", To: "2.0", How: "version"}},
+			replace:  []string{"<version>1.33</version>", "<version>2.0</version>"},
+		},
+		{
+			name: "highest suggested version per package",
+			pom:  fixTestPOM,
+			findings: []scan.Finding{
+				remediation("org.yaml:snakeyaml", "1.33", "2.0"),
+				remediation("org.yaml:snakeyaml", "1.33", "2.2"),
+				remediation("org.yaml:snakeyaml", "1.33", "1.34"),
+			},
+			changes: []pomChange{{Package: "org.yaml:snakeyaml", From: "1.33", To: "2.2", How: "version"}},
+			replace: []string{"<version>1.33</version>", "<version>2.2</version>"},
+		},
+		{
+			name: "property shared by several packages",
+			pom:  fixTestPOM,
+			findings: []scan.Finding{
+				remediation("com.fasterxml.jackson.core:jackson-core", "2.13.0", "2.13.4"),
+				remediation("com.fasterxml.jackson.core:jackson-databind", "2.13.0", "2.13.4.2"),
+			},
+			changes: []pomChange{
+				{Package: "com.fasterxml.jackson.core:jackson-core", From: "2.13.0", To: "2.13.4", How: "property jackson.version"},
+				{Package: "com.fasterxml.jackson.core:jackson-databind", From: "2.13.4", To: "2.13.4.2", How: "property jackson.version"},
+			},
+			replace: []string{"<jackson.version>2.13.0</jackson.version>", "<jackson.version>2.13.4.2</jackson.version>"},
+		},
+		{
+			name:     "version already fixed",
+			pom:      fixTestPOM,
+			findings: []scan.Finding{remediation("org.yaml:snakeyaml", "1.30", "1.33")},
+		},
+		{
+			name: "other ecosystems are ignored",
+			pom:  fixTestPOM,
+			findings: []scan.Finding{{
+				Ecosystem:   "npm",
+				Package:     "lodash",
+				Remediation: &scan.Remediation{Package: "lodash", From: "4.17.20", To: "4.17.21"},
+			}},
+		},
+		{
+			name:     "transitive dependency without dependencyManagement",
+			pom:      fixTestPOM,
+			findings: []scan.Finding{remediation("io.netty:netty-handler", "4.1.90.Final", "4.1.94.Final")},
+			changes:  []pomChange{{Package: "io.netty:netty-handler", From: "4.1.90.Final", To: "4.1.94.Final", How: "dependencyManagement"}},
+			replace: []string{"    <dependencies>\n", `    <dependencyManagement>
+        <dependencies>
+            <dependency>
+                <groupId>io.netty</groupId>
+                <artifactId>netty-handler</artifactId>
+                <version>4.1.94.Final</version>
+            </dependency>
+        </dependencies>
+    </dependencyManagement>
+
+    <dependencies>
+`},
+		},
+		{
+			name:     "managed version",
+			pom:      fixTestManagedPOM,
+			findings: []scan.Finding{remediation("io.netty:netty-handler", "4.1.90.Final", "4.1.94.Final")},
+			changes:  []pomChange{{Package: "io.netty:netty-handler", From: "4.1.90.Final", To: "4.1.94.Final", How: "version"}},
+			replace:  []string{"4.1.90.Final", "4.1.94.Final"},
+		},
+		{
+			name:     "transitive dependency added to dependencyManagement",
+			pom:      fixTestManagedPOM,
+			findings: []scan.Finding{remediation("org.yaml:snakeyaml", "1.33", "2.0")},
+			changes:  []pomChange{{Package: "org.yaml:snakeyaml", From: "1.33", To: "2.0", How: "dependencyManagement"}},
+			replace: []string{"            </dependency>\n        </dependencies>", `            </dependency>
+            <dependency>
+                <groupId>org.yaml</groupId>
+                <artifactId>snakeyaml</artifactId>
+                <version>2.0</version>
+            </dependency>
+        </dependencies>`},
+		},
+		{
+			name: "transitive dependency without any dependencies",
+			pom:  "<project>\n    <modelVersion>4.0.0</modelVersion>\n</project>\n",
+			findings: []scan.Finding{
+				remediation("org.yaml:snakeyaml", "1.33", "2.0"),
+			},
+			changes: []pomChange{{Package: "org.yaml:snakeyaml", From: "1.33", To: "2.0", How: "dependencyManagement"}},
+			replace: []string{"</project>", `    <dependencyManagement>
+        <dependencies>
+            <dependency>
+                <groupId>org.yaml</groupId>
+                <artifactId>snakeyaml</artifactId>
+                <version>2.0</version>
+            </dependency>
+        </dependencies>
+    </dependencyManagement>
+</project>`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, edits, err := PlanPOMChanges([]byte(tt.pom), tt.findings)
+			if err != nil {
+				t.Fatalf("PlanPOMChanges() error = %v", err)
+			}
+			if !reflect.DeepEqual(changes, tt.changes) {
+				t.Errorf("changes = %+v, want %+v", changes, tt.changes)
+			}
+
+			got := string(ApplyPOMEdits([]byte(tt.pom), edits))
+			want := tt.pom
+			if tt.replace != nil {
+				want = strings.Replace(tt.pom, tt.replace[0], tt.replace[1], 1)
+			}
+			if got != want {
+				t.Errorf("patched POM:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestPlanPOMChangesUndefinedProperty(t *testing.T) {
+	pom := strings.Replace(fixTestPOM, "<jackson.version>2.13.0</jackson.version>", "", 1)
+	findings := []scan.Finding{remediation("com.fasterxml.jackson.core:jackson-core", "2.13.0", "2.13.4")}
+
+	changes, edits, err := PlanPOMChanges([]byte(pom), findings)
+	if err != nil {
+		t.Fatalf("PlanPOMChanges() error = %v", err)
+	}
+	if len(changes) != 0 || len(edits) != 0 {
+		t.Errorf("PlanPOMChanges() = %v, %v, want no changes", changes, edits)
+	}
+}
+
+// numbered returns lines 1 to n, with the given lines replaced
+func numbered(n int, replace map[int]string) []byte {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		if line, ok := replace[i]; ok {
+			b.WriteString(line + "\n")
+		} else {
+			fmt.Fprintf(&b, "%d\n", i)
+		}
+	}
+	return []byte(b.String())
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		old  []byte
+		new  []byte
+		want string
+	}{
+		{
+			name: "no changes",
+			old:  numbered(5, nil),
+			new:  numbered(5, nil),
+			want: "",
+		},
+		{
+			name: "changed line with context",
+			old:  numbered(10, nil),
+			new:  numbered(10, map[int]string{5: "five"}),
+			want: "@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "changes within twice the context share a hunk",
+			old:  numbered(10, nil),
+			new:  numbered(10, map[int]string{2: "two", 9: "nine"}),
+			want: "@@ -1,10 +1,10 @@\n 1\n-2\n+two\n 3\n 4\n 5\n 6\n 7\n 8\n-9\n+nine\n 10\n",
+		},
+		{
+			name: "distant changes get their own hunks",
+			old:  numbered(20, nil),
+			new:  numbered(20, map[int]string{2: "two", 19: "nineteen"}),
+			want: "@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n" +
+				"@@ -16,5 +16,5 @@\n 16\n 17\n 18\n-19\n+nineteen\n 20\n",
+		},
+		{
+			name: "inserted lines",
+			old:  []byte("a\nb\nc\n"),
+			new:  []byte("start\na\nb\nc\nend\n"),
+			want: "@@ -1,3 +1,5 @@\n+start\n a\n b\n c\n+end\n",
+		},
+		{
+			name: "removed lines",
+			old:  numbered(8, nil),
+			new:  []byte("1\n2\n3\n6\n7\n8\n"),
+			want: "@@ -1,8 +1,6 @@\n 1\n 2\n 3\n-4\n-5\n 6\n 7\n 8\n",
+		},
+		{
+			name: "no newline at end of file",
+			old:  []byte("a\nb"),
+			new:  []byte("a\nc"),
+			want: "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := UnifiedDiff("pom.xml", tt.old, tt.new)
+			want := "--- a/pom.xml\n+++ b/pom.xml\n" + tt.want
+			if got != want {
+				t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package scanner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// githubActions reports whether the scan runs in a GitHub Actions job
+func githubActions() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// githubAnnotation is a workflow command that GitHub shows on a line of a
+// file in the pull request and the job
+type githubAnnotation struct {
+	level   string // error, warning or notice
+	file    string
+	line    int
+	title   string
+	message string
+}
+
+func (a githubAnnotation) String() string {
+	var properties []string
+	if a.file != "" {
+		properties = append(properties, "file="+escapeGitHubProperty(a.file))
+	}
+	if a.line > 0 {
+		properties = append(properties, fmt.Sprintf("line=%d", a.line))
+	}
+	if a.title != "" {
+		properties = append(properties, "title="+escapeGitHubProperty(a.title))
+	}
+	command := "::" + a.level
+	if len(properties) > 0 {
+		command += " " + strings.Join(properties, ",")
+	}
+	return command + "::" + escapeGitHubData(a.message)
+}
+
+func escapeGitHubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeGitHubProperty(s string) string {
+	return strings.NewReplacer(":", "%3A", ",", "%2C").Replace(escapeGitHubData(s))
+}
+
+// githubLevel maps a severity to the level of its annotation
+func githubLevel(severity string) string {
+	switch severity {
+	case "CRITICAL", "HIGH":
+		return "error"
+	case "MEDIUM":
+		return "warning"
+	}
+	return "notice"
+}
+
+// reportToGitHub writes an annotation per vulnerable direct dependency to w
+// and appends the markdown report to the job summary (GITHUB_STEP_SUMMARY)
+func reportToGitHub(w io.Writer, title string, results Result, opts ScanOptions) error {
+	for _, annotation := range githubAnnotations(results, opts.ignoreRules) {
+		fmt.Fprintln(w, annotation)
+	}
+
+	path := os.Getenv("GITHUB_STEP_SUMMARY")
+	if path == "" {
+		return nil
+	}
+	content, err := runMarkdown(title, results, opts)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(content, '\n'))
+	return err
+}
+
+// setGitHubOutput sets an output of the step running the scan (GITHUB_OUTPUT)
+func setGitHubOutput(name, value string) error {
+	path := os.Getenv("GITHUB_OUTPUT")
+	if path == "" || strings.ContainsAny(value, "\r\n") {
+		return nil
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = fmt.Fprintf(file, "%s=%s\n", name, value)
+	return err
+}
+
+// githubAnnotations groups the active findings of every module by the
+// direct dependency that brings them in. Annotations of a POM point to the
+// line declaring the dependency, other build files are annotated as a whole.
+func githubAnnotations(results Result, rules []scan.IgnoreRule) []githubAnnotation {
+	// File paths are relative to the checked out repository
+	workspace := os.Getenv("GITHUB_WORKSPACE")
+	if workspace == "" {
+		workspace, _ = os.Getwd()
+	}
+
+	var annotations []githubAnnotation
+	for _, m := range results.Modules {
+		all, err := scan.ReadFindings(scan.FindingsPath(filepath.Join(m.OutputDir, "sbom.xml")))
+		if err != nil {
+			continue
+		}
+		findings, _ := scan.ApplySuppressions(all, rules)
+		if len(findings) == 0 {
+			continue
+		}
+
+		file := m.ProjectFile
+		if abs, err := filepath.Abs(file); err == nil {
+			if rel, err := filepath.Rel(workspace, abs); err == nil && !strings.HasPrefix(rel, "..") {
+				file = rel
+			}
+		}
+		file = filepath.ToSlash(file)
+
+		lines := make(map[string]int)
+		if m.BuildTool == sbom.BuildToolMaven && filepath.Ext(m.ProjectFile) == ".xml" {
+			lines = pomDeclarationLines(m.ProjectFile)
+		}
+
+		type group struct {
+			dependency string
+			severity   string
+			findings   []scan.Finding
+		}
+		var groups []*group
+		byDependency := make(map[string]*group)
+		for _, f := range findings {
+			dependency := f.Package
+			if len(f.DependencyPath) > 0 {
+				dependency, _, _ = runenv.CutLast(f.DependencyPath[0], "@")
+			}
+			g, ok := byDependency[dependency]
+			if !ok {
+				g = &group{dependency: dependency, severity: f.Severity}
+				byDependency[dependency] = g
+				groups = append(groups, g)
+			}
+			g.findings = append(g.findings, f)
+			if scan.SeverityRank(f.Severity) > scan.SeverityRank(g.severity) {
+				g.severity = f.Severity
+			}
+		}
+		// GitHub shows a limited number of annotations per step, the most severe first
+		sort.SliceStable(groups, func(i, j int) bool {
+			return scan.SeverityRank(groups[i].severity) > scan.SeverityRank(groups[j].severity)
+		})
+
+		for _, g := range groups {
+			var message []string
+			for _, f := range g.findings {
+				line := fmt.Sprintf("%s %s in %s@%s", f.ID, f.Severity, f.Package, f.Version)
+				if len(f.FixedVersions) > 0 {
+					line += " (fixed in " + strings.Join(f.FixedVersions, ", ") + ")"
+				}
+				message = append(message, line)
+			}
+			annotations = append(annotations, githubAnnotation{
+				level:   githubLevel(g.severity),
+				file:    file,
+				line:    lines[g.dependency],
+				title:   fmt.Sprintf("%s: %d vulnerabilities", g.dependency, len(g.findings)),
+				message: strings.Join(message, "\n"),
+			})
+		}
+	}
+	return annotations
+}
+
+// pomDeclarationLines returns the line declaring each dependency of a POM,
+// preferring the dependencies section over dependencyManagement
+func pomDeclarationLines(pomPath string) map[string]int {
+	lines := make(map[string]int)
+	data, err := os.ReadFile(pomPath)
+	if err != nil {
+		return lines
+	}
+	layout, err := scanPOMLayout(data)
+	if err != nil {
+		return lines
+	}
+	for _, d := range layout.declarations {
+		if _, ok := lines[d.key]; ok && d.managed {
+			continue
+		}
+		lines[d.key] = bytes.Count(data[:d.start], []byte("\n")) + 1
+	}
+	return lines
+}
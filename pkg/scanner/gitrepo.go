@@ -0,0 +1,130 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+// scp-like Git URLs: user@host:path, without the :: of the ext:: transport
+var scpGitURLPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*@[A-Za-z0-9][A-Za-z0-9.-]*:[A-Za-z0-9._~/][^:\s]*$`)
+
+// validateGitURL accepts remote repositories only: HTTPS and SSH URLs or the
+// scp-like git@host:path syntax. Local paths, plain http:// and the file://
+// and ext:: transports are refused since the URL may come from an API request.
+func validateGitURL(repoURL string) error {
+	switch {
+	case strings.ContainsAny(repoURL, " \t\r\n"):
+	case strings.HasPrefix(repoURL, "https://"), strings.HasPrefix(repoURL, "ssh://"):
+		return nil
+	case scpGitURLPattern.MatchString(repoURL):
+		return nil
+	}
+	return fmt.Errorf("unsupported Git URL: %s (expected https://, ssh:// or git@host:path)", repoURL)
+}
+
+// validateGitRef refuses refs that git would parse as an option, such as
+// --upload-pack=..., since the ref may come from an API request
+func validateGitRef(ref string) error {
+	if strings.HasPrefix(ref, "-") || strings.ContainsAny(ref, " \t\r\n") {
+		return fmt.Errorf("invalid Git ref: %s", ref)
+	}
+	return nil
+}
+
+// cloneGitRepository makes a shallow clone of the repository into dir at ref,
+// a branch, tag or commit, or the default branch when ref is empty
+func cloneGitRepository(ctx context.Context, repoURL, ref, dir string) error {
+	if err := validateGitURL(repoURL); err != nil {
+		return err
+	}
+	if err := validateGitRef(ref); err != nil {
+		return err
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return runenv.MissingTool("git is not installed, it is needed to scan Git repositories")
+	}
+
+	args := []string{"clone", "--quiet", "--depth", "1"}
+	if ref != "" {
+		args = append(args, "--branch", ref)
+	}
+	err := runGit(ctx, "", append(args, "--", repoURL, dir)...)
+	if err == nil || ref == "" {
+		return err
+	}
+
+	// --branch only takes branches and tags, a commit is fetched on its own
+	os.RemoveAll(dir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %v", err)
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"remote", "add", "origin", repoURL},
+		{"fetch", "--quiet", "--depth", "1", "--end-of-options", "origin", ref},
+		{"checkout", "--quiet", "FETCH_HEAD"},
+	} {
+		if err := runGit(ctx, dir, args...); err != nil {
+			return fmt.Errorf("failed to check out %s: %v", ref, err)
+		}
+	}
+	return nil
+}
+
+// checkoutRepository clones the repository into a temporary directory below
+// the workspace and returns the path of the project to scan in it, subpath or
+// the repository root. cleanup removes the clone unless --keep-temp is set.
+func checkoutRepository(ctx context.Context, repoURL, ref, subpath string) (string, func(), error) {
+	if subpath != "" && !filepath.IsLocal(subpath) {
+		return "", nil, fmt.Errorf("invalid path in the repository: %s", subpath)
+	}
+	dir, err := runenv.NewTempDir(ctx, "sbom-scanner-git-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	cleanup := func() {
+		if runenv.SettingsOf(ctx).KeepTemp {
+			runenv.Logger.Infof("Keeping the clone of %s in %s", repoURL, dir)
+			return
+		}
+		os.RemoveAll(dir)
+	}
+
+	runenv.Logger.Infof("Cloning %s", gitTarget(repoURL, ref, ""))
+	if err := cloneGitRepository(ctx, repoURL, ref, filepath.Join(dir, "repo")); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+	return filepath.Join(dir, "repo", subpath), cleanup, nil
+}
+
+// gitTarget names a scanned repository in the results and the history, e.g.
+// https://github.com/org/app.git@v1.2.3//services/api
+func gitTarget(repoURL, ref, subpath string) string {
+	target := repoURL
+	if ref != "" {
+		target += "@" + ref
+	}
+	if subpath != "" && subpath != "." {
+		target += "//" + filepath.ToSlash(subpath)
+	}
+	return target
+}
+
+// runGit runs git without prompting for credentials, which would hang
+func runGit(ctx context.Context, dir string, args ...string) error {
+	cmd := runenv.Command(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(cmd.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s failed: %v: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package scanner
+
+import "testing"
+
+func TestValidateGitURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		valid bool
+	}{
+		{"https://github.com/org/app.git", true},
+		{"ssh://git@github.com/org/app.git", true},
+		{"git@github.com:org/app.git", true},
+		{"http://github.com/org/app.git", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"file:///etc", false},
+		{"/srv/repos/app.git", false},
+		{"../app", false},
+		{"ext::sh -c touch% /tmp/pwned", false},
+		{"git://github.com/org/app.git", false},
+		{"https://github.com/org/app.git --upload-pack=touch", false},
+		{"-uhttps://github.com/org/app.git", false},
+	}
+
+	for _, tt := range tests {
+		err := validateGitURL(tt.url)
+		if tt.valid && err != nil {
+			t.Errorf("validateGitURL(%q) error = %v", tt.url, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("validateGitURL(%q) did not fail", tt.url)
+		}
+	}
+}
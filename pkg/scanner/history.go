@@ -0,0 +1,312 @@
+package scanner
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+	_ "modernc.org/sqlite"
+)
+
+// Default history database, kept in the output directory across runs
+const HistoryFileName = "scan-history.db"
+
+// How long a write waits for another connection, e.g. another serve worker,
+// to release the database
+const historyBusyTimeout = 10 * time.Second
+
+const historySchema = `
+CREATE TABLE IF NOT EXISTS scans (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	started_at      TEXT NOT NULL,
+	finished_at     TEXT NOT NULL,
+	target          TEXT NOT NULL,
+	scanners        TEXT NOT NULL,
+	status          TEXT NOT NULL,
+	error           TEXT NOT NULL DEFAULT '',
+	vulnerabilities INTEGER NOT NULL,
+	suppressed      INTEGER NOT NULL,
+	risk_score      REAL NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS scans_target ON scans(target);
+
+CREATE TABLE IF NOT EXISTS scan_modules (
+	scan_id         INTEGER NOT NULL REFERENCES scans(id) ON DELETE CASCADE,
+	path            TEXT NOT NULL,
+	project_file    TEXT NOT NULL,
+	build_tool      TEXT NOT NULL,
+	sbom_sha256     TEXT NOT NULL,
+	vulnerabilities INTEGER NOT NULL,
+	error           TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS scan_modules_scan ON scan_modules(scan_id);
+
+CREATE TABLE IF NOT EXISTS scan_findings (
+	scan_id          INTEGER NOT NULL REFERENCES scans(id) ON DELETE CASCADE,
+	vulnerability_id TEXT NOT NULL,
+	package          TEXT NOT NULL,
+	version          TEXT NOT NULL,
+	ecosystem        TEXT NOT NULL,
+	severity         TEXT NOT NULL,
+	score            REAL NOT NULL,
+	suppressed_by    TEXT NOT NULL DEFAULT '',
+	finding          TEXT NOT NULL -- normalized finding as JSON
+);
+CREATE INDEX IF NOT EXISTS scan_findings_scan ON scan_findings(scan_id);
+`
+
+// HistoryScan is a row of the scans table
+type HistoryScan struct {
+	ID              int64           `json:"id"`
+	StartedAt       time.Time       `json:"started_at"`
+	FinishedAt      time.Time       `json:"finished_at"`
+	Target          string          `json:"target"`
+	Scanners        []string        `json:"scanners"`
+	Status          string          `json:"status"`
+	Error           string          `json:"error,omitempty"`
+	Vulnerabilities int             `json:"vulnerabilities"`
+	Suppressed      int             `json:"suppressed"`
+	RiskScore       float64         `json:"risk_score"`
+	Modules         []historyModule `json:"modules,omitempty"`
+	Findings        []scan.Finding  `json:"findings,omitempty"`
+}
+
+type historyModule struct {
+	Path            string `json:"path"`
+	ProjectFile     string `json:"project_file"`
+	BuildTool       string `json:"build_tool"`
+	SBOMHash        string `json:"sbom_sha256"`
+	Vulnerabilities int    `json:"vulnerabilities"`
+	Error           string `json:"error,omitempty"`
+}
+
+// historyDSN returns the file: URI of the database. The pragmas are set in the
+// URI so that every pooled connection gets them, and the path is escaped so
+// that a ? or # in it is not taken for the query or the fragment.
+func historyDSN(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // C:/... on Windows
+	}
+	dsn := url.URL{
+		Scheme:   "file",
+		Path:     path,
+		RawQuery: fmt.Sprintf("_pragma=foreign_keys(1)&_pragma=busy_timeout(%d)", historyBusyTimeout.Milliseconds()),
+	}
+	return dsn.String()
+}
+
+// OpenHistory opens the history database, creating it when missing
+func OpenHistory(path string) (*sql.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", historyDSN(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %v", err)
+	}
+	if _, err := db.Exec(historySchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create history schema: %v", err)
+	}
+	if err := migrateHistory(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// migrateHistory adds the columns of newer versions to an existing database
+func migrateHistory(db *sql.DB) error {
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('scans') WHERE name = 'risk_score'`).Scan(&count); err != nil {
+		return fmt.Errorf("failed to migrate history database: %v", err)
+	}
+	if count == 0 {
+		if _, err := db.Exec(`ALTER TABLE scans ADD COLUMN risk_score REAL NOT NULL DEFAULT 0`); err != nil {
+			return fmt.Errorf("failed to migrate history database: %v", err)
+		}
+	}
+	return nil
+}
+
+// recordScan stores the results of a run in the history database
+func recordScan(path string, results Result, startedAt time.Time) (int64, error) {
+	db, err := OpenHistory(path)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	target := HistoryTarget(results.Target)
+
+	summary := results.Summary()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to record scan: %v", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO scans (started_at, finished_at, target, scanners, status, error, vulnerabilities, suppressed, risk_score)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		startedAt.UTC().Format(time.RFC3339), results.GeneratedAt.UTC().Format(time.RFC3339), target,
+		strings.Join(results.Scanners, ","), results.Status, results.Error,
+		summary.Vulnerabilities, summary.Suppressed, summary.RiskScore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record scan: %v", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to record scan: %v", err)
+	}
+
+	for _, m := range results.Modules {
+		hash, err := runenv.FileSHA256(filepath.Join(m.OutputDir, "sbom.xml"))
+		if err != nil {
+			hash = ""
+		}
+		if _, err := tx.Exec(`INSERT INTO scan_modules (scan_id, path, project_file, build_tool, sbom_sha256, vulnerabilities, error)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			id, m.Path, m.ProjectFile, string(m.BuildTool), hash, m.Vulnerabilities, m.Error); err != nil {
+			return 0, fmt.Errorf("failed to record scan: %v", err)
+		}
+	}
+
+	err = results.EachFinding(func(f scan.Finding) error {
+		data, err := json.Marshal(f)
+		if err != nil {
+			return fmt.Errorf("failed to encode finding: %v", err)
+		}
+		if _, err := tx.Exec(`INSERT INTO scan_findings (scan_id, vulnerability_id, package, version, ecosystem, severity, score, suppressed_by, finding)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, f.ID, f.Package, f.Version, f.Ecosystem, f.Severity, f.Score, f.SuppressedBy, string(data)); err != nil {
+			return fmt.Errorf("failed to record scan: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to record scan: %v", err)
+	}
+	return id, nil
+}
+
+// HistoryTarget makes local targets absolute, so that the scans of a project
+// are found from any directory. Git repositories are kept as they are.
+func HistoryTarget(target string) string {
+	if validateGitURL(target) == nil {
+		return target
+	}
+	if abs, err := filepath.Abs(target); err == nil {
+		return abs
+	}
+	return target
+}
+
+// ListScans returns the most recent scans, optionally for one target only
+func ListScans(db *sql.DB, target string, limit int) ([]HistoryScan, error) {
+	query := `SELECT id, started_at, finished_at, target, scanners, status, error, vulnerabilities, suppressed, risk_score FROM scans`
+	var args []any
+	if target != "" {
+		query += ` WHERE target = ?`
+		args = append(args, target)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %v", err)
+	}
+	defer rows.Close()
+
+	var scans []HistoryScan
+	for rows.Next() {
+		s, err := scanHistoryRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		scans = append(scans, s)
+	}
+	return scans, rows.Err()
+}
+
+// LoadScan returns a scan with its modules and findings
+func LoadScan(db *sql.DB, id int64) (HistoryScan, error) {
+	row := db.QueryRow(`SELECT id, started_at, finished_at, target, scanners, status, error, vulnerabilities, suppressed, risk_score
+		FROM scans WHERE id = ?`, id)
+	s, err := scanHistoryRow(row)
+	if err == sql.ErrNoRows {
+		return s, fmt.Errorf("scan %d not found", id)
+	}
+	if err != nil {
+		return s, err
+	}
+
+	rows, err := db.Query(`SELECT path, project_file, build_tool, sbom_sha256, vulnerabilities, error
+		FROM scan_modules WHERE scan_id = ? ORDER BY path`, id)
+	if err != nil {
+		return s, fmt.Errorf("failed to read history: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m historyModule
+		if err := rows.Scan(&m.Path, &m.ProjectFile, &m.BuildTool, &m.SBOMHash, &m.Vulnerabilities, &m.Error); err != nil {
+			return s, fmt.Errorf("failed to read history: %v", err)
+		}
+		s.Modules = append(s.Modules, m)
+	}
+	if err := rows.Err(); err != nil {
+		return s, fmt.Errorf("failed to read history: %v", err)
+	}
+
+	findings, err := db.Query(`SELECT finding FROM scan_findings WHERE scan_id = ?`, id)
+	if err != nil {
+		return s, fmt.Errorf("failed to read history: %v", err)
+	}
+	defer findings.Close()
+	for findings.Next() {
+		var data string
+		var f scan.Finding
+		if err := findings.Scan(&data); err != nil {
+			return s, fmt.Errorf("failed to read history: %v", err)
+		}
+		if err := json.Unmarshal([]byte(data), &f); err != nil {
+			return s, fmt.Errorf("failed to parse finding: %v", err)
+		}
+		s.Findings = append(s.Findings, f)
+	}
+	scan.SortFindings(s.Findings)
+	return s, findings.Err()
+}
+
+// scanHistoryRow reads a scans row from sql.Row or sql.Rows
+func scanHistoryRow(row interface{ Scan(...any) error }) (HistoryScan, error) {
+	var s HistoryScan
+	var started, finished, scanners string
+	if err := row.Scan(&s.ID, &started, &finished, &s.Target, &scanners, &s.Status, &s.Error, &s.Vulnerabilities, &s.Suppressed, &s.RiskScore); err != nil {
+		if err == sql.ErrNoRows {
+			return s, err
+		}
+		return s, fmt.Errorf("failed to read history: %v", err)
+	}
+	s.StartedAt, _ = time.Parse(time.RFC3339, started)
+	s.FinishedAt, _ = time.Parse(time.RFC3339, finished)
+	s.Scanners = strings.Split(scanners, ",")
+	return s, nil
+}
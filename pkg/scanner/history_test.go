@@ -0,0 +1,31 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenHistoryPath(t *testing.T) {
+	for _, name := range []string{"scan-history.db", "what?.db", "run #1/history.db", "100%/history.db"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			db, err := OpenHistory(path)
+			if err != nil {
+				t.Fatalf("OpenHistory() error = %v", err)
+			}
+			defer db.Close()
+
+			var foreignKeys int
+			if err := db.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+				t.Fatal(err)
+			}
+			if foreignKeys != 1 {
+				t.Errorf("foreign_keys = %d, want 1", foreignKeys)
+			}
+			if _, err := os.Stat(path); err != nil {
+				t.Errorf("database not created at %s: %v", path, err)
+			}
+		})
+	}
+}
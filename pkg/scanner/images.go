@@ -0,0 +1,142 @@
+package scanner
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// writeServiceReport writes the findings of every service, counted from the
+// scans of its modules, to aggregated-services.json
+func writeServiceReport(outputDir string, services []sbom.ComposeService, rules []scan.IgnoreRule) error {
+	var err error
+	for i := range services {
+		modules := make([]sbom.ServiceModule, len(services[i].Modules))
+		copy(modules, services[i].Modules)
+		for j := range modules {
+			m := &modules[j]
+			m.OutputDir = filepath.Join(outputDir, m.OutputDir)
+			if m.Vulnerabilities, m.Severities, err = countModuleFindings(m.OutputDir, rules); err != nil {
+				m.Error = "not scanned"
+			}
+		}
+		services[i].Modules = modules
+	}
+
+	data, err := json.MarshalIndent(services, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode service report: %v", err)
+	}
+	path := filepath.Join(outputDir, "aggregated-services.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write service report: %v", err)
+	}
+	runenv.Logger.Infof("Service report written to %s", path)
+	return nil
+}
+
+// writeWorkloadReport writes the findings of every workload, counted from
+// the scans of its images, to aggregated-workloads.json
+func writeWorkloadReport(outputDir string, workloads []sbom.Workload, rules []scan.IgnoreRule) error {
+	var err error
+	for i := range workloads {
+		for j := range workloads[i].Images {
+			image := &workloads[i].Images[j]
+			image.OutputDir = filepath.Join(outputDir, sbom.ImageOutputDir(image.Image))
+			if image.Vulnerabilities, image.Severities, err = countModuleFindings(image.OutputDir, rules); err != nil {
+				image.Error = "not scanned"
+			}
+		}
+	}
+	sort.SliceStable(workloads, func(i, j int) bool {
+		a, b := workloads[i], workloads[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+
+	data, err := json.MarshalIndent(workloads, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode workload report: %v", err)
+	}
+	path := filepath.Join(outputDir, "aggregated-workloads.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write workload report: %v", err)
+	}
+	runenv.Logger.Infof("Workload report written to %s", path)
+	return nil
+}
+
+// countModuleFindings returns the number of active findings of the module
+// scanned into dir, in total and per severity
+func countModuleFindings(dir string, rules []scan.IgnoreRule) (int, map[string]int, error) {
+	findings, err := scan.ReadFindings(scan.FindingsPath(filepath.Join(dir, "sbom.xml")))
+	if err != nil {
+		return 0, nil, err
+	}
+	active, _ := scan.ApplySuppressions(findings, rules)
+	severities := make(map[string]int)
+	for _, f := range active {
+		severities[f.Severity]++
+	}
+	return len(active), severities, nil
+}
+
+// writeTagMatrix writes the findings of every scanned tag to
+// aggregated-tags.json and, as a matrix of tags and severities, to
+// aggregated-tags.csv
+func writeTagMatrix(outputDir string, projects []sbom.Project, rules []scan.IgnoreRule) error {
+	var tags []sbom.RegistryTag
+	for _, p := range projects {
+		// Tags cannot contain colons, the tag follows the last one
+		tag := p.File[strings.LastIndex(p.File, ":")+1:]
+		t := sbom.RegistryTag{Tag: tag, Image: p.File, OutputDir: filepath.Join(outputDir, p.Output)}
+		var err error
+		if t.Vulnerabilities, t.Severities, err = countModuleFindings(t.OutputDir, rules); err != nil {
+			t.Error = "not scanned"
+		}
+		tags = append(tags, t)
+	}
+
+	data, err := json.MarshalIndent(tags, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode tag report: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, "aggregated-tags.json"), data, 0644); err != nil {
+		return fmt.Errorf("failed to write tag report: %v", err)
+	}
+
+	severities := []string{scan.SeverityCritical, scan.SeverityHigh, scan.SeverityMedium, scan.SeverityLow, scan.SeverityUnknown}
+	records := [][]string{append(append([]string{"tag"}, severities...), "total", "error")}
+	for _, t := range tags {
+		row := []string{t.Tag}
+		for _, severity := range severities {
+			row = append(row, strconv.Itoa(t.Severities[severity]))
+		}
+		records = append(records, append(row, strconv.Itoa(t.Vulnerabilities), t.Error))
+	}
+	path := filepath.Join(outputDir, "aggregated-tags.csv")
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to write tag matrix: %v", err)
+	}
+	defer file.Close()
+	if err := csv.NewWriter(file).WriteAll(records); err != nil {
+		return fmt.Errorf("failed to write tag matrix: %v", err)
+	}
+	runenv.Logger.Infof("Tag matrix written to %s", path)
+	return nil
+}
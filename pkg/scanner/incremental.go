@@ -0,0 +1,317 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/maven"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+// Files of a run directory that belong to the run rather than to the module
+// written to its root
+var runFiles = map[string]bool{
+	"summary.json":    true,
+	sbom.OutputMarker: true,
+}
+
+// gitLines runs git in dir and returns the lines it prints
+func gitLines(ctx context.Context, dir string, args ...string) ([]string, error) {
+	cmd := runenv.Command(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(cmd.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s failed: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	var lines []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// realPath returns the absolute path of a file with symlinks resolved, so
+// paths printed by git compare with the ones of the scan
+func realPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved
+	}
+	// Deleted files are compared by their directory
+	if dir, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+		return filepath.Join(dir, filepath.Base(abs))
+	}
+	return abs
+}
+
+// gitCheckout returns the root directory of the Git checkout holding target
+func gitCheckout(ctx context.Context, target string) (string, error) {
+	dir := target
+	if info, err := os.Stat(target); err == nil && !info.IsDir() {
+		dir = filepath.Dir(target)
+	}
+	top, err := gitLines(ctx, dir, "rev-parse", "--show-toplevel")
+	if err != nil || len(top) == 0 {
+		return "", fmt.Errorf("%s is not in a Git checkout", target)
+	}
+	return top[0], nil
+}
+
+// gitState returns the commit checked out in the Git checkout holding target
+// and the files with uncommitted changes, untracked ones included, relative
+// to its root. Both are empty outside of a checkout.
+func gitState(ctx context.Context, target string) (string, []string) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return "", nil
+	}
+	root, err := gitCheckout(ctx, target)
+	if err != nil {
+		return "", nil
+	}
+	commit, err := gitLines(ctx, root, "rev-parse", "--verify", "--quiet", "HEAD^{commit}")
+	if err != nil || len(commit) == 0 {
+		return "", nil
+	}
+	diff, err := gitLines(ctx, root, "diff", "--name-only", "--no-renames", "HEAD", "--")
+	if err != nil {
+		return "", nil
+	}
+	untracked, err := gitLines(ctx, root, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return "", nil
+	}
+	return commit[0], append(diff, untracked...)
+}
+
+// shortCommit abbreviates a commit hash for the log
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
+
+// gitChangedFiles returns the files of the checkout in root that differ from
+// any of refs in the working tree, committed or not, and the untracked ones,
+// as real paths
+func gitChangedFiles(ctx context.Context, root string, refs ...string) (map[string]bool, error) {
+	var names []string
+	for _, ref := range refs {
+		if _, err := gitLines(ctx, root, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err != nil {
+			return nil, fmt.Errorf("unknown Git ref: %s", ref)
+		}
+		diff, err := gitLines(ctx, root, "diff", "--name-only", "--no-renames", ref, "--")
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, diff...)
+	}
+	untracked, err := gitLines(ctx, root, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	changed := make(map[string]bool)
+	for _, name := range append(names, untracked...) {
+		changed[realPath(filepath.Join(root, filepath.FromSlash(name)))] = true
+	}
+	return changed, nil
+}
+
+// projectManifests returns the files a module is resolved from: its project
+// file, the files read together with it, the local parent POMs of a Maven
+// module and the settings and version catalogs of the Gradle builds above a
+// Gradle module, up to root
+func projectManifests(p sbom.Project, root string) []string {
+	dir := filepath.Dir(p.File)
+	manifests := []string{p.File}
+	for _, name := range watchCompanions[filepath.Base(p.File)] {
+		manifests = append(manifests, filepath.Join(dir, name))
+	}
+
+	switch p.Tool {
+	case sbom.BuildToolMaven:
+		data, err := os.ReadFile(p.File)
+		if err != nil {
+			break
+		}
+		current, err := maven.ParsePOM(data)
+		for err == nil && current.Parent != nil {
+			parent, path, ok := maven.LocalParent(current.Parent, dir)
+			if !ok {
+				break
+			}
+			manifests = append(manifests, path)
+			current, dir = parent, filepath.Dir(path)
+		}
+	case sbom.BuildToolGradle:
+		root = realPath(root)
+		for {
+			for _, name := range []string{"settings.gradle", "settings.gradle.kts", "build.gradle", "build.gradle.kts", "gradle/libs.versions.toml", "gradle.lockfile"} {
+				manifests = append(manifests, filepath.Join(dir, filepath.FromSlash(name)))
+			}
+			parent := filepath.Dir(dir)
+			if realPath(dir) == root || parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+	return manifests
+}
+
+// previousRun returns the directory of the newest completed run in the output
+// directory: a timestamped run or the output directory itself after --clean.
+// Runs that were canceled or timed out have no summary.json.
+func previousRun(outputDir string) (string, *Summary) {
+	candidates := []string{outputDir}
+	entries, _ := os.ReadDir(outputDir)
+	for _, entry := range entries {
+		if entry.IsDir() && isRunDir(entry.Name()) {
+			candidates = append(candidates, filepath.Join(outputDir, entry.Name()))
+		}
+	}
+
+	var newest string
+	var newestTime int64
+	for _, dir := range candidates {
+		info, err := os.Stat(filepath.Join(dir, "summary.json"))
+		if err == nil && info.ModTime().UnixNano() > newestTime {
+			newest, newestTime = dir, info.ModTime().UnixNano()
+		}
+	}
+	if newest == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(filepath.Join(newest, "summary.json"))
+	if err != nil {
+		return "", nil
+	}
+	var summary Summary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		runenv.Logger.Warnf("Ignoring the previous run in %s: failed to parse its summary: %v", newest, err)
+		return "", nil
+	}
+	return newest, &summary
+}
+
+// markUnchangedProjects looks up the modules whose manifests did not change
+// since ref, nor since the commit the previous run in the output directory
+// scanned, and that this run scanned without an error, and sets the run
+// directory their results are reused from. Modules that are new, changed or
+// failed before are scanned again, and all of them when the previous run
+// scanned another target or recorded no commit.
+func markUnchangedProjects(ctx context.Context, target, displayTarget, ref, outputDir string, projects []sbom.Project) error {
+	if strings.HasPrefix(ref, "-") {
+		return fmt.Errorf("invalid Git ref: %s", ref)
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		return runenv.MissingTool("git is not installed, it is needed for --since")
+	}
+	checkout, err := gitCheckout(ctx, target)
+	if err != nil {
+		return fmt.Errorf("--since needs a Git checkout, %s is not in one", target)
+	}
+	if _, err := gitLines(ctx, checkout, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err != nil {
+		return fmt.Errorf("unknown Git ref: %s", ref)
+	}
+	previous, summary := previousRun(outputDir)
+	switch {
+	case previous == "":
+		runenv.Logger.Infof("No previous run in %s to reuse, scanning all %d modules", outputDir, len(projects))
+		return nil
+	case summary.Target != displayTarget:
+		runenv.Logger.Infof("The previous run in %s scanned %s, scanning all %d modules", previous, summary.Target, len(projects))
+		return nil
+	case summary.Commit == "":
+		runenv.Logger.Infof("The previous run in %s recorded no Git commit, scanning all %d modules", previous, len(projects))
+		return nil
+	}
+	if _, err := gitLines(ctx, checkout, "rev-parse", "--verify", "--quiet", summary.Commit+"^{commit}"); err != nil {
+		runenv.Logger.Infof("Commit %s of the previous run in %s is not in the local history, scanning all %d modules", summary.Commit, previous, len(projects))
+		return nil
+	}
+
+	// Files that were modified when the previous run scanned them may have
+	// been reverted since
+	changed, err := gitChangedFiles(ctx, checkout, ref, summary.Commit)
+	if err != nil {
+		return err
+	}
+	for _, name := range summary.Uncommitted {
+		changed[realPath(filepath.Join(checkout, filepath.FromSlash(name)))] = true
+	}
+
+	// A single module run fails on its findings without a module error
+	_, err = os.Stat(filepath.Join(previous, "aggregated-report.json"))
+	single := err != nil
+	passed := make(map[string]bool)
+	for _, m := range summary.Modules {
+		if m.Error == "" && (!single || summary.Status == "passed") {
+			passed[m.Path+"@"+string(m.BuildTool)] = true
+		}
+	}
+
+	root := target
+	if info, err := os.Stat(target); err == nil && !info.IsDir() {
+		root = filepath.Dir(target)
+	}
+	unchanged := 0
+	for i, p := range projects {
+		if !passed[filepath.ToSlash(p.Rel)+"@"+string(p.Tool)] {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(previous, p.Output, "sbom.xml")); err != nil {
+			continue
+		}
+		modified := false
+		for _, manifest := range projectManifests(p, root) {
+			if changed[realPath(manifest)] {
+				modified = true
+				break
+			}
+		}
+		if !modified {
+			projects[i].Reuse = previous
+			unchanged++
+		}
+	}
+	runenv.Logger.Infof("Reusing the results of %d modules unchanged since %s and %s from %s, scanning the other %d",
+		unchanged, ref, shortCommit(summary.Commit), previous, len(projects)-unchanged)
+	return nil
+}
+
+// reuseModuleResults copies the results of a module from the previous run
+// into its output directory. Only the files of the module are copied: the
+// directories of nested modules and the files of the run are left out.
+func reuseModuleResults(p sbom.Project, moduleDir string) error {
+	dir := filepath.Join(p.Reuse, p.Output)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read the previous results of %s: %v", p.Rel, err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || runFiles[name] || strings.HasPrefix(name, "aggregated-") || name == HistoryFileName {
+			continue
+		}
+		if err := runenv.CopyFile(filepath.Join(dir, name), filepath.Join(moduleDir, name)); err != nil {
+			return fmt.Errorf("failed to copy the previous results of %s: %v", p.Rel, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+)
+
+// runDirLayout names the timestamped directory of a run in the output directory
+const runDirLayout = "20060102-150405"
+
+// latestRunLink points to the directory of the newest run
+const latestRunLink = "latest"
+
+// Files that identify the output directory of an earlier version, which did
+// not write the marker
+var legacyOutputFiles = []string{"sbom.xml", "aggregated-report.json", HistoryFileName}
+
+// prepareOutputDir returns the directory a run writes its results to: a new
+// timestamped subdirectory of outputDir, or with clean outputDir itself after
+// removing its contents except for the history database (keep)
+func prepareOutputDir(outputDir, target, keep string, clean bool) (string, error) {
+	entries, err := os.ReadDir(outputDir)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read output directory: %v", err)
+	}
+	empty := len(entries) == 0
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	if clean {
+		if err := checkCleanable(outputDir, target, empty); err != nil {
+			return "", err
+		}
+		if err := cleanDirectory(outputDir, keep); err != nil {
+			return "", fmt.Errorf("failed to clean directory: %v", err)
+		}
+		return outputDir, writeOutputMarker(outputDir)
+	}
+
+	// A directory that already held other files is not marked, so that
+	// --clean never empties it later
+	if empty {
+		if err := writeOutputMarker(outputDir); err != nil {
+			return "", err
+		}
+	}
+	return newRunDir(outputDir)
+}
+
+// newRunDir creates the timestamped directory of a run, with a counter when
+// a run of the same second exists
+func newRunDir(outputDir string) (string, error) {
+	name := time.Now().Format(runDirLayout)
+	for i := 2; ; i++ {
+		dir := filepath.Join(outputDir, name)
+		err := os.Mkdir(dir, 0755)
+		if err == nil {
+			return dir, nil
+		}
+		if !os.IsExist(err) {
+			return "", fmt.Errorf("failed to create directory: %v", err)
+		}
+		name = time.Now().Format(runDirLayout) + "-" + strconv.Itoa(i)
+	}
+}
+
+// isRunDir reports whether name is a directory of newRunDir
+func isRunDir(name string) bool {
+	if len(name) < len(runDirLayout) {
+		return false
+	}
+	if _, err := time.Parse(runDirLayout, name[:len(runDirLayout)]); err != nil {
+		return false
+	}
+	counter := name[len(runDirLayout):]
+	if counter == "" {
+		return true
+	}
+	_, err := strconv.Atoi(strings.TrimPrefix(counter, "-"))
+	return strings.HasPrefix(counter, "-") && err == nil
+}
+
+// linkLatestRun points outputDir/latest to the run directory. Symlinks need
+// extra privileges on Windows, where the link is skipped.
+func linkLatestRun(outputDir, runDir string) {
+	if runtime.GOOS == "windows" {
+		return
+	}
+	link := filepath.Join(outputDir, latestRunLink)
+	if info, err := os.Lstat(link); err == nil && info.Mode()&os.ModeSymlink == 0 {
+		runenv.Logger.Warnf("Not linking %s to the latest run, it is not a symlink", link)
+		return
+	}
+	// Replaced with a rename so that the link always points to a run
+	tmp := link + ".tmp"
+	os.Remove(tmp)
+	if err := os.Symlink(filepath.Base(runDir), tmp); err != nil {
+		runenv.Logger.Warnf("Failed to link %s to the latest run: %v", link, err)
+		return
+	}
+	if err := os.Rename(tmp, link); err != nil {
+		os.Remove(tmp)
+		runenv.Logger.Warnf("Failed to link %s to the latest run: %v", link, err)
+	}
+}
+
+// pruneRuns removes the oldest run directories of outputDir so that keep
+// remain, including the current one
+func pruneRuns(outputDir, current string, keep int) error {
+	entries, err := os.ReadDir(outputDir)
+	if err != nil {
+		return fmt.Errorf("failed to read output directory: %v", err)
+	}
+	// ReadDir sorts by name, which orders the timestamps
+	var runs []string
+	for _, entry := range entries {
+		if entry.IsDir() && isRunDir(entry.Name()) {
+			runs = append(runs, entry.Name())
+		}
+	}
+	if len(runs) <= keep {
+		return nil
+	}
+	for _, name := range runs[:len(runs)-keep] {
+		if name == filepath.Base(current) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(outputDir, name)); err != nil {
+			return fmt.Errorf("failed to remove old run %s: %v", name, err)
+		}
+		runenv.Logger.Infof("Removed old run %s (--keep-last %d)", name, keep)
+	}
+	return nil
+}
+
+// checkCleanable refuses to clean a directory that holds the scanned project
+// or was not created by sbom-scanner
+func checkCleanable(dir, target string, empty bool) error {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %v", err)
+	}
+	absTarget, err := filepath.Abs(target)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %v", err)
+	}
+	if absTarget == absDir || strings.HasPrefix(absTarget, absDir+string(filepath.Separator)) {
+		return fmt.Errorf("refusing to clean %s, it contains the scanned project %s", dir, target)
+	}
+
+	if empty {
+		return nil
+	}
+	for _, name := range append([]string{sbom.OutputMarker}, legacyOutputFiles...) {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("refusing to clean %s, it was not created by sbom-scanner (no %s file); choose another output directory or scan without --clean", dir, sbom.OutputMarker)
+}
+
+func writeOutputMarker(dir string) error {
+	if err := os.WriteFile(filepath.Join(dir, sbom.OutputMarker), []byte("Output directory of sbom-scanner, --clean may remove its contents\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", sbom.OutputMarker, err)
+	}
+	return nil
+}
+
+// removeRunResults removes the results of a canceled run
+func removeRunResults(outputDir, runDir, keep string) error {
+	if runDir == outputDir {
+		return cleanDirectory(runDir, keep)
+	}
+	return os.RemoveAll(runDir)
+}
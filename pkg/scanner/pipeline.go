@@ -0,0 +1,1214 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/schollz/progressbar/v3"
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/maven"
+	"github.com/xshuden/sbom-scanner/pkg/report"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// ScanOptions carries the settings shared by every scanned module
+type ScanOptions struct {
+	Resolver    string
+	Scanners    []string
+	exitOnVuln  bool
+	failOn      string
+	denyLicense []string // licenses that fail the scan
+	reports     []string
+	graphs      []string // dependency graph formats
+	scopes      []string // Maven scopes to scan, all when empty
+	noDepsTree  bool     // skip the dependency tree, and with it the dependency paths of findings
+	noEffective bool     // skip the Maven effective POM
+	ignoreRules []scan.IgnoreRule
+	policies    []scan.PolicyRule
+	defectDojo  defectDojoOptions
+	webhookURL  string
+	email       EmailConfig
+	comment     *pullRequestTarget       // pull request the report is posted to
+	baseline    *report.BaselineFindings // findings of a previous scan, only new ones fail the scan
+	aggregated  bool                     // modules are compared with the baseline as a whole
+	Sign        signOptions
+	Attest      attestOptions
+	ghsa        scan.GHSAOptions // enrichment from GitHub advisories, disabled without a token
+	nvd         scan.NVDOptions  // enrichment from the NVD
+	exploits    bool             // enrichment with EPSS scores and the CISA KEV catalog
+	// tag Java findings as reachable or unreachable from the compiled classes
+	reachability bool
+	staleAfter   string // maintenance check of the components, disabled when empty
+	supplyChain  bool   // dependency confusion and typosquatting checks
+	// Maven groupIds, npm scopes or package patterns of internal packages
+	internalNamespaces []string
+	secrets            bool   // scan the project files for secrets
+	failOnSecret       string // severity of secrets that fail the scan
+	failOnUnknown      bool   // findings of unknown severity fail the scan too with failOn
+	// add the Maven build plugins and their dependencies to the SBOM
+	includePlugins bool
+}
+
+// ResolveMavenFallback switches to the native resolver when Maven is not installed
+func ResolveMavenFallback(ctx context.Context, opts *ScanOptions, projects []sbom.Project) {
+	if opts.Resolver != "maven" {
+		return
+	}
+	for _, p := range projects {
+		if p.Tool != sbom.BuildToolMaven {
+			continue
+		}
+		if _, err := exec.LookPath(maven.Executable(runenv.SettingsOf(ctx), p.File)); err != nil {
+			runenv.Logger.Warn("Maven is not installed, falling back to the native resolver")
+			opts.Resolver = "native"
+			return
+		}
+	}
+}
+
+// CheckRequiredTools fails before the scan when a selected scanner, the
+// Gradle of a Gradle module, syft for images, the browser of PDF reports or
+// cosign is not installed
+func CheckRequiredTools(ctx context.Context, projects []sbom.Project, opts ScanOptions) error {
+	s := runenv.SettingsOf(ctx)
+	for _, name := range opts.Scanners {
+		tool := scan.ScannerBackends[name].Tool
+		if tool == "" {
+			continue
+		}
+		if _, err := exec.LookPath(s.ToolPath(tool)); err != nil {
+			return runenv.MissingTool("%s is not installed, it is needed by the %s scanner (see sbom-scanner deps check)", tool, name)
+		}
+	}
+	for _, p := range projects {
+		if p.Tool != sbom.BuildToolGradle {
+			continue
+		}
+		if _, err := exec.LookPath(s.ToolPath("gradle")); err != nil {
+			return runenv.MissingTool("Gradle is not installed, it is needed to scan %s", p.File)
+		}
+		break
+	}
+	for _, p := range projects {
+		if p.Tool != sbom.BuildToolImage {
+			continue
+		}
+		if _, err := exec.LookPath(s.ToolPath("syft")); err != nil {
+			return runenv.MissingTool("syft is not installed, it is needed to catalog the image %s", p.File)
+		}
+		break
+	}
+	if slices.Contains(opts.reports, "pdf") && report.ChromePath(s) == "" {
+		return runenv.MissingTool("PDF reports need Chrome, Chromium or Edge, install one or set tools.chrome in the config file")
+	}
+	if opts.Sign.Enabled {
+		return CosignInstalled(ctx)
+	}
+	return nil
+}
+
+// Task is a step of the scan pipeline
+type Task struct {
+	Name     string
+	Action   func(ctx context.Context) error
+	Progress int
+	parallel []Task // run concurrently instead of action
+}
+
+// buildTasks copies the project file into outputDir and returns the pipeline for it
+func buildTasks(ctx context.Context, p sbom.Project, outputDir string, opts ScanOptions) ([]Task, error) {
+	depsPath := filepath.Join(outputDir, "deps-tree.txt")
+	sbomPath := filepath.Join(outputDir, "sbom.xml")
+
+	tasks, err := GenerateTasks(ctx, p, outputDir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsPath := scan.FindingsPath(sbomPath)
+	reportBase := strings.TrimSuffix(scan.VulnerabilityReportPath(sbomPath), ".json")
+
+	if opts.Attest.Format != "" {
+		tasks = append(tasks, Task{
+			Name: "Generating Attestations",
+			Action: func(ctx context.Context) error {
+				return WriteAttestations(ctx, sbomPath, p, opts)
+			},
+			Progress: 0,
+		})
+	}
+
+	if opts.Sign.Enabled {
+		tasks = append(tasks, Task{
+			Name: "Signing SBOM",
+			Action: func(ctx context.Context) error {
+				return SignOutputs(ctx, sbomPath, opts)
+			},
+			Progress: 0,
+		})
+	}
+
+	tasks = append(tasks, Task{
+		Name: "Detecting Licenses",
+		Action: func(ctx context.Context) error {
+			return maven.WriteLicenseReport(ctx, sbomPath)
+		},
+		Progress: 0,
+	})
+
+	if p.Tool == sbom.BuildToolMaven || p.Tool == sbom.BuildToolGradle {
+		tasks = append(tasks, Task{
+			Name: "Checking Unpinned Versions",
+			Action: func(ctx context.Context) error {
+				return maven.CheckUnpinnedVersions(ctx, sbomPath)
+			},
+			Progress: 0,
+		})
+	}
+
+	if p.Tool == sbom.BuildToolMaven {
+		tasks = append(tasks, Task{
+			Name: "Tracing Version Provenance",
+			Action: func(ctx context.Context) error {
+				return maven.WriteVersionProvenance(ctx, p.File, sbomPath)
+			},
+			Progress: 0,
+		})
+	}
+
+	if opts.staleAfter != "" {
+		tasks = append(tasks, Task{
+			Name: "Checking Maintenance",
+			Action: func(ctx context.Context) error {
+				return scan.CheckMaintenance(ctx, sbomPath, opts.staleAfter)
+			},
+			Progress: 0,
+		})
+	}
+
+	if opts.supplyChain {
+		tasks = append(tasks, Task{
+			Name: "Checking Supply Chain",
+			Action: func(ctx context.Context) error {
+				return scan.CheckSupplyChain(ctx, sbomPath, opts.internalNamespaces)
+			},
+			Progress: 0,
+		})
+	}
+
+	// Polyglot directories are scanned for secrets once, built archives,
+	// imported SBOMs and images have no project directory
+	scanSecretsOf := opts.secrets && p.Output == p.Rel && p.Tool != sbom.BuildToolArtifact && p.Tool != sbom.BuildToolSBOM && p.Tool != sbom.BuildToolImage
+	if scanSecretsOf {
+		tasks = append(tasks, Task{
+			Name: "Scanning Secrets",
+			Action: func(ctx context.Context) error {
+				return scan.ScanSecrets(filepath.Dir(p.File), sbomPath, opts.aggregated)
+			},
+			Progress: 0,
+		})
+	}
+
+	tasks = append(tasks, VulnerabilityTasks(sbomPath, depsPath, opts)...)
+
+	if p.Tool == sbom.BuildToolMaven {
+		tasks = append(tasks, Task{
+			Name: "Checking Excluded Dependencies",
+			Action: func(ctx context.Context) error {
+				return scan.CheckExcludedDependencies(ctx, sbomPath)
+			},
+			Progress: 0,
+		})
+	}
+
+	if opts.reachability && (p.Tool == sbom.BuildToolMaven || p.Tool == sbom.BuildToolGradle) {
+		tasks = append(tasks, Task{
+			Name: "Analyzing Reachability",
+			Action: func(ctx context.Context) error {
+				return scan.AnalyzeReachability(ctx, resultsPath, sbomPath, filepath.Dir(p.File))
+			},
+			Progress: 0,
+		})
+	}
+
+	if len(opts.reports) > 0 {
+		tasks = append(tasks, Task{
+			Name: "Generating Reports",
+			Action: func(ctx context.Context) error {
+				base := opts.baseline
+				if opts.aggregated {
+					// Compared as a whole in the aggregated report
+					base = nil
+				}
+				return report.RenderReports(ctx, opts.reports, "Vulnerability Report: "+filepath.Base(p.File), resultsPath, reportBase, opts.ignoreRules, base)
+			},
+			Progress: 5,
+		})
+	}
+
+	if len(opts.graphs) > 0 {
+		tasks = append(tasks, Task{
+			Name: "Exporting Dependency Graph",
+			Action: func(ctx context.Context) error {
+				return report.WriteDependencyGraphs(opts.graphs, depsPath, resultsPath, opts.ignoreRules)
+			},
+			Progress: 0,
+		})
+	}
+
+	if opts.defectDojo.enabled() {
+		module := filepath.ToSlash(filepath.Join(p.Rel, filepath.Base(p.File)))
+		tasks = append(tasks, Task{
+			Name: "Exporting to DefectDojo",
+			Action: func(ctx context.Context) error {
+				return exportToDefectDojo(resultsPath, module, opts.ignoreRules, opts.defectDojo)
+			},
+			Progress: 0,
+		})
+	}
+
+	if opts.baseline != nil && !opts.aggregated {
+		tasks = append(tasks, Task{
+			Name: "Comparing with Baseline",
+			Action: func(ctx context.Context) error {
+				return report.WriteFindingsDiff(resultsPath, report.DiffPath(resultsPath), opts.baseline, opts.ignoreRules)
+			},
+			Progress: 0,
+		})
+	}
+
+	if len(opts.denyLicense) > 0 {
+		tasks = append(tasks, Task{
+			Name: "Checking Licenses",
+			Action: func(ctx context.Context) error {
+				return scan.CheckLicenses(maven.LicensesPath(sbomPath), opts.denyLicense)
+			},
+			Progress: 0,
+		})
+	}
+
+	if len(opts.policies) > 0 {
+		tasks = append(tasks, Task{
+			Name: "Evaluating Policies",
+			Action: func(ctx context.Context) error {
+				return scan.CheckPolicies(ctx, sbomPath, opts.policies, opts.ignoreRules)
+			},
+			Progress: 0,
+		})
+	}
+
+	if scanSecretsOf && opts.failOnSecret != "" {
+		tasks = append(tasks, Task{
+			Name: "Checking Secrets",
+			Action: func(ctx context.Context) error {
+				return scan.CheckSecrets(scan.SecretsPath(sbomPath), opts.failOnSecret)
+			},
+			Progress: 0,
+		})
+	}
+
+	tasks = append(tasks, Task{
+		Name: "Checking Results",
+		Action: func(ctx context.Context) error {
+			return EvaluateResults(resultsPath, opts)
+		},
+		Progress: 5,
+	})
+
+	return tasks, nil
+}
+
+// GenerateTasks copies the project file into outputDir and returns the
+// tasks that generate the SBOM and the dependency tree
+func GenerateTasks(ctx context.Context, p sbom.Project, outputDir string, opts ScanOptions) ([]Task, error) {
+	depsPath := filepath.Join(outputDir, "deps-tree.txt")
+	effectivePomPath := filepath.Join(outputDir, "effective-pom.xml")
+	sbomPath := filepath.Join(outputDir, "sbom.xml")
+
+	// The Go module graph and the native resolver write the tree as a by-product
+	treePath := depsPath
+	if opts.noDepsTree {
+		treePath = ""
+	}
+
+	var tasks []Task
+	switch {
+	case p.Tool == sbom.BuildToolGradle:
+		// Önce Gradle dosyalarını kopyala
+		dstBuildFile, err := sbom.CopyGradleProject(p.File, outputDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy Gradle build file: %v", err)
+		}
+		runenv.Logger.Info("Copying Gradle Build File")
+
+		if !opts.noDepsTree {
+			tasks = append(tasks, Task{
+				Name: "Analyzing Dependencies",
+				Action: func(ctx context.Context) error {
+					return sbom.RunGradleDependencies(ctx, dstBuildFile, depsPath)
+				},
+				Progress: 30,
+			})
+		}
+		tasks = append(tasks, Task{
+			Name: "Generating CycloneDX SBOM",
+			Action: func(ctx context.Context) error {
+				return sbom.GenerateGradleCycloneDX(ctx, dstBuildFile, sbomPath, opts.scopes)
+			},
+			Progress: 30,
+		})
+	case p.Tool == sbom.BuildToolNode:
+		dstProjectFile := filepath.Join(outputDir, filepath.Base(p.File))
+
+		// Önce proje dosyasını kopyala
+		if err := runenv.CopyFile(p.File, dstProjectFile); err != nil {
+			return nil, fmt.Errorf("failed to copy project file: %v", err)
+		}
+		runenv.Logger.Infof("Copying %s", filepath.Base(p.File))
+
+		tasks = []Task{
+			{
+				Name: "Generating CycloneDX SBOM",
+				Action: func(ctx context.Context) error {
+					return sbom.GenerateNodeSBOM(ctx, dstProjectFile, sbomPath)
+				},
+				Progress: 60,
+			},
+		}
+	case p.Tool == sbom.BuildToolGo:
+		// Önce go.mod ve go.sum dosyalarını kopyala
+		dstGoMod, err := sbom.CopyGoModule(p.File, outputDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy go.mod: %v", err)
+		}
+		runenv.Logger.Info("Copying go.mod")
+
+		tasks = []Task{
+			{
+				Name: "Generating CycloneDX SBOM",
+				Action: func(ctx context.Context) error {
+					return sbom.GenerateGoSBOM(ctx, dstGoMod, treePath, sbomPath)
+				},
+				Progress: 60,
+			},
+		}
+	case p.Tool == sbom.BuildToolPython:
+		tasks = []Task{
+			{
+				Name: "Generating CycloneDX SBOM",
+				Action: func(ctx context.Context) error {
+					return sbom.GeneratePythonSBOM(p.File, sbomPath)
+				},
+				Progress: 60,
+			},
+		}
+	case p.Tool == sbom.BuildToolRust:
+		tasks = []Task{
+			{
+				Name: "Generating CycloneDX SBOM",
+				Action: func(ctx context.Context) error {
+					return sbom.GenerateRustSBOM(p.File, sbomPath)
+				},
+				Progress: 60,
+			},
+		}
+	case p.Tool == sbom.BuildToolNuGet:
+		tasks = []Task{
+			{
+				Name: "Generating CycloneDX SBOM",
+				Action: func(ctx context.Context) error {
+					return sbom.GenerateNuGetSBOM(p.File, sbomPath)
+				},
+				Progress: 60,
+			},
+		}
+	case p.Tool == sbom.BuildToolComposer:
+		tasks = []Task{
+			{
+				Name: "Generating CycloneDX SBOM",
+				Action: func(ctx context.Context) error {
+					return sbom.GenerateComposerSBOM(p.File, sbomPath)
+				},
+				Progress: 60,
+			},
+		}
+	case p.Tool == sbom.BuildToolBundler:
+		tasks = []Task{
+			{
+				Name: "Generating CycloneDX SBOM",
+				Action: func(ctx context.Context) error {
+					return sbom.GenerateBundlerSBOM(p.File, sbomPath)
+				},
+				Progress: 60,
+			},
+		}
+	case p.Tool == sbom.BuildToolArtifact:
+		tasks = []Task{
+			{
+				Name: "Inspecting Artifact",
+				Action: func(ctx context.Context) error {
+					return maven.GenerateArtifactSBOM(p.File, sbomPath)
+				},
+				Progress: 60,
+			},
+		}
+	case p.Tool == sbom.BuildToolSBOM:
+		tasks = []Task{
+			{
+				Name: "Importing SBOM",
+				Action: func(ctx context.Context) error {
+					return sbom.ImportSBOM(p.File, sbomPath)
+				},
+				Progress: 60,
+			},
+		}
+	case p.Tool == sbom.BuildToolImage:
+		tasks = []Task{
+			{
+				Name: "Cataloging Image",
+				Action: func(ctx context.Context) error {
+					return sbom.GenerateImageSBOM(ctx, p.File, sbomPath)
+				},
+				Progress: 60,
+			},
+		}
+	case sbom.ProviderFor(p.Tool) != nil:
+		provider := sbom.ProviderFor(p.Tool)
+		tasks = []Task{
+			{
+				Name: "Generating SBOM (" + provider.Name() + ")",
+				Action: func(ctx context.Context) error {
+					return sbom.GenerateProviderSBOM(ctx, provider, p.File, sbomPath)
+				},
+				Progress: 60,
+			},
+		}
+	case p.Tool == sbom.BuildToolMaven && opts.Resolver == "native":
+		tasks = []Task{
+			{
+				Name: "Resolving Dependencies",
+				Action: func(ctx context.Context) error {
+					return maven.ResolveNative(ctx, p.File, treePath, sbomPath, opts.scopes)
+				},
+				Progress: 60,
+			},
+		}
+	default:
+		dstPomPath := filepath.Join(outputDir, "pom.xml")
+
+		// Önce POM dosyasını kopyala
+		if err := runenv.CopyFile(p.File, dstPomPath); err != nil {
+			return nil, fmt.Errorf("failed to copy POM file: %v", err)
+		}
+		runenv.Logger.Info("Copying POM File")
+
+		mvn := maven.Executable(runenv.SettingsOf(ctx), p.File)
+		if maven.IsWrapper(mvn) {
+			runenv.Logger.Infof("Using Maven wrapper %s", mvn)
+		}
+
+		// Only the SBOM is required, the other outputs can be skipped
+		var outputs []string
+		if !opts.noDepsTree {
+			outputs = append(outputs, "deps-tree.txt")
+		}
+		if !opts.noEffective {
+			outputs = append(outputs, "effective-pom.xml")
+		}
+		outputs = append(outputs, "sbom.xml")
+
+		var cacheKey string
+		if runenv.SettingsOf(ctx).ResultCacheTTL > 0 {
+			key, err := maven.CacheKey(ctx, dstPomPath, opts.scopes)
+			if err != nil {
+				return nil, err
+			}
+			if maven.RestoreResolution(ctx, key, outputDir, outputs) {
+				break
+			}
+			cacheKey = key
+		}
+
+		// The Maven invocations are independent of each other
+		var mavenTasks []Task
+		if !opts.noDepsTree {
+			mavenTasks = append(mavenTasks, Task{
+				Name: "Analyzing Dependencies",
+				Action: func(ctx context.Context) error {
+					return maven.WriteDependencyTree(ctx, mvn, dstPomPath, depsPath)
+				},
+				Progress: 20,
+			})
+		}
+		if !opts.noEffective {
+			mavenTasks = append(mavenTasks, Task{
+				Name: "Generating Effective POM",
+				Action: func(ctx context.Context) error {
+					return maven.WriteEffectivePOM(ctx, mvn, dstPomPath, effectivePomPath)
+				},
+				Progress: 20,
+			})
+		}
+		mavenTasks = append(mavenTasks, Task{
+			Name: "Generating CycloneDX SBOM",
+			Action: func(ctx context.Context) error {
+				return maven.GenerateCycloneDX(ctx, mvn, dstPomPath, sbomPath, opts.scopes)
+			},
+			Progress: 30,
+		})
+		tasks = []Task{{Name: "Running Maven", parallel: mavenTasks}}
+		if cacheKey != "" {
+			tasks = append(tasks, Task{
+				Name: "Caching Maven Resolution",
+				Action: func(ctx context.Context) error {
+					if err := maven.StoreResolution(ctx, cacheKey, outputDir, outputs); err != nil {
+						runenv.Logger.Warnf("Failed to cache the Maven resolution: %v", err)
+					}
+					return nil
+				},
+				Progress: 0,
+			})
+		}
+	}
+
+	// Malformed package URLs of plugins would silently match no vulnerabilities
+	tasks = append(tasks, Task{
+		Name: "Normalizing Package URLs",
+		Action: func(ctx context.Context) error {
+			return sbom.NormalizePackageURLs(sbomPath)
+		},
+		Progress: 0,
+	})
+
+	// Build plugins run with the permissions of the build, their compromise
+	// is a supply chain risk even though they do not ship
+	if opts.includePlugins && p.Tool == sbom.BuildToolMaven {
+		pluginsEffectivePom := effectivePomPath
+		if opts.Resolver == "native" || opts.noEffective {
+			pluginsEffectivePom = ""
+		}
+		tasks = append(tasks, Task{
+			Name: "Adding Build Plugins",
+			Action: func(ctx context.Context) error {
+				return maven.AddBuildPlugins(ctx, p.File, pluginsEffectivePom, sbomPath)
+			},
+			Progress: 0,
+		})
+	}
+
+	// Advisories of frameworks such as Spring Boot match the framework rather
+	// than the artifacts the module happens to depend on
+	if p.Tool == sbom.BuildToolMaven {
+		frameworksEffectivePom := effectivePomPath
+		if opts.Resolver == "native" || opts.noEffective {
+			frameworksEffectivePom = ""
+		}
+		tasks = append(tasks, Task{
+			Name: "Detecting Frameworks",
+			Action: func(ctx context.Context) error {
+				return maven.AddRuntimeMetadata(ctx, p.File, frameworksEffectivePom, sbomPath)
+			},
+			Progress: 0,
+		})
+	}
+
+	// The hashes of the resolved JARs make the SBOM usable for integrity checks
+	if p.Tool == sbom.BuildToolMaven || p.Tool == sbom.BuildToolGradle {
+		tasks = append(tasks, Task{
+			Name: "Hashing Artifacts",
+			Action: func(ctx context.Context) error {
+				return maven.AddArtifactHashes(ctx, sbomPath)
+			},
+			Progress: 0,
+		})
+	}
+
+	if opts.noDepsTree {
+		return tasks, nil
+	}
+
+	if len(opts.scopes) > 0 {
+		tasks = append(tasks, Task{
+			Name: "Filtering Dependency Scopes",
+			Action: func(ctx context.Context) error {
+				return sbom.FilterDependencyTree(depsPath, opts.scopes)
+			},
+			Progress: 0,
+		})
+	}
+
+	tasks = append(tasks, Task{
+		Name: "Parsing Dependency Tree",
+		Action: func(ctx context.Context) error {
+			return sbom.WriteDependencyGraph(depsPath)
+		},
+		Progress: 0,
+	})
+
+	return tasks, nil
+}
+
+// VulnerabilityTasks scans the SBOM and adds the GitHub advisory details, the
+// dependency paths and upgrade suggestions to the findings
+func VulnerabilityTasks(sbomPath, depsPath string, opts ScanOptions) []Task {
+	resultsPath := scan.FindingsPath(sbomPath)
+
+	tasks := []Task{{
+		Name: "Scanning for Vulnerabilities",
+		Action: func(ctx context.Context) error {
+			_, err := scan.RunVulnerabilityScan(ctx, opts.Scanners, sbomPath)
+			return err
+		},
+		Progress: 20,
+	}}
+	if opts.ghsa.Enabled() {
+		tasks = append(tasks, Task{
+			Name: "Enriching from GitHub Advisories",
+			Action: func(ctx context.Context) error {
+				return scan.EnrichFindingsFromGHSA(ctx, resultsPath, opts.ghsa)
+			},
+			Progress: 0,
+		})
+	}
+	if opts.nvd.Enabled {
+		tasks = append(tasks, Task{
+			Name: "Enriching from the NVD",
+			Action: func(ctx context.Context) error {
+				return scan.EnrichFindingsFromNVD(ctx, resultsPath, opts.nvd)
+			},
+			Progress: 0,
+		})
+	}
+	if opts.exploits {
+		tasks = append(tasks, Task{
+			Name: "Enriching with EPSS and KEV",
+			Action: func(ctx context.Context) error {
+				return scan.EnrichFindingsWithExploits(ctx, resultsPath)
+			},
+			Progress: 0,
+		})
+	}
+
+	return append(tasks, []Task{
+		{
+			Name: "Tracing Dependency Paths",
+			Action: func(ctx context.Context) error {
+				return scan.TraceDependencyPaths(resultsPath, depsPath)
+			},
+			Progress: 0,
+		},
+		{
+			Name: "Adding Version Provenance",
+			Action: func(ctx context.Context) error {
+				return scan.AddVersionProvenance(resultsPath, maven.ProvenancePath(sbomPath))
+			},
+			Progress: 0,
+		},
+		{
+			Name: "Suggesting Remediations",
+			Action: func(ctx context.Context) error {
+				return scan.SuggestRemediations(ctx, resultsPath)
+			},
+			Progress: 0,
+		},
+	}...)
+}
+
+// runParallel runs the tasks concurrently, at most --parallelism at a time
+// across the whole run, and calls done after each task that succeeds. Tasks
+// still waiting for a slot are skipped once ctx is canceled.
+func runParallel(ctx context.Context, tasks []Task, label string, done func(progress int)) error {
+	sem := runenv.SettingsOf(ctx).Slots
+	errs := make([]error, len(tasks))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i, task := range tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+
+			runenv.Logger.Info(label + task.Name)
+			if err := runTask(ctx, task, label); err != nil {
+				errs[i] = err
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			done(task.Progress)
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+// executeTasks runs the tasks in order and calls done after each one. The
+// subtasks of a parallel task run concurrently. label prefixes the task
+// names in the log. It stops before the next task once ctx is canceled.
+func executeTasks(ctx context.Context, tasks []Task, label string, done func(progress int)) error {
+	for _, task := range tasks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		runenv.Logger.Info(label + task.Name)
+		if len(task.parallel) > 0 {
+			groupCtx, span := runenv.StartSpan(ctx, task.Name)
+			err := runParallel(groupCtx, task.parallel, label, done)
+			span.Finish(err)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err := runTask(ctx, task, label); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		done(task.Progress)
+		time.Sleep(100 * time.Millisecond)
+	}
+	return nil
+}
+
+// runTask runs the action of a task, canceled after StageTimeout. With
+// --timings its duration is logged with the label and recorded.
+func runTask(ctx context.Context, task Task, label string) (err error) {
+	ctx, span := runenv.StartSpan(ctx, task.Name)
+	defer func() { span.Finish(err) }()
+	start := time.Now()
+	defer func() {
+		if runenv.TimingsEnabled(ctx) {
+			runenv.RecordTiming(ctx, runenv.TimingStage, task.Name, time.Since(start))
+			runenv.Logger.Infof("%s%s took %s", label, task.Name, runenv.FormatSeconds(time.Since(start).Seconds()))
+		}
+	}()
+
+	stageCtx := ctx
+	timeout := runenv.SettingsOf(ctx).StageTimeout
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		stageCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	err = task.Action(stageCtx)
+	if stageCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		return fmt.Errorf("stage %s exceeded %s", task.Name, timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%s error: %w", task.Name, err)
+	}
+	return nil
+}
+
+// newProgressBar creates the progress bar shown while tasks run
+func newProgressBar(ctx context.Context, total int, description string) *progressbar.ProgressBar {
+	writer := io.Writer(os.Stdout)
+	if runenv.SettingsOf(ctx).Quiet {
+		writer = io.Discard
+	}
+
+	// Create progress bar with clear line option
+	return progressbar.NewOptions(total,
+		progressbar.OptionSetWriter(writer),
+		progressbar.OptionEnableColorCodes(true),
+		progressbar.OptionShowBytes(false),
+		progressbar.OptionSetWidth(30),
+		progressbar.OptionSetDescription("[cyan]"+description+"[reset]"),
+		progressbar.OptionSetTheme(progressbar.Theme{
+			Saucer:        "[green]=[reset]",
+			SaucerHead:    "[green]>[reset]",
+			SaucerPadding: " ",
+			BarStart:      "[",
+			BarEnd:        "]",
+		}),
+		progressbar.OptionClearOnFinish(),
+		progressbar.OptionSetPredictTime(false),
+		progressbar.OptionShowCount(),
+		progressbar.OptionFullWidth(),
+		progressbar.OptionSpinnerType(14))
+}
+
+// RunTasks runs the tasks in order while showing a progress bar
+func RunTasks(ctx context.Context, tasks []Task, description string) error {
+	bar := newProgressBar(ctx, 100, description)
+
+	completedProgress := 0
+
+	// İlk görev için progress bar'ı güncelle
+	bar.Set(10)
+
+	err := executeTasks(ctx, tasks, "", func(progress int) {
+		completedProgress += progress
+		bar.Set(completedProgress)
+	})
+	if err != nil {
+		if !runenv.SettingsOf(ctx).Quiet {
+			fmt.Println() // Add newline before error
+		}
+		return err
+	}
+
+	// Clear the progress bar
+	bar.Clear()
+	return nil
+}
+
+// ModuleSummary describes the scan result of a single module
+type ModuleSummary struct {
+	Path               string         `json:"path"`
+	ProjectFile        string         `json:"project_file"`
+	BuildTool          sbom.BuildTool `json:"build_tool"`
+	OutputDir          string         `json:"output_dir"`
+	Components         int            `json:"components"`
+	VulnerablePackages int            `json:"vulnerable_packages"`
+	Vulnerabilities    int            `json:"vulnerabilities"`
+	Error              string         `json:"error,omitempty"`
+	ReusedFrom         string         `json:"reused_from,omitempty"` // run directory the results were copied from with --since
+}
+
+// count records the number of vulnerabilities and vulnerable packages as the
+// findings are read
+func (s *ModuleSummary) count(findings scan.FindingSource) error {
+	packages := make(map[string]bool)
+	vulnerabilities := 0
+	err := findings(func(f scan.Finding) error {
+		packages[f.Package+"@"+f.Version] = true
+		vulnerabilities++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.VulnerablePackages = len(packages)
+	s.Vulnerabilities = vulnerabilities
+	return nil
+}
+
+// countComponents records the number of components in the SBOM of the module
+func (s *ModuleSummary) countComponents(sbomPath string) {
+	if components, err := sbom.ReadCycloneDX(sbomPath); err == nil {
+		s.Components = len(components)
+	}
+}
+
+// writeAggregatedReport writes the modules and the findings of all modules
+// formatted like json.MarshalIndent, with the findings encoded one at a time
+// as they are read
+func writeAggregatedReport(path string, summaries []ModuleSummary, findings scan.FindingSource) error {
+	modules, err := json.MarshalIndent(summaries, "  ", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode aggregated report: %v", err)
+	}
+	err = runenv.WriteBuffered(path, func(w *bufio.Writer) error {
+		w.WriteString("{\n  \"modules\": ")
+		w.Write(modules)
+		w.WriteString(",\n  \"findings\": ")
+		if err := runenv.EncodeJSONItems(w, "  ", findings); err != nil {
+			return err
+		}
+		_, err := w.WriteString("\n}")
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write aggregated report: %v", err)
+	}
+	return nil
+}
+
+// scanModules scans every project below the root, each into its own output
+// subdirectory that mirrors the source layout, and writes an aggregated report
+func scanModules(ctx context.Context, projects []sbom.Project, outputDir string, opts ScanOptions) error {
+	// All project files are copied first so that copied modules can find their parent POMs
+	moduleTasks := make([][]Task, len(projects))
+	summaries := make([]ModuleSummary, len(projects))
+	errs := make([]error, len(projects))
+	reused := 0
+
+	for i, p := range projects {
+		moduleDir := filepath.Join(outputDir, p.Output)
+		summaries[i] = ModuleSummary{
+			Path:        filepath.ToSlash(p.Rel),
+			ProjectFile: p.File,
+			BuildTool:   p.Tool,
+			OutputDir:   moduleDir,
+		}
+
+		if err := os.MkdirAll(moduleDir, 0755); err != nil {
+			summaries[i].Error = fmt.Sprintf("failed to create directory: %v", err)
+			continue
+		}
+
+		// Modules unchanged since --since are not scanned again
+		if p.Reuse != "" {
+			if err := reuseModuleResults(p, moduleDir); err != nil {
+				summaries[i].Error = err.Error()
+				continue
+			}
+			summaries[i].ReusedFrom = p.Reuse
+			reused++
+			continue
+		}
+
+		tasks, err := buildTasks(ctx, p, moduleDir, opts)
+		if err != nil {
+			summaries[i].Error = err.Error()
+			continue
+		}
+		moduleTasks[i] = tasks
+	}
+
+	// Modules are scanned concurrently with one progress bar for all of them
+	scanned := len(projects) - reused
+	bar := newProgressBar(ctx, 100*scanned, fmt.Sprintf("Scanning %d modules", scanned))
+	var mu sync.Mutex
+	advance := func(progress int) {
+		mu.Lock()
+		defer mu.Unlock()
+		bar.Add(progress)
+	}
+
+	sem := make(chan struct{}, runenv.SettingsOf(ctx).Parallelism())
+	var wg sync.WaitGroup
+	for i, p := range projects {
+		if moduleTasks[i] == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				summaries[i].Error = ctx.Err().Error()
+				return
+			}
+			defer func() { <-sem }()
+
+			runenv.Logger.Infof("Scanning module %s (%s, %d/%d)", summaries[i].Path, p.Tool, i+1, len(projects))
+			moduleCtx, span := runenv.StartSpan(ctx, "Module "+summaries[i].Path)
+			span.Set("sbom_scanner.module.path", summaries[i].Path)
+			span.Set("sbom_scanner.module.build_tool", string(p.Tool))
+			start := time.Now()
+			err := executeTasks(moduleCtx, moduleTasks[i], "["+summaries[i].Path+"] ", advance)
+			runenv.RecordTiming(ctx, runenv.TimingModule, summaries[i].Path, time.Since(start))
+			span.Finish(err)
+			if err != nil {
+				summaries[i].Error = err.Error()
+				errs[i] = err
+			}
+		}()
+	}
+	wg.Wait()
+	bar.Clear()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	defer func() { runenv.RecordTiming(ctx, runenv.TimingStage, "Aggregating Results", time.Since(start)) }()
+	var paths []string
+	failed := 0
+
+	for i, p := range projects {
+		if summaries[i].Error != "" {
+			runenv.Logger.Errorf("Module %s failed: %s", summaries[i].Path, summaries[i].Error)
+			failed++
+			if errs[i] == nil {
+				errs[i] = errors.New(summaries[i].Error)
+			}
+		}
+
+		sbomPath := filepath.Join(outputDir, p.Output, "sbom.xml")
+		summaries[i].countComponents(sbomPath)
+		if err := summaries[i].count(scan.FindingsFrom(scan.FindingsPath(sbomPath))); err != nil {
+			continue
+		}
+		paths = append(paths, scan.FindingsPath(sbomPath))
+	}
+
+	// The sorted findings files of the modules are merged, so the findings
+	// are never held in memory together
+	reportPath := filepath.Join(outputDir, "aggregated-report.json")
+	if err := writeAggregatedReport(reportPath, summaries, scan.MergeFindingsFiles(paths)); err != nil {
+		return err
+	}
+	runenv.Logger.Infof("Aggregated report written to %s", reportPath)
+
+	if err := writeAggregatedLicenses(outputDir, projects); err != nil {
+		return err
+	}
+	if err := writeAggregatedMaintenance(outputDir, projects); err != nil {
+		return err
+	}
+	if err := writeAggregatedExclusions(outputDir, projects); err != nil {
+		return err
+	}
+	if err := writeAggregatedUnpinned(outputDir, projects); err != nil {
+		return err
+	}
+	if err := writeAggregatedSupplyChain(outputDir, projects); err != nil {
+		return err
+	}
+	if err := writeAggregatedSecrets(outputDir, projects); err != nil {
+		return err
+	}
+
+	if opts.baseline != nil {
+		if err := report.WriteFindingsDiff(reportPath, filepath.Join(outputDir, "aggregated-diff.json"), opts.baseline, opts.ignoreRules); err != nil {
+			return err
+		}
+	}
+
+	if len(opts.reports) > 0 {
+		if err := report.RenderReportFormats(ctx, opts.reports, "Aggregated Vulnerability Report", scan.FindingsFrom(reportPath),
+			opts.ignoreRules, opts.baseline, maven.LicenseReportFor(reportPath), strings.TrimSuffix(reportPath, ".json")); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		// Modules failing on their findings keep the exit code of the findings
+		return runenv.WithExitCode(fmt.Errorf("%d of %d modules failed", failed, len(projects)), runenv.ExitCode(errors.Join(errs...)))
+	}
+	return nil
+}
+
+// Retry records a failed attempt of a network operation that was retried
+type Retry = runenv.Retry
+
+// Timings tell where the time of a run went, recorded with --timings
+type Timings = runenv.Timings
+
+// Result summarizes a whole run. It is returned by Run and sent to the
+// webhook and email notifications.
+type Result struct {
+	Target      string          `json:"target"`
+	Commit      string          `json:"commit,omitempty"`      // Git commit checked out in the target
+	Uncommitted []string        `json:"uncommitted,omitempty"` // files of the checkout with changes not committed, relative to its root
+	OutputDir   string          `json:"output_dir"`            // timestamped subdirectory of the output directory unless cleaned
+	GeneratedAt time.Time       `json:"generated_at"`
+	Scanners    []string        `json:"scanners"`
+	Status      string          `json:"status"` // passed or failed
+	Error       string          `json:"error,omitempty"`
+	Modules     []ModuleSummary `json:"modules"`
+	Retries     []Retry         `json:"retries,omitempty"` // failed attempts of network operations that were retried
+	Timings     *Timings        `json:"timings,omitempty"` // with --timings
+
+	// The findings are streamed from the output directory by EachFinding,
+	// counted once when the results are collected
+	findings scan.FindingSource
+	counts   Summary
+}
+
+// EachFinding calls fn with the findings of the run, most severe first, as
+// they are read from the output directory. Suppressed findings have
+// suppressed_by set.
+func (r *Result) EachFinding(fn func(scan.Finding) error) error {
+	if r.findings == nil {
+		return nil
+	}
+	return r.findings(fn)
+}
+
+// collectRunResults reads the modules of a run from the output directory and
+// counts its findings, which are streamed from there again when needed.
+// Suppressed findings are included with suppressed_by set.
+func collectRunResults(ctx context.Context, target, outputDir string, projects []sbom.Project, single bool, opts ScanOptions, scanErr error) (Result, error) {
+	results := Result{
+		Target:      target,
+		OutputDir:   outputDir,
+		GeneratedAt: time.Now().UTC(),
+		Scanners:    opts.Scanners,
+		Status:      "passed",
+		Modules:     []ModuleSummary{},
+		Retries:     runenv.TakeRetries(ctx),
+		Timings:     runenv.TakeTimings(ctx),
+		findings:    scan.SliceFindings(nil),
+	}
+	if scanErr != nil {
+		results.Status = "failed"
+		results.Error = scanErr.Error()
+	}
+
+	if single {
+		p := projects[0]
+		summary := ModuleSummary{Path: ".", ProjectFile: p.File, BuildTool: p.Tool, OutputDir: outputDir, ReusedFrom: p.Reuse}
+		summary.countComponents(filepath.Join(outputDir, "sbom.xml"))
+		findings := scan.FindingsFrom(scan.FindingsPath(filepath.Join(outputDir, "sbom.xml")))
+		if err := summary.count(findings); err == nil {
+			results.findings = findings.SuppressedBy(opts.ignoreRules)
+		}
+		results.Modules = append(results.Modules, summary)
+	} else {
+		reportPath := filepath.Join(outputDir, "aggregated-report.json")
+		file, err := os.Open(reportPath)
+		if err != nil {
+			return results, fmt.Errorf("failed to read aggregated report: %v", err)
+		}
+		defer file.Close()
+		// Only the modules are decoded here, the findings are streamed
+		err = runenv.DecodeJSONObject(json.NewDecoder(bufio.NewReader(file)), map[string]func(*json.Decoder) error{
+			"modules": func(d *json.Decoder) error { return d.Decode(&results.Modules) },
+		})
+		if err != nil {
+			return results, fmt.Errorf("failed to parse aggregated report: %v", err)
+		}
+		results.findings = scan.FindingsFrom(reportPath).SuppressedBy(opts.ignoreRules)
+	}
+
+	counts, err := countFindings(results.findings)
+	if err != nil {
+		return results, err
+	}
+	results.counts = counts
+	return results, nil
+}
+
+// notify sends the results of the run to the webhook, the pull request and
+// the email recipients
+func notify(results Result, outputDir string, single bool, opts ScanOptions) error {
+	var errs []error
+	if opts.webhookURL != "" {
+		errs = append(errs, sendWebhook(opts.webhookURL, webhookSecret(), results))
+	}
+	if opts.comment != nil {
+		report, err := runMarkdown(runReportTitle(results, single), results, opts)
+		if err == nil {
+			err = commentOnPullRequest(*opts.comment, report)
+		}
+		errs = append(errs, err)
+	}
+	if opts.email.enabled() {
+		reportBase := filepath.Join(outputDir, "aggregated-report")
+		if single {
+			reportBase = strings.TrimSuffix(scan.VulnerabilityReportPath(filepath.Join(outputDir, "sbom.xml")), ".json")
+		}
+		errs = append(errs, sendReportEmail(opts.email, results, reportBase))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,284 @@
+package scanner
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// Environment variable holding the Bitbucket API token used by --comment-pr,
+// GitHub and GitLab use the tokens of fix --pr
+const bitbucketTokenEnv = "BITBUCKET_TOKEN"
+
+// commentMarker identifies the comment of sbom-scanner, which every scan of
+// the pull request updates instead of adding a new one
+const commentMarker = "<!-- sbom-scanner -->"
+
+// pullRequestTarget is the pull request (merge request on GitLab) to comment on
+type pullRequestTarget struct {
+	provider string // github, gitlab or bitbucket
+	api      string
+	repo     string // owner/repo, the GitLab project path or the Bitbucket workspace/repo
+	number   int
+	token    string
+}
+
+func (t pullRequestTarget) String() string {
+	return fmt.Sprintf("%s %s#%d", t.provider, t.repo, t.number)
+}
+
+// resolvePullRequest parses the URL of a pull request or, with auto, reads it
+// from the CI environment. It returns nil when auto finds no pull request,
+// e.g. in a build of the main branch.
+func resolvePullRequest(ref string) (*pullRequestTarget, error) {
+	var target *pullRequestTarget
+	var err error
+	if ref == "auto" {
+		target, err = detectPullRequest()
+	} else {
+		target, err = parsePullRequestURL(ref)
+	}
+	if err != nil || target == nil {
+		return nil, err
+	}
+
+	tokenEnv := map[string]string{"github": scan.GitHubTokenEnv, "gitlab": scan.GitLabTokenEnv, "bitbucket": bitbucketTokenEnv}[target.provider]
+	target.token = strings.TrimSpace(os.Getenv(tokenEnv))
+	if target.token == "" {
+		return nil, fmt.Errorf("%s is not set, it is needed to comment on %s", tokenEnv, target)
+	}
+	return target, nil
+}
+
+// parsePullRequestURL recognizes the web URLs of GitHub pull requests, GitLab
+// merge requests and Bitbucket Cloud pull requests
+func parsePullRequestURL(ref string) (*pullRequestTarget, error) {
+	u, err := url.Parse(ref)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid pull request URL: %s (expected a URL or auto)", ref)
+	}
+	path := strings.Trim(u.Path, "/")
+	invalid := fmt.Errorf("unsupported pull request URL: %s", ref)
+
+	var target pullRequestTarget
+	var number string
+	switch {
+	case strings.Contains(path, "/-/merge_requests/"):
+		target.provider = "gitlab"
+		target.repo, number, _ = strings.Cut(path, "/-/merge_requests/")
+		target.api = u.Scheme + "://" + u.Host + "/api/v4"
+		if env := os.Getenv("CI_API_V4_URL"); env != "" {
+			target.api = strings.TrimSuffix(env, "/")
+		}
+	case u.Hostname() == "bitbucket.org":
+		target.provider = "bitbucket"
+		target.repo, number, _ = strings.Cut(path, "/pull-requests/")
+		target.api = "https://api.bitbucket.org/2.0"
+	case strings.Contains(path, "/pull/"):
+		target.provider = "github"
+		target.repo, number, _ = strings.Cut(path, "/pull/")
+		target.api = "https://api.github.com"
+		if u.Hostname() != "github.com" {
+			target.api = u.Scheme + "://" + u.Host + "/api/v3"
+		}
+		if env := os.Getenv("GITHUB_API_URL"); env != "" {
+			target.api = strings.TrimSuffix(env, "/")
+		}
+	default:
+		return nil, invalid
+	}
+
+	// Trailing parts such as /files or /diffs are ignored
+	number, _, _ = strings.Cut(number, "/")
+	if target.number, err = strconv.Atoi(number); err != nil || target.repo == "" {
+		return nil, invalid
+	}
+	return &target, nil
+}
+
+// detectPullRequest reads the pull request of the build from the variables
+// of GitHub Actions, GitLab CI and Bitbucket Pipelines
+func detectPullRequest() (*pullRequestTarget, error) {
+	switch {
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		// refs/pull/<number>/merge in pull_request workflows
+		ref := strings.TrimPrefix(os.Getenv("GITHUB_REF"), "refs/pull/")
+		number, _, ok := strings.Cut(ref, "/")
+		if !ok || ref == os.Getenv("GITHUB_REF") {
+			runenv.Logger.Info("Not a pull request build, skipping the pull request comment")
+			return nil, nil
+		}
+		api := "https://api.github.com"
+		if env := os.Getenv("GITHUB_API_URL"); env != "" {
+			api = strings.TrimSuffix(env, "/")
+		}
+		return newDetectedPullRequest("github", api, os.Getenv("GITHUB_REPOSITORY"), number)
+
+	case os.Getenv("GITLAB_CI") == "true":
+		number := os.Getenv("CI_MERGE_REQUEST_IID")
+		if number == "" {
+			runenv.Logger.Info("Not a merge request pipeline, skipping the merge request comment")
+			return nil, nil
+		}
+		return newDetectedPullRequest("gitlab", strings.TrimSuffix(os.Getenv("CI_API_V4_URL"), "/"), os.Getenv("CI_PROJECT_PATH"), number)
+
+	case os.Getenv("BITBUCKET_BUILD_NUMBER") != "":
+		number := os.Getenv("BITBUCKET_PR_ID")
+		if number == "" {
+			runenv.Logger.Info("Not a pull request pipeline, skipping the pull request comment")
+			return nil, nil
+		}
+		return newDetectedPullRequest("bitbucket", "https://api.bitbucket.org/2.0", os.Getenv("BITBUCKET_REPO_FULL_NAME"), number)
+	}
+	return nil, fmt.Errorf("no pull request found: --comment-pr auto needs GitHub Actions, GitLab CI or Bitbucket Pipelines, pass the URL of the pull request instead")
+}
+
+func newDetectedPullRequest(provider, api, repo, number string) (*pullRequestTarget, error) {
+	n, err := strconv.Atoi(number)
+	if err != nil || repo == "" || api == "" {
+		return nil, fmt.Errorf("incomplete %s CI environment, pass the URL of the pull request instead", provider)
+	}
+	return &pullRequestTarget{provider: provider, api: api, repo: repo, number: n}, nil
+}
+
+// commentOnPullRequest posts the markdown report to the pull request, or
+// updates the comment of an earlier scan
+func commentOnPullRequest(target pullRequestTarget, report []byte) error {
+	body := commentMarker + "\n" + string(report)
+
+	var link string
+	var err error
+	switch target.provider {
+	case "gitlab":
+		link, err = upsertGitLabNote(target, body)
+	case "bitbucket":
+		link, err = upsertBitbucketComment(target, body)
+	default:
+		link, err = upsertGitHubComment(target, body)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to comment on %s: %v", target, err)
+	}
+	runenv.Logger.Infof("Commented on %s", link)
+	return nil
+}
+
+func upsertGitHubComment(target pullRequestTarget, body string) (string, error) {
+	headers := map[string]string{"Authorization": "Bearer " + target.token, "Accept": "application/vnd.github+json"}
+	issue := fmt.Sprintf("%s/repos/%s/issues/%d", target.api, target.repo, target.number)
+
+	type comment struct {
+		ID      int64  `json:"id"`
+		Body    string `json:"body"`
+		HTMLURL string `json:"html_url"`
+	}
+	for page := 1; ; page++ {
+		var comments []comment
+		if err := sendJSON(http.MethodGet, fmt.Sprintf("%s/comments?per_page=100&page=%d", issue, page), headers, nil, &comments); err != nil {
+			return "", err
+		}
+		for _, c := range comments {
+			if strings.HasPrefix(c.Body, commentMarker) {
+				var updated comment
+				endpoint := fmt.Sprintf("%s/repos/%s/issues/comments/%d", target.api, target.repo, c.ID)
+				if err := sendJSON(http.MethodPatch, endpoint, headers, map[string]string{"body": body}, &updated); err != nil {
+					return "", err
+				}
+				return updated.HTMLURL, nil
+			}
+		}
+		if len(comments) < 100 {
+			break
+		}
+	}
+
+	var created comment
+	if err := postJSON(issue+"/comments", headers, map[string]string{"body": body}, &created); err != nil {
+		return "", err
+	}
+	return created.HTMLURL, nil
+}
+
+func upsertGitLabNote(target pullRequestTarget, body string) (string, error) {
+	headers := map[string]string{"PRIVATE-TOKEN": target.token}
+	mr := fmt.Sprintf("%s/projects/%s/merge_requests/%d", target.api, url.PathEscape(target.repo), target.number)
+
+	type note struct {
+		ID   int64  `json:"id"`
+		Body string `json:"body"`
+	}
+	for page := 1; ; page++ {
+		var notes []note
+		if err := sendJSON(http.MethodGet, fmt.Sprintf("%s/notes?per_page=100&page=%d", mr, page), headers, nil, &notes); err != nil {
+			return "", err
+		}
+		for _, n := range notes {
+			if strings.HasPrefix(n.Body, commentMarker) {
+				var updated note
+				if err := sendJSON(http.MethodPut, fmt.Sprintf("%s/notes/%d", mr, n.ID), headers, map[string]string{"body": body}, &updated); err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("%s!%d (note %d)", target.repo, target.number, n.ID), nil
+			}
+		}
+		if len(notes) < 100 {
+			break
+		}
+	}
+
+	var created note
+	if err := postJSON(mr+"/notes", headers, map[string]string{"body": body}, &created); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s!%d (note %d)", target.repo, target.number, created.ID), nil
+}
+
+func upsertBitbucketComment(target pullRequestTarget, body string) (string, error) {
+	headers := map[string]string{"Authorization": "Bearer " + target.token}
+	pr := fmt.Sprintf("%s/repositories/%s/pullrequests/%d", target.api, target.repo, target.number)
+	payload := map[string]any{"content": map[string]string{"raw": body}}
+
+	type comment struct {
+		ID      int64 `json:"id"`
+		Content struct {
+			Raw string `json:"raw"`
+		} `json:"content"`
+		Links struct {
+			HTML struct {
+				Href string `json:"href"`
+			} `json:"html"`
+		} `json:"links"`
+	}
+	next := pr + "/comments?pagelen=100"
+	for next != "" {
+		var page struct {
+			Values []comment `json:"values"`
+			Next   string    `json:"next"`
+		}
+		if err := sendJSON(http.MethodGet, next, headers, nil, &page); err != nil {
+			return "", err
+		}
+		for _, c := range page.Values {
+			if strings.HasPrefix(c.Content.Raw, commentMarker) {
+				var updated comment
+				if err := sendJSON(http.MethodPut, fmt.Sprintf("%s/comments/%d", pr, c.ID), headers, payload, &updated); err != nil {
+					return "", err
+				}
+				return updated.Links.HTML.Href, nil
+			}
+		}
+		next = page.Next
+	}
+
+	var created comment
+	if err := postJSON(pr+"/comments", headers, payload, &created); err != nil {
+		return "", err
+	}
+	return created.Links.HTML.Href, nil
+}
@@ -0,0 +1,219 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// pullRequest is the branch and description of a remediation change
+type pullRequest struct {
+	title  string
+	body   string
+	branch string
+	base   string
+}
+
+// git runs git in dir and returns its trimmed output
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %v: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// parseGitRemote returns the host and repository path of an HTTPS or SSH remote URL
+func parseGitRemote(remote string) (string, string, error) {
+	remote = strings.TrimSuffix(strings.TrimSpace(remote), ".git")
+	if strings.Contains(remote, "://") {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid remote URL %s: %v", remote, err)
+		}
+		return u.Hostname(), strings.Trim(u.Path, "/"), nil
+	}
+
+	// scp-like syntax: git@github.com:owner/repo
+	host, path, ok := strings.Cut(remote, ":")
+	if !ok {
+		return "", "", fmt.Errorf("unsupported remote URL: %s", remote)
+	}
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	return host, strings.Trim(path, "/"), nil
+}
+
+// OpenFixPullRequest commits the changed POM to a new branch, pushes it and
+// opens a pull request (GitHub) or merge request (GitLab) against the current branch
+func OpenFixPullRequest(provider, pomPath string, changes []pomChange, validation string) error {
+	tokenEnv := scan.GitHubTokenEnv
+	if provider == "gitlab" {
+		tokenEnv = scan.GitLabTokenEnv
+	}
+	token := strings.TrimSpace(os.Getenv(tokenEnv))
+	if token == "" {
+		return fmt.Errorf("%s is not set", tokenEnv)
+	}
+
+	dir := filepath.Dir(pomPath)
+	base, err := git(dir, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return err
+	}
+	remote, err := git(dir, "remote", "get-url", "origin")
+	if err != nil {
+		return err
+	}
+	host, repo, err := parseGitRemote(remote)
+	if err != nil {
+		return err
+	}
+
+	title := fmt.Sprintf("Upgrade %d vulnerable dependencies", len(changes))
+	if len(changes) == 1 {
+		title = fmt.Sprintf("Upgrade %s to %s", changes[0].Package, changes[0].To)
+	}
+
+	pr := pullRequest{
+		title:  title,
+		body:   pullRequestBody(changes, validation),
+		branch: "sbom-scanner/fix-" + time.Now().Format("20060102-150405"),
+		base:   base,
+	}
+
+	for _, args := range [][]string{
+		{"checkout", "-b", pr.branch},
+		{"add", filepath.Base(pomPath)},
+		{"commit", "-m", pr.title},
+		{"push", "-u", "origin", pr.branch},
+		{"checkout", pr.base},
+	} {
+		if _, err := git(dir, args...); err != nil {
+			return err
+		}
+	}
+
+	var link string
+	if provider == "gitlab" {
+		link, err = createGitLabMergeRequest(host, repo, token, pr)
+	} else {
+		link, err = createGitHubPullRequest(host, repo, token, pr)
+	}
+	if err != nil {
+		return err
+	}
+
+	runenv.Logger.Infof("Opened %s", link)
+	return nil
+}
+
+// pullRequestBody lists the upgrades and the validation result as Markdown
+func pullRequestBody(changes []pomChange, validation string) string {
+	var b strings.Builder
+	b.WriteString("Upgrades vulnerable dependencies to the versions suggested by sbom-scanner.\n\n")
+	b.WriteString("| Dependency | From | To | Change |\n|---|---|---|---|\n")
+	for _, c := range changes {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", c.Package, c.From, c.To, c.How)
+	}
+	if validation != "" {
+		b.WriteString("\n" + validation + "\n")
+	}
+	return b.String()
+}
+
+func createGitHubPullRequest(host, repo, token string, pr pullRequest) (string, error) {
+	api := "https://api.github.com"
+	if host != "github.com" {
+		api = "https://" + host + "/api/v3"
+	}
+	if env := os.Getenv("GITHUB_API_URL"); env != "" {
+		api = strings.TrimSuffix(env, "/")
+	}
+
+	payload := map[string]string{"title": pr.title, "body": pr.body, "head": pr.branch, "base": pr.base}
+	headers := map[string]string{"Authorization": "Bearer " + token, "Accept": "application/vnd.github+json"}
+
+	var result struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := postJSON(api+"/repos/"+repo+"/pulls", headers, payload, &result); err != nil {
+		return "", fmt.Errorf("failed to open pull request: %v", err)
+	}
+	return result.HTMLURL, nil
+}
+
+func createGitLabMergeRequest(host, repo, token string, pr pullRequest) (string, error) {
+	api := "https://" + host + "/api/v4"
+	if env := os.Getenv("CI_API_V4_URL"); env != "" {
+		api = strings.TrimSuffix(env, "/")
+	}
+
+	payload := map[string]string{"title": pr.title, "description": pr.body, "source_branch": pr.branch, "target_branch": pr.base}
+	headers := map[string]string{"PRIVATE-TOKEN": token}
+
+	var result struct {
+		WebURL string `json:"web_url"`
+	}
+	if err := postJSON(api+"/projects/"+url.PathEscape(repo)+"/merge_requests", headers, payload, &result); err != nil {
+		return "", fmt.Errorf("failed to open merge request: %v", err)
+	}
+	return result.WebURL, nil
+}
+
+// postJSON sends payload as JSON and decodes the response into result
+func postJSON(endpoint string, headers map[string]string, payload, result any) error {
+	return sendJSON(http.MethodPost, endpoint, headers, payload, result)
+}
+
+// sendJSON sends a request with payload as JSON, no body when payload is nil,
+// and decodes the response into result
+func sendJSON(method, endpoint string, headers map[string]string, payload, result any) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("User-Agent", "sbom-scanner")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := runenv.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var detail bytes.Buffer
+		detail.ReadFrom(resp.Body)
+		return fmt.Errorf("%s %s", resp.Status, strings.TrimSpace(detail.String()))
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,118 @@
+package scanner
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/report"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// EvaluateResults decides whether the scan should fail, based on
+// --exit-on-vuln (any finding) and --fail-on (findings at or above a severity).
+// Known malicious package versions always fail the scan, even when they are
+// in the baseline; only an ignore rule suppresses them. The findings are
+// counted as they are read from the report.
+func EvaluateResults(reportPath string, opts ScanOptions) error {
+	var malicious []string
+	findings, suppressed, failing, unknown := 0, 0, 0, 0
+	err := scan.FindingsFrom(reportPath).SuppressedBy(opts.ignoreRules)(func(f scan.Finding) error {
+		if f.SuppressedBy != "" {
+			suppressed++
+			return nil
+		}
+		if f.Malicious() {
+			runenv.Logger.Errorf("Malicious package %s@%s (%s)", f.Package, f.Version, f.ID)
+			malicious = append(malicious, f.Package+"@"+f.Version)
+		}
+		if opts.baseline != nil && report.InBaseline(opts.baseline.Findings, f) {
+			return nil
+		}
+		findings++
+		if opts.failOn != "" && scan.ReachesSeverity(f, opts.failOn) {
+			failing++
+		}
+		if f.Severity == scan.SeverityUnknown {
+			unknown++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(malicious) > 0 {
+		return runenv.WithExitCode(fmt.Errorf("known malicious packages found: %s, see details in: %s",
+			strings.Join(malicious, ", "), reportPath), runenv.ExitMalicious)
+	}
+
+	if !opts.exitOnVuln && opts.failOn == "" {
+		return nil
+	}
+	if suppressed > 0 {
+		runenv.Logger.Infof("%d vulnerabilities suppressed by ignore rules", suppressed)
+	}
+
+	scope := ""
+	if opts.baseline != nil {
+		scope = "new "
+		runenv.Logger.Infof("%d vulnerabilities are not in the baseline %s", findings, opts.baseline.Path)
+	}
+
+	if opts.failOn != "" {
+		if failing > 0 {
+			return runenv.WithExitCode(fmt.Errorf("%d %svulnerabilities with %s or higher severity found, see details in: %s",
+				failing, scope, opts.failOn, reportPath), runenv.ExitVulnerabilities)
+		}
+		if unknown > 0 && opts.failOnUnknown {
+			return runenv.WithExitCode(fmt.Errorf("%d %svulnerabilities of unknown severity found, see details in: %s",
+				unknown, scope, reportPath), runenv.ExitVulnerabilities)
+		}
+		if unknown > 0 {
+			runenv.Logger.Warnf("%d %svulnerabilities have no severity and do not count toward --fail-on (see --fail-on-unknown)", unknown, scope)
+		}
+		return nil
+	}
+
+	if findings > 0 {
+		return runenv.WithExitCode(fmt.Errorf("%svulnerabilities found, see details in: %s", scope, reportPath), runenv.ExitVulnerabilities)
+	}
+	return nil
+}
+
+// runReportTitle is the title of the reports of a run
+func runReportTitle(results Result, single bool) string {
+	if single && len(results.Modules) == 1 {
+		return "Vulnerability Report: " + filepath.Base(results.Modules[0].ProjectFile)
+	}
+	return "Aggregated Vulnerability Report"
+}
+
+// runMarkdown renders the markdown report of a run, compared with the
+// baseline when there is one, for the GitHub job summary and pull request
+// comments
+func runMarkdown(title string, results Result, opts ScanOptions) ([]byte, error) {
+	data, err := report.NewReportData(title, results.findings.Where(scan.ActiveFinding), results.findings.Where(scan.SuppressedFinding))
+	if err != nil {
+		return nil, err
+	}
+	if opts.baseline != nil {
+		known, _ := scan.ApplySuppressions(opts.baseline.Findings, opts.ignoreRules)
+		diff, err := report.DiffFindings(known, data.Findings.Source)
+		if err != nil {
+			return nil, err
+		}
+		diff.Baseline = opts.baseline.Path
+		data.Diff = &diff
+	}
+
+	content, err := report.RenderMarkdown(data)
+	if err != nil {
+		return nil, err
+	}
+	if results.Error != "" {
+		content = append([]byte(fmt.Sprintf("> **Scan failed:** %s\n\n", results.Error)), content...)
+	}
+	return content, nil
+}
@@ -0,0 +1,683 @@
+// Package scanner generates SBOMs for Maven, Gradle, npm, Go, Python, Rust,
+// .NET, PHP and Ruby projects, scans them for vulnerabilities and writes the
+// reports. Run scans a project file or a directory of modules the same way
+// the sbom-scanner command does, with the stages of the packages sbom, maven,
+// scan and report.
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/maven"
+	"github.com/xshuden/sbom-scanner/pkg/report"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// SetLogger replaces the logger the scanner writes its progress to
+func SetLogger(l *logrus.Logger) {
+	runenv.Logger = l
+}
+
+// Logger returns the logger the scanner writes its progress to
+func Logger() *logrus.Logger {
+	return runenv.Logger
+}
+
+// Options configures a scan. Empty fields take the defaults of the
+// sbom-scanner command.
+type Options struct {
+	Target    string // project file, built archive or directory of modules, relative to the repository with GitURL
+	GitURL    string // remote Git repository, shallow-cloned into a temporary directory for the scan
+	GitRef    string // branch, tag or commit of GitURL, the default branch when empty
+	Since     string // Git ref of the checkout of Target, unchanged modules reuse the results of the previous run
+	OutputDir string // each run writes to a new timestamped subdirectory
+	Clean     bool   // write directly into OutputDir after emptying it, except for the history database
+	KeepLast  int    // number of run directories kept in OutputDir, all when zero
+
+	// Target holds Kubernetes manifests or a Helm chart, whose container
+	// images are scanned with syft instead of a project
+	Kubernetes bool
+	HelmValues []string // values files of the Helm chart
+
+	// Repository of a container registry, e.g. ghcr.io/org/app, whose tags
+	// are scanned instead of Target
+	Registry    string
+	Tags        []string // globs of the tags to scan, all when empty
+	ExcludeTags []string // globs of the tags to skip
+
+	Resolver string   // Maven dependency resolver: maven (default) or native
+	Scanners []string // osv (default), osv-binary, grype, trivy or all
+	Scopes   []string // Maven scopes to scan, all when empty
+	Reports  []string // csv, html, junit, markdown, openvex, pdf
+	Graphs   []string // dot, mermaid, graphml
+
+	ExitOnVuln    bool
+	FailOn        string   // critical, high, medium or low
+	FailOnUnknown bool     // findings without severity information fail the scan too with FailOn
+	FailOnLicense []string // SPDX ids
+	Ignore        []scan.IgnoreRule
+	IgnoreFile    string
+	VEX           []string // OpenVEX or CycloneDX VEX documents
+	Policies      []scan.PolicyRule
+	PolicyFile    string
+	Baseline      string // findings of a previous scan, only new ones fail the scan
+	GHSA          bool   // enriches findings from the GitHub Security Advisories, the token is read from GITHUB_TOKEN
+	NVD           bool   // enriches findings with the CVSS vectors and CPEs of the NVD, NVD_API_KEY raises the rate limit
+	Exploits      bool   // enriches findings with EPSS scores and the CISA KEV catalog
+	Reachability  bool   // tags Java findings as reachable or unreachable from the compiled classes
+	Maintenance   bool   // flags end of life, deprecated and unmaintained components
+	StaleAfter    string // time without a release after which a package is unmaintained, e.g. 2y (default: DefaultStaleAfter)
+	SupplyChain   bool   // flags dependency confusion and typosquatting
+	// Maven groupIds, npm scopes or package patterns of internal packages,
+	// which must not be published in public registries
+	InternalNamespaces []string
+	Secrets            bool   // scans the project files for API keys, tokens and private keys
+	FailOnSecret       string // critical, high, medium or low; secrets never fail the scan when empty
+
+	HistoryDB  string // scan-history.db in the output directory when empty
+	NoHistory  bool
+	WebhookURL string
+	CommentPR  string     // URL of a pull request to post the report to, or auto to detect it in CI
+	DefectDojo DefectDojo // the API key is read from DEFECTDOJO_TOKEN
+	Email      EmailConfig
+	Tools      ToolsConfig
+
+	Offline        bool
+	DBDir          string // offline OSV database directory
+	TrivyCacheDir  string
+	CacheTTL       time.Duration // zero uses the default, negative disables the cache
+	MavenRepoLocal string
+	MavenOffline   bool
+	MavenSettings  string   // settings.xml passed to Maven with -s
+	MavenProfiles  []string // activated with -P
+	MavenArgs      []string // added to every Maven run, e.g. -DskipTests
+	Workspace      string   // parent of the temporary Maven workspaces and clones, the work directory or the system temp directory when empty
+	NoDepsTree     bool     // skips the dependency tree, findings get no dependency paths and --graph is unavailable
+	NoEffectivePOM bool     // skips the Maven effective POM
+	IncludePlugins bool     // adds the Maven build plugins and their dependencies to the SBOM with scope excluded
+	KeepTemp       bool     // keeps the Maven workspaces and Gradle build directories for debugging
+	Workdir        string   // holds the temporary files and caches of the runs instead of the system temp and user cache directories
+	WorkdirMaxSize string   // size limit of Workdir, e.g. 20GB; the oldest cache entries are removed to stay below it
+
+	// MavenRepositories are private repositories written to a generated
+	// settings.xml, which is used instead of MavenSettings
+	MavenRepositories []maven.MavenRepository
+
+	CycloneDXPluginVersion string // DefaultCycloneDXPluginVersion when empty, or latest
+	Parallelism            int    // number of CPUs when zero
+
+	Timeout      time.Duration // whole run, unlimited when zero
+	StageTimeout time.Duration // every pipeline step, e.g. the Maven resolution
+	Retries      *int          // of OSV queries and Maven downloads, DefaultRetries when nil
+	RetryBackoff time.Duration // wait before the first retry, doubled for every further one
+
+	Sign           bool     // signs every SBOM with cosign, keyless through Fulcio and Rekor unless SignKey is set
+	SignKey        string   // cosign private key file or KMS URI, e.g. awskms:///alias/sbom
+	Attest         string   // predicate of the in-toto SBOM attestation, cyclonedx or spdx; none when empty
+	AttestSubjects []string // artifacts the SBOM describes, files or name@sha256:digest; the project files when empty
+
+	OTLPEndpoint string // OTLP/HTTP collector the spans of the run are exported to, e.g. http://localhost:4318
+	Timings      bool   // records the time of the stages, Maven runs and HTTP requests into the summary
+
+	// Quiet hides the progress bar and the module table, e.g. when the
+	// summary is written to stdout with WriteSummary
+	Quiet bool
+}
+
+// GenerateOptions validates the options of the SBOM generation
+func (o Options) GenerateOptions() (ScanOptions, error) {
+	opts := ScanOptions{Resolver: o.Resolver, exitOnVuln: o.ExitOnVuln, noDepsTree: o.NoDepsTree, noEffective: o.NoEffectivePOM, includePlugins: o.IncludePlugins}
+	if o.SignKey != "" && !o.Sign {
+		return opts, fmt.Errorf("--sign-key needs --sign")
+	}
+	if o.Sign && o.SignKey == "" && o.Offline {
+		return opts, fmt.Errorf("keyless signing needs Fulcio and Rekor and cannot be combined with --offline, sign with --sign-key")
+	}
+	opts.Sign = signOptions{Enabled: o.Sign, key: o.SignKey}
+	var err error
+	if opts.Attest, err = parseAttestOptions(o.Attest, o.AttestSubjects); err != nil {
+		return opts, err
+	}
+
+	if opts.Resolver == "" {
+		opts.Resolver = "maven"
+	}
+	switch opts.Resolver {
+	case "maven", "native":
+	default:
+		return opts, fmt.Errorf("unknown resolver: %s", opts.Resolver)
+	}
+
+	if opts.scopes, err = sbom.ParseScopes(strings.Join(o.Scopes, ",")); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// IgnoreRules combines the ignore rules with those of the ignore file and
+// the VEX documents
+func (o Options) IgnoreRules() ([]scan.IgnoreRule, error) {
+	ignoreRules := slices.Clone(o.Ignore)
+	if o.IgnoreFile != "" {
+		rules, err := scan.LoadIgnoreFile(o.IgnoreFile)
+		if err != nil {
+			return nil, err
+		}
+		ignoreRules = append(ignoreRules, rules...)
+	}
+	for _, path := range o.VEX {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		rules, err := scan.LoadVEX(path)
+		if err != nil {
+			return nil, err
+		}
+		runenv.Logger.Infof("Loaded %d VEX statements from %s", len(rules), path)
+		ignoreRules = append(ignoreRules, rules...)
+	}
+	if err := scan.ValidateIgnoreRules(ignoreRules); err != nil {
+		return nil, err
+	}
+	return ignoreRules, nil
+}
+
+// ScanOptions validates the options with the settings of the run of ctx and
+// loads the ignore, VEX, policy and baseline files they refer to
+func (o Options) ScanOptions(ctx context.Context) (ScanOptions, error) {
+	opts, err := o.GenerateOptions()
+	if err != nil {
+		return opts, err
+	}
+
+	scanners := strings.Join(o.Scanners, ",")
+	if scanners == "" {
+		scanners = "osv"
+	}
+	if opts.Scanners, err = scan.ParseScanners(runenv.SettingsOf(ctx), scanners); err != nil {
+		return opts, err
+	}
+	if len(opts.Scanners) == 0 {
+		return opts, fmt.Errorf("no vulnerability scanner is available")
+	}
+
+	if opts.reports, err = report.ParseReportFormats(strings.Join(o.Reports, ",")); err != nil {
+		return opts, err
+	}
+	if opts.graphs, err = report.ParseGraphFormats(strings.Join(o.Graphs, ",")); err != nil {
+		return opts, err
+	}
+	if len(opts.graphs) > 0 && opts.noDepsTree {
+		return opts, fmt.Errorf("dependency graphs are built from the dependency tree and cannot be combined with --no-deps-tree")
+	}
+	if opts.failOn, err = scan.ParseSeverityThreshold(o.FailOn); err != nil {
+		return opts, err
+	}
+	if o.FailOnUnknown && opts.failOn == "" {
+		return opts, fmt.Errorf("--fail-on-unknown needs --fail-on")
+	}
+	opts.failOnUnknown = o.FailOnUnknown
+	opts.denyLicense = scan.ParseLicenseDenylist(strings.Join(o.FailOnLicense, ","))
+
+	if opts.ignoreRules, err = o.IgnoreRules(); err != nil {
+		return opts, err
+	}
+
+	policies := slices.Clone(o.Policies)
+	if o.PolicyFile != "" {
+		rules, err := scan.LoadPolicyFile(o.PolicyFile)
+		if err != nil {
+			return opts, err
+		}
+		policies = append(policies, rules...)
+	}
+	if err := scan.ValidatePolicyRules(policies); err != nil {
+		return opts, err
+	}
+	opts.policies = policies
+
+	if o.GHSA {
+		if opts.ghsa, err = scan.NewGHSAOptions(ctx); err != nil {
+			return opts, err
+		}
+	}
+	if o.NVD {
+		if opts.nvd, err = scan.NewNVDOptions(ctx); err != nil {
+			return opts, err
+		}
+	}
+	if o.Exploits {
+		if o.Offline {
+			return opts, fmt.Errorf("the EPSS and KEV enrichment needs FIRST and CISA and cannot be combined with --offline")
+		}
+		opts.exploits = true
+	}
+	opts.reachability = o.Reachability
+	if o.Maintenance {
+		if o.Offline {
+			return opts, fmt.Errorf("the maintenance check needs endoflife.date and deps.dev and cannot be combined with --offline")
+		}
+		opts.staleAfter = o.StaleAfter
+		if opts.staleAfter == "" {
+			opts.staleAfter = scan.DefaultStaleAfter
+		}
+		if err := scan.ValidateStaleAfter(opts.staleAfter); err != nil {
+			return opts, err
+		}
+	}
+	if len(o.InternalNamespaces) > 0 && !o.SupplyChain {
+		return opts, fmt.Errorf("--internal-namespaces needs --supply-chain")
+	}
+	opts.supplyChain = o.SupplyChain
+	opts.internalNamespaces = o.InternalNamespaces
+	if o.FailOnSecret != "" && !o.Secrets {
+		return opts, fmt.Errorf("--fail-on-secret needs --secrets")
+	}
+	opts.secrets = o.Secrets
+	if opts.failOnSecret, err = scan.ParseSeverityThreshold(o.FailOnSecret); err != nil {
+		return opts, err
+	}
+	if len(o.HelmValues) > 0 && !o.Kubernetes {
+		return opts, fmt.Errorf("--helm-values needs --k8s")
+	}
+	if o.Registry != "" && (o.GitURL != "" || o.Kubernetes) {
+		return opts, fmt.Errorf("--registry cannot be combined with --git or --k8s")
+	}
+	if o.Registry != "" && o.Offline {
+		return opts, fmt.Errorf("listing the tags of a registry cannot be combined with --offline")
+	}
+	if o.Since != "" {
+		switch {
+		case o.GitURL != "":
+			return opts, fmt.Errorf("--since needs the history of a local checkout and cannot be combined with --git")
+		case o.Registry != "" || o.Kubernetes:
+			return opts, fmt.Errorf("--since cannot be combined with --registry or --k8s")
+		case o.Clean:
+			return opts, fmt.Errorf("--since reuses the results of the previous run and cannot be combined with --clean")
+		}
+	}
+	if (len(o.Tags) > 0 || len(o.ExcludeTags) > 0) && o.Registry == "" {
+		return opts, fmt.Errorf("--tags and --exclude-tags need --registry")
+	}
+
+	opts.defectDojo = defectDojoOptions{
+		url:        o.DefectDojo.URL,
+		token:      defectDojoToken(),
+		product:    o.DefectDojo.Product,
+		engagement: o.DefectDojo.Engagement,
+		dedup:      o.DefectDojo.Dedup,
+		closeOld:   o.DefectDojo.CloseOld,
+	}
+	if err := opts.defectDojo.validate(); err != nil {
+		return opts, err
+	}
+
+	if o.WebhookURL != "" {
+		if err := validateWebhookURL(o.WebhookURL); err != nil {
+			return opts, err
+		}
+	}
+	opts.webhookURL = o.WebhookURL
+
+	if o.CommentPR != "" {
+		if opts.comment, err = resolvePullRequest(o.CommentPR); err != nil {
+			return opts, err
+		}
+	}
+
+	email := o.Email
+	if err := email.validate(); err != nil {
+		return opts, err
+	}
+	opts.email = email
+	// Emailed reports are rendered even when not requested with Reports
+	for _, format := range email.Attach {
+		if !slices.Contains(opts.reports, format) {
+			opts.reports = append(opts.reports, format)
+		}
+	}
+
+	// The baseline is read before the output directory, where it may live, is cleaned
+	if o.Baseline != "" {
+		findings, err := report.LoadFindingsFile(o.Baseline)
+		if err != nil {
+			return opts, err
+		}
+		opts.baseline = &report.BaselineFindings{Path: o.Baseline, Findings: findings}
+		runenv.Logger.Infof("Loaded %d findings from baseline %s", len(findings), o.Baseline)
+	}
+	return opts, nil
+}
+
+// Run scans the target into the output directory, records the scan in the
+// history database and sends the notifications. When the scan itself fails,
+// e.g. because FailOn is exceeded, the result is returned together with the
+// error.
+func Run(ctx context.Context, o Options) (*Result, error) {
+	ctx, span := runenv.StartTrace(ctx, o.OTLPEndpoint, "sbom-scanner scan")
+	result, err := run(ctx, o)
+	if span != nil {
+		if result != nil {
+			span.Set("sbom_scanner.target", result.Target)
+			span.Set("sbom_scanner.scanners", strings.Join(result.Scanners, ","))
+			span.Set("sbom_scanner.status", result.Status)
+			span.Set("sbom_scanner.modules", len(result.Modules))
+			summary := result.Summary()
+			span.Set("sbom_scanner.findings", summary.Vulnerabilities+summary.Suppressed)
+		}
+		span.Set("sbom_scanner.exit_code", runenv.ExitCode(err))
+		span.Finish(err)
+		span.Export()
+	}
+	return result, err
+}
+
+// run is Run within the span of the whole run
+func run(ctx context.Context, o Options) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	settings, err := o.settings()
+	if err != nil {
+		return nil, err
+	}
+	ctx = runenv.WithSettings(ctx, settings)
+	ctx = runenv.WithTimings(ctx, o.Timings)
+	ctx, finishWorkDir, err := runenv.StartWorkDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer finishWorkDir()
+	parent := ctx
+	if o.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+	}
+	if settings.Offline {
+		runenv.Logger.Infof("Offline mode, using the OSV database in %s", scan.OSVDatabase(settings))
+	}
+	if o.OutputDir == "" {
+		o.OutputDir = "scan-results"
+	}
+	if o.KeepLast < 0 {
+		return nil, fmt.Errorf("invalid number of runs to keep: %d", o.KeepLast)
+	}
+
+	opts, err := o.ScanOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	displayTarget := o.Target
+	if o.GitURL != "" {
+		cloneCtx, span := runenv.StartSpan(ctx, "Cloning Repository")
+		start := time.Now()
+		target, cleanup, err := checkoutRepository(cloneCtx, o.GitURL, o.GitRef, o.Target)
+		runenv.RecordTiming(ctx, runenv.TimingStage, "Cloning Repository", time.Since(start))
+		span.Finish(err)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+		displayTarget = gitTarget(o.GitURL, o.GitRef, o.Target)
+		o.Target = target
+		if opts.Attest.Format != "" {
+			opts.Attest.source = &attestSource{uri: gitTarget(o.GitURL, o.GitRef, ""), commit: gitCommit(ctx, target)}
+		}
+	}
+
+	var projects []sbom.Project
+	var workloads []sbom.Workload
+	var services []sbom.ComposeService
+	switch {
+	case o.Registry != "":
+		projects, err = sbom.FindRegistryTags(ctx, o.Registry, o.Tags, o.ExcludeTags)
+		displayTarget = o.Registry
+	case o.Kubernetes:
+		projects, workloads, err = sbom.FindKubernetesImages(ctx, o.Target, o.HelmValues)
+	case sbom.IsComposeFile(o.Target):
+		projects, services, err = sbom.FindComposeServices(o.Target, o.OutputDir)
+	default:
+		projects, err = sbom.FindProjects(o.Target, o.OutputDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The state of the checkout that is scanned, for the next --since
+	var commit string
+	var uncommitted []string
+	if o.Registry == "" {
+		commit, uncommitted = gitState(ctx, o.Target)
+	}
+
+	if o.Since != "" {
+		if services != nil {
+			return nil, fmt.Errorf("--since cannot be combined with Docker Compose files")
+		}
+		if err := markUnchangedProjects(ctx, o.Target, displayTarget, o.Since, o.OutputDir, projects); err != nil {
+			return nil, err
+		}
+	}
+
+	ResolveMavenFallback(ctx, &opts, projects)
+	if err := CheckRequiredTools(ctx, projects, opts); err != nil {
+		return nil, err
+	}
+
+	historyPath := ""
+	if !o.NoHistory {
+		historyPath = o.HistoryDB
+		if historyPath == "" {
+			historyPath = filepath.Join(o.OutputDir, HistoryFileName)
+		}
+	}
+
+	// Önce çıktı dizinini oluştur
+	runDir, err := prepareOutputDir(o.OutputDir, o.Target, historyPath, o.Clean)
+	if err != nil {
+		return nil, err
+	}
+	runenv.Logger.Infof("Writing results to %s", runDir)
+
+	startTime := time.Now()
+
+	single := len(projects) == 1 && projects[0].Output == "."
+	opts.aggregated = !single
+	var scanErr error
+	if single {
+		if projects[0].Reuse != "" {
+			scanErr = reuseModuleResults(projects[0], runDir)
+		} else {
+			tasks, err := buildTasks(ctx, projects[0], runDir, opts)
+			if err != nil {
+				return nil, err
+			}
+			scanErr = RunTasks(ctx, tasks, "Running SBOM Scan")
+		}
+	} else {
+		runenv.Logger.Infof("Found %d modules in %s", len(projects), o.Target)
+		scanErr = scanModules(ctx, projects, runDir, opts)
+	}
+	if workloads != nil && ctx.Err() == nil {
+		if err := writeWorkloadReport(runDir, workloads, opts.ignoreRules); err != nil {
+			runenv.Logger.Warn(err)
+		}
+	}
+	if services != nil && ctx.Err() == nil {
+		if err := writeServiceReport(runDir, services, opts.ignoreRules); err != nil {
+			runenv.Logger.Warn(err)
+		}
+	}
+	if o.Registry != "" && ctx.Err() == nil {
+		if err := writeTagMatrix(runDir, projects, opts.ignoreRules); err != nil {
+			runenv.Logger.Warn(err)
+		}
+	}
+
+	// The results of a timed out scan are kept to see how far it got
+	if ctx.Err() != nil && parent.Err() == nil {
+		return nil, fmt.Errorf("scan exceeded the timeout of %s", o.Timeout)
+	}
+
+	// A canceled scan leaves no partial results behind
+	if err := ctx.Err(); err != nil {
+		runenv.Logger.Warn("Scan canceled, removing partial results")
+		if err := removeRunResults(o.OutputDir, runDir, historyPath); err != nil {
+			runenv.Logger.Warnf("Failed to remove partial results: %v", err)
+		}
+		return nil, err
+	}
+
+	if runDir != o.OutputDir {
+		linkLatestRun(o.OutputDir, runDir)
+		if o.KeepLast > 0 {
+			if err := pruneRuns(o.OutputDir, runDir, o.KeepLast); err != nil {
+				runenv.Logger.Warnf("Failed to remove old runs: %v", err)
+			}
+		}
+	}
+
+	// Failed scans, e.g. when FailOn is exceeded, are recorded and notified too
+	results, err := collectRunResults(ctx, displayTarget, runDir, projects, single, opts, scanErr)
+	if err != nil {
+		runenv.Logger.Errorf("Failed to collect results: %v", err)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		return nil, err
+	}
+	results.Commit, results.Uncommitted = commit, uncommitted
+
+	if err := writeSummaryFile(runDir, &results); err != nil {
+		runenv.Logger.Warn(err)
+	}
+	if !settings.Quiet {
+		fmt.Println()
+		WriteSummary(os.Stdout, &results, "text")
+	}
+
+	// Workflow commands are read from stderr as well, which keeps the json
+	// summary on stdout intact
+	if githubActions() {
+		if err := reportToGitHub(os.Stderr, runReportTitle(results, single), results, opts); err != nil {
+			runenv.Logger.Warnf("Failed to write the GitHub job summary: %v", err)
+		}
+		if err := setGitHubOutput("output-dir", runDir); err != nil {
+			runenv.Logger.Warnf("Failed to set the output-dir output of the step: %v", err)
+		}
+	}
+
+	if historyPath != "" {
+		if id, err := recordScan(historyPath, results, startTime); err != nil {
+			runenv.Logger.Warnf("Failed to record scan history: %v", err)
+		} else {
+			runenv.Logger.Infof("Scan recorded as #%d in %s", id, historyPath)
+		}
+	}
+
+	if opts.webhookURL != "" || opts.comment != nil || opts.email.enabled() {
+		_, span := runenv.StartSpan(ctx, "Sending Notifications")
+		err := notify(results, runDir, single, opts)
+		span.Finish(err)
+		if err != nil {
+			if scanErr != nil {
+				runenv.Logger.Error(err)
+			} else {
+				return &results, err
+			}
+		}
+	}
+
+	return &results, scanErr
+}
+
+// CheckDependencies downloads Maven and the OSV Scanner into the tools
+// directory when they are missing and returns the doctor report of the
+// installed versions. It fails when a required tool is missing or too old.
+func CheckDependencies() (DoctorReport, error) {
+	// Check Maven
+	if _, err := exec.LookPath(runenv.SystemMaven(runenv.DefaultSettings)); err != nil {
+		runenv.Logger.Warn("Maven is not installed")
+		if err := runenv.InstallTool("maven"); err != nil {
+			return DoctorReport{}, err
+		}
+	}
+
+	// Check OSV Scanner (used with --scanner=osv-binary)
+	if _, err := exec.LookPath(runenv.DefaultSettings.ToolPath("osv-scanner")); err != nil {
+		runenv.Logger.Warn("OSV Scanner is not installed")
+		if err := runenv.InstallTool("osv-scanner"); err != nil {
+			return DoctorReport{}, err
+		}
+	}
+
+	report := Doctor()
+	for _, check := range report.Tools {
+		switch {
+		case check.Status == "ok":
+			runenv.Logger.Infof("%s %s is installed (%s)", check.Name, check.Version, check.Path)
+		case check.Status == "missing" && !check.Required:
+			runenv.Logger.Infof("%s is not installed (optional)", check.Name)
+		case check.Status == "unknown":
+			runenv.Logger.Warnf("Could not determine the version of %s (%s): %s", check.Name, check.Path, check.Error)
+		case check.Status == "outdated" && !check.Required:
+			runenv.Logger.Warnf("%s %s is older than the supported %s", check.Name, check.Version, check.Minimum)
+		}
+	}
+
+	// Provider plugins are optional
+	for _, p := range sbom.Providers() {
+		runenv.Logger.Infof("Provider %s is available", p.Name())
+	}
+
+	if problems := report.problems(); len(problems) > 0 {
+		return report, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return report, nil
+}
+
+// isSameFileOrJournal reports whether path is the file keep or one of its
+// SQLite journal files
+func isSameFileOrJournal(path, keep string) bool {
+	absPath, err1 := filepath.Abs(path)
+	absKeep, err2 := filepath.Abs(keep)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	return absPath == absKeep || strings.HasPrefix(absPath, absKeep+"-")
+}
+
+// Klasörü temizleyen yardımcı fonksiyon. The history database (keep), its
+// journal files and the output marker are left in place.
+func cleanDirectory(dir, keep string) error {
+	// Klasör içeriğini oku
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	// Her bir öğeyi sil
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.Name() == sbom.OutputMarker || keep != "" && isSameFileOrJournal(path, keep) {
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
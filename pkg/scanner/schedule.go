@@ -0,0 +1,376 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/report"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// File in the data directory holding the last run of every schedule
+const scheduleStateFile = "schedules.json"
+
+// Shorthands of common cron expressions
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var cronMonths = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+
+var cronDays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// cronSchedule is a parsed cron expression, every field a set of bits
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAll, dowAll                bool
+}
+
+// parseCron parses the five fields minute, hour, day of month, month and day
+// of week with lists, ranges, steps and names (jan, mon), or a macro such as
+// @daily. Like cron, a day matches either day field when both are restricted.
+func parseCron(spec string) (*cronSchedule, error) {
+	expr := strings.TrimSpace(spec)
+	if macro, ok := cronMacros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression: %q (expected minute hour day-of-month month day-of-week)", spec)
+	}
+
+	var c cronSchedule
+	var err error
+	parsers := []struct {
+		target   *uint64
+		min, max int
+		names    []string
+	}{
+		{&c.minute, 0, 59, nil},
+		{&c.hour, 0, 23, nil},
+		{&c.dom, 1, 31, nil},
+		{&c.month, 1, 12, cronMonths},
+		{&c.dow, 0, 7, cronDays},
+	}
+	for i, p := range parsers {
+		if *p.target, err = parseCronField(fields[i], p.min, p.max, p.names); err != nil {
+			return nil, fmt.Errorf("invalid cron expression: %q: %v", spec, err)
+		}
+	}
+	// 7 is Sunday as well
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domAll = strings.HasPrefix(fields[2], "*")
+	c.dowAll = strings.HasPrefix(fields[4], "*")
+	return &c, nil
+}
+
+// parseCronField parses a comma-separated list of *, values and ranges, each
+// with an optional /step
+func parseCronField(field string, min, max int, names []string) (uint64, error) {
+	value := func(s string) (int, error) {
+		for i, name := range names {
+			if strings.EqualFold(s, name) {
+				return i + min, nil
+			}
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < min || n > max {
+			return 0, fmt.Errorf("%q is not between %d and %d", s, min, max)
+		}
+		return n, nil
+	}
+
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if rangePart, stepPart, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+			part, step = rangePart, n
+		}
+
+		lo, hi := min, max
+		if part != "*" {
+			from, to, isRange := strings.Cut(part, "-")
+			var err error
+			if lo, err = value(from); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = value(to); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// 5/15 is 5 to the maximum in steps of 15
+				hi = max
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		}
+		for i := lo; i <= hi; i += step {
+			bits |= 1 << i
+		}
+	}
+	return bits, nil
+}
+
+// matches reports whether the schedule fires in the minute of t
+func (c *cronSchedule) matches(t time.Time) bool {
+	return c.dayMatches(t) && c.hour&(1<<t.Hour()) != 0 && c.minute&(1<<t.Minute()) != 0
+}
+
+// dayMatches reports whether the schedule fires on the day of t at all
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	if c.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<int(t.Weekday())) != 0
+	if c.domAll || c.dowAll {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the first minute after t the schedule fires in, or the zero
+// time when it never does (e.g. on February 30)
+func (c *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// Every combination of day and weekday repeats within a few years
+	for end := t.AddDate(5, 0, 0); t.Before(end); {
+		switch {
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// scheduleState is the persisted state of a schedule
+type scheduleState struct {
+	LastRun time.Time `json:"last_run"`
+	LastJob string    `json:"last_job"`
+}
+
+// scheduleStatus is a schedule as listed by the API
+type scheduleStatus struct {
+	Name       string     `json:"name"`
+	Cron       string     `json:"cron"`
+	Target     string     `json:"target"`
+	NextRun    *time.Time `json:"next_run,omitempty"`
+	LastRun    *time.Time `json:"last_run,omitempty"`
+	LastJob    string     `json:"last_job,omitempty"`
+	LastStatus string     `json:"last_status,omitempty"`
+}
+
+// ServerSchedule is a schedule of the config file with its parsed expression
+type ServerSchedule struct {
+	Schedule
+	cron *cronSchedule
+}
+
+// LoadSchedules checks the schedules of the config file. Local projects must
+// exist, since a typo would otherwise only show up at the first run.
+func LoadSchedules(ctx context.Context, schedules []Schedule) ([]ServerSchedule, error) {
+	var result []ServerSchedule
+	seen := make(map[string]bool)
+	for _, schedule := range schedules {
+		if schedule.Name == "" {
+			return nil, fmt.Errorf("schedule without a name")
+		}
+		if seen[schedule.Name] {
+			return nil, fmt.Errorf("duplicate schedule: %s", schedule.Name)
+		}
+		seen[schedule.Name] = true
+
+		cron, err := parseCron(schedule.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %s: %v", schedule.Name, err)
+		}
+		if cron.next(time.Now()).IsZero() {
+			return nil, fmt.Errorf("schedule %s: %s never matches", schedule.Name, schedule.Cron)
+		}
+		if schedule.Git != "" {
+			if err := validateGitURL(schedule.Git); err != nil {
+				return nil, fmt.Errorf("schedule %s: %v", schedule.Name, err)
+			}
+			if schedule.File != "" && !filepath.IsLocal(schedule.File) {
+				return nil, fmt.Errorf("schedule %s: invalid path in the repository: %s", schedule.Name, schedule.File)
+			}
+		} else if schedule.File == "" {
+			return nil, fmt.Errorf("schedule %s: needs git or file", schedule.Name)
+		} else if _, err := os.Stat(schedule.File); err != nil {
+			return nil, fmt.Errorf("schedule %s: project not found: %s", schedule.Name, schedule.File)
+		}
+		if schedule.Scanner != "" {
+			if _, err := scan.ParseScanners(runenv.SettingsOf(ctx), schedule.Scanner); err != nil {
+				return nil, fmt.Errorf("schedule %s: %v", schedule.Name, err)
+			}
+		}
+		if _, err := report.ParseReportFormats(strings.Join(schedule.Reports, ",")); err != nil {
+			return nil, fmt.Errorf("schedule %s: %v", schedule.Name, err)
+		}
+		result = append(result, ServerSchedule{Schedule: schedule, cron: cron})
+	}
+	return result, nil
+}
+
+// Schedule queues the scans of the schedules when they are due. A run missed
+// while the server was down is made up once at startup.
+func (s *ScanServer) Schedule(ctx context.Context) {
+	now := time.Now()
+	next := make(map[string]time.Time)
+	for _, schedule := range s.Schedules {
+		s.mu.Lock()
+		state, ok := s.scheduleStates[schedule.Name]
+		s.mu.Unlock()
+		if ok && !state.LastRun.IsZero() {
+			if due := schedule.cron.next(state.LastRun); !due.IsZero() && due.Before(now) {
+				runenv.Logger.Infof("Schedule %s missed its run at %s", schedule.Name, due.Format(time.RFC3339))
+				s.runSchedule(schedule, now)
+			}
+		}
+		next[schedule.Name] = schedule.cron.next(now)
+	}
+
+	for {
+		var wake time.Time
+		for _, t := range next {
+			if !t.IsZero() && (wake.IsZero() || t.Before(wake)) {
+				wake = t
+			}
+		}
+		if wake.IsZero() {
+			return
+		}
+
+		timer := time.NewTimer(time.Until(wake))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		for _, schedule := range s.Schedules {
+			if t := next[schedule.Name]; !t.IsZero() && !t.After(now) {
+				s.runSchedule(schedule, now)
+				next[schedule.Name] = schedule.cron.next(now)
+			}
+		}
+	}
+}
+
+// runSchedule queues a scan of the schedule and records it as its last run
+func (s *ScanServer) runSchedule(schedule ServerSchedule, now time.Time) {
+	id, err := newJobID()
+	if err != nil {
+		runenv.Logger.Warnf("Schedule %s: %v", schedule.Name, err)
+		return
+	}
+	job := &ServerJob{
+		ID:        id,
+		Status:    "queued",
+		Schedule:  schedule.Name,
+		Source:    "path",
+		Target:    schedule.File,
+		Scanners:  splitFormList(schedule.Scanner),
+		Reports:   schedule.Reports,
+		CreatedAt: now.UTC(),
+	}
+	if schedule.Git != "" {
+		job.Source, job.Target, job.Ref, job.Path = "git", schedule.Git, schedule.Ref, schedule.File
+	}
+	if err := os.MkdirAll(s.jobDir(id), 0755); err != nil {
+		runenv.Logger.Warnf("Schedule %s: failed to create directory: %v", schedule.Name, err)
+		return
+	}
+	if err := s.enqueue(job); err != nil {
+		os.RemoveAll(s.jobDir(id))
+		runenv.Logger.Warnf("Schedule %s: %v", schedule.Name, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scheduleStates[schedule.Name] = scheduleState{LastRun: now.UTC(), LastJob: id}
+	s.saveScheduleStates()
+}
+
+// LoadScheduleStates reads the last runs of the schedules
+func (s *ScanServer) LoadScheduleStates() error {
+	s.scheduleStates = make(map[string]scheduleState)
+	data, err := os.ReadFile(filepath.Join(s.DataDir, scheduleStateFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read schedule state: %v", err)
+	}
+	if err := json.Unmarshal(data, &s.scheduleStates); err != nil {
+		return fmt.Errorf("failed to parse schedule state: %v", err)
+	}
+	return nil
+}
+
+// saveScheduleStates writes the last runs of the schedules, the caller holds s.mu
+func (s *ScanServer) saveScheduleStates() {
+	data, err := json.MarshalIndent(s.scheduleStates, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(s.DataDir, scheduleStateFile), data, 0644)
+	}
+	if err != nil {
+		runenv.Logger.Warnf("Failed to save schedule state: %v", err)
+	}
+}
+
+func (s *ScanServer) listSchedules(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	schedules := make([]scheduleStatus, 0, len(s.Schedules))
+	for _, schedule := range s.Schedules {
+		status := scheduleStatus{Name: schedule.Name, Cron: schedule.Cron, Target: schedule.File}
+		if schedule.Git != "" {
+			status.Target = gitTarget(schedule.Git, schedule.Ref, schedule.File)
+		}
+		if next := schedule.cron.next(now); !next.IsZero() {
+			status.NextRun = &next
+		}
+		if state, ok := s.scheduleStates[schedule.Name]; ok {
+			status.LastRun, status.LastJob = &state.LastRun, state.LastJob
+			if job, ok := s.Jobs[state.LastJob]; ok {
+				status.LastStatus = job.Status
+			}
+		}
+		schedules = append(schedules, status)
+	}
+	writeJSON(w, http.StatusOK, schedules)
+}
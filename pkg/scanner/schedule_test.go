@@ -0,0 +1,71 @@
+package scanner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"10-5 * * * *",
+		"* * * foo *",
+		"@reboot",
+	}
+
+	for _, spec := range tests {
+		if _, err := parseCron(spec); err == nil {
+			t.Errorf("parseCron(%q) did not fail", spec)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2024, time.January, 10, 10, 30, 45, 0, time.UTC)
+
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 1, 10, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 10, 10, 45, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2024, 1, 10, 10, 45, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2024, 1, 10, 11, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 1, 10, 11, 0, 0, 0, time.UTC)},
+		{"30 10 * * *", time.Date(2024, 1, 11, 10, 30, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2024, 1, 10, 13, 0, 0, 0, time.UTC)},
+		{"0 2,22 * * *", time.Date(2024, 1, 10, 22, 0, 0, 0, time.UTC)},
+		{"0 0 * * mon-fri", time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * SAT", time.Date(2024, 1, 13, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2024, 1, 14, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either one matches
+		{"0 0 15 * fri", time.Date(2024, 1, 12, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			c, err := parseCron(tt.spec)
+			if err != nil {
+				t.Fatalf("parseCron() error = %v", err)
+			}
+			if got := c.next(from); !got.Equal(tt.want) {
+				t.Errorf("next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
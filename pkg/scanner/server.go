@@ -0,0 +1,472 @@
+package scanner
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/report"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// Environment variable holding the bearer token of the API, which is open
+// when it is not set
+const ServerTokenEnv = "SBOM_SCANNER_API_TOKEN"
+
+// Largest accepted upload
+const maxUploadSize = 50 << 20
+
+// Number of scans waiting for a worker before submissions are refused
+const ServerQueueSize = 100
+
+// ServerJob is a scan submitted to the API. It is stored as job.json in its
+// directory so that the results survive a restart.
+type ServerJob struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"` // queued, running, passed, failed or error
+	Source     string     `json:"source"` // upload, git or path (a project on the server, only for schedules)
+	Target     string     `json:"target"` // uploaded file name, Git URL or project path
+	Ref        string     `json:"ref,omitempty"`
+	Path       string     `json:"path,omitempty"`     // project inside the Git repository
+	Schedule   string     `json:"schedule,omitempty"` // name of the schedule that started the scan
+	Scanners   []string   `json:"scanners,omitempty"`
+	Reports    []string   `json:"reports,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	ExitCode   *int       `json:"exit_code,omitempty"` // of the equivalent command, see ExitCode
+	Error      string     `json:"error,omitempty"`
+	Summary    *Summary   `json:"summary,omitempty"`
+	Files      []string   `json:"files,omitempty"` // results, downloadable below /files/
+}
+
+// scanRequest is the JSON body of a Git repository scan
+type scanRequest struct {
+	Git      string   `json:"git"`
+	Ref      string   `json:"ref"`
+	Path     string   `json:"path"`
+	Scanners []string `json:"scanners"`
+	Reports  []string `json:"reports"`
+}
+
+// ScanServer runs the submitted scans one after another per worker
+type ScanServer struct {
+	DataDir string
+	Base    Options
+	Token   string
+	Queue   chan string
+
+	Schedules []ServerSchedule
+
+	mu             sync.Mutex
+	Jobs           map[string]*ServerJob
+	scheduleStates map[string]scheduleState // by schedule name
+}
+
+// LoopbackAddress reports whether a listen address only accepts connections
+// from the local host. An empty host listens on all interfaces.
+func LoopbackAddress(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *ScanServer) Routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("POST /api/v1/scans", s.submit)
+	mux.HandleFunc("GET /api/v1/scans", s.list)
+	mux.HandleFunc("GET /api/v1/scans/{id}", s.get)
+	mux.HandleFunc("GET /api/v1/scans/{id}/files/{path...}", s.download)
+	mux.HandleFunc("GET /api/v1/schedules", s.listSchedules)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Token != "" && r.URL.Path != "/healthz" {
+			given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(s.Token)) != 1 {
+				writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+				return
+			}
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// submit accepts a multipart upload of a project file or SBOM (field file),
+// or a JSON body with the URL of a Git repository
+func (s *ScanServer) submit(w http.ResponseWriter, r *http.Request) {
+	id, err := newJobID()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	job := &ServerJob{ID: id, Status: "queued", CreatedAt: time.Now().UTC()}
+	dir := s.jobDir(id)
+	if err := os.MkdirAll(filepath.Join(dir, "input"), 0755); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("failed to create directory: %v", err))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		var req scanRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.reject(w, dir, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+			return
+		}
+		if err := validateGitURL(req.Git); err != nil {
+			s.reject(w, dir, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err := validateGitRef(req.Ref); err != nil {
+			s.reject(w, dir, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.Path != "" && !filepath.IsLocal(req.Path) {
+			s.reject(w, dir, http.StatusBadRequest, fmt.Sprintf("invalid path in the repository: %s", req.Path))
+			return
+		}
+		job.Source, job.Target, job.Ref, job.Path = "git", req.Git, req.Ref, req.Path
+		job.Scanners, job.Reports = req.Scanners, req.Reports
+	} else {
+		name, err := saveUpload(r, filepath.Join(dir, "input"))
+		if err != nil {
+			s.reject(w, dir, http.StatusBadRequest, err.Error())
+			return
+		}
+		job.Source, job.Target = "upload", name
+		job.Scanners = splitFormList(r.FormValue("scanner"))
+		job.Reports = splitFormList(r.FormValue("report"))
+	}
+
+	// The options are checked before the job is queued
+	o := s.jobOptions(job)
+	if _, err := report.ParseReportFormats(strings.Join(o.Reports, ",")); err != nil {
+		s.reject(w, dir, http.StatusBadRequest, err.Error())
+		return
+	}
+	settings, err := o.settings()
+	if err != nil {
+		s.reject(w, dir, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := scan.ParseScanners(settings, strings.Join(o.Scanners, ",")); err != nil {
+		s.reject(w, dir, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := s.enqueue(job); err != nil {
+		s.reject(w, dir, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/scans/"+id)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// enqueue stores the job and hands it to the workers
+func (s *ScanServer) enqueue(job *ServerJob) error {
+	s.mu.Lock()
+	s.Jobs[job.ID] = job
+	s.saveJob(job)
+	s.mu.Unlock()
+
+	select {
+	case s.Queue <- job.ID:
+	default:
+		s.mu.Lock()
+		delete(s.Jobs, job.ID)
+		s.mu.Unlock()
+		return fmt.Errorf("too many scans queued, try again later")
+	}
+	runenv.Logger.Infof("Queued scan %s of %s", job.ID, job.Target)
+	return nil
+}
+
+// reject removes the directory of a refused submission
+func (s *ScanServer) reject(w http.ResponseWriter, dir string, status int, message string) {
+	os.RemoveAll(dir)
+	writeError(w, status, message)
+}
+
+func (s *ScanServer) list(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]*ServerJob, 0, len(s.Jobs))
+	for _, job := range s.Jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.After(jobs[j].CreatedAt) })
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+func (s *ScanServer) get(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.Jobs[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "scan not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// download serves a result file of a finished scan
+func (s *ScanServer) download(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	s.mu.Lock()
+	job, ok := s.Jobs[id]
+	finished := ok && job.FinishedAt != nil
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "scan not found")
+		return
+	}
+	if !finished {
+		writeError(w, http.StatusConflict, "scan has not finished")
+		return
+	}
+
+	results := filepath.Join(s.jobDir(id), "results")
+	path := filepath.Join(results, filepath.FromSlash(r.PathValue("path")))
+	if !strings.HasPrefix(path, results+string(filepath.Separator)) {
+		writeError(w, http.StatusBadRequest, "invalid path")
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "file not found")
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		writeError(w, http.StatusNotFound, "file not found")
+		return
+	}
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
+
+// Work runs queued scans until ctx is canceled
+func (s *ScanServer) Work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-s.Queue:
+			s.runJob(ctx, id)
+		}
+	}
+}
+
+func (s *ScanServer) runJob(ctx context.Context, id string) {
+	s.mu.Lock()
+	job := s.Jobs[id]
+	now := time.Now().UTC()
+	job.Status, job.StartedAt = "running", &now
+	s.saveJob(job)
+	o := s.jobOptions(job)
+	s.mu.Unlock()
+
+	runenv.Logger.Infof("Scanning %s (%s)", job.Target, id)
+	result, err := Run(ctx, o)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	finished := time.Now().UTC()
+	job.FinishedAt = &finished
+	code := runenv.ExitCode(err)
+	job.ExitCode = &code
+	job.Status = "error"
+	if result != nil {
+		job.Status = result.Status
+		summary := result.Summary()
+		summary.OutputDir = ""
+		if job.Source == "upload" {
+			summary.Target = job.Target
+		}
+		job.Summary = &summary
+	}
+	if err != nil {
+		job.Error = err.Error()
+	}
+	job.Files = resultFiles(o.OutputDir)
+	s.saveJob(job)
+	runenv.Logger.Infof("Scan %s finished: %s", id, job.Status)
+}
+
+// jobOptions applies the scanners and reports of the request to the options
+// of the config file. Every scan writes into its own results directory.
+func (s *ScanServer) jobOptions(job *ServerJob) Options {
+	o := s.Base
+	dir := s.jobDir(job.ID)
+	switch job.Source {
+	case "git":
+		o.Target, o.GitURL, o.GitRef = job.Path, job.Target, job.Ref
+	case "path":
+		o.Target = job.Target
+	default:
+		o.Target = filepath.Join(dir, "input", job.Target)
+	}
+	o.OutputDir = filepath.Join(dir, "results")
+	o.Clean = true
+	o.KeepLast = 0
+	o.Quiet = true
+	o.CommentPR = ""
+	if o.HistoryDB == "" {
+		o.HistoryDB = filepath.Join(s.DataDir, HistoryFileName)
+	}
+	if len(job.Scanners) > 0 {
+		o.Scanners = job.Scanners
+	}
+	if len(job.Reports) > 0 {
+		o.Reports = job.Reports
+	}
+	return o
+}
+
+func (s *ScanServer) jobDir(id string) string {
+	return filepath.Join(s.DataDir, "jobs", id)
+}
+
+// saveJob writes job.json, the caller holds s.mu
+func (s *ScanServer) saveJob(job *ServerJob) {
+	data, err := json.MarshalIndent(job, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(s.jobDir(job.ID), "job.json"), data, 0644)
+	}
+	if err != nil {
+		runenv.Logger.Warnf("Failed to save scan %s: %v", job.ID, err)
+	}
+}
+
+// LoadJobs reads the scans of an earlier run of the server. Scans that had
+// not finished are marked as interrupted.
+func (s *ScanServer) LoadJobs() error {
+	entries, err := os.ReadDir(filepath.Join(s.DataDir, "jobs"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read data directory: %v", err)
+	}
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(s.jobDir(entry.Name()), "job.json"))
+		if err != nil {
+			continue
+		}
+		var job ServerJob
+		if err := json.Unmarshal(data, &job); err != nil || job.ID != entry.Name() {
+			continue
+		}
+		if job.FinishedAt == nil {
+			now := time.Now().UTC()
+			job.Status, job.Error, job.FinishedAt = "error", "interrupted by a restart of the server", &now
+			s.saveJob(&job)
+		}
+		s.Jobs[job.ID] = &job
+	}
+	if len(s.Jobs) > 0 {
+		runenv.Logger.Infof("Loaded %d scans from %s", len(s.Jobs), s.DataDir)
+	}
+	return nil
+}
+
+// saveUpload stores the file field of a multipart request in dir under its
+// base name, which decides how it is scanned (pom.xml, bom.json, ...)
+func saveUpload(r *http.Request, dir string) (string, error) {
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return "", fmt.Errorf("expected a multipart upload with a file field or a JSON body with git: %v", err)
+	}
+	defer file.Close()
+
+	name := filepath.Base(filepath.Clean("/" + strings.ReplaceAll(header.Filename, "\\", "/")))
+	if name == "/" || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid file name: %s", header.Filename)
+	}
+	if _, err := sbom.DetectProject(name); err != nil && !sbom.IsArchive(name) {
+		return "", err
+	}
+
+	out, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return "", fmt.Errorf("failed to store upload: %v", err)
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, file); err != nil {
+		return "", fmt.Errorf("failed to store upload: %v", err)
+	}
+	return name, nil
+}
+
+// resultFiles lists the files below dir as slash-separated relative paths
+func resultFiles(dir string) []string {
+	var files []string
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || d.Name() == sbom.OutputMarker {
+			return nil
+		}
+		if rel, err := filepath.Rel(dir, path); err == nil {
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return files
+}
+
+func splitFormList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate scan ID: %v", err)
+	}
+	return time.Now().UTC().Format("20060102") + "-" + hex.EncodeToString(b), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
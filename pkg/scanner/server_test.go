@@ -0,0 +1,40 @@
+package scanner
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRoutesToken(t *testing.T) {
+	s := &ScanServer{Token: "secret", Jobs: make(map[string]*ServerJob)}
+	routes := s.Routes()
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		want          int
+	}{
+		{"bearer token", "/api/v1/scans", "Bearer secret", http.StatusOK},
+		{"no header", "/api/v1/scans", "", http.StatusUnauthorized},
+		{"wrong token", "/api/v1/scans", "Bearer wrong", http.StatusUnauthorized},
+		{"token without scheme", "/api/v1/scans", "secret", http.StatusUnauthorized},
+		{"other scheme", "/api/v1/scans", "Basic secret", http.StatusUnauthorized},
+		{"health check", "/healthz", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			routes.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("GET %s with %q = %d, want %d", tt.path, tt.authorization, w.Code, tt.want)
+			}
+		})
+	}
+}
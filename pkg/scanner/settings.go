@@ -0,0 +1,103 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/maven"
+)
+
+// Context validates the options and returns ctx carrying their settings, for
+// the stages run on their own instead of through Run
+func (o Options) Context(ctx context.Context) (context.Context, error) {
+	s, err := o.settings()
+	if err != nil {
+		return ctx, err
+	}
+	return runenv.WithSettings(ctx, s), nil
+}
+
+// settings validates the options and returns the settings of their run
+func (o Options) settings() (*runenv.Settings, error) {
+	s := runenv.NewSettings()
+	s.Tools = configuredTools(o.Tools)
+	s.Offline = o.Offline
+	s.OSVDatabaseDir = o.DBDir
+	s.TrivyCacheDir = o.TrivyCacheDir
+	s.MavenRepoLocal = o.MavenRepoLocal
+	s.MavenOffline = o.MavenOffline
+	s.MavenSettings = o.MavenSettings
+	s.MavenProfiles = o.MavenProfiles
+	s.MavenExtraArgs = o.MavenArgs
+	s.MavenWorkspaceDir = o.Workspace
+	s.KeepTemp = o.KeepTemp
+	if o.Workdir != "" {
+		dir, err := filepath.Abs(o.Workdir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get absolute path: %v", err)
+		}
+		s.WorkDir = dir
+		// An offline Trivy needs the DB prepared in its own cache
+		if s.TrivyCacheDir == "" && !s.Offline {
+			s.TrivyCacheDir = s.CacheDir("trivy")
+		}
+	}
+	if o.WorkdirMaxSize != "" {
+		if s.WorkDir == "" {
+			return nil, fmt.Errorf("--workdir-max-size needs --workdir")
+		}
+		size, err := runenv.ParseByteSize(o.WorkdirMaxSize)
+		if err != nil {
+			return nil, err
+		}
+		s.WorkDirMaxSize = size
+	}
+	if o.CycloneDXPluginVersion != "" {
+		if !maven.ValidCycloneDXPluginVersion(o.CycloneDXPluginVersion) {
+			return nil, fmt.Errorf("invalid CycloneDX plugin version: %s (expected a version such as %s or latest)", o.CycloneDXPluginVersion, maven.DefaultCycloneDXPluginVersion)
+		}
+		s.CycloneDXPluginVersion = o.CycloneDXPluginVersion
+	}
+	s.MavenWrapper = o.Tools.Maven == ""
+	if len(o.MavenRepositories) > 0 {
+		if o.MavenSettings != "" {
+			return nil, fmt.Errorf("--maven-repository and --maven-mirror cannot be combined with --maven-settings")
+		}
+		path, err := maven.WriteSettings(s, o.MavenRepositories)
+		if err != nil {
+			return nil, err
+		}
+		s.MavenSettings = path
+	}
+	if s.MavenSettings != "" {
+		if _, err := maven.CentralRepository(s); err != nil {
+			return nil, err
+		}
+	}
+	s.Quiet = o.Quiet
+	s.StageTimeout = o.StageTimeout
+
+	if o.Retries != nil {
+		s.RetryCount = max(*o.Retries, 0)
+	}
+	if o.RetryBackoff > 0 {
+		s.RetryBackoff = o.RetryBackoff
+	}
+
+	switch {
+	case o.CacheTTL < 0:
+		s.ResultCacheTTL = 0
+	case o.CacheTTL > 0:
+		s.ResultCacheTTL = o.CacheTTL
+	}
+
+	if o.Parallelism < 0 {
+		return nil, fmt.Errorf("invalid parallelism: %d (expected at least 1)", o.Parallelism)
+	}
+	if o.Parallelism > 0 {
+		s.Slots = make(chan struct{}, o.Parallelism)
+	}
+	return s, nil
+}
@@ -0,0 +1,159 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+)
+
+// Files written next to a signed SBOM: the signature, the Sigstore bundle
+// with the certificate and the transparency log entry, and the certificate
+// of keyless signatures
+const (
+	signatureSuffix   = ".sig"
+	bundleSuffix      = ".bundle"
+	certificateSuffix = ".pem"
+)
+
+// signOptions configures the signing of the SBOMs with cosign
+type signOptions struct {
+	Enabled bool
+	key     string // private key file or KMS URI, keyless through Fulcio and Rekor when empty
+}
+
+// CosignInstalled fails with the missing tool error when cosign is not found
+func CosignInstalled(ctx context.Context) error {
+	if _, err := exec.LookPath(runenv.SettingsOf(ctx).ToolPath("cosign")); err != nil {
+		return runenv.MissingTool("cosign is not installed, it is needed to sign and verify SBOMs (https://docs.sigstore.dev/cosign/system_config/installation/)")
+	}
+	return nil
+}
+
+// signSBOM signs the SBOM with cosign sign-blob and writes the signature and
+// the bundle next to it. Keyless signing gets the certificate from Fulcio
+// with the OIDC token of the CI job, or through the browser locally. The
+// password of an encrypted key is read by cosign from COSIGN_PASSWORD.
+func signSBOM(ctx context.Context, sbomPath string, opts signOptions) error {
+	args := []string{"sign-blob", "--yes",
+		"--output-signature", sbomPath + signatureSuffix,
+		"--bundle", sbomPath + bundleSuffix,
+	}
+	if opts.key != "" {
+		args = append(args, "--key", opts.key)
+		if runenv.SettingsOf(ctx).Offline {
+			// Rekor cannot be reached, the signature is verified with the key alone
+			args = append(args, "--tlog-upload=false")
+		}
+	} else {
+		args = append(args, "--output-certificate", sbomPath+certificateSuffix)
+	}
+	args = append(args, sbomPath)
+
+	cmd := runenv.Command(ctx, runenv.SettingsOf(ctx).ToolPath("cosign"), args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to sign %s: %v\n%s", sbomPath, err, strings.TrimSpace(string(output)))
+	}
+	runenv.Logger.Infof("Signed %s", sbomPath)
+	return nil
+}
+
+// SignOutputs signs the SBOM and its attestations
+func SignOutputs(ctx context.Context, sbomPath string, opts ScanOptions) error {
+	paths := []string{sbomPath}
+	if opts.Attest.Format != "" {
+		paths = append(paths, attestationFiles(sbomPath)...)
+	}
+	for _, path := range paths {
+		if err := signSBOM(ctx, path, opts.Sign); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyOptions are the identities a signature is accepted from
+type VerifyOptions struct {
+	Key            string
+	Identity       string
+	IdentityRegexp string
+	Issuer         string
+	IssuerRegexp   string
+	IgnoreTlog     bool
+}
+
+// VerifySBOM checks the signature of the SBOM with cosign verify-blob
+func VerifySBOM(ctx context.Context, sbomPath string, opts VerifyOptions) error {
+	bundle := sbomPath + bundleSuffix
+	if _, err := os.Stat(bundle); err != nil {
+		return fmt.Errorf("%s is not signed: %s not found", sbomPath, bundle)
+	}
+
+	args := []string{"verify-blob", "--bundle", bundle}
+	if opts.Key != "" {
+		args = append(args, "--key", opts.Key)
+	}
+	for _, option := range [][2]string{
+		{"--certificate-identity", opts.Identity},
+		{"--certificate-identity-regexp", opts.IdentityRegexp},
+		{"--certificate-oidc-issuer", opts.Issuer},
+		{"--certificate-oidc-issuer-regexp", opts.IssuerRegexp},
+	} {
+		if option[1] != "" {
+			args = append(args, option[0], option[1])
+		}
+	}
+	if opts.IgnoreTlog {
+		args = append(args, "--insecure-ignore-tlog=true")
+	}
+	args = append(args, sbomPath)
+
+	cmd := runenv.Command(ctx, runenv.SettingsOf(ctx).ToolPath("cosign"), args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to verify %s: %v\n%s", sbomPath, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// SignedSBOMs returns the SBOMs to verify: the given files, and the module
+// SBOMs of a scan output directory with their attestations
+func SignedSBOMs(paths []string) ([]string, error) {
+	var sboms []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			sboms = append(sboms, path)
+			continue
+		}
+		found := 0
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			switch {
+			case d.IsDir():
+			case d.Name() == "sbom.xml":
+				sboms = append(sboms, p)
+				found++
+			case d.Name() == provenanceFileName, strings.HasSuffix(d.Name(), sbomAttestationSuffix):
+				sboms = append(sboms, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to search %s: %v", path, err)
+		}
+		if found == 0 {
+			return nil, fmt.Errorf("no SBOM found in %s", path)
+		}
+	}
+	return sboms, nil
+}
@@ -0,0 +1,204 @@
+package scanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/report"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// Number of packages listed as the most affected in the summary
+const topPackageCount = 10
+
+// Summary holds the counts of a run, printed at the end of the scan and
+// written to summary.json
+type Summary struct {
+	Target             string           `json:"target"`
+	Commit             string           `json:"commit,omitempty"`      // Git commit checked out in the target
+	Uncommitted        []string         `json:"uncommitted,omitempty"` // files with changes not committed
+	OutputDir          string           `json:"output_dir"`
+	Status             string           `json:"status"` // passed or failed
+	Error              string           `json:"error,omitempty"`
+	Components         int              `json:"components"` // in the SBOMs of all modules
+	VulnerablePackages int              `json:"vulnerable_packages"`
+	Vulnerabilities    int              `json:"vulnerabilities"`
+	Suppressed         int              `json:"suppressed"`
+	Severities         map[string]int   `json:"severities"`
+	RiskScore          float64          `json:"risk_score"`   // severities weighted by EPSS, KEV and reachability
+	TopPackages        []PackageSummary `json:"top_packages"` // most vulnerable packages first
+	Retries            int              `json:"retries"`      // retried network operations
+	Modules            []ModuleSummary  `json:"modules"`
+	Timings            *Timings         `json:"timings,omitempty"` // with --timings
+}
+
+// PackageSummary counts the active findings of a package version
+type PackageSummary struct {
+	Package         string `json:"package"`
+	Version         string `json:"version"`
+	Vulnerabilities int    `json:"vulnerabilities"`
+	Severity        string `json:"severity"` // highest of its findings
+}
+
+// Summary returns the counts of the run
+func (r *Result) Summary() Summary {
+	summary := r.counts
+	summary.Target = r.Target
+	summary.Commit = r.Commit
+	summary.Uncommitted = r.Uncommitted
+	summary.OutputDir = r.OutputDir
+	summary.Status = r.Status
+	summary.Error = r.Error
+	summary.Modules = r.Modules
+	summary.Retries = len(r.Retries)
+	summary.Timings = r.Timings
+	for _, m := range r.Modules {
+		summary.Components += m.Components
+	}
+	return summary
+}
+
+// countFindings counts the active and suppressed findings of a run as they
+// are read, into the finding counts of its summary
+func countFindings(findings scan.FindingSource) (Summary, error) {
+	summary := Summary{Severities: make(map[string]int)}
+	for _, s := range scan.SeverityOrder {
+		summary.Severities[s] = 0
+	}
+
+	packages := make(map[string]*PackageSummary)
+	err := findings(func(f scan.Finding) error {
+		if f.SuppressedBy != "" {
+			summary.Suppressed++
+			return nil
+		}
+		summary.Vulnerabilities++
+		summary.Severities[f.Severity]++
+		key := f.Package + "@" + f.Version
+		p, ok := packages[key]
+		if !ok {
+			p = &PackageSummary{Package: f.Package, Version: f.Version, Severity: f.Severity}
+			packages[key] = p
+		}
+		p.Vulnerabilities++
+		if scan.SeverityRank(f.Severity) > scan.SeverityRank(p.Severity) {
+			p.Severity = f.Severity
+		}
+		return nil
+	})
+	if err != nil {
+		return summary, err
+	}
+	if summary.RiskScore, err = report.RiskScore(findings); err != nil {
+		return summary, err
+	}
+	summary.VulnerablePackages = len(packages)
+
+	summary.TopPackages = []PackageSummary{}
+	for _, p := range packages {
+		summary.TopPackages = append(summary.TopPackages, *p)
+	}
+	sort.Slice(summary.TopPackages, func(i, j int) bool {
+		a, b := summary.TopPackages[i], summary.TopPackages[j]
+		if a.Vulnerabilities != b.Vulnerabilities {
+			return a.Vulnerabilities > b.Vulnerabilities
+		}
+		if scan.SeverityRank(a.Severity) != scan.SeverityRank(b.Severity) {
+			return scan.SeverityRank(a.Severity) > scan.SeverityRank(b.Severity)
+		}
+		return a.Package+"@"+a.Version < b.Package+"@"+b.Version
+	})
+	if len(summary.TopPackages) > topPackageCount {
+		summary.TopPackages = summary.TopPackages[:topPackageCount]
+	}
+	return summary, nil
+}
+
+// writeSummaryFile writes the summary of a run to summary.json in its directory
+func writeSummaryFile(dir string, r *Result) error {
+	data, err := json.MarshalIndent(r.Summary(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode summary: %v", err)
+	}
+	path := filepath.Join(dir, "summary.json")
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write summary: %v", err)
+	}
+	runenv.Logger.Infof("Summary written to %s", path)
+	return nil
+}
+
+// WriteSummary writes the summary of a run as text or json
+func WriteSummary(w io.Writer, r *Result, format string) error {
+	summary := r.Summary()
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(summary)
+	case "", "text":
+	default:
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+
+	fmt.Fprintf(w, "Target: %s\n", summary.Target)
+	fmt.Fprintf(w, "Output: %s\n", summary.OutputDir)
+	fmt.Fprintf(w, "Status: %s\n", summary.Status)
+	if summary.Error != "" {
+		fmt.Fprintf(w, "Error: %s\n", summary.Error)
+	}
+	fmt.Fprintf(w, "Components: %d\n", summary.Components)
+	fmt.Fprintf(w, "Vulnerabilities: %d in %d packages", summary.Vulnerabilities, summary.VulnerablePackages)
+	if summary.Suppressed > 0 {
+		fmt.Fprintf(w, ", %d suppressed", summary.Suppressed)
+	}
+	fmt.Fprintln(w)
+	for _, s := range scan.SeverityOrder {
+		fmt.Fprintf(w, "  %-8s %d\n", s, summary.Severities[s])
+	}
+	fmt.Fprintf(w, "Risk score: %.1f\n", summary.RiskScore)
+	if len(summary.TopPackages) > 0 {
+		fmt.Fprintln(w, "Top affected packages:")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, p := range summary.TopPackages {
+			fmt.Fprintf(tw, "  %s@%s\t%d\t%s\n", p.Package, p.Version, p.Vulnerabilities, p.Severity)
+		}
+		tw.Flush()
+	}
+	if summary.Retries > 0 {
+		fmt.Fprintf(w, "Retries: %d\n", summary.Retries)
+	}
+
+	if len(summary.Modules) > 1 {
+		fmt.Fprintln(w)
+		writeModuleTable(w, summary.Modules)
+	}
+	if summary.Timings != nil {
+		fmt.Fprintln(w)
+		runenv.WriteTimingsTable(w, summary.Timings)
+	}
+	return nil
+}
+
+// writeModuleTable writes a table of the findings of every module
+func writeModuleTable(w io.Writer, summaries []ModuleSummary) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODULE\tBUILD TOOL\tVULNERABLE PACKAGES\tVULNERABILITIES\tSTATUS")
+	for _, s := range summaries {
+		status := "ok"
+		switch {
+		case s.Error != "":
+			status = "failed"
+		case s.ReusedFrom != "":
+			status = "reused"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\n", s.Path, s.BuildTool, s.VulnerablePackages, s.Vulnerabilities, status)
+	}
+	tw.Flush()
+}
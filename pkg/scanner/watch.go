@@ -0,0 +1,277 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/report"
+	"github.com/xshuden/sbom-scanner/pkg/sbom"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// How often the watched files are checked for changes
+const watchInterval = time.Second
+
+// Files read together with a project file, e.g. go.sum for the checksums of
+// go.mod, whose changes trigger a new scan too
+var watchCompanions = map[string][]string{
+	"go.mod":           {"go.sum"},
+	"build.gradle":     {"settings.gradle", "gradle.lockfile"},
+	"build.gradle.kts": {"settings.gradle.kts", "gradle.lockfile"},
+	"package.json":     {"package-lock.json", "npm-shrinkwrap.json", "yarn.lock", "pnpm-lock.yaml"},
+}
+
+// watchDelta lists what changed between two runs of the watch mode
+type watchDelta struct {
+	Time       time.Time            `json:"time"`
+	Changed    []string             `json:"changed"` // files that triggered the run
+	Status     string               `json:"status"`
+	Error      string               `json:"error,omitempty"`
+	Added      []sbom.Package       `json:"added"`
+	Removed    []sbom.Package       `json:"removed"`
+	Upgraded   []sbom.VersionChange `json:"upgraded"`
+	Downgraded []sbom.VersionChange `json:"downgraded"`
+	New        []scan.Finding       `json:"new"`
+	Fixed      []scan.Finding       `json:"fixed"`
+	Components int                  `json:"components"`
+	Vulns      int                  `json:"vulnerabilities"`
+}
+
+// watchedFile is the state a change is detected by
+type watchedFile struct {
+	modTime time.Time
+	size    int64
+}
+
+// Watch scans the target like Run and scans it again whenever one of its
+// project files changes, e.g. when a dependency is added to the POM. After
+// the first scan, whose summary is printed in full, only the difference to
+// the previous scan is written to w: added, removed and upgraded components
+// and new and fixed vulnerabilities. Watch returns when ctx is canceled.
+//
+// The scans are not recorded in the history and send no notifications. Only
+// the latest run directory is kept unless KeepLast is set.
+func Watch(ctx context.Context, o Options, w io.Writer, format string) error {
+	if o.GitURL != "" {
+		return fmt.Errorf("watch mode needs a local project, not a Git repository")
+	}
+	if format != "" && format != "text" && format != "json" {
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+	o.Quiet = true
+	o.NoHistory = true
+	o.WebhookURL, o.CommentPR = "", ""
+	o.DefectDojo, o.Email = DefectDojo{}, EmailConfig{}
+	if o.KeepLast == 0 {
+		o.KeepLast = 1
+	}
+
+	files, err := watchFiles(o.Target, o.OutputDir)
+	if err != nil {
+		return err
+	}
+
+	// The first scan is the reference of the first delta
+	result, err := Run(ctx, o)
+	if ctx.Err() != nil {
+		return nil
+	}
+	if result == nil {
+		return err
+	}
+	if err := WriteSummary(w, result, format); err != nil {
+		return err
+	}
+	state := readWatchState(result)
+	runenv.Logger.Infof("Watching %d files for changes, press Ctrl+C to stop", len(files))
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		current, err := watchFiles(o.Target, o.OutputDir)
+		if err != nil {
+			// A file being rewritten may be missing for a moment
+			continue
+		}
+		changed := changedFiles(files, current)
+		if len(changed) == 0 {
+			continue
+		}
+		files = current
+
+		next, err := Run(ctx, o)
+		if ctx.Err() != nil {
+			return nil
+		}
+		nextState := readWatchState(next)
+		delta := diffRuns(state, next, nextState, err)
+		delta.Changed = changed
+		if err := writeWatchDelta(w, delta, format); err != nil {
+			return err
+		}
+		// A broken build keeps the last good scan as the reference
+		if next != nil {
+			state = nextState
+		}
+		// Files changed during the scan are picked up by the next check
+		if latest, err := watchFiles(o.Target, o.OutputDir); err == nil && len(changedFiles(files, latest)) == 0 {
+			files = latest
+		}
+	}
+}
+
+// watchFiles returns the state of the project files of the target and their
+// companions. Directories are searched again each time, so new modules are
+// picked up.
+func watchFiles(target, outputDir string) (map[string]watchedFile, error) {
+	projects, err := sbom.FindProjects(target, outputDir)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]watchedFile)
+	for _, p := range projects {
+		paths := []string{p.File}
+		for _, name := range watchCompanions[filepath.Base(p.File)] {
+			paths = append(paths, filepath.Join(filepath.Dir(p.File), name))
+		}
+		for _, path := range paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			files[path] = watchedFile{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+	return files, nil
+}
+
+// changedFiles lists the files added, removed or modified since before
+func changedFiles(before, after map[string]watchedFile) []string {
+	var changed []string
+	for path, state := range after {
+		if old, ok := before[path]; !ok || old != state {
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// watchState is what the watch compares of two runs: the components of all
+// module SBOMs and the active findings. It is read before the next scan
+// prunes the run directory.
+type watchState struct {
+	packages []sbom.Package
+	findings []scan.Finding
+}
+
+// readWatchState reads the components and the active findings of a run
+func readWatchState(r *Result) watchState {
+	var state watchState
+	if r == nil {
+		return state
+	}
+	for _, m := range r.Modules {
+		found, err := sbom.ReadPackages(filepath.Join(m.OutputDir, "sbom.xml"))
+		if err != nil {
+			continue
+		}
+		state.packages = append(state.packages, found...)
+	}
+	if findings, err := scan.FindingSource(r.EachFinding).Where(scan.ActiveFinding).Collect(); err == nil {
+		state.findings = findings
+	}
+	return state
+}
+
+// diffRuns compares the components and the active findings of two runs.
+// Without a new result, e.g. when the build failed, only the error is set.
+func diffRuns(prev watchState, next *Result, nextState watchState, scanErr error) watchDelta {
+	delta := watchDelta{Time: time.Now(), Status: "error"}
+	if scanErr != nil {
+		delta.Error = scanErr.Error()
+	}
+	if next == nil {
+		return delta
+	}
+	delta.Status = next.Status
+
+	components := sbom.DiffSBOMs(prev.packages, nextState.packages)
+	delta.Added, delta.Removed = components.Added, components.Removed
+	delta.Upgraded, delta.Downgraded = components.Upgraded, components.Downgraded
+	delta.Components = len(nextState.packages)
+
+	findings, _ := report.DiffFindings(prev.findings, scan.SliceFindings(nextState.findings))
+	delta.New, delta.Fixed = findings.New, findings.Fixed
+	delta.Vulns = len(nextState.findings)
+	return delta
+}
+
+// writeWatchDelta prints the changes of a run, as one JSON object per run
+// in json format
+func writeWatchDelta(w io.Writer, delta watchDelta, format string) error {
+	if format == "json" {
+		return json.NewEncoder(w).Encode(delta)
+	}
+
+	names := make([]string, len(delta.Changed))
+	for i, path := range delta.Changed {
+		names[i] = filepath.Base(path)
+	}
+	fmt.Fprintf(w, "\n[%s] %s changed, scanned again: %s\n", delta.Time.Format("15:04:05"), strings.Join(names, ", "), delta.Status)
+	if delta.Error != "" {
+		fmt.Fprintf(w, "Error: %s\n", delta.Error)
+	}
+	if delta.Status == "error" {
+		return nil
+	}
+
+	changes := len(delta.Added) + len(delta.Removed) + len(delta.Upgraded) + len(delta.Downgraded) + len(delta.New) + len(delta.Fixed)
+	if changes == 0 {
+		fmt.Fprintf(w, "No dependency or vulnerability changes (%d components, %d vulnerabilities)\n", delta.Components, delta.Vulns)
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, p := range delta.Added {
+		fmt.Fprintf(tw, "added\t%s\t%s\n", p.Name, p.Version)
+	}
+	for _, p := range delta.Removed {
+		fmt.Fprintf(tw, "removed\t%s\t%s\n", p.Name, p.Version)
+	}
+	for _, c := range delta.Upgraded {
+		fmt.Fprintf(tw, "upgraded\t%s\t%s -> %s\n", c.Name, c.From, c.To)
+	}
+	for _, c := range delta.Downgraded {
+		fmt.Fprintf(tw, "downgraded\t%s\t%s -> %s\n", c.Name, c.From, c.To)
+	}
+	for _, f := range delta.New {
+		fmt.Fprintf(tw, "new\t%s %s\t%s@%s\n", f.Severity, f.ID, f.Package, f.Version)
+	}
+	for _, f := range delta.Fixed {
+		fmt.Fprintf(tw, "fixed\t%s %s\t%s@%s\n", f.Severity, f.ID, f.Package, f.Version)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "%d components, %d vulnerabilities\n", delta.Components, delta.Vulns)
+	return nil
+}
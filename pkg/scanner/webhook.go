@@ -0,0 +1,141 @@
+package scanner
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/xshuden/sbom-scanner/internal/runenv"
+	"github.com/xshuden/sbom-scanner/pkg/scan"
+)
+
+// Environment variable holding the optional webhook signing secret
+const webhookSecretEnv = "SBOM_SCANNER_WEBHOOK_SECRET"
+
+// Header carrying the HMAC-SHA256 signature of the request body
+const webhookSignatureHeader = "X-SBOM-Scanner-Signature"
+
+// validateWebhookURL accepts absolute http(s) URLs and warns about plain HTTP
+func validateWebhookURL(value string) error {
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return fmt.Errorf("invalid webhook URL: %s", value)
+	}
+	if u.Scheme == "http" {
+		runenv.Logger.Warnf("Webhook %s does not use HTTPS, results are sent unencrypted", u.Host)
+	}
+	return nil
+}
+
+// sendWebhook posts the run results as JSON. With a secret, the body is signed
+// with HMAC-SHA256 and the hex digest sent as "sha256=<digest>". The findings
+// are encoded into the body as they are read, twice: first to sign the body
+// and to measure its length, then while it is sent.
+func sendWebhook(webhookURL, secret string, payload Result) error {
+	head, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %v", err)
+	}
+	write := func(w io.Writer) error {
+		// The findings are added as the last field of the result
+		if _, err := w.Write(head[:len(head)-1]); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, `,"findings":[`); err != nil {
+			return err
+		}
+		separator := ""
+		err := payload.EachFinding(func(f scan.Finding) error {
+			data, err := json.Marshal(f)
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, separator); err != nil {
+				return err
+			}
+			separator = ","
+			_, err = w.Write(data)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, "]}")
+		return err
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	var length byteCounter
+	if err := write(io.MultiWriter(mac, &length)); err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %v", err)
+	}
+	body := func() (io.ReadCloser, error) {
+		reader, writer := io.Pipe()
+		go func() {
+			w := bufio.NewWriter(writer)
+			err := write(w)
+			if err == nil {
+				err = w.Flush()
+			}
+			writer.CloseWithError(err)
+		}()
+		return reader, nil
+	}
+
+	req, err := http.NewRequest(http.MethodPost, webhookURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %v", err)
+	}
+	req.Body, _ = body()
+	req.GetBody = body
+	req.ContentLength = int64(length)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "sbom-scanner")
+	if secret != "" {
+		req.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := runenv.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook request failed: %s", resp.Status)
+	}
+
+	summary := payload.Summary()
+	runenv.Logger.Infof("Posted %d findings to webhook %s", summary.Vulnerabilities+summary.Suppressed, webhookHost(webhookURL))
+	return nil
+}
+
+// byteCounter counts the bytes written to it
+type byteCounter int64
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
+
+// webhookHost returns the host of the URL for logging, leaving out paths and
+// query strings that may carry tokens
+func webhookHost(value string) string {
+	if u, err := url.Parse(value); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return "endpoint"
+}
+
+// webhookSecret reads the signing secret from the environment
+func webhookSecret() string {
+	return strings.TrimSpace(os.Getenv(webhookSecretEnv))
+}